@@ -0,0 +1,188 @@
+// Copyright 2024 Juan Pablo Tosso and the OWASP Coraza contributors
+// SPDX-License-Identifier: Apache-2.0
+
+// Package auditlog defines the schema of the audit logs written with
+// SecAuditLogFormat JSON, for the programs consuming them.
+//
+// The schema is versioned with SchemaVersion, MAJOR.MINOR, written in the
+// schema_version field of every entry:
+//   - the minor version is increased when fields are added, the consumers of
+//     a version can read the entries of the later minor versions, the fields
+//     they don't know are ignored
+//   - the major version is increased when fields are renamed, removed or
+//     change type, the consumers must be updated to read these entries
+//
+// The entries written before the schema was versioned have no
+// schema_version, their fields are the ones of version 1.0.
+package auditlog
+
+import (
+	_ "embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// SchemaVersion is the version of the schema of the entries written by this
+// version of the engine
+const SchemaVersion = "1.1"
+
+// Schema is the JSON Schema of the entries of SchemaVersion
+//
+//go:embed schema.json
+var Schema []byte
+
+// ErrIncompatibleVersion is returned for the entries whose schema has a
+// major version other than the one of SchemaVersion
+var ErrIncompatibleVersion = errors.New("incompatible audit log schema version")
+
+// Entry is an audit log entry, one per logged transaction
+type Entry struct {
+	// SchemaVersion is the version of the schema of the entry, empty for the
+	// entries written before the schema was versioned
+	SchemaVersion string      `json:"schema_version,omitempty"`
+	Transaction   Transaction `json:"transaction"`
+	// Messages are the rules matched by the transaction, part K
+	Messages []Message `json:"messages,omitempty"`
+}
+
+// Transaction contains the transaction information
+type Transaction struct {
+	// Timestamp is the start of the transaction, formatted as 2006/01/02 15:04:05
+	Timestamp string `json:"timestamp"`
+	// UnixTimestamp is the start of the transaction in nanoseconds
+	UnixTimestamp int64     `json:"unix_timestamp"`
+	ID            string    `json:"id"`
+	ClientIP      string    `json:"client_ip"`
+	ClientPort    int       `json:"client_port"`
+	HostIP        string    `json:"host_ip"`
+	HostPort      int       `json:"host_port"`
+	ServerID      string    `json:"server_id"`
+	Request       *Request  `json:"request,omitempty"`
+	Response      *Response `json:"response,omitempty"`
+	Producer      *Producer `json:"producer,omitempty"`
+	// HighestSeverity is the highest severity of the matched rules
+	HighestSeverity string `json:"highest_severity"`
+	IsInterrupted   bool   `json:"is_interrupted"`
+}
+
+// Request contains the request information, the headers are logged with
+// part B and the body with part C
+type Request struct {
+	Method      string              `json:"method"`
+	Protocol    string              `json:"protocol"`
+	URI         string              `json:"uri"`
+	HTTPVersion string              `json:"http_version"`
+	Headers     map[string][]string `json:"headers"`
+	Body        string              `json:"body"`
+	// Files are the files uploaded with multipart forms, part I
+	Files []File `json:"files"`
+	// Args is reserved for the request arguments, it is empty or null
+	Args map[string][]string `json:"args"`
+	// Length is the size of the request in bytes
+	Length int32 `json:"length"`
+}
+
+// File is a file uploaded with a multipart form
+type File struct {
+	Name string `json:"name"`
+	Size int64  `json:"size"`
+	Mime string `json:"mime"`
+}
+
+// Response contains the response information, the headers are logged with
+// part F and the body with part E
+type Response struct {
+	Protocol string              `json:"protocol"`
+	Status   int                 `json:"status"`
+	Headers  map[string][]string `json:"headers"`
+	Body     string              `json:"body"`
+}
+
+// Producer contains the information about the engine and the rules that
+// produced the entry, part H
+type Producer struct {
+	Connector     string   `json:"connector"`
+	Version       string   `json:"version"`
+	EngineName    string   `json:"engine_name,omitempty"`
+	EngineVersion string   `json:"engine_version,omitempty"`
+	Server        string   `json:"server"`
+	RuleEngine    string   `json:"rule_engine"`
+	Stopwatch     string   `json:"stopwatch"`
+	Rulesets      []string `json:"rulesets"`
+	// Labels identify the WAF instance that produced the entry
+	Labels map[string]string `json:"labels,omitempty"`
+	// RulesPerformanceInfo lists the rules that took longer than
+	// SecRulePerfTime with their duration in microseconds
+	RulesPerformanceInfo string `json:"rules_performance_info,omitempty"`
+}
+
+// Message contains the information about a matched rule
+type Message struct {
+	Actionset    string       `json:"actionset"`
+	Message      string       `json:"message"`
+	ErrorMessage string       `json:"error_message"`
+	Data         *MessageData `json:"data"`
+}
+
+// MessageData contains the details of a matched rule
+type MessageData struct {
+	File string `json:"file"`
+	Line int    `json:"line"`
+	ID   int    `json:"id"`
+	Rev  string `json:"rev"`
+	Msg  string `json:"msg"`
+	Data string `json:"data"`
+	// Severity is the severity of the rule, from 0 (emergency) to 7 (debug)
+	Severity int      `json:"severity"`
+	Ver      string   `json:"ver"`
+	Maturity int      `json:"maturity"`
+	Accuracy int      `json:"accuracy"`
+	Tags     []string `json:"tags"`
+	Raw      string   `json:"raw"`
+	// Truncated is set when the rule reached SecRuleMatchLimit and some of
+	// its matches were not logged, added in 1.1
+	Truncated bool `json:"truncated,omitempty"`
+}
+
+// Unmarshal parses an entry, it returns ErrIncompatibleVersion if its schema
+// can't be read as SchemaVersion
+func Unmarshal(data []byte) (*Entry, error) {
+	e := &Entry{}
+	if err := json.Unmarshal(data, e); err != nil {
+		return nil, err
+	}
+	if !Compatible(e.SchemaVersion) {
+		return nil, fmt.Errorf("%w: %q, expected %s", ErrIncompatibleVersion, e.SchemaVersion, SchemaVersion)
+	}
+	return e, nil
+}
+
+// Compatible returns whether the entries of a schema version can be read as
+// SchemaVersion, which is the case for the same major version and for the
+// entries without version
+func Compatible(version string) bool {
+	if version == "" {
+		return true
+	}
+	major, ok := majorVersion(version)
+	if !ok {
+		return false
+	}
+	current, _ := majorVersion(SchemaVersion)
+	return major == current
+}
+
+func majorVersion(version string) (int, bool) {
+	major, minor, ok := strings.Cut(version, ".")
+	if !ok {
+		return 0, false
+	}
+	if _, err := strconv.Atoi(minor); err != nil {
+		return 0, false
+	}
+	n, err := strconv.Atoi(major)
+	return n, err == nil
+}
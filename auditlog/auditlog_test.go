@@ -0,0 +1,176 @@
+// Copyright 2024 Juan Pablo Tosso and the OWASP Coraza contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package auditlog
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestUnmarshal(t *testing.T) {
+	// entries of 1.0 must remain readable as long as the major version is 1
+	data, err := os.ReadFile("testdata/entry-1.0.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	e, err := Unmarshal(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want, have := "1.0", e.SchemaVersion; want != have {
+		t.Errorf("unexpected schema version, want %q, have %q", want, have)
+	}
+	if want, have := "AbCdEf", e.Transaction.ID; want != have {
+		t.Errorf("unexpected transaction id, want %q, have %q", want, have)
+	}
+	if want, have := "multipart/form-data; boundary=x", e.Transaction.Request.Headers["Content-Type"][0]; want != have {
+		t.Errorf("unexpected request header, want %q, have %q", want, have)
+	}
+	if want, have := (File{Name: "avatar.png", Size: 1024, Mime: "image/png"}), e.Transaction.Request.Files[0]; want != have {
+		t.Errorf("unexpected file, want %v, have %v", want, have)
+	}
+	if want, have := 403, e.Transaction.Response.Status; want != have {
+		t.Errorf("unexpected response status, want %d, have %d", want, have)
+	}
+	if want, have := "eu-1", e.Transaction.Producer.Labels["cluster"]; want != have {
+		t.Errorf("unexpected label, want %q, have %q", want, have)
+	}
+	if len(e.Messages) != 1 {
+		t.Fatalf("unexpected messages, want 1, have %d", len(e.Messages))
+	}
+	if want, have := 942100, e.Messages[0].Data.ID; want != have {
+		t.Errorf("unexpected rule id, want %d, have %d", want, have)
+	}
+	if want, have := 2, e.Messages[0].Data.Severity; want != have {
+		t.Errorf("unexpected severity, want %d, have %d", want, have)
+	}
+}
+
+func TestUnmarshalVersions(t *testing.T) {
+	tests := []struct {
+		name  string
+		entry string
+		want  error
+	}{
+		{
+			name:  "current version",
+			entry: `{"schema_version":"1.0","transaction":{"id":"1"}}`,
+		},
+		{
+			name:  "unversioned entry",
+			entry: `{"transaction":{"id":"1"}}`,
+		},
+		{
+			name:  "later minor version with added fields",
+			entry: `{"schema_version":"1.3","transaction":{"id":"1","added":true},"added":{}}`,
+		},
+		{
+			name:  "later major version",
+			entry: `{"schema_version":"2.0","transaction":{"id":"1"}}`,
+			want:  ErrIncompatibleVersion,
+		},
+		{
+			name:  "invalid version",
+			entry: `{"schema_version":"one","transaction":{"id":"1"}}`,
+			want:  ErrIncompatibleVersion,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e, err := Unmarshal([]byte(tt.entry))
+			if !errors.Is(err, tt.want) {
+				t.Fatalf("unexpected error, want %v, have %v", tt.want, err)
+			}
+			if err == nil && e.Transaction.ID != "1" {
+				t.Errorf("unexpected transaction id, want %q, have %q", "1", e.Transaction.ID)
+			}
+		})
+	}
+}
+
+func TestCompatible(t *testing.T) {
+	tests := map[string]bool{
+		"":     true,
+		"1.0":  true,
+		"1.12": true,
+		"0.9":  false,
+		"2.0":  false,
+		"1":    false,
+		"1.x":  false,
+	}
+	for version, want := range tests {
+		if have := Compatible(version); want != have {
+			t.Errorf("unexpected compatibility of %q, want %t, have %t", version, want, have)
+		}
+	}
+}
+
+// TestSchema checks that the JSON Schema and the structs describe the same
+// fields, so neither is changed without the other
+func TestSchema(t *testing.T) {
+	var schema map[string]any
+	if err := json.Unmarshal(Schema, &schema); err != nil {
+		t.Fatal(err)
+	}
+	defs, _ := schema["$defs"].(map[string]any)
+	pattern := schema["properties"].(map[string]any)["schema_version"].(map[string]any)["pattern"].(string)
+	if want := "^" + strings.Split(SchemaVersion, ".")[0] + `\.[0-9]+$`; want != pattern {
+		t.Errorf("unexpected schema_version pattern, want %q, have %q", want, pattern)
+	}
+	compareSchema(t, "entry", schema, reflect.TypeOf(Entry{}), defs)
+}
+
+func compareSchema(t *testing.T, path string, schema map[string]any, typ reflect.Type, defs map[string]any) {
+	t.Helper()
+	schema = resolveSchema(schema, defs)
+	properties, _ := schema["properties"].(map[string]any)
+	var fields []string
+	for i := 0; i < typ.NumField(); i++ {
+		f := typ.Field(i)
+		name, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+		fields = append(fields, name)
+		property, ok := properties[name].(map[string]any)
+		if !ok {
+			t.Errorf("field %s.%s is missing from the schema", path, name)
+			continue
+		}
+		ft := f.Type
+		for ft.Kind() == reflect.Pointer || ft.Kind() == reflect.Slice {
+			ft = ft.Elem()
+			if items, ok := property["items"].(map[string]any); ok {
+				property = items
+			}
+		}
+		if ft.Kind() == reflect.Struct {
+			compareSchema(t, path+"."+name, property, ft, defs)
+		}
+	}
+	for name := range properties {
+		if !slices.Contains(fields, name) {
+			t.Errorf("property %s.%s is missing from the structs", path, name)
+		}
+	}
+	required, _ := schema["required"].([]any)
+	for _, name := range required {
+		if !slices.Contains(fields, name.(string)) {
+			t.Errorf("required property %s.%s is missing from the structs", path, name)
+		}
+	}
+}
+
+// resolveSchema follows the references and the nullable alternatives
+func resolveSchema(schema map[string]any, defs map[string]any) map[string]any {
+	if oneOf, ok := schema["oneOf"].([]any); ok {
+		schema = oneOf[0].(map[string]any)
+	}
+	if ref, ok := schema["$ref"].(string); ok {
+		return resolveSchema(defs[strings.TrimPrefix(ref, "#/$defs/")].(map[string]any), defs)
+	}
+	return schema
+}
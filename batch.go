@@ -0,0 +1,260 @@
+// Copyright 2024 Juan Pablo Tosso and the OWASP Coraza contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package seclang
+
+import (
+	"context"
+	"errors"
+	"io"
+	"runtime"
+	"slices"
+	"sync"
+
+	"github.com/ad3n/seclang/internal/corazawaf"
+	stringutils "github.com/ad3n/seclang/internal/strings"
+	"github.com/corazawaf/coraza/v3/types"
+)
+
+// BatchRequest is a stored request evaluated by EvaluateBatch, e.g. read from
+// a corpus of historical traffic
+type BatchRequest struct {
+	// ID is the ID of the transaction, a random one is used if empty
+	ID         string
+	ClientIP   string
+	ClientPort int
+	ServerIP   string
+	ServerPort int
+	Method     string
+	URI        string
+	// Protocol is the HTTP version of the request, HTTP/1.1 if empty
+	Protocol string
+	// Headers are the request headers in the order they were received
+	Headers []BatchHeader
+	Body    []byte
+	// Response is the response to the request, the response phases are
+	// only evaluated if it is set
+	Response *BatchResponse
+}
+
+// BatchResponse is the stored response to a BatchRequest
+type BatchResponse struct {
+	Status int
+	// Protocol is the HTTP version of the response, HTTP/1.1 if empty
+	Protocol string
+	Headers  []BatchHeader
+	Body     []byte
+}
+
+// BatchHeader is a header of a stored request or response
+type BatchHeader struct {
+	Name  string
+	Value string
+}
+
+// BatchSource returns the requests to evaluate, Next returns io.EOF once
+// there are no more requests. Next is never called concurrently.
+type BatchSource interface {
+	Next() (*BatchRequest, error)
+}
+
+// BatchRequests returns a source of the requests of a slice
+func BatchRequests(requests []BatchRequest) BatchSource {
+	return &sliceBatchSource{requests: requests}
+}
+
+type sliceBatchSource struct {
+	requests []BatchRequest
+	next     int
+}
+
+func (s *sliceBatchSource) Next() (*BatchRequest, error) {
+	if s.next >= len(s.requests) {
+		return nil, io.EOF
+	}
+	s.next++
+	return &s.requests[s.next-1], nil
+}
+
+// BatchOptions configures EvaluateBatch
+type BatchOptions struct {
+	// Concurrency is the number of requests evaluated at once, GOMAXPROCS
+	// if 0 or negative
+	Concurrency int
+	// Log processes the logging phase of the transactions, so the matched
+	// requests are written to the audit log of the WAF
+	Log bool
+	// OnResult is called with the result of each request once evaluated.
+	// The calls are not concurrent, but they don't follow the order of the
+	// source.
+	OnResult func(BatchResult)
+}
+
+// BatchResult is the result of the evaluation of a request
+type BatchResult struct {
+	Request       *BatchRequest
+	TransactionID string
+	// Rules are the IDs of the rules the request matched, in the order
+	// they matched
+	Rules []int
+	// Interruption is the disruptive action of the rules, nil if the
+	// request was not interrupted
+	Interruption *types.Interruption
+	// Err is the error evaluating the request, e.g. a request body over
+	// the limit rejected by SecRequestBodyLimitAction Reject
+	Err error
+}
+
+// BatchReport aggregates the results of the requests evaluated by EvaluateBatch
+type BatchReport struct {
+	// Requests is the number of evaluated requests
+	Requests int
+	// Interrupted is the number of interrupted requests
+	Interrupted int
+	// Failed is the number of requests whose evaluation returned an error
+	Failed int
+	// Rules is the number of requests matched by each rule ID
+	Rules map[int]int
+	// Interruptions is the number of requests interrupted by each rule ID
+	Interruptions map[int]int
+}
+
+// EvaluateBatch evaluates the requests of the source against the WAF
+// concurrently and aggregates their results, e.g. to scan historical traffic
+// with newly added rules. The transactions are created from the pool of the
+// WAF and closed once evaluated. It stops at the first error of the source or
+// once the context is done, returning the error with the report of the
+// requests evaluated so far.
+func EvaluateBatch(ctx context.Context, waf *corazawaf.WAF, source BatchSource, opts BatchOptions) (*BatchReport, error) {
+	concurrency := opts.Concurrency
+	if concurrency <= 0 {
+		concurrency = runtime.GOMAXPROCS(0)
+	}
+
+	requests := make(chan *BatchRequest)
+	results := make(chan BatchResult)
+	var sourceErr error
+	go func() {
+		defer close(requests)
+		for {
+			req, err := source.Next()
+			if err != nil {
+				if !errors.Is(err, io.EOF) {
+					sourceErr = err
+				}
+				return
+			}
+			select {
+			case requests <- req:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	var wg sync.WaitGroup
+	for i := 0; i < concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for req := range requests {
+				results <- evaluateBatchRequest(ctx, waf, req, opts.Log)
+			}
+		}()
+	}
+	go func() {
+		wg.Wait()
+		close(results)
+	}()
+
+	report := &BatchReport{Rules: map[int]int{}, Interruptions: map[int]int{}}
+	for res := range results {
+		report.Requests++
+		if res.Err != nil {
+			report.Failed++
+		}
+		for _, id := range res.Rules {
+			report.Rules[id]++
+		}
+		if res.Interruption != nil {
+			report.Interrupted++
+			report.Interruptions[res.Interruption.RuleID]++
+		}
+		if opts.OnResult != nil {
+			opts.OnResult(res)
+		}
+	}
+	// the source goroutine is done once the requests channel is closed
+	if sourceErr != nil {
+		return report, sourceErr
+	}
+	return report, ctx.Err()
+}
+
+func evaluateBatchRequest(ctx context.Context, waf *corazawaf.WAF, req *BatchRequest, log bool) BatchResult {
+	id := req.ID
+	if id == "" {
+		id = stringutils.RandomString(19)
+	}
+	tx := waf.NewTransactionWithOptions(corazawaf.Options{ID: id, Context: ctx})
+	res := BatchResult{Request: req, TransactionID: tx.ID()}
+	res.Interruption, res.Err = processBatchRequest(tx, req)
+	if log {
+		tx.ProcessLogging()
+	}
+	for _, mr := range tx.MatchedRules() {
+		// rules matching in several phases are listed once
+		if id := mr.Rule().ID(); !slices.Contains(res.Rules, id) {
+			res.Rules = append(res.Rules, id)
+		}
+	}
+	if err := tx.Close(); err != nil && res.Err == nil {
+		res.Err = err
+	}
+	return res
+}
+
+// processBatchRequest evaluates the phases of the request and of its
+// response until one of them is interrupted
+func processBatchRequest(tx *corazawaf.Transaction, req *BatchRequest) (*types.Interruption, error) {
+	tx.ProcessConnection(req.ClientIP, req.ClientPort, req.ServerIP, req.ServerPort)
+	tx.ProcessURI(req.URI, req.Method, batchProtocol(req.Protocol))
+	for _, h := range req.Headers {
+		tx.AddRequestHeader(h.Name, h.Value)
+	}
+	if it := tx.ProcessRequestHeaders(); it != nil {
+		return it, nil
+	}
+	if len(req.Body) > 0 {
+		if it, _, err := tx.WriteRequestBody(req.Body); it != nil || err != nil {
+			return it, err
+		}
+	}
+	if it, err := tx.ProcessRequestBody(); it != nil || err != nil {
+		return it, err
+	}
+
+	res := req.Response
+	if res == nil {
+		return nil, nil
+	}
+	for _, h := range res.Headers {
+		tx.AddResponseHeader(h.Name, h.Value)
+	}
+	if it := tx.ProcessResponseHeaders(res.Status, batchProtocol(res.Protocol)); it != nil {
+		return it, nil
+	}
+	if len(res.Body) > 0 {
+		if it, _, err := tx.WriteResponseBody(res.Body); it != nil || err != nil {
+			return it, err
+		}
+	}
+	return tx.ProcessResponseBody()
+}
+
+func batchProtocol(protocol string) string {
+	if protocol == "" {
+		return "HTTP/1.1"
+	}
+	return protocol
+}
@@ -0,0 +1,214 @@
+// Copyright 2024 Juan Pablo Tosso and the OWASP Coraza contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package seclang
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// harEntry is an entry of a HAR 1.2 archive, the fields the requests are
+// built from
+type harEntry struct {
+	ServerIPAddress string      `json:"serverIPAddress"`
+	Request         harRequest  `json:"request"`
+	Response        harResponse `json:"response"`
+}
+
+type harRequest struct {
+	Method      string      `json:"method"`
+	URL         string      `json:"url"`
+	HTTPVersion string      `json:"httpVersion"`
+	Headers     []harHeader `json:"headers"`
+	PostData    *struct {
+		MimeType string      `json:"mimeType"`
+		Text     string      `json:"text"`
+		Params   []harHeader `json:"params"`
+	} `json:"postData"`
+}
+
+type harResponse struct {
+	Status      int         `json:"status"`
+	HTTPVersion string      `json:"httpVersion"`
+	Headers     []harHeader `json:"headers"`
+	Content     struct {
+		Text     string `json:"text"`
+		Encoding string `json:"encoding"`
+	} `json:"content"`
+}
+
+type harHeader struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+// NewHARSource returns a source of the requests of a HAR archive, e.g.
+// exported from the developer tools of a browser to report a false
+// positive. The entries are decoded one at a time, so large archives are
+// not loaded in memory. Their responses are evaluated too, except the ones
+// the browser didn't receive. The pseudo-headers of HTTP/2 are dropped and
+// the Host header is added from the URL if missing.
+func NewHARSource(r io.Reader) BatchSource {
+	return &harSource{dec: json.NewDecoder(r)}
+}
+
+type harSource struct {
+	dec *json.Decoder
+	// entries is set once the decoder is in the entries array
+	entries bool
+	done    bool
+}
+
+func (s *harSource) Next() (*BatchRequest, error) {
+	if s.done {
+		return nil, io.EOF
+	}
+	if !s.entries {
+		if err := s.seekEntries(); err != nil {
+			s.done = true
+			return nil, err
+		}
+		s.entries = true
+	}
+	if !s.dec.More() {
+		s.done = true
+		return nil, io.EOF
+	}
+	var e harEntry
+	if err := s.dec.Decode(&e); err != nil {
+		s.done = true
+		return nil, fmt.Errorf("invalid HAR entry: %s", err.Error())
+	}
+	req, err := e.batchRequest()
+	if err != nil {
+		s.done = true
+		return nil, err
+	}
+	return req, nil
+}
+
+// seekEntries moves the decoder into the log.entries array
+func (s *harSource) seekEntries() error {
+	for _, key := range []string{"log", "entries"} {
+		if err := s.seekKey(key); err != nil {
+			return err
+		}
+	}
+	if t, err := s.dec.Token(); err != nil || t != json.Delim('[') {
+		return errors.New("invalid HAR archive, log.entries is not an array")
+	}
+	return nil
+}
+
+// seekKey moves the decoder to the value of the key of the next object,
+// skipping the values of the previous keys
+func (s *harSource) seekKey(key string) error {
+	if t, err := s.dec.Token(); err != nil || t != json.Delim('{') {
+		return fmt.Errorf("invalid HAR archive, expected an object holding %s", key)
+	}
+	for s.dec.More() {
+		t, err := s.dec.Token()
+		if err != nil {
+			return fmt.Errorf("invalid HAR archive: %s", err.Error())
+		}
+		if t == key {
+			return nil
+		}
+		var skip json.RawMessage
+		if err := s.dec.Decode(&skip); err != nil {
+			return fmt.Errorf("invalid HAR archive: %s", err.Error())
+		}
+	}
+	return fmt.Errorf("invalid HAR archive, missing %s", key)
+}
+
+func (e *harEntry) batchRequest() (*BatchRequest, error) {
+	u, err := url.Parse(e.Request.URL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid HAR request URL %q: %s", e.Request.URL, err.Error())
+	}
+	req := &BatchRequest{
+		ServerIP:   strings.Trim(e.ServerIPAddress, "[]"),
+		ServerPort: harPort(u),
+		Method:     e.Request.Method,
+		URI:        u.RequestURI(),
+		Protocol:   harProtocol(e.Request.HTTPVersion),
+	}
+	req.Headers = harHeaders(e.Request.Headers)
+	if !slices.ContainsFunc(req.Headers, func(h BatchHeader) bool {
+		return strings.EqualFold(h.Name, "Host")
+	}) && u.Host != "" {
+		req.Headers = append(req.Headers, BatchHeader{Name: "Host", Value: u.Host})
+	}
+	if pd := e.Request.PostData; pd != nil {
+		req.Body = []byte(pd.Text)
+		// the browsers may only record the parameters of the forms
+		if pd.Text == "" && len(pd.Params) > 0 && strings.HasPrefix(pd.MimeType, "application/x-www-form-urlencoded") {
+			form := url.Values{}
+			for _, p := range pd.Params {
+				form.Add(p.Name, p.Value)
+			}
+			req.Body = []byte(form.Encode())
+		}
+	}
+
+	// the status is 0 for the responses that were not received
+	if e.Response.Status > 0 {
+		res := &BatchResponse{
+			Status:   e.Response.Status,
+			Protocol: harProtocol(e.Response.HTTPVersion),
+			Headers:  harHeaders(e.Response.Headers),
+			Body:     []byte(e.Response.Content.Text),
+		}
+		if e.Response.Content.Encoding == "base64" {
+			if res.Body, err = base64.StdEncoding.DecodeString(e.Response.Content.Text); err != nil {
+				return nil, fmt.Errorf("invalid HAR response content of %q: %s", e.Request.URL, err.Error())
+			}
+		}
+		req.Response = res
+	}
+	return req, nil
+}
+
+// harHeaders returns the headers without the pseudo-headers of HTTP/2
+func harHeaders(headers []harHeader) []BatchHeader {
+	var res []BatchHeader
+	for _, h := range headers {
+		if !strings.HasPrefix(h.Name, ":") {
+			res = append(res, BatchHeader(h))
+		}
+	}
+	return res
+}
+
+func harPort(u *url.URL) int {
+	if port, err := strconv.Atoi(u.Port()); err == nil {
+		return port
+	}
+	if u.Scheme == "https" {
+		return 443
+	}
+	return 80
+}
+
+// harProtocol normalizes the HTTP versions recorded by the browsers, e.g. h2
+func harProtocol(version string) string {
+	switch v := strings.ToLower(version); v {
+	case "":
+		return ""
+	case "h2", "http/2", "http/2.0":
+		return "HTTP/2.0"
+	case "h3", "http/3", "http/3.0":
+		return "HTTP/3.0"
+	default:
+		return strings.ToUpper(v)
+	}
+}
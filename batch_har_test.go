@@ -0,0 +1,135 @@
+// Copyright 2024 Juan Pablo Tosso and the OWASP Coraza contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package seclang
+
+import (
+	"context"
+	"errors"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const testHAR = `{
+  "log": {
+    "version": "1.2",
+    "creator": {"name": "Firefox", "version": "128.0"},
+    "pages": [{"id": "page_1", "title": "login"}],
+    "entries": [
+      {
+        "serverIPAddress": "[2001:db8::1]",
+        "request": {
+          "method": "POST",
+          "url": "https://example.com:8443/login?next=%2Fhome",
+          "httpVersion": "h2",
+          "headers": [
+            {"name": ":authority", "value": "example.com:8443"},
+            {"name": "user-agent", "value": "Mozilla/5.0"},
+            {"name": "content-type", "value": "application/x-www-form-urlencoded"}
+          ],
+          "postData": {
+            "mimeType": "application/x-www-form-urlencoded",
+            "params": [{"name": "user", "value": "alice"}, {"name": "pass", "value": "1' or '1'='1"}]
+          }
+        },
+        "response": {
+          "status": 200,
+          "httpVersion": "h2",
+          "headers": [{"name": "content-type", "value": "text/html"}],
+          "content": {"mimeType": "text/html", "text": "PHA+c3RhY2sgdHJhY2U8L3A+", "encoding": "base64"}
+        }
+      },
+      {
+        "serverIPAddress": "192.0.2.1",
+        "request": {
+          "method": "GET",
+          "url": "http://example.com/search?q=attack",
+          "httpVersion": "HTTP/1.1",
+          "headers": [{"name": "Host", "value": "example.com"}]
+        },
+        "response": {"status": 0, "headers": [], "content": {}}
+      }
+    ]
+  }
+}`
+
+func TestHARSource(t *testing.T) {
+	s := NewHARSource(strings.NewReader(testHAR))
+	var requests []*BatchRequest
+	for {
+		req, err := s.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		requests = append(requests, req)
+	}
+	want := []*BatchRequest{
+		{
+			ServerIP:   "2001:db8::1",
+			ServerPort: 8443,
+			Method:     "POST",
+			URI:        "/login?next=%2Fhome",
+			Protocol:   "HTTP/2.0",
+			Headers: []BatchHeader{
+				{Name: "user-agent", Value: "Mozilla/5.0"},
+				{Name: "content-type", Value: "application/x-www-form-urlencoded"},
+				{Name: "Host", Value: "example.com:8443"},
+			},
+			Body: []byte("pass=1%27+or+%271%27%3D%271&user=alice"),
+			Response: &BatchResponse{
+				Status:   200,
+				Protocol: "HTTP/2.0",
+				Headers:  []BatchHeader{{Name: "content-type", Value: "text/html"}},
+				Body:     []byte("<p>stack trace</p>"),
+			},
+		},
+		{
+			ServerIP:   "192.0.2.1",
+			ServerPort: 80,
+			Method:     "GET",
+			URI:        "/search?q=attack",
+			Protocol:   "HTTP/1.1",
+			Headers:    []BatchHeader{{Name: "Host", Value: "example.com"}},
+		},
+	}
+	if !reflect.DeepEqual(want, requests) {
+		t.Errorf("unexpected requests, want %+v, have %+v", want, requests)
+	}
+}
+
+func TestHARSourceEvaluateBatch(t *testing.T) {
+	report, err := EvaluateBatch(context.Background(), newBatchWAF(t), NewHARSource(strings.NewReader(testHAR)), BatchOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want, have := map[int]int{1: 1, 4: 1}, report.Rules; !reflect.DeepEqual(want, have) {
+		t.Errorf("unexpected rules, want %v, have %v", want, have)
+	}
+}
+
+func TestHARSourceInvalid(t *testing.T) {
+	tests := map[string]string{
+		"not an object":   `[]`,
+		"missing entries": `{"log": {"version": "1.2"}}`,
+		"invalid entries": `{"log": {"entries": {}}}`,
+		"invalid entry":   `{"log": {"entries": [{"request": []}]}}`,
+		"invalid url":     `{"log": {"entries": [{"request": {"url": "http://[::1"}}]}}`,
+		"invalid body":    `{"log": {"entries": [{"request": {"url": "/"}, "response": {"status": 200, "content": {"text": "!", "encoding": "base64"}}}]}}`,
+	}
+	for name, har := range tests {
+		t.Run(name, func(t *testing.T) {
+			s := NewHARSource(strings.NewReader(har))
+			if _, err := s.Next(); err == nil || errors.Is(err, io.EOF) {
+				t.Errorf("expected error, have %v", err)
+			}
+			if _, err := s.Next(); !errors.Is(err, io.EOF) {
+				t.Errorf("expected the source to be done, have %v", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,158 @@
+// Copyright 2024 Juan Pablo Tosso and the OWASP Coraza contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package seclang
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"slices"
+	"sync/atomic"
+	"testing"
+
+	"github.com/ad3n/seclang/internal/corazawaf"
+)
+
+func newBatchWAF(t *testing.T) *corazawaf.WAF {
+	t.Helper()
+	waf := corazawaf.NewWAF()
+	err := NewParser(waf).FromString(`
+SecRuleEngine On
+SecRequestBodyAccess On
+SecResponseBodyAccess On
+SecResponseBodyMimeType text/html
+SecRule REQUEST_HEADERS:User-Agent "@contains scanner" "id:2,phase:1,pass,log"
+SecRule ARGS_GET:q "@contains attack" "id:1,phase:1,deny,status:403"
+SecRule ARGS_POST:user "@contains admin" "id:3,phase:2,deny,status:403"
+SecRule RESPONSE_BODY "@contains stack trace" "id:4,phase:4,pass,log"
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return waf
+}
+
+func TestEvaluateBatch(t *testing.T) {
+	waf := newBatchWAF(t)
+	var requests []BatchRequest
+	for i := 0; i < 100; i++ {
+		req := BatchRequest{
+			ID:     fmt.Sprintf("tx-%d", i),
+			Method: "GET",
+			URI:    "/search?q=hello",
+		}
+		switch i % 5 {
+		case 1:
+			req.URI = "/search?q=attack"
+			req.Headers = []BatchHeader{{Name: "User-Agent", Value: "scanner"}}
+		case 2:
+			req.Method = "POST"
+			req.Headers = []BatchHeader{{Name: "Content-Type", Value: "application/x-www-form-urlencoded"}}
+			req.Body = []byte("user=admin")
+		case 3:
+			req.Response = &BatchResponse{
+				Status:  500,
+				Headers: []BatchHeader{{Name: "Content-Type", Value: "text/html"}},
+				Body:    []byte("<pre>stack trace</pre>"),
+			}
+		}
+		requests = append(requests, req)
+	}
+
+	var results []BatchResult
+	report, err := EvaluateBatch(context.Background(), waf, BatchRequests(requests), BatchOptions{
+		Concurrency: 4,
+		OnResult: func(res BatchResult) {
+			results = append(results, res)
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want, have := 100, report.Requests; want != have {
+		t.Errorf("unexpected requests, want %d, have %d", want, have)
+	}
+	if want, have := 40, report.Interrupted; want != have {
+		t.Errorf("unexpected interrupted requests, want %d, have %d", want, have)
+	}
+	if want, have := map[int]int{1: 20, 2: 20, 3: 20, 4: 20}, report.Rules; !maps.Equal(want, have) {
+		t.Errorf("unexpected rules, want %v, have %v", want, have)
+	}
+	if want, have := map[int]int{1: 20, 3: 20}, report.Interruptions; !maps.Equal(want, have) {
+		t.Errorf("unexpected interruptions, want %v, have %v", want, have)
+	}
+	if want, have := 100, len(results); want != have {
+		t.Fatalf("unexpected results, want %d, have %d", want, have)
+	}
+	for _, res := range results {
+		if res.TransactionID != res.Request.ID {
+			t.Errorf("unexpected transaction id, want %q, have %q", res.Request.ID, res.TransactionID)
+		}
+		if res.Request.ID == "tx-1" && !slices.Equal(res.Rules, []int{2, 1}) {
+			t.Errorf("unexpected rules of tx-1, want [2 1], have %v", res.Rules)
+		}
+	}
+}
+
+type failingBatchSource struct {
+	requests int
+}
+
+func (s *failingBatchSource) Next() (*BatchRequest, error) {
+	if s.requests == 0 {
+		return nil, errors.New("corrupted corpus")
+	}
+	s.requests--
+	return &BatchRequest{Method: "GET", URI: "/"}, nil
+}
+
+func TestEvaluateBatchSourceError(t *testing.T) {
+	report, err := EvaluateBatch(context.Background(), newBatchWAF(t), &failingBatchSource{requests: 3}, BatchOptions{})
+	if err == nil || err.Error() != "corrupted corpus" {
+		t.Errorf("unexpected error, want corrupted corpus, have %v", err)
+	}
+	if want, have := 3, report.Requests; want != have {
+		t.Errorf("unexpected requests, want %d, have %d", want, have)
+	}
+}
+
+type endlessBatchSource struct {
+	requests atomic.Int64
+}
+
+func (s *endlessBatchSource) Next() (*BatchRequest, error) {
+	s.requests.Add(1)
+	return &BatchRequest{Method: "GET", URI: "/"}, nil
+}
+
+func TestEvaluateBatchCancel(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	source := &endlessBatchSource{}
+	report, err := EvaluateBatch(ctx, newBatchWAF(t), source, BatchOptions{
+		Concurrency: 2,
+		OnResult: func(res BatchResult) {
+			if res.Request != nil {
+				cancel()
+			}
+		},
+	})
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("unexpected error, want %v, have %v", context.Canceled, err)
+	}
+	if report.Requests == 0 || int64(report.Requests) > source.requests.Load() {
+		t.Errorf("unexpected requests, have %d of %d read", report.Requests, source.requests.Load())
+	}
+}
+
+func TestBatchRequests(t *testing.T) {
+	s := BatchRequests([]BatchRequest{{ID: "1"}})
+	if req, err := s.Next(); err != nil || req.ID != "1" {
+		t.Errorf("unexpected request, have %v, %v", req, err)
+	}
+	if _, err := s.Next(); !errors.Is(err, io.EOF) {
+		t.Errorf("unexpected error, want %v, have %v", io.EOF, err)
+	}
+}
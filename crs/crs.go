@@ -0,0 +1,187 @@
+// Copyright 2024 Juan Pablo Tosso and the OWASP Coraza contributors
+// SPDX-License-Identifier: Apache-2.0
+
+// Package crs loads the OWASP Core Rule Set embedded by
+// github.com/corazawaf/coraza-coreruleset with the recommended configuration,
+// the settings of crs-setup.conf being set from Go:
+//
+//	waf := corazawaf.NewWAF()
+//	p, err := crs.NewParser(waf, crs.Config{RuleEngine: "On", ParanoiaLevel: 2})
+//	if err != nil {
+//		...
+//	}
+//	// exclusions can be added after the rules
+//	err = p.FromString(`SecRuleRemoveById 920350`)
+package crs
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/ad3n/seclang"
+	"github.com/ad3n/seclang/internal/corazawaf"
+	coreruleset "github.com/corazawaf/coraza-coreruleset"
+	"github.com/jcchavezs/mergefs"
+	"github.com/jcchavezs/mergefs/io"
+)
+
+// The files of the embedded rule set
+const (
+	recommendedFile = "@coraza.conf-recommended"
+	setupFile       = "@crs-setup.conf.example"
+	rulesFiles      = "@owasp_crs/*.conf"
+)
+
+// Config holds the settings of crs-setup.conf, the zero values keep the
+// defaults of the rule set.
+type Config struct {
+	// RuleEngine overrides the SecRuleEngine of the recommended configuration,
+	// DetectionOnly, it is one of On, DetectionOnly and Off.
+	RuleEngine string
+
+	// ParanoiaLevel is the blocking paranoia level, from 1 to 4, 1 by default
+	ParanoiaLevel int
+	// DetectionParanoiaLevel runs the rules up to this level without adding
+	// them to the anomaly score, it defaults to ParanoiaLevel
+	DetectionParanoiaLevel int
+	// DropHigherParanoiaLevels doesn't load the rules above the detection
+	// paranoia level instead of skipping them at runtime, the paranoia level
+	// can then not be raised by the transactions
+	DropHigherParanoiaLevels bool
+
+	// InboundAnomalyThreshold is the inbound anomaly score blocking a
+	// request, 5 by default
+	InboundAnomalyThreshold int
+	// OutboundAnomalyThreshold is the outbound anomaly score blocking a
+	// response, 4 by default
+	OutboundAnomalyThreshold int
+
+	// EarlyBlocking blocks the requests and responses in the headers phases
+	// when the anomaly score is already reached
+	EarlyBlocking bool
+
+	// TX sets other variables of crs-setup.conf, e.g. allowed_methods, they
+	// take precedence over the fields above
+	TX map[string]string
+
+	// Root is the filesystem of the local files, like the SecDataDir or the
+	// files included after the rules, the OS filesystem by default
+	Root fs.FS
+}
+
+var txNameRegex = regexp.MustCompile(`^[a-zA-Z0-9_.]+$`)
+
+func (c Config) validate() error {
+	switch c.RuleEngine {
+	case "", "On", "DetectionOnly", "Off":
+	default:
+		return fmt.Errorf("invalid rule engine %q", c.RuleEngine)
+	}
+	if c.ParanoiaLevel < 0 || c.ParanoiaLevel > 4 {
+		return fmt.Errorf("invalid paranoia level %d", c.ParanoiaLevel)
+	}
+	if c.DetectionParanoiaLevel != 0 &&
+		(c.DetectionParanoiaLevel > 4 || c.DetectionParanoiaLevel < max(c.ParanoiaLevel, 1)) {
+		return fmt.Errorf("invalid detection paranoia level %d, it must be between the paranoia level and 4", c.DetectionParanoiaLevel)
+	}
+	if c.InboundAnomalyThreshold < 0 || c.OutboundAnomalyThreshold < 0 {
+		return errors.New("anomaly thresholds can't be negative")
+	}
+	for name, value := range c.TX {
+		if !txNameRegex.MatchString(name) {
+			return fmt.Errorf("invalid TX variable name %q", name)
+		}
+		if strings.ContainsAny(value, "'\"\\\n") {
+			return fmt.Errorf("invalid value for TX variable %q", name)
+		}
+	}
+	return nil
+}
+
+// setvars returns the TX variables set by the configuration
+func (c Config) setvars() map[string]string {
+	tx := map[string]string{}
+	if c.ParanoiaLevel > 0 {
+		tx["blocking_paranoia_level"] = fmt.Sprint(c.ParanoiaLevel)
+	}
+	if c.DetectionParanoiaLevel > 0 {
+		tx["detection_paranoia_level"] = fmt.Sprint(c.DetectionParanoiaLevel)
+	}
+	if c.InboundAnomalyThreshold > 0 {
+		tx["inbound_anomaly_score_threshold"] = fmt.Sprint(c.InboundAnomalyThreshold)
+	}
+	if c.OutboundAnomalyThreshold > 0 {
+		tx["outbound_anomaly_score_threshold"] = fmt.Sprint(c.OutboundAnomalyThreshold)
+	}
+	if c.EarlyBlocking {
+		tx["early_blocking"] = "1"
+	}
+	for name, value := range c.TX {
+		tx[strings.ToLower(name)] = value
+	}
+	return tx
+}
+
+// Directives returns the directives applying the configuration, they are
+// loaded between crs-setup.conf and the rules. The variables are set by
+// the rule 900000, which crs-setup.conf reserves for the paranoia level.
+func (c Config) Directives() (string, error) {
+	if err := c.validate(); err != nil {
+		return "", err
+	}
+	var sb strings.Builder
+	if c.RuleEngine != "" {
+		fmt.Fprintf(&sb, "SecRuleEngine %s\n", c.RuleEngine)
+	}
+	tx := c.setvars()
+	if len(tx) == 0 {
+		return sb.String(), nil
+	}
+	names := make([]string, 0, len(tx))
+	for name := range tx {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	sb.WriteString(`SecAction "id:900000,phase:1,pass,t:none,nolog`)
+	for _, name := range names {
+		fmt.Fprintf(&sb, `,setvar:'tx.%s=%s'`, name, tx[name])
+	}
+	sb.WriteString("\"\n")
+	return sb.String(), nil
+}
+
+// NewParser returns a parser of the WAF with the rule set loaded, the files
+// of the rule set are resolved in the embedded filesystem and the others in
+// the root of the configuration.
+func NewParser(waf *corazawaf.WAF, c Config) (*seclang.Parser, error) {
+	directives, err := c.Directives()
+	if err != nil {
+		return nil, err
+	}
+	root := c.Root
+	if root == nil {
+		root = io.OSFS
+	}
+	p := seclang.NewParser(waf)
+	p.SetRoot(mergefs.Merge(coreruleset.FS, root))
+	if c.DropHigherParanoiaLevels {
+		p.SetMaxParanoiaLevel(max(c.DetectionParanoiaLevel, c.ParanoiaLevel, 1))
+	}
+	if err := p.FromFile(recommendedFile); err != nil {
+		return nil, err
+	}
+	if err := p.FromFile(setupFile); err != nil {
+		return nil, err
+	}
+	if err := p.FromString(directives); err != nil {
+		return nil, err
+	}
+	if err := p.FromFile(rulesFiles); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
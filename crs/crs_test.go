@@ -0,0 +1,113 @@
+// Copyright 2024 Juan Pablo Tosso and the OWASP Coraza contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package crs
+
+import (
+	"testing"
+
+	"github.com/ad3n/seclang/internal/corazawaf"
+	"github.com/corazawaf/coraza/v3/types"
+)
+
+func TestNewParser(t *testing.T) {
+	waf := corazawaf.NewWAF()
+	_, err := NewParser(waf, Config{
+		RuleEngine:              "On",
+		ParanoiaLevel:           2,
+		InboundAnomalyThreshold: 10,
+		TX:                      map[string]string{"allowed_methods": "GET HEAD POST"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if waf.RuleEngine != types.RuleEngineOn {
+		t.Errorf("unexpected rule engine, want On, have %s", waf.RuleEngine)
+	}
+	if waf.Rules.Count() < 100 {
+		t.Errorf("expected the rules to be loaded, have %d", waf.Rules.Count())
+	}
+
+	tx := waf.NewTransaction()
+	defer tx.Close()
+	tx.ProcessConnection("127.0.0.1", 1234, "127.0.0.1", 80)
+	tx.ProcessURI("/", "PUT", "HTTP/1.1")
+	tx.AddRequestHeader("Host", "example.com")
+	tx.ProcessRequestHeaders()
+	for name, want := range map[string]string{
+		"blocking_paranoia_level":          "2",
+		"detection_paranoia_level":         "2",
+		"inbound_anomaly_score_threshold":  "10",
+		"outbound_anomaly_score_threshold": "4",
+		"allowed_methods":                  "GET HEAD POST",
+	} {
+		if have := tx.Variables().TX().Get(name); len(have) != 1 || have[0] != want {
+			t.Errorf("unexpected tx.%s, want %q, have %q", name, want, have)
+		}
+	}
+	// PUT is not allowed, rule 911100 adds a critical anomaly score
+	if have := tx.Variables().TX().Get("inbound_anomaly_score_pl1"); len(have) != 1 || have[0] != "5" {
+		t.Errorf("expected the method to be blocked, have score %q", have)
+	}
+}
+
+func TestDirectives(t *testing.T) {
+	tests := []struct {
+		config Config
+		want   string
+	}{
+		{Config{}, ""},
+		{Config{RuleEngine: "DetectionOnly"}, "SecRuleEngine DetectionOnly\n"},
+		{
+			Config{ParanoiaLevel: 1, DetectionParanoiaLevel: 3, OutboundAnomalyThreshold: 8, EarlyBlocking: true},
+			`SecAction "id:900000,phase:1,pass,t:none,nolog,setvar:'tx.blocking_paranoia_level=1',setvar:'tx.detection_paranoia_level=3',setvar:'tx.early_blocking=1',setvar:'tx.outbound_anomaly_score_threshold=8'"` + "\n",
+		},
+		{
+			Config{ParanoiaLevel: 1, TX: map[string]string{"Blocking_Paranoia_Level": "2"}},
+			`SecAction "id:900000,phase:1,pass,t:none,nolog,setvar:'tx.blocking_paranoia_level=2'"` + "\n",
+		},
+	}
+	for _, tt := range tests {
+		have, err := tt.config.Directives()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if tt.want != have {
+			t.Errorf("unexpected directives for %+v, want %q, have %q", tt.config, tt.want, have)
+		}
+	}
+
+	for _, c := range []Config{
+		{RuleEngine: "on"},
+		{ParanoiaLevel: 5},
+		{ParanoiaLevel: 2, DetectionParanoiaLevel: 1},
+		{InboundAnomalyThreshold: -1},
+		{TX: map[string]string{"a b": "1"}},
+		{TX: map[string]string{"a": "1'"}},
+	} {
+		if _, err := c.Directives(); err == nil {
+			t.Errorf("expected error for %+v", c)
+		}
+		if _, err := NewParser(corazawaf.NewWAF(), c); err == nil {
+			t.Errorf("expected error creating a parser for %+v", c)
+		}
+	}
+}
+
+func TestDropHigherParanoiaLevels(t *testing.T) {
+	all := corazawaf.NewWAF()
+	if _, err := NewParser(all, Config{ParanoiaLevel: 1}); err != nil {
+		t.Fatal(err)
+	}
+	dropped := corazawaf.NewWAF()
+	if _, err := NewParser(dropped, Config{ParanoiaLevel: 1, DropHigherParanoiaLevels: true}); err != nil {
+		t.Fatal(err)
+	}
+	if dropped.Rules.Count() >= all.Rules.Count() {
+		t.Errorf("expected fewer rules, have %d of %d", dropped.Rules.Count(), all.Rules.Count())
+	}
+	// 942100 is a paranoia level 1 rule, 942101 a paranoia level 2 one
+	if dropped.Rules.FindByID(942100) == nil || dropped.Rules.FindByID(942101) != nil {
+		t.Error("expected only the rules of paranoia level 1 to be loaded")
+	}
+}
@@ -0,0 +1,83 @@
+// Copyright 2024 Juan Pablo Tosso and the OWASP Coraza contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package seclang
+
+import (
+	"sync"
+)
+
+// DatasetRegistry shares datasets between WAF instances. Datasets defined with
+// SecDataset are scoped to the WAF of the parser, parsers opting in with
+// ShareDatasets also publish them to the registry and resolve the datasets they
+// don't define from it. It is safe for concurrent use.
+type DatasetRegistry struct {
+	mu       sync.RWMutex
+	datasets map[string][]string
+}
+
+// NewDatasetRegistry returns an empty registry
+func NewDatasetRegistry() *DatasetRegistry {
+	return &DatasetRegistry{datasets: map[string][]string{}}
+}
+
+// Set adds or replaces a dataset, rules compiled before keep the previous values
+func (r *DatasetRegistry) Set(name string, values []string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.datasets[name] = append([]string(nil), values...)
+}
+
+// Get returns the values of a dataset
+func (r *DatasetRegistry) Get(name string) ([]string, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	values, ok := r.datasets[name]
+	return values, ok
+}
+
+// clone returns a registry holding the datasets of r, nil if r is nil
+func (r *DatasetRegistry) clone() *DatasetRegistry {
+	if r == nil {
+		return nil
+	}
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	c := NewDatasetRegistry()
+	for name, values := range r.datasets {
+		c.datasets[name] = values
+	}
+	return c
+}
+
+// resolve returns the datasets visible to a rule, the ones of the WAF take
+// precedence over the shared ones
+func (r *DatasetRegistry) resolve(local map[string][]string) map[string][]string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	datasets := make(map[string][]string, len(r.datasets)+len(local))
+	for name, values := range r.datasets {
+		datasets[name] = values
+	}
+	for name, values := range local {
+		datasets[name] = values
+	}
+	return datasets
+}
+
+// ShareDatasets opts the parser in to a registry shared with other WAF
+// instances: the datasets defined with SecDataset are published to it, and
+// rules can reference the datasets of the registry, unless the WAF defines
+// its own dataset with the same name.
+//
+// Example:
+// ```go
+//
+//	shared := seclang.NewDatasetRegistry()
+//	shared.Set("blocklist", []string{"10.0.0.1"})
+//	p.ShareDatasets(shared)
+//
+// ```
+func (p *Parser) ShareDatasets(r *DatasetRegistry) {
+	p.options.Parser.SharedDatasets = r
+}
@@ -6,18 +6,26 @@
 package seclang
 
 import (
+	"crypto/rand"
 	"errors"
 	"fmt"
 	"io/fs"
+	"path"
 	"regexp"
 	"strconv"
 	"strings"
+	"time"
 
+	"github.com/ad3n/seclang/experimental/plugins/plugintypes"
 	"github.com/ad3n/seclang/internal/auditlog"
+	"github.com/ad3n/seclang/internal/bodyprocessors"
 	"github.com/ad3n/seclang/internal/corazawaf"
 	"github.com/ad3n/seclang/internal/environment"
+	"github.com/ad3n/seclang/internal/io"
 	"github.com/ad3n/seclang/internal/memoize"
+	"github.com/ad3n/seclang/internal/operators"
 	utils "github.com/ad3n/seclang/internal/strings"
+	"github.com/ad3n/seclang/internal/transformations"
 
 	"github.com/corazawaf/coraza/v3/debuglog"
 	"github.com/corazawaf/coraza/v3/types"
@@ -27,10 +35,11 @@ import (
 // across multiple directives, to support collecting the options for audit logs for example.
 // TODO(anuraaga): Propagation of config probably should be separated from a directive's options.
 type DirectiveOptions struct {
-	WAF      *corazawaf.WAF
-	Raw      string
-	Opts     string
-	Path     []string
+	WAF  *corazawaf.WAF
+	Raw  string
+	Opts string
+	Path []string
+	// Deprecated: the datasets are kept by the WAF, use WAF.Datasets
 	Datasets map[string][]string
 
 	// Parser is configuration of the parser, populated by multiple directives and consumed by
@@ -40,6 +49,20 @@ type DirectiveOptions struct {
 
 type directive = func(options *DirectiveOptions) error
 
+// confinedPath resolves the path of a file written by a directive when the
+// parser is confined to a directory, see Parser.ConfineFiles. The standard
+// streams are kept as they are, URLs are rejected as they can't be confined.
+func confinedPath(options *DirectiveOptions, name string) (string, error) {
+	root, ok := options.Parser.Root.(io.ConfinedFS)
+	if !ok || name == "/dev/stdout" || name == "/dev/stderr" {
+		return name, nil
+	}
+	if strings.Contains(name, "://") {
+		return "", fmt.Errorf("%s: %w", name, io.ErrNotConfinable)
+	}
+	return root.Resolve(name)
+}
+
 // Description: Include and evaluate a file or file pattern.
 // Syntax: Include [PATH_TO_CONF_FILES]
 // ---
@@ -54,6 +77,13 @@ type directive = func(options *DirectiveOptions) error
 // > The syntax of patterns is the same as in Match. The pattern may describe hierarchical
 // > names such as /usr/*/bin/ed (assuming the Separator is ‘/’).
 // > Glob ignores file system errors such as I/O errors reading directories. The only possible returned error is ErrBadPattern, when pattern is malformed.
+//
+// Files can also be fetched over https, optionally pinned with a sha256 checksum of the content.
+// See `SecIncludeCacheDir` to keep a local copy that is revalidated on every load.
+//
+// ```apache
+// Include https://rules.example.com/shared.conf sha256:9f86d081884c7d659a2feaa0c55ad015a3bf4f1b2b0b822cd15d6c15b0f00a08
+// ```
 func directiveInclude(_ *DirectiveOptions) error {
 	return errors.New("not implemented")
 }
@@ -62,11 +92,31 @@ var _ directive = directiveInclude
 
 var errEmptyOptions = errors.New("expected options")
 
+// Description: Appends component identification to the audit logs.
+// Syntax: SecComponentSignature "COMPONENT_NAME/X.Y.Z"
+// ---
+// Rule sets declare their name and version so the audit logs identify which components
+// produced them. The directive can be used multiple times, e.g. once by the OWASP CRS and
+// once by a vendor rule set: every signature is kept, in the order they were declared,
+// and repeated signatures are only kept once. The signatures are listed by the producer of
+// the audit logs, by the effective configuration of the WAF and by its capability report,
+// see plugins.Capabilities.
+//
+// Example:
+// ```apache
+// SecComponentSignature "OWASP_CRS/4.0.0"
+// SecComponentSignature "vendor-rules/1.2"
+// ```
 func directiveSecComponentSignature(options *DirectiveOptions) error {
 	if len(options.Opts) == 0 {
 		return errEmptyOptions
 	}
-	options.WAF.ComponentNames = append(options.WAF.ComponentNames, options.Opts)
+	ruleset := corazawaf.ParseRuleset(options.Opts)
+	if !options.WAF.Producer.AddRuleset(ruleset) {
+		options.WAF.Logger.Debug().
+			Str("signature", ruleset.String()).
+			Msg("Ignoring repeated component signature")
+	}
 	return nil
 }
 
@@ -137,6 +187,11 @@ func directiveSecAction(options *DirectiveOptions) error {
 		return errEmptyOptions
 	}
 
+	// the rules above the maximum paranoia level and the rest of the chain of
+	// a skipped rule are skipped too
+	if skipRule(options, options.Opts, nil) {
+		return nil
+	}
 	rule, err := ParseRule(RuleOptions{
 		WithOperator: false,
 		WAF:          options.WAF,
@@ -146,9 +201,12 @@ func directiveSecAction(options *DirectiveOptions) error {
 		Data:         options.Opts,
 	})
 	if err != nil {
+		if skipRule(options, options.Opts, err) {
+			return nil
+		}
 		return err
 	}
-	if err := options.WAF.Rules.Add(rule); err != nil {
+	if err := addRule(options.WAF, options.Parser, rule); err != nil {
 		return err
 	}
 	options.WAF.Logger.Debug().
@@ -177,6 +235,12 @@ func directiveSecRule(options *DirectiveOptions) error {
 		return errEmptyOptions
 	}
 
+	// the rules above the maximum paranoia level and the rest of the chain of
+	// a skipped rule are skipped too
+	_, _, actions, _ := parseActionOperator(options.Opts)
+	if skipRule(options, actions, nil) {
+		return nil
+	}
 	ignoreErrors := options.Parser.IgnoreRuleCompilationErrors
 	rule, err := ParseRule(RuleOptions{
 		WithOperator: true,
@@ -185,8 +249,11 @@ func directiveSecRule(options *DirectiveOptions) error {
 		Raw:          options.Raw,
 		Directive:    "SecRule",
 		Data:         options.Opts,
-		Datasets:     options.Datasets,
+		Datasets:     options.WAF.Datasets(),
 	})
+	if err != nil && skipRule(options, actions, err) {
+		return nil
+	}
 	if err != nil && !ignoreErrors {
 		return err
 	} else if err != nil && ignoreErrors {
@@ -196,7 +263,7 @@ func directiveSecRule(options *DirectiveOptions) error {
 			Msg("Ignoring rule compilation error")
 		return nil
 	}
-	err = options.WAF.Rules.Add(rule)
+	err = addRule(options.WAF, options.Parser, rule)
 	if err != nil && !ignoreErrors {
 		return err
 	} else if err != nil && ignoreErrors {
@@ -310,15 +377,18 @@ func directiveSecServerSignature(options *DirectiveOptions) error {
 }
 
 // Description: Removes the matching rules from the current configuration context.
-// Syntax: SecRuleRemoveByTag [TAG]
+// Syntax: SecRuleRemoveByTag [REGEX]
 // ---
 // Normally, you would use `SecRuleRemoveById` to remove rules, but it may occasionally
-// be easier to disable an entire group of rules with `SecRuleRemoveByTag`. Matching is
-// by case-sensitive string equality.
+// be easier to disable an entire group of rules with `SecRuleRemoveByTag`. As in ModSecurity,
+// the argument is a case-sensitive regular expression and every rule with at least one tag
+// matching it is removed. Anchor the expression to match a tag exactly.
+// The IDs of the removed rules are written to the debug log.
 //
 // Example:
 // ```apache
 // SecRuleRemoveByTag attack-dos
+// SecRuleRemoveByTag "^paranoia-level/[34]$"
 // ```
 //
 // Note: OWASP CRS has a list of supported tags https://coreruleset.org/docs/rules/metadata/
@@ -327,16 +397,44 @@ func directiveSecRuleRemoveByTag(options *DirectiveOptions) error {
 		return errEmptyOptions
 	}
 
-	options.WAF.Rules.DeleteByTag(options.Opts)
+	re, err := regexp.Compile(utils.MaybeRemoveQuotes(options.Opts))
+	if err != nil {
+		return fmt.Errorf("invalid tag expression: %s", err.Error())
+	}
+	removed := options.WAF.Rules.DeleteByTagRegex(re)
+	options.WAF.Logger.Debug().
+		Str("tag", re.String()).
+		Str("rule_ids", fmt.Sprint(removed)).
+		Msg("Removed rules by tag")
 	return nil
 }
 
+// Description: Removes the matching rules from the current configuration context.
+// Syntax: SecRuleRemoveByMsg [REGEX]
+// ---
+// As in ModSecurity, the argument is a case-sensitive regular expression matched against
+// the message of each rule, every rule with a matching message is removed. Anchor the
+// expression to match a message exactly.
+// The IDs of the removed rules are written to the debug log.
+//
+// Example:
+// ```apache
+// SecRuleRemoveByMsg "^SQL Injection Attack"
+// ```
 func directiveSecRuleRemoveByMsg(options *DirectiveOptions) error {
 	if len(options.Opts) == 0 {
 		return errEmptyOptions
 	}
 
-	options.WAF.Rules.DeleteByMsg(options.Opts)
+	re, err := regexp.Compile(utils.MaybeRemoveQuotes(options.Opts))
+	if err != nil {
+		return fmt.Errorf("invalid message expression: %s", err.Error())
+	}
+	removed := options.WAF.Rules.DeleteByMsgRegex(re)
+	options.WAF.Logger.Debug().
+		Str("msg", re.String()).
+		Str("rule_ids", fmt.Sprint(removed)).
+		Msg("Removed rules by message")
 	return nil
 }
 
@@ -349,6 +447,7 @@ func directiveSecRuleRemoveByID(options *DirectiveOptions) error {
 
 	idsOrRanges := strings.Fields(options.Opts)
 	for _, idOrRange := range idsOrRanges {
+		idOrRange = options.Parser.RulePack.ruleIDs(idOrRange)
 		if idx := strings.Index(idOrRange, "-"); idx == -1 {
 			id, err := strconv.Atoi(idOrRange)
 			if err != nil {
@@ -447,6 +546,59 @@ func directiveSecResponseBodyLimit(options *DirectiveOptions) error {
 	return nil
 }
 
+// Description: Configures the maximum request body limit transactions can set with
+// `ctl:requestBodyLimit`.
+// Default: the request body limit
+// Syntax: SecRequestBodyLimitMax [LIMIT_IN_BYTES]
+// ---
+// It allows specific endpoints, identified in phase 1, to accept larger bodies without
+// raising `SecRequestBodyLimit` for every request. Larger limits set with ctl are capped
+// to it. It must be between the request body limit and 1 GB.
+//
+// Example:
+// ```apache
+// SecRequestBodyLimit 1048576
+// SecRequestBodyLimitMax 104857600
+// SecRule REQUEST_FILENAME "@streq /upload" "id:10,phase:1,pass,nolog,ctl:requestBodyLimit=104857600"
+// ```
+func directiveSecRequestBodyLimitMax(options *DirectiveOptions) error {
+	if len(options.Opts) == 0 {
+		return errEmptyOptions
+	}
+
+	limit, err := strconv.ParseInt(options.Opts, 10, 64)
+	if err != nil {
+		return err
+	}
+	options.WAF.RequestBodyLimitMax = limit
+	return nil
+}
+
+// Description: Configures the maximum response body limit transactions can set with
+// `ctl:responseBodyLimit`.
+// Default: the response body limit
+// Syntax: SecResponseBodyLimitMax [LIMIT_IN_BYTES]
+// ---
+// Larger limits set with ctl are capped to it. It must be between the response body
+// limit and 1 GB.
+//
+// Example:
+// ```apache
+// SecResponseBodyLimitMax 10485760
+// ```
+func directiveSecResponseBodyLimitMax(options *DirectiveOptions) error {
+	if len(options.Opts) == 0 {
+		return errEmptyOptions
+	}
+
+	limit, err := strconv.ParseInt(options.Opts, 10, 64)
+	if err != nil {
+		return err
+	}
+	options.WAF.ResponseBodyLimitMax = limit
+	return nil
+}
+
 // Description: Controls what happens once a request body limit, configured with
 // SecRequestBodyLimit, is encountered
 // Syntax: SecRequestBodyLimitAction Reject|ProcessPartial
@@ -466,6 +618,31 @@ func directiveSecRequestBodyLimitAction(options *DirectiveOptions) error {
 	return nil
 }
 
+// Description: Controls whether the bodies of the requests whose method doesn't
+// define one, like GET, are processed.
+// Syntax: SecRequestBodyUnexpectedAction Inspect|Ignore
+// Default: Inspect
+// ---
+// The methods without defined body semantics are GET, HEAD, DELETE and TRACE. With
+// `Inspect` their bodies are processed like the body of any other method, with `Ignore`
+// the request body variables stay empty. Either way the body is exposed to rules through
+// `TX:request_body_present` and `TX:request_body_unexpected`, and a body whose length
+// doesn't match the Content-Length header sets `TX:request_body_length_mismatch`.
+//
+// Example:
+// ```apache
+// SecRequestBodyUnexpectedAction Inspect
+// SecRule TX:request_body_unexpected "@eq 1" "id:100,phase:2,pass,log,msg:'Body sent with %{REQUEST_METHOD}'"
+// ```
+func directiveSecRequestBodyUnexpectedAction(options *DirectiveOptions) error {
+	action, ok := corazawaf.ParseUnexpectedRequestBodyAction(options.Opts)
+	if !ok {
+		return errors.New("syntax error: SecRequestBodyUnexpectedAction [Inspect/Ignore]")
+	}
+	options.WAF.UnexpectedRequestBodyAction = action
+	return nil
+}
+
 // Description: Configures the maximum request body size that Coraza will store in memory.
 // Default: defaults to RequestBodyLimit
 // Syntax: SecRequestBodyInMemoryLimit [LIMIT_IN_BYTES]
@@ -501,11 +678,397 @@ func directiveSecRemoteRulesFailAction(options *DirectiveOptions) error {
 	return nil
 }
 
+// Description: Configures the directory used to cache files included over https.
+// Syntax: SecIncludeCacheDir [PATH]
+// ---
+// Cached files are revalidated with `If-None-Match` and `If-Modified-Since` every time
+// they are included, and they are used as fallback when the server is not reachable.
+// It must be declared before the `Include` directives it applies to.
+//
+// Example:
+// ```apache
+// SecIncludeCacheDir /var/cache/coraza
+// Include https://rules.example.com/shared.conf
+// ```
+func directiveSecIncludeCacheDir(options *DirectiveOptions) error {
+	if len(options.Opts) == 0 {
+		return errEmptyOptions
+	}
+
+	dir, err := confinedPath(options, options.Opts)
+	if err != nil {
+		return err
+	}
+	options.Parser.IncludeCacheDir = dir
+	return nil
+}
+
+// Description: Loads the best-fit unicode mapping used by the `urlDecodeUni` transformation.
+// Syntax: SecUnicodeMapFile [PATH] [CODEPAGE]
+// ---
+// The file uses the format of the `unicode.mapping` file distributed with ModSecurity.
+// Once loaded, `%uXXXX` sequences are decoded into the byte the code page maps the code
+// point to, e.g. with the code page 20127 (US-ASCII) `%u0131` (dotless i) decodes to `i`.
+// Code points missing in the mapping keep their lower byte, as they do without a mapping.
+// The code page can also be set with `SecUnicodeCodePage`, the mapping is not loaded
+// until it is known. The mapping applies to the rules declared after it. Relative paths
+// are resolved from the directory of the configuration file.
+//
+// Example:
+// ```apache
+// SecUnicodeMapFile unicode.mapping 20127
+// ```
+func directiveSecUnicodeMapFile(options *DirectiveOptions) error {
+	if len(options.Opts) == 0 {
+		return errEmptyOptions
+	}
+
+	file, codePage, hasCodePage := strings.Cut(options.Opts, " ")
+	options.Parser.UnicodeMapFile = file
+	if hasCodePage {
+		cp, err := strconv.Atoi(strings.TrimSpace(codePage))
+		if err != nil {
+			return fmt.Errorf("invalid code page %q", codePage)
+		}
+		options.Parser.UnicodeCodePage = cp
+	}
+	if options.Parser.UnicodeCodePage == 0 {
+		options.WAF.Logger.Warn().
+			Str("file", file).
+			Msg("Unicode map file without code page, the map is not loaded until SecUnicodeCodePage is set")
+		return nil
+	}
+	return loadUnicodeMap(options)
+}
+
+// Description: Sets the code page of the mapping loaded with `SecUnicodeMapFile`.
+// Syntax: SecUnicodeCodePage [CODEPAGE]
+// ---
+// It is kept for compatibility with configurations written for ModSecurity 2.5,
+// where the code page was configured separately from the mapping file.
+//
+// Example:
+// ```apache
+// SecUnicodeMapFile unicode.mapping
+// SecUnicodeCodePage 20127
+// ```
+func directiveSecUnicodeCodePage(options *DirectiveOptions) error {
+	if len(options.Opts) == 0 {
+		return errEmptyOptions
+	}
+
+	cp, err := strconv.Atoi(options.Opts)
+	if err != nil {
+		return fmt.Errorf("invalid code page %q", options.Opts)
+	}
+	options.Parser.UnicodeCodePage = cp
+	return loadUnicodeMap(options)
+}
+
+// loadUnicodeMap sets the unicode map of the WAF once both the
+// file and the code page are known
+func loadUnicodeMap(options *DirectiveOptions) error {
+	if options.Parser.UnicodeMapFile == "" || options.Parser.UnicodeCodePage == 0 {
+		return nil
+	}
+
+	file := options.Parser.UnicodeMapFile
+	if !path.IsAbs(file) {
+		file = path.Join(options.Parser.ConfigDir, file)
+	}
+	var root fs.FS = options.Parser.Root
+	if root == nil {
+		root = io.OSFS{}
+	}
+	data, err := fs.ReadFile(root, file)
+	if err != nil {
+		return fmt.Errorf("failed to read unicode map: %s", err.Error())
+	}
+	m, err := transformations.ParseUnicodeMap(data, options.Parser.UnicodeCodePage)
+	if err != nil {
+		return err
+	}
+	options.WAF.UnicodeMap = m
+	options.WAF.Logger.Debug().
+		Int("code_page", m.CodePage()).
+		Int("code_points", m.Len()).
+		Msg("Loaded unicode map")
+	return nil
+}
+
+// Description: Configures the MaxMind database (MMDB) used by the `@geoLookup` operator.
+// Syntax: SecGeoLookupDb [PATH]
+// ---
+// GeoIP2 and GeoLite2 City and Country databases are supported. `@geoLookup` resolves the
+// address it receives and populates the GEO collection with the COUNTRY_CODE, COUNTRY_NAME,
+// COUNTRY_CONTINENT, REGION, CITY, POSTAL_CODE, LATITUDE, LONGITUDE and DMA_CODE fields
+// available for it. Relative paths are resolved from the directory of the configuration file.
+// The database is loaded in memory, TinyGo builds ignore the directive and `@geoLookup`
+// matches every address, as it does when no database is configured.
+//
+// Example:
+// ```apache
+// SecGeoLookupDb /usr/share/GeoIP/GeoLite2-City.mmdb
+// SecRule REMOTE_ADDR "@geoLookup" "id:1,phase:1,chain,deny"
+// SecRule GEO:COUNTRY_CODE "@pm CN RU"
+// ```
+func directiveSecGeoLookupDB(options *DirectiveOptions) error {
+	if len(options.Opts) == 0 {
+		return errEmptyOptions
+	}
+
+	file := options.Opts
+	if !path.IsAbs(file) {
+		file = path.Join(options.Parser.ConfigDir, file)
+	}
+	var root fs.FS = options.Parser.Root
+	if root == nil {
+		root = io.OSFS{}
+	}
+	db, err := openGeoDatabase(root, file)
+	if err != nil {
+		return err
+	}
+	if db == nil {
+		options.WAF.Logger.Warn().
+			Str("file", file).
+			Msg("Geo databases are not supported in this build, @geoLookup matches every address")
+		return nil
+	}
+	options.WAF.GeoDatabase = db
+	return nil
+}
+
+// Description: Enables the STREAM_INPUT_BODY variable, which holds the raw request body.
+// Default: Off
+// Syntax: SecStreamInBodyInspection On|Off
+// ---
+// STREAM_INPUT_BODY is available in phase 2 regardless of the body processor, and the
+// `@rsub` operator can rewrite it, the connectors then forward the rewritten body. It
+// requires `SecRequestBodyAccess On`.
+//
+// Example:
+// ```apache
+// SecRequestBodyAccess On
+// SecStreamInBodyInspection On
+// SecRule STREAM_INPUT_BODY "@rsub s/password=[^&]*/password=redacted/" "id:1,phase:2,t:none,pass,nolog"
+// ```
+func directiveSecStreamInBodyInspection(options *DirectiveOptions) error {
+	b, err := parseBoolean(options.Opts)
+	if err != nil {
+		return err
+	}
+	options.WAF.StreamInBodyInspection = b
+	return nil
+}
+
+// Description: Enables the STREAM_OUTPUT_BODY variable, which holds the raw response body.
+// Default: Off
+// Syntax: SecStreamOutBodyInspection On|Off
+// ---
+// STREAM_OUTPUT_BODY is available in phase 4 regardless of the body processor, and the
+// `@rsub` operator can rewrite it, the connectors then send the rewritten body. It
+// requires `SecResponseBodyAccess On`.
+//
+// Example:
+// ```apache
+// SecResponseBodyAccess On
+// SecStreamOutBodyInspection On
+// SecRule STREAM_OUTPUT_BODY "@rsub s/Server error: .*/Server error/" "id:2,phase:4,t:none,pass,nolog"
+// ```
+func directiveSecStreamOutBodyInspection(options *DirectiveOptions) error {
+	b, err := parseBoolean(options.Opts)
+	if err != nil {
+		return err
+	}
+	options.WAF.StreamOutBodyInspection = b
+	return nil
+}
+
+// Description: Loads a catalog of messages that replace the `msg` of the rules in the audit log.
+// Syntax: SecRuleMessageCatalog [PATH]
+// ---
+// The catalog has one `ID MESSAGE` entry per line, empty lines and lines starting with `#`
+// are ignored. It allows presenting the messages in another language without editing the rule
+// files. Messages can use macros, they are expanded when the audit log is written so variables
+// like `MATCHED_VAR` refer to the last match of the transaction. The directive can be used more
+// than once, entries of later catalogs replace the earlier ones. Relative paths are resolved from
+// the directory of the configuration file.
+//
+// Example:
+// ```apache
+// SecRuleMessageCatalog messages/es.txt
+// ```
+//
+// With messages/es.txt:
+// ```
+// 942100 Ataque de inyección SQL detectado mediante libinjection
+// 949110 Puntuación de anomalía de entrada superada (total: %{TX.BLOCKING_INBOUND_ANOMALY_SCORE})
+// ```
+func directiveSecRuleMessageCatalog(options *DirectiveOptions) error {
+	if len(options.Opts) == 0 {
+		return errEmptyOptions
+	}
+
+	file := options.Opts
+	if !path.IsAbs(file) {
+		file = path.Join(options.Parser.ConfigDir, file)
+	}
+	var root fs.FS = options.Parser.Root
+	if root == nil {
+		root = io.OSFS{}
+	}
+	data, err := fs.ReadFile(root, file)
+	if err != nil {
+		return fmt.Errorf("failed to read message catalog: %s", err.Error())
+	}
+	catalog, err := corazawaf.ParseMessageCatalog(data)
+	if err != nil {
+		return fmt.Errorf("failed to parse message catalog %s: %s", file, err.Error())
+	}
+	if options.WAF.MessageCatalog == nil {
+		options.WAF.MessageCatalog = corazawaf.MessageCatalog{}
+	}
+	options.WAF.MessageCatalog.Merge(catalog)
+	return nil
+}
+
+// Description: Configures how operators behave when an external dependency they rely on fails.
+// Syntax: SecDependencyFailureMode [FEATURE|*] FailOpen|FailClosed
+// Default: * FailOpen
+// ---
+// Operators like `@rbl` or `@inspectFile` depend on services that might be unavailable
+// (DNS timeouts, missing scanner binary, ...). With `FailOpen` the operator does not match,
+// with `FailClosed` it matches as if the check failed. `*` sets the mode for every feature
+// without an explicit configuration.
+// Regardless of the mode, failures set `TX:dependency_error` and `TX:dependency_error_<feature>`
+// to 1 so rules can react to them.
+//
+// Example:
+// ```apache
+// SecDependencyFailureMode rbl FailClosed
+// SecRule TX:dependency_error "@eq 1" "id:100,phase:5,pass,log,msg:'A dependency failed'"
+// ```
+func directiveSecDependencyFailureMode(options *DirectiveOptions) error {
+	if len(options.Opts) == 0 {
+		return errEmptyOptions
+	}
+
+	fields := strings.Fields(options.Opts)
+	if len(fields) != 2 {
+		return errors.New("syntax error: SecDependencyFailureMode [FEATURE|*] FailOpen|FailClosed")
+	}
+	mode, err := corazawaf.ParseDependencyFailureMode(fields[1])
+	if err != nil {
+		return err
+	}
+	options.WAF.SetDependencyFailureMode(fields[0], mode)
+	return nil
+}
+
+// Description: Configures a watchdog that flags the transactions not closed within a number
+// of seconds, e.g. leaked by a connector.
+// Syntax: SecTransactionWatchdog SECONDS [Log]
+// Default: 0 (transactions are not watched)
+// ---
+// Transactions that are never closed keep their body buffers and temporary files. The watchdog
+// logs a warning with the age and the last phase evaluated of these transactions, and counts them,
+// so the connector leaking them can be found. The watchdog doesn't close them, the transactions
+// are not safe for concurrent use and a leaked transaction can't be told from a slow one.
+//
+// Example:
+// ```apache
+// SecTransactionWatchdog 300
+// ```
+func directiveSecTransactionWatchdog(options *DirectiveOptions) error {
+	if len(options.Opts) == 0 {
+		return errEmptyOptions
+	}
+
+	fields := strings.Fields(options.Opts)
+	if len(fields) > 2 {
+		return errors.New("syntax error: SecTransactionWatchdog SECONDS [Log]")
+	}
+	seconds, err := strconv.Atoi(fields[0])
+	if err != nil {
+		return err
+	}
+	if seconds < 0 {
+		return errors.New("watchdog timeout should not be negative")
+	}
+	if len(fields) == 2 && !strings.EqualFold(fields[1], "log") {
+		return fmt.Errorf("invalid watchdog action %q, expected Log", fields[1])
+	}
+	options.WAF.TransactionWatchdogTimeout = time.Duration(seconds) * time.Second
+	return nil
+}
+
+// Description: Writes a forensic dump of the transactions whose rule evaluation panics.
+// Syntax: SecPanicDump PATH [Panic|Recover]
+// Default: panics are not handled
+// ---
+// Each dump is a JSON line with the transaction id, the phase, the rule being evaluated, the
+// panic and its stack, and the variables of the transaction. The dumps are sanitized: bodies,
+// the request and response cookies, the POST and XML arguments, the request line, URI, path
+// and query string, the matched values, the numbered and named captures and the values whose
+// key looks like a credential, e.g. `authorization` or `password`, are redacted, and the other
+// values are truncated to 256 bytes. With `Panic`,
+// the default, the panic is propagated to the connector once dumped. With `Recover` it is
+// stopped and, as the remaining rules of the phase are not evaluated, the transaction is
+// interrupted with a 500 status when the rule engine is On.
+//
+// Example:
+// ```apache
+// SecPanicDump /var/log/coraza/panic.log Recover
+// ```
+func directiveSecPanicDump(options *DirectiveOptions) error {
+	if len(options.Opts) == 0 {
+		return errEmptyOptions
+	}
+
+	fields := strings.Fields(options.Opts)
+	if len(fields) > 2 {
+		return errors.New("syntax error: SecPanicDump PATH [Panic|Recover]")
+	}
+	recoverPanics := false
+	if len(fields) == 2 {
+		switch strings.ToLower(fields[1]) {
+		case "panic":
+		case "recover":
+			recoverPanics = true
+		default:
+			return fmt.Errorf("invalid panic dump action %q, expected Panic or Recover", fields[1])
+		}
+	}
+	path, err := confinedPath(options, utils.MaybeRemoveQuotes(fields[0]))
+	if err != nil {
+		return err
+	}
+	return options.WAF.SetPanicDumpPath(path, recoverPanics)
+}
+
 func directiveSecRemoteRules(options *DirectiveOptions) error {
 	return fmt.Errorf("not implemented")
 }
 
+// Description: Configures the maximum number of connections of a client in the write
+// state, that is writing responses.
+// Default: 0 (unlimited)
+// Syntax: SecConnWriteStateLimit LIMIT ["[!]@ipMatch ADDRESSES"]
+// ---
+// It works like `SecConnReadStateLimit`, see its documentation.
+//
+// Example:
+// ```apache
+// SecConnEngine On
+// SecConnWriteStateLimit 50 "!@ipMatch 127.0.0.1"
+// ```
 func directiveSecConnWriteStateLimit(options *DirectiveOptions) error {
+	limit, err := parseConnLimit(options)
+	if err != nil {
+		return err
+	}
+	options.WAF.ConnWriteStateLimit = limit
 	return nil
 }
 
@@ -518,43 +1081,332 @@ func directiveSecSensorID(options *DirectiveOptions) error {
 	return nil
 }
 
+// Description: Configures the maximum number of connections of a client in the read
+// state, that is reading requests, to mitigate slow denial of service attacks.
+// Default: 0 (unlimited)
+// Syntax: SecConnReadStateLimit LIMIT ["[!]@ipMatch ADDRESSES"]
+// ---
+// Connectors report the connections with `TrackConnection`, the connections exceeding the
+// limit must be dropped. The optional operator selects the client addresses the limit
+// applies to, only the IP operators `@ipMatch` and `@ipMatchFromFile` are supported as
+// there is no transaction to evaluate other operators with. It requires `SecConnEngine`.
+//
+// Example:
+// ```apache
+// SecConnEngine On
+// SecConnReadStateLimit 50 "!@ipMatch 127.0.0.1"
+// ```
 func directiveSecConnReadStateLimit(options *DirectiveOptions) error {
+	limit, err := parseConnLimit(options)
+	if err != nil {
+		return err
+	}
+	options.WAF.ConnReadStateLimit = limit
 	return nil
 }
 
+// parseConnLimit parses the limit and the optional IP operator of the
+// SecConnReadStateLimit and SecConnWriteStateLimit directives
+func parseConnLimit(options *DirectiveOptions) (corazawaf.ConnLimit, error) {
+	if len(options.Opts) == 0 {
+		return corazawaf.ConnLimit{}, errEmptyOptions
+	}
+
+	limitRaw, operator, _ := strings.Cut(options.Opts, " ")
+	limit, err := strconv.Atoi(limitRaw)
+	if err != nil || limit < 0 {
+		return corazawaf.ConnLimit{}, fmt.Errorf("invalid connection limit %q", limitRaw)
+	}
+	operator = utils.MaybeRemoveQuotes(strings.TrimSpace(operator))
+	if operator == "" {
+		return corazawaf.ConnLimit{Limit: limit}, nil
+	}
+
+	negated := strings.HasPrefix(operator, "!")
+	name, arguments, _ := strings.Cut(strings.TrimPrefix(operator, "!"), " ")
+	switch name {
+	case "@ipMatch", "@ipMatchFromFile", "@ipMatchF":
+	default:
+		return corazawaf.ConnLimit{}, fmt.Errorf("unsupported connection limit operator %q, expected @ipMatch or @ipMatchFromFile", name)
+	}
+	op, err := operators.Get(name[1:], plugintypes.OperatorOptions{
+		Arguments: strings.TrimSpace(arguments),
+		Path:      []string{options.Parser.ConfigDir, options.Parser.WorkingDir},
+		Root:      options.Parser.Root,
+	})
+	if err != nil {
+		return corazawaf.ConnLimit{}, err
+	}
+	return corazawaf.ConnLimit{
+		Limit: limit,
+		Match: func(state plugintypes.TransactionState, addr string) bool {
+			return op.Evaluate(state, addr) != negated
+		},
+	}, nil
+}
+
+// Description: Sets the depth limit of the matches of the PCRE2 regex engine.
+// Default: 1000
+// Syntax: SecPcreMatchLimitRecursion [LIMIT]
+// ---
+// It only applies when built with the `coraza.rule.pcre2` tag, see `SecPcreMatchLimit`.
 func directiveSecPcreMatchLimitRecursion(options *DirectiveOptions) error {
+	limit, err := parsePcreMatchLimit(options.Opts)
+	if err != nil {
+		return err
+	}
+	options.Parser.PcreMatchLimitRecursion = limit
 	return nil
 }
 
+// Description: Sets the match limit of the PCRE2 regex engine, which bounds the
+// backtracking of the expressions.
+// Default: 1000
+// Syntax: SecPcreMatchLimit [LIMIT]
+// ---
+// The `@rx` expressions are matched with RE2, in linear time. When built with the
+// `coraza.rule.pcre2` tag, which requires cgo and libpcre2-8, the expressions RE2
+// rejects, e.g. the lookarounds and backreferences of legacy rules, are matched with
+// PCRE2 instead. The matches aborted by the limit don't match and set
+// `MSC_PCRE_LIMITS_EXCEEDED`, also known as `RX_BUDGET_EXCEEDED`, to 1. The limits
+// apply to the rules defined after the directive.
+//
+// Example:
+// ```apache
+// SecPcreMatchLimit 1000
+// SecPcreMatchLimitRecursion 1000
+// SecRule ARGS "@rx (\w+)=\1" "id:1,phase:2,deny"
+// SecRule MSC_PCRE_LIMITS_EXCEEDED "@eq 1" "id:2,phase:2,pass,log,msg:'PCRE limits exceeded'"
+// ```
 func directiveSecPcreMatchLimit(options *DirectiveOptions) error {
+	limit, err := parsePcreMatchLimit(options.Opts)
+	if err != nil {
+		return err
+	}
+	options.Parser.PcreMatchLimit = limit
 	return nil
 }
 
+func parsePcreMatchLimit(opts string) (int, error) {
+	limit, err := strconv.Atoi(opts)
+	if err != nil {
+		return 0, err
+	}
+	if limit <= 0 {
+		return 0, errors.New("the PCRE match limit should be positive")
+	}
+	return limit, nil
+}
+
 func directiveSecHTTPBlKey(options *DirectiveOptions) error {
 	return nil
 }
 
+// Description: Configures the URL reputation provider used by the `@gsbLookup` operator.
+// Syntax: SecGsbLookupDb [PATH|URI]
+// ---
+// PATH is a local list with one entry per line, a URL expression like
+// `evil.example.com/login/` or its hexadecimal SHA-256 as in the Safe Browsing lists,
+// optionally followed by the threat it is listed for, MALWARE by default. Relative
+// paths are resolved from the directory of the configuration file. An https URI is
+// the `threatMatches:find` endpoint of a Safe Browsing v4 compatible API, including its
+// key. The other schemes select the providers registered with
+// `plugins.RegisterURLReputation`. TinyGo builds ignore the directive and
+// `@gsbLookup` doesn't match, as it does when no provider is configured.
+//
+// Example:
+// ```apache
+// SecGsbLookupDb https://safebrowsing.googleapis.com/v4/threatMatches:find?key=KEY
+// SecRule ARGS "@gsbLookup" "id:1,phase:2,deny,capture,logdata:'%{TX.gsb_threat} %{TX.0}'"
+// ```
 func directiveSecGsbLookupDb(options *DirectiveOptions) error {
+	if len(options.Opts) == 0 {
+		return errEmptyOptions
+	}
+
+	source := options.Opts
+	if !strings.Contains(source, "://") && !path.IsAbs(source) {
+		source = path.Join(options.Parser.ConfigDir, source)
+	}
+	var root fs.FS = options.Parser.Root
+	if root == nil {
+		root = io.OSFS{}
+	}
+	if io.IsConfined(root) && (strings.HasPrefix(source, "https://") || strings.HasPrefix(source, "http://")) {
+		return fmt.Errorf("%s: %w", source, io.ErrNotConfinable)
+	}
+	provider, err := openURLReputation(root, source)
+	if err != nil {
+		return err
+	}
+	if provider == nil {
+		options.WAF.Logger.Warn().
+			Str("source", options.Opts).
+			Msg("URL reputation providers are not supported in this build, @gsbLookup doesn't match")
+		return nil
+	}
+	options.WAF.URLReputation = provider
 	return nil
 }
 
+// Description: Signs the links of the type whose URL contains any of the phrases.
+// Syntax: SecHashMethodPm [TYPE] "[PHRASES]"
+// ---
+// TYPE is one of `HashHref`, `HashFormAction`, `HashIframeSrc`, `HashFrameSrc` or
+// `HashScriptSrc`, the phrases are matched case insensitively.
+//
+// Example:
+// ```apache
+// SecHashMethodPm HashHref "product_info list_product"
+// ```
 func directiveSecHashMethodPm(options *DirectiveOptions) error {
+	typ, phrases, err := parseHashMethod(options.Opts)
+	if err != nil {
+		return err
+	}
+	words := strings.Fields(strings.ToLower(phrases))
+	if len(words) == 0 {
+		return errors.New("syntax error: SecHashMethodPm [TYPE] \"[PHRASES]\"")
+	}
+	options.WAF.HashMethods = append(options.WAF.HashMethods, corazawaf.HashMethod{
+		Type: typ,
+		Match: func(link string) bool {
+			link = strings.ToLower(link)
+			for _, w := range words {
+				if strings.Contains(link, w) {
+					return true
+				}
+			}
+			return false
+		},
+	})
 	return nil
 }
 
+// Description: Signs the links of the type whose URL matches the regular expression.
+// Syntax: SecHashMethodRx [TYPE] "[REGEX]"
+// ---
+// TYPE is one of `HashHref`, `HashFormAction`, `HashIframeSrc`, `HashFrameSrc` or
+// `HashScriptSrc`.
+//
+// Example:
+// ```apache
+// SecHashMethodRx HashHref "^/account/"
+// SecHashMethodRx HashFormAction "."
+// ```
 func directiveSecHashMethodRx(options *DirectiveOptions) error {
+	typ, expr, err := parseHashMethod(options.Opts)
+	if err != nil {
+		return err
+	}
+	re, err := regexp.Compile(expr)
+	if err != nil {
+		return err
+	}
+	options.WAF.HashMethods = append(options.WAF.HashMethods, corazawaf.HashMethod{Type: typ, Match: re.MatchString})
 	return nil
 }
 
+// parseHashMethod parses the type and the unquoted argument of SecHashMethodRx and
+// SecHashMethodPm, typographic quotes copied from the ModSecurity reference are accepted
+func parseHashMethod(opts string) (corazawaf.HashLinkType, string, error) {
+	end := strings.IndexFunc(opts, func(r rune) bool { return r == ' ' || r == '"' || r == '“' })
+	if end < 0 {
+		return 0, "", errors.New("syntax error: expected a link type and a pattern")
+	}
+	t, err := corazawaf.ParseHashLinkType(opts[:end])
+	if err != nil {
+		return 0, "", err
+	}
+	return t, strings.Trim(strings.TrimSpace(opts[end:]), `"“”`), nil
+}
+
+// Description: Sets the name of the parameter signed links carry their signature in.
+// Default: hmac
+// Syntax: SecHashParam [NAME]
+// ---
+// Example:
+// ```apache
+// SecHashParam hmac
+// ```
 func directiveSecHashParam(options *DirectiveOptions) error {
+	if len(options.Opts) == 0 {
+		return errEmptyOptions
+	}
+
+	options.WAF.HashParam = strings.Trim(options.Opts, `"`)
 	return nil
 }
 
+// Description: Sets the key used to sign the links of the response bodies.
+// Syntax: SecHashKey [rand|TEXT] [KeyOnly|RemoteIP]
+// ---
+// `rand` generates a random key, so signed links are only valid until the WAF is
+// restarted. With `RemoteIP` the key is combined with the client address, so a
+// signed link can't be used by other clients. ModSecurity's `SessionID` mode is
+// not supported.
+//
+// Example:
+// ```apache
+// SecHashKey rand KeyOnly
+// ```
 func directiveSecHashKey(options *DirectiveOptions) error {
+	if len(options.Opts) == 0 {
+		return errEmptyOptions
+	}
+
+	key, mode, _ := strings.Cut(options.Opts, " ")
+	key = strings.Trim(key, `"`)
+	switch strings.ToLower(strings.TrimSpace(mode)) {
+	case "", "keyonly":
+		options.WAF.HashKeyMode = corazawaf.HashKeyOnly
+	case "remoteip":
+		options.WAF.HashKeyMode = corazawaf.HashKeyRemoteIP
+	default:
+		return fmt.Errorf("unsupported hash key mode %q", mode)
+	}
+
+	if key == "rand" {
+		b := make([]byte, 32)
+		if _, err := rand.Read(b); err != nil {
+			return fmt.Errorf("failed to generate the hash key: %s", err.Error())
+		}
+		options.WAF.HashKey = b
+		return nil
+	}
+	options.WAF.HashKey = []byte(key)
 	return nil
 }
 
+// Description: Configures the hash engine, which signs the links of the HTML
+// response bodies and verifies them with the `@validateHash` operator.
+// Default: Off
+// Syntax: SecHashEngine [On|Off]
+// ---
+// The links to sign are selected with `SecHashMethodRx` and `SecHashMethodPm`, and
+// signed with the key of `SecHashKey`. The signature is appended to the link as the
+// parameter set with `SecHashParam`. Response bodies are only signed when they are
+// accessible, see `SecResponseBodyAccess`. It can be changed per transaction with
+// `ctl:hashEngine` and `ctl:hashEnforcement`.
+//
+// Example:
+// ```apache
+// SecResponseBodyAccess On
+// SecHashEngine On
+// SecHashKey rand KeyOnly
+// SecHashMethodRx HashHref "^/account/"
+// SecRule REQUEST_URI "@validateHash ^/account/" "id:1,phase:1,deny,status:403"
+// ```
 func directiveSecHashEngine(options *DirectiveOptions) error {
+	if len(options.Opts) == 0 {
+		return errEmptyOptions
+	}
+
+	b, err := parseBoolean(options.Opts)
+	if err != nil {
+		return err
+	}
+	options.WAF.HashEngine = b
 	return nil
 }
 
@@ -563,48 +1415,135 @@ func directiveSecHashEngine(options *DirectiveOptions) error {
 // Default: phase:2,log,auditlog,pass
 // Syntax: SecDefaultAction "phase:2,log,auditlog,deny,status:403,tag:'SLA 24/7'"
 // ---
-// Every rule following a previous `SecDefaultAction` directive in the same configuration
-// context will inherit its settings unless more specific actions are used.
+// Every rule following a previous `SecDefaultAction` directive in the same configuration
+// context will inherit its settings unless more specific actions are used.
+//
+// Rulesets like OWASP Core Ruleset uses this to define operation modes:
+//
+// - You can set the default disruptive action to block for phases 1 and 2 and you can force
+// a phase 3 rule to be disrupted if the thread score is high.
+// - You can set the default disruptive action to deny and each risky rule will interrupt
+// the connection.
+//
+// Important: Every `SecDefaultAction` directive must specify a disruptive action and a processing
+// phase and cannot contain metadata actions.
+func directiveSecDefaultAction(options *DirectiveOptions) error {
+	if len(options.Opts) == 0 {
+		return errEmptyOptions
+	}
+
+	options.Parser.RuleDefaultActions = append(options.Parser.RuleDefaultActions, options.Opts)
+	options.Parser.HasRuleDefaultActions = true
+	return nil
+}
+
+// Description: Configures the connection engine, which limits the number of concurrent
+// connections of each client with `SecConnReadStateLimit` and `SecConnWriteStateLimit`.
+// Syntax: SecConnEngine On|Off|DetectionOnly
+// Default: Off
+// ---
+// The possible values are:
+// - On: drop the connections exceeding the limits
+// - Off: do not track connections
+// - DetectionOnly: track connections and log the ones exceeding the limits
+//
+// Example:
+// ```apache
+// SecConnEngine On
+// SecConnReadStateLimit 50
+// ```
+func directiveSecConnEngine(options *DirectiveOptions) error {
+	engine, err := types.ParseRuleEngineStatus(options.Opts)
+	if err != nil {
+		return err
+	}
+	options.WAF.ConnEngine = engine
+	return nil
+}
+
+// Description: Configures the evaluation of the security headers of the responses:
+// Content-Security-Policy, Strict-Transport-Security and X-Frame-Options.
+// Syntax: SecSecurityHeadersEngine On|Off|DetectionOnly
+// Default: Off
+// ---
+// The headers are evaluated before the phase 3 rules, their status is exposed in the
+// `SECURITY_HEADERS` collection as `csp`, `hsts` and `xfo`:
+// - ok: the header is set and enforces a sane policy
+// - weak: the header is set but the policy is weak, the reason is in `<key>_reason`, e.g.
+// `unsafe-inline`, `max-age below 180 days` or `invalid value`
+// - missing: the header is not set
+// - injected: the header was missing and has been injected
 //
-// Rulesets like OWASP Core Ruleset uses this to define operation modes:
+// A content security policy is weak when its scripts sources, script-src or default-src,
+// allow inline scripts without a nonce or hash, eval or any host. X-Frame-Options is not
+// required when the policy sets frame-ancestors. The possible values are:
+// - On: evaluate the headers and inject the missing ones, see `SecSecurityHeaderDefault`
+// - Off: do not evaluate the headers
+// - DetectionOnly: evaluate the headers only
 //
-// - You can set the default disruptive action to block for phases 1 and 2 and you can force
-// a phase 3 rule to be disrupted if the thread score is high.
-// - You can set the default disruptive action to deny and each risky rule will interrupt
-// the connection.
+// The injected headers are set with the response header mutation API, connectors get them
+// from the transaction with `ResponseHeaderMutations` and apply them to the response.
+// `SECURITY_HEADERS` is read-only, rules can't change the status of the headers.
 //
-// Important: Every `SecDefaultAction` directive must specify a disruptive action and a processing
-// phase and cannot contain metadata actions.
-func directiveSecDefaultAction(options *DirectiveOptions) error {
-	if len(options.Opts) == 0 {
-		return errEmptyOptions
+// Example:
+// ```apache
+// SecSecurityHeadersEngine On
+// SecRule SECURITY_HEADERS:csp "@streq weak" "id:1,phase:3,pass,log,msg:'Weak CSP: %{SECURITY_HEADERS.csp_reason}'"
+// ```
+func directiveSecSecurityHeadersEngine(options *DirectiveOptions) error {
+	engine, err := types.ParseRuleEngineStatus(options.Opts)
+	if err != nil {
+		return err
 	}
-
-	options.Parser.RuleDefaultActions = append(options.Parser.RuleDefaultActions, options.Opts)
-	options.Parser.HasRuleDefaultActions = true
+	options.WAF.SecurityHeadersEngine = engine
 	return nil
 }
 
-func directiveSecConnEngine(options *DirectiveOptions) error {
-	/*
-		switch opts{
-		case "On":
-			w.ConnEngine = engine.CONN_ENGINE_ON
-			break
-		case "Off":
-			w.ConnEngine = engine.CONN_ENGINE_OFF
-			break
-		case "DetectOnly":
-			w.ConnEngine = engine.CONN_ENGINE_DETECTONLY
-			break
-		}
-		break
-	*/
-	return nil
+// Description: Configures the value injected when a security header is missing.
+// Syntax: SecSecurityHeaderDefault HEADER "VALUE"
+// ---
+// HEADER is Content-Security-Policy, Strict-Transport-Security or X-Frame-Options. By
+// default `max-age=31536000; includeSubDomains` is injected for Strict-Transport-Security,
+// `SAMEORIGIN` for X-Frame-Options and no content security policy, which is specific to
+// each application. An empty value disables the injection of the header.
+//
+// Example:
+// ```apache
+// SecSecurityHeadersEngine On
+// SecSecurityHeaderDefault Content-Security-Policy "default-src 'self'; frame-ancestors 'self'"
+// SecSecurityHeaderDefault X-Frame-Options ""
+// ```
+func directiveSecSecurityHeaderDefault(options *DirectiveOptions) error {
+	header, value, ok := strings.Cut(options.Opts, " ")
+	if !ok {
+		return errors.New("syntax error: SecSecurityHeaderDefault HEADER \"VALUE\"")
+	}
+	return options.WAF.SetSecurityHeaderDefault(header, utils.MaybeRemoveQuotes(strings.TrimSpace(value)))
 }
 
+// Description: Specifies the time to live of the persistent collection records, in seconds.
+// Default: 3600
+// Syntax: SecCollectionTimeout [SECONDS]
+// ---
+// The GLOBAL, IP, RESOURCE, SESSION and USER collections are loaded with the `initcol` action and stored when
+// the transaction is closed if they were changed, e.g. with `setvar`. Records expire once they are not updated within the timeout, unless they set their own
+// `TIMEOUT`. Expired records are removed when they are read, connectors can also run the
+// garbage collector of the persistent collections periodically to release the records that
+// are not read anymore.
+//
+// Example:
+// ```apache
+// SecCollectionTimeout 600
+// ```
 func directiveSecCollectionTimeout(options *DirectiveOptions) error {
-	// w.CollectionTimeout, _ = strconv.Atoi(opts)
+	seconds, err := strconv.Atoi(options.Opts)
+	if err != nil {
+		return err
+	}
+	if seconds <= 0 {
+		return errors.New("collection timeout should be bigger than 0")
+	}
+	options.WAF.CollectionTimeout = time.Duration(seconds) * time.Second
 	return nil
 }
 
@@ -626,8 +1565,104 @@ func directiveSecAuditLog(options *DirectiveOptions) error {
 		return errEmptyOptions
 	}
 
-	options.WAF.AuditLogWriterConfig.Target = options.Opts
+	target, err := confinedPath(options, options.Opts)
+	if err != nil {
+		return err
+	}
+	options.WAF.AuditLogWriterConfig.Target = target
+
+	return nil
+}
+
+// Description: Sets the duration, in microseconds, above which rules are considered slow.
+// Slow rules are exposed in the `PERF_RULES` collection and logged in the audit log trailer.
+// Default: 0 (rules are not timed)
+// Syntax: SecRulePerfTime [USECS]
+// ---
+// The durations of the phases are always available in `PERF_PHASE1` to `PERF_PHASE5` and their
+// sum in `PERF_COMBINED`, in microseconds. `PERF_RULES` holds the duration of each rule that took
+// longer than the threshold, keyed by rule id, and part H of the audit log lists them as
+// `Rules-Performance-Info`. The PERF_* variables are computed when rules read them.
+//
+// Example:
+// ```apache
+// SecRulePerfTime 1000
+// SecRule &PERF_RULES "@gt 0" "id:1,phase:5,pass,log,msg:'Slow rules: %{PERF_COMBINED}us'"
+// ```
+func directiveSecRulePerfTime(options *DirectiveOptions) error {
+	usecs, err := strconv.Atoi(options.Opts)
+	if err != nil {
+		return err
+	}
+	if usecs < 0 {
+		return errors.New("rule performance time should not be negative")
+	}
+	options.WAF.RulePerfTime = time.Duration(usecs) * time.Microsecond
+	return nil
+}
+
+// Description: Bounds the evaluation of the `@rx` operator, protecting latency-sensitive
+// deployments from long values and slow expressions.
+// Default: off
+// Syntax: SecRxBudget [input=BYTES] [time=DURATION]|off
+// ---
+// `input` is the number of bytes of each value inspected, the rest of the value is
+// ignored. `time` is the duration, e.g. `2ms`, above which a match is reported as
+// slow. A match can't be interrupted, as expressions are matched in linear time the
+// input limit is what bounds it. Exceeding either limit is logged at the debug level
+// and sets the read-only `RX_BUDGET_EXCEEDED` variable to 1. Rules can override the
+// budget with the `rxBudget` action.
+//
+// Example:
+// ```apache
+// SecRxBudget input=65536 time=5ms
+// SecRule REQUEST_BODY "@rx (?:a+)+b" "id:1,phase:2,pass,rxBudget:'input=4096'"
+// SecRule RX_BUDGET_EXCEEDED "@eq 1" "id:2,phase:2,pass,log,msg:'Regex budget exceeded'"
+// ```
+func directiveSecRxBudget(options *DirectiveOptions) error {
+	b, err := corazawaf.ParseRxBudget(options.Opts)
+	if err != nil {
+		return err
+	}
+	options.WAF.RxBudget = b
+	return nil
+}
+
+// Description: Configures an external program, or a named pipe, that receives a summary
+// line of every transaction in real time, e.g. httpd-guardian to detect denial of service
+// attacks. It is independent of the audit log and of `SecAuditEngine`.
+// Syntax: SecGuardianLog |/path/to/program [ARGS] | /path/to/named/pipe
+// ---
+// The program is started when the first transaction is logged and reads the lines from its
+// standard input, it is stopped when the WAF is closed. The lines logged while a named pipe
+// has no reader are dropped. Each line uses the Apache combined log format, followed by the
+// transaction id, the session id and the duration of the transaction in microseconds.
+//
+// Example:
+// ```apache
+// SecGuardianLog |/usr/local/apache/bin/httpd-guardian
+// ```
+func directiveSecGuardianLog(options *DirectiveOptions) error {
+	if len(options.Opts) == 0 {
+		return errEmptyOptions
+	}
 
+	if !environment.HasAccessToFS {
+		return errors.New("SecGuardianLog directive is not effective because of no access to the filesystem")
+	}
+	target := utils.MaybeRemoveQuotes(options.Opts)
+	if _, confined := options.Parser.Root.(io.ConfinedFS); confined && strings.HasPrefix(target, "|") {
+		return errors.New("SecGuardianLog programs are not allowed when the files are confined to a directory")
+	}
+	target, err := confinedPath(options, target)
+	if err != nil {
+		return err
+	}
+	open, err := openGuardianLog(target)
+	if err != nil {
+		return err
+	}
+	options.WAF.SetGuardianLog(corazawaf.NewGuardianLog(open))
 	return nil
 }
 
@@ -649,6 +1684,11 @@ func directiveSecAuditLogType(options *DirectiveOptions) error {
 // the native AuditLogs format, JSON, or OCSF (Open CyberSecurity Schema Framework).
 // Syntax: SecAuditLogFormat JSON|JsonLegacy|Native|OCSF
 // Default: Native
+// ---
+// The JSON entries follow a versioned schema, written in their schema_version
+// field. The auditlog package provides its JSON Schema and the structs to
+// decode the entries, the fields are only renamed or removed in a new major
+// version.
 func directiveSecAuditLogFormat(options *DirectiveOptions) error {
 	if len(options.Opts) == 0 {
 		return errEmptyOptions
@@ -678,7 +1718,11 @@ func directiveSecAuditLogDir(options *DirectiveOptions) error {
 		return errEmptyOptions
 	}
 
-	options.WAF.AuditLogWriterConfig.Dir = options.Opts
+	dir, err := confinedPath(options, options.Opts)
+	if err != nil {
+		return err
+	}
+	options.WAF.AuditLogWriterConfig.Dir = dir
 
 	return nil
 }
@@ -854,10 +1898,26 @@ func directiveSecDataDir(options *DirectiveOptions) error {
 		return errEmptyOptions
 	}
 
-	options.WAF.DataDir = options.Opts
+	dir, err := confinedPath(options, options.Opts)
+	if err != nil {
+		return err
+	}
+	options.WAF.DataDir = dir
 	return nil
 }
 
+// Description: Configures whether or not the intercepted files will be kept after
+// the transaction is processed. By default the files stored in `SecUploadDir` are
+// removed when the transaction is closed, keeping them allows other tools, e.g. an
+// antivirus, to scan them. Their paths are available in `FILES_TMPNAMES`.
+// Default: Off
+// Syntax: SecUploadKeepFiles On|Off
+// ---
+// Example:
+// ```apache
+// SecUploadDir /tmp/uploads
+// SecUploadKeepFiles On
+// ```
 func directiveSecUploadKeepFiles(options *DirectiveOptions) error {
 	b, err := parseBoolean(options.Opts)
 	if err != nil {
@@ -867,6 +1927,15 @@ func directiveSecUploadKeepFiles(options *DirectiveOptions) error {
 	return nil
 }
 
+// Description: Configures the mode (permissions) of the uploaded files using an octal
+// mode, as used in chmod. When not configured the files are only readable and
+// writable by the owner.
+// Syntax: SecUploadFileMode octal_mode
+// ---
+// Example:
+// ```apache
+// SecUploadFileMode 0640
+// ```
 func directiveSecUploadFileMode(options *DirectiveOptions) error {
 	if len(options.Opts) == 0 {
 		return errEmptyOptions
@@ -880,6 +1949,16 @@ func directiveSecUploadFileMode(options *DirectiveOptions) error {
 	return nil
 }
 
+// Description: Configures the maximum number of file uploads stored per multipart
+// request. The files over the limit are still inspected, but they are not stored and
+// the read-only `MULTIPART_FILE_LIMIT_EXCEEDED` variable is set to 1. 0 means no limit.
+// Default: 0
+// Syntax: SecUploadFileLimit LIMIT
+// ---
+// Example:
+// ```apache
+// SecUploadFileLimit 10
+// ```
 func directiveSecUploadFileLimit(options *DirectiveOptions) error {
 	if len(options.Opts) == 0 {
 		return errEmptyOptions
@@ -890,19 +1969,32 @@ func directiveSecUploadFileLimit(options *DirectiveOptions) error {
 	return err
 }
 
+// Description: Configures the directory where the files of multipart requests are
+// stored, it must be writable by the process. The system temporary directory is used when
+// not configured.
+// Syntax: SecUploadDir /path/to/dir
+// ---
+// Example:
+// ```apache
+// SecUploadDir /tmp/uploads
+// ```
 func directiveSecUploadDir(options *DirectiveOptions) error {
 	if len(options.Opts) == 0 {
 		return errEmptyOptions
 	}
 
+	dir, err := confinedPath(options, options.Opts)
+	if err != nil {
+		return err
+	}
 	if environment.HasAccessToFS {
-		if err := environment.IsDirWritable(options.Opts); err != nil {
+		if err := environment.IsDirWritable(dir); err != nil {
 			return fmt.Errorf("filesystem access check: %w. Check SecUploadDir provided dir: %s", err, options.Opts)
 		}
 	} else {
 		return fmt.Errorf("SecUploadDir directive is not effective because of no access to the filesystem")
 	}
-	options.WAF.UploadDir = options.Opts
+	options.WAF.UploadDir = dir
 	return nil
 }
 
@@ -941,7 +2033,11 @@ func directiveSecDebugLog(options *DirectiveOptions) error {
 		return errEmptyOptions
 	}
 
-	return options.WAF.SetDebugLogPath(options.Opts)
+	file, err := confinedPath(options, options.Opts)
+	if err != nil {
+		return err
+	}
+	return options.WAF.SetDebugLogPath(file)
 }
 
 // Description: Configures the verboseness of the debug log data.
@@ -974,6 +2070,17 @@ func directiveSecDebugLogLevel(options *DirectiveOptions) error {
 // ---
 // This directive will append variables to the specified rule with the targets provided in the second parameter.
 // The rule ID can be single IDs or ranges of IDs. The targets are separated by a pipe character.
+// Targets starting with `!` are added as exclusions to the matching variables of the rule,
+// which is the usual way to tune false positives without modifying the rule itself.
+// It must be placed after the rules it updates.
+//
+// Example:
+// ```apache
+// # Do not inspect ARGS:foo with rule 942100
+// SecRuleUpdateTargetById 942100 "!ARGS:foo"
+// # Inspect REQUEST_HEADERS too with the rules 100 and 200 to 299
+// SecRuleUpdateTargetById 100 200-299 "REQUEST_HEADERS"
+// ```
 func directiveSecRuleUpdateTargetByID(options *DirectiveOptions) error {
 	if len(options.Opts) == 0 {
 		return errEmptyOptions
@@ -985,14 +2092,17 @@ func directiveSecRuleUpdateTargetByID(options *DirectiveOptions) error {
 		return errors.New("syntax error: SecRuleUpdateTargetById id \"VARIABLES\"")
 	}
 	// The last element is expected to be the variable(s)
-	variables := idsOrRanges[length-1]
+	variables := strings.Trim(idsOrRanges[length-1], "\"")
 	for _, idOrRange := range idsOrRanges[:length-1] {
+		idOrRange = options.Parser.RulePack.ruleIDs(idOrRange)
 		if idx := strings.Index(idOrRange, "-"); idx == -1 {
 			id, err := strconv.Atoi(idOrRange)
 			if err != nil {
 				return err
 			}
-			return updateTargetBySingleID(id, variables, options)
+			if err := updateTargetBySingleID(id, variables, options); err != nil {
+				return err
+			}
 		} else {
 			if idx == 0 {
 				return fmt.Errorf("SecRuleUpdateTargetById: invalid negative id: %s", idOrRange)
@@ -1007,22 +2117,20 @@ func directiveSecRuleUpdateTargetByID(options *DirectiveOptions) error {
 				return err
 			}
 			if start == end {
-				return updateTargetBySingleID(start, variables, options)
+				if err := updateTargetBySingleID(start, variables, options); err != nil {
+					return err
+				}
+				continue
 			}
 			if start > end {
 				return fmt.Errorf("invalid range: %s", idOrRange)
 			}
 
-			for _, rule := range options.WAF.Rules.GetRules() {
-				if rule.ID_ >= start && rule.ID_ <= end {
-					rp := RuleParser{
-						rule: &rule,
-						options: RuleOptions{
-							WAF: options.WAF,
-						},
-						defaultActions: map[types.RulePhase][]ruleAction{},
-					}
-					if err := rp.ParseVariables(strings.Trim(variables, "\"")); err != nil {
+			// rules are updated in place, ranging over the values would update copies
+			rules := options.WAF.Rules.GetRules()
+			for i := range rules {
+				if rules[i].ID_ >= start && rules[i].ID_ <= end {
+					if err := updateRuleTargets(&rules[i], variables, options); err != nil {
 						return err
 					}
 				}
@@ -1036,8 +2144,19 @@ func updateTargetBySingleID(id int, variables string, options *DirectiveOptions)
 
 	rule := options.WAF.Rules.FindByID(id)
 	if rule == nil {
+		if ruleDropped(options, id) {
+			options.WAF.Logger.Debug().
+				Int("rule_id", id).
+				Msg("SecRuleUpdateTargetById: ignoring rule above the maximum paranoia level")
+			return nil
+		}
 		return fmt.Errorf("SecRuleUpdateTargetById: rule \"%d\" not found", id)
 	}
+	return updateRuleTargets(rule, variables, options)
+}
+
+// updateRuleTargets appends the variables and variable exclusions to a compiled rule
+func updateRuleTargets(rule *corazawaf.Rule, variables string, options *DirectiveOptions) error {
 	rp := RuleParser{
 		rule: rule,
 		options: RuleOptions{
@@ -1045,7 +2164,7 @@ func updateTargetBySingleID(id int, variables string, options *DirectiveOptions)
 		},
 		defaultActions: map[types.RulePhase][]ruleAction{},
 	}
-	return rp.ParseVariables(strings.Trim(variables, "\""))
+	return rp.ParseVariables(variables)
 }
 
 // Description: Updates the action list of the specified rule(s).
@@ -1072,6 +2191,7 @@ func directiveSecRuleUpdateActionByID(options *DirectiveOptions) error {
 	// The last element is expected to be the action(s)
 	actions := idsOrRanges[idsOrRangesLen-1]
 	for _, idOrRange := range idsOrRanges[:idsOrRangesLen-1] {
+		idOrRange = options.Parser.RulePack.ruleIDs(idOrRange)
 		if idx := strings.Index(idOrRange, "-"); idx == -1 {
 			id, err := strconv.Atoi(idOrRange)
 			if err != nil {
@@ -1106,6 +2226,8 @@ func directiveSecRuleUpdateActionByID(options *DirectiveOptions) error {
 					rule: &rule,
 					options: RuleOptions{
 						WAF: options.WAF,
+						// tags added inside a rule pack are namespaced too
+						ParserConfig: ParserConfig{RulePack: options.Parser.RulePack},
 					},
 					defaultActions: map[types.RulePhase][]ruleAction{},
 				}
@@ -1122,12 +2244,20 @@ func updateActionBySingleID(id int, actions string, options *DirectiveOptions) e
 
 	rule := options.WAF.Rules.FindByID(id)
 	if rule == nil {
+		if ruleDropped(options, id) {
+			options.WAF.Logger.Debug().
+				Int("rule_id", id).
+				Msg("SecRuleUpdateActionById: ignoring rule above the maximum paranoia level")
+			return nil
+		}
 		return fmt.Errorf("SecRuleUpdateActionById: rule \"%d\" not found", id)
 	}
 	rp := RuleParser{
 		rule: rule,
 		options: RuleOptions{
 			WAF: options.WAF,
+			// tags added inside a rule pack are namespaced too
+			ParserConfig: ParserConfig{RulePack: options.Parser.RulePack},
 		},
 		defaultActions: map[types.RulePhase][]ruleAction{},
 	}
@@ -1149,18 +2279,13 @@ func directiveSecRuleUpdateTargetByTag(options *DirectiveOptions) error {
 		return errors.New("syntax error: SecRuleUpdateTargetByTag tag \"VARIABLES\"")
 	}
 
-	for _, rule := range options.WAF.Rules.GetRules() {
-		inputTag := strings.Trim(tagAndvars[0], "\"")
-		if utils.InSlice(inputTag, rule.Tags_) {
-			rp := RuleParser{
-				rule: &rule,
-				options: RuleOptions{
-					WAF: options.WAF,
-				},
-				defaultActions: map[types.RulePhase][]ruleAction{},
-			}
-			inputVars := strings.Trim(tagAndvars[1], "\"")
-			if err := rp.ParseVariables(inputVars); err != nil {
+	inputTag := options.Parser.RulePack.tag(strings.Trim(tagAndvars[0], "\""))
+	inputVars := strings.Trim(tagAndvars[1], "\"")
+	// rules are updated in place, ranging over the values would update copies
+	rules := options.WAF.Rules.GetRules()
+	for i := range rules {
+		if utils.InSlice(inputTag, rules[i].Tags_) {
+			if err := updateRuleTargets(&rules[i], inputVars, options); err != nil {
 				return err
 			}
 		}
@@ -1182,6 +2307,26 @@ func directiveSecIgnoreRuleCompilationErrors(options *DirectiveOptions) error {
 	return nil
 }
 
+// Description: Defines a dataset, a list of values referenced by the `@pmFromDataset`
+// and `@ipMatchFromDataset` operators.
+// Syntax: SecDataset NAME `VALUES`
+// ---
+// The values are separated by new lines, empty lines and comments are ignored. Datasets
+// are scoped to the WAF instance, rule sets loaded in different instances can define
+// datasets with the same name without sharing them. Parsers opting in to a registry with
+// `ShareDatasets` publish their datasets to it and can reference the datasets of the
+// other instances sharing it. `plugins.UpdateDataset` replaces the values of a dataset
+// of an instance at runtime without parsing the rules again, the other instances keep
+// their values.
+//
+// Example:
+// ```apache
+// SecDataset blocklist `
+// 10.0.0.0/8
+// 192.168.1.1
+// `
+// SecRule REMOTE_ADDR "@ipMatchFromDataset blocklist" "id:1,phase:1,deny"
+// ```
 func directiveSecDataset(options *DirectiveOptions) error {
 	if len(options.Opts) == 0 {
 		return errEmptyOptions
@@ -1191,7 +2336,8 @@ func directiveSecDataset(options *DirectiveOptions) error {
 	if !ok {
 		return errors.New("syntax error: SecDataset name `\n...\n`")
 	}
-	if _, ok := options.Datasets[name]; ok {
+	datasets := options.WAF.Datasets()
+	if _, ok := datasets[name]; ok {
 		options.WAF.Logger.Warn().
 			Str("dataset_name", name).
 			Msg("Dataset already exists, overwriting")
@@ -1205,7 +2351,15 @@ func directiveSecDataset(options *DirectiveOptions) error {
 		}
 		arr = append(arr, s)
 	}
-	options.Datasets[name] = arr
+	datasets[name] = arr
+	if shared := options.Parser.SharedDatasets; shared != nil {
+		if _, ok := shared.Get(name); ok {
+			options.WAF.Logger.Warn().
+				Str("dataset_name", name).
+				Msg("Shared dataset already exists, overwriting")
+		}
+		shared.Set(name, arr)
+	}
 	return nil
 }
 
@@ -1231,6 +2385,249 @@ func directiveSecArgumentsLimit(options *DirectiveOptions) error {
 	return nil
 }
 
+// Description: Configures the approximate memory, in bytes, the data of a transaction can use.
+// Default: 0 (no limit)
+// Syntax: SecTransactionMemoryLimit [LIMIT]
+// ---
+// The memory counts the keys and values of the collections, like headers, arguments and cookies,
+// the bodies buffered in memory and the matched data. It is accounted as the data is added, the
+// headers and arguments that would exceed the limit are dropped, and checked before evaluating
+// each rule. The read-only `MEMORY_USAGE` variable is the current usage and
+// `MEMORY_LIMIT_EXCEEDED` is set to 1 once the limit is exceeded. What happens then is
+// configured with `SecTransactionMemoryLimitAction`.
+// It protects shared proxies from requests with huge amounts of headers or arguments.
+//
+// Example:
+// ```apache
+// SecTransactionMemoryLimit 16777216
+// ```
+func directiveSecTransactionMemoryLimit(options *DirectiveOptions) error {
+	limit, err := strconv.ParseInt(options.Opts, 10, 64)
+	if err != nil {
+		return err
+	}
+	if limit < 0 {
+		return errors.New("transaction memory limit should not be negative")
+	}
+	options.WAF.TransactionMemoryLimit = limit
+	return nil
+}
+
+// Description: Controls what happens once a transaction exceeds the memory limit configured
+// with SecTransactionMemoryLimit.
+// Syntax: SecTransactionMemoryLimitAction Reject|Log
+// Default: Reject
+// ---
+// `Reject` interrupts the transaction with a 413 status before evaluating the next rule, the
+// interruption has the id of that rule and it is only enforced when `SecRuleEngine` is On. `Log`
+// logs the transaction and evaluates the rules, which can check `MEMORY_LIMIT_EXCEEDED`.
+//
+// Example:
+// ```apache
+// SecTransactionMemoryLimitAction Log
+// SecRule MEMORY_LIMIT_EXCEEDED "@eq 1" "id:100,phase:2,deny,status:413,log,msg:'Too much data'"
+// ```
+func directiveSecTransactionMemoryLimitAction(options *DirectiveOptions) error {
+	switch strings.ToLower(options.Opts) {
+	case "reject":
+		options.WAF.TransactionMemoryLimitReject = true
+	case "log":
+		options.WAF.TransactionMemoryLimitReject = false
+	default:
+		return errors.New("syntax error: SecTransactionMemoryLimitAction [Reject/Log]")
+	}
+	return nil
+}
+
+// Description: Adds a label identifying the WAF instance in logs.
+// Syntax: SecLabel KEY VALUE
+// ---
+// Processes running several WAF instances, e.g. one per listener, can use labels to tell
+// which instance produced an event. Labels are added to the producer section of the JSON
+// audit logs and as fields of the transaction debug logs. The directive can be repeated
+// to set multiple labels, setting an existing key overwrites its value.
+//
+// Example:
+// ```apache
+// SecLabel cluster eu-west-1
+// SecLabel listener public-https
+// ```
+func directiveSecLabel(options *DirectiveOptions) error {
+	key, value, ok := strings.Cut(options.Opts, " ")
+	key, value = strings.TrimSpace(key), strings.Trim(strings.TrimSpace(value), `"`)
+	if !ok || key == "" || value == "" {
+		return errors.New("syntax error: SecLabel KEY VALUE")
+	}
+	options.WAF.SetLabel(key, value)
+	return nil
+}
+
+// Description: Configures whether transactions reusing the ID of a transaction in flight are logged.
+// Syntax: SecTransactionIDDuplicateDetection On|Off
+// Default: Off
+// ---
+// Connectors propagating upstream request IDs may create concurrent transactions with the
+// same ID, conflating their audit log entries. When enabled, a warning is written to the
+// debug log every time a transaction is created with the ID of a transaction not closed yet.
+//
+// Example:
+// ```apache
+// SecTransactionIDDuplicateDetection On
+// ```
+func directiveSecTransactionIDDuplicateDetection(options *DirectiveOptions) error {
+	if len(options.Opts) == 0 {
+		return errEmptyOptions
+	}
+
+	b, err := parseBoolean(strings.ToLower(options.Opts))
+	if err != nil {
+		return err
+	}
+	options.WAF.DetectDuplicateTransactionIDs = b
+	return nil
+}
+
+// Description: Configures how url-encoded arguments using PHP/Rails style array keys are named.
+// Default: Raw
+// Syntax: SecArgumentsArrayMode Raw|Flatten
+// ---
+// With `Raw`, keys are kept as sent, so `a[]=1&a[]=2` creates two `ARGS:a[]` values.
+// With `Flatten`, empty brackets are replaced by the index the application would assign,
+// so the same query creates `ARGS:a[0]` and `ARGS:a[1]`, and `a[b][]=c` creates `ARGS:a[b][0]`.
+// It applies to the query string and to `application/x-www-form-urlencoded` request bodies.
+//
+// Example:
+// ```apache
+// SecArgumentsArrayMode Flatten
+// SecRule ARGS:ids[0] "!@rx ^[0-9]+$" "id:100,phase:2,deny"
+// ```
+func directiveSecArgumentsArrayMode(options *DirectiveOptions) error {
+	switch strings.ToLower(options.Opts) {
+	case "raw":
+		options.WAF.FlattenArrayArguments = false
+	case "flatten":
+		options.WAF.FlattenArrayArguments = true
+	default:
+		return fmt.Errorf("invalid arguments array mode %q, expected Raw or Flatten", options.Opts)
+	}
+	return nil
+}
+
+// Description: Configures whether the XML body processor resolves the external entities
+// declared in the DOCTYPE of request bodies, e.g. `<!ENTITY name SYSTEM "file:///etc/passwd">`,
+// including the parameter entities, e.g. `<!ENTITY % name SYSTEM "http://host/name.dtd">`,
+// and the external DTDs they reference, e.g. `<!DOCTYPE name SYSTEM "http://host/name.dtd">`.
+// Default: Off
+// Syntax: SecXmlExternalEntity On|Off
+// ---
+// With `Off`, external entities and DTDs are never resolved and the entity references are
+// inspected as sent. With `On`, they are resolved by the resolver registered with
+// `plugins.RegisterXMLEntityResolver`, which decides what can be loaded; the directive
+// fails when no resolver is registered. Resolving external entities exposes the WAF to
+// XXE attacks, only turn it on with a resolver restricted to trusted resources.
+//
+// Either way, the read-only `XML_EXTERNAL_ENTITY` variable is set to 1 when a body references
+// external entities, including unparsed NDATA entities, or DTDs, so rules can alert on XXE attempts.
+//
+// Example:
+// ```apache
+// SecXmlExternalEntity Off
+// SecRule XML_EXTERNAL_ENTITY "@eq 1" "id:200,phase:2,deny,msg:'XML external entity'"
+// ```
+func directiveSecXMLExternalEntity(options *DirectiveOptions) error {
+	b, err := parseBoolean(options.Opts)
+	if err != nil {
+		return err
+	}
+	if b && bodyprocessors.GetXMLEntityResolver() == nil {
+		return errors.New("SecXmlExternalEntity On requires an XML entity resolver, none is registered")
+	}
+	options.WAF.XMLExternalEntity = b
+	return nil
+}
+
+// Description: Configures the maximum number of matched variables kept for a single rule.
+// Default: 0 (unlimited)
+// Syntax: SecRuleMatchLimit [LIMIT]
+// ---
+// Rules targeting large collections, like `ARGS` on a request with thousands of parameters,
+// can produce a match for every variable. Matches over the limit are still evaluated and
+// trigger the rule actions, but they are not kept for the matched data and the audit logs.
+// Rules exceeding the limit are flagged as truncated.
+// Example:
+// ```apache
+// SecRuleMatchLimit 100
+// ```
+func directiveSecRuleMatchLimit(options *DirectiveOptions) error {
+	limit, err := strconv.Atoi(options.Opts)
+	if err != nil {
+		return err
+	}
+	if limit < 0 {
+		return errors.New("rule match limit should not be negative")
+	}
+	options.WAF.RuleMatchLimit = limit
+	return nil
+}
+
+// Description: Configures the maximum number of groups captured by the `capture` action.
+// Default: 10
+// Syntax: SecCaptureLimit [LIMIT]
+// ---
+// Operators supporting `capture`, like `@rx` and `@pm`, store the captured fields in
+// TX:0, TX:1 and so on. By default only TX:0 to TX:9 are populated, extraction rules
+// requiring more groups can raise the limit. The number of fields captured by the last
+// operator is available in the read-only `CAPTURE_COUNT` variable, 0 after a rule
+// without captures. The named groups of `@rx`, captured in
+// TX:<name>, are not bounded by the limit.
+// Example:
+// ```apache
+// SecCaptureLimit 20
+// SecRule REQUEST_URI "@rx ^/(a)/(b)/(c)/(d)/(e)/(f)/(g)/(h)/(i)/(j)/(k)$" "id:1,phase:1,capture,log,msg:'%{tx.11}'"
+// ```
+func directiveSecCaptureLimit(options *DirectiveOptions) error {
+	limit, err := strconv.Atoi(options.Opts)
+	if err != nil {
+		return err
+	}
+	if limit <= 0 {
+		return errors.New("capture limit should be bigger than 0")
+	}
+	options.WAF.CaptureLimit = limit
+	return nil
+}
+
+// Description: Configures the flags applied by default to the regular expressions of the `@rx` operator.
+// Default: sm (s when built with coraza.rule.no_regex_multiline)
+// Syntax: SecRxDefaultFlags FLAGS|none
+// ---
+// Without this directive, `@rx` expressions are compiled with the dotall (`s`) and multiline (`m`)
+// flags, or only dotall depending on the build tags. Rules relying on those implicit flags are
+// reported at load time, as the multiline default will change in future versions. Setting the
+// flags explicitly keeps the behavior independent of the build, `none` disables them. Valid flags
+// are `i` (case insensitive), `m` (multiline), `s` (dotall) and `U` (ungreedy).
+// The flags apply to the rules defined after the directive. A rule can still set or clear
+// them with a leading flag group, e.g. `(?-m)`.
+// Example:
+// ```apache
+// SecRxDefaultFlags s
+// SecRule ARGS "@rx ^admin" "id:1,phase:1,deny"
+// ```
+func directiveSecRxDefaultFlags(options *DirectiveOptions) error {
+	if len(options.Opts) == 0 {
+		return errEmptyOptions
+	}
+	flags := options.Opts
+	if strings.EqualFold(flags, "none") {
+		flags = ""
+	} else if strings.Trim(flags, "imsU") != "" {
+		return fmt.Errorf("invalid rx flags %q, expected a combination of i, m, s and U", flags)
+	}
+	options.Parser.RxDefaultFlags = flags
+	options.Parser.HasRxDefaultFlags = true
+	return nil
+}
+
 func parseBoolean(data string) (bool, error) {
 	data = strings.ToLower(data)
 	switch data {
@@ -0,0 +1,28 @@
+// Copyright 2024 Juan Pablo Tosso and the OWASP Coraza contributors
+// SPDX-License-Identifier: Apache-2.0
+
+//go:build !tinygo
+// +build !tinygo
+
+package seclang
+
+import (
+	"fmt"
+	"io/fs"
+
+	"github.com/ad3n/seclang/experimental/plugins/plugintypes"
+	"github.com/ad3n/seclang/internal/geoip"
+)
+
+// openGeoDatabase reads a MaxMind database in memory
+func openGeoDatabase(root fs.FS, file string) (plugintypes.GeoDatabase, error) {
+	data, err := fs.ReadFile(root, file)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read geo database: %s", err.Error())
+	}
+	db, err := geoip.Open(data)
+	if err != nil {
+		return nil, err
+	}
+	return db, nil
+}
@@ -0,0 +1,19 @@
+// Copyright 2024 Juan Pablo Tosso and the OWASP Coraza contributors
+// SPDX-License-Identifier: Apache-2.0
+
+//go:build tinygo
+// +build tinygo
+
+package seclang
+
+import (
+	"io/fs"
+
+	"github.com/ad3n/seclang/experimental/plugins/plugintypes"
+)
+
+// openGeoDatabase doesn't load databases in TinyGo, they are too large for
+// the memory available to most TinyGo targets. @geoLookup matches every address.
+func openGeoDatabase(fs.FS, string) (plugintypes.GeoDatabase, error) {
+	return nil, nil
+}
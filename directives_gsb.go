@@ -0,0 +1,37 @@
+// Copyright 2024 Juan Pablo Tosso and the OWASP Coraza contributors
+// SPDX-License-Identifier: Apache-2.0
+
+//go:build !tinygo
+// +build !tinygo
+
+package seclang
+
+import (
+	"fmt"
+	"io/fs"
+	"strings"
+
+	"github.com/ad3n/seclang/experimental/plugins/plugintypes"
+	"github.com/ad3n/seclang/internal/urlreputation"
+)
+
+// openURLReputation returns the provider registered for the scheme of the
+// URI, or reads the local list of the file
+func openURLReputation(root fs.FS, source string) (plugintypes.URLReputation, error) {
+	if scheme, _, ok := strings.Cut(source, "://"); ok {
+		factory, ok := urlreputation.Get(scheme)
+		if !ok {
+			return nil, fmt.Errorf("unknown URL reputation provider %q", scheme)
+		}
+		return factory(source)
+	}
+	data, err := fs.ReadFile(root, source)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read URL reputation list: %s", err.Error())
+	}
+	list, err := urlreputation.ParseHashSet(data)
+	if err != nil {
+		return nil, err
+	}
+	return list, nil
+}
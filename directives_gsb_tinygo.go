@@ -0,0 +1,19 @@
+// Copyright 2024 Juan Pablo Tosso and the OWASP Coraza contributors
+// SPDX-License-Identifier: Apache-2.0
+
+//go:build tinygo
+// +build tinygo
+
+package seclang
+
+import (
+	"io/fs"
+
+	"github.com/ad3n/seclang/experimental/plugins/plugintypes"
+)
+
+// openURLReputation doesn't configure providers in TinyGo, where the APIs
+// can't be called. @gsbLookup doesn't match.
+func openURLReputation(fs.FS, string) (plugintypes.URLReputation, error) {
+	return nil, nil
+}
@@ -0,0 +1,72 @@
+// Copyright 2024 Juan Pablo Tosso and the OWASP Coraza contributors
+// SPDX-License-Identifier: Apache-2.0
+
+//go:build !tinygo
+// +build !tinygo
+
+package seclang
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+	"syscall"
+)
+
+// openGuardianLog returns the function opening the target of the guardian
+// log. Programs of |program targets are started by it and their standard
+// input is returned, other targets are files or named pipes opened for
+// appending. Named pipes are opened without blocking, so they are opened
+// again until they have a reader.
+func openGuardianLog(target string) (func() (io.Writer, error), error) {
+	cmdline, ok := strings.CutPrefix(target, "|")
+	if !ok {
+		f, err := openGuardianLogFile(target)
+		if err == nil {
+			return func() (io.Writer, error) { return f, nil }, nil
+		}
+		if !errors.Is(err, syscall.ENXIO) {
+			return nil, fmt.Errorf("failed to open guardian log: %s", err.Error())
+		}
+		// a named pipe without reader
+		return func() (io.Writer, error) { return openGuardianLogFile(target) }, nil
+	}
+	args := strings.Fields(cmdline)
+	if len(args) == 0 {
+		return nil, errors.New("missing guardian log program")
+	}
+	if _, err := exec.LookPath(args[0]); err != nil {
+		return nil, fmt.Errorf("failed to find guardian log program: %s", err.Error())
+	}
+	return func() (io.Writer, error) {
+		cmd := exec.Command(args[0], args[1:]...)
+		cmd.Stdout = os.Stdout
+		cmd.Stderr = os.Stderr
+		stdin, err := cmd.StdinPipe()
+		if err != nil {
+			return nil, err
+		}
+		if err := cmd.Start(); err != nil {
+			return nil, fmt.Errorf("failed to start guardian log program: %s", err.Error())
+		}
+		return &guardianProgram{WriteCloser: stdin, cmd: cmd}, nil
+	}, nil
+}
+
+func openGuardianLogFile(target string) (*os.File, error) {
+	return os.OpenFile(target, os.O_WRONLY|os.O_APPEND|os.O_CREATE|syscall.O_NONBLOCK, 0600)
+}
+
+// guardianProgram is the standard input of a guardian log program, closing
+// it waits for the program to exit
+type guardianProgram struct {
+	io.WriteCloser
+	cmd *exec.Cmd
+}
+
+func (p *guardianProgram) Close() error {
+	return errors.Join(p.WriteCloser.Close(), p.cmd.Wait())
+}
@@ -0,0 +1,17 @@
+// Copyright 2024 Juan Pablo Tosso and the OWASP Coraza contributors
+// SPDX-License-Identifier: Apache-2.0
+
+//go:build tinygo
+// +build tinygo
+
+package seclang
+
+import (
+	"errors"
+	"io"
+)
+
+// openGuardianLog is not supported in TinyGo, it can't start programs
+func openGuardianLog(string) (func() (io.Writer, error), error) {
+	return nil, errors.New("SecGuardianLog is not supported in TinyGo")
+}
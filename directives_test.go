@@ -4,14 +4,28 @@
 package seclang
 
 import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/netip"
+	"net/url"
 	"os"
+	"os/exec"
+	"path/filepath"
 	"regexp"
+	"slices"
 	"strings"
 	"testing"
+	"testing/fstest"
+	"time"
 
+	"github.com/ad3n/seclang/experimental/plugins/plugintypes"
+	"github.com/ad3n/seclang/internal/corazatypes"
 	"github.com/ad3n/seclang/internal/corazawaf"
 	"github.com/ad3n/seclang/internal/environment"
+	"github.com/ad3n/seclang/internal/urlreputation"
 
+	"github.com/corazawaf/coraza/v3/debuglog"
 	"github.com/corazawaf/coraza/v3/types"
 )
 
@@ -19,8 +33,6 @@ func Test_NonImplementedDirective(t *testing.T) {
 	rules := []string{
 		`SecSensorId WAFSensor01`,
 		`SecConnReadStateLimit 50 "!@ipMatch 127.0.0.1"`,
-		`SecPcreMatchLimit 1500`,
-		`SecPcreMatchLimitRecursion 1500`,
 		`SecHttpBlKey whdkfieyhtnf`,
 		`SecHashMethodRx HashHref "product_info|list_product"`,
 		`SecHashMethodPm HashHref“product_info list_product”`,
@@ -88,6 +100,647 @@ func TestSecRuleUpdateTargetByID(t *testing.T) {
 
 }
 
+func TestSecRuleUpdateTarget(t *testing.T) {
+	waf := corazawaf.NewWAF()
+	p := NewParser(waf)
+	if err := p.FromString(`
+SecRule ARGS "@rx attack" "id:100,phase:1,pass,log"
+SecRule ARGS "@rx attack" "id:150,phase:1,pass,log,tag:headers"
+SecRule ARGS "@rx attack" "id:200,phase:1,pass,log,tag:headers"
+SecRuleUpdateTargetById 100 150-160 "!ARGS:safe"
+SecRuleUpdateTargetByTag headers "REQUEST_HEADERS:x-attack"
+`); err != nil {
+		t.Fatal(err)
+	}
+
+	matched := func(tx *corazawaf.Transaction) []int {
+		defer tx.Close()
+		tx.ProcessRequestHeaders()
+		var ids []int
+		for _, mr := range tx.MatchedRules() {
+			ids = append(ids, mr.Rule().ID())
+		}
+		return ids
+	}
+
+	tx := waf.NewTransaction()
+	tx.AddGetRequestArgument("safe", "attack")
+	if want, have := []int{200}, matched(tx); !slices.Equal(want, have) {
+		t.Errorf("unexpected matched rules for excluded argument, want %v, have %v", want, have)
+	}
+
+	tx = waf.NewTransaction()
+	tx.AddRequestHeader("X-Attack", "attack")
+	if want, have := []int{150, 200}, matched(tx); !slices.Equal(want, have) {
+		t.Errorf("unexpected matched rules for added target, want %v, have %v", want, have)
+	}
+}
+
+func TestSecRuleRemoveByRegex(t *testing.T) {
+	waf := corazawaf.NewWAF()
+	p := NewParser(waf)
+	if err := p.FromString(`
+SecAction "id:1,msg:'SQL Injection Attack',tag:attack-sqli"
+SecAction "id:2,msg:'SQL Injection Attack Detected via libinjection',tag:attack-sqli"
+SecAction "id:3,msg:'XSS Attack',tag:attack-xss,tag:paranoia-level/3"
+SecAction "id:4,msg:'Other',tag:paranoia-level/1"
+SecAction "id:5,msg:'Other',tag:paranoia-level/4"
+SecRuleRemoveByMsg "^SQL Injection Attack$"
+SecRuleRemoveByTag "^paranoia-level/[34]$"
+`); err != nil {
+		t.Fatal(err)
+	}
+
+	var ids []int
+	for _, r := range waf.Rules.GetRules() {
+		ids = append(ids, r.ID_)
+	}
+	if want, have := []int{2, 4}, ids; !slices.Equal(want, have) {
+		t.Errorf("unexpected remaining rules, want %v, have %v", want, have)
+	}
+
+	if err := p.FromString("SecRuleRemoveByTag attack"); err != nil {
+		t.Fatal(err)
+	}
+	if want, have := 1, waf.Rules.Count(); want != have {
+		t.Errorf("unexpected rule count after unanchored removal, want %d, have %d", want, have)
+	}
+}
+
+func TestSecRxDefaultFlags(t *testing.T) {
+	waf := corazawaf.NewWAF()
+	logs := &bytes.Buffer{}
+	waf.Logger = debuglog.Default().WithLevel(debuglog.LevelDebug).WithOutput(logs)
+	p := NewParser(waf)
+	if err := p.FromString(`
+SecRule ARGS:a "@rx ^b" "id:1,phase:1,pass,nolog"
+SecRule ARGS:c "@rx ^b" "id:4,phase:1,pass,nolog,chain"
+	SecRule ARGS:c "@rx ^d" "t:none"
+SecRxDefaultFlags none
+SecRule ARGS:a "@rx ^b" "id:2,phase:1,pass,nolog"
+SecRxDefaultFlags sm
+SecRule ARGS:a "@rx ^b" "id:3,phase:1,pass,nolog"
+`); err != nil {
+		t.Fatal(err)
+	}
+	if want, have := 1, strings.Count(logs.String(), "Rules rely on the implicit rx flags"); want != have {
+		t.Errorf("unexpected number of implicit flags reports, want %d, have %d: %s", want, have, logs.String())
+	}
+	if want := `rule_ids="1,4"`; !strings.Contains(logs.String(), want) {
+		t.Errorf("expected the report to list the rules, want %s, have %s", want, logs.String())
+	}
+
+	tx := waf.NewTransaction()
+	tx.AddGetRequestArgument("a", "a\nb")
+	tx.ProcessRequestHeaders()
+	var matched []int
+	for _, mr := range tx.MatchedRules() {
+		matched = append(matched, mr.Rule().ID())
+	}
+	if want, have := []int{1, 3}, matched; !slices.Equal(want, have) {
+		t.Errorf("unexpected matched rules, want %v, have %v", want, have)
+	}
+
+	for _, flags := range []string{"", "x", "sm,i"} {
+		if err := NewParser(corazawaf.NewWAF()).FromString("SecRxDefaultFlags " + flags); err == nil {
+			t.Errorf("expected error for flags %q", flags)
+		}
+	}
+}
+
+func TestSecPcreMatchLimit(t *testing.T) {
+	p := NewParser(corazawaf.NewWAF())
+	if err := p.FromString(`
+SecPcreMatchLimit 1500
+SecPcreMatchLimitRecursion 500
+`); err != nil {
+		t.Fatal(err)
+	}
+	if want, have := 1500, p.options.Parser.PcreMatchLimit; want != have {
+		t.Errorf("unexpected match limit, want %d, have %d", want, have)
+	}
+	if want, have := 500, p.options.Parser.PcreMatchLimitRecursion; want != have {
+		t.Errorf("unexpected match limit recursion, want %d, have %d", want, have)
+	}
+
+	for _, directive := range []string{"SecPcreMatchLimit", "SecPcreMatchLimitRecursion"} {
+		for _, limit := range []string{"", "0", "many"} {
+			if err := NewParser(corazawaf.NewWAF()).FromString(directive + " " + limit); err == nil {
+				t.Errorf("expected error for %s %q", directive, limit)
+			}
+		}
+	}
+}
+
+func TestSecUnicodeMapFile(t *testing.T) {
+	mapping, err := os.ReadFile("internal/transformations/testdata/unicode.mapping")
+	if err != nil {
+		t.Fatal(err)
+	}
+	root := fstest.MapFS{"rules/unicode.mapping": {Data: mapping}}
+
+	for name, directives := range map[string]string{
+		"code page in map file": "SecUnicodeMapFile rules/unicode.mapping 20127",
+		"legacy code page":      "SecUnicodeMapFile rules/unicode.mapping\nSecUnicodeCodePage 20127",
+	} {
+		t.Run(name, func(t *testing.T) {
+			waf := corazawaf.NewWAF()
+			p := NewParser(waf)
+			p.SetRoot(root)
+			if err := p.FromString(`
+SecRule ARGS:user "@streq admin" "id:1,phase:1,t:urlDecodeUni,deny,status:401"
+` + directives + `
+SecRule ARGS:user "@streq admin" "id:2,phase:1,t:urlDecodeUni,deny,status:403"
+`); err != nil {
+				t.Fatal(err)
+			}
+			if waf.UnicodeMap == nil || waf.UnicodeMap.CodePage() != 20127 {
+				t.Fatal("expected the unicode map of code page 20127 to be loaded")
+			}
+
+			tx := waf.NewTransaction()
+			defer tx.Close()
+			tx.AddGetRequestArgument("user", "adm%u0131n")
+			it := tx.ProcessRequestHeaders()
+			if it == nil {
+				t.Fatal("expected interruption")
+			}
+			// rules declared before the map keep decoding the lower byte
+			if want, have := 403, it.Status; want != have {
+				t.Errorf("unexpected interruption status, want %d, have %d", want, have)
+			}
+		})
+	}
+
+	waf := corazawaf.NewWAF()
+	logs := &bytes.Buffer{}
+	waf.Logger = debuglog.Default().WithLevel(debuglog.LevelWarn).WithOutput(logs)
+	p := NewParser(waf)
+	p.SetRoot(root)
+	if err := p.FromString("SecUnicodeMapFile rules/unicode.mapping"); err != nil {
+		t.Fatal(err)
+	}
+	if waf.UnicodeMap != nil {
+		t.Error("expected the unicode map not to be loaded without code page")
+	}
+	if !strings.Contains(logs.String(), "Unicode map file without code page") {
+		t.Errorf("expected a warning for the missing code page, have %q", logs.String())
+	}
+	if err := p.FromString("SecUnicodeMapFile rules/unicode.mapping 1"); err == nil {
+		t.Error("expected error for missing code page")
+	}
+	if err := p.FromString("SecUnicodeMapFile rules/missing.mapping 20127"); err == nil {
+		t.Error("expected error for missing file")
+	}
+}
+
+func TestSecHashEngine(t *testing.T) {
+	waf := corazawaf.NewWAF()
+	if err := NewParser(waf).FromString(`
+SecResponseBodyAccess On
+SecResponseBodyMimeType text/html
+SecHashEngine On
+SecHashKey secret KeyOnly
+SecHashMethodRx HashHref "^/account/"
+SecRule REQUEST_URI "@validateHash ^/account/" "id:1,phase:1,deny,status:403"
+`); err != nil {
+		t.Fatal(err)
+	}
+
+	tx := waf.NewTransaction()
+	tx.ProcessURI("/", "GET", "HTTP/1.1")
+	tx.ProcessRequestHeaders()
+	tx.AddResponseHeader("Content-Type", "text/html")
+	tx.ProcessResponseHeaders(200, "HTTP/1.1")
+	if _, _, err := tx.WriteResponseBody([]byte(`<a href="/account/edit">edit</a><a href="/about">about</a>`)); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := tx.ProcessResponseBody(); err != nil {
+		t.Fatal(err)
+	}
+	r, err := tx.ResponseBodyReader()
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tx.Close()
+	link, _, _ := strings.Cut(strings.TrimPrefix(string(body), `<a href="`), `"`)
+	if !strings.HasPrefix(link, "/account/edit?hmac=") {
+		t.Fatalf("expected the account link to be signed, have %q", body)
+	}
+
+	for uri, want := range map[string]int{
+		link:                    0,
+		"/account/edit":         403,
+		"/account/edit?hmac=00": 403,
+		"/about":                0,
+		strings.Replace(link, "edit", "delete", 1): 403,
+	} {
+		tx := waf.NewTransaction()
+		tx.ProcessURI(uri, "GET", "HTTP/1.1")
+		status := 0
+		if it := tx.ProcessRequestHeaders(); it != nil {
+			status = it.Status
+		}
+		if want != status {
+			t.Errorf("unexpected status for %q, want %d, have %d", uri, want, status)
+		}
+		tx.Close()
+	}
+}
+
+func TestSecGuardianLog(t *testing.T) {
+	if !environment.HasAccessToFS {
+		t.Skip("skipping test as it requires access to filesystem")
+	}
+	file := filepath.Join(t.TempDir(), "guardian.log")
+	waf := corazawaf.NewWAF()
+	p := NewParser(waf)
+	if err := p.FromString(fmt.Sprintf("SecAuditEngine Off\nSecGuardianLog %q", file)); err != nil {
+		t.Fatal(err)
+	}
+
+	tx := waf.NewTransaction()
+	tx.ProcessConnection("10.0.0.1", 1234, "10.0.0.2", 80)
+	tx.ProcessURI("/index.html", "GET", "HTTP/1.1")
+	tx.AddRequestHeader("Host", "example.com")
+	tx.ProcessRequestHeaders()
+	tx.ProcessResponseHeaders(200, "HTTP/1.1")
+	tx.ProcessLogging()
+	id := tx.ID()
+	tx.Close()
+	// the lines are written once the WAF is closed
+	if err := waf.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := os.ReadFile(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want, have := `example.com 10.0.0.1 - - [`, string(data); !strings.HasPrefix(have, want) {
+		t.Errorf("unexpected guardian log line, want prefix %q, have %q", want, have)
+	}
+	if want, have := `"GET /index.html HTTP/1.1" 200 - "" "" `+id, string(data); !strings.Contains(have, want) {
+		t.Errorf("unexpected guardian log line, want %q in %q", want, have)
+	}
+
+	if err := p.FromString("SecGuardianLog |"); err == nil {
+		t.Error("expected error for missing program")
+	}
+}
+
+func TestSecGuardianLogProgram(t *testing.T) {
+	if !environment.HasAccessToFS {
+		t.Skip("skipping test as it requires access to filesystem")
+	}
+	dd, err := exec.LookPath("dd")
+	if err != nil {
+		t.Skip("skipping test as it requires dd")
+	}
+	file := filepath.Join(t.TempDir(), "guardian.log")
+	waf := corazawaf.NewWAF()
+	p := NewParser(waf)
+	if err := p.FromString(fmt.Sprintf("SecAuditEngine Off\nSecGuardianLog \"|%s of=%s status=none\"", dd, file)); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(file); !os.IsNotExist(err) {
+		t.Error("unexpected guardian log program started before the first transaction")
+	}
+
+	for i := 0; i < 3; i++ {
+		tx := waf.NewTransaction()
+		tx.ProcessURI("/", "GET", "HTTP/1.1")
+		tx.ProcessLogging()
+		tx.Close()
+	}
+	// closing the WAF waits for the program to exit
+	if err := waf.Close(); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want, have := 3, strings.Count(string(data), "\n"); want != have {
+		t.Errorf("unexpected number of guardian log lines, want %d, have %d", want, have)
+	}
+}
+
+func TestSecRuleMessageCatalog(t *testing.T) {
+	root := fstest.MapFS{
+		"rules/es.txt":          {Data: []byte("1 Usuario bloqueado: %{MATCHED_VAR}\n2 Sustituido\n")},
+		"rules/es-override.txt": {Data: []byte("2 Sustituido de nuevo\n")},
+	}
+	waf := corazawaf.NewWAF()
+	p := NewParser(waf)
+	p.SetRoot(root)
+	if err := p.FromString(`
+SecRuleMessageCatalog rules/es.txt
+SecRuleMessageCatalog rules/es-override.txt
+SecAuditLogParts ABHKZ
+SecRule ARGS:user "@streq admin" "id:1,phase:1,log,pass,msg:'User blocked'"
+SecRule ARGS:user "@streq admin" "id:2,phase:1,log,pass,msg:'Replaced'"
+SecRule ARGS:user "@streq admin" "id:3,phase:1,log,pass,msg:'Kept'"
+`); err != nil {
+		t.Fatal(err)
+	}
+
+	tx := waf.NewTransaction()
+	defer tx.Close()
+	tx.AddGetRequestArgument("user", "admin")
+	tx.ProcessRequestHeaders()
+	var messages []string
+	for _, m := range tx.AuditLog().Messages() {
+		messages = append(messages, m.Message(), m.Data().Msg())
+	}
+	want := []string{"Usuario bloqueado: admin", "Usuario bloqueado: admin", "Sustituido de nuevo", "Sustituido de nuevo", "Kept", "Kept"}
+	if !slices.Equal(want, messages) {
+		t.Errorf("unexpected audit log messages, want %q, have %q", want, messages)
+	}
+
+	if err := p.FromString("SecRuleMessageCatalog rules/missing.txt"); err == nil {
+		t.Error("expected error for missing catalog")
+	}
+	root["rules/invalid.txt"] = &fstest.MapFile{Data: []byte("abc message")}
+	if err := p.FromString("SecRuleMessageCatalog rules/invalid.txt"); err == nil {
+		t.Error("expected error for invalid catalog")
+	}
+}
+
+func TestSecStreamBodyInspection(t *testing.T) {
+	waf := corazawaf.NewWAF()
+	if err := NewParser(waf).FromString(`
+SecRequestBodyAccess On
+SecResponseBodyAccess On
+SecResponseBodyMimeType text/plain
+SecStreamInBodyInspection On
+SecStreamOutBodyInspection On
+SecRule STREAM_INPUT_BODY "@rsub s/password=[^&]*/password=redacted/" "id:1,phase:2,t:none,pass,nolog"
+SecRule STREAM_INPUT_BODY "@contains password=redacted" "id:2,phase:2,t:none,pass,nolog"
+SecRule STREAM_OUTPUT_BODY "@rsub s/(internal) error: .*/$1 error/" "id:3,phase:4,t:none,pass,nolog"
+`); err != nil {
+		t.Fatal(err)
+	}
+
+	tx := waf.NewTransaction()
+	defer tx.Close()
+	tx.ProcessURI("/login", "POST", "HTTP/1.1")
+	tx.AddRequestHeader("Content-Type", "multipart/form-data; boundary=x")
+	tx.ProcessRequestHeaders()
+	if _, _, err := tx.WriteRequestBody([]byte("user=admin&password=1234&next=/")); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := tx.ProcessRequestBody(); err != nil {
+		t.Fatal(err)
+	}
+	tx.AddResponseHeader("Content-Type", "text/plain")
+	tx.ProcessResponseHeaders(500, "HTTP/1.1")
+	if _, _, err := tx.WriteResponseBody([]byte("internal error: stack trace")); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := tx.ProcessResponseBody(); err != nil {
+		t.Fatal(err)
+	}
+
+	var (
+		matched []int
+		names   []string
+	)
+	for _, mr := range tx.MatchedRules() {
+		matched = append(matched, mr.Rule().ID())
+		for _, md := range mr.MatchedDatas() {
+			names = append(names, corazatypes.VariableName(md.Variable()))
+		}
+	}
+	if want, have := []int{1, 2, 3}, matched; !slices.Equal(want, have) {
+		t.Errorf("unexpected matched rules, want %v, have %v", want, have)
+	}
+	if want, have := []string{"STREAM_INPUT_BODY", "STREAM_INPUT_BODY", "STREAM_OUTPUT_BODY"}, names; !slices.Equal(want, have) {
+		t.Errorf("unexpected matched variables, want %v, have %v", want, have)
+	}
+	for name, want := range map[string]string{
+		"request":  "user=admin&password=redacted&next=/",
+		"response": "internal error",
+	} {
+		read := tx.RequestBodyReader
+		if name == "response" {
+			read = tx.ResponseBodyReader
+		}
+		r, err := read()
+		if err != nil {
+			t.Fatal(err)
+		}
+		have, err := io.ReadAll(r)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want != string(have) {
+			t.Errorf("unexpected %s body, want %q, have %q", name, want, have)
+		}
+	}
+
+	for _, rule := range []string{
+		`SecRule STREAM_INPUT_BODY "@rx a" "id:1,phase:2"`,
+		`SecRule STREAM_OUTPUT_BODY "@rx a" "id:1,phase:4"`,
+	} {
+		if err := NewParser(corazawaf.NewWAF()).FromString(rule); err == nil {
+			t.Errorf("expected error for %q without stream inspection", rule)
+		}
+	}
+}
+
+func TestSecRulePerfTime(t *testing.T) {
+	waf := corazawaf.NewWAF()
+	if err := NewParser(waf).FromString(`
+SecRulePerfTime 1
+SecRule PERF_PHASE1 "@ge 0" "id:1,phase:2,pass,nolog"
+SecRule PERF_COMBINED "@ge 0" "id:2,phase:2,pass,nolog"
+SecRule &PERF_RULES:2 "@ge 0" "id:3,phase:2,pass,nolog"
+SecRule &PERF_RULES "@lt 0" "id:4,phase:2,pass,nolog"
+`); err != nil {
+		t.Fatal(err)
+	}
+
+	tx := waf.NewTransaction()
+	defer tx.Close()
+	tx.ProcessRequestHeaders()
+	if _, err := tx.ProcessRequestBody(); err != nil {
+		t.Fatal(err)
+	}
+	var matched []int
+	for _, mr := range tx.MatchedRules() {
+		matched = append(matched, mr.Rule().ID())
+	}
+	if want, have := []int{1, 2, 3}, matched; !slices.Equal(want, have) {
+		t.Errorf("unexpected matched rules, want %v, have %v", want, have)
+	}
+
+	if err := NewParser(corazawaf.NewWAF()).FromString(`SecRule PERF_PHASE1:foo "@ge 0" "id:1,phase:2"`); err == nil {
+		t.Error("expected error selecting a key of PERF_PHASE1")
+	}
+}
+
+func TestSecRxBudget(t *testing.T) {
+	waf := corazawaf.NewWAF()
+	if err := NewParser(waf).FromString(`
+SecRxBudget input=4
+SecRule REQUEST_URI "@rx a+b" "id:1,phase:1,pass,nolog"
+SecRule REQUEST_URI "@rx a+b" "id:2,phase:1,pass,nolog,rxBudget:off"
+SecAction "id:10,phase:1,pass,nolog,setvar:tx.rx_budget_exceeded=0"
+SecRule RX_BUDGET_EXCEEDED "@eq 1" "id:3,phase:1,pass,nolog"
+SecRule MSC_PCRE_LIMITS_EXCEEDED "@eq 1" "id:4,phase:1,pass,nolog"
+`); err != nil {
+		t.Fatal(err)
+	}
+
+	tx := waf.NewTransaction()
+	defer tx.Close()
+	tx.ProcessURI("/aaab", "GET", "HTTP/1.1")
+	tx.ProcessRequestHeaders()
+	var matched []int
+	for _, mr := range tx.MatchedRules() {
+		matched = append(matched, mr.Rule().ID())
+	}
+	// the variable can't be cleared with setvar
+	if want, have := []int{2, 10, 3, 4}, matched; !slices.Equal(want, have) {
+		t.Errorf("unexpected matched rules, want %v, have %v", want, have)
+	}
+
+	if err := NewParser(corazawaf.NewWAF()).FromString(`SecRule RX_BUDGET_EXCEEDED:foo "@eq 1" "id:1,phase:1"`); err == nil {
+		t.Error("expected error selecting a key of RX_BUDGET_EXCEEDED")
+	}
+}
+
+func TestSecGeoLookupDB(t *testing.T) {
+	db, err := os.ReadFile("internal/geoip/testdata/GeoIP2-City-Test.mmdb")
+	if err != nil {
+		t.Fatal(err)
+	}
+	root := fstest.MapFS{
+		"geo/GeoIP2-City-Test.mmdb": {Data: db},
+		"geo/invalid.mmdb":          {Data: []byte("not a database")},
+	}
+
+	waf := corazawaf.NewWAF()
+	p := NewParser(waf)
+	p.SetRoot(root)
+	if err := p.FromString(`
+SecGeoLookupDb geo/GeoIP2-City-Test.mmdb
+SecRule REMOTE_ADDR "@geoLookup" "id:1,phase:1,chain,deny,status:403"
+SecRule GEO:COUNTRY_CODE "@streq GB"
+`); err != nil {
+		t.Fatal(err)
+	}
+	if waf.GeoDatabase == nil {
+		t.Fatal("expected geo database to be loaded")
+	}
+
+	for _, test := range []struct {
+		addr string
+		city string
+		want bool
+	}{
+		{addr: "81.2.69.142", city: "London", want: true},
+		{addr: "81.2.70.1", want: false},
+		{addr: "2001:db8::1", city: "Mountain View", want: false},
+	} {
+		tx := waf.NewTransaction()
+		tx.ProcessConnection(test.addr, 1234, "127.0.0.1", 80)
+		it := tx.ProcessRequestHeaders()
+		if want, have := test.want, it != nil; want != have {
+			t.Errorf("unexpected interruption for %s, want %t, have %t", test.addr, want, have)
+		}
+		city := ""
+		if v := tx.Variables().Geo().Get("CITY"); len(v) > 0 {
+			city = v[0]
+		}
+		if want, have := test.city, city; want != have {
+			t.Errorf("unexpected GEO:CITY for %s, want %q, have %q", test.addr, want, have)
+		}
+		tx.Close()
+	}
+
+	for _, file := range []string{"geo/missing.mmdb", "geo/invalid.mmdb", ""} {
+		p := NewParser(corazawaf.NewWAF())
+		p.SetRoot(root)
+		if err := p.FromString("SecGeoLookupDb " + file); err == nil {
+			t.Errorf("expected error for %q", file)
+		}
+	}
+}
+
+func TestSecGsbLookupDb(t *testing.T) {
+	root := fstest.MapFS{
+		"gsb/local.txt":   {Data: []byte("# phishing kits\nevil.example.com/login/ SOCIAL_ENGINEERING\n")},
+		"gsb/invalid.txt": {Data: []byte("ftp://evil.example.com/")},
+	}
+
+	waf := corazawaf.NewWAF()
+	p := NewParser(waf)
+	p.SetRoot(root)
+	if err := p.FromString(`
+SecRequestBodyAccess On
+SecGsbLookupDb gsb/local.txt
+SecRule ARGS:website "@gsbLookup" "id:1,phase:2,deny,status:403,capture,setvar:'tx.threat=%{TX.gsb_threat}'"
+`); err != nil {
+		t.Fatal(err)
+	}
+	if waf.URLReputation == nil {
+		t.Fatal("expected URL reputation provider to be configured")
+	}
+	for _, test := range []struct {
+		website string
+		want    bool
+	}{
+		{website: "https://www.evil.example.com/login/index.php", want: true},
+		{website: "https://www.example.com/login/", want: false},
+	} {
+		tx := waf.NewTransaction()
+		tx.ProcessURI("/signup?website="+url.QueryEscape(test.website), "GET", "HTTP/1.1")
+		tx.ProcessRequestHeaders()
+		it, err := tx.ProcessRequestBody()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want, have := test.want, it != nil; want != have {
+			t.Errorf("unexpected interruption for %s, want %t, have %t", test.website, want, have)
+		}
+		if test.want {
+			if want, have := "SOCIAL_ENGINEERING", strings.Join(tx.Variables().TX().Get("threat"), ","); want != have {
+				t.Errorf("unexpected threat, want %q, have %q", want, have)
+			}
+		}
+		tx.Close()
+	}
+
+	registered := &urlreputation.HashSet{}
+	urlreputation.Register("test-reputation", func(uri string) (plugintypes.URLReputation, error) {
+		if uri != "test-reputation://feed" {
+			return nil, fmt.Errorf("unexpected uri %q", uri)
+		}
+		return registered, nil
+	})
+	waf = corazawaf.NewWAF()
+	if err := NewParser(waf).FromString("SecGsbLookupDb test-reputation://feed"); err != nil {
+		t.Fatal(err)
+	}
+	if waf.URLReputation != registered {
+		t.Error("expected the registered provider to be configured")
+	}
+
+	for _, source := range []string{"gsb/missing.txt", "gsb/invalid.txt", "unknown://feed", ""} {
+		p := NewParser(corazawaf.NewWAF())
+		p.SetRoot(root)
+		if err := p.FromString("SecGsbLookupDb " + source); err == nil {
+			t.Errorf("expected error for %q", source)
+		}
+	}
+}
+
 func TestInvalidBooleanForDirectives(t *testing.T) {
 	waf := corazawaf.NewWAF()
 	p := NewParser(waf)
@@ -121,7 +774,7 @@ func TestSecDataset(t *testing.T) {
 		"SecDataset test `\n123\n456\n`\n"); err != nil {
 		t.Error(err)
 	}
-	ds := p.options.Datasets["test"]
+	ds := p.options.WAF.Datasets()["test"]
 	if len(ds) != 2 {
 		t.Errorf("failed to add dataset, got %d records", len(ds))
 	}
@@ -130,6 +783,135 @@ func TestSecDataset(t *testing.T) {
 	}
 }
 
+func TestSecDatasetScope(t *testing.T) {
+	matches := func(waf *corazawaf.WAF, ip string) bool {
+		tx := waf.NewTransaction()
+		defer tx.Close()
+		tx.ProcessConnection(ip, 1234, "127.0.0.1", 80)
+		return tx.ProcessRequestHeaders() != nil
+	}
+	rule := `SecRule REMOTE_ADDR "@ipMatchFromDataset blocklist" "id:1,phase:1,deny"`
+
+	tenantA := corazawaf.NewWAF()
+	if err := NewParser(tenantA).FromString("SecDataset blocklist `\n10.0.0.1\n`"); err != nil {
+		t.Fatal(err)
+	}
+	// parsers of the same WAF share its datasets
+	if err := NewParser(tenantA).FromString(rule); err != nil {
+		t.Fatal(err)
+	}
+	tenantB := corazawaf.NewWAF()
+	if err := NewParser(tenantB).FromString(rule); err == nil {
+		t.Error("expected error referencing the dataset of another WAF")
+	}
+	if err := NewParser(tenantB).FromString("SecDataset blocklist `\n10.0.0.2\n`\n" + rule); err != nil {
+		t.Fatal(err)
+	}
+	if !matches(tenantA, "10.0.0.1") || matches(tenantA, "10.0.0.2") {
+		t.Error("unexpected dataset of tenant A")
+	}
+	if !matches(tenantB, "10.0.0.2") || matches(tenantB, "10.0.0.1") {
+		t.Error("unexpected dataset of tenant B")
+	}
+
+	shared := NewDatasetRegistry()
+	shared.Set("allowlist", []string{"10.0.0.3"})
+	publisher := NewParser(corazawaf.NewWAF())
+	publisher.ShareDatasets(shared)
+	if err := publisher.FromString("SecDataset blocklist `\n10.0.0.4\n`"); err != nil {
+		t.Fatal(err)
+	}
+	if have, ok := shared.Get("blocklist"); !ok || len(have) != 1 || have[0] != "10.0.0.4" {
+		t.Errorf("expected dataset published to the registry, have %q", have)
+	}
+
+	subscriber := corazawaf.NewWAF()
+	p := NewParser(subscriber)
+	p.ShareDatasets(shared)
+	if err := p.FromString(rule + "\n" + `SecRule REMOTE_ADDR "@ipMatchFromDataset allowlist" "id:2,phase:1,deny"`); err != nil {
+		t.Fatal(err)
+	}
+	if !matches(subscriber, "10.0.0.4") || !matches(subscriber, "10.0.0.3") {
+		t.Error("expected shared datasets to be used")
+	}
+}
+
+func TestDatasetsOfParserAndWAF(t *testing.T) {
+	waf := corazawaf.NewWAF()
+	p := NewParser(waf)
+	// the datasets registered after creating the parser are visible to it
+	if err := waf.RegisterDataset("words", []string{"attack"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := p.FromString("SecDataset ips `\n10.0.0.1\n`\n" + `SecRule ARGS "@pmFromDataset words" "id:1,phase:1,deny"`); err != nil {
+		t.Fatal(err)
+	}
+	if want, have := []string{"10.0.0.1"}, waf.Datasets()["ips"]; !slices.Equal(want, have) {
+		t.Errorf("unexpected dataset, want %q, have %q", want, have)
+	}
+}
+
+func TestRegisterDataset(t *testing.T) {
+	waf := corazawaf.NewWAF()
+	if err := waf.RegisterDataset("words", []string{"attack", "exploit"}); err != nil {
+		t.Fatal(err)
+	}
+	addr := netip.MustParseAddr("10.0.0.1")
+	if err := waf.RegisterIPDataset("ips", []netip.Prefix{
+		netip.PrefixFrom(addr, addr.BitLen()),
+		netip.MustParsePrefix("192.168.1.7/24"),
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if want, have := []string{"10.0.0.1/32", "192.168.1.0/24"}, waf.Datasets()["ips"]; !slices.Equal(want, have) {
+		t.Errorf("unexpected dataset, want %q, have %q", want, have)
+	}
+	if err := waf.RegisterIPDataset("invalid", []netip.Prefix{{}}); err == nil {
+		t.Error("expected error registering an invalid prefix")
+	}
+	if err := waf.RegisterDataset("", nil); err == nil {
+		t.Error("expected error registering a dataset without name")
+	}
+
+	if err := NewParser(waf).FromString(`
+SecRule ARGS "@pmFromDataset words" "id:1,phase:1,deny"
+SecRule REMOTE_ADDR "@ipMatchFromDataset ips" "id:2,phase:1,deny"
+`); err != nil {
+		t.Fatal(err)
+	}
+	for _, tc := range []struct {
+		ip, arg string
+		want    bool
+	}{
+		{"127.0.0.1", "hello", false},
+		{"127.0.0.1", "an exploit", true},
+		{"10.0.0.1", "hello", true},
+		{"192.168.1.200", "hello", true},
+	} {
+		tx := waf.NewTransaction()
+		tx.ProcessConnection(tc.ip, 1234, "127.0.0.1", 80)
+		tx.AddGetRequestArgument("q", tc.arg)
+		if want, have := tc.want, tx.ProcessRequestHeaders() != nil; want != have {
+			t.Errorf("unexpected interruption for %s %q, want %t, have %t", tc.ip, tc.arg, want, have)
+		}
+		tx.Close()
+	}
+}
+
+func TestSecComponentSignature(t *testing.T) {
+	waf := corazawaf.NewWAF()
+	p := NewParser(waf)
+	if err := p.FromString(`SecComponentSignature "OWASP_CRS/4.0.0"
+SecComponentSignature vendor-rules/1.2
+SecComponentSignature "OWASP_CRS/4.0.0"`); err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"OWASP_CRS/4.0.0", "vendor-rules/1.2"}
+	if have := waf.ComponentSignatures(); !slices.Equal(want, have) {
+		t.Errorf("unexpected signatures, want %v, have %v", want, have)
+	}
+}
+
 var expectErrorOnDirective func(*corazawaf.WAF) bool = nil
 var expectNoErrorOnDirective func(*corazawaf.WAF) bool = func(*corazawaf.WAF) bool { return true }
 
@@ -141,7 +923,10 @@ func TestDirectives(t *testing.T) {
 	directiveCases := map[string][]directiveCase{
 		"SecComponentSignature": {
 			{"", expectErrorOnDirective},
-			{"name", func(w *corazawaf.WAF) bool { return len(w.ComponentNames) == 1 }},
+			{"name", func(w *corazawaf.WAF) bool { return len(w.Producer.Rulesets) == 1 }},
+			{"OWASP_CRS/4.0.0", func(w *corazawaf.WAF) bool {
+				return w.Producer.Rulesets[0] == corazawaf.Ruleset{Name: "OWASP_CRS", Version: "4.0.0"}
+			}},
 		},
 		"SecMarker": {
 			{"", expectErrorOnDirective},
@@ -167,6 +952,139 @@ func TestDirectives(t *testing.T) {
 			{"", expectErrorOnDirective},
 			{"1000", func(w *corazawaf.WAF) bool { return w.UploadFileLimit == 1000 }},
 		},
+		"SecDependencyFailureMode": {
+			{"", expectErrorOnDirective},
+			{"rbl", expectErrorOnDirective},
+			{"rbl Maybe", expectErrorOnDirective},
+			{"rbl FailClosed", func(w *corazawaf.WAF) bool {
+				return w.DependencyFailureMode("rbl") == corazawaf.DependencyFailClosed &&
+					w.DependencyFailureMode("inspectFile") == corazawaf.DependencyFailOpen
+			}},
+			{"* failclosed", func(w *corazawaf.WAF) bool { return w.DependencyFailureMode("rbl") == corazawaf.DependencyFailClosed }},
+		},
+		"SecLabel": {
+			{"", expectErrorOnDirective},
+			{"cluster", expectErrorOnDirective},
+			{"cluster eu-west-1", func(w *corazawaf.WAF) bool { return w.Labels()["cluster"] == "eu-west-1" }},
+			{`listener "public https"`, func(w *corazawaf.WAF) bool { return w.Labels()["listener"] == "public https" }},
+		},
+		"SecTransactionIDDuplicateDetection": {
+			{"", expectErrorOnDirective},
+			{"Maybe", expectErrorOnDirective},
+			{"On", func(w *corazawaf.WAF) bool { return w.DetectDuplicateTransactionIDs }},
+		},
+		"SecArgumentsArrayMode": {
+			{"", expectErrorOnDirective},
+			{"Nested", expectErrorOnDirective},
+			{"Flatten", func(w *corazawaf.WAF) bool { return w.FlattenArrayArguments }},
+			{"raw", func(w *corazawaf.WAF) bool { return !w.FlattenArrayArguments }},
+		},
+		"SecTransactionWatchdog": {
+			{"", expectErrorOnDirective},
+			{"-1", expectErrorOnDirective},
+			{"60 Kill", expectErrorOnDirective},
+			{"60 Log Close", expectErrorOnDirective},
+			{"300 Close", expectErrorOnDirective},
+			{"60", func(w *corazawaf.WAF) bool { return w.TransactionWatchdogTimeout == time.Minute }},
+			{"300 log", func(w *corazawaf.WAF) bool { return w.TransactionWatchdogTimeout == 5*time.Minute }},
+		},
+		"SecPanicDump": {
+			{"", expectErrorOnDirective},
+			{"/dev/stderr Ignore", expectErrorOnDirective},
+			{"/dev/stderr Panic Recover", expectErrorOnDirective},
+			{"/dev/stderr", func(w *corazawaf.WAF) bool {
+				return w.PanicDump != nil && !w.PanicDump.Recover
+			}},
+			{"/dev/stderr recover", func(w *corazawaf.WAF) bool {
+				return w.PanicDump != nil && w.PanicDump.Recover
+			}},
+		},
+		"SecTransactionMemoryLimit": {
+			{"", expectErrorOnDirective},
+			{"-1", expectErrorOnDirective},
+			{"1048576", func(w *corazawaf.WAF) bool { return w.TransactionMemoryLimit == 1048576 }},
+		},
+		"SecTransactionMemoryLimitAction": {
+			{"", expectErrorOnDirective},
+			{"Drop", expectErrorOnDirective},
+			{"Log", func(w *corazawaf.WAF) bool { return !w.TransactionMemoryLimitReject }},
+			{"reject", func(w *corazawaf.WAF) bool { return w.TransactionMemoryLimitReject }},
+		},
+		"SecCollectionTimeout": {
+			{"", expectErrorOnDirective},
+			{"0", expectErrorOnDirective},
+			{"600", func(w *corazawaf.WAF) bool { return w.CollectionTimeout == 10*time.Minute }},
+		},
+		"SecRulePerfTime": {
+			{"", expectErrorOnDirective},
+			{"-1", expectErrorOnDirective},
+			{"1000", func(w *corazawaf.WAF) bool { return w.RulePerfTime == time.Millisecond }},
+		},
+		"SecRxBudget": {
+			{"", expectErrorOnDirective},
+			{"input=-1", expectErrorOnDirective},
+			{"input=1024 time=2ms", func(w *corazawaf.WAF) bool {
+				return w.RxBudget == corazawaf.RxBudget{InputLimit: 1024, TimeLimit: 2 * time.Millisecond}
+			}},
+			{"off", func(w *corazawaf.WAF) bool { return w.RxBudget == corazawaf.RxBudget{} }},
+		},
+		"SecSecurityHeadersEngine": {
+			{"", expectErrorOnDirective},
+			{"Maybe", expectErrorOnDirective},
+			{"On", func(w *corazawaf.WAF) bool { return w.SecurityHeadersEngine == types.RuleEngineOn }},
+			{"DetectionOnly", func(w *corazawaf.WAF) bool { return w.SecurityHeadersEngine == types.RuleEngineDetectionOnly }},
+		},
+		"SecSecurityHeaderDefault": {
+			{"", expectErrorOnDirective},
+			{"X-Frame-Options", expectErrorOnDirective},
+			{`Server "none"`, expectErrorOnDirective},
+			{`Content-Security-Policy "default-src 'self'"`, func(w *corazawaf.WAF) bool { return true }},
+		},
+		"SecConnEngine": {
+			{"", expectErrorOnDirective},
+			{"Maybe", expectErrorOnDirective},
+			{"On", func(w *corazawaf.WAF) bool { return w.ConnEngine == types.RuleEngineOn }},
+			{"DetectionOnly", func(w *corazawaf.WAF) bool { return w.ConnEngine == types.RuleEngineDetectionOnly }},
+		},
+		"SecConnReadStateLimit": {
+			{"", expectErrorOnDirective},
+			{"-1", expectErrorOnDirective},
+			{`50 "@rx ^10\\."`, expectErrorOnDirective},
+			{"50", func(w *corazawaf.WAF) bool {
+				return w.ConnReadStateLimit.Limit == 50 && w.ConnReadStateLimit.Match == nil
+			}},
+			{`50 "!@ipMatch 127.0.0.1"`, func(w *corazawaf.WAF) bool {
+				tx := w.NewTransaction()
+				defer tx.Close()
+				return w.ConnReadStateLimit.Limit == 50 &&
+					!w.ConnReadStateLimit.Match(tx, "127.0.0.1") && w.ConnReadStateLimit.Match(tx, "10.0.0.1")
+			}},
+		},
+		"SecConnWriteStateLimit": {
+			{"", expectErrorOnDirective},
+			{`10 "@ipMatch 10.0.0.0/8"`, func(w *corazawaf.WAF) bool {
+				tx := w.NewTransaction()
+				defer tx.Close()
+				return w.ConnWriteStateLimit.Limit == 10 &&
+					w.ConnWriteStateLimit.Match(tx, "10.0.0.1") && !w.ConnWriteStateLimit.Match(tx, "127.0.0.1")
+			}},
+		},
+		"SecXmlExternalEntity": {
+			{"", expectErrorOnDirective},
+			// no entity resolver is registered
+			{"On", expectErrorOnDirective},
+			{"Off", func(w *corazawaf.WAF) bool { return !w.XMLExternalEntity }},
+		},
+		"SecRuleMatchLimit": {
+			{"", expectErrorOnDirective},
+			{"-1", expectErrorOnDirective},
+			{"100", func(w *corazawaf.WAF) bool { return w.RuleMatchLimit == 100 }},
+		},
+		"SecCaptureLimit": {
+			{"", expectErrorOnDirective},
+			{"0", expectErrorOnDirective},
+			{"20", func(w *corazawaf.WAF) bool { return w.CaptureLimit == 20 }},
+		},
 		"SecSensorId": {
 			{"", expectErrorOnDirective},
 			{"test", func(w *corazawaf.WAF) bool { return w.SensorID == "test" }},
@@ -184,9 +1102,22 @@ func TestDirectives(t *testing.T) {
 		"SecRuleRemoveByTag": {
 			{"", expectErrorOnDirective},
 			{"attack-sqli", expectNoErrorOnDirective},
+			{"(attack", expectErrorOnDirective},
+		},
+		"SecUnicodeMapFile": {
+			{"", expectErrorOnDirective},
+			{"unicode.mapping", expectNoErrorOnDirective},
+			{"unicode.mapping abc", expectErrorOnDirective},
+		},
+		"SecUnicodeCodePage": {
+			{"", expectErrorOnDirective},
+			{"20127", expectNoErrorOnDirective},
+			{"abc", expectErrorOnDirective},
 		},
 		"SecRuleRemoveByMsg": {
 			{"", expectErrorOnDirective},
+			{"^SQL Injection", expectNoErrorOnDirective},
+			{"(SQL", expectErrorOnDirective},
 		},
 		"SecRuleRemoveById": {
 			{"", expectErrorOnDirective},
@@ -287,6 +1218,78 @@ func TestDirectives(t *testing.T) {
 			{"Reject", func(w *corazawaf.WAF) bool { return w.ResponseBodyLimitAction == types.BodyLimitActionReject }},
 			{"ProcessPartial", func(w *corazawaf.WAF) bool { return w.ResponseBodyLimitAction == types.BodyLimitActionProcessPartial }},
 		},
+		"SecHashEngine": {
+			{"", expectErrorOnDirective},
+			{"What?", expectErrorOnDirective},
+			{"On", func(w *corazawaf.WAF) bool { return w.HashEngine }},
+			{"Off", func(w *corazawaf.WAF) bool { return !w.HashEngine }},
+		},
+		"SecHashKey": {
+			{"", expectErrorOnDirective},
+			{"secret", func(w *corazawaf.WAF) bool {
+				return string(w.HashKey) == "secret" && w.HashKeyMode == corazawaf.HashKeyOnly
+			}},
+			{"rand RemoteIP", func(w *corazawaf.WAF) bool {
+				return len(w.HashKey) == 32 && w.HashKeyMode == corazawaf.HashKeyRemoteIP
+			}},
+			{"rand SessionID", expectErrorOnDirective},
+		},
+		"SecHashParam": {
+			{"", expectErrorOnDirective},
+			{"sig", func(w *corazawaf.WAF) bool { return w.HashParam == "sig" }},
+		},
+		"SecHashMethodRx": {
+			{"", expectErrorOnDirective},
+			{"HashHref", expectErrorOnDirective},
+			{`HashLink "^/account"`, expectErrorOnDirective},
+			{`HashHref "(^/account"`, expectErrorOnDirective},
+			{`HashHref "^/account"`, func(w *corazawaf.WAF) bool {
+				return len(w.HashMethods) == 1 && w.HashMethods[0].Type == corazawaf.HashHref &&
+					w.HashMethods[0].Match("/account/edit") && !w.HashMethods[0].Match("/about")
+			}},
+		},
+		"SecHashMethodPm": {
+			{"", expectErrorOnDirective},
+			{`HashFormAction ""`, expectErrorOnDirective},
+			{`HashFormAction "login Logout"`, func(w *corazawaf.WAF) bool {
+				return len(w.HashMethods) == 1 && w.HashMethods[0].Type == corazawaf.HashFormAction &&
+					w.HashMethods[0].Match("/logout") && !w.HashMethods[0].Match("/about")
+			}},
+		},
+		"SecRequestBodyUnexpectedAction": {
+			{"", expectErrorOnDirective},
+			{"What?", expectErrorOnDirective},
+			{"Ignore", func(w *corazawaf.WAF) bool {
+				return w.UnexpectedRequestBodyAction == corazawaf.UnexpectedRequestBodyIgnore
+			}},
+			{"inspect", func(w *corazawaf.WAF) bool {
+				return w.UnexpectedRequestBodyAction == corazawaf.UnexpectedRequestBodyInspect
+			}},
+		},
+		"SecRuleMessageCatalog": {
+			{"", expectErrorOnDirective},
+			{"missing.txt", expectErrorOnDirective},
+		},
+		"SecStreamInBodyInspection": {
+			{"", expectErrorOnDirective},
+			{"On", func(w *corazawaf.WAF) bool { return w.StreamInBodyInspection }},
+			{"Off", func(w *corazawaf.WAF) bool { return !w.StreamInBodyInspection }},
+		},
+		"SecStreamOutBodyInspection": {
+			{"", expectErrorOnDirective},
+			{"On", func(w *corazawaf.WAF) bool { return w.StreamOutBodyInspection }},
+			{"Off", func(w *corazawaf.WAF) bool { return !w.StreamOutBodyInspection }},
+		},
+		"SecRequestBodyLimitMax": {
+			{"", expectErrorOnDirective},
+			{"abc", expectErrorOnDirective},
+			{"104857600", func(w *corazawaf.WAF) bool { return w.RequestBodyLimitMax == 104857600 }},
+		},
+		"SecResponseBodyLimitMax": {
+			{"", expectErrorOnDirective},
+			{"abc", expectErrorOnDirective},
+			{"10485760", func(w *corazawaf.WAF) bool { return w.ResponseBodyLimitMax == 10485760 }},
+		},
 		"SecResponseBodyAccess": {
 			{"", expectErrorOnDirective},
 			{"What?", expectErrorOnDirective},
@@ -23,9 +23,22 @@ var (
 	_ directive = directiveSecResponseBodyMimeType
 	_ directive = directiveSecResponseBodyLimitAction
 	_ directive = directiveSecResponseBodyLimit
+	_ directive = directiveSecRequestBodyLimitMax
+	_ directive = directiveSecResponseBodyLimitMax
 	_ directive = directiveSecRequestBodyLimitAction
+	_ directive = directiveSecRequestBodyUnexpectedAction
 	_ directive = directiveSecRequestBodyInMemoryLimit
 	_ directive = directiveSecRemoteRulesFailAction
+	_ directive = directiveSecIncludeCacheDir
+	_ directive = directiveSecUnicodeMapFile
+	_ directive = directiveSecUnicodeCodePage
+	_ directive = directiveSecGeoLookupDB
+	_ directive = directiveSecStreamInBodyInspection
+	_ directive = directiveSecStreamOutBodyInspection
+	_ directive = directiveSecRuleMessageCatalog
+	_ directive = directiveSecDependencyFailureMode
+	_ directive = directiveSecTransactionWatchdog
+	_ directive = directiveSecPanicDump
 	_ directive = directiveSecRemoteRules
 	_ directive = directiveSecConnWriteStateLimit
 	_ directive = directiveSecSensorID
@@ -41,8 +54,13 @@ var (
 	_ directive = directiveSecHashEngine
 	_ directive = directiveSecDefaultAction
 	_ directive = directiveSecConnEngine
+	_ directive = directiveSecSecurityHeadersEngine
+	_ directive = directiveSecSecurityHeaderDefault
 	_ directive = directiveSecCollectionTimeout
 	_ directive = directiveSecAuditLog
+	_ directive = directiveSecRulePerfTime
+	_ directive = directiveSecRxBudget
+	_ directive = directiveSecGuardianLog
 	_ directive = directiveSecAuditLogType
 	_ directive = directiveSecAuditLogFormat
 	_ directive = directiveSecAuditLogDir
@@ -65,75 +83,110 @@ var (
 	_ directive = directiveSecIgnoreRuleCompilationErrors
 	_ directive = directiveSecDataset
 	_ directive = directiveSecArgumentsLimit
+	_ directive = directiveSecTransactionMemoryLimit
+	_ directive = directiveSecTransactionMemoryLimitAction
+	_ directive = directiveSecLabel
+	_ directive = directiveSecTransactionIDDuplicateDetection
+	_ directive = directiveSecArgumentsArrayMode
+	_ directive = directiveSecXMLExternalEntity
+	_ directive = directiveSecRuleMatchLimit
+	_ directive = directiveSecCaptureLimit
+	_ directive = directiveSecRxDefaultFlags
 )
 
 var directivesMap = map[string]directive{
-	"seccomponentsignature":          directiveSecComponentSignature,
-	"secmarker":                      directiveSecMarker,
-	"secaction":                      directiveSecAction,
-	"secrule":                        directiveSecRule,
-	"secresponsebodyaccess":          directiveSecResponseBodyAccess,
-	"secrequestbodylimit":            directiveSecRequestBodyLimit,
-	"secrequestbodyaccess":           directiveSecRequestBodyAccess,
-	"secruleengine":                  directiveSecRuleEngine,
-	"secwebappid":                    directiveSecWebAppID,
-	"secserversignature":             directiveSecServerSignature,
-	"secruleremovebytag":             directiveSecRuleRemoveByTag,
-	"secruleremovebymsg":             directiveSecRuleRemoveByMsg,
-	"secruleremovebyid":              directiveSecRuleRemoveByID,
-	"secresponsebodymimetypesclear":  directiveSecResponseBodyMimeTypesClear,
-	"secresponsebodymimetype":        directiveSecResponseBodyMimeType,
-	"secresponsebodylimitaction":     directiveSecResponseBodyLimitAction,
-	"secresponsebodylimit":           directiveSecResponseBodyLimit,
-	"secrequestbodylimitaction":      directiveSecRequestBodyLimitAction,
-	"secrequestbodyinmemorylimit":    directiveSecRequestBodyInMemoryLimit,
-	"secremoterulesfailaction":       directiveSecRemoteRulesFailAction,
-	"secremoterules":                 directiveSecRemoteRules,
-	"secconnwritestatelimit":         directiveSecConnWriteStateLimit,
-	"secsensorid":                    directiveSecSensorID,
-	"secconnreadstatelimit":          directiveSecConnReadStateLimit,
-	"secpcrematchlimitrecursion":     directiveSecPcreMatchLimitRecursion,
-	"secpcrematchlimit":              directiveSecPcreMatchLimit,
-	"sechttpblkey":                   directiveSecHTTPBlKey,
-	"secgsblookupdb":                 directiveSecGsbLookupDb,
-	"sechashmethodpm":                directiveSecHashMethodPm,
-	"sechashmethodrx":                directiveSecHashMethodRx,
-	"sechashparam":                   directiveSecHashParam,
-	"sechashkey":                     directiveSecHashKey,
-	"sechashengine":                  directiveSecHashEngine,
-	"secdefaultaction":               directiveSecDefaultAction,
-	"secconnengine":                  directiveSecConnEngine,
-	"seccollectiontimeout":           directiveSecCollectionTimeout,
-	"secauditlog":                    directiveSecAuditLog,
-	"secauditlogtype":                directiveSecAuditLogType,
-	"secauditlogformat":              directiveSecAuditLogFormat,
-	"secauditlogdir":                 directiveSecAuditLogDir,
-	"secauditlogdirmode":             directiveSecAuditLogDirMode,
-	"secauditlogfilemode":            directiveSecAuditLogFileMode,
-	"secauditlogrelevantstatus":      directiveSecAuditLogRelevantStatus,
-	"secauditlogparts":               directiveSecAuditLogParts,
-	"secauditengine":                 directiveSecAuditEngine,
-	"secdatadir":                     directiveSecDataDir,
-	"secuploadkeepfiles":             directiveSecUploadKeepFiles,
-	"secuploadfilemode":              directiveSecUploadFileMode,
-	"secuploadfilelimit":             directiveSecUploadFileLimit,
-	"secuploaddir":                   directiveSecUploadDir,
-	"secrequestbodynofileslimit":     directiveSecRequestBodyNoFilesLimit,
-	"secdebuglog":                    directiveSecDebugLog,
-	"secdebugloglevel":               directiveSecDebugLogLevel,
-	"secruleupdatetargetbyid":        directiveSecRuleUpdateTargetByID,
-	"secruleupdateactionbyid":        directiveSecRuleUpdateActionByID,
-	"secruleupdatetargetbytag":       directiveSecRuleUpdateTargetByTag,
-	"secignorerulecompilationerrors": directiveSecIgnoreRuleCompilationErrors,
-	"secdataset":                     directiveSecDataset,
-	"secargumentslimit":              directiveSecArgumentsLimit,
+	"seccomponentsignature":              directiveSecComponentSignature,
+	"secmarker":                          directiveSecMarker,
+	"secaction":                          directiveSecAction,
+	"secrule":                            directiveSecRule,
+	"secresponsebodyaccess":              directiveSecResponseBodyAccess,
+	"secrequestbodylimit":                directiveSecRequestBodyLimit,
+	"secrequestbodyaccess":               directiveSecRequestBodyAccess,
+	"secruleengine":                      directiveSecRuleEngine,
+	"secwebappid":                        directiveSecWebAppID,
+	"secserversignature":                 directiveSecServerSignature,
+	"secruleremovebytag":                 directiveSecRuleRemoveByTag,
+	"secruleremovebymsg":                 directiveSecRuleRemoveByMsg,
+	"secruleremovebyid":                  directiveSecRuleRemoveByID,
+	"secresponsebodymimetypesclear":      directiveSecResponseBodyMimeTypesClear,
+	"secresponsebodymimetype":            directiveSecResponseBodyMimeType,
+	"secresponsebodylimitaction":         directiveSecResponseBodyLimitAction,
+	"secresponsebodylimit":               directiveSecResponseBodyLimit,
+	"secrequestbodylimitmax":             directiveSecRequestBodyLimitMax,
+	"secresponsebodylimitmax":            directiveSecResponseBodyLimitMax,
+	"secrequestbodylimitaction":          directiveSecRequestBodyLimitAction,
+	"secrequestbodyunexpectedaction":     directiveSecRequestBodyUnexpectedAction,
+	"secrequestbodyinmemorylimit":        directiveSecRequestBodyInMemoryLimit,
+	"secremoterulesfailaction":           directiveSecRemoteRulesFailAction,
+	"secincludecachedir":                 directiveSecIncludeCacheDir,
+	"secunicodemapfile":                  directiveSecUnicodeMapFile,
+	"secunicodecodepage":                 directiveSecUnicodeCodePage,
+	"secgeolookupdb":                     directiveSecGeoLookupDB,
+	"secstreaminbodyinspection":          directiveSecStreamInBodyInspection,
+	"secstreamoutbodyinspection":         directiveSecStreamOutBodyInspection,
+	"secrulemessagecatalog":              directiveSecRuleMessageCatalog,
+	"secdependencyfailuremode":           directiveSecDependencyFailureMode,
+	"sectransactionwatchdog":             directiveSecTransactionWatchdog,
+	"secpanicdump":                       directiveSecPanicDump,
+	"secremoterules":                     directiveSecRemoteRules,
+	"secconnwritestatelimit":             directiveSecConnWriteStateLimit,
+	"secsensorid":                        directiveSecSensorID,
+	"secconnreadstatelimit":              directiveSecConnReadStateLimit,
+	"secpcrematchlimitrecursion":         directiveSecPcreMatchLimitRecursion,
+	"secpcrematchlimit":                  directiveSecPcreMatchLimit,
+	"sechttpblkey":                       directiveSecHTTPBlKey,
+	"secgsblookupdb":                     directiveSecGsbLookupDb,
+	"sechashmethodpm":                    directiveSecHashMethodPm,
+	"sechashmethodrx":                    directiveSecHashMethodRx,
+	"sechashparam":                       directiveSecHashParam,
+	"sechashkey":                         directiveSecHashKey,
+	"sechashengine":                      directiveSecHashEngine,
+	"secdefaultaction":                   directiveSecDefaultAction,
+	"secconnengine":                      directiveSecConnEngine,
+	"secsecurityheadersengine":           directiveSecSecurityHeadersEngine,
+	"secsecurityheaderdefault":           directiveSecSecurityHeaderDefault,
+	"seccollectiontimeout":               directiveSecCollectionTimeout,
+	"secauditlog":                        directiveSecAuditLog,
+	"secruleperftime":                    directiveSecRulePerfTime,
+	"secrxbudget":                        directiveSecRxBudget,
+	"secguardianlog":                     directiveSecGuardianLog,
+	"secauditlogtype":                    directiveSecAuditLogType,
+	"secauditlogformat":                  directiveSecAuditLogFormat,
+	"secauditlogdir":                     directiveSecAuditLogDir,
+	"secauditlogdirmode":                 directiveSecAuditLogDirMode,
+	"secauditlogfilemode":                directiveSecAuditLogFileMode,
+	"secauditlogrelevantstatus":          directiveSecAuditLogRelevantStatus,
+	"secauditlogparts":                   directiveSecAuditLogParts,
+	"secauditengine":                     directiveSecAuditEngine,
+	"secdatadir":                         directiveSecDataDir,
+	"secuploadkeepfiles":                 directiveSecUploadKeepFiles,
+	"secuploadfilemode":                  directiveSecUploadFileMode,
+	"secuploadfilelimit":                 directiveSecUploadFileLimit,
+	"secuploaddir":                       directiveSecUploadDir,
+	"secrequestbodynofileslimit":         directiveSecRequestBodyNoFilesLimit,
+	"secdebuglog":                        directiveSecDebugLog,
+	"secdebugloglevel":                   directiveSecDebugLogLevel,
+	"secruleupdatetargetbyid":            directiveSecRuleUpdateTargetByID,
+	"secruleupdateactionbyid":            directiveSecRuleUpdateActionByID,
+	"secruleupdatetargetbytag":           directiveSecRuleUpdateTargetByTag,
+	"secignorerulecompilationerrors":     directiveSecIgnoreRuleCompilationErrors,
+	"secdataset":                         directiveSecDataset,
+	"secargumentslimit":                  directiveSecArgumentsLimit,
+	"sectransactionmemorylimit":          directiveSecTransactionMemoryLimit,
+	"sectransactionmemorylimitaction":    directiveSecTransactionMemoryLimitAction,
+	"seclabel":                           directiveSecLabel,
+	"sectransactionidduplicatedetection": directiveSecTransactionIDDuplicateDetection,
+	"secargumentsarraymode":              directiveSecArgumentsArrayMode,
+	"secxmlexternalentity":               directiveSecXMLExternalEntity,
+	"secrulematchlimit":                  directiveSecRuleMatchLimit,
+	"seccapturelimit":                    directiveSecCaptureLimit,
+	"secrxdefaultflags":                  directiveSecRxDefaultFlags,
 
 	// Unsupported directives
 	"secargumentseparator":     directiveUnsupported,
 	"seccookieformat":          directiveUnsupported,
 	"secruleupdatetargetbymsg": directiveUnsupported,
 	"secrulescript":            directiveUnsupported,
-	"secruleperftime":          directiveUnsupported,
 	"secunicodemap":            directiveUnsupported,
 	"sectmpdir":                directiveUnsupported,
 }
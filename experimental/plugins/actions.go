@@ -16,4 +16,5 @@ type ActionFactory = func() plugintypes.Action
 // If you register an action with an existing name, it will be overwritten.
 func RegisterAction(name string, a ActionFactory) {
 	actions.Register(name, a)
+	track(PluginTypeAction, name)
 }
@@ -11,9 +11,11 @@ import (
 // RegisterAuditLogWriter registers a new audit log writer.
 func RegisterAuditLogWriter(name string, writerFactory func() plugintypes.AuditLogWriter) {
 	auditlog.RegisterWriter(name, writerFactory)
+	track(PluginTypeAuditLogWriter, name)
 }
 
 // RegisterAuditLogFormatter registers a new audit log formatter.
 func RegisterAuditLogFormatter(name string, format plugintypes.AuditLogFormatter) {
 	auditlog.RegisterFormatter(name, format)
+	track(PluginTypeAuditLogFormatter, name)
 }
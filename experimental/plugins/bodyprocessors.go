@@ -13,4 +13,12 @@ import (
 // it will be overwritten
 func RegisterBodyProcessor(name string, fn func() plugintypes.BodyProcessor) {
 	bodyprocessors.RegisterBodyProcessor(name, fn)
+	track(PluginTypeBodyProcessor, name)
+}
+
+// RegisterXMLEntityResolver registers the resolver of the external entities
+// of XML bodies, it is only used when SecXmlExternalEntity is On. If a
+// resolver is already registered, it will be overwritten
+func RegisterXMLEntityResolver(resolver plugintypes.XMLEntityResolver) {
+	bodyprocessors.RegisterXMLEntityResolver(resolver)
 }
@@ -0,0 +1,31 @@
+// Copyright 2024 Juan Pablo Tosso and the OWASP Coraza contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package plugins
+
+import (
+	"github.com/ad3n/seclang/internal/corazawaf"
+)
+
+// CapabilityReport describes what a WAF runs: the engine, the rule
+// components declared with SecComponentSignature and the registered plugins.
+// It answers the compliance audits asking which components were active.
+type CapabilityReport struct {
+	EngineName    string `json:"engine_name"`
+	EngineVersion string `json:"engine_version"`
+	// Components are the signatures of the rulesets run by the WAF, e.g.
+	// "OWASP_CRS/4.0.0", in the order they were declared
+	Components []string `json:"components,omitempty"`
+	Plugins    []Plugin `json:"plugins"`
+}
+
+// Capabilities returns the capability report of the WAF, it is meant to be
+// called once the rules are parsed
+func Capabilities(waf *corazawaf.WAF) CapabilityReport {
+	return CapabilityReport{
+		EngineName:    corazawaf.EngineName(),
+		EngineVersion: corazawaf.EngineVersion(),
+		Components:    waf.ComponentSignatures(),
+		Plugins:       List(),
+	}
+}
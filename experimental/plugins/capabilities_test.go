@@ -0,0 +1,36 @@
+// Copyright 2024 Juan Pablo Tosso and the OWASP Coraza contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package plugins_test
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/ad3n/seclang"
+	"github.com/ad3n/seclang/experimental/plugins"
+	"github.com/ad3n/seclang/internal/corazawaf"
+)
+
+func TestCapabilities(t *testing.T) {
+	waf := corazawaf.NewWAF()
+	p := seclang.NewParser(waf)
+	if err := p.FromString(`SecComponentSignature "OWASP_CRS/4.0.0"
+SecComponentSignature "vendor-rules/1.2"`); err != nil {
+		t.Fatal(err)
+	}
+
+	report := plugins.Capabilities(waf)
+	if want, have := "seclang", report.EngineName; want != have {
+		t.Errorf("unexpected engine name, want %q, have %q", want, have)
+	}
+	if report.EngineVersion == "" {
+		t.Error("expected an engine version")
+	}
+	if want, have := []string{"OWASP_CRS/4.0.0", "vendor-rules/1.2"}, report.Components; !slices.Equal(want, have) {
+		t.Errorf("unexpected components, want %q, have %q", want, have)
+	}
+	if !slices.Contains(report.Plugins, plugins.Plugin{Name: "rx", Type: plugins.PluginTypeOperator, BuiltIn: true}) {
+		t.Error("expected the built-in operators to be reported")
+	}
+}
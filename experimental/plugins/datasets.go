@@ -0,0 +1,32 @@
+// Copyright 2024 Juan Pablo Tosso and the OWASP Coraza contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package plugins
+
+import (
+	"github.com/ad3n/seclang/internal/corazawaf"
+)
+
+// UpdateDataset atomically replaces the values of the dataset matched by the
+// @pmFromDataset and @ipMatchFromDataset rules of the WAF, e.g. to refresh a
+// feed without parsing the rules again. The other WAFs defining a dataset
+// with the same name are not updated. The rules compiled afterwards for the
+// WAF, including by a transactional reload, use the new values too. An error
+// is returned, and the previous values kept, if the values are invalid for
+// one of the operators.
+//
+// Example:
+// ```go
+//
+//	go func() {
+//		for range time.Tick(5 * time.Minute) {
+//			if err := plugins.UpdateDataset(waf, "blocked_ips", fetchBlockedIPs()); err != nil {
+//				log.Printf("cannot update the blocked addresses: %v", err)
+//			}
+//		}
+//	}()
+//
+// ```
+func UpdateDataset(waf *corazawaf.WAF, name string, values []string) error {
+	return waf.DatasetFeeds().Update(name, values)
+}
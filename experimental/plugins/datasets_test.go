@@ -0,0 +1,97 @@
+// Copyright 2024 Juan Pablo Tosso and the OWASP Coraza contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package plugins_test
+
+import (
+	"testing"
+
+	"github.com/ad3n/seclang"
+	"github.com/ad3n/seclang/experimental/plugins"
+	"github.com/ad3n/seclang/internal/corazawaf"
+)
+
+func TestUpdateDataset(t *testing.T) {
+	newWAF := func() *corazawaf.WAF {
+		t.Helper()
+		waf := corazawaf.NewWAF()
+		if err := seclang.NewParser(waf).FromString(`
+SecDataset feed_words ` + "`" + `
+attack
+` + "`" + `
+SecDataset feed_ips ` + "`" + `
+10.0.0.1
+` + "`" + `
+SecRule ARGS "@pmFromDataset feed_words" "id:1,phase:1,deny"
+SecRule REMOTE_ADDR "@ipMatchFromDataset feed_ips" "id:2,phase:1,deny"
+`); err != nil {
+			t.Fatal(err)
+		}
+		return waf
+	}
+	interrupted := func(waf *corazawaf.WAF, ip, arg string) bool {
+		tx := waf.NewTransaction()
+		defer tx.Close()
+		tx.ProcessConnection(ip, 1234, "127.0.0.1", 80)
+		tx.AddGetRequestArgument("q", arg)
+		return tx.ProcessRequestHeaders() != nil
+	}
+
+	waf := newWAF()
+	tests := []struct {
+		ip, arg string
+		before  bool
+		after   bool
+	}{
+		{"127.0.0.1", "an attack", true, false},
+		{"127.0.0.1", "an exploit", false, true},
+		{"10.0.0.1", "hello", true, false},
+		{"192.168.1.7", "hello", false, true},
+	}
+	for _, tc := range tests {
+		if want, have := tc.before, interrupted(waf, tc.ip, tc.arg); want != have {
+			t.Errorf("unexpected interruption before the update for %s %q, want %t, have %t", tc.ip, tc.arg, want, have)
+		}
+	}
+
+	if err := plugins.UpdateDataset(waf, "feed_words", []string{"exploit"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := plugins.UpdateDataset(waf, "feed_ips", []string{"192.168.1.0/24"}); err != nil {
+		t.Fatal(err)
+	}
+	for _, tc := range tests {
+		if want, have := tc.after, interrupted(waf, tc.ip, tc.arg); want != have {
+			t.Errorf("unexpected interruption after the update for %s %q, want %t, have %t", tc.ip, tc.arg, want, have)
+		}
+	}
+	// the datasets of another WAF are not updated
+	other := newWAF()
+	for _, tc := range tests {
+		if want, have := tc.before, interrupted(other, tc.ip, tc.arg); want != have {
+			t.Errorf("unexpected interruption by another WAF for %s %q, want %t, have %t", tc.ip, tc.arg, want, have)
+		}
+	}
+
+	// the rules compiled by a reload of the WAF match the new values too
+	p := seclang.NewParser(waf)
+	reload, err := p.Begin()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := reload.FromString(`
+SecRuleRemoveById 1 2
+SecRule ARGS "@pmFromDataset feed_words" "id:3,phase:1,deny"
+SecRule REMOTE_ADDR "@ipMatchFromDataset feed_ips" "id:4,phase:1,deny"
+`); err != nil {
+		t.Fatal(err)
+	}
+	if err := reload.Commit(); err != nil {
+		t.Fatal(err)
+	}
+	for _, tc := range tests {
+		if want, have := tc.after, interrupted(waf, tc.ip, tc.arg); want != have {
+			t.Errorf("unexpected interruption after the reload for %s %q, want %t, have %t", tc.ip, tc.arg, want, have)
+		}
+	}
+}
@@ -2,3 +2,18 @@
 // SPDX-License-Identifier: Apache-2.0
 
 package plugins
+
+import (
+	"github.com/ad3n/seclang"
+	"github.com/ad3n/seclang/experimental/plugins/plugintypes"
+)
+
+// DirectiveHandler is called by the parser every time the registered directive is found
+type DirectiveHandler = plugintypes.DirectiveHandler
+
+// RegisterDirective registers a new directive, names are case-insensitive.
+// Built-in directives take precedence and cannot be replaced.
+// If the directive already exists it will be overwritten
+func RegisterDirective(name string, handler DirectiveHandler) {
+	seclang.RegisterDirective(name, handler)
+}
@@ -0,0 +1,56 @@
+// Copyright 2024 Juan Pablo Tosso and the OWASP Coraza contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package plugins_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/ad3n/seclang"
+	"github.com/ad3n/seclang/experimental/plugins"
+	"github.com/ad3n/seclang/experimental/plugins/plugintypes"
+	"github.com/ad3n/seclang/internal/corazawaf"
+)
+
+func TestDirective(t *testing.T) {
+	t.Run("dispatch registered directive", func(t *testing.T) {
+		var got plugintypes.DirectiveOptions
+		plugins.RegisterDirective("SecMyVendorOption", func(options plugintypes.DirectiveOptions) error {
+			got = options
+			return nil
+		})
+
+		p := seclang.NewParser(corazawaf.NewWAF())
+		if err := p.FromString(`SecMyVendorOption "some value"`); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if want := "secmyvendoroption"; got.Name != want {
+			t.Errorf("unexpected name, want %q, have %q", want, got.Name)
+		}
+		if want := "some value"; got.Arguments != want {
+			t.Errorf("unexpected arguments, want %q, have %q", want, got.Arguments)
+		}
+		if got.Line != 1 {
+			t.Errorf("unexpected line, want 1, have %d", got.Line)
+		}
+	})
+
+	t.Run("handler error aborts parsing", func(t *testing.T) {
+		plugins.RegisterDirective("SecFailingOption", func(plugintypes.DirectiveOptions) error {
+			return errors.New("invalid value")
+		})
+
+		p := seclang.NewParser(corazawaf.NewWAF())
+		if err := p.FromString("SecFailingOption On"); err == nil {
+			t.Error("expected error")
+		}
+	})
+
+	t.Run("unknown directive still fails", func(t *testing.T) {
+		p := seclang.NewParser(corazawaf.NewWAF())
+		if err := p.FromString("SecNotRegisteredOption On"); err == nil {
+			t.Error("expected error")
+		}
+	})
+}
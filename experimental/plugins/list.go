@@ -0,0 +1,76 @@
+// Copyright 2024 Juan Pablo Tosso and the OWASP Coraza contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package plugins
+
+import (
+	"strings"
+
+	"github.com/ad3n/seclang/internal/actions"
+	"github.com/ad3n/seclang/internal/auditlog"
+	"github.com/ad3n/seclang/internal/bodyprocessors"
+	"github.com/ad3n/seclang/internal/operators"
+	"github.com/ad3n/seclang/internal/transformations"
+	"github.com/ad3n/seclang/internal/urlreputation"
+)
+
+// PluginType is the kind of extension a plugin provides
+type PluginType string
+
+// The types of the plugins returned by List
+const (
+	PluginTypeOperator          PluginType = "operator"
+	PluginTypeAction            PluginType = "action"
+	PluginTypeTransformation    PluginType = "transformation"
+	PluginTypeBodyProcessor     PluginType = "bodyprocessor"
+	PluginTypeAuditLogWriter    PluginType = "auditlog_writer"
+	PluginTypeAuditLogFormatter PluginType = "auditlog_formatter"
+	PluginTypeURLReputation     PluginType = "url_reputation"
+)
+
+// Plugin describes a registered operator, action, transformation, body
+// processor, audit log writer, audit log formatter or URL reputation provider
+type Plugin struct {
+	Name string     `json:"name"`
+	Type PluginType `json:"type"`
+	// BuiltIn is false for the plugins registered with this package,
+	// including the built-in ones they overwrite
+	BuiltIn bool `json:"built_in"`
+}
+
+// registered keeps the names registered with this package by type, names
+// are lowercased as the registries may or may not be case-sensitive
+var registered = map[PluginType]map[string]struct{}{}
+
+func track(typ PluginType, name string) {
+	names := registered[typ]
+	if names == nil {
+		names = map[string]struct{}{}
+		registered[typ] = names
+	}
+	names[strings.ToLower(name)] = struct{}{}
+}
+
+// List returns the registered plugins sorted by type and name, it can be used
+// to check that a deployment includes the expected custom plugins
+func List() []Plugin {
+	var plugins []Plugin
+	for _, t := range []struct {
+		typ   PluginType
+		names []string
+	}{
+		{PluginTypeOperator, operators.Names()},
+		{PluginTypeAction, actions.Names()},
+		{PluginTypeTransformation, transformations.Names()},
+		{PluginTypeBodyProcessor, bodyprocessors.Names()},
+		{PluginTypeAuditLogWriter, auditlog.WriterNames()},
+		{PluginTypeAuditLogFormatter, auditlog.FormatterNames()},
+		{PluginTypeURLReputation, urlreputation.Names()},
+	} {
+		for _, name := range t.names {
+			_, custom := registered[t.typ][strings.ToLower(name)]
+			plugins = append(plugins, Plugin{Name: name, Type: t.typ, BuiltIn: !custom})
+		}
+	}
+	return plugins
+}
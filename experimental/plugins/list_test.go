@@ -0,0 +1,44 @@
+// Copyright 2024 Juan Pablo Tosso and the OWASP Coraza contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package plugins_test
+
+import (
+	"testing"
+
+	"github.com/ad3n/seclang/experimental/plugins"
+	"github.com/ad3n/seclang/experimental/plugins/plugintypes"
+)
+
+func TestList(t *testing.T) {
+	plugins.RegisterOperator("listedOperator", func(plugintypes.OperatorOptions) (plugintypes.Operator, error) {
+		return nil, nil
+	})
+	plugins.RegisterURLReputation("listedReputation", func(string) (plugintypes.URLReputation, error) {
+		return nil, nil
+	})
+	plugins.RegisterTransformation("listedTransformation", func(input string) (string, bool, error) {
+		return input, false, nil
+	})
+
+	found := map[plugins.Plugin]bool{}
+	for _, p := range plugins.List() {
+		found[p] = true
+	}
+	tests := []plugins.Plugin{
+		{Name: "rx", Type: plugins.PluginTypeOperator, BuiltIn: true},
+		{Name: "listedOperator", Type: plugins.PluginTypeOperator},
+		{Name: "deny", Type: plugins.PluginTypeAction, BuiltIn: true},
+		{Name: "lowercase", Type: plugins.PluginTypeTransformation, BuiltIn: true},
+		{Name: "listedtransformation", Type: plugins.PluginTypeTransformation},
+		{Name: "json", Type: plugins.PluginTypeBodyProcessor, BuiltIn: true},
+		{Name: "serial", Type: plugins.PluginTypeAuditLogWriter, BuiltIn: true},
+		{Name: "json", Type: plugins.PluginTypeAuditLogFormatter, BuiltIn: true},
+		{Name: "listedreputation", Type: plugins.PluginTypeURLReputation},
+	}
+	for _, want := range tests {
+		if !found[want] {
+			t.Errorf("expected %+v to be listed", want)
+		}
+	}
+}
@@ -9,6 +9,7 @@ import (
 	"strings"
 
 	"github.com/ad3n/seclang/experimental/plugins/plugintypes"
+	"github.com/ad3n/seclang/internal/corazatypes"
 	"github.com/corazawaf/coraza/v3/collection"
 	"github.com/corazawaf/coraza/v3/types/variables"
 )
@@ -20,18 +21,52 @@ type Macro interface {
 
 var errEmptyData = errors.New("empty data")
 
+// NewMacro compiles data into a macro. Identical strings share the same
+// compiled macro, which must be treated as immutable.
 func NewMacro(data string) (Macro, error) {
 	if len(data) == 0 {
 		return nil, errEmptyData
 	}
 
-	macro := &macro{
-		tokens: []macroToken{},
+	m, err := compileShared(data)
+	if err != nil {
+		return nil, err
 	}
-	if err := macro.compile(data); err != nil {
+	return m, nil
+}
+
+// NewEscapedMacro compiles data into a macro whose variables are escaped with
+// escape when it is expanded, e.g. to embed the values of the request in a
+// JSON or HTML document. The text around the variables is kept as is.
+func NewEscapedMacro(data string, escape func(string) string) (Macro, error) {
+	if len(data) == 0 {
+		return nil, errEmptyData
+	}
+
+	m, err := compileShared(data)
+	if err != nil {
 		return nil, err
 	}
-	return macro, nil
+	return &escapedMacro{macro: m, escape: escape}, nil
+}
+
+type escapedMacro struct {
+	*macro
+	escape func(string) string
+}
+
+// Expand the pre-compiled macro expression into a string, escaping the
+// values of the variables
+func (m *escapedMacro) Expand(tx plugintypes.TransactionState) string {
+	res := strings.Builder{}
+	for i, token := range m.tokens {
+		if token.variable == variables.Unknown {
+			res.WriteString(token.text)
+			continue
+		}
+		res.WriteString(m.escape(m.expandToken(tx, i)))
+	}
+	return res.String()
 }
 
 type macroToken struct {
@@ -51,43 +86,55 @@ type macroToken struct {
 type macro struct {
 	original string
 	tokens   []macroToken
+	// defaults contains the default values of the variable tokens
+	// written as %{tx.key|default}, indexed by token position
+	defaults map[int]string
 }
 
 // Expand the pre-compiled macro expression into a string
 func (m *macro) Expand(tx plugintypes.TransactionState) string {
 	if len(m.tokens) == 1 {
-		return expandToken(tx, m.tokens[0])
+		return m.expandToken(tx, 0)
 	}
 	res := strings.Builder{}
-	for _, token := range m.tokens {
-		res.WriteString(expandToken(tx, token))
+	for i := range m.tokens {
+		res.WriteString(m.expandToken(tx, i))
 	}
 	return res.String()
 }
 
-func expandToken(tx plugintypes.TransactionState, token macroToken) string {
+func (m *macro) expandToken(tx plugintypes.TransactionState, i int) string {
+	token := m.tokens[i]
 	if token.variable == variables.Unknown {
 		return token.text
 	}
+	defaultValue, hasDefault := m.defaults[i]
 	switch col := tx.Collection(token.variable).(type) {
 	case collection.Keyed:
-		if c := col.Get(token.key); len(c) > 0 {
-			return c[0]
+		if v, ok := firstValue(col, token.key); ok {
+			return v
 		}
 	case collection.Single:
-		return col.Get()
+		if v := col.Get(); v != "" || !hasDefault {
+			return v
+		}
 	default:
 		if c := col.FindAll(); len(c) > 0 {
 			return c[0].Value()
 		}
 	}
 
+	if hasDefault {
+		return defaultValue
+	}
 	// If the variable is known (e.g. TX) but the key is not found, we return the original text
 	tx.DebugLogger().Warn().Str("variable", token.variable.Name()).Str("key", token.key).Msg("key not found in collection, returning the original text")
 	return token.text
 }
 
 // compile is used to parse the input and generate the corresponding token
+// A variable can have a default value used when it is not set, written
+// after a pipe: %{tx.block_status|403}. Defaults can't contain braces.
 // Example input: %{var.foo} and %{var.bar}
 // expected result:
 // [0] macroToken{text: "%{var.foo}", variable: &variables.Var, key: "foo"},
@@ -102,6 +149,9 @@ func (m *macro) compile(input string) error {
 	m.original = input
 	var currentToken strings.Builder
 	isMacro := false
+	// defaultStart is the position of the default value in the current
+	// token, -1 if the variable has no default
+	defaultStart := -1
 
 	for i := 0; i < l; i++ {
 		c := input[i]
@@ -123,16 +173,28 @@ func (m *macro) compile(input string) error {
 		if isMacro {
 			if c == '}' {
 				isMacro = false
-				if input[i-1] == '.' {
+				name := currentToken.String()
+				if defaultStart != -1 {
+					if m.defaults == nil {
+						m.defaults = map[int]string{}
+					}
+					m.defaults[len(m.tokens)] = name[defaultStart:]
+					name = name[:defaultStart-1]
+					defaultStart = -1
+				}
+				if name == "" {
+					return fmt.Errorf("empty variable name")
+				}
+				if name[len(name)-1] == '.' {
 					return fmt.Errorf("empty variable name")
 				}
-				varName, key, _ := strings.Cut(currentToken.String(), ".")
-				v, err := variables.Parse(varName)
+				varName, key, _ := strings.Cut(name, ".")
+				v, err := corazatypes.ParseVariable(varName)
 				if err != nil {
 					return fmt.Errorf("unknown variable %q", varName)
 				}
 				m.tokens = append(m.tokens, macroToken{
-					text:     currentToken.String(),
+					text:     name,
 					variable: v,
 					key:      strings.ToLower(key),
 				})
@@ -140,6 +202,26 @@ func (m *macro) compile(input string) error {
 				continue
 			}
 
+			if defaultStart == -1 && c == '|' {
+				currentToken.WriteByte(c)
+				defaultStart = currentToken.Len()
+				if i+1 == l {
+					return errors.New("malformed variable: no closing braces")
+				}
+				continue
+			}
+
+			if defaultStart != -1 {
+				if c == '{' {
+					return fmt.Errorf("malformed default value starting with %q", "%{"+currentToken.String())
+				}
+				currentToken.WriteByte(c)
+				if i+1 == l {
+					return errors.New("malformed variable: no closing braces")
+				}
+				continue
+			}
+
 			if !isValidMacroChar(c) {
 				return fmt.Errorf("malformed variable starting with %q", "%{"+currentToken.String())
 			}
@@ -179,3 +261,15 @@ func (m *macro) String() string {
 func (m *macro) IsExpandable() bool {
 	return len(m.tokens) > 1
 }
+
+// firstValue returns the first value of the key, without copying the values
+// when the collection supports it as Get is one of the hottest paths.
+func firstValue(col collection.Keyed, key string) (string, bool) {
+	if c, ok := col.(interface{ GetFirst(string) (string, bool) }); ok {
+		return c.GetFirst(key)
+	}
+	if c := col.Get(key); len(c) > 0 {
+		return c[0], true
+	}
+	return "", false
+}
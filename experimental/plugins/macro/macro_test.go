@@ -146,6 +146,41 @@ func TestCompile(t *testing.T) {
 		}
 	})
 
+	t.Run("default value", func(t *testing.T) {
+		for _, tc := range []struct {
+			input         string
+			expectedMacro macroToken
+			expectedValue string
+		}{
+			{"%{tx.block_status|403}", macroToken{"tx.block_status", variables.TX, "block_status"}, "403"},
+			{"%{tx.msg|blocked by policy: see logs}", macroToken{"tx.msg", variables.TX, "msg"}, "blocked by policy: see logs"},
+			{"%{tx.pipe|a|b}", macroToken{"tx.pipe", variables.TX, "pipe"}, "a|b"},
+			{"%{REQUEST_METHOD|GET}", macroToken{"REQUEST_METHOD", variables.RequestMethod, ""}, "GET"},
+			{"%{tx.empty|}", macroToken{"tx.empty", variables.TX, "empty"}, ""},
+		} {
+			m := &macro{}
+			if err := m.compile(tc.input); err != nil {
+				t.Fatalf("unexpected error: %s", err.Error())
+			}
+			if len(m.tokens) != 1 {
+				t.Fatalf("unexpected number of tokens: want %d, have %d", 1, len(m.tokens))
+			}
+			if m.tokens[0] != tc.expectedMacro {
+				t.Errorf("unexpected token: want %v, have %v", tc.expectedMacro, m.tokens[0])
+			}
+			if want, have := tc.expectedValue, m.defaults[0]; want != have {
+				t.Errorf("unexpected default value: want %q, have %q", want, have)
+			}
+		}
+
+		for _, test := range []string{"%{tx.status|403", "%{tx.status|", "%{tx.status|{403}}", "%{|403}", "%{tx.|403}"} {
+			m := &macro{}
+			if err := m.compile(test); err == nil {
+				t.Errorf("expected error for %q", test)
+			}
+		}
+	})
+
 	t.Run("multi variable", func(t *testing.T) {
 		m := &macro{}
 		err := m.compile("%{tx.id} got %{tx.count} in this transaction and as zero %{tx.0}")
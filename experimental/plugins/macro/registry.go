@@ -0,0 +1,62 @@
+// Copyright 2024 Juan Pablo Tosso and the OWASP Coraza contributors
+// SPDX-License-Identifier: Apache-2.0
+
+//go:build !tinygo
+// +build !tinygo
+
+package macro
+
+import (
+	"runtime"
+	"strings"
+	"sync"
+	"weak"
+)
+
+// registry shares the compiled macros between identical strings, e.g. the msg
+// and logdata templates repeated across many CRS rules. Macros are immutable
+// once compiled so they are safe to share between rules and WAF instances.
+// Entries are weak, a macro no longer used by any rule is collected and removed
+// from the registry, so reloading rules doesn't leak memory.
+var registry = struct {
+	mu     sync.Mutex
+	macros map[string]weak.Pointer[macro]
+}{
+	macros: map[string]weak.Pointer[macro]{},
+}
+
+// compileShared returns the compiled macro for data, reusing the
+// one in the registry if there is one alive
+func compileShared(data string) (*macro, error) {
+	registry.mu.Lock()
+	defer registry.mu.Unlock()
+
+	if wp, ok := registry.macros[data]; ok {
+		if m := wp.Value(); m != nil {
+			return m, nil
+		}
+	}
+
+	// data is usually a slice of the whole rule, it is cloned to
+	// not keep the rule alive through the macro
+	data = strings.Clone(data)
+	m := &macro{
+		tokens: []macroToken{},
+	}
+	if err := m.compile(data); err != nil {
+		return nil, err
+	}
+	registry.macros[data] = weak.Make(m)
+	runtime.AddCleanup(m, removeCollected, data)
+	return m, nil
+}
+
+// removeCollected removes the entry of a collected macro unless
+// it has already been replaced by a new one
+func removeCollected(data string) {
+	registry.mu.Lock()
+	defer registry.mu.Unlock()
+	if wp, ok := registry.macros[data]; ok && wp.Value() == nil {
+		delete(registry.macros, data)
+	}
+}
@@ -0,0 +1,83 @@
+// Copyright 2024 Juan Pablo Tosso and the OWASP Coraza contributors
+// SPDX-License-Identifier: Apache-2.0
+
+//go:build !tinygo
+// +build !tinygo
+
+package macro
+
+import (
+	"runtime"
+	"testing"
+	"time"
+)
+
+func TestNewMacroIsShared(t *testing.T) {
+	m1, err := NewMacro("SQL Injection Attack Detected via %{tx.0}")
+	if err != nil {
+		t.Fatal(err)
+	}
+	m2, err := NewMacro("SQL Injection Attack Detected via %{tx.0}")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m1 != m2 {
+		t.Error("expected identical strings to share the compiled macro")
+	}
+
+	m3, err := NewMacro("XSS Attack Detected via %{tx.0}")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m1 == m3 {
+		t.Error("unexpected shared macro for different strings")
+	}
+
+	if allocs := testing.AllocsPerRun(100, func() {
+		_, _ = NewMacro("SQL Injection Attack Detected via %{tx.0}")
+	}); allocs != 0 {
+		t.Errorf("unexpected allocations compiling a registered macro, have %v", allocs)
+	}
+	runtime.KeepAlive(m1)
+
+	if _, err := NewMacro("%{tx.missing_brace"); err == nil {
+		t.Error("expected error")
+	}
+	if _, ok := registry.macros["%{tx.missing_brace"]; ok {
+		t.Error("unexpected registry entry for a failed compilation")
+	}
+}
+
+func TestRegistryRemovesCollectedMacros(t *testing.T) {
+	const data = "collected %{tx.anomaly_score}"
+	m, err := NewMacro(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.String() != data {
+		t.Fatalf("unexpected macro string, want %q, have %q", data, m.String())
+	}
+	m = nil
+	_ = m
+
+	for i := 0; i < 100; i++ {
+		runtime.GC()
+		registry.mu.Lock()
+		_, ok := registry.macros[data]
+		registry.mu.Unlock()
+		if !ok {
+			return
+		}
+		time.Sleep(time.Millisecond)
+	}
+	t.Error("expected collected macro to be removed from the registry")
+}
+
+func BenchmarkNewMacro(b *testing.B) {
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if _, err := NewMacro("Remote Command Execution: Unix Shell Code Found in %{matched_var_name}"); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
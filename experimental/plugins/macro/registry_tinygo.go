@@ -0,0 +1,19 @@
+// Copyright 2024 Juan Pablo Tosso and the OWASP Coraza contributors
+// SPDX-License-Identifier: Apache-2.0
+
+//go:build tinygo
+// +build tinygo
+
+package macro
+
+// compileShared compiles the macro, TinyGo doesn't support weak
+// pointers so macros are not shared.
+func compileShared(data string) (*macro, error) {
+	m := &macro{
+		tokens: []macroToken{},
+	}
+	if err := m.compile(data); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
@@ -12,4 +12,26 @@ import (
 // If the operator already exists it will be overwritten
 func RegisterOperator(name string, op plugintypes.OperatorFactory) {
 	operators.Register(name, op)
+	track(PluginTypeOperator, name)
+}
+
+// RegisterOperatorMiddleware wraps the operator registered with name, e.g. to
+// time, sample or count its evaluations without forking its implementation.
+// It applies to the rules parsed from now on, the middlewares registered last
+// are the outermost. name is case-sensitive, like the operator names.
+//
+// Example:
+// ```go
+//
+//	plugins.RegisterOperatorMiddleware("rx", func(name string, next plugintypes.Operator) plugintypes.Operator {
+//		return operatorFunc(func(tx plugintypes.TransactionState, value string) bool {
+//			start := time.Now()
+//			defer func() { rxDuration.Observe(time.Since(start).Seconds()) }()
+//			return next.Evaluate(tx, value)
+//		})
+//	})
+//
+// ```
+func RegisterOperatorMiddleware(name string, mw plugintypes.OperatorMiddleware) {
+	operators.RegisterMiddleware(name, mw)
 }
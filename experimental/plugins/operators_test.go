@@ -4,6 +4,7 @@
 package plugins_test
 
 import (
+	"strings"
 	"testing"
 
 	"github.com/ad3n/seclang/experimental/plugins"
@@ -24,3 +25,94 @@ func TestGetOperator(t *testing.T) {
 		}
 	})
 }
+
+type operatorFunc func(plugintypes.TransactionState, string) bool
+
+func (f operatorFunc) Evaluate(tx plugintypes.TransactionState, value string) bool {
+	return f(tx, value)
+}
+
+func TestRegisterOperatorMiddleware(t *testing.T) {
+	plugins.RegisterOperator("middleware_operator", func(options plugintypes.OperatorOptions) (plugintypes.Operator, error) {
+		return operatorFunc(func(_ plugintypes.TransactionState, value string) bool {
+			return value == options.Arguments
+		}), nil
+	})
+
+	var calls []string
+	failures := 0
+	plugins.RegisterOperatorMiddleware("middleware_operator", func(name string, next plugintypes.Operator) plugintypes.Operator {
+		return operatorFunc(func(tx plugintypes.TransactionState, value string) bool {
+			calls = append(calls, "inner "+name)
+			res := next.Evaluate(tx, value)
+			if !res {
+				failures++
+			}
+			return res
+		})
+	})
+	plugins.RegisterOperatorMiddleware("middleware_operator", func(_ string, next plugintypes.Operator) plugintypes.Operator {
+		return operatorFunc(func(tx plugintypes.TransactionState, value string) bool {
+			calls = append(calls, "outer")
+			return next.Evaluate(tx, value)
+		})
+	})
+
+	op, err := operators.Get("middleware_operator", plugintypes.OperatorOptions{Arguments: "abc"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !op.Evaluate(nil, "abc") {
+		t.Error("expected the wrapped operator to match")
+	}
+	if op.Evaluate(nil, "def") {
+		t.Error("unexpected match of the wrapped operator")
+	}
+	if want, have := 1, failures; want != have {
+		t.Errorf("unexpected failures, want %d, have %d", want, have)
+	}
+	if want, have := "outer,inner middleware_operator,outer,inner middleware_operator", strings.Join(calls, ","); want != have {
+		t.Errorf("unexpected calls, want %q, have %q", want, have)
+	}
+
+	if _, err := operators.Get("unconditionalMatch", plugintypes.OperatorOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	if len(calls) != 4 {
+		t.Error("unexpected middleware call for another operator")
+	}
+}
+
+type maskingOperatorFunc struct {
+	operatorFunc
+}
+
+func (maskingOperatorFunc) Mask(value string) string {
+	return strings.Repeat("*", len(value))
+}
+
+func TestRegisterOperatorMiddlewareMasking(t *testing.T) {
+	plugins.RegisterOperator("masking_middleware_operator", func(options plugintypes.OperatorOptions) (plugintypes.Operator, error) {
+		return maskingOperatorFunc{operatorFunc(func(_ plugintypes.TransactionState, value string) bool {
+			return value == options.Arguments
+		})}, nil
+	})
+	plugins.RegisterOperatorMiddleware("masking_middleware_operator", func(_ string, next plugintypes.Operator) plugintypes.Operator {
+		return operatorFunc(next.Evaluate)
+	})
+
+	op, err := operators.Get("masking_middleware_operator", plugintypes.OperatorOptions{Arguments: "abc"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !op.Evaluate(nil, "abc") {
+		t.Error("expected the wrapped operator to match")
+	}
+	m, ok := op.(plugintypes.MaskingOperator)
+	if !ok {
+		t.Fatal("expected the wrapped operator to keep masking its matches")
+	}
+	if want, have := "***", m.Mask("abc"); want != have {
+		t.Errorf("unexpected masked value, want %q, have %q", want, have)
+	}
+}
@@ -0,0 +1,36 @@
+// Copyright 2024 Juan Pablo Tosso and the OWASP Coraza contributors
+// SPDX-License-Identifier: Apache-2.0
+
+// Command fixturegen generates the golden files of operators and
+// transformations, and the tests running them, from a YAML spec, see
+// plugintest.Spec. It is meant to be run with go generate from the package
+// of the plugin:
+//
+//	//go:generate go run github.com/ad3n/seclang/experimental/plugins/plugintest/fixturegen -spec fixtures.yaml
+package main
+
+import (
+	"flag"
+	"log"
+	"os"
+
+	"github.com/ad3n/seclang/experimental/plugins/plugintest"
+)
+
+func main() {
+	specPath := flag.String("spec", "fixtures.yaml", "path of the YAML spec")
+	dir := flag.String("dir", ".", "directory of the package the tests are written to")
+	flag.Parse()
+
+	data, err := os.ReadFile(*specPath)
+	if err != nil {
+		log.Fatal(err)
+	}
+	spec, err := plugintest.ParseSpec(data)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err := plugintest.Generate(spec, *dir); err != nil {
+		log.Fatal(err)
+	}
+}
@@ -0,0 +1,201 @@
+// Copyright 2024 Juan Pablo Tosso and the OWASP Coraza contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package plugintest
+
+import (
+	"bytes"
+	_ "embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"go/format"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"text/template"
+	"unicode"
+	"unicode/utf8"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Spec lists the cases of the operators and transformations of a package,
+// Generate writes their golden files and the tests running them.
+//
+// Example:
+// ```yaml
+//
+//	package: myplugin
+//	operators:
+//	  - name: startsWithDigit
+//	    cases:
+//	      - input: 1abc
+//	        match: true
+//	      - input: abc
+//	        match: false
+//	transformations:
+//	  - name: reverse
+//	    cases:
+//	      - input: abc
+//	        output: cba
+//
+// ```
+//
+// Inputs that are not valid utf8 can be written with the !!binary tag.
+type Spec struct {
+	// Package is the package of the generated tests
+	Package         string      `yaml:"package"`
+	Operators       []Component `yaml:"operators"`
+	Transformations []Component `yaml:"transformations"`
+}
+
+// Component is an operator or a transformation and its cases
+type Component struct {
+	Name  string `yaml:"name"`
+	Cases []Case `yaml:"cases"`
+}
+
+// Case is an input of a component and the expected result, Param and Match
+// apply to the operators and Output to the transformations
+type Case struct {
+	Param  string `yaml:"param"`
+	Input  string `yaml:"input"`
+	Match  bool   `yaml:"match"`
+	Output string `yaml:"output"`
+}
+
+// ParseSpec decodes a YAML spec, unknown fields are rejected to catch typos
+func ParseSpec(data []byte) (Spec, error) {
+	var spec Spec
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+	if err := dec.Decode(&spec); err != nil {
+		return Spec{}, fmt.Errorf("invalid fixtures spec: %s", err.Error())
+	}
+	if spec.Package == "" {
+		return Spec{}, errors.New("invalid fixtures spec: missing package")
+	}
+	for _, components := range [][]Component{spec.Operators, spec.Transformations} {
+		seen := map[string]bool{}
+		for _, c := range components {
+			if c.Name == "" {
+				return Spec{}, errors.New("invalid fixtures spec: missing component name")
+			}
+			if len(c.Cases) == 0 {
+				return Spec{}, fmt.Errorf("invalid fixtures spec: %s has no cases", c.Name)
+			}
+			if seen[c.Name] {
+				return Spec{}, fmt.Errorf("invalid fixtures spec: %s is listed twice", c.Name)
+			}
+			seen[c.Name] = true
+		}
+	}
+	return spec, nil
+}
+
+//go:embed fixtures_test.go.tmpl
+var fixturesTestTmpl string
+
+var fixturesTest = template.Must(template.New("fixtures").Parse(fixturesTestTmpl))
+
+// Generate writes to dir the golden files of the components of the spec, in
+// testdata/operators and testdata/transformations, and a test running each
+// of them. The golden files are always written, the tests are skeletons
+// meant to be completed and are only written if they don't exist.
+func Generate(spec Spec, dir string) error {
+	for _, kind := range []struct {
+		typ        string
+		dir        string
+		suffix     string
+		components []Component
+	}{
+		{TypeOperator, "operators", "Operator", spec.Operators},
+		{TypeTransformation, "transformations", "Transformation", spec.Transformations},
+	} {
+		for _, c := range kind.components {
+			golden := filepath.Join("testdata", kind.dir, c.Name+".json")
+			if err := writeGolden(filepath.Join(dir, golden), kind.typ, c); err != nil {
+				return err
+			}
+			name := identifier(c.Name)
+			test := filepath.Join(dir, strings.ToLower(name)+"_"+strings.ToLower(kind.suffix)+"_fixtures_test.go")
+			if _, err := os.Stat(test); err == nil {
+				continue
+			}
+			var src bytes.Buffer
+			if err := fixturesTest.Execute(&src, map[string]string{
+				"Package": spec.Package,
+				"Test":    "Test" + name + kind.suffix + "Fixtures",
+				"Golden":  filepath.ToSlash(golden),
+			}); err != nil {
+				return err
+			}
+			formatted, err := format.Source(src.Bytes())
+			if err != nil {
+				return fmt.Errorf("failed to format the test of %s: %s", c.Name, err.Error())
+			}
+			if err := os.WriteFile(test, formatted, 0o644); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func writeGolden(path string, typ string, c Component) error {
+	fixtures := make([]Fixture, 0, len(c.Cases))
+	for _, cs := range c.Cases {
+		f := Fixture{Name: c.Name, Type: typ, Input: escape(cs.Input)}
+		if typ == TypeOperator {
+			f.Param = escape(cs.Param)
+			if cs.Match {
+				f.Ret = 1
+			}
+		} else {
+			f.Output = escape(cs.Output)
+			if cs.Output != cs.Input {
+				f.Ret = 1
+			}
+		}
+		fixtures = append(fixtures, f)
+	}
+	data, err := json.MarshalIndent(fixtures, "", "   ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(data, '\n'), 0o644)
+}
+
+// escape quotes the strings that are not valid utf8, which JSON can't
+// represent, and the ones containing \x, which LoadFixtures unquotes
+func escape(s string) string {
+	if utf8.ValidString(s) && !strings.Contains(s, `\x`) {
+		return s
+	}
+	q := strconv.Quote(s)
+	return q[1 : len(q)-1]
+}
+
+// identifier returns the name as an exported Go identifier, e.g.
+// StartsWithDigit for startsWithDigit
+func identifier(name string) string {
+	var sb strings.Builder
+	upper := true
+	for _, r := range name {
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) {
+			upper = true
+			continue
+		}
+		if upper {
+			r = unicode.ToUpper(r)
+			upper = false
+		}
+		sb.WriteRune(r)
+	}
+	return sb.String()
+}
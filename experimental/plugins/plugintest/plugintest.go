@@ -0,0 +1,149 @@
+// Copyright 2024 Juan Pablo Tosso and the OWASP Coraza contributors
+// SPDX-License-Identifier: Apache-2.0
+
+// Package plugintest tests operators and transformations against fixtures in
+// the format of the secrules-language-tests, the one the engine tests its own
+// operators and transformations with, so plugins can check they honor the
+// same contract. The fixtures and the tests running them can be generated
+// from a YAML spec with Generate or the fixturegen command.
+package plugintest
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/ad3n/seclang/experimental/plugins/plugintypes"
+	"github.com/ad3n/seclang/internal/corazawaf"
+	"github.com/ad3n/seclang/internal/operators"
+	"github.com/ad3n/seclang/internal/transformations"
+)
+
+// The types of the fixtures
+const (
+	TypeOperator       = "op"
+	TypeTransformation = "tfn"
+)
+
+// Fixture is a case of a golden file. Inputs, params and outputs containing
+// \x escapes are unquoted, which allows bytes that are not valid utf8.
+type Fixture struct {
+	// Name is the name the operator or transformation is registered with
+	Name string `json:"name"`
+	// Type is TypeOperator or TypeTransformation
+	Type string `json:"type"`
+	// Param is the argument of the operator
+	Param string `json:"param,omitempty"`
+	Input string `json:"input"`
+	// Output is the output of the transformation
+	Output string `json:"output,omitempty"`
+	// Ret is 1 if the operator matches or if the transformation changes the
+	// input, 0 otherwise
+	Ret int `json:"ret"`
+}
+
+// LoadFixtures reads the fixtures of a golden file
+func LoadFixtures(path string) ([]Fixture, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var fixtures []Fixture
+	if err := json.Unmarshal(data, &fixtures); err != nil {
+		return nil, fmt.Errorf("invalid fixtures %s: %s", path, err.Error())
+	}
+	for i := range fixtures {
+		f := &fixtures[i]
+		for _, s := range []*string{&f.Param, &f.Input, &f.Output} {
+			if *s, err = unescape(*s); err != nil {
+				return nil, fmt.Errorf("invalid fixture %d of %s: %s", i, path, err.Error())
+			}
+		}
+	}
+	return fixtures, nil
+}
+
+// RunFixtures runs the fixtures of a golden file as subtests. The files the
+// operators read, e.g. @pmFromFile, are resolved from the directory of the
+// golden file.
+func RunFixtures(t *testing.T, path string) {
+	t.Helper()
+	fixtures, err := LoadFixtures(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	root := os.DirFS(filepath.Dir(path))
+	for i, f := range fixtures {
+		t.Run(fmt.Sprintf("%s/%d", f.Name, i), func(t *testing.T) {
+			if err := Check(f, root); err != nil {
+				t.Error(err)
+			}
+		})
+	}
+}
+
+// Check runs a fixture, the files read by the operators are resolved from
+// root. Operators must return the same result whether the transaction
+// captures or not, and transformations must report whether they changed the
+// input.
+func Check(f Fixture, root fs.FS) error {
+	switch f.Type {
+	case TypeOperator:
+		return checkOperator(f, root)
+	case TypeTransformation:
+		return checkTransformation(f)
+	}
+	return fmt.Errorf("unknown fixture type %q of %s", f.Type, f.Name)
+}
+
+func checkOperator(f Fixture, root fs.FS) error {
+	op, err := operators.Get(f.Name, plugintypes.OperatorOptions{
+		Arguments: f.Param,
+		Path:      []string{"."},
+		Root:      root,
+	})
+	if err != nil {
+		return err
+	}
+	waf := corazawaf.NewWAF()
+	for _, capture := range []bool{false, true} {
+		tx := waf.NewTransaction()
+		tx.Capture = capture
+		if want, have := f.Ret == 1, op.Evaluate(tx, f.Input); want != have {
+			return fmt.Errorf("unexpected result of @%s %q with %q (capture %t), want %t, have %t", f.Name, f.Param, f.Input, capture, want, have)
+		}
+		tx.Close()
+	}
+	return nil
+}
+
+func checkTransformation(f Fixture) error {
+	trans, err := transformations.GetTransformation(f.Name)
+	if err != nil {
+		return err
+	}
+	out, changed, err := trans(f.Input)
+	if err != nil {
+		return fmt.Errorf("unexpected error of t:%s with %q: %s", f.Name, f.Input, err.Error())
+	}
+	if out != f.Output {
+		return fmt.Errorf("unexpected output of t:%s with %q, want %q, have %q", f.Name, f.Input, f.Output, out)
+	}
+	if want := f.Ret == 1; want != changed {
+		return fmt.Errorf("unexpected change of t:%s with %q, want %t, have %t", f.Name, f.Input, want, changed)
+	}
+	return nil
+}
+
+// unescape unquotes the strings with \x escapes
+func unescape(s string) (string, error) {
+	if !strings.Contains(s, `\x`) {
+		return s, nil
+	}
+	return strconv.Unquote(`"` + s + `"`)
+}
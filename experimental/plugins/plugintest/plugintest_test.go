@@ -0,0 +1,146 @@
+// Copyright 2024 Juan Pablo Tosso and the OWASP Coraza contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package plugintest_test
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"github.com/ad3n/seclang/experimental/plugins"
+	"github.com/ad3n/seclang/experimental/plugins/plugintest"
+	"github.com/ad3n/seclang/experimental/plugins/plugintypes"
+)
+
+type startsWithDigit struct{}
+
+func (startsWithDigit) Evaluate(_ plugintypes.TransactionState, value string) bool {
+	return value != "" && value[0] >= '0' && value[0] <= '9'
+}
+
+func reverse(input string) (string, bool, error) {
+	r := []byte(input)
+	slices.Reverse(r)
+	return string(r), string(r) != input, nil
+}
+
+func init() {
+	plugins.RegisterOperator("startsWithDigit", func(plugintypes.OperatorOptions) (plugintypes.Operator, error) {
+		return startsWithDigit{}, nil
+	})
+	plugins.RegisterTransformation("reverse", reverse)
+}
+
+const spec = `package: myplugin
+operators:
+  - name: startsWithDigit
+    cases:
+      - input: 1abc
+        match: true
+      - input: abc
+        match: false
+  - name: rx
+    cases:
+      - param: \x41\d
+        input: A1
+        match: true
+transformations:
+  - name: reverse
+    cases:
+      - input: abc
+        output: cba
+      - input: aba
+        output: aba
+      - input: !!binary rO0=
+        output: !!binary 7aw=
+`
+
+func TestGenerate(t *testing.T) {
+	s, err := plugintest.ParseSpec([]byte(spec))
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := t.TempDir()
+	if err := plugintest.Generate(s, dir); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, golden := range []string{"operators/startsWithDigit.json", "operators/rx.json", "transformations/reverse.json"} {
+		t.Run(golden, func(t *testing.T) {
+			plugintest.RunFixtures(t, filepath.Join(dir, "testdata", golden))
+		})
+	}
+	fixtures, err := plugintest.LoadFixtures(filepath.Join(dir, "testdata", "transformations", "reverse.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want, have := "\xed\xac", fixtures[2].Output; want != have {
+		t.Errorf("unexpected binary output, want %q, have %q", want, have)
+	}
+	if want, have := 0, fixtures[1].Ret; want != have {
+		t.Errorf("unexpected ret of an unchanged input, want %d, have %d", want, have)
+	}
+
+	test := filepath.Join(dir, "startswithdigit_operator_fixtures_test.go")
+	src, err := os.ReadFile(test)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"package myplugin", "func TestStartsWithDigitOperatorFixtures(t *testing.T)", `plugintest.RunFixtures(t, "testdata/operators/startsWithDigit.json")`} {
+		if !strings.Contains(string(src), want) {
+			t.Errorf("expected %q in the generated test:\n%s", want, src)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(dir, "reverse_transformation_fixtures_test.go")); err != nil {
+		t.Error(err)
+	}
+
+	// the skeletons are not overwritten
+	if err := os.WriteFile(test, []byte("edited"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := plugintest.Generate(s, dir); err != nil {
+		t.Fatal(err)
+	}
+	if src, _ := os.ReadFile(test); string(src) != "edited" {
+		t.Error("unexpected overwrite of an existing test")
+	}
+}
+
+func TestParseSpecErrors(t *testing.T) {
+	for _, spec := range []string{
+		"operators: []",
+		"package: p\noperators:\n  - name: rx\n",
+		"package: p\noperators:\n  - cases: [{input: a}]\n",
+		"package: p\noperators:\n  - name: rx\n    cases: [{input: a}]\n  - name: rx\n    cases: [{input: b}]\n",
+		"package: p\noperators:\n  - name: rx\n    cases: [{input: a, expected: b}]\n",
+	} {
+		if _, err := plugintest.ParseSpec([]byte(spec)); err == nil {
+			t.Errorf("expected error for %q", spec)
+		}
+	}
+}
+
+func TestCheck(t *testing.T) {
+	root := fstest.MapFS{"agents.txt": &fstest.MapFile{Data: []byte("sqlmap\n")}}
+	tests := []struct {
+		fixture plugintest.Fixture
+		valid   bool
+	}{
+		{plugintest.Fixture{Name: "pmFromFile", Type: plugintest.TypeOperator, Param: "agents.txt", Input: "sqlmap/1.0", Ret: 1}, true},
+		{plugintest.Fixture{Name: "startsWithDigit", Type: plugintest.TypeOperator, Input: "abc", Ret: 1}, false},
+		{plugintest.Fixture{Name: "reverse", Type: plugintest.TypeTransformation, Input: "ab", Output: "ba", Ret: 0}, false},
+		{plugintest.Fixture{Name: "reverse", Type: plugintest.TypeTransformation, Input: "ab", Output: "ab", Ret: 1}, false},
+		{plugintest.Fixture{Name: "missing", Type: plugintest.TypeOperator}, false},
+		{plugintest.Fixture{Name: "reverse", Type: "unknown"}, false},
+	}
+	for _, tt := range tests {
+		if err := plugintest.Check(tt.fixture, root); (err == nil) != tt.valid {
+			t.Errorf("unexpected result of %+v, want valid %t, have %v", tt.fixture, tt.valid, err)
+		}
+	}
+}
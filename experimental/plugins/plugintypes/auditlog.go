@@ -55,6 +55,31 @@ type AuditLogTransactionProducer interface {
 	Rulesets() []string
 }
 
+// AuditLogTransactionProducerEngine is implemented by the producers that
+// identify the engine that produced the log. It is not part of
+// AuditLogTransactionProducer so that the producers implemented before it
+// keep working, the formatters omit the engine of the other producers.
+type AuditLogTransactionProducerEngine interface {
+	// EngineName is the name of the engine that produced the log
+	EngineName() string
+	// EngineVersion is the version of the engine that produced the log
+	EngineVersion() string
+}
+
+// AuditLogTransactionProducerLabels is implemented by the producers that
+// report the labels of the WAF instance that produced the log, see SecLabel
+type AuditLogTransactionProducerLabels interface {
+	Labels() map[string]string
+}
+
+// AuditLogTransactionProducerRulesPerformance is implemented by the producers
+// that report the slow rules of the transaction
+type AuditLogTransactionProducerRulesPerformance interface {
+	// RulesPerformanceInfo lists the rules that took longer than
+	// SecRulePerfTime with their duration in microseconds
+	RulesPerformanceInfo() string
+}
+
 // AuditLogTransactionRequest contains request specific information
 type AuditLogTransactionRequest interface {
 	Method() string
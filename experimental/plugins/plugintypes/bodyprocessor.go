@@ -21,6 +21,25 @@ type BodyProcessorOptions struct {
 	FileMode fs.FileMode
 	// DirMode is the mode of the directory that will be created
 	DirMode fs.FileMode
+	// FileLimit is the maximum number of files stored in StoragePath,
+	// the files over the limit are inspected but not stored. 0 means
+	// no limit.
+	FileLimit int
+	// XMLEntityResolver resolves the external entities declared in the
+	// DOCTYPE of XML bodies, they are not resolved when it is nil
+	XMLEntityResolver XMLEntityResolver
+	// FlattenArrayArguments indicates that array keys like a[]=1 must be
+	// indexed (a[0]) instead of kept raw
+	FlattenArrayArguments bool
+}
+
+// XMLEntityResolver resolves the external entities of XML bodies when
+// SecXmlExternalEntity is On, e.g. <!ENTITY name SYSTEM "uri">.
+type XMLEntityResolver interface {
+	// ResolveEntity returns the replacement text of the entity declared
+	// with the public and system identifiers, publicID is empty for the
+	// SYSTEM entities.
+	ResolveEntity(publicID, systemID string) (string, error)
 }
 
 // BodyProcessor interface is used to create
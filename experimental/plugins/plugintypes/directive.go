@@ -0,0 +1,43 @@
+// Copyright 2024 Juan Pablo Tosso and the OWASP Coraza contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package plugintypes
+
+import (
+	"io/fs"
+
+	"github.com/corazawaf/coraza/v3/debuglog"
+)
+
+// DirectiveOptions contains the data passed to a custom directive
+// when it is found by the seclang parser.
+type DirectiveOptions struct {
+	// Name is the lowercased name of the directive
+	Name string
+
+	// Arguments contains everything after the directive name,
+	// with surrounding quotes removed
+	Arguments string
+
+	// Raw is the full line as read by the parser
+	Raw string
+
+	// ConfigFile is the file the directive was read from
+	ConfigFile string
+
+	// ConfigDir is the directory of ConfigFile, used to resolve relative paths
+	ConfigDir string
+
+	// Line is the line number of the directive in ConfigFile
+	Line int
+
+	// Root is the filesystem used by the parser
+	Root fs.FS
+
+	// Logger is the debug logger of the WAF being configured
+	Logger debuglog.Logger
+}
+
+// DirectiveHandler is used to create directive plugins.
+// Returning an error will abort the parsing of the configuration.
+type DirectiveHandler func(options DirectiveOptions) error
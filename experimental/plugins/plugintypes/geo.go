@@ -0,0 +1,15 @@
+// Copyright 2024 Juan Pablo Tosso and the OWASP Coraza contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package plugintypes
+
+import "net"
+
+// GeoDatabase resolves addresses to their geographical location, it is
+// used by the @geoLookup operator to populate the GEO collection.
+type GeoDatabase interface {
+	// Lookup returns the location of the address using the GEO collection
+	// keys, e.g. COUNTRY_CODE or CITY. It returns false if the address is
+	// not in the database.
+	Lookup(ip net.IP) (map[string]string, bool, error)
+}
@@ -0,0 +1,17 @@
+// Copyright 2024 Juan Pablo Tosso and the OWASP Coraza contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package plugintypes
+
+import "github.com/corazawaf/coraza/v3/types"
+
+// MatchedLogger can be implemented by connectors to receive the matched
+// rules formatted as ModSecurity error.log lines, so existing alerting
+// pipelines parsing that format can consume them as they are, e.g.
+//
+//	[client "10.0.0.1"] ModSecurity: Warning. SQL Injection Attack [file "rules.conf"] [line "12"] [id "942100"] ...
+type MatchedLogger interface {
+	// LogMatched is called for every matched rule with logging enabled
+	// (i.e. not using nolog). The rule contains the match details.
+	LogMatched(line string, rule types.MatchedRule)
+}
@@ -3,7 +3,10 @@
 
 package plugintypes
 
-import "io/fs"
+import (
+	"io/fs"
+	"sync/atomic"
+)
 
 // OperatorOptions is used to store the options for a rule operator
 type OperatorOptions struct {
@@ -18,6 +21,32 @@ type OperatorOptions struct {
 
 	// Datasets contains input datasets or dictionaries
 	Datasets map[string][]string
+
+	// RxDefaultFlags are the flags, e.g. "sm", applied to the regular
+	// expressions of the operators when HasRxDefaultFlags is set, otherwise
+	// the operators apply their own defaults
+	RxDefaultFlags    string
+	HasRxDefaultFlags bool
+
+	// PcreMatchLimit and PcreMatchLimitRecursion bound the matches of the
+	// expressions compiled with PCRE2, zero applies the defaults of the
+	// operators
+	PcreMatchLimit          int
+	PcreMatchLimitRecursion int
+
+	// DatasetFeeds keeps the values of the datasets updated at runtime for
+	// the rules of the WAF, nil if the datasets can't be updated
+	DatasetFeeds DatasetFeeds
+}
+
+// DatasetFeeds keeps the values of the datasets of a WAF updated at runtime,
+// e.g. with plugins.UpdateDataset, for the operators matching them
+type DatasetFeeds interface {
+	// Subscribe returns the operator named name matching the values of the
+	// last update of the dataset, nil until the dataset is updated. The
+	// operator is replaced with the one built from the values of the
+	// following updates.
+	Subscribe(dataset, name string, build func(values []string) (Operator, error)) (*atomic.Pointer[Operator], error)
 }
 
 // Operator interface is used to define rule @operators
@@ -28,4 +57,17 @@ type Operator interface {
 	Evaluate(TransactionState, string) bool
 }
 
+// MaskingOperator is an operator matching sensitive values, e.g. card
+// numbers, Mask redacts them in the value it matched. The masked value is
+// the one of MATCHED_VAR and of the logged matches. The middlewares wrapping
+// the operator don't have to implement it, the mask of the operator is kept.
+type MaskingOperator interface {
+	Operator
+	Mask(value string) string
+}
+
 type OperatorFactory func(options OperatorOptions) (Operator, error)
+
+// OperatorMiddleware wraps an operator, e.g. to time or sample its
+// evaluations, name is the name the operator is registered with
+type OperatorMiddleware func(name string, next Operator) Operator
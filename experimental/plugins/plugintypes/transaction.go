@@ -35,6 +35,10 @@ type TransactionState interface {
 	CaptureField(idx int, value string)
 
 	LastPhase() types.RulePhase
+
+	// DependencyFailed reports that an external dependency used by an operator failed,
+	// it returns the result the operator has to return according to the configured failure mode.
+	DependencyFailed(feature string, err error) bool
 }
 
 // TransactionVariables has pointers to all the variables of the transaction
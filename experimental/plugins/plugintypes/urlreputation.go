@@ -0,0 +1,17 @@
+// Copyright 2024 Juan Pablo Tosso and the OWASP Coraza contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package plugintypes
+
+// URLReputation looks up URLs in a reputation service, e.g. a Safe Browsing
+// compatible API or a local list, it is used by the @gsbLookup operator.
+// It must be safe for concurrent use.
+type URLReputation interface {
+	// Lookup returns the threat the URL is listed for, e.g. MALWARE or
+	// SOCIAL_ENGINEERING. It returns false if the URL is not listed.
+	Lookup(url string) (string, bool, error)
+}
+
+// URLReputationFactory returns the provider configured by the URI passed to
+// SecGsbLookupDb, the provider is selected by the scheme of the URI
+type URLReputationFactory func(uri string) (URLReputation, error)
@@ -12,4 +12,5 @@ import (
 // If the transformation is already registered, it will be overwritten
 func RegisterTransformation(name string, trans plugintypes.Transformation) {
 	transformations.Register(name, trans)
+	track(PluginTypeTransformation, name)
 }
@@ -0,0 +1,18 @@
+// Copyright 2024 Juan Pablo Tosso and the OWASP Coraza contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package plugins
+
+import (
+	"github.com/ad3n/seclang/experimental/plugins/plugintypes"
+	"github.com/ad3n/seclang/internal/urlreputation"
+)
+
+// RegisterURLReputation registers the provider of the @gsbLookup operator
+// selected by the scheme of the URI passed to SecGsbLookupDb, e.g.
+// `SecGsbLookupDb reputation://feeds.example.com`. If a provider is already
+// registered for the scheme it will be overwritten.
+func RegisterURLReputation(scheme string, factory plugintypes.URLReputationFactory) {
+	urlreputation.Register(scheme, factory)
+	track(PluginTypeURLReputation, scheme)
+}
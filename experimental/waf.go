@@ -4,6 +4,7 @@
 package experimental
 
 import (
+	"github.com/ad3n/seclang/experimental/plugins/plugintypes"
 	"github.com/ad3n/seclang/internal/corazawaf"
 	"github.com/corazawaf/coraza/v3/types"
 )
@@ -15,3 +16,10 @@ type Options = corazawaf.Options
 type WAFWithOptions interface {
 	NewTransactionWithOptions(Options) types.Transaction
 }
+
+// TransactionWithAuditEntry is an interface that allows connectors to get
+// the audit log entry of a transaction before it is logged, even if the
+// audit engine is disabled
+type TransactionWithAuditEntry interface {
+	AuditEntry() plugintypes.AuditLog
+}
@@ -13,6 +13,8 @@ require (
 	github.com/valllabh/ocsf-schema-golang v1.0.3
 	golang.org/x/net v0.40.0
 	golang.org/x/sync v0.14.0
+	golang.org/x/text v0.25.0
+	gopkg.in/yaml.v3 v3.0.1
 	rsc.io/binaryregexp v0.2.0
 )
 
@@ -6,6 +6,8 @@ package actions
 import (
 	"errors"
 	"fmt"
+	"maps"
+	"slices"
 	"strings"
 
 	"github.com/ad3n/seclang/experimental/plugins/plugintypes"
@@ -32,14 +34,22 @@ func Register(name string, a func() plugintypes.Action) {
 	actionmap[name] = a
 }
 
+// Names returns the sorted names of the registered actions
+func Names() []string {
+	return slices.Sorted(maps.Keys(actionmap))
+}
+
 func init() {
+	Register("active", active)
 	Register("allow", allow)
 	Register("auditlog", auditlog)
+	Register("ban", ban)
 	Register("block", block)
 	Register("capture", capture)
 	Register("chain", chain)
 	Register("ctl", ctl)
 	Register("deny", deny)
+	Register("denybody", denyBody)
 	Register("drop", drop)
 	Register("exec", exec)
 	Register("expirevar", expirevar)
@@ -56,6 +66,8 @@ func init() {
 	Register("phase", phase)
 	Register("redirect", redirect)
 	Register("rev", rev)
+	Register("rxBudget", rxBudget)
+	Register("sample", sample)
 	Register("setenv", setenv)
 	Register("setvar", setvar)
 	Register("severity", severity)
@@ -0,0 +1,53 @@
+// Copyright 2024 Juan Pablo Tosso and the OWASP Coraza contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package actions
+
+import (
+	"github.com/ad3n/seclang/experimental/plugins/plugintypes"
+	"github.com/ad3n/seclang/internal/corazawaf"
+)
+
+// Action Group: Metadata
+//
+// Description:
+// Restricts the evaluation of the rule to a time window. The window is composed of
+// an optional list of weekdays (`Mon`, `Mon-Fri`, `Sat,Sun`) and an optional time of day
+// range (`09:00-17:00`), at least one of them is required. Ranges like `22:00-06:00` span midnight.
+// Outside of the window the rule is skipped as if it was removed. If the action is used more than
+// once, the rule is evaluated when any of the windows matches.
+// Windows are evaluated against the transaction timestamp, the same used by the `TIME` variables.
+//
+// Example:
+// ```
+// # Deny access to the admin area outside business hours
+// SecRule REQUEST_FILENAME "@beginsWith /admin" "id:180,phase:1,deny,active:'Sat,Sun',active:'Sun-Thu 18:00-08:00',active:'Fri 18:00-24:00'"
+// ```
+type activeFn struct{}
+
+func (a *activeFn) Init(r plugintypes.RuleMetadata, data string) error {
+	if len(data) == 0 {
+		return ErrMissingArguments
+	}
+	w, err := corazawaf.ParseActiveWindow(data)
+	if err != nil {
+		return err
+	}
+	r.(*corazawaf.Rule).AddActiveWindow(w)
+	return nil
+}
+
+func (a *activeFn) Evaluate(_ plugintypes.RuleMetadata, _ plugintypes.TransactionState) {}
+
+func (a *activeFn) Type() plugintypes.ActionType {
+	return plugintypes.ActionTypeMetadata
+}
+
+func active() plugintypes.Action {
+	return &activeFn{}
+}
+
+var (
+	_ plugintypes.Action = &activeFn{}
+	_ ruleActionWrapper  = active
+)
@@ -0,0 +1,36 @@
+// Copyright 2024 Juan Pablo Tosso and the OWASP Coraza contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package actions
+
+import (
+	"testing"
+
+	"github.com/ad3n/seclang/internal/corazawaf"
+)
+
+func TestActiveInit(t *testing.T) {
+	for _, test := range []struct {
+		data          string
+		expectedError bool
+	}{
+		{"", true},
+		{"Mon-Fri", false},
+		{"09:00-17:00", false},
+		{"Mon-Fri 09:00-17:00", false},
+		{"Sat,Sun", false},
+		{"Funday", true},
+		{"25:00-26:00", true},
+		{"Mon 09:00", true},
+	} {
+		a := active()
+		r := corazawaf.NewRule()
+		err := a.Init(r, test.data)
+		if test.expectedError && err == nil {
+			t.Errorf("expected error for %q", test.data)
+		}
+		if !test.expectedError && err != nil {
+			t.Errorf("unexpected error for %q: %s", test.data, err.Error())
+		}
+	}
+}
@@ -0,0 +1,69 @@
+// Copyright 2024 Juan Pablo Tosso and the OWASP Coraza contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package actions
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/ad3n/seclang/experimental/plugins/plugintypes"
+	"github.com/ad3n/seclang/internal/corazawaf"
+)
+
+// Action Group: Non-disruptive
+//
+// Description:
+// Bans the client address (`REMOTE_ADDR`) for the given duration. Transactions from a banned client
+// are interrupted with status 403 before any rule of the request headers phase is evaluated, which is
+// cheaper than tracking clients with `setvar` and checking them with chained rules.
+// The duration uses the Go duration format (e.g. `30s`, `10m`, `1h`).
+// The action does not interrupt the current transaction, it is usually combined with a disruptive action.
+// Bans are kept in memory by default, connectors can configure a store shared between instances.
+//
+// Example:
+// ```
+// # Ban clients scanning for well known admin paths for one hour
+// SecRule REQUEST_FILENAME "@pm /wp-admin /phpmyadmin" "id:190,phase:1,deny,ban:duration=1h"
+// ```
+type banFn struct {
+	duration time.Duration
+}
+
+func (a *banFn) Init(_ plugintypes.RuleMetadata, data string) error {
+	if len(data) == 0 {
+		return ErrMissingArguments
+	}
+	key, value, ok := strings.Cut(data, "=")
+	if !ok || strings.TrimSpace(key) != "duration" {
+		return fmt.Errorf("invalid ban argument %q, expected duration=DURATION", data)
+	}
+	d, err := time.ParseDuration(strings.TrimSpace(value))
+	if err != nil {
+		return err
+	}
+	if d <= 0 {
+		return errors.New("ban duration must be positive")
+	}
+	a.duration = d
+	return nil
+}
+
+func (a *banFn) Evaluate(_ plugintypes.RuleMetadata, txS plugintypes.TransactionState) {
+	txS.(*corazawaf.Transaction).Ban(a.duration)
+}
+
+func (a *banFn) Type() plugintypes.ActionType {
+	return plugintypes.ActionTypeNondisruptive
+}
+
+func ban() plugintypes.Action {
+	return &banFn{}
+}
+
+var (
+	_ plugintypes.Action = &banFn{}
+	_ ruleActionWrapper  = ban
+)
@@ -0,0 +1,36 @@
+// Copyright 2024 Juan Pablo Tosso and the OWASP Coraza contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package actions
+
+import (
+	"testing"
+
+	"github.com/ad3n/seclang/internal/corazawaf"
+)
+
+func TestBanInit(t *testing.T) {
+	for _, test := range []struct {
+		data          string
+		expectedError bool
+	}{
+		{"", true},
+		{"duration=1h", false},
+		{"duration=30s", false},
+		{"1h", true},
+		{"duration=", true},
+		{"duration=abc", true},
+		{"duration=-1m", true},
+		{"time=1h", true},
+	} {
+		a := ban()
+		r := corazawaf.NewRule()
+		err := a.Init(r, test.data)
+		if test.expectedError && err == nil {
+			t.Errorf("expected error for %q", test.data)
+		}
+		if !test.expectedError && err != nil {
+			t.Errorf("unexpected error for %q: %s", test.data, err.Error())
+		}
+	}
+}
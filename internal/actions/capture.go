@@ -15,14 +15,19 @@ import (
 //
 // When used together with the regular expression operator `@rx`,
 // `capture` creates a copy of the regular expression and places them into the transaction variable collection.
-// Up to 10 captures will be copied on a successful pattern match, each with a name consisting of a digit from 0 to 9.
+// Up to 10 captures will be copied on a successful pattern match, each with a name consisting of a digit from 0 to 9,
+// see `SecCaptureLimit` to capture more.
 // The `TX.0` variable always contains the entire area that the regular expression matched.
 // All the other variables contain the captured values, in the order in which the capturing parentheses appear in the regular expression.
+// The named groups of `@rx` are also copied to the variable of their name, whatever the limit, e.g. `(?P<user>\w+)` to `TX.user`.
+// Unlike the numbered ones, they are not cleared when the next rule is evaluated.
 //
 // Example:
 // ```
 // SecRule REQUEST_BODY "^username=(\w{25,})" phase:2,capture,t:none,chain,id:105
 // SecRule TX:1 "(?:(?:a(dmin|nonymous)))"
+// SecRule REQUEST_BODY "^username=(?P<username>\w{25,})" phase:2,capture,t:none,chain,id:106
+// SecRule TX:username "(?:(?:a(dmin|nonymous)))"
 // ```
 type captureFn struct{}
 
@@ -6,11 +6,13 @@ package actions
 import (
 	"errors"
 	"fmt"
+	"slices"
 	"strconv"
 	"strings"
 
 	"github.com/ad3n/seclang/experimental/plugins/plugintypes"
 	"github.com/ad3n/seclang/internal/collections"
+	"github.com/ad3n/seclang/internal/corazatypes"
 	"github.com/ad3n/seclang/internal/corazawaf"
 	utils "github.com/ad3n/seclang/internal/strings"
 	"github.com/corazawaf/coraza/v3/debuglog"
@@ -68,8 +70,8 @@ const (
 // - `ruleRemoveTargetById`
 // - `ruleRemoveTargetByMsg`
 // - `ruleRemoveTargetByTag`
-// - `hashEngine` (**Not Supported in Coraza (TBI)**)
-// - `hashEnforcement` (**Not supported in Coraza (TBI)**)
+// - `hashEngine`
+// - `hashEnforcement`
 //
 // Here are some notes about the options:
 //
@@ -89,6 +91,21 @@ const (
 //  4. Option `forceRequestBodyVariable“ allows you to configure the `REQUEST_BODY` variable to be set when there is no request body processor configured.
 //     This allows for inspection of request bodies of unknown types.
 //
+//  5. Options `requestBodyAccess` and `responseBodyAccess` override SecRequestBodyAccess and SecResponseBodyAccess
+//     for the current transaction. They must be set before the body phase starts, e.g. `ctl:requestBodyAccess=Off` in phase 1
+//     skips buffering and processing of the request body of allow-listed endpoints. Connectors can do the same through
+//     `Transaction.SetRequestBodyAccess` and `Transaction.SetResponseBodyAccess`.
+//
+//  6. Options `requestBodyLimit` and `responseBodyLimit` override SecRequestBodyLimit and SecResponseBodyLimit
+//     for the current transaction, so endpoints identified in phase 1 can accept larger bodies. Larger limits are
+//     capped to SecRequestBodyLimitMax and SecResponseBodyLimitMax, which default to the configured limits.
+//
+//  7. Option `auditLogParts` either replaces the parts, e.g. `ctl:auditLogParts=ABCFHZ`, or adds parts to or
+//     removes parts from the ones of the transaction, e.g. `ctl:auditLogParts=+E` records the response body of
+//     the transactions matched by the rule only. The parts are validated when the rule is parsed, the mandatory
+//     parts A and Z and the parts D and G, which are not recorded, can't be added or removed. A warning is logged
+//     when a part is added to a transaction that can't record it, e.g. part E without response body access.
+//
 // Example:
 // ```
 // # Parse requests with Content-Type "text/xml" as XML
@@ -110,6 +127,9 @@ type ctlFn struct {
 func (a *ctlFn) Init(_ plugintypes.RuleMetadata, data string) error {
 	var err error
 	a.action, a.value, a.collection, a.colKey, err = parseCtl(data)
+	if err == nil && a.action == ctlAuditLogParts {
+		_, err = modifyAuditLogParts(nil, a.value)
+	}
 	return err
 }
 
@@ -167,7 +187,7 @@ func (a *ctlFn) Evaluate(_ plugintypes.RuleMetadata, txS plugintypes.Transaction
 		}
 		tx.AuditEngine = ae
 	case ctlAuditLogParts:
-		AuditLogParts, err := types.ParseAuditLogParts(a.value)
+		AuditLogParts, err := modifyAuditLogParts(tx.AuditLogParts, a.value)
 		if err != nil {
 			tx.DebugLogger().Error().
 				Str("ctl", "AuditLogParts").
@@ -176,6 +196,17 @@ func (a *ctlFn) Evaluate(_ plugintypes.RuleMetadata, txS plugintypes.Transaction
 				Msg("Invalid audit log part")
 			return
 		}
+		for _, part := range AuditLogParts {
+			if slices.Contains(tx.AuditLogParts, part) {
+				continue
+			}
+			if reason := unrecordedAuditLogPart(tx, part); reason != "" {
+				tx.DebugLogger().Warn().
+					Str("ctl", "AuditLogParts").
+					Str("part", string(part)).
+					Msg(reason)
+			}
+		}
 		tx.AuditLogParts = AuditLogParts
 	case ctlForceRequestBodyVariable:
 		val, ok := parseOnOff(a.value)
@@ -192,18 +223,15 @@ func (a *ctlFn) Evaluate(_ plugintypes.RuleMetadata, txS plugintypes.Transaction
 			Bool("value", val).
 			Msg("Forcing request body var")
 	case ctlRequestBodyAccess:
-		if tx.LastPhase() <= types.PhaseRequestHeaders {
-
-			val, ok := parseOnOff(a.value)
-			if !ok {
-				tx.DebugLogger().Error().
-					Str("ctl", "RequestBodyAccess").
-					Str("value", a.value).
-					Msg("Unknown toggle")
-				return
-			}
-			tx.RequestBodyAccess = val
-		} else {
+		val, ok := parseOnOff(a.value)
+		if !ok {
+			tx.DebugLogger().Error().
+				Str("ctl", "RequestBodyAccess").
+				Str("value", a.value).
+				Msg("Unknown toggle")
+			return
+		}
+		if err := tx.SetRequestBodyAccess(val); err != nil {
 			tx.DebugLogger().Warn().
 				Str("ctl", "RequestBodyAccess").
 				Msg("Cannot change request body access after request headers phase")
@@ -212,6 +240,9 @@ func (a *ctlFn) Evaluate(_ plugintypes.RuleMetadata, txS plugintypes.Transaction
 	case ctlRequestBodyLimit:
 		if tx.LastPhase() <= types.PhaseRequestHeaders {
 			limit, err := strconv.ParseInt(a.value, 10, 64)
+			if err == nil {
+				err = tx.SetRequestBodyLimit(limit)
+			}
 			if err != nil {
 				tx.DebugLogger().Error().
 					Str("ctl", "RequestBodyLimit").
@@ -220,7 +251,6 @@ func (a *ctlFn) Evaluate(_ plugintypes.RuleMetadata, txS plugintypes.Transaction
 					Msg("Invalid limit")
 				return
 			}
-			tx.RequestBodyLimit = limit
 		} else {
 			tx.DebugLogger().Warn().
 				Str("ctl", "RequestBodyLimit").
@@ -288,17 +318,15 @@ func (a *ctlFn) Evaluate(_ plugintypes.RuleMetadata, txS plugintypes.Transaction
 		}
 
 	case ctlResponseBodyAccess:
-		if tx.LastPhase() <= types.PhaseResponseHeaders {
-			val, ok := parseOnOff(a.value)
-			if !ok {
-				tx.DebugLogger().Error().
-					Str("ctl", "ResponseBodyAccess").
-					Str("value", a.value).
-					Msg("Unknown toggle")
-				return
-			}
-			tx.ResponseBodyAccess = val
-		} else {
+		val, ok := parseOnOff(a.value)
+		if !ok {
+			tx.DebugLogger().Error().
+				Str("ctl", "ResponseBodyAccess").
+				Str("value", a.value).
+				Msg("Unknown toggle")
+			return
+		}
+		if err := tx.SetResponseBodyAccess(val); err != nil {
 			tx.DebugLogger().Warn().
 				Str("ctl", "ResponseBodyAccess").
 				Msg("Cannot change response body access after response headers phase")
@@ -308,6 +336,9 @@ func (a *ctlFn) Evaluate(_ plugintypes.RuleMetadata, txS plugintypes.Transaction
 	case ctlResponseBodyLimit:
 		if tx.LastPhase() <= types.PhaseResponseHeaders {
 			limit, err := strconv.ParseInt(a.value, 10, 64)
+			if err == nil {
+				err = tx.SetResponseBodyLimit(limit)
+			}
 			if err != nil {
 				tx.DebugLogger().Error().
 					Str("ctl", "ResponseBodyLimit").
@@ -316,7 +347,6 @@ func (a *ctlFn) Evaluate(_ plugintypes.RuleMetadata, txS plugintypes.Transaction
 					Msg("Invalid limit")
 				return
 			}
-			tx.ResponseBodyLimit = limit
 		} else {
 			tx.DebugLogger().Warn().
 				Str("ctl", "ResponseBodyLimit").
@@ -353,9 +383,25 @@ func (a *ctlFn) Evaluate(_ plugintypes.RuleMetadata, txS plugintypes.Transaction
 			return
 		}
 	case ctlHashEngine:
-		// Not supported yet
+		val, ok := parseOnOff(a.value)
+		if !ok {
+			tx.DebugLogger().Error().
+				Str("ctl", "HashEngine").
+				Str("value", a.value).
+				Msg("Unknown toggle")
+			return
+		}
+		tx.HashEngine = val
 	case ctlHashEnforcement:
-		// Not supported yet
+		val, ok := parseOnOff(a.value)
+		if !ok {
+			tx.DebugLogger().Error().
+				Str("ctl", "HashEnforcement").
+				Str("value", a.value).
+				Msg("Unknown toggle")
+			return
+		}
+		tx.HashEnforcement = val
 	case ctlDebugLogLevel:
 		lvl, err := strconv.ParseInt(a.value, 10, 8)
 		if err != nil {
@@ -375,6 +421,61 @@ func (a *ctlFn) Type() plugintypes.ActionType {
 	return plugintypes.ActionTypeNondisruptive
 }
 
+// modifyAuditLogParts returns the audit log parts set by the value of
+// ctl:auditLogParts, either the full list of parts such as ABCFHZ, or the
+// parts to add to the current ones such as +E, or to remove such as -C
+func modifyAuditLogParts(current types.AuditLogParts, value string) (types.AuditLogParts, error) {
+	if value == "" || (value[0] != '+' && value[0] != '-') {
+		return types.ParseAuditLogParts(value)
+	}
+	modified := value[1:]
+	if modified == "" {
+		return nil, fmt.Errorf("invalid audit log parts %q, expected the parts to add or remove", value)
+	}
+	for _, p := range modified {
+		switch types.AuditLogPart(p) {
+		case 'A', 'Z':
+			return nil, fmt.Errorf("invalid audit log parts %q, parts A and Z are mandatory", value)
+		case types.AuditLogPartIntermediaryResponseHeaders, types.AuditLogPartResponseBody:
+			return nil, fmt.Errorf("invalid audit log parts %q, part %c is not recorded", value, p)
+		}
+	}
+	// the modified parts are validated as a full list
+	if _, err := types.ParseAuditLogParts("A" + modified + "Z"); err != nil {
+		return nil, fmt.Errorf("invalid audit log parts %q", value)
+	}
+
+	parts := make(types.AuditLogParts, 0, len(current)+len(modified))
+	for _, p := range current {
+		if value[0] == '+' || !strings.ContainsRune(modified, rune(p)) {
+			parts = append(parts, p)
+		}
+	}
+	if value[0] == '+' {
+		for _, p := range modified {
+			if !slices.Contains(parts, types.AuditLogPart(p)) {
+				parts = append(parts, types.AuditLogPart(p))
+			}
+		}
+		slices.Sort(parts)
+	}
+	return parts, nil
+}
+
+// unrecordedAuditLogPart returns why the part added to the audit log parts of
+// the transaction won't be recorded, empty if it will
+func unrecordedAuditLogPart(tx *corazawaf.Transaction, part types.AuditLogPart) string {
+	switch {
+	case tx.AuditEngine == types.AuditEngineOff:
+		return "Audit log part added while the audit engine is off"
+	case (part == types.AuditLogPartRequestBody || part == types.AuditLogPartRequestBodyAlternative) && !tx.RequestBodyAccess:
+		return "Audit log part added without request body access"
+	case part == types.AuditLogPartIntermediaryResponseBody && !tx.ResponseBodyAccess:
+		return "Audit log part added without response body access"
+	}
+	return ""
+}
+
 func parseCtl(data string) (ctlFunctionType, string, variables.RuleVariable, string, error) {
 	action, ctlVal, ok := strings.Cut(data, "=")
 	if !ok {
@@ -385,7 +486,7 @@ func parseCtl(data string) (ctlFunctionType, string, variables.RuleVariable, str
 	if ok {
 		colname, colkey, _ = strings.Cut(col, ":")
 	}
-	collection, _ := variables.Parse(strings.TrimSpace(colname))
+	collection, _ := corazatypes.ParseVariable(strings.TrimSpace(colname))
 	colkey = strings.ToLower(colkey)
 	var act ctlFunctionType
 	switch action {
@@ -54,6 +54,43 @@ func TestCtl(t *testing.T) {
 				}
 			},
 		},
+		"auditLogParts added": {
+			input: "auditLogParts=+EKB",
+			prepareTX: func(tx *corazawaf.Transaction) {
+				tx.AuditEngine = types.AuditEngineOn
+				tx.ResponseBodyAccess = true
+			},
+			checkTX: func(t *testing.T, tx *corazawaf.Transaction, logEntry string) {
+				if want, have := "BCEFHK", string(tx.AuditLogParts); want != have {
+					t.Errorf("Failed to add audit log parts, want %s, have %s", want, have)
+				}
+				if logEntry != "" {
+					t.Errorf("Unexpected log entry %q", logEntry)
+				}
+			},
+		},
+		"auditLogParts removed": {
+			input: "auditLogParts=-CHK",
+			checkTX: func(t *testing.T, tx *corazawaf.Transaction, logEntry string) {
+				if want, have := "BF", string(tx.AuditLogParts); want != have {
+					t.Errorf("Failed to remove audit log parts, want %s, have %s", want, have)
+				}
+			},
+		},
+		"auditLogParts added without body access": {
+			input: "auditLogParts=+E",
+			prepareTX: func(tx *corazawaf.Transaction) {
+				tx.AuditEngine = types.AuditEngineOn
+			},
+			checkTX: func(t *testing.T, tx *corazawaf.Transaction, logEntry string) {
+				if want, have := "BCEFH", string(tx.AuditLogParts); want != have {
+					t.Errorf("Failed to add audit log parts, want %s, have %s", want, have)
+				}
+				if wantToContain, have := "Audit log part added without response body access", logEntry; !strings.Contains(have, wantToContain) {
+					t.Errorf("Failed to log entry, want to contain %q, have %q", wantToContain, have)
+				}
+			},
+		},
 		"forceRequestBodyVariable incorrect": {
 			input: "forceRequestBodyVariable=X",
 			checkTX: func(t *testing.T, tx *corazawaf.Transaction, logEntry string) {
@@ -152,6 +189,30 @@ func TestCtl(t *testing.T) {
 				}
 			},
 		},
+		"hashEngine incorrect": {
+			input: "hashEngine=X",
+			checkTX: func(t *testing.T, tx *corazawaf.Transaction, logEntry string) {
+				if wantToContain, have := "Unknown toggle", logEntry; !strings.Contains(have, wantToContain) {
+					t.Errorf("Failed to log entry, want to contain %q, have %q", wantToContain, have)
+				}
+			},
+		},
+		"hashEngine successfully": {
+			input: "hashEngine=On",
+			checkTX: func(t *testing.T, tx *corazawaf.Transaction, logEntry string) {
+				if want, have := true, tx.HashEngine; want != have {
+					t.Errorf("Failed to set hashEngine, want %t, have %t", want, have)
+				}
+			},
+		},
+		"hashEnforcement successfully": {
+			input: "hashEnforcement=On",
+			checkTX: func(t *testing.T, tx *corazawaf.Transaction, logEntry string) {
+				if want, have := true, tx.HashEnforcement; want != have {
+					t.Errorf("Failed to set hashEnforcement, want %t, have %t", want, have)
+				}
+			},
+		},
 		"ruleEngine incorrect": {
 			input: "ruleEngine=X",
 			checkTX: func(t *testing.T, tx *corazawaf.Transaction, logEntry string) {
@@ -366,6 +427,31 @@ func TestCtl(t *testing.T) {
 	}
 }
 
+func TestCtlAuditLogPartsInit(t *testing.T) {
+	tests := []struct {
+		value string
+		valid bool
+	}{
+		{"ABCZ", true},
+		{"+E", true},
+		{"-CK", true},
+		{"BCZ", false},
+		{"ABXZ", false},
+		{"+", false},
+		{"+AE", false},
+		{"-Z", false},
+		{"+G", false},
+		{"-D", false},
+		{"+X", false},
+	}
+	for _, tt := range tests {
+		err := ctl().Init(corazawaf.NewRule(), "auditLogParts="+tt.value)
+		if want, have := tt.valid, err == nil; want != have {
+			t.Errorf("unexpected validation of %q, want valid %t, have %v", tt.value, want, err)
+		}
+	}
+}
+
 func TestParseCtl(t *testing.T) {
 	t.Run("invalid ctl", func(t *testing.T) {
 		ctl, _, _, _, err := parseCtl("invalid")
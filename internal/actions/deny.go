@@ -7,6 +7,7 @@ import (
 	"net/http"
 
 	"github.com/ad3n/seclang/experimental/plugins/plugintypes"
+	"github.com/ad3n/seclang/internal/corazawaf"
 	"github.com/corazawaf/coraza/v3/types"
 )
 
@@ -15,6 +16,7 @@ import (
 // Description:
 // Stops rule processing and intercepts transaction.
 // If status action is not used, deny action defaults to status 403.
+// The response body set with the denybody action is passed in the Data of the interruption.
 //
 // Example:
 // ```
@@ -42,10 +44,15 @@ func (a *denyFn) Evaluate(r plugintypes.RuleMetadata, tx plugintypes.Transaction
 	if status == noStatus {
 		status = http.StatusForbidden
 	}
+	var body string
+	if rule, ok := r.(*corazawaf.Rule); ok && rule.DisruptiveBody != nil {
+		body = rule.DisruptiveBody.Expand(tx)
+	}
 	tx.Interrupt(&types.Interruption{
 		Status: status,
 		RuleID: rid,
 		Action: "deny",
+		Data:   body,
 	})
 }
 
@@ -0,0 +1,73 @@
+// Copyright 2024 Juan Pablo Tosso and the OWASP Coraza contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package actions
+
+import (
+	"encoding/json"
+	"html"
+	"strings"
+
+	"github.com/ad3n/seclang/experimental/plugins/macro"
+	"github.com/ad3n/seclang/experimental/plugins/plugintypes"
+	"github.com/ad3n/seclang/internal/corazawaf"
+	utils "github.com/ad3n/seclang/internal/strings"
+)
+
+// Action Group: Data
+//
+// Description:
+// Specifies the response body of the interruptions triggered by the deny action of the rule,
+// connectors get it macro expanded in the Data field of the interruption. It allows rules to
+// return specific machine-readable errors without connector-side mapping tables. The double
+// quotes escaped to be part of the rule actions, e.g. \", are unescaped.
+//
+// The expanded values are escaped for the content of the body, as they may come from the
+// request: a body starting with { or [ is a JSON document and the values are escaped as the
+// content of JSON strings, other bodies are escaped as HTML text.
+//
+// Example:
+// ```
+// SecRule ARGS:id "!@rx ^[0-9]+$" "id:200,phase:1,deny,status:400,denybody:'{\"error\":\"E100\",\"rule\":%{RULE.id}}'"
+// ```
+type denyBodyFn struct{}
+
+func (a *denyBodyFn) Init(r plugintypes.RuleMetadata, data string) error {
+	data = strings.ReplaceAll(utils.MaybeRemoveQuotes(data), `\"`, `"`)
+	if len(data) == 0 {
+		return ErrMissingArguments
+	}
+
+	escape := html.EscapeString
+	if trimmed := strings.TrimSpace(data); strings.HasPrefix(trimmed, "{") || strings.HasPrefix(trimmed, "[") {
+		escape = escapeJSONString
+	}
+	body, err := macro.NewEscapedMacro(data, escape)
+	if err != nil {
+		return err
+	}
+	r.(*corazawaf.Rule).DisruptiveBody = body
+	return nil
+}
+
+// escapeJSONString escapes s to be the content of a JSON string, including
+// the HTML characters in case the body is rendered by a browser
+func escapeJSONString(s string) string {
+	b, _ := json.Marshal(s)
+	return string(b[1 : len(b)-1])
+}
+
+func (a *denyBodyFn) Evaluate(_ plugintypes.RuleMetadata, _ plugintypes.TransactionState) {}
+
+func (a *denyBodyFn) Type() plugintypes.ActionType {
+	return plugintypes.ActionTypeData
+}
+
+func denyBody() plugintypes.Action {
+	return &denyBodyFn{}
+}
+
+var (
+	_ plugintypes.Action = &denyBodyFn{}
+	_ ruleActionWrapper  = denyBody
+)
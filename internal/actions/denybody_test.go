@@ -0,0 +1,34 @@
+// Copyright 2024 Juan Pablo Tosso and the OWASP Coraza contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package actions
+
+import (
+	"testing"
+
+	"github.com/ad3n/seclang/internal/corazawaf"
+)
+
+func TestDenyBodyInit(t *testing.T) {
+	t.Run("no arguments", func(t *testing.T) {
+		a := denyBody()
+		if err := a.Init(nil, ""); err == nil || err != ErrMissingArguments {
+			t.Error("expected error ErrMissingArguments")
+		}
+	})
+
+	t.Run("with arguments", func(t *testing.T) {
+		a := denyBody()
+		r := &corazawaf.Rule{}
+		if err := a.Init(r, `'{\"error\":\"E100\"}'`); err != nil {
+			t.Error(err)
+		}
+
+		if r.DisruptiveBody == nil {
+			t.Fatal("expected body to be set")
+		}
+		if want, have := `{"error":"E100"}`, r.DisruptiveBody.String(); want != have {
+			t.Errorf("unexpected body, want %q, have %q", want, have)
+		}
+	})
+}
@@ -4,9 +4,13 @@
 package actions
 
 import (
+	"errors"
 	"strings"
 
+	"github.com/ad3n/seclang/experimental/plugins/macro"
 	"github.com/ad3n/seclang/experimental/plugins/plugintypes"
+	"github.com/ad3n/seclang/internal/corazatypes"
+	"github.com/corazawaf/coraza/v3/types/variables"
 )
 
 // Action Group: Non-disruptive
@@ -15,7 +19,8 @@ import (
 // Initializes a named persistent collection, either by loading data from storage or by creating a new collection in memory.
 // Collections are loaded into memory on-demand, when the initcol action is executed.
 // A collection will be persisted only if a change was made to it in the course of transaction processing.
-// See the `Persistent Storage` section for further details.
+// The supported collections are GLOBAL, IP, RESOURCE, SESSION and USER, their records expire after SecCollectionTimeout
+// unless they set their own TIMEOUT. See the `Persistent Storage` section for further details.
 //
 // Example:
 // ```
@@ -23,9 +28,14 @@ import (
 // SecAction "phase:1,id:116,nolog,pass,initcol:ip=%{REMOTE_ADDR}"
 // ```
 type initcolFn struct {
-	collection string
-	variable   byte
-	key        string
+	variable variables.RuleVariable
+	key      macro.Macro
+}
+
+// collectionInitializer is implemented by the transactions supporting the
+// persistent collections
+type collectionInitializer interface {
+	InitCollection(variable variables.RuleVariable, key string) error
 }
 
 func (a *initcolFn) Init(_ plugintypes.RuleMetadata, data string) error {
@@ -34,34 +44,34 @@ func (a *initcolFn) Init(_ plugintypes.RuleMetadata, data string) error {
 		return ErrInvalidKVArguments
 	}
 
-	a.collection = col
-	a.key = key
-	a.variable = 0x0
-	return nil
+	v, err := corazatypes.ParseVariable(col)
+	if err != nil {
+		return err
+	}
+	switch v {
+	case corazatypes.Global, corazatypes.IP, corazatypes.Resource, corazatypes.Session, corazatypes.User:
+	default:
+		return errors.New("invalid arguments, expected collection GLOBAL, IP, RESOURCE, SESSION or USER")
+	}
+	a.variable = v
+	a.key, err = macro.NewMacro(key)
+	return err
 }
 
-func (a *initcolFn) Evaluate(_ plugintypes.RuleMetadata, _ plugintypes.TransactionState) {
-	// tx.DebugLogger().Error().Msg("initcol was used but it's not supported", zap.Int("rule", r.Id))
-	/*
-		key := tx.MacroExpansion(a.key)
-		data := tx.WAF.Persistence.Get(a.variable, key)
-		if data == nil {
-			ts := time.Now().UnixNano()
-			tss := strconv.FormatInt(ts, 10)
-			tsstimeout := strconv.FormatInt(ts+(int64(tx.WAF.CollectionTimeout)*1000), 10)
-			data = map[string][]string{
-				"CREATE_TIME":      {tss},
-				"IS_NEW":           {"1"},
-				"KEY":              {key},
-				"LAST_UPDATE_TIME": {tss},
-				"TIMEOUT":          {tsstimeout},
-				"UPDATE_COUNTER":   {"0"},
-				"UPDATE_RATE":      {"0"},
-			}
-		}
-		tx.GetCollection(a.variable).SetData(data)
-		tx.PersistentCollections[a.variable] = key
-	*/
+func (a *initcolFn) Evaluate(r plugintypes.RuleMetadata, tx plugintypes.TransactionState) {
+	ci, ok := tx.(collectionInitializer)
+	if !ok {
+		tx.DebugLogger().Error().
+			Int("rule_id", r.ID()).
+			Msg("initcol was used but the transaction doesn't support persistent collections")
+		return
+	}
+	if err := ci.InitCollection(a.variable, a.key.Expand(tx)); err != nil {
+		tx.DebugLogger().Error().
+			Int("rule_id", r.ID()).
+			Err(err).
+			Msg("Failed to initialize collection")
+	}
 }
 
 func (a *initcolFn) Type() plugintypes.ActionType {
@@ -0,0 +1,50 @@
+// Copyright 2024 Juan Pablo Tosso and the OWASP Coraza contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package actions
+
+import (
+	"github.com/ad3n/seclang/experimental/plugins/plugintypes"
+	"github.com/ad3n/seclang/internal/corazawaf"
+)
+
+// Action Group: Non-disruptive
+//
+// Description:
+// Overrides the budget of the `@rx` operator of the rule set with `SecRxBudget`, e.g. to inspect
+// more of the values of a rule known to be fast or less of the ones of a costly rule. The budget
+// replaces the one of `SecRxBudget`, the limits it doesn't set are not enforced, and `off` removes them.
+// It has no effect on the other operators.
+//
+// Example:
+// ```
+// SecRule REQUEST_BODY "@rx <script" "id:1,phase:2,deny,rxBudget:'input=1048576 time=10ms'"
+// ```
+type rxBudgetFn struct{}
+
+func (a *rxBudgetFn) Init(r plugintypes.RuleMetadata, data string) error {
+	if len(data) == 0 {
+		return ErrMissingArguments
+	}
+	b, err := corazawaf.ParseRxBudget(data)
+	if err != nil {
+		return err
+	}
+	r.(*corazawaf.Rule).SetRxBudget(b)
+	return nil
+}
+
+func (a *rxBudgetFn) Evaluate(_ plugintypes.RuleMetadata, _ plugintypes.TransactionState) {}
+
+func (a *rxBudgetFn) Type() plugintypes.ActionType {
+	return plugintypes.ActionTypeNondisruptive
+}
+
+func rxBudget() plugintypes.Action {
+	return &rxBudgetFn{}
+}
+
+var (
+	_ plugintypes.Action = &rxBudgetFn{}
+	_ ruleActionWrapper  = rxBudget
+)
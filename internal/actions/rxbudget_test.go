@@ -0,0 +1,48 @@
+// Copyright 2024 Juan Pablo Tosso and the OWASP Coraza contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package actions
+
+import (
+	"testing"
+	"time"
+
+	"github.com/ad3n/seclang/internal/corazawaf"
+)
+
+func TestRxBudgetInit(t *testing.T) {
+	tests := []struct {
+		data    string
+		want    corazawaf.RxBudget
+		invalid bool
+	}{
+		{data: "input=1024 time=2ms", want: corazawaf.RxBudget{InputLimit: 1024, TimeLimit: 2 * time.Millisecond}},
+		{data: "input=1024", want: corazawaf.RxBudget{InputLimit: 1024}},
+		{data: "off", want: corazawaf.RxBudget{}},
+		{data: "", invalid: true},
+		{data: "input=-1", invalid: true},
+		{data: "steps=10", invalid: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.data, func(t *testing.T) {
+			r := corazawaf.NewRule()
+			err := rxBudget().Init(r, tt.data)
+			if tt.invalid {
+				if err == nil {
+					t.Error("expected error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			b, ok := r.RxBudget()
+			if !ok {
+				t.Fatal("expected the rule to override the budget")
+			}
+			if b != tt.want {
+				t.Errorf("unexpected budget, want %+v, have %+v", tt.want, b)
+			}
+		})
+	}
+}
@@ -0,0 +1,53 @@
+// Copyright 2024 Juan Pablo Tosso and the OWASP Coraza contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package actions
+
+import (
+	"github.com/ad3n/seclang/experimental/plugins/plugintypes"
+	"github.com/ad3n/seclang/internal/corazawaf"
+)
+
+// Action Group: Metadata
+//
+// Description:
+// Restricts the evaluation of the rule to a percentage of the transactions, e.g. to trial an
+// expensive or experimental rule on a fraction of the production traffic. The rate is a number
+// greater than 0 and at most 100, decimals are allowed down to 0.01. For the other transactions
+// the rule is skipped as if it was removed.
+// The transactions are selected by the hash of their unique ID, so the selection is deterministic:
+// a transaction sampled by a rule is sampled by all the rules with the same or a higher rate.
+//
+// Example:
+// ```
+// # Evaluate the new detection on 10% of the traffic, without blocking
+// SecRule ARGS "@rx (?i)new-attack-pattern" "id:190,phase:2,pass,log,sample:10,msg:'Trial detection'"
+// ```
+type sampleFn struct{}
+
+func (a *sampleFn) Init(r plugintypes.RuleMetadata, data string) error {
+	if len(data) == 0 {
+		return ErrMissingArguments
+	}
+	rate, err := corazawaf.ParseSampleRate(data)
+	if err != nil {
+		return err
+	}
+	r.(*corazawaf.Rule).SetSampleRate(rate)
+	return nil
+}
+
+func (a *sampleFn) Evaluate(_ plugintypes.RuleMetadata, _ plugintypes.TransactionState) {}
+
+func (a *sampleFn) Type() plugintypes.ActionType {
+	return plugintypes.ActionTypeMetadata
+}
+
+func sample() plugintypes.Action {
+	return &sampleFn{}
+}
+
+var (
+	_ plugintypes.Action = &sampleFn{}
+	_ ruleActionWrapper  = sample
+)
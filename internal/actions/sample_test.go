@@ -0,0 +1,36 @@
+// Copyright 2024 Juan Pablo Tosso and the OWASP Coraza contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package actions
+
+import (
+	"testing"
+
+	"github.com/ad3n/seclang/internal/corazawaf"
+)
+
+func TestSampleInit(t *testing.T) {
+	for _, test := range []struct {
+		data          string
+		expectedError bool
+	}{
+		{"", true},
+		{"10", false},
+		{"0.5%", false},
+		{"100", false},
+		{"0", true},
+		{"101", true},
+		{"-5", true},
+		{"ten", true},
+	} {
+		a := sample()
+		r := corazawaf.NewRule()
+		err := a.Init(r, test.data)
+		if test.expectedError && err == nil {
+			t.Errorf("expected error for %q", test.data)
+		}
+		if !test.expectedError && err != nil {
+			t.Errorf("unexpected error for %q: %s", test.data, err.Error())
+		}
+	}
+}
@@ -10,6 +10,7 @@ import (
 
 	"github.com/ad3n/seclang/experimental/plugins/macro"
 	"github.com/ad3n/seclang/experimental/plugins/plugintypes"
+	"github.com/ad3n/seclang/internal/corazatypes"
 	"github.com/corazawaf/coraza/v3/collection"
 	"github.com/corazawaf/coraza/v3/types/variables"
 )
@@ -33,6 +34,12 @@ import (
 // # Increase or decrease variable value, use + and - characters in front of a numerical value
 // `setvar:TX.score=+5`
 //
+// # Macros can provide a default value, used when the variable is not set
+// `setvar:TX.score=+%{TX.critical_anomaly_score|5}`
+//
+// # Persistent collections initialized with initcol can be set too
+// `setvar:IP.block_score=+1`
+//
 // # Example from OWASP CRS:
 //
 //	SecRule REQUEST_FILENAME|ARGS_NAMES|ARGS|XML:/* "\bsys\.user_catalog\b" \
@@ -74,15 +81,16 @@ func (a *setvarFn) Init(_ plugintypes.RuleMetadata, data string) error {
 	var err error
 	key, val, valOk := strings.Cut(data, "=")
 	colKey, colVal, colOk := strings.Cut(key, ".")
-	// Right not it only makes sense to allow setting TX
-	// key is also required
-	if strings.ToUpper(colKey) != "TX" {
-		return errors.New("invalid arguments, expected collection TX")
+	// Only TX and the persistent collections can be set, key is also required
+	switch strings.ToUpper(colKey) {
+	case "TX", "GLOBAL", "IP", "RESOURCE", "SESSION", "USER":
+	default:
+		return errors.New("invalid arguments, expected collection TX, GLOBAL, IP, RESOURCE, SESSION or USER")
 	}
 	if strings.TrimSpace(colVal) == "" {
 		return errors.New("invalid arguments, expected syntax TX.{key}={value}")
 	}
-	a.collection, err = variables.Parse(colKey)
+	a.collection, err = corazatypes.ParseVariable(colKey)
 	if err != nil {
 		return err
 	}
@@ -137,7 +145,9 @@ func (a *setvarFn) evaluateTxCollection(r plugintypes.RuleMetadata, tx plugintyp
 		return
 	}
 	currentVal := ""
-	if r := col.Get(key); len(r) > 0 {
+	if c, ok := col.(interface{ GetFirst(string) (string, bool) }); ok {
+		currentVal, _ = c.GetFirst(key)
+	} else if r := col.Get(key); len(r) > 0 {
 		currentVal = r[0]
 	}
 	var err error
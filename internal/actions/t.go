@@ -4,6 +4,9 @@
 package actions
 
 import (
+	"fmt"
+	"strings"
+
 	"github.com/ad3n/seclang/experimental/plugins/plugintypes"
 	"github.com/ad3n/seclang/internal/corazawaf"
 	"github.com/ad3n/seclang/internal/transformations"
@@ -31,11 +34,18 @@ func (a *tFn) Init(r plugintypes.RuleMetadata, data string) error {
 		return nil
 	}
 
+	rule := r.(*corazawaf.Rule)
+	// urlDecodeUni uses the unicode map configured with SecUnicodeMapFile, the
+	// code page is part of the name so the cache isn't shared with other mappings
+	if m := rule.UnicodeMap(); m != nil && strings.EqualFold(data, "urlDecodeUni") {
+		return rule.AddTransformation(fmt.Sprintf("%s@%d", data, m.CodePage()), transformations.URLDecodeUniWithMap(m))
+	}
+
 	tt, err := transformations.GetTransformation(data)
 	if err != nil {
 		return err
 	}
-	return r.(*corazawaf.Rule).AddTransformation(data, tt)
+	return rule.AddTransformation(data, tt)
 }
 
 func (a *tFn) Evaluate(_ plugintypes.RuleMetadata, _ plugintypes.TransactionState) {}
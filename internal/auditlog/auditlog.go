@@ -197,15 +197,27 @@ func (tr *TransactionResponse) Body() string {
 // TransactionProducer contains producer specific
 // information for debugging
 type TransactionProducer struct {
-	Connector_  string   `json:"connector"`
-	Version_    string   `json:"version"`
-	Server_     string   `json:"server"`
-	RuleEngine_ string   `json:"rule_engine"`
-	Stopwatch_  string   `json:"stopwatch"`
-	Rulesets_   []string `json:"rulesets"`
-}
-
-var _ plugintypes.AuditLogTransactionProducer = (*TransactionProducer)(nil)
+	Connector_ string `json:"connector"`
+	Version_   string `json:"version"`
+	// EngineName_ and EngineVersion_ identify the engine that produced the log
+	EngineName_    string   `json:"engine_name,omitempty"`
+	EngineVersion_ string   `json:"engine_version,omitempty"`
+	Server_        string   `json:"server"`
+	RuleEngine_    string   `json:"rule_engine"`
+	Stopwatch_     string   `json:"stopwatch"`
+	Rulesets_      []string `json:"rulesets"`
+	// Labels_ identify the WAF instance that produced the log
+	Labels_ map[string]string `json:"labels,omitempty"`
+	// RulesPerformanceInfo_ lists the rules that took longer than SecRulePerfTime
+	RulesPerformanceInfo_ string `json:"rules_performance_info,omitempty"`
+}
+
+var (
+	_ plugintypes.AuditLogTransactionProducer                 = (*TransactionProducer)(nil)
+	_ plugintypes.AuditLogTransactionProducerEngine           = (*TransactionProducer)(nil)
+	_ plugintypes.AuditLogTransactionProducerLabels           = (*TransactionProducer)(nil)
+	_ plugintypes.AuditLogTransactionProducerRulesPerformance = (*TransactionProducer)(nil)
+)
 
 func (tp *TransactionProducer) Connector() string {
 	if tp == nil {
@@ -223,6 +235,22 @@ func (tp *TransactionProducer) Version() string {
 	return tp.Version_
 }
 
+func (tp *TransactionProducer) EngineName() string {
+	if tp == nil {
+		return ""
+	}
+
+	return tp.EngineName_
+}
+
+func (tp *TransactionProducer) EngineVersion() string {
+	if tp == nil {
+		return ""
+	}
+
+	return tp.EngineVersion_
+}
+
 func (tp *TransactionProducer) Server() string {
 	if tp == nil {
 		return ""
@@ -255,6 +283,22 @@ func (tp *TransactionProducer) Rulesets() []string {
 	return tp.Rulesets_
 }
 
+func (tp *TransactionProducer) Labels() map[string]string {
+	if tp == nil {
+		return nil
+	}
+
+	return tp.Labels_
+}
+
+func (tp *TransactionProducer) RulesPerformanceInfo() string {
+	if tp == nil {
+		return ""
+	}
+
+	return tp.RulesPerformanceInfo_
+}
+
 // TransactionRequest contains request specific
 // information
 type TransactionRequest struct {
@@ -402,6 +446,9 @@ type MessageData struct {
 	Accuracy_ int                `json:"accuracy"`
 	Tags_     []string           `json:"tags"`
 	Raw_      string             `json:"raw"`
+	// Truncated_ is set when the rule reached the match limit and some of
+	// its matches were dropped
+	Truncated_ bool `json:"truncated,omitempty"`
 }
 
 var _ plugintypes.AuditLogMessageData = (*MessageData)(nil)
@@ -453,3 +500,9 @@ func (md *MessageData) Tags() []string {
 func (md *MessageData) Raw() string {
 	return md.Raw_
 }
+
+// Truncated returns true if the rule reached the match limit, some of its
+// matches are not logged
+func (md *MessageData) Truncated() bool {
+	return md.Truncated_
+}
@@ -112,7 +112,15 @@ func (nativeFormatter) Format(al plugintypes.AuditLog) ([]byte, error) {
 				}
 			}
 
-			_, _ = fmt.Fprintf(&res, "\nStopwatch: %s\nResponse-Body-Transformed: %s\nProducer: %s\nServer: %s", "", "", "", "")
+			p := al.Transaction().Producer()
+			server := ""
+			if p != nil {
+				server = p.Server()
+			}
+			_, _ = fmt.Fprintf(&res, "\nStopwatch: %s\nResponse-Body-Transformed: %s\nProducer: %s\nServer: %s", "", "", strings.Join(producerNames(p), "; "), server)
+			if info := producerRulesPerformanceInfo(p); info != "" {
+				_, _ = fmt.Fprintf(&res, "\nRules-Performance-Info: %s", info)
+			}
 		case types.AuditLogPartRulesMatched:
 			for _, alEntry := range al.Messages() {
 				res.WriteByte('\n')
@@ -125,6 +133,56 @@ func (nativeFormatter) Format(al plugintypes.AuditLog) ([]byte, error) {
 	return []byte(res.String()), nil
 }
 
+// producerNames lists the connector, the engine and the rulesets of a producer
+// as name/version pairs, it is shared by the formatters so that they identify
+// the producer the same way.
+func producerNames(p plugintypes.AuditLogTransactionProducer) []string {
+	if p == nil {
+		return nil
+	}
+	var names []string
+	if conn := p.Connector(); conn != "" {
+		if v := p.Version(); v != "" {
+			conn += "/" + v
+		}
+		names = append(names, conn)
+	}
+	if engine, v := producerEngine(p); engine != "" {
+		if v != "" {
+			engine += "/" + v
+		}
+		names = append(names, engine)
+	}
+	return append(names, p.Rulesets()...)
+}
+
+// producerLabels returns the labels of the WAF instance that produced the
+// log, nil if the producer doesn't report them
+func producerLabels(p plugintypes.AuditLogTransactionProducer) map[string]string {
+	if l, ok := p.(plugintypes.AuditLogTransactionProducerLabels); ok {
+		return l.Labels()
+	}
+	return nil
+}
+
+// producerRulesPerformanceInfo returns the slow rules of the transaction,
+// empty if the producer doesn't report them
+func producerRulesPerformanceInfo(p plugintypes.AuditLogTransactionProducer) string {
+	if r, ok := p.(plugintypes.AuditLogTransactionProducerRulesPerformance); ok {
+		return r.RulesPerformanceInfo()
+	}
+	return ""
+}
+
+// producerEngine returns the name and the version of the engine that produced
+// the log, they are empty if the producer doesn't identify it
+func producerEngine(p plugintypes.AuditLogTransactionProducer) (name string, version string) {
+	if e, ok := p.(plugintypes.AuditLogTransactionProducerEngine); ok {
+		return e.EngineName(), e.EngineVersion()
+	}
+	return "", ""
+}
+
 func (nativeFormatter) MIME() string {
 	return "application/x-coraza-auditlog-native"
 }
@@ -6,21 +6,135 @@ package auditlog
 import (
 	"encoding/json"
 	"fmt"
+	"reflect"
 	"strings"
 
+	"github.com/ad3n/seclang/auditlog"
 	"github.com/ad3n/seclang/experimental/plugins/plugintypes"
 )
 
+// jsonFormatter writes the entries of the versioned schema of the auditlog
+// package, the log is copied through its interface so the entries of any
+// implementation follow the schema
 type jsonFormatter struct{}
 
 func (jsonFormatter) Format(al plugintypes.AuditLog) ([]byte, error) {
-	jsdata, err := json.Marshal(al)
+	jsdata, err := json.Marshal(schemaEntry(al))
 	if err != nil {
 		return nil, err
 	}
 	return jsdata, nil
 }
 
+func schemaEntry(al plugintypes.AuditLog) *auditlog.Entry {
+	t := al.Transaction()
+	e := &auditlog.Entry{
+		SchemaVersion: auditlog.SchemaVersion,
+		Transaction: auditlog.Transaction{
+			Timestamp:       t.Timestamp(),
+			UnixTimestamp:   t.UnixTimestamp(),
+			ID:              t.ID(),
+			ClientIP:        t.ClientIP(),
+			ClientPort:      t.ClientPort(),
+			HostIP:          t.HostIP(),
+			HostPort:        t.HostPort(),
+			ServerID:        t.ServerID(),
+			HighestSeverity: t.HighestSeverity(),
+			IsInterrupted:   t.IsInterrupted(),
+		},
+	}
+	if t.HasRequest() {
+		r := t.Request()
+		e.Transaction.Request = &auditlog.Request{
+			Method:      r.Method(),
+			Protocol:    r.Protocol(),
+			URI:         r.URI(),
+			HTTPVersion: r.HTTPVersion(),
+			Headers:     r.Headers(),
+			Body:        r.Body(),
+			Length:      r.Length(),
+		}
+		// the arguments are not logged, the field is kept as it was written
+		// before the schema was versioned
+		if r.Args() != nil {
+			e.Transaction.Request.Args = map[string][]string{}
+		}
+		for _, f := range r.Files() {
+			e.Transaction.Request.Files = append(e.Transaction.Request.Files, auditlog.File{
+				Name: f.Name(),
+				Size: f.Size(),
+				Mime: f.Mime(),
+			})
+		}
+	}
+	if t.HasResponse() {
+		r := t.Response()
+		e.Transaction.Response = &auditlog.Response{
+			Protocol: r.Protocol(),
+			Status:   r.Status(),
+			Headers:  r.Headers(),
+			Body:     r.Body(),
+		}
+	}
+	if p := t.Producer(); !isNil(p) {
+		engine, engineVersion := producerEngine(p)
+		e.Transaction.Producer = &auditlog.Producer{
+			Connector:            p.Connector(),
+			Version:              p.Version(),
+			EngineName:           engine,
+			EngineVersion:        engineVersion,
+			Server:               p.Server(),
+			RuleEngine:           p.RuleEngine(),
+			Stopwatch:            p.Stopwatch(),
+			Rulesets:             p.Rulesets(),
+			Labels:               producerLabels(p),
+			RulesPerformanceInfo: producerRulesPerformanceInfo(p),
+		}
+	}
+	for _, m := range al.Messages() {
+		msg := auditlog.Message{
+			Actionset: m.Actionset(),
+			Message:   m.Message(),
+		}
+		// ErrorMessage is not part of the AuditLogMessage interface yet
+		if em, ok := m.(interface{ ErrorMessage() string }); ok {
+			msg.ErrorMessage = em.ErrorMessage()
+		}
+		if d := m.Data(); !isNil(d) {
+			msg.Data = &auditlog.MessageData{
+				File:     d.File(),
+				Line:     d.Line(),
+				ID:       d.ID(),
+				Rev:      d.Rev(),
+				Msg:      d.Msg(),
+				Data:     d.Data(),
+				Severity: d.Severity().Int(),
+				Ver:      d.Ver(),
+				Maturity: d.Maturity(),
+				Accuracy: d.Accuracy(),
+				Tags:     d.Tags(),
+				Raw:      d.Raw(),
+			}
+			// Truncated is not part of the AuditLogMessageData interface yet
+			if td, ok := d.(interface{ Truncated() bool }); ok {
+				msg.Data.Truncated = td.Truncated()
+			}
+		}
+		e.Messages = append(e.Messages, msg)
+	}
+	return e
+}
+
+// isNil returns whether v is nil or a nil pointer, the getters of Log return
+// its missing parts as nil pointers
+func isNil(v any) bool {
+	if v == nil {
+		return true
+	}
+	rv := reflect.ValueOf(v)
+	return rv.Kind() == reflect.Pointer && rv.IsNil()
+}
+
 func (jsonFormatter) MIME() string {
 	return "application/json; charset=utf-8"
 }
@@ -66,14 +180,9 @@ func (_ legacyJSONFormatter) Format(al plugintypes.AuditLog) ([]byte, error) {
 	}
 
 	if al.Transaction().Producer() != nil {
-		var producers []string
-		if conn := al.Transaction().Producer().Connector(); conn != "" {
-			producers = append(producers, conn)
-		}
-		producers = append(producers, al.Transaction().Producer().Rulesets()...)
 		al2.AuditData = &logLegacyData{
 			Stopwatch:  logLegacyStopwatch{},
-			Producer:   producers,
+			Producer:   producerNames(al.Transaction().Producer()),
 			EngineMode: al.Transaction().Producer().RuleEngine(),
 		}
 	}
@@ -5,8 +5,11 @@ package auditlog
 
 import (
 	"encoding/json"
+	"slices"
 	"strings"
 	"testing"
+
+	"github.com/ad3n/seclang/auditlog"
 )
 
 /*
@@ -68,4 +71,111 @@ func TestLegacyFormatter(t *testing.T) {
 	if legacyAl.AuditData.Messages[0] != "some message" {
 		t.Errorf("failed to match legacy formatter, \ngot: %s\nexpected: %s", legacyAl.AuditData.Messages[0], "some message")
 	}
+	if want, have := "some connector/1.2.3,seclang/v1.0.0,OWASP_CRS/4.0.0", strings.Join(legacyAl.AuditData.Producer, ","); want != have {
+		t.Errorf("failed to match legacy formatter producer, \ngot: %s\nexpected: %s", have, want)
+	}
+}
+
+func TestJSONFormatter(t *testing.T) {
+	al := createAuditLog()
+	f := &jsonFormatter{}
+	data, err := f.Format(al)
+	if err != nil {
+		t.Fatal(err)
+	}
+	e, err := auditlog.Unmarshal(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want, have := auditlog.SchemaVersion, e.SchemaVersion; want != have {
+		t.Errorf("unexpected schema version, want %q, have %q", want, have)
+	}
+	if want, have := "/test.php", e.Transaction.Request.URI; want != have {
+		t.Errorf("unexpected uri, want %q, have %q", want, have)
+	}
+	if want, have := "response header", e.Transaction.Response.Headers["some"][0]; want != have {
+		t.Errorf("unexpected response header, want %q, have %q", want, have)
+	}
+	if want, have := "OWASP_CRS/4.0.0", e.Transaction.Producer.Rulesets[0]; want != have {
+		t.Errorf("unexpected ruleset, want %q, have %q", want, have)
+	}
+	if want, have := "error message", e.Messages[0].ErrorMessage; want != have {
+		t.Errorf("unexpected error message, want %q, have %q", want, have)
+	}
+	if want, have := `SecAction "id:100"`, e.Messages[0].Data.Raw; want != have {
+		t.Errorf("unexpected raw rule, want %q, have %q", want, have)
+	}
+	if e.Messages[0].Data.Truncated {
+		t.Error("unexpected truncated message")
+	}
+
+	// the messages of the rules reaching the match limit are flagged
+	al.Messages_[0].(*Message).Data_.Truncated_ = true
+	if data, err = f.Format(al); err != nil {
+		t.Fatal(err)
+	}
+	if e, err = auditlog.Unmarshal(data); err != nil {
+		t.Fatal(err)
+	}
+	if !e.Messages[0].Data.Truncated {
+		t.Error("expected the message flagged as truncated")
+	}
+
+	// the entries remain readable as Log
+	var l Log
+	if err := json.Unmarshal(data, &l); err != nil {
+		t.Fatal(err)
+	}
+	if want, have := al.Transaction().ID(), l.Transaction().ID(); want != have {
+		t.Errorf("unexpected transaction id, want %q, have %q", want, have)
+	}
+}
+
+func TestJSONFormatterFields(t *testing.T) {
+	// the fields of the schema 1.0, they can't be renamed nor removed
+	// without increasing the major version
+	want := map[string][]string{
+		"":                     {"messages", "schema_version", "transaction"},
+		"transaction":          {"client_ip", "client_port", "highest_severity", "host_ip", "host_port", "id", "is_interrupted", "producer", "request", "response", "server_id", "timestamp", "unix_timestamp"},
+		"transaction.request":  {"args", "body", "files", "headers", "http_version", "length", "method", "protocol", "uri"},
+		"transaction.response": {"body", "headers", "protocol", "status"},
+		"transaction.producer": {"connector", "engine_name", "engine_version", "rule_engine", "rulesets", "server", "stopwatch", "version"},
+		"messages.0":           {"actionset", "data", "error_message", "message"},
+		"messages.0.data":      {"accuracy", "data", "file", "id", "line", "maturity", "msg", "raw", "rev", "severity", "tags", "ver"},
+	}
+
+	data, err := (&jsonFormatter{}).Format(createAuditLog())
+	if err != nil {
+		t.Fatal(err)
+	}
+	var entry any
+	if err := json.Unmarshal(data, &entry); err != nil {
+		t.Fatal(err)
+	}
+	for path, fields := range want {
+		v := entry
+		if path != "" {
+			for _, key := range strings.Split(path, ".") {
+				switch o := v.(type) {
+				case map[string]any:
+					v = o[key]
+				case []any:
+					v = o[0]
+				}
+			}
+		}
+		o, ok := v.(map[string]any)
+		if !ok {
+			t.Errorf("%q is not an object: %v", path, v)
+			continue
+		}
+		var have []string
+		for k := range o {
+			have = append(have, k)
+		}
+		slices.Sort(have)
+		if !slices.Equal(fields, have) {
+			t.Errorf("unexpected fields of %q, want %v, have %v", path, fields, have)
+		}
+	}
 }
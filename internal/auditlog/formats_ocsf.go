@@ -140,6 +140,8 @@ func (f ocsfFormatter) Format(al plugintypes.AuditLog) ([]byte, error) {
 
 	}
 
+	engine, engineVersion := producerEngine(al.Transaction().Producer())
+
 	// Populate the required fields for the WebRecourcesActivity
 	webResourcesActivity := application.WebResourcesActivity{
 		ActivityId:   enums.WEB_RESOURCES_ACTIVITY_ACTIVITY_ID_WEB_RESOURCES_ACTIVITY_ACTIVITY_ID_READ,
@@ -162,6 +164,8 @@ func (f ocsfFormatter) Format(al plugintypes.AuditLog) ([]byte, error) {
 			LogVersion:  al.Transaction().Producer().Version(),
 			LoggedTime:  time.Now().UnixMicro(),
 			Product: &objects.Product{
+				Name:       engine,
+				Version:    engineVersion,
 				VendorName: "OWASP Coraza Web Application Firewall",
 			},
 			Version: "1.2.0",
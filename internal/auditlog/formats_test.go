@@ -81,7 +81,7 @@ func TestNativeFormatter(t *testing.T) {
 		checkLine(t, lines, 15, "error message")
 		checkLine(t, lines, 16, "Stopwatch: ")
 		checkLine(t, lines, 17, "Response-Body-Transformed: ")
-		checkLine(t, lines, 18, "Producer: ")
+		checkLine(t, lines, 18, "Producer: some connector/1.2.3; seclang/v1.0.0; OWASP_CRS/4.0.0")
 		checkLine(t, lines, 19, "Server: ")
 		checkLine(t, lines, 20, mutateSeparator(separator, 'K'))
 		checkLine(t, lines, 22, `SecAction "id:100"`)
@@ -123,8 +123,11 @@ func createAuditLog() *Log {
 				Body_: "some response body",
 			},
 			Producer_: &TransactionProducer{
-				Connector_: "some connector",
-				Version_:   "1.2.3",
+				Connector_:     "some connector",
+				Version_:       "1.2.3",
+				EngineName_:    "seclang",
+				EngineVersion_: "v1.0.0",
+				Rulesets_:      []string{"OWASP_CRS/4.0.0"},
 			},
 		},
 		Messages_: []plugintypes.AuditLogMessage{
@@ -139,3 +142,20 @@ func createAuditLog() *Log {
 		},
 	}
 }
+
+// connectorProducer implements only the methods AuditLogTransactionProducer
+// requires, like the producers implemented outside the engine
+type connectorProducer struct{}
+
+func (connectorProducer) Connector() string  { return "some connector" }
+func (connectorProducer) Version() string    { return "1.2.3" }
+func (connectorProducer) Server() string     { return "" }
+func (connectorProducer) RuleEngine() string { return "" }
+func (connectorProducer) Stopwatch() string  { return "" }
+func (connectorProducer) Rulesets() []string { return []string{"OWASP_CRS/4.0.0"} }
+
+func TestProducerNamesWithoutEngine(t *testing.T) {
+	if want, have := "some connector/1.2.3; OWASP_CRS/4.0.0", strings.Join(producerNames(connectorProducer{}), "; "); want != have {
+		t.Errorf("unexpected producer, want %q, have %q", want, have)
+	}
+}
@@ -5,6 +5,8 @@ package auditlog
 
 import (
 	"fmt"
+	"maps"
+	"slices"
 	"strings"
 
 	"github.com/ad3n/seclang/experimental/plugins/plugintypes"
@@ -30,6 +32,11 @@ func RegisterWriter(name string, writer func() plugintypes.AuditLogWriter) {
 	writers[strings.ToLower(name)] = writer
 }
 
+// WriterNames returns the sorted names of the registered writers
+func WriterNames() []string {
+	return slices.Sorted(maps.Keys(writers))
+}
+
 // GetWriter returns a logger by name
 // It returns an error if it doesn't exist
 func GetWriter(name string) (plugintypes.AuditLogWriter, error) {
@@ -46,6 +53,11 @@ func RegisterFormatter(name string, f plugintypes.AuditLogFormatter) {
 	formatters[strings.ToLower(name)] = f
 }
 
+// FormatterNames returns the sorted names of the registered formatters
+func FormatterNames() []string {
+	return slices.Sorted(maps.Keys(formatters))
+}
+
 // GetFormatter returns a formatter by name
 // It returns an error if it doesn't exist
 func GetFormatter(name string) (plugintypes.AuditLogFormatter, error) {
@@ -5,6 +5,8 @@ package bodyprocessors
 
 import (
 	"fmt"
+	"maps"
+	"slices"
 	"strings"
 
 	"github.com/ad3n/seclang/experimental/plugins/plugintypes"
@@ -14,6 +16,8 @@ type bodyProcessorWrapper = func() plugintypes.BodyProcessor
 
 var processors = map[string]bodyProcessorWrapper{}
 
+var xmlEntityResolver plugintypes.XMLEntityResolver
+
 // RegisterBodyProcessor registers a body processor
 // by name. If the body processor is already registered,
 // it will be overwritten
@@ -21,6 +25,23 @@ func RegisterBodyProcessor(name string, fn func() plugintypes.BodyProcessor) {
 	processors[name] = fn
 }
 
+// Names returns the sorted names of the registered body processors
+func Names() []string {
+	return slices.Sorted(maps.Keys(processors))
+}
+
+// RegisterXMLEntityResolver registers the resolver of the external entities
+// of XML bodies. If a resolver is already registered, it will be overwritten
+func RegisterXMLEntityResolver(resolver plugintypes.XMLEntityResolver) {
+	xmlEntityResolver = resolver
+}
+
+// GetXMLEntityResolver returns the registered resolver of the external
+// entities of XML bodies, or nil if there is none
+func GetXMLEntityResolver() plugintypes.XMLEntityResolver {
+	return xmlEntityResolver
+}
+
 // GetBodyProcessor returns a body processor by name
 // If the body processor is not found, it returns an error
 func GetBodyProcessor(name string) (plugintypes.BodyProcessor, error) {
@@ -4,9 +4,11 @@
 package bodyprocessors
 
 import (
+	"encoding/base64"
 	"errors"
 	"fmt"
 	"io"
+	"io/fs"
 	"mime"
 	"mime/multipart"
 	"os"
@@ -15,10 +17,19 @@ import (
 	"github.com/ad3n/seclang/experimental/plugins/plugintypes"
 	"github.com/ad3n/seclang/internal/collections"
 	"github.com/ad3n/seclang/internal/environment"
+	"github.com/corazawaf/coraza/v3/collection"
 )
 
 type multipartBodyProcessor struct{}
 
+// multipartVariables is implemented by the transaction variables exposing
+// the multipart variables the TransactionVariables interface doesn't define
+type multipartVariables interface {
+	MultipartOriginalValues() collection.Map
+	MultipartCharsetError() collection.Single
+	MultipartFileLimitExceeded() collection.Single
+}
+
 func (mbp *multipartBodyProcessor) ProcessRequest(reader io.Reader, v plugintypes.TransactionVariables, options plugintypes.BodyProcessorOptions) error {
 	mimeType := options.Mime
 	storagePath := options.StoragePath
@@ -32,6 +43,7 @@ func (mbp *multipartBodyProcessor) ProcessRequest(reader io.Reader, v plugintype
 	}
 	mr := multipart.NewReader(reader, params["boundary"])
 	totalSize := int64(0)
+	stored := 0
 	filesCol := v.Files()
 	filesTmpNamesCol := v.FilesTmpNames()
 	fileSizesCol := v.FilesSizes()
@@ -54,27 +66,30 @@ func (mbp *multipartBodyProcessor) ProcessRequest(reader io.Reader, v plugintype
 				headersNames.Add(partName, fmt.Sprintf("%s: %s", key, value))
 			}
 		}
+		// multipart.Reader already decodes quoted-printable parts
+		var pr io.Reader = p
+		if strings.EqualFold(strings.TrimSpace(p.Header.Get("Content-Transfer-Encoding")), "base64") {
+			pr = base64.NewDecoder(base64.StdEncoding, p)
+		}
 		// if is a file
 		filename := originFileName(p)
 		if filename != "" {
 			var size int64
-			if environment.HasAccessToFS {
+			if environment.HasAccessToFS && (options.FileLimit == 0 || stored < options.FileLimit) {
 				// Only copy file to temp when not running in TinyGo
-				temp, err := os.CreateTemp(storagePath, "crzmp*")
-				if err != nil {
-					v.MultipartStrictError().(*collections.Single).Set("1")
-					return err
-				}
-				defer temp.Close()
-				sz, err := io.Copy(temp, p)
+				sz, name, err := storeFile(pr, storagePath, options.FileMode)
 				if err != nil {
 					v.MultipartStrictError().(*collections.Single).Set("1")
 					return err
 				}
 				size = sz
-				filesTmpNamesCol.Add("", temp.Name())
+				stored++
+				filesTmpNamesCol.Add("", name)
 			} else {
-				sz, err := io.Copy(io.Discard, p)
+				if mv, ok := v.(multipartVariables); ok && environment.HasAccessToFS {
+					mv.MultipartFileLimitExceeded().(*collections.Single).Set("1")
+				}
+				sz, err := io.Copy(io.Discard, pr)
 				if err != nil {
 					v.MultipartStrictError().(*collections.Single).Set("1")
 					return err
@@ -87,19 +102,45 @@ func (mbp *multipartBodyProcessor) ProcessRequest(reader io.Reader, v plugintype
 			filesNamesCol.Add("", p.FormName())
 		} else {
 			// if is a field
-			data, err := io.ReadAll(p)
+			data, err := io.ReadAll(pr)
 			if err != nil {
 				v.MultipartStrictError().(*collections.Single).Set("1")
 				return err
 			}
 			totalSize += int64(len(data))
-			postCol.Add(p.FormName(), string(data))
+			postCol.Add(partName, partValue(v, partName, p.Header.Get("Content-Type"), data))
 		}
 		filesCombinedSizeCol.(*collections.Single).Set(fmt.Sprintf("%d", totalSize))
 	}
 	return nil
 }
 
+// storeFile copies the file of a part to a new file in dir, using the
+// system temporary directory when dir is empty. The file mode is only
+// changed when mode is not 0, otherwise the file is only readable by
+// the owner.
+func storeFile(r io.Reader, dir string, mode fs.FileMode) (int64, string, error) {
+	f, err := os.CreateTemp(dir, "crzmp*")
+	if err != nil {
+		return 0, "", err
+	}
+	defer f.Close()
+	var size int64
+	if mode != 0 {
+		err = f.Chmod(mode)
+	}
+	if err == nil {
+		size, err = io.Copy(f, r)
+	}
+	if err != nil {
+		// the file is not in FILES_TMPNAMES yet, so it wouldn't be cleaned up
+		f.Close()
+		os.Remove(f.Name())
+		return 0, "", err
+	}
+	return size, f.Name(), nil
+}
+
 func (mbp *multipartBodyProcessor) ProcessResponse(_ io.Reader, _ plugintypes.TransactionVariables, options plugintypes.BodyProcessorOptions) error {
 	return nil
 }
@@ -108,6 +149,33 @@ var (
 	_ plugintypes.BodyProcessor = (*multipartBodyProcessor)(nil)
 )
 
+// partValue returns the value of a multipart field transcoded to UTF-8 according to
+// the charset of the part Content-Type. When transcoding changes the value, the original
+// is added to MULTIPART_ORIGINAL_VALUES:<name>. Fields using a charset that cannot be
+// transcoded are kept as is and MULTIPART_CHARSET_ERROR is set.
+func partValue(v plugintypes.TransactionVariables, name string, contentType string, data []byte) string {
+	if contentType == "" {
+		return string(data)
+	}
+	_, params, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return string(data)
+	}
+	value, ok := toUTF8(params["charset"], data)
+	mv, hasVariables := v.(multipartVariables)
+	if !hasVariables {
+		return value
+	}
+	if !ok {
+		mv.MultipartCharsetError().(*collections.Single).Set("1")
+		return value
+	}
+	if value != string(data) {
+		mv.MultipartOriginalValues().Add(name, string(data))
+	}
+	return value
+}
+
 // OriginFileName returns the filename parameter of the Part's Content-Disposition header.
 // This function is based on (multipart.Part).parseContentDisposition,
 // See https://go.googlesource.com/go/+/refs/tags/go1.17.9/src/mime/multipart/multipart.go#87
@@ -23,7 +23,12 @@ func (*urlencodedBodyProcessor) ProcessRequest(reader io.Reader, v plugintypes.T
 	}
 
 	b := buf.String()
-	values := urlutil.ParseQuery(b, '&')
+	var values map[string][]string
+	if options.FlattenArrayArguments {
+		values = urlutil.ParseQueryFlatten(b, '&')
+	} else {
+		values = urlutil.ParseQuery(b, '&')
+	}
 	argsCol := v.ArgsPost()
 	for k, vs := range values {
 		argsCol.Set(k, vs)
@@ -5,17 +5,51 @@ package bodyprocessors
 
 import (
 	"encoding/xml"
+	"fmt"
 	"io"
+	"maps"
+	"regexp"
 	"strings"
 
 	"github.com/ad3n/seclang/experimental/plugins/plugintypes"
+	"github.com/ad3n/seclang/internal/collections"
+	"github.com/ad3n/seclang/internal/xmlschema"
+	"github.com/corazawaf/coraza/v3/collection"
 )
 
 type xmlBodyProcessor struct {
 }
 
+// xmlExternalEntityVariable is implemented by the transaction variables
+// exposing XML_EXTERNAL_ENTITY
+type xmlExternalEntityVariable interface {
+	XMLExternalEntity() collection.Single
+}
+
+// requestXMLDocumentSetter is implemented by the transaction variables keeping
+// the decoded document of the XML bodies, so that the rules validating it
+// against an XML Schema don't decode the body again
+type requestXMLDocumentSetter interface {
+	SetRequestXMLDocument(doc *xmlschema.Document)
+}
+
 func (*xmlBodyProcessor) ProcessRequest(reader io.Reader, v plugintypes.TransactionVariables, options plugintypes.BodyProcessorOptions) error {
-	values, contents, err := readXML(reader)
+	var doc *xmlschema.DocumentBuilder
+	setter, ok := v.(requestXMLDocumentSetter)
+	if ok {
+		doc = &xmlschema.DocumentBuilder{}
+	}
+	values, contents, external, err := decodeXML(reader, options.XMLEntityResolver, doc)
+	if ok {
+		if err != nil {
+			doc.Fail(err)
+		}
+		setter.SetRequestXMLDocument(doc.Document())
+	}
+	if xv, ok := v.(xmlExternalEntityVariable); ok && external {
+		// rules can alert on XXE attempts, whether the entities are resolved or not
+		xv.XMLExternalEntity().(*collections.Single).Set("1")
+	}
 	if err != nil {
 		return err
 	}
@@ -29,9 +63,30 @@ func (*xmlBodyProcessor) ProcessResponse(reader io.Reader, v plugintypes.Transac
 	return nil
 }
 
+// xmlExternalEntityRegex finds the external entities declared in a DOCTYPE,
+// the submatches are the % of the parameter entities, the name, the public
+// identifier of the PUBLIC entities and the system identifier, both quoted,
+// and the notation of the unparsed entities, e.g. NDATA gif
+var xmlExternalEntityRegex = regexp.MustCompile(`<!ENTITY\s+(%\s+)?([^\s%>]+)\s+(?:SYSTEM|PUBLIC\s+("[^"]*"|'[^']*'))\s+("[^"]*"|'[^']*')(\s+NDATA\s+[^\s>]+)?\s*>`)
+
+// xmlExternalDTDRegex matches a DOCTYPE referencing an external DTD, the
+// submatches are the public identifier of PUBLIC DTDs and the system
+// identifier, both quoted
+var xmlExternalDTDRegex = regexp.MustCompile(`^DOCTYPE\s+[^\s\[>]+\s+(?:SYSTEM|PUBLIC\s+("[^"]*"|'[^']*'))\s+("[^"]*"|'[^']*')`)
+
 func readXML(reader io.Reader) ([]string, []string, error) {
+	attrs, content, _, err := decodeXML(reader, nil, nil)
+	return attrs, content, err
+}
+
+// decodeXML returns the attribute values and the text of the XML document, and
+// whether its DOCTYPE references an external DTD or declares external entities.
+// They are resolved with the resolver, and left unresolved when it is nil. The
+// tokens are added to doc unless it is nil.
+func decodeXML(reader io.Reader, resolver plugintypes.XMLEntityResolver, doc *xmlschema.DocumentBuilder) ([]string, []string, bool, error) {
 	var attrs []string
 	var content []string
+	var external bool
 	dec := xml.NewDecoder(reader)
 	dec.Strict = false
 	dec.AutoClose = xml.HTMLAutoClose
@@ -39,11 +94,14 @@ func readXML(reader io.Reader) ([]string, []string, error) {
 	for {
 		token, err := dec.Token()
 		if err != nil && err != io.EOF {
-			return nil, nil, err
+			return nil, nil, external, err
 		}
 		if token == nil {
 			break
 		}
+		if doc != nil {
+			doc.Token(token)
+		}
 		switch tok := token.(type) {
 		case xml.StartElement:
 			for _, attr := range tok.Attr {
@@ -53,9 +111,94 @@ func readXML(reader io.Reader) ([]string, []string, error) {
 			if c := strings.TrimSpace(string(tok)); c != "" {
 				content = append(content, c)
 			}
+		case xml.Directive:
+			entities, ext, err := doctypeEntities(string(tok), resolver)
+			external = external || ext
+			if err != nil {
+				return nil, nil, external, err
+			}
+			if len(entities) > 0 {
+				dec.Entity = entities
+			}
 		}
 	}
-	return attrs, content, nil
+	return attrs, content, external, nil
+}
+
+// doctypeEntities returns the HTML entities along with the external entities
+// declared in the directive, or in the external DTD it references, resolved with
+// the resolver. It returns nil entities if there is none or the resolver is nil,
+// and true if the directive references an external DTD or entities.
+func doctypeEntities(directive string, resolver plugintypes.XMLEntityResolver) (map[string]string, bool, error) {
+	if !strings.HasPrefix(directive, "DOCTYPE") {
+		return nil, false, nil
+	}
+	var external bool
+	declarations := directive
+	if m := xmlExternalDTDRegex.FindStringSubmatch(directive); m != nil {
+		external = true
+		if resolver == nil {
+			return nil, true, nil
+		}
+		dtd, err := resolver.ResolveEntity(unquoteXMLLiteral(m[1]), unquoteXMLLiteral(m[2]))
+		if err != nil {
+			return nil, true, fmt.Errorf("resolving XML DTD: %w", err)
+		}
+		// the declarations of the internal subset take precedence
+		declarations = dtd + declarations
+	}
+
+	if xmlExternalEntityRegex.MatchString(declarations) {
+		external = true
+	}
+	if !external || resolver == nil {
+		return nil, external, nil
+	}
+
+	// the parameter entities, e.g. <!ENTITY % dtd SYSTEM "uri"> %dtd;, include
+	// their content in the declarations where they are referenced. They are
+	// expanded once, the entities they declare aren't expanded again.
+	var parameters []string
+	for _, m := range xmlExternalEntityRegex.FindAllStringSubmatch(declarations, -1) {
+		if m[1] == "" {
+			continue
+		}
+		value, err := resolver.ResolveEntity(unquoteXMLLiteral(m[3]), unquoteXMLLiteral(m[4]))
+		if err != nil {
+			return nil, true, fmt.Errorf("resolving XML parameter entity %q: %w", m[2], err)
+		}
+		parameters = append(parameters, "%"+m[2]+";", value)
+	}
+	if len(parameters) > 0 {
+		declarations = strings.NewReplacer(parameters...).Replace(declarations)
+	}
+
+	var entities map[string]string
+	for _, m := range xmlExternalEntityRegex.FindAllStringSubmatch(declarations, -1) {
+		// the unparsed entities are only referenced by attributes of type
+		// ENTITY, their content isn't part of the document
+		if m[1] != "" || m[5] != "" {
+			continue
+		}
+		value, err := resolver.ResolveEntity(unquoteXMLLiteral(m[3]), unquoteXMLLiteral(m[4]))
+		if err != nil {
+			return nil, true, fmt.Errorf("resolving XML entity %q: %w", m[2], err)
+		}
+		if entities == nil {
+			entities = maps.Clone(xml.HTMLEntity)
+		}
+		entities[m[2]] = value
+	}
+	return entities, true, nil
+}
+
+// unquoteXMLLiteral removes the quotes of a literal, it returns an empty
+// string for the missing public identifiers
+func unquoteXMLLiteral(s string) string {
+	if len(s) < 2 {
+		return ""
+	}
+	return s[1 : len(s)-1]
 }
 
 var (
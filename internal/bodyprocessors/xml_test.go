@@ -5,6 +5,8 @@ package bodyprocessors
 
 import (
 	"bytes"
+	"errors"
+	"slices"
 	"testing"
 
 	"github.com/ad3n/seclang/internal/strings"
@@ -68,3 +70,120 @@ func TestXMLPayloadFlexibility(t *testing.T) {
 		t.Errorf("Expected 4 contents, got %d", len(contents))
 	}
 }
+
+type testEntityResolver map[string]string
+
+func (r testEntityResolver) ResolveEntity(publicID, systemID string) (string, error) {
+	v, ok := r[publicID+" "+systemID]
+	if !ok {
+		return "", errors.New("unknown entity")
+	}
+	return v, nil
+}
+
+func TestXMLExternalEntity(t *testing.T) {
+	xmldoc := `<?xml version="1.0"?>
+<!DOCTYPE foo [
+  <!ENTITY sys SYSTEM "file:///etc/hostname">
+  <!ENTITY pub PUBLIC "-//Test//EN" 'http://example.com/pub'>
+]>
+<foo><a>&sys;</a><b>&pub;</b><c>&amp;</c></foo>`
+	resolver := testEntityResolver{
+		" file:///etc/hostname":              "host",
+		"-//Test//EN http://example.com/pub": "public",
+	}
+
+	_, contents, external, err := decodeXML(bytes.NewReader([]byte(xmldoc)), resolver, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !external {
+		t.Error("expected external entities to be reported")
+	}
+	if want, have := []string{"host", "public", "&"}, contents; !slices.Equal(want, have) {
+		t.Errorf("unexpected contents, want %q, have %q", want, have)
+	}
+
+	_, contents, err = readXML(bytes.NewReader([]byte(xmldoc)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want, have := []string{"&sys;", "&pub;", "&"}, contents; !slices.Equal(want, have) {
+		t.Errorf("expected unresolved entities, want %q, have %q", want, have)
+	}
+
+	if _, _, _, err := decodeXML(bytes.NewReader([]byte(xmldoc)), testEntityResolver{}, nil); err == nil {
+		t.Error("expected error when the resolver fails")
+	}
+}
+
+func TestXMLExternalDTD(t *testing.T) {
+	xmldoc := `<?xml version="1.0"?>
+<!DOCTYPE foo SYSTEM "http://example.com/foo.dtd" [
+  <!ENTITY local SYSTEM "file:///local">
+]>
+<foo><a>&dtd;</a><b>&local;</b></foo>`
+	resolver := testEntityResolver{
+		" http://example.com/foo.dtd": `<!ENTITY dtd SYSTEM "file:///dtd"><!ENTITY local SYSTEM "file:///other">`,
+		" file:///dtd":                "from dtd",
+		" file:///local":              "from internal subset",
+		" file:///other":              "from dtd too",
+	}
+
+	_, contents, external, err := decodeXML(bytes.NewReader([]byte(xmldoc)), resolver, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !external {
+		t.Error("expected external DTD to be reported")
+	}
+	if want, have := []string{"from dtd", "from internal subset"}, contents; !slices.Equal(want, have) {
+		t.Errorf("unexpected contents, want %q, have %q", want, have)
+	}
+
+	tests := map[string]bool{
+		`<!DOCTYPE foo PUBLIC "-//Test//EN" "foo.dtd"><foo/>`:                              true,
+		`<!DOCTYPE foo [<!ENTITY x "internal">]><foo/>`:                                    false,
+		`<!DOCTYPE foo [<!ENTITY % p SYSTEM "http://example.com/p.dtd"> %p;]><foo/>`:       true,
+		`<!DOCTYPE foo [<!ENTITY % p "<!ENTITY x 'internal'>"> %p;]><foo/>`:                false,
+		`<!DOCTYPE foo [<!ENTITY img SYSTEM "http://example.com/a.gif" NDATA gif>]><foo/>`: true,
+		`<foo/>`: false,
+	}
+	for doc, want := range tests {
+		_, _, have, err := decodeXML(bytes.NewReader([]byte(doc)), nil, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want != have {
+			t.Errorf("unexpected external report for %q, want %t, have %t", doc, want, have)
+		}
+	}
+}
+
+func TestXMLExternalParameterEntity(t *testing.T) {
+	xmldoc := `<?xml version="1.0"?>
+<!DOCTYPE foo [
+  <!ENTITY % remote SYSTEM "http://example.com/remote.dtd">
+  %remote;
+]>
+<foo>&fromremote;</foo>`
+	resolver := testEntityResolver{
+		" http://example.com/remote.dtd": `<!ENTITY fromremote SYSTEM "file:///remote">`,
+		" file:///remote":                "from parameter entity",
+	}
+
+	_, contents, external, err := decodeXML(bytes.NewReader([]byte(xmldoc)), resolver, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !external {
+		t.Error("expected external parameter entity to be reported")
+	}
+	if want, have := []string{"from parameter entity"}, contents; !slices.Equal(want, have) {
+		t.Errorf("unexpected contents, want %q, have %q", want, have)
+	}
+
+	if _, _, _, err := decodeXML(bytes.NewReader([]byte(xmldoc)), testEntityResolver{}, nil); err == nil {
+		t.Error("expected error when the resolver fails")
+	}
+}
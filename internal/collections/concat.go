@@ -8,6 +8,7 @@ import (
 	"strings"
 
 	"github.com/ad3n/seclang/internal/corazarules"
+	"github.com/ad3n/seclang/internal/corazatypes"
 	"github.com/corazawaf/coraza/v3/collection"
 	"github.com/corazawaf/coraza/v3/types"
 	"github.com/corazawaf/coraza/v3/types/variables"
@@ -39,7 +40,7 @@ func (c *ConcatCollection) FindAll() []types.MatchData {
 
 // Name returns the name for the current CollectionconcatCollection
 func (c *ConcatCollection) Name() string {
-	return c.variable.Name()
+	return corazatypes.VariableName(c.variable)
 }
 
 // ConcatKeyed is a collection view over multiple keyed collections.
@@ -95,7 +96,7 @@ func (c *ConcatKeyed) FindAll() []types.MatchData {
 
 // Name returns the name for the current CollectionconcatCollection
 func (c *ConcatKeyed) Name() string {
-	return c.variable.Name()
+	return corazatypes.VariableName(c.variable)
 }
 
 // replaceVariable ensures a returned match references the variable of a concatenated variable,
@@ -0,0 +1,128 @@
+// Copyright 2024 Juan Pablo Tosso and the OWASP Coraza contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package collections
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/ad3n/seclang/internal/corazarules"
+	"github.com/ad3n/seclang/internal/corazatypes"
+	"github.com/corazawaf/coraza/v3/collection"
+	"github.com/corazawaf/coraza/v3/types"
+	"github.com/corazawaf/coraza/v3/types/variables"
+)
+
+// LazyMap is a read-only collection.Keyed whose values are derived from the
+// transaction when they are read, e.g. the segments of the path most
+// transactions don't inspect. The values are loaded once, the owner of the
+// collection invalidates them when what they are derived from changes.
+type LazyMap struct {
+	data   *Map
+	load   func(m *Map)
+	loaded bool
+}
+
+var _ collection.Keyed = &LazyMap{}
+
+// NewLazyMap creates a new LazyMap loading its values with load, the keys
+// are case insensitive
+func NewLazyMap(variable variables.RuleVariable, load func(m *Map)) *LazyMap {
+	return &LazyMap{
+		data: NewMap(variable),
+		load: load,
+	}
+}
+
+func (c *LazyMap) values() *Map {
+	if !c.loaded {
+		c.loaded = true
+		c.load(c.data)
+	}
+	return c.data
+}
+
+func (c *LazyMap) Get(key string) []string {
+	return c.values().Get(key)
+}
+
+// GetFirst returns the first value of the key and false if the key is not set
+func (c *LazyMap) GetFirst(key string) (string, bool) {
+	return c.values().GetFirst(key)
+}
+
+func (c *LazyMap) FindRegex(key *regexp.Regexp) []types.MatchData {
+	return c.values().FindRegex(key)
+}
+
+func (c *LazyMap) FindString(key string) []types.MatchData {
+	return c.values().FindString(key)
+}
+
+func (c *LazyMap) FindAll() []types.MatchData {
+	return c.values().FindAll()
+}
+
+func (c *LazyMap) Name() string {
+	return c.data.Name()
+}
+
+// Invalidate loads the values again when they are read
+func (c *LazyMap) Invalidate() {
+	c.data.Reset()
+	c.loaded = false
+}
+
+// Reset invalidates the values
+func (c *LazyMap) Reset() {
+	c.Invalidate()
+}
+
+func (c *LazyMap) Format(res *strings.Builder) {
+	c.values().Format(res)
+}
+
+func (c *LazyMap) String() string {
+	return c.values().String()
+}
+
+// LazySingle is a read-only collection.Single whose value is derived from
+// the transaction every time it is read, e.g. the duration of a phase
+type LazySingle struct {
+	variable variables.RuleVariable
+	get      func() string
+}
+
+var _ collection.Single = &LazySingle{}
+
+// NewLazySingle creates a new LazySingle returning the value of get
+func NewLazySingle(variable variables.RuleVariable, get func() string) *LazySingle {
+	return &LazySingle{
+		variable: variable,
+		get:      get,
+	}
+}
+
+func (c *LazySingle) Get() string {
+	return c.get()
+}
+
+func (c *LazySingle) FindAll() []types.MatchData {
+	return []types.MatchData{
+		&corazarules.MatchData{
+			Variable_: c.variable,
+			Value_:    c.get(),
+		},
+	}
+}
+
+func (c *LazySingle) Name() string {
+	return corazatypes.VariableName(c.variable)
+}
+
+func (c *LazySingle) Format(res *strings.Builder) {
+	res.WriteString(corazatypes.VariableName(c.variable))
+	res.WriteString(": ")
+	res.WriteString(c.get())
+}
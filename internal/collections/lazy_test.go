@@ -0,0 +1,51 @@
+// Copyright 2024 Juan Pablo Tosso and the OWASP Coraza contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package collections
+
+import (
+	"testing"
+
+	"github.com/corazawaf/coraza/v3/types/variables"
+)
+
+func TestLazyMap(t *testing.T) {
+	loads := 0
+	value := "bear"
+	c := NewLazyMap(variables.ArgsPath, func(m *Map) {
+		loads++
+		m.Set("animal", []string{value})
+	})
+
+	if want, have := "ARGS_PATH", c.Name(); want != have {
+		t.Errorf("want %s, have %s", want, have)
+	}
+	if want, have := 0, loads; want != have {
+		t.Errorf("unexpected loads before reading, want %d, have %d", want, have)
+	}
+
+	assertValuesMatch(t, c.FindString("ANIMAL"), "bear")
+	assertValuesMatch(t, c.FindAll(), "bear")
+	if want, have := 1, loads; want != have {
+		t.Errorf("unexpected loads, want %d, have %d", want, have)
+	}
+
+	value = "fox"
+	assertValuesMatch(t, c.FindAll(), "bear")
+	c.Invalidate()
+	assertValuesMatch(t, c.FindAll(), "fox")
+	if want, have := 2, loads; want != have {
+		t.Errorf("unexpected loads, want %d, have %d", want, have)
+	}
+}
+
+func TestLazySingle(t *testing.T) {
+	value := "bear"
+	c := NewLazySingle(variables.ArgsPath, func() string { return value })
+
+	if want, have := "bear", c.Get(); want != have {
+		t.Errorf("want %s, have %s", want, have)
+	}
+	value = "fox"
+	assertValuesMatch(t, c.FindAll(), "fox")
+}
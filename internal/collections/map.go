@@ -8,6 +8,7 @@ import (
 	"strings"
 
 	"github.com/ad3n/seclang/internal/corazarules"
+	"github.com/ad3n/seclang/internal/corazatypes"
 	"github.com/corazawaf/coraza/v3/collection"
 	"github.com/corazawaf/coraza/v3/types"
 	"github.com/corazawaf/coraza/v3/types/variables"
@@ -18,6 +19,8 @@ type Map struct {
 	isCaseSensitive bool
 	data            map[string][]keyValue
 	variable        variables.RuleVariable
+	// counter accounts the size of the keys and values, if set
+	counter *MemoryCounter
 }
 
 var _ collection.Map = &Map{}
@@ -58,6 +61,22 @@ func (c *Map) Get(key string) []string {
 	return result
 }
 
+// GetFirst returns the first value of the key and false if the key is not set.
+// Unlike Get, it doesn't copy the values so it doesn't allocate.
+func (c *Map) GetFirst(key string) (string, bool) {
+	if len(c.data) == 0 {
+		return "", false
+	}
+	if !c.isCaseSensitive {
+		key = strings.ToLower(key)
+	}
+	values := c.data[key]
+	if len(values) == 0 {
+		return "", false
+	}
+	return values[0].value, true
+}
+
 // FindRegex returns all map elements whose key matches the regular expression.
 func (c *Map) FindRegex(key *regexp.Regexp) []types.MatchData {
 	var result []types.MatchData
@@ -122,6 +141,9 @@ func (c *Map) Add(key string, value string) {
 		key = strings.ToLower(key)
 	}
 	c.data[key] = append(c.data[key], aVal)
+	if c.counter != nil {
+		c.counter.Add(len(aVal.key) + len(value))
+	}
 }
 
 // Sets the value of a key with the array of strings passed. If the key already exists, it will be overwritten.
@@ -131,6 +153,13 @@ func (c *Map) Set(key string, values []string) {
 		key = strings.ToLower(key)
 	}
 	dataSlice, exists := c.data[key]
+	if c.counter != nil {
+		delta := -sizeOf(dataSlice)
+		for _, v := range values {
+			delta += len(originalKey) + len(v)
+		}
+		c.counter.Add(delta)
+	}
 	if !exists || cap(dataSlice) < len(values) {
 		dataSlice = make([]keyValue, len(values))
 	} else {
@@ -150,6 +179,13 @@ func (c *Map) SetIndex(key string, index int, value string) {
 	}
 	values := c.data[key]
 	av := keyValue{key: originalKey, value: value}
+	if c.counter != nil {
+		delta := len(originalKey) + len(value)
+		if index < len(values) {
+			delta -= len(values[index].key) + len(values[index].value)
+		}
+		c.counter.Add(delta)
+	}
 
 	switch {
 	case len(values) == 0:
@@ -169,16 +205,22 @@ func (c *Map) Remove(key string) {
 	if len(c.data) == 0 {
 		return
 	}
+	if c.counter != nil {
+		c.counter.Add(-sizeOf(c.data[key]))
+	}
 	delete(c.data, key)
 }
 
 // Name returns the name of the map/collection.
 func (c *Map) Name() string {
-	return c.variable.Name()
+	return corazatypes.VariableName(c.variable)
 }
 
 // Reset removes all key/value pairs from the map.
 func (c *Map) Reset() {
+	if c.counter != nil {
+		c.counter.Add(-c.Size())
+	}
 	for k := range c.data {
 		delete(c.data, k)
 	}
@@ -186,7 +228,7 @@ func (c *Map) Reset() {
 
 // Format updates the passed strings.Builder with the formatted map key/values.
 func (c *Map) Format(res *strings.Builder) {
-	res.WriteString(c.variable.Name())
+	res.WriteString(corazatypes.VariableName(c.variable))
 	res.WriteString(":\n")
 	for k, v := range c.data {
 		res.WriteString("    ")
@@ -214,6 +256,31 @@ func (c *Map) Len() int {
 	return len(c.data)
 }
 
+// Size returns the sum of the lengths of the keys and values in the map.
+func (c *Map) Size() int {
+	size := 0
+	for _, data := range c.data {
+		size += sizeOf(data)
+	}
+	return size
+}
+
+// SetMemoryCounter accounts the size of the keys and values of the map in
+// counter from now on, including the ones already in the map
+func (c *Map) SetMemoryCounter(counter *MemoryCounter) {
+	c.counter = counter
+	counter.Add(c.Size())
+}
+
+// sizeOf returns the sum of the lengths of the keys and values
+func sizeOf(data []keyValue) int {
+	size := 0
+	for _, d := range data {
+		size += len(d.key) + len(d.value)
+	}
+	return size
+}
+
 // keyValue stores the case preserved original key and value
 // of the variable
 type keyValue struct {
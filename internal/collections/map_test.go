@@ -107,6 +107,43 @@ func TestNewCaseSensitiveKeyMap(t *testing.T) {
 
 }
 
+func TestGetFirst(t *testing.T) {
+	c := NewMap(variables.RequestHeaders)
+	c.Add("Key", "value1")
+	c.Add("key", "value2")
+
+	if v, ok := c.GetFirst("KEY"); !ok || v != "value1" {
+		t.Errorf("want %q, have %q (found %t)", "value1", v, ok)
+	}
+	if v, ok := c.GetFirst("missing"); ok || v != "" {
+		t.Errorf("want missing key, have %q (found %t)", v, ok)
+	}
+
+	if allocs := testing.AllocsPerRun(100, func() {
+		c.GetFirst("key")
+	}); allocs != 0 {
+		t.Errorf("want no allocations, have %v", allocs)
+	}
+}
+
+func BenchmarkGetFirst(b *testing.B) {
+	c := NewMap(variables.RequestHeaders)
+	c.Add("Content-Type", "application/json")
+
+	b.Run("Get", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			_ = c.Get("content-type")[0]
+		}
+	})
+	b.Run("GetFirst", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			c.GetFirst("content-type")
+		}
+	})
+}
+
 func BenchmarkTxSetGet(b *testing.B) {
 	keys := make(map[int]string, b.N)
 	for i := 0; i < b.N; i++ {
@@ -128,3 +165,29 @@ func BenchmarkTxSetGet(b *testing.B) {
 	})
 	b.ReportAllocs()
 }
+
+func TestMapMemoryCounter(t *testing.T) {
+	m := NewMap(variables.ArgsGet)
+	m.Add("a", "1")
+	counter := &MemoryCounter{}
+	m.SetMemoryCounter(counter)
+
+	steps := []struct {
+		name  string
+		apply func()
+	}{
+		{"add", func() { m.Add("Key", "value") }},
+		{"add to key", func() { m.Add("key", "v") }},
+		{"set", func() { m.Set("key", []string{"longer value"}) }},
+		{"set index", func() { m.SetIndex("key", 0, "x") }},
+		{"append index", func() { m.SetIndex("key", 5, "yy") }},
+		{"remove", func() { m.Remove("a") }},
+		{"reset", func() { m.Reset() }},
+	}
+	for _, s := range steps {
+		s.apply()
+		if want, have := int64(m.Size()), counter.Usage(); want != have {
+			t.Errorf("unexpected usage after %s, want %d, have %d", s.name, want, have)
+		}
+	}
+}
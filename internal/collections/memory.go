@@ -0,0 +1,26 @@
+// Copyright 2024 Juan Pablo Tosso and the OWASP Coraza contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package collections
+
+// MemoryCounter accounts the approximate memory used by the collections it
+// is set on, in bytes, as their keys and values are added and removed, so
+// the memory of a transaction is known without walking its collections
+type MemoryCounter struct {
+	usage int64
+}
+
+// Add adds delta bytes to the usage, delta is negative when data is removed
+func (m *MemoryCounter) Add(delta int) {
+	m.usage += int64(delta)
+}
+
+// Usage returns the bytes accounted
+func (m *MemoryCounter) Usage() int64 {
+	return m.usage
+}
+
+// Reset sets the usage to 0
+func (m *MemoryCounter) Reset() {
+	m.usage = 0
+}
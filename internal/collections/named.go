@@ -6,17 +6,23 @@ package collections
 import (
 	"fmt"
 	"regexp"
+	"slices"
 	"strings"
 
 	"github.com/ad3n/seclang/internal/corazarules"
+	"github.com/ad3n/seclang/internal/corazatypes"
 	"github.com/corazawaf/coraza/v3/collection"
 	"github.com/corazawaf/coraza/v3/types"
 	"github.com/corazawaf/coraza/v3/types/variables"
 )
 
-// NamedCollection is a Collection that also keeps track of names.
+// NamedCollection is a Collection that also keeps track of names. The names
+// are listed in the order their key was first added, e.g. the names of the
+// request headers in the order they were received.
 type NamedCollection struct {
 	*Map
+	// keys are the keys of the map in insertion order
+	keys []string
 }
 
 var _ collection.Map = &NamedCollection{}
@@ -35,11 +41,13 @@ func NewNamedCollection(rv variables.RuleVariable) *NamedCollection {
 
 // Add a value to some key
 func (c *NamedCollection) Add(key string, value string) {
+	c.track(key)
 	c.Map.Add(key, value)
 }
 
 // Set will replace the key's value with this slice
 func (c *NamedCollection) Set(key string, values []string) {
+	c.track(key)
 	c.Map.Set(key, values)
 }
 
@@ -47,12 +55,30 @@ func (c *NamedCollection) Set(key string, values []string) {
 // If the index is higher than the current size of the CollectionMap
 // it will be appended
 func (c *NamedCollection) SetIndex(key string, index int, value string) {
+	c.track(key)
 	c.Map.SetIndex(key, index, value)
 }
 
 // Remove deletes the key from the CollectionMap
 func (c *NamedCollection) Remove(key string) {
 	c.Map.Remove(key)
+	key = c.normalize(key)
+	c.keys = slices.DeleteFunc(c.keys, func(k string) bool { return k == key })
+}
+
+// track records the key if it is not in the map yet
+func (c *NamedCollection) track(key string) {
+	key = c.normalize(key)
+	if _, ok := c.Map.data[key]; !ok {
+		c.keys = append(c.keys, key)
+	}
+}
+
+func (c *NamedCollection) normalize(key string) string {
+	if c.Map.isCaseSensitive {
+		return key
+	}
+	return strings.ToLower(key)
 }
 
 func (c *NamedCollection) Len() int {
@@ -78,6 +104,7 @@ func (c *NamedCollection) Name() string {
 
 func (c *NamedCollection) Reset() {
 	c.Map.Reset()
+	c.keys = c.keys[:0]
 }
 
 func (c *NamedCollection) Names(rv variables.RuleVariable) collection.Keyed {
@@ -103,11 +130,11 @@ type NamedCollectionNames struct {
 func (c *NamedCollectionNames) FindRegex(key *regexp.Regexp) []types.MatchData {
 	var res []types.MatchData
 
-	for k, data := range c.collection.Map.data {
+	for _, k := range c.collection.keys {
 		if !key.MatchString(k) {
 			continue
 		}
-		for _, d := range data {
+		for _, d := range c.collection.Map.data[k] {
 			res = append(res, &corazarules.MatchData{
 				Variable_: c.variable,
 				Key_:      d.key,
@@ -121,11 +148,11 @@ func (c *NamedCollectionNames) FindRegex(key *regexp.Regexp) []types.MatchData {
 func (c *NamedCollectionNames) FindString(key string) []types.MatchData {
 	var res []types.MatchData
 
-	for k, data := range c.collection.Map.data {
+	for _, k := range c.collection.keys {
 		if k != key {
 			continue
 		}
-		for _, d := range data {
+		for _, d := range c.collection.Map.data[k] {
 			res = append(res, &corazarules.MatchData{
 				Variable_: c.variable,
 				Key_:      d.key,
@@ -144,8 +171,8 @@ func (c *NamedCollectionNames) FindAll() []types.MatchData {
 	var res []types.MatchData
 	// Iterates over all the data in the map and adds the key element also to the Key field (The key value may be the value
 	//  that is matched, but it is still also the key of the pair and it is needed to print the matched var name)
-	for _, data := range c.collection.Map.data {
-		for _, d := range data {
+	for _, k := range c.collection.keys {
+		for _, d := range c.collection.Map.data[k] {
 			res = append(res, &corazarules.MatchData{
 				Variable_: c.variable,
 				Key_:      d.key,
@@ -157,16 +184,16 @@ func (c *NamedCollectionNames) FindAll() []types.MatchData {
 }
 
 func (c *NamedCollectionNames) Name() string {
-	return c.variable.Name()
+	return corazatypes.VariableName(c.variable)
 }
 
 func (c *NamedCollectionNames) String() string {
 	res := strings.Builder{}
-	res.WriteString(c.variable.Name())
+	res.WriteString(corazatypes.VariableName(c.variable))
 	res.WriteString(": ")
 	firstOccurrence := true
-	for _, data := range c.collection.Map.data {
-		for _, d := range data {
+	for _, k := range c.collection.keys {
+		for _, d := range c.collection.Map.data[k] {
 			if !firstOccurrence {
 				res.WriteString(",")
 			}
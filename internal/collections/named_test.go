@@ -6,6 +6,7 @@ package collections
 import (
 	"fmt"
 	"regexp"
+	"slices"
 	"testing"
 
 	"github.com/corazawaf/coraza/v3/types/variables"
@@ -123,3 +124,31 @@ func TestNames(t *testing.T) {
 		t.Errorf("Error finding nonexistent regex, got %d instead of 0", len(r))
 	}
 }
+
+func TestNamesOrder(t *testing.T) {
+	c := NewNamedCollection(variables.RequestHeaders)
+	names := c.Names(variables.RequestHeadersNames)
+	for _, h := range []string{"Host", "User-Agent", "Accept", "Cookie", "accept", "Referer"} {
+		c.Add(h, "value")
+	}
+	c.Remove("cookie")
+	c.Set("Referer", []string{"value"})
+	c.Set("DNT", []string{"1"})
+
+	var have []string
+	for _, md := range names.FindAll() {
+		have = append(have, md.Value())
+	}
+	if want := []string{"Host", "User-Agent", "Accept", "accept", "Referer", "DNT"}; !slices.Equal(want, have) {
+		t.Errorf("unexpected names, want %q, have %q", want, have)
+	}
+	if want, have := "REQUEST_HEADERS_NAMES: Host,User-Agent,Accept,accept,Referer,DNT", fmt.Sprint(names); want != have {
+		t.Errorf("unexpected string, want %q, have %q", want, have)
+	}
+
+	c.Reset()
+	c.Add("Accept", "value")
+	if want, have := "REQUEST_HEADERS_NAMES: Accept", fmt.Sprint(names); want != have {
+		t.Errorf("unexpected string after reset, want %q, have %q", want, have)
+	}
+}
@@ -0,0 +1,190 @@
+// Copyright 2024 Juan Pablo Tosso and the OWASP Coraza contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package collections
+
+import (
+	"context"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultCollectionTimeout is the time to live of the persistent collection
+// records when SecCollectionTimeout is not configured
+const DefaultCollectionTimeout = 3600 * time.Second
+
+// persistentKey identifies a record, e.g. the IP collection of 10.0.0.1
+type persistentKey struct {
+	collection string
+	key        string
+}
+
+// persistentRecord is a record of a persistent collection
+type persistentRecord struct {
+	data    map[string][]string
+	created time.Time
+	updated time.Time
+	timeout time.Duration
+	counter int
+}
+
+func (r *persistentRecord) expired(now time.Time) bool {
+	return !now.Before(r.updated.Add(r.timeout))
+}
+
+// PersistentStore keeps the records of the persistent collections, like IP or
+// SESSION, across transactions. A record expires once it is not updated within
+// its timeout, expired records are removed when they are read and by Collect.
+// It is safe for concurrent use.
+type PersistentStore struct {
+	mu      sync.Mutex
+	records map[persistentKey]*persistentRecord
+	// Timeout is the time to live of the records that don't set their own
+	// TIMEOUT, the records created by initcol set the one configured with
+	// SecCollectionTimeout
+	Timeout time.Duration
+	// Clock returns the current time
+	Clock func() time.Time
+}
+
+// NewPersistentStore returns an empty store using DefaultCollectionTimeout
+func NewPersistentStore() *PersistentStore {
+	return &PersistentStore{
+		records: map[persistentKey]*persistentRecord{},
+		Timeout: DefaultCollectionTimeout,
+		Clock:   time.Now,
+	}
+}
+
+// Get returns a copy of the record of the collection, including the
+// CREATE_TIME, KEY, LAST_UPDATE_TIME, TIMEOUT and UPDATE_COUNTER fields.
+// It returns false if the record doesn't exist or expired.
+func (s *PersistentStore) Get(collection string, key string) (map[string][]string, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.getLocked(persistentKey{strings.ToLower(collection), key}, s.Clock())
+}
+
+func (s *PersistentStore) getLocked(k persistentKey, now time.Time) (map[string][]string, bool) {
+	r, ok := s.records[k]
+	if !ok {
+		return nil, false
+	}
+	if r.expired(now) {
+		delete(s.records, k)
+		return nil, false
+	}
+
+	data := make(map[string][]string, len(r.data)+5)
+	for field, values := range r.data {
+		data[field] = append([]string(nil), values...)
+	}
+	data["CREATE_TIME"] = []string{strconv.FormatInt(r.created.Unix(), 10)}
+	data["KEY"] = []string{k.key}
+	data["LAST_UPDATE_TIME"] = []string{strconv.FormatInt(r.updated.Unix(), 10)}
+	data["TIMEOUT"] = []string{strconv.FormatInt(int64(r.timeout.Seconds()), 10)}
+	data["UPDATE_COUNTER"] = []string{strconv.Itoa(r.counter)}
+	return data, true
+}
+
+// Set stores the record of the collection and postpones its expiration. The
+// TIMEOUT field overrides the time to live of the record, in seconds, the
+// other fields computed by Get are ignored.
+func (s *PersistentStore) Set(collection string, key string, data map[string][]string) {
+	now := s.Clock()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.setLocked(persistentKey{strings.ToLower(collection), key}, now, data)
+}
+
+// Update changes the record of the collection with update, atomically. The
+// data is empty if the record doesn't exist or expired, update can change the
+// fields like with Set. It lets the writers of different fields of a record,
+// e.g. the transactions and the background lookups, keep the fields of the
+// others.
+func (s *PersistentStore) Update(collection string, key string, update func(data map[string][]string)) {
+	k := persistentKey{strings.ToLower(collection), key}
+	now := s.Clock()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	data, ok := s.getLocked(k, now)
+	if !ok {
+		data = map[string][]string{}
+	}
+	update(data)
+	s.setLocked(k, now, data)
+}
+
+func (s *PersistentStore) setLocked(k persistentKey, now time.Time, data map[string][]string) {
+	r, ok := s.records[k]
+	if !ok || r.expired(now) {
+		r = &persistentRecord{created: now}
+		s.records[k] = r
+	}
+	r.timeout = s.Timeout
+	r.data = make(map[string][]string, len(data))
+	for field, values := range data {
+		switch strings.ToUpper(field) {
+		case "TIMEOUT":
+			if len(values) > 0 {
+				if seconds, err := strconv.Atoi(values[0]); err == nil && seconds > 0 {
+					r.timeout = time.Duration(seconds) * time.Second
+				}
+			}
+		case "CREATE_TIME", "KEY", "LAST_UPDATE_TIME", "UPDATE_COUNTER", "IS_NEW":
+		default:
+			r.data[field] = append([]string(nil), values...)
+		}
+	}
+	r.updated = now
+	r.counter++
+}
+
+// Delete removes the record of the collection
+func (s *PersistentStore) Delete(collection string, key string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.records, persistentKey{strings.ToLower(collection), key})
+}
+
+// Len returns the number of records, including the expired ones not
+// collected yet
+func (s *PersistentStore) Len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.records)
+}
+
+// Collect removes the expired records and returns how many were removed.
+// Records are also removed when read, Collect releases the ones that are
+// not read anymore.
+func (s *PersistentStore) Collect() int {
+	now := s.Clock()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	removed := 0
+	for k, r := range s.records {
+		if r.expired(now) {
+			delete(s.records, k)
+			removed++
+		}
+	}
+	return removed
+}
+
+// RunGC calls Collect at every interval until the context is done, it is
+// meant to be started in its own goroutine by the connector.
+func (s *PersistentStore) RunGC(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			s.Collect()
+		}
+	}
+}
@@ -0,0 +1,95 @@
+// Copyright 2024 Juan Pablo Tosso and the OWASP Coraza contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package collections
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestPersistentStore(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	s := NewPersistentStore()
+	s.Clock = func() time.Time { return now }
+	s.Timeout = time.Minute
+
+	if _, ok := s.Get("ip", "10.0.0.1"); ok {
+		t.Fatal("unexpected record")
+	}
+	s.Set("IP", "10.0.0.1", map[string][]string{"score": {"5"}, "KEY": {"ignored"}})
+	s.Set("ip", "10.0.0.2", map[string][]string{"score": {"1"}, "TIMEOUT": {"300"}})
+
+	now = now.Add(30 * time.Second)
+	s.Set("ip", "10.0.0.1", map[string][]string{"score": {"10"}})
+	data, ok := s.Get("ip", "10.0.0.1")
+	if !ok {
+		t.Fatal("expected record")
+	}
+	for field, want := range map[string]string{
+		"score":            "10",
+		"KEY":              "10.0.0.1",
+		"CREATE_TIME":      "1700000000",
+		"LAST_UPDATE_TIME": "1700000030",
+		"TIMEOUT":          "60",
+		"UPDATE_COUNTER":   "2",
+	} {
+		if have := data[field]; len(have) != 1 || want != have[0] {
+			t.Errorf("unexpected %s, want %q, have %q", field, want, have)
+		}
+	}
+
+	// the expiration is postponed by the updates
+	now = now.Add(59 * time.Second)
+	if _, ok := s.Get("ip", "10.0.0.1"); !ok {
+		t.Error("expected record updated within the timeout")
+	}
+	now = now.Add(time.Second)
+	if _, ok := s.Get("ip", "10.0.0.1"); ok {
+		t.Error("unexpected expired record")
+	}
+	if want, have := 1, s.Len(); want != have {
+		t.Errorf("expected expired record to be removed when read, want %d records, have %d", want, have)
+	}
+
+	// records setting their own timeout outlive the default one
+	if data, ok := s.Get("ip", "10.0.0.2"); !ok || data["TIMEOUT"][0] != "300" {
+		t.Errorf("expected record with its own timeout, have %v", data)
+	}
+	now = now.Add(5 * time.Minute)
+	s.Set("session", "abc", map[string][]string{"valid": {"1"}})
+	if want, have := 1, s.Collect(); want != have {
+		t.Errorf("unexpected collected records, want %d, have %d", want, have)
+	}
+	if _, ok := s.Get("session", "abc"); !ok {
+		t.Error("expected record not expired to be kept by Collect")
+	}
+
+	s.Delete("session", "abc")
+	if want, have := 0, s.Len(); want != have {
+		t.Errorf("unexpected records, want %d, have %d", want, have)
+	}
+}
+
+func TestPersistentStoreRunGC(t *testing.T) {
+	s := NewPersistentStore()
+	s.Timeout = time.Millisecond
+	s.Set("ip", "10.0.0.1", map[string][]string{"score": {"5"}})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		s.RunGC(ctx, time.Millisecond)
+		close(done)
+	}()
+	deadline := time.Now().Add(5 * time.Second)
+	for s.Len() != 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("expected expired record to be collected")
+		}
+		time.Sleep(time.Millisecond)
+	}
+	cancel()
+	<-done
+}
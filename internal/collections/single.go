@@ -8,6 +8,7 @@ import (
 	"strings"
 
 	"github.com/ad3n/seclang/internal/corazarules"
+	"github.com/ad3n/seclang/internal/corazatypes"
 	"github.com/corazawaf/coraza/v3/collection"
 	"github.com/corazawaf/coraza/v3/types"
 	"github.com/corazawaf/coraza/v3/types/variables"
@@ -16,6 +17,8 @@ import (
 type Single struct {
 	data     string
 	variable variables.RuleVariable
+	// counter accounts the size of the value, if set
+	counter *MemoryCounter
 }
 
 var _ collection.Single = &Single{}
@@ -41,23 +44,33 @@ func (c *Single) Get() string {
 }
 
 func (c *Single) Set(value string) {
+	if c.counter != nil {
+		c.counter.Add(len(value) - len(c.data))
+	}
 	c.data = value
 }
 
 func (c *Single) Name() string {
-	return c.variable.Name()
+	return corazatypes.VariableName(c.variable)
 }
 
 func (c *Single) Reset() {
-	c.data = ""
+	c.Set("")
+}
+
+// SetMemoryCounter accounts the size of the value in counter from now on,
+// including the current value
+func (c *Single) SetMemoryCounter(counter *MemoryCounter) {
+	c.counter = counter
+	counter.Add(len(c.data))
 }
 
 func (c *Single) Format(res *strings.Builder) {
-	res.WriteString(c.variable.Name())
+	res.WriteString(corazatypes.VariableName(c.variable))
 	res.WriteString(": ")
 	res.WriteString(c.data)
 }
 
 func (c *Single) String() string {
-	return fmt.Sprintf("%s: %s", c.variable.Name(), c.data)
+	return fmt.Sprintf("%s: %s", corazatypes.VariableName(c.variable), c.data)
 }
@@ -10,6 +10,7 @@ import (
 	"strings"
 
 	"github.com/ad3n/seclang/internal/corazarules"
+	"github.com/ad3n/seclang/internal/corazatypes"
 	"github.com/corazawaf/coraza/v3/collection"
 	"github.com/corazawaf/coraza/v3/types"
 	"github.com/corazawaf/coraza/v3/types/variables"
@@ -53,17 +54,17 @@ func (c *SizeCollection) FindAll() []types.MatchData {
 
 // Name returns the name for the current CollectionSizeProxy
 func (c *SizeCollection) Name() string {
-	return c.variable.Name()
+	return corazatypes.VariableName(c.variable)
 }
 
 func (c *SizeCollection) Format(res *strings.Builder) {
-	res.WriteString(c.variable.Name())
+	res.WriteString(corazatypes.VariableName(c.variable))
 	res.WriteString(": ")
 	res.WriteString(strconv.Itoa(c.size()))
 }
 
 func (c *SizeCollection) String() string {
-	return fmt.Sprintf("%s: %d", c.variable.Name(), c.size())
+	return fmt.Sprintf("%s: %d", corazatypes.VariableName(c.variable), c.size())
 }
 
 // Size returns the size of all the collections values
@@ -37,3 +37,35 @@ func ParseCookies(rawCookies string) map[string][]string {
 	}
 	return cookies
 }
+
+// SetCookie is a cookie set by a Set-Cookie response header
+type SetCookie struct {
+	Name  string
+	Value string
+	// Attrs are the attributes by lowercase name, the flags like Secure
+	// have an empty value. The last occurrence of an attribute wins.
+	Attrs map[string]string
+}
+
+// ParseSetCookie parses the value of a Set-Cookie header, ok is false if it
+// has no cookie name. Like ParseCookies, names and values are not validated.
+func ParseSetCookie(raw string) (cookie SetCookie, ok bool) {
+	part, rest, _ := strings.Cut(raw, ";")
+	name, val, _ := strings.Cut(part, "=")
+	cookie.Name = textproto.TrimString(name)
+	if cookie.Name == "" {
+		return SetCookie{}, false
+	}
+	cookie.Value = textproto.TrimString(val)
+	cookie.Attrs = map[string]string{}
+	for len(rest) > 0 {
+		part, rest, _ = strings.Cut(rest, ";")
+		key, val, _ := strings.Cut(part, "=")
+		key = strings.ToLower(textproto.TrimString(key))
+		if key == "" {
+			continue
+		}
+		cookie.Attrs[key] = textproto.TrimString(val)
+	}
+	return cookie, true
+}
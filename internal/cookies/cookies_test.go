@@ -4,6 +4,7 @@
 package cookies
 
 import (
+	"maps"
 	"testing"
 )
 
@@ -98,3 +99,40 @@ func TestParseCookies(t *testing.T) {
 		})
 	}
 }
+
+func TestParseSetCookie(t *testing.T) {
+	tests := []struct {
+		raw  string
+		want SetCookie
+		ok   bool
+	}{
+		{
+			raw:  "session=abc; Path=/; Secure; HttpOnly; SameSite=Lax",
+			want: SetCookie{Name: "session", Value: "abc", Attrs: map[string]string{"path": "/", "secure": "", "httponly": "", "samesite": "Lax"}},
+			ok:   true,
+		},
+		{
+			raw:  " id = a=b ;; max-age=0; SECURE",
+			want: SetCookie{Name: "id", Value: "a=b", Attrs: map[string]string{"max-age": "0", "secure": ""}},
+			ok:   true,
+		},
+		{
+			raw:  "flag",
+			want: SetCookie{Name: "flag", Attrs: map[string]string{}},
+			ok:   true,
+		},
+		{raw: "=abc; Secure"},
+		{raw: "  "},
+	}
+	for _, tt := range tests {
+		t.Run(tt.raw, func(t *testing.T) {
+			have, ok := ParseSetCookie(tt.raw)
+			if ok != tt.ok {
+				t.Fatalf("unexpected ok, want %t, have %t", tt.ok, ok)
+			}
+			if have.Name != tt.want.Name || have.Value != tt.want.Value || !maps.Equal(have.Attrs, tt.want.Attrs) {
+				t.Errorf("unexpected cookie, want %+v, have %+v", tt.want, have)
+			}
+		})
+	}
+}
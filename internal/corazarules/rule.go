@@ -7,6 +7,11 @@ import (
 	"github.com/corazawaf/coraza/v3/types"
 )
 
+// DefaultCaptureLimit is the number of fields captured by the operators with
+// the capture action when no limit is configured, it keeps the ModSecurity
+// behavior of capturing TX:0-TX:9
+const DefaultCaptureLimit = 10
+
 // RuleMetadata is used to store rule metadata
 // that can be used across packages
 type RuleMetadata struct {
@@ -9,6 +9,7 @@ import (
 	"strconv"
 	"strings"
 
+	"github.com/ad3n/seclang/internal/corazatypes"
 	"github.com/corazawaf/coraza/v3/types"
 	"github.com/corazawaf/coraza/v3/types/variables"
 )
@@ -101,6 +102,9 @@ type MatchedRule struct {
 	ClientIPAddress_ string
 	// A slice of matched variables
 	MatchedDatas_ []types.MatchData
+	// True if some matched variables were dropped because
+	// the rule reached the match limit
+	Truncated_ bool
 
 	Rule_ types.RuleMetadata
 
@@ -145,6 +149,12 @@ func (mr *MatchedRule) MatchedDatas() []types.MatchData {
 	return mr.MatchedDatas_
 }
 
+// Truncated returns true if MatchedDatas does not contain every match
+// because the rule reached the configured match limit
+func (mr *MatchedRule) Truncated() bool {
+	return mr.Truncated_
+}
+
 func (mr *MatchedRule) Rule() types.RuleMetadata {
 	return mr.Rule_
 }
@@ -196,6 +206,9 @@ func (mr MatchedRule) writeDetails(log *strings.Builder, matchData types.MatchDa
 		fmt.Fprintf(log, " [tag %q]", t)
 	}
 	fmt.Fprintf(log, " [hostname %q] [uri %q] [unique_id %q]", mr.ServerIPAddress_, mr.URI_, mr.TransactionID_)
+	if mr.Truncated_ {
+		log.WriteString(` [truncated "true"]`)
+	}
 }
 
 func (mr MatchedRule) writeExtraRuleDetails(log *strings.Builder, matchData types.MatchData, n int) {
@@ -225,7 +238,7 @@ func (mr MatchedRule) matchData(log *strings.Builder, matchData types.MatchData)
 	log.WriteString(" matched ")
 	log.WriteString(value)
 	log.WriteString(" at ")
-	log.WriteString(matchData.Variable().Name())
+	log.WriteString(corazatypes.VariableName(matchData.Variable()))
 	if matchData.Key() != "" {
 		log.WriteString(":")
 		log.WriteString(matchData.Key())
@@ -240,7 +253,7 @@ func (mr MatchedRule) AuditLog() string {
 	for _, matchData := range mr.MatchedDatas_ {
 		fmt.Fprintf(log, "[client %q] ", mr.ClientIPAddress_)
 		if mr.Disruptive_ {
-			writeDisruptiveActionSpecificLog(log, mr)
+			writeDisruptiveActionSpecificLog(log, mr, "Coraza")
 		} else {
 			log.WriteString("Coraza: Warning. ")
 		}
@@ -252,6 +265,17 @@ func (mr MatchedRule) AuditLog() string {
 
 // ErrorLog returns the same as audit log but without matchData
 func (mr MatchedRule) ErrorLog() string {
+	return mr.errorLog("Coraza")
+}
+
+// ModSecurityErrorLog returns the same as ErrorLog but using the
+// "ModSecurity:" prefix, as written by ModSecurity in the error.log.
+// It allows pipelines parsing ModSecurity logs to consume the matches
+func (mr MatchedRule) ModSecurityErrorLog() string {
+	return mr.errorLog("ModSecurity")
+}
+
+func (mr MatchedRule) errorLog(product string) string {
 	matchData := mr.MatchedDatas_[0]
 	msg := matchData.Message()
 	for _, md := range mr.MatchedDatas_ {
@@ -269,9 +293,10 @@ func (mr MatchedRule) ErrorLog() string {
 
 	fmt.Fprintf(log, "[client %q] ", mr.ClientIPAddress_)
 	if mr.Disruptive_ {
-		writeDisruptiveActionSpecificLog(log, mr)
+		writeDisruptiveActionSpecificLog(log, mr, product)
 	} else {
-		log.WriteString("Coraza: Warning. ")
+		log.WriteString(product)
+		log.WriteString(": Warning. ")
 	}
 	log.WriteString(msg)
 	log.WriteString(" ")
@@ -290,19 +315,19 @@ func (mr MatchedRule) ErrorLog() string {
 	return log.String()
 }
 
-func writeDisruptiveActionSpecificLog(log *strings.Builder, mr MatchedRule) {
+func writeDisruptiveActionSpecificLog(log *strings.Builder, mr MatchedRule, product string) {
 	switch mr.DisruptiveAction_ {
 	case DisruptiveActionAllow:
-		fmt.Fprintf(log, "Coraza: Access allowed (phase %d). ", mr.Rule_.Phase())
+		fmt.Fprintf(log, "%s: Access allowed (phase %d). ", product, mr.Rule_.Phase())
 	case DisruptiveActionDeny:
-		fmt.Fprintf(log, "Coraza: Access denied (phase %d). ", mr.Rule_.Phase())
+		fmt.Fprintf(log, "%s: Access denied (phase %d). ", product, mr.Rule_.Phase())
 	case DisruptiveActionDrop:
-		fmt.Fprintf(log, "Coraza: Access dropped (phase %d). ", mr.Rule_.Phase())
+		fmt.Fprintf(log, "%s: Access dropped (phase %d). ", product, mr.Rule_.Phase())
 	case DisruptiveActionPass:
-		log.WriteString("Coraza: Warning. ")
+		fmt.Fprintf(log, "%s: Warning. ", product)
 	case DisruptiveActionRedirect:
-		fmt.Fprintf(log, "Coraza: Access redirected (phase %d). ", mr.Rule_.Phase())
+		fmt.Fprintf(log, "%s: Access redirected (phase %d). ", product, mr.Rule_.Phase())
 	default:
-		fmt.Fprintf(log, "Coraza: Custom disruptive action triggered (phase %d). ", mr.Rule_.Phase())
+		fmt.Fprintf(log, "%s: Custom disruptive action triggered (phase %d). ", product, mr.Rule_.Phase())
 	}
 }
@@ -0,0 +1,190 @@
+// Copyright 2024 Juan Pablo Tosso and the OWASP Coraza contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package corazatypes
+
+import (
+	"strings"
+
+	"github.com/corazawaf/coraza/v3/types/variables"
+)
+
+// The variables the variables package doesn't define, their values don't
+// overlap the ones of the variables package
+const (
+	// StreamInputBody is the raw request body, see SecStreamInBodyInspection
+	StreamInputBody variables.RuleVariable = 200 + iota
+	// StreamOutputBody is the raw response body, see SecStreamOutBodyInspection
+	StreamOutputBody
+	// PerfCombined is the sum of the durations of the phases evaluated so
+	// far in microseconds
+	PerfCombined
+	// PerfPhase1 is the duration of the request headers phase in microseconds
+	PerfPhase1
+	// PerfPhase2 is the duration of the request body phase in microseconds
+	PerfPhase2
+	// PerfPhase3 is the duration of the response headers phase in microseconds
+	PerfPhase3
+	// PerfPhase4 is the duration of the response body phase in microseconds
+	PerfPhase4
+	// PerfPhase5 is the duration of the logging phase in microseconds
+	PerfPhase5
+	// PerfRules are the durations in microseconds of the rules that took
+	// longer than SecRulePerfTime, keyed by rule id
+	PerfRules
+	// Global is the persistent collection shared by every transaction,
+	// initialized with initcol
+	Global
+	// IP is the persistent collection of the client address initialized
+	// with initcol
+	IP
+	// Resource is the persistent collection of the resource initialized with
+	// initcol
+	Resource
+	// Session is the persistent collection of the session initialized with
+	// initcol
+	Session
+	// User is the persistent collection of the user initialized with initcol
+	User
+	// RequestURLNormalized is the URL of the request after canonicalization
+	RequestURLNormalized
+	// RequestPathSegments are the segments of the normalized path of the
+	// request keyed by their index
+	RequestPathSegments
+	// ResponseCookies are the values of the cookies set by the response keyed
+	// by name
+	ResponseCookies
+	// ResponseCookiesAttrs are the attributes of the cookies set by the
+	// response keyed by cookie and attribute, e.g. session.secure
+	ResponseCookiesAttrs
+	// RequestContentTypeAnomalies are the anomalies of the Content-Type
+	// headers of the request keyed by anomaly, e.g. multiple_headers
+	RequestContentTypeAnomalies
+	// RemoteScore is the last score returned to @remoteScore
+	RemoteScore
+	// RxBudgetExceeded is set to 1 when an @rx evaluation exceeds its budget,
+	// see SecRxBudget
+	RxBudgetExceeded
+	// SecurityHeaders is the status of the security headers of the response
+	// keyed by header, e.g. csp, see SecSecurityHeadersEngine
+	SecurityHeaders
+	// MemoryUsage is the approximate memory used by the data of the
+	// transaction in bytes, see SecTransactionMemoryLimit
+	MemoryUsage
+	// MemoryLimitExceeded is set to 1 once the transaction exceeds
+	// SecTransactionMemoryLimit
+	MemoryLimitExceeded
+	// CaptureCount is the number of fields captured by the last operator
+	// supporting capture, see SecCaptureLimit
+	CaptureCount
+	// XMLExternalEntity is set to 1 when the XML request body references
+	// external entities or DTDs, see SecXmlExternalEntity
+	XMLExternalEntity
+	// MultipartOriginalValues are the values of the multipart fields before
+	// their transcoding to UTF-8 keyed by field name
+	MultipartOriginalValues
+	// MultipartCharsetError is set to 1 when a multipart field uses a charset
+	// that can't be transcoded to UTF-8
+	MultipartCharsetError
+	// YaraMatches are the names of the YARA rules matched by @yara keyed by
+	// name
+	YaraMatches
+	// MultipartFileLimitExceeded is set to 1 when a multipart request uploads
+	// more files than SecUploadFileLimit
+	MultipartFileLimitExceeded
+)
+
+// extraVariables are the names of the variables the variables package
+// doesn't define
+var extraVariables = map[variables.RuleVariable]string{
+	StreamInputBody:  "STREAM_INPUT_BODY",
+	StreamOutputBody: "STREAM_OUTPUT_BODY",
+	PerfCombined:     "PERF_COMBINED",
+	PerfPhase1:       "PERF_PHASE1",
+	PerfPhase2:       "PERF_PHASE2",
+	PerfPhase3:       "PERF_PHASE3",
+	PerfPhase4:       "PERF_PHASE4",
+	PerfPhase5:       "PERF_PHASE5",
+	PerfRules:        "PERF_RULES",
+	Global:           "GLOBAL",
+	IP:               "IP",
+	Resource:         "RESOURCE",
+	Session:          "SESSION",
+	User:             "USER",
+
+	RequestURLNormalized: "REQUEST_URL_NORMALIZED",
+	RequestPathSegments:  "REQUEST_PATH_SEGMENTS",
+	ResponseCookies:      "RESPONSE_COOKIES",
+	ResponseCookiesAttrs: "RESPONSE_COOKIES_ATTRS",
+
+	RequestContentTypeAnomalies: "REQUEST_CONTENT_TYPE_ANOMALIES",
+	RemoteScore:                 "REMOTE_SCORE",
+	RxBudgetExceeded:            "RX_BUDGET_EXCEEDED",
+	SecurityHeaders:             "SECURITY_HEADERS",
+	MemoryUsage:                 "MEMORY_USAGE",
+	MemoryLimitExceeded:         "MEMORY_LIMIT_EXCEEDED",
+	CaptureCount:                "CAPTURE_COUNT",
+	XMLExternalEntity:           "XML_EXTERNAL_ENTITY",
+	MultipartOriginalValues:     "MULTIPART_ORIGINAL_VALUES",
+	MultipartCharsetError:       "MULTIPART_CHARSET_ERROR",
+	YaraMatches:                 "YARA_MATCHES",
+	MultipartFileLimitExceeded:  "MULTIPART_FILE_LIMIT_EXCEEDED",
+}
+
+// variableAliases are the other names of the extra variables, e.g. the
+// ModSecurity names of the variables whose features differ
+var variableAliases = map[string]variables.RuleVariable{
+	"MSC_PCRE_LIMITS_EXCEEDED": RxBudgetExceeded,
+}
+
+// selectableExtraVariables are the extra variables that are collections
+var selectableExtraVariables = map[variables.RuleVariable]bool{
+	PerfRules: true,
+	Global:    true,
+	IP:        true,
+	Resource:  true,
+	Session:   true,
+	User:      true,
+
+	RequestPathSegments:  true,
+	ResponseCookies:      true,
+	ResponseCookiesAttrs: true,
+
+	RequestContentTypeAnomalies: true,
+	SecurityHeaders:             true,
+	MultipartOriginalValues:     true,
+	YaraMatches:                 true,
+}
+
+// ParseVariable returns the variable with the name, including the variables
+// the variables package doesn't define
+func ParseVariable(name string) (variables.RuleVariable, error) {
+	upper := strings.ToUpper(name)
+	if v, ok := variableAliases[upper]; ok {
+		return v, nil
+	}
+	for v, n := range extraVariables {
+		if n == upper {
+			return v, nil
+		}
+	}
+	return variables.Parse(name)
+}
+
+// VariableName returns the name of the variable, including the variables the
+// variables package doesn't define
+func VariableName(v variables.RuleVariable) string {
+	if name, ok := extraVariables[v]; ok {
+		return name
+	}
+	return v.Name()
+}
+
+// CanBeSelected returns true if the variable supports selection, including
+// the variables the variables package doesn't define
+func CanBeSelected(v variables.RuleVariable) bool {
+	if _, ok := extraVariables[v]; ok {
+		return selectableExtraVariables[v]
+	}
+	return v.CanBeSelected()
+}
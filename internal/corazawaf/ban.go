@@ -0,0 +1,152 @@
+// Copyright 2024 Juan Pablo Tosso and the OWASP Coraza contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package corazawaf
+
+import (
+	"container/heap"
+	"sync"
+	"time"
+
+	"github.com/corazawaf/coraza/v3/types"
+)
+
+// BanStore keeps the clients banned by the ban action. Implementations
+// must be safe for concurrent use, connectors can provide a store shared
+// between processes to make bans effective across instances.
+type BanStore interface {
+	// Ban bans the key until the given time
+	Ban(key string, until time.Time)
+	// Banned returns true if the key is banned at the given time
+	Banned(key string, now time.Time) bool
+}
+
+// defaultMaxBans is the number of clients the in memory store keeps banned,
+// the bans expiring first are dropped once it is full
+const defaultMaxBans = 100000
+
+// memoryBanStore is the default in memory BanStore
+type memoryBanStore struct {
+	mu   sync.Mutex
+	bans map[string]time.Time
+	// expiries orders the bans by expiry to remove them once they expire,
+	// it also holds the outdated expiries of the bans that were extended
+	expiries banExpiries
+	max      int
+}
+
+// NewMemoryBanStore returns a BanStore keeping the bans in memory
+func NewMemoryBanStore() BanStore {
+	return newMemoryBanStore(defaultMaxBans)
+}
+
+func newMemoryBanStore(max int) *memoryBanStore {
+	return &memoryBanStore{bans: map[string]time.Time{}, max: max}
+}
+
+func (s *memoryBanStore) Ban(key string, until time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	current, ok := s.bans[key]
+	if ok && current.After(until) {
+		return
+	}
+	if !ok && len(s.bans) >= s.max {
+		s.evict()
+	}
+	s.bans[key] = until
+	heap.Push(&s.expiries, banExpiry{key: key, until: until})
+	if len(s.expiries) > 2*len(s.bans) {
+		// drops the outdated expiries
+		s.expiries = s.expiries[:0]
+		for key, until := range s.bans {
+			s.expiries = append(s.expiries, banExpiry{key: key, until: until})
+		}
+		heap.Init(&s.expiries)
+	}
+}
+
+func (s *memoryBanStore) Banned(key string, now time.Time) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	// expired bans are removed as time goes by
+	for len(s.expiries) > 0 && !now.Before(s.expiries[0].until) {
+		s.remove(heap.Pop(&s.expiries).(banExpiry))
+	}
+	until, ok := s.bans[key]
+	return ok && now.Before(until)
+}
+
+// evict removes the ban expiring first
+func (s *memoryBanStore) evict() {
+	for len(s.expiries) > 0 {
+		if s.remove(heap.Pop(&s.expiries).(banExpiry)) {
+			return
+		}
+	}
+}
+
+// remove removes the ban of the expiry unless it was extended, it returns
+// whether the ban was removed
+func (s *memoryBanStore) remove(e banExpiry) bool {
+	if until, ok := s.bans[e.key]; ok && until.Equal(e.until) {
+		delete(s.bans, e.key)
+		return true
+	}
+	return false
+}
+
+type banExpiry struct {
+	key   string
+	until time.Time
+}
+
+// banExpiries is a min-heap of ban expiries, see container/heap
+type banExpiries []banExpiry
+
+func (h banExpiries) Len() int           { return len(h) }
+func (h banExpiries) Less(i, j int) bool { return h[i].until.Before(h[j].until) }
+func (h banExpiries) Swap(i, j int)      { h[i], h[j] = h[j], h[i] }
+func (h *banExpiries) Push(x any)        { *h = append(*h, x.(banExpiry)) }
+func (h *banExpiries) Pop() any {
+	old := *h
+	e := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return e
+}
+
+// Ban bans the client address of the transaction for the given duration
+func (tx *Transaction) Ban(duration time.Duration) {
+	if tx.WAF.BanStore == nil {
+		return
+	}
+	addr := tx.variables.remoteAddr.Get()
+	if addr == "" {
+		tx.debugLogger.Warn().Msg("Cannot ban a transaction without client address")
+		return
+	}
+	tx.WAF.BanStore.Ban(addr, time.Unix(0, tx.Timestamp).Add(duration))
+	tx.debugLogger.Debug().
+		Str("client", addr).
+		Str("duration", duration.String()).
+		Msg("Client banned")
+}
+
+// checkBan interrupts the transaction if the client address is banned,
+// it runs before evaluating the request headers rules
+func (tx *Transaction) checkBan() {
+	if tx.WAF.BanStore == nil {
+		return
+	}
+	addr := tx.variables.remoteAddr.Get()
+	if addr == "" || !tx.WAF.BanStore.Banned(addr, time.Unix(0, tx.Timestamp)) {
+		return
+	}
+	tx.debugLogger.Debug().
+		Str("client", addr).
+		Msg("Interrupting transaction from banned client")
+	tx.Interrupt(&types.Interruption{
+		Status: 403,
+		Action: "deny",
+	})
+}
@@ -0,0 +1,69 @@
+// Copyright 2024 Juan Pablo Tosso and the OWASP Coraza contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package corazawaf
+
+import (
+	"testing"
+	"time"
+)
+
+func TestBan(t *testing.T) {
+	now := time.Date(2024, time.January, 8, 12, 0, 0, 0, time.UTC)
+	waf := NewWAF()
+	waf.Clock = func() time.Time { return now }
+
+	newTx := func(addr string) *Transaction {
+		tx := waf.NewTransaction()
+		tx.ProcessConnection(addr, 1234, "127.0.0.1", 80)
+		return tx
+	}
+
+	tx := newTx("10.0.0.1")
+	if it := tx.ProcessRequestHeaders(); it != nil {
+		t.Fatal("unexpected interruption before the ban")
+	}
+	tx.Ban(time.Hour)
+
+	tx = newTx("10.0.0.1")
+	it := tx.ProcessRequestHeaders()
+	if it == nil {
+		t.Fatal("expected banned client to be interrupted")
+	}
+	if want, have := 403, it.Status; want != have {
+		t.Errorf("unexpected status, want %d, have %d", want, have)
+	}
+
+	if it := newTx("10.0.0.2").ProcessRequestHeaders(); it != nil {
+		t.Error("unexpected interruption of a client not banned")
+	}
+
+	now = now.Add(time.Hour)
+	if it := newTx("10.0.0.1").ProcessRequestHeaders(); it != nil {
+		t.Error("unexpected interruption after the ban expired")
+	}
+}
+
+func TestMemoryBanStore(t *testing.T) {
+	now := time.Date(2024, time.January, 8, 12, 0, 0, 0, time.UTC)
+	s := newMemoryBanStore(2)
+	s.Ban("a", now.Add(time.Hour))
+	s.Ban("b", now.Add(2*time.Hour))
+	// extended, the previous expiry is outdated
+	s.Ban("a", now.Add(3*time.Hour))
+	// the store is full, the ban expiring first is dropped
+	s.Ban("c", now.Add(time.Minute))
+	for key, want := range map[string]bool{"a": true, "b": false, "c": true} {
+		if have := s.Banned(key, now); want != have {
+			t.Errorf("unexpected ban of %s, want %t, have %t", key, want, have)
+		}
+	}
+
+	// expired bans are removed
+	if s.Banned("c", now.Add(time.Hour)) {
+		t.Error("unexpected expired ban")
+	}
+	if want, have := 1, len(s.bans); want != have {
+		t.Errorf("unexpected number of bans, want %d, have %d", want, have)
+	}
+}
@@ -0,0 +1,190 @@
+// Copyright 2024 Juan Pablo Tosso and the OWASP Coraza contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package corazawaf
+
+import (
+	"sync"
+
+	"github.com/ad3n/seclang/experimental/plugins/plugintypes"
+	"github.com/ad3n/seclang/internal/collections"
+	"github.com/corazawaf/coraza/v3/collection"
+	"github.com/corazawaf/coraza/v3/debuglog"
+	"github.com/corazawaf/coraza/v3/types"
+	"github.com/corazawaf/coraza/v3/types/variables"
+)
+
+// ConnState is the state of a client connection tracked by the connection engine
+type ConnState int
+
+const (
+	// ConnStateRead is a connection reading a request
+	ConnStateRead ConnState = iota
+	// ConnStateWrite is a connection writing a response
+	ConnStateWrite
+)
+
+func (s ConnState) String() string {
+	if s == ConnStateWrite {
+		return "write"
+	}
+	return "read"
+}
+
+// ConnLimit is the maximum number of connections of a client in a state,
+// 0 means no limit
+type ConnLimit struct {
+	Limit int
+	// Match selects the client addresses the limit applies to, it applies
+	// to all of them when nil. The connections have no transaction yet, the
+	// state only provides the logger and the dependency failure mode of the
+	// WAF to the operators.
+	Match func(state plugintypes.TransactionState, addr string) bool
+}
+
+func (l ConnLimit) exceeded(w *WAF, addr string, count int) bool {
+	return l.Limit > 0 && count > l.Limit && (l.Match == nil || l.Match(connectionState{waf: w, addr: addr}, addr))
+}
+
+// connectionState is the transaction state the connection limits are
+// matched with, it has no variables
+type connectionState struct {
+	waf  *WAF
+	addr string
+}
+
+var _ plugintypes.TransactionState = connectionState{}
+
+func (connectionState) ID() string {
+	return ""
+}
+
+func (connectionState) Variables() plugintypes.TransactionVariables {
+	return NewTransactionVariables()
+}
+
+func (connectionState) Collection(variables.RuleVariable) collection.Collection {
+	return collections.Noop
+}
+
+func (connectionState) Interrupt(*types.Interruption) {}
+
+func (s connectionState) DebugLogger() debuglog.Logger {
+	return s.waf.Logger.With(debuglog.Str("client", s.addr))
+}
+
+func (connectionState) Capturing() bool {
+	return false
+}
+
+func (connectionState) CaptureField(int, string) {}
+
+func (connectionState) LastPhase() types.RulePhase {
+	return types.PhaseUnknown
+}
+
+func (s connectionState) DependencyFailed(feature string, err error) bool {
+	s.DebugLogger().Warn().
+		Str("dependency", feature).
+		Err(err).
+		Msg("External dependency failed")
+	return s.waf.DependencyFailureMode(feature) == DependencyFailClosed
+}
+
+// connTracker counts the connections of each client address in each state
+type connTracker struct {
+	mu    sync.Mutex
+	conns map[string]*[2]int
+}
+
+// move moves a connection of the address from a state to another one, -1
+// meaning it is not tracked, and returns the connections in the new state
+func (t *connTracker) move(addr string, from, to ConnState) int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.conns == nil {
+		t.conns = map[string]*[2]int{}
+	}
+	counts := t.conns[addr]
+	if counts == nil {
+		counts = &[2]int{}
+		t.conns[addr] = counts
+	}
+	if from >= 0 {
+		counts[from]--
+	}
+	if to < 0 {
+		if counts[ConnStateRead] == 0 && counts[ConnStateWrite] == 0 {
+			delete(t.conns, addr)
+		}
+		return 0
+	}
+	counts[to]++
+	return counts[to]
+}
+
+func (t *connTracker) count(addr string, state ConnState) int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if counts := t.conns[addr]; counts != nil {
+		return counts[state]
+	}
+	return 0
+}
+
+// Connection is a client connection tracked by the connection engine,
+// connectors must close it when the connection ends
+type Connection struct {
+	waf   *WAF
+	addr  string
+	state ConnState
+}
+
+// TrackConnection starts tracking a connection of the client address in the
+// read state. It returns false if the connection exceeds the SecConnReadStateLimit
+// of the client and must be dropped, when SecConnEngine is DetectionOnly it is only
+// logged. The connection is tracked even if it must be dropped until it is closed.
+func (w *WAF) TrackConnection(addr string) (*Connection, bool) {
+	c := &Connection{waf: w, addr: addr, state: -1}
+	return c, c.SetState(ConnStateRead)
+}
+
+// SetState moves the connection to the state, it returns false if the connection
+// exceeds the limit of the state for the client and must be dropped
+func (c *Connection) SetState(state ConnState) bool {
+	w := c.waf
+	if w.ConnEngine == types.RuleEngineOff || c.state == state {
+		return true
+	}
+	count := w.conns.move(c.addr, c.state, state)
+	c.state = state
+	limit := w.ConnReadStateLimit
+	if state == ConnStateWrite {
+		limit = w.ConnWriteStateLimit
+	}
+	if !limit.exceeded(w, c.addr, count) {
+		return true
+	}
+	w.Logger.Warn().
+		Str("client", c.addr).
+		Str("state", state.String()).
+		Int("connections", count).
+		Int("limit", limit.Limit).
+		Msg("Client exceeds the connection limit")
+	return w.ConnEngine == types.RuleEngineDetectionOnly
+}
+
+// Close stops tracking the connection
+func (c *Connection) Close() {
+	if c.state < 0 {
+		return
+	}
+	c.waf.conns.move(c.addr, c.state, -1)
+	c.state = -1
+}
+
+// Connections returns the number of tracked connections of the client address
+// in the state
+func (w *WAF) Connections(addr string, state ConnState) int {
+	return w.conns.count(addr, state)
+}
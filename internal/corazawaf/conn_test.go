@@ -0,0 +1,111 @@
+// Copyright 2024 Juan Pablo Tosso and the OWASP Coraza contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package corazawaf
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/ad3n/seclang/experimental/plugins/plugintypes"
+	"github.com/corazawaf/coraza/v3/types"
+)
+
+func TestTrackConnection(t *testing.T) {
+	waf := NewWAF()
+	waf.ConnEngine = types.RuleEngineOn
+	waf.ConnReadStateLimit = ConnLimit{Limit: 2}
+	waf.ConnWriteStateLimit = ConnLimit{Limit: 1, Match: func(_ plugintypes.TransactionState, addr string) bool { return addr != "127.0.0.1" }}
+
+	c1, ok := waf.TrackConnection("10.0.0.1")
+	if !ok {
+		t.Fatal("expected first connection to be allowed")
+	}
+	c2, ok := waf.TrackConnection("10.0.0.1")
+	if !ok {
+		t.Fatal("expected second connection to be allowed")
+	}
+	c3, ok := waf.TrackConnection("10.0.0.1")
+	if ok {
+		t.Error("expected third connection to exceed the read state limit")
+	}
+	if _, ok := waf.TrackConnection("10.0.0.2"); !ok {
+		t.Error("expected connections of other clients to be allowed")
+	}
+	if want, have := 3, waf.Connections("10.0.0.1", ConnStateRead); want != have {
+		t.Errorf("unexpected read connections, want %d, have %d", want, have)
+	}
+
+	c3.Close()
+	if !c1.SetState(ConnStateWrite) {
+		t.Error("expected first connection to be allowed to write")
+	}
+	if c2.SetState(ConnStateWrite) {
+		t.Error("expected second connection to exceed the write state limit")
+	}
+	if want, have := 0, waf.Connections("10.0.0.1", ConnStateRead); want != have {
+		t.Errorf("unexpected read connections, want %d, have %d", want, have)
+	}
+	if want, have := 2, waf.Connections("10.0.0.1", ConnStateWrite); want != have {
+		t.Errorf("unexpected write connections, want %d, have %d", want, have)
+	}
+
+	local1, _ := waf.TrackConnection("127.0.0.1")
+	local2, _ := waf.TrackConnection("127.0.0.1")
+	if !local1.SetState(ConnStateWrite) || !local2.SetState(ConnStateWrite) {
+		t.Error("expected the write state limit not to apply to excluded clients")
+	}
+
+	c1.Close()
+	c2.Close()
+	c2.Close()
+	if want, have := 0, waf.Connections("10.0.0.1", ConnStateWrite); want != have {
+		t.Errorf("unexpected write connections after close, want %d, have %d", want, have)
+	}
+}
+
+func TestTrackConnectionModes(t *testing.T) {
+	tests := []struct {
+		engine  types.RuleEngineStatus
+		allowed bool
+		tracked int
+	}{
+		{types.RuleEngineOff, true, 0},
+		{types.RuleEngineDetectionOnly, true, 2},
+		{types.RuleEngineOn, false, 2},
+	}
+	for _, tc := range tests {
+		t.Run(tc.engine.String(), func(t *testing.T) {
+			waf := NewWAF()
+			waf.ConnEngine = tc.engine
+			waf.ConnReadStateLimit = ConnLimit{Limit: 1}
+			waf.TrackConnection("10.0.0.1")
+			_, allowed := waf.TrackConnection("10.0.0.1")
+			if want, have := tc.allowed, allowed; want != have {
+				t.Errorf("unexpected decision, want %t, have %t", want, have)
+			}
+			if want, have := tc.tracked, waf.Connections("10.0.0.1", ConnStateRead); want != have {
+				t.Errorf("unexpected tracked connections, want %d, have %d", want, have)
+			}
+		})
+	}
+}
+
+func TestConnLimitState(t *testing.T) {
+	waf := NewWAF()
+	waf.ConnEngine = types.RuleEngineOn
+	waf.SetDependencyFailureMode("iplist", DependencyFailClosed)
+	// the limit applies to the clients of an unavailable list, like a
+	// refreshed @ipMatchFromFile that couldn't be read yet
+	waf.ConnReadStateLimit = ConnLimit{Limit: 1, Match: func(state plugintypes.TransactionState, _ string) bool {
+		state.DebugLogger().Debug().Msg("matching the client")
+		return state.DependencyFailed("iplist", errors.New("not available"))
+	}}
+
+	if _, ok := waf.TrackConnection("10.0.0.1"); !ok {
+		t.Fatal("expected first connection to be allowed")
+	}
+	if _, ok := waf.TrackConnection("10.0.0.1"); ok {
+		t.Error("expected second connection to exceed the read state limit")
+	}
+}
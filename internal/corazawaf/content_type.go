@@ -0,0 +1,238 @@
+// Copyright 2024 Juan Pablo Tosso and the OWASP Coraza contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package corazawaf
+
+import (
+	"strconv"
+	"strings"
+)
+
+// The anomalies of the Content-Type headers, the keys of
+// REQUEST_CONTENT_TYPE_ANOMALIES, their values describe the anomaly
+const (
+	// multiple_headers is the number of Content-Type headers
+	contentTypeMultipleHeaders = "multiple_headers"
+	// multiple_values is a header listing several media types
+	contentTypeMultipleValues = "multiple_values"
+	// conflicting_media_types are the different media types of the headers
+	contentTypeConflictingMediaTypes = "conflicting_media_types"
+	// malformed is a header that can't be parsed, e.g. an unterminated quote
+	contentTypeMalformed = "malformed"
+	// duplicate_parameters are the parameters set more than once
+	contentTypeDuplicateParameters = "duplicate_parameters"
+	// extended_parameters are the RFC 2231 parameters, e.g. boundary*=
+	contentTypeExtendedParameters = "extended_parameters"
+	// conflicting_boundaries are the different boundaries of the headers
+	contentTypeConflictingBoundaries = "conflicting_boundaries"
+	// missing_boundary is the multipart media type without boundary
+	contentTypeMissingBoundary = "missing_boundary"
+	// invalid_boundary is a boundary RFC 2046 doesn't allow
+	contentTypeInvalidBoundary = "invalid_boundary"
+	// conflicting_charsets are the different charsets of the headers
+	contentTypeConflictingCharsets = "conflicting_charsets"
+	// unsafe_charset is a charset that isn't a superset of ASCII, the
+	// payloads it encodes aren't seen by the rules, e.g. utf-7
+	contentTypeUnsafeCharset = "unsafe_charset"
+)
+
+// maxBoundaryLength is the maximum length of a multipart boundary, RFC 2046
+const maxBoundaryLength = 70
+
+// unsafeCharsets are the charsets of the request bodies that aren't a
+// superset of ASCII
+var unsafeCharsets = map[string]bool{
+	"utf-7":      true,
+	"utf7":       true,
+	"utf-16":     true,
+	"utf-16be":   true,
+	"utf-16le":   true,
+	"utf16":      true,
+	"utf-32":     true,
+	"utf-32be":   true,
+	"utf-32le":   true,
+	"utf32":      true,
+	"ucs-2":      true,
+	"ucs-4":      true,
+	"cp037":      true,
+	"cp500":      true,
+	"cp875":      true,
+	"cp1026":     true,
+	"ibm037":     true,
+	"ibm500":     true,
+	"ibm1047":    true,
+	"hz-gb-2312": true,
+}
+
+// contentTypeParameter is a parameter of a Content-Type header
+type contentTypeParameter struct {
+	name  string
+	value string
+}
+
+// parseContentType returns the lowercased media type and the parameters of
+// the Content-Type header, including the duplicate ones mime.ParseMediaType
+// rejects. It returns false if the header is malformed.
+func parseContentType(value string) (string, []contentTypeParameter, bool) {
+	mediaType, rest, _ := strings.Cut(value, ";")
+	mediaType = strings.ToLower(strings.TrimSpace(mediaType))
+	ok := mediaType != ""
+	var params []contentTypeParameter
+	for rest = strings.TrimLeft(rest, " \t;"); rest != ""; rest = strings.TrimLeft(rest, " \t;") {
+		var name string
+		i := strings.IndexAny(rest, "=;")
+		if i == -1 || rest[i] == ';' {
+			// a parameter without value
+			ok = false
+			name, rest, _ = strings.Cut(rest, ";")
+			params = append(params, contentTypeParameter{name: strings.ToLower(strings.TrimSpace(name))})
+			continue
+		}
+		name, rest = strings.ToLower(strings.TrimSpace(rest[:i])), strings.TrimLeft(rest[i+1:], " \t")
+		var v string
+		if strings.HasPrefix(rest, `"`) {
+			var sb strings.Builder
+			closed := false
+			j := 1
+			for ; j < len(rest); j++ {
+				c := rest[j]
+				if c == '\\' && j+1 < len(rest) {
+					j++
+					sb.WriteByte(rest[j])
+					continue
+				}
+				if c == '"' {
+					closed = true
+					break
+				}
+				sb.WriteByte(c)
+			}
+			if !closed {
+				ok = false
+				v, rest = sb.String(), ""
+			} else {
+				v, rest = sb.String(), rest[j+1:]
+				if after := strings.TrimLeft(rest, " \t"); after != "" && after[0] != ';' {
+					// characters after the closing quote
+					ok = false
+					_, rest, _ = strings.Cut(rest, ";")
+				}
+			}
+		} else {
+			v, rest, _ = strings.Cut(rest, ";")
+			v = strings.TrimSpace(v)
+			if strings.ContainsAny(v, ` "`) {
+				ok = false
+			}
+		}
+		if name == "" {
+			ok = false
+		}
+		params = append(params, contentTypeParameter{name: name, value: v})
+	}
+	return mediaType, params, ok
+}
+
+// validBoundary reports whether the boundary is made of the characters RFC
+// 2046 allows and doesn't end with a space
+func validBoundary(boundary string) bool {
+	if boundary == "" || len(boundary) > maxBoundaryLength || strings.HasSuffix(boundary, " ") {
+		return false
+	}
+	for i := 0; i < len(boundary); i++ {
+		c := boundary[i]
+		switch {
+		case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c >= '0' && c <= '9':
+		case strings.IndexByte(`'()+_,-./:=? `, c) != -1:
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// appendUnique appends s to values unless it is already one of them
+func appendUnique(values []string, s string) []string {
+	for _, v := range values {
+		if v == s {
+			return values
+		}
+	}
+	return append(values, s)
+}
+
+// setContentTypeAnomalies records the anomalies of the Content-Type headers
+// of the request the parsers of the WAF and of the application may not agree
+// on, e.g. several headers or boundaries, so the rules detecting the
+// smuggling of request bodies don't have to parse the raw headers.
+func (tx *Transaction) setContentTypeAnomalies() {
+	headers := tx.variables.requestHeaders.Get("content-type")
+	if len(headers) == 0 {
+		return
+	}
+	anomalies := map[string]string{}
+	if len(headers) > 1 {
+		anomalies[contentTypeMultipleHeaders] = strconv.Itoa(len(headers))
+	}
+
+	var mediaTypes, boundaries, charsets, duplicates, extended []string
+	for _, header := range headers {
+		mediaType, params, ok := parseContentType(header)
+		if !ok {
+			anomalies[contentTypeMalformed] = header
+		}
+		if strings.Contains(mediaType, ",") {
+			anomalies[contentTypeMultipleValues] = header
+		}
+		mediaTypes = appendUnique(mediaTypes, mediaType)
+
+		seen := map[string]bool{}
+		hasBoundary := false
+		for _, p := range params {
+			if seen[p.name] {
+				duplicates = appendUnique(duplicates, p.name)
+			}
+			seen[p.name] = true
+			if strings.Contains(p.name, "*") {
+				extended = appendUnique(extended, p.name)
+			}
+			switch p.name {
+			case "boundary":
+				hasBoundary = true
+				boundaries = appendUnique(boundaries, p.value)
+				if !validBoundary(p.value) {
+					anomalies[contentTypeInvalidBoundary] = p.value
+				}
+			case "charset":
+				charset := strings.ToLower(p.value)
+				charsets = appendUnique(charsets, charset)
+				if unsafeCharsets[charset] {
+					anomalies[contentTypeUnsafeCharset] = charset
+				}
+			}
+		}
+		if strings.HasPrefix(mediaType, "multipart/") && !hasBoundary {
+			anomalies[contentTypeMissingBoundary] = mediaType
+		}
+	}
+
+	for key, values := range map[string][]string{
+		contentTypeConflictingMediaTypes: mediaTypes,
+		contentTypeConflictingBoundaries: boundaries,
+		contentTypeConflictingCharsets:   charsets,
+	} {
+		if len(values) > 1 {
+			anomalies[key] = strings.Join(values, ", ")
+		}
+	}
+	if len(duplicates) > 0 {
+		anomalies[contentTypeDuplicateParameters] = strings.Join(duplicates, ", ")
+	}
+	if len(extended) > 0 {
+		anomalies[contentTypeExtendedParameters] = strings.Join(extended, ", ")
+	}
+
+	for key, value := range anomalies {
+		tx.variables.contentTypeAnomalies.Set(key, []string{value})
+	}
+}
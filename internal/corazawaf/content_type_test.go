@@ -0,0 +1,118 @@
+// Copyright 2024 Juan Pablo Tosso and the OWASP Coraza contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package corazawaf
+
+import (
+	"maps"
+	"strings"
+	"testing"
+)
+
+func TestContentTypeAnomalies(t *testing.T) {
+	tests := []struct {
+		name      string
+		headers   []string
+		anomalies map[string]string
+	}{
+		{"none", nil, map[string]string{}},
+		{"urlencoded", []string{"application/x-www-form-urlencoded; charset=UTF-8"}, map[string]string{}},
+		{"multipart", []string{`multipart/form-data; boundary="----abc 123"`}, map[string]string{}},
+		{
+			"multiple headers",
+			[]string{"application/json", "multipart/form-data; boundary=abc"},
+			map[string]string{
+				"multiple_headers":        "2",
+				"conflicting_media_types": "application/json, multipart/form-data",
+			},
+		},
+		{
+			"same headers",
+			[]string{"application/json", "Application/JSON"},
+			map[string]string{"multiple_headers": "2"},
+		},
+		{
+			"multiple values",
+			[]string{"text/plain, multipart/form-data; boundary=abc"},
+			map[string]string{"multiple_values": "text/plain, multipart/form-data; boundary=abc"},
+		},
+		{
+			"duplicate boundary",
+			[]string{"multipart/form-data; boundary=a; BOUNDARY=b"},
+			map[string]string{
+				"duplicate_parameters":   "boundary",
+				"conflicting_boundaries": "a, b",
+			},
+		},
+		{
+			"boundaries of the headers",
+			[]string{"multipart/form-data; boundary=a", "multipart/form-data; boundary=b"},
+			map[string]string{
+				"multiple_headers":       "2",
+				"conflicting_boundaries": "a, b",
+			},
+		},
+		{
+			"extended boundary",
+			[]string{"multipart/form-data; boundary=a; boundary*=utf-8''b"},
+			map[string]string{"extended_parameters": "boundary*"},
+		},
+		{
+			"missing boundary",
+			[]string{"multipart/mixed; charset=utf-8"},
+			map[string]string{"missing_boundary": "multipart/mixed"},
+		},
+		{
+			"invalid boundary",
+			[]string{`multipart/form-data; boundary="a<b>"`},
+			map[string]string{"invalid_boundary": "a<b>"},
+		},
+		{
+			"long boundary",
+			[]string{"multipart/form-data; boundary=" + strings.Repeat("a", 71)},
+			map[string]string{"invalid_boundary": strings.Repeat("a", 71)},
+		},
+		{
+			"unterminated quote",
+			[]string{`multipart/form-data; boundary="abc`},
+			map[string]string{"malformed": `multipart/form-data; boundary="abc`},
+		},
+		{
+			"characters after quote",
+			[]string{`multipart/form-data; boundary="abc"def`},
+			map[string]string{"malformed": `multipart/form-data; boundary="abc"def`},
+		},
+		{
+			"unsafe charset",
+			[]string{"application/x-www-form-urlencoded; charset=UTF-7"},
+			map[string]string{"unsafe_charset": "utf-7"},
+		},
+		{
+			"conflicting charsets",
+			[]string{"text/plain; charset=utf-8; charset=ibm037"},
+			map[string]string{
+				"duplicate_parameters": "charset",
+				"conflicting_charsets": "utf-8, ibm037",
+				"unsafe_charset":       "ibm037",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tx := NewWAF().NewTransaction()
+			defer tx.Close()
+			for _, h := range tt.headers {
+				tx.AddRequestHeader("Content-Type", h)
+			}
+			tx.ProcessRequestHeaders()
+
+			have := map[string]string{}
+			for _, md := range tx.variables.contentTypeAnomalies.FindAll() {
+				have[md.Key()] = md.Value()
+			}
+			if !maps.Equal(tt.anomalies, have) {
+				t.Errorf("unexpected anomalies, want %q, have %q", tt.anomalies, have)
+			}
+		})
+	}
+}
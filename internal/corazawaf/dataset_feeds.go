@@ -0,0 +1,112 @@
+// Copyright 2024 Juan Pablo Tosso and the OWASP Coraza contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package corazawaf
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"sync/atomic"
+
+	"github.com/ad3n/seclang/experimental/plugins/plugintypes"
+)
+
+// DatasetFeeds keeps the values of the datasets of a WAF updated at runtime
+// with Update, e.g. by plugins.UpdateDataset, and the operators built from
+// them, one per operator name, shared by the rules of the WAF referencing the
+// dataset. The WAFs staged from the WAF share its feeds, so the updates
+// outlive the reloads.
+type DatasetFeeds struct {
+	mu    sync.Mutex
+	feeds map[string]*datasetFeed
+}
+
+var _ plugintypes.DatasetFeeds = (*DatasetFeeds)(nil)
+
+type datasetFeed struct {
+	// values is nil until the dataset is updated
+	values   []string
+	matchers map[string]*datasetMatcher
+}
+
+type datasetMatcher struct {
+	build func(values []string) (plugintypes.Operator, error)
+	op    atomic.Pointer[plugintypes.Operator]
+}
+
+// DatasetFeeds returns the feeds of the datasets of the WAF, created on
+// first use
+func (w *WAF) DatasetFeeds() *DatasetFeeds {
+	if w.datasetFeeds == nil {
+		w.datasetFeeds = &DatasetFeeds{}
+	}
+	return w.datasetFeeds
+}
+
+// feedLocked returns the feed of the dataset, creating it if needed
+func (f *DatasetFeeds) feedLocked(dataset string) *datasetFeed {
+	if f.feeds == nil {
+		f.feeds = map[string]*datasetFeed{}
+	}
+	feed, ok := f.feeds[dataset]
+	if !ok {
+		feed = &datasetFeed{matchers: map[string]*datasetMatcher{}}
+		f.feeds[dataset] = feed
+	}
+	return feed
+}
+
+// Subscribe returns the operator named name matching the values of the last
+// update of the dataset, nil until the dataset is updated, see
+// plugintypes.DatasetFeeds
+func (f *DatasetFeeds) Subscribe(dataset, name string, build func(values []string) (plugintypes.Operator, error)) (*atomic.Pointer[plugintypes.Operator], error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	feed := f.feedLocked(dataset)
+	m, ok := feed.matchers[name]
+	if !ok {
+		m = &datasetMatcher{build: build}
+		if feed.values != nil {
+			op, err := build(feed.values)
+			if err != nil {
+				return nil, fmt.Errorf("invalid dataset %q: %s", dataset, err.Error())
+			}
+			m.op.Store(&op)
+		}
+		feed.matchers[name] = m
+	}
+	return &m.op, nil
+}
+
+// Update replaces the values of the dataset for the rules of the WAF
+// referencing it, including the rules compiled afterwards. The operators are
+// rebuilt before any of them is replaced, each one matches either the
+// previous values or the new ones, and none is replaced if the values are
+// invalid for one of them.
+func (f *DatasetFeeds) Update(dataset string, values []string) error {
+	values = append(make([]string, 0, len(values)), values...)
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	feed := f.feedLocked(dataset)
+
+	names := make([]string, 0, len(feed.matchers))
+	for name := range feed.matchers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	built := make([]plugintypes.Operator, len(names))
+	for i, name := range names {
+		op, err := feed.matchers[name].build(values)
+		if err != nil {
+			return fmt.Errorf("invalid dataset %q for @%s: %s", dataset, name, err.Error())
+		}
+		built[i] = op
+	}
+
+	for i, name := range names {
+		feed.matchers[name].op.Store(&built[i])
+	}
+	feed.values = values
+	return nil
+}
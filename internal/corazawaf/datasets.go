@@ -0,0 +1,53 @@
+// Copyright 2024 Juan Pablo Tosso and the OWASP Coraza contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package corazawaf
+
+import (
+	"errors"
+	"fmt"
+	"net/netip"
+)
+
+// Datasets returns the datasets defined with SecDataset and RegisterDataset,
+// they are scoped to the WAF so rule sets loaded in different instances don't
+// share them. The map is created on first use, every reader and writer of the
+// datasets goes through it.
+func (w *WAF) Datasets() map[string][]string {
+	if w.datasets == nil {
+		w.datasets = map[string][]string{}
+	}
+	return w.datasets
+}
+
+// RegisterDataset defines a dataset for @pmFromDataset from Go, e.g. with the
+// results of a database query, as SecDataset does from the configuration.
+// Datasets are resolved when the rules are compiled, so they must be registered
+// before the rules referencing them are parsed.
+func (w *WAF) RegisterDataset(name string, values []string) error {
+	if name == "" {
+		return errors.New("dataset name is empty")
+	}
+	datasets := w.Datasets()
+	if _, ok := datasets[name]; ok {
+		w.Logger.Warn().
+			Str("dataset_name", name).
+			Msg("Dataset already exists, overwriting")
+	}
+	datasets[name] = append([]string(nil), values...)
+	return nil
+}
+
+// RegisterIPDataset defines a dataset of networks for @ipMatchFromDataset, see
+// RegisterDataset. Single addresses are prefixes of their full length, e.g.
+// netip.PrefixFrom(addr, addr.BitLen()).
+func (w *WAF) RegisterIPDataset(name string, prefixes []netip.Prefix) error {
+	values := make([]string, 0, len(prefixes))
+	for _, p := range prefixes {
+		if !p.IsValid() {
+			return fmt.Errorf("invalid prefix in dataset %q", name)
+		}
+		values = append(values, p.Masked().String())
+	}
+	return w.RegisterDataset(name, values)
+}
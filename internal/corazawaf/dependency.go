@@ -0,0 +1,70 @@
+// Copyright 2024 Juan Pablo Tosso and the OWASP Coraza contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package corazawaf
+
+import (
+	"fmt"
+	"strings"
+)
+
+// DependencyFailureMode defines the result of an operator when an external
+// dependency it relies on (DNS resolver, GeoIP database, scanner...) is not available.
+type DependencyFailureMode int
+
+const (
+	// DependencyFailOpen makes the operator not match, the transaction goes on
+	// as if the check passed.
+	DependencyFailOpen DependencyFailureMode = iota
+	// DependencyFailClosed makes the operator match, as if the check failed.
+	DependencyFailClosed
+)
+
+// anyDependency is the feature name used to configure the mode of every
+// dependency without an explicit configuration
+const anyDependency = "*"
+
+// ParseDependencyFailureMode parses FailOpen or FailClosed, case-insensitive
+func ParseDependencyFailureMode(mode string) (DependencyFailureMode, error) {
+	switch strings.ToLower(mode) {
+	case "failopen":
+		return DependencyFailOpen, nil
+	case "failclosed":
+		return DependencyFailClosed, nil
+	}
+	return DependencyFailOpen, fmt.Errorf("invalid dependency failure mode %q", mode)
+}
+
+// SetDependencyFailureMode configures the failure mode of a dependency, features
+// are case-insensitive and "*" configures the default mode.
+func (w *WAF) SetDependencyFailureMode(feature string, mode DependencyFailureMode) {
+	if w.dependencyFailureModes == nil {
+		w.dependencyFailureModes = map[string]DependencyFailureMode{}
+	}
+	w.dependencyFailureModes[strings.ToLower(feature)] = mode
+}
+
+// DependencyFailureMode returns the failure mode configured for a dependency,
+// if not configured the default mode is returned, which is DependencyFailOpen
+// unless changed.
+func (w *WAF) DependencyFailureMode(feature string) DependencyFailureMode {
+	if m, ok := w.dependencyFailureModes[strings.ToLower(feature)]; ok {
+		return m
+	}
+	return w.dependencyFailureModes[anyDependency]
+}
+
+// DependencyFailed reports that an external dependency failed while evaluating the transaction.
+// The failure is exposed to rules through TX:dependency_error and TX:dependency_error_<feature>
+// and the returned value is the result the operator must return according to the failure mode.
+func (tx *Transaction) DependencyFailed(feature string, err error) bool {
+	feature = strings.ToLower(feature)
+	tx.debugLogger.Warn().
+		Str("dependency", feature).
+		Err(err).
+		Msg("External dependency failed")
+
+	tx.variables.tx.Set("dependency_error", []string{"1"})
+	tx.variables.tx.Set("dependency_error_"+feature, []string{"1"})
+	return tx.WAF.DependencyFailureMode(feature) == DependencyFailClosed
+}
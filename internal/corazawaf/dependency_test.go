@@ -0,0 +1,45 @@
+// Copyright 2024 Juan Pablo Tosso and the OWASP Coraza contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package corazawaf
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestDependencyFailed(t *testing.T) {
+	waf := NewWAF()
+	tx := waf.NewTransaction()
+	if tx.DependencyFailed("rbl", errors.New("timeout")) {
+		t.Error("expected dependencies to fail open by default")
+	}
+	if want, have := "1", tx.variables.tx.Get("dependency_error"); len(have) != 1 || have[0] != want {
+		t.Errorf("unexpected dependency_error, want %q, have %v", want, have)
+	}
+	if want, have := "1", tx.variables.tx.Get("dependency_error_rbl"); len(have) != 1 || have[0] != want {
+		t.Errorf("unexpected dependency_error_rbl, want %q, have %v", want, have)
+	}
+
+	waf.SetDependencyFailureMode("*", DependencyFailClosed)
+	waf.SetDependencyFailureMode("geo", DependencyFailOpen)
+	tx = waf.NewTransaction()
+	if !tx.DependencyFailed("RBL", errors.New("timeout")) {
+		t.Error("expected rbl to fail closed using the default mode")
+	}
+	if tx.DependencyFailed("geo", errors.New("missing database")) {
+		t.Error("expected geo to fail open")
+	}
+}
+
+func TestParseDependencyFailureMode(t *testing.T) {
+	if m, err := ParseDependencyFailureMode("FailClosed"); err != nil || m != DependencyFailClosed {
+		t.Errorf("unexpected result: %v, %v", m, err)
+	}
+	if m, err := ParseDependencyFailureMode("failopen"); err != nil || m != DependencyFailOpen {
+		t.Errorf("unexpected result: %v, %v", m, err)
+	}
+	if _, err := ParseDependencyFailureMode("closed"); err == nil {
+		t.Error("expected error")
+	}
+}
@@ -0,0 +1,190 @@
+// Copyright 2024 Juan Pablo Tosso and the OWASP Coraza contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package corazawaf
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// guardianLogQueueSize is the number of lines waiting to be written to the
+// guardian log, the lines of the transactions closed while it is full are
+// dropped
+const guardianLogQueueSize = 4096
+
+// GuardianLog writes a summary line per transaction to an external program
+// or a named pipe, in the format read by httpd-guardian. It is independent
+// of the audit log, every transaction is written. Lines are queued and
+// written by a single goroutine, so transactions neither wait for the
+// reader nor interleave their lines.
+type GuardianLog struct {
+	open func() (io.Writer, error)
+
+	mu     sync.RWMutex
+	closed bool
+	lines  chan string
+	done   chan struct{}
+
+	// dropped is the number of lines that were not written, the queue was
+	// full or the writer failed
+	dropped atomic.Int64
+	// w and err are owned by the goroutine writing the lines until done is
+	// closed
+	w   io.Writer
+	err error
+}
+
+// NewGuardianLog returns a guardian log writing to the writer returned by
+// open, it is called when the first line is written so programs aren't
+// started and named pipes aren't opened until there is a transaction to
+// log. If it or the writer fails the line is dropped and it is called again
+// for the next one, e.g. until the named pipe has a reader.
+func NewGuardianLog(open func() (io.Writer, error)) *GuardianLog {
+	g := &GuardianLog{
+		open:  open,
+		lines: make(chan string, guardianLogQueueSize),
+		done:  make(chan struct{}),
+	}
+	go g.run()
+	return g
+}
+
+func (g *GuardianLog) run() {
+	defer close(g.done)
+	for line := range g.lines {
+		if g.w == nil {
+			w, err := g.open()
+			if err != nil {
+				g.err = err
+				g.dropped.Add(1)
+				continue
+			}
+			g.w = w
+		}
+		if _, err := io.WriteString(g.w, line); err != nil {
+			g.err = err
+			g.dropped.Add(1)
+			// the writer is opened again for the next line, e.g. the named
+			// pipe once it has a reader again or a new guardian program
+			if c, ok := g.w.(io.Closer); ok {
+				c.Close()
+			}
+			g.w = nil
+		}
+	}
+}
+
+// Close writes the queued lines and closes the writer of the guardian log if
+// it is an io.Closer, e.g. the standard input of the guardian program, which
+// waits for the program to exit
+func (g *GuardianLog) Close() error {
+	g.mu.Lock()
+	if g.closed {
+		g.mu.Unlock()
+		return nil
+	}
+	g.closed = true
+	close(g.lines)
+	g.mu.Unlock()
+
+	<-g.done
+	var errs []error
+	if n := g.dropped.Load(); n > 0 {
+		err := fmt.Errorf("%d guardian log lines dropped", n)
+		if g.err != nil {
+			err = fmt.Errorf("%s: %w", err.Error(), g.err)
+		}
+		errs = append(errs, err)
+	}
+	if c, ok := g.w.(io.Closer); ok {
+		errs = append(errs, c.Close())
+	}
+	return errors.Join(errs...)
+}
+
+// write queues the line, it doesn't wait for the line to be written
+func (g *GuardianLog) write(line string) error {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+	if g.closed {
+		return errors.New("guardian log closed")
+	}
+	select {
+	case g.lines <- line:
+		return nil
+	default:
+		g.dropped.Add(1)
+		return errors.New("guardian log queue is full")
+	}
+}
+
+// guardianLogLine returns the summary of the transaction in the httpd-guardian
+// format, that is the Apache combined log format followed by the transaction
+// id, the session id and the duration of the transaction in microseconds:
+//
+//	host remote_addr - - [time] "request_line" status bytes "referer" "user_agent" id "-" duration
+func (tx *Transaction) guardianLogLine() string {
+	ts := time.Unix(0, tx.Timestamp)
+	host, _ := tx.variables.requestHeaders.GetFirst("host")
+	referer, _ := tx.variables.requestHeaders.GetFirst("referer")
+	userAgent, _ := tx.variables.requestHeaders.GetFirst("user-agent")
+	status := tx.variables.responseStatus.Get()
+	if tx.IsInterrupted() {
+		status = strconv.Itoa(tx.interruption.Status)
+	}
+
+	var sb strings.Builder
+	sb.WriteString(guardianField(host))
+	sb.WriteByte(' ')
+	sb.WriteString(guardianField(tx.variables.remoteAddr.Get()))
+	sb.WriteString(" - - [")
+	sb.WriteString(ts.Format("02/Jan/2006:15:04:05 -0700"))
+	sb.WriteString("] ")
+	sb.WriteString(strconv.Quote(tx.variables.requestLine.Get()))
+	sb.WriteByte(' ')
+	sb.WriteString(guardianField(status))
+	sb.WriteByte(' ')
+	sb.WriteString(guardianField(tx.variables.responseContentLength.Get()))
+	sb.WriteByte(' ')
+	sb.WriteString(strconv.Quote(referer))
+	sb.WriteByte(' ')
+	sb.WriteString(strconv.Quote(userAgent))
+	sb.WriteByte(' ')
+	sb.WriteString(tx.id)
+	sb.WriteString(` "-" `)
+	sb.WriteString(strconv.FormatInt(tx.WAF.now().Sub(ts).Microseconds(), 10))
+	sb.WriteByte('\n')
+	return sb.String()
+}
+
+// guardianField returns the value of an unquoted field, "-" if it is empty
+func guardianField(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return strings.Map(func(r rune) rune {
+		if r == ' ' || r == '\n' || r == '\r' || r == '\t' {
+			return '_'
+		}
+		return r
+	}, s)
+}
+
+// writeGuardianLog writes the summary of the transaction to the guardian log
+func (tx *Transaction) writeGuardianLog() {
+	if tx.WAF.GuardianLog == nil {
+		return
+	}
+	if err := tx.WAF.GuardianLog.write(tx.guardianLogLine()); err != nil {
+		tx.debugLogger.Error().
+			Err(err).
+			Msg("Failed to queue guardian log line")
+	}
+}
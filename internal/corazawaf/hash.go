@@ -0,0 +1,235 @@
+// Copyright 2024 Juan Pablo Tosso and the OWASP Coraza contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package corazawaf
+
+import (
+	"crypto/hmac"
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"html"
+	"io"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+// defaultHashParam is the parameter signed links carry the signature in
+// when SecHashParam is not configured
+const defaultHashParam = "hmac"
+
+// HashKeyMode is the value the SecHashKey key is combined with to sign links
+type HashKeyMode int
+
+const (
+	// HashKeyOnly signs the links with the key alone
+	HashKeyOnly HashKeyMode = iota
+	// HashKeyRemoteIP combines the key with the client address, so
+	// signed links can't be replayed by other clients
+	HashKeyRemoteIP
+)
+
+// HashLinkType is the kind of link of a response body signed by the hash engine
+type HashLinkType int
+
+const (
+	// HashHref is the href attribute of the a elements
+	HashHref HashLinkType = iota
+	// HashFormAction is the action attribute of the form elements
+	HashFormAction
+	// HashIframeSrc is the src attribute of the iframe elements
+	HashIframeSrc
+	// HashFrameSrc is the src attribute of the frame elements
+	HashFrameSrc
+	// HashScriptSrc is the src attribute of the script elements
+	HashScriptSrc
+)
+
+// ParseHashLinkType parses the link types of SecHashMethodRx and
+// SecHashMethodPm, e.g. HashHref
+func ParseHashLinkType(s string) (HashLinkType, error) {
+	switch strings.ToLower(s) {
+	case "hashhref":
+		return HashHref, nil
+	case "hashformaction":
+		return HashFormAction, nil
+	case "hashiframesrc":
+		return HashIframeSrc, nil
+	case "hashframesrc":
+		return HashFrameSrc, nil
+	case "hashscriptsrc":
+		return HashScriptSrc, nil
+	}
+	return 0, fmt.Errorf("invalid hash link type %q", s)
+}
+
+// HashMethod selects the links of a type the hash engine signs
+type HashMethod struct {
+	Type HashLinkType
+	// Match returns true if the link has to be signed
+	Match func(link string) bool
+}
+
+// hashLinkTypes maps the elements and attributes holding links to their type
+var hashLinkTypes = map[string]HashLinkType{
+	"a href":      HashHref,
+	"form action": HashFormAction,
+	"iframe src":  HashIframeSrc,
+	"frame src":   HashFrameSrc,
+	"script src":  HashScriptSrc,
+}
+
+// hashLinkRegex finds the attributes of the elements in hashLinkTypes, the
+// submatches are the element, the attribute and the quoted or bare value
+var hashLinkRegex = regexp.MustCompile(`(?is)<(a|form|i?frame|script)\b[^>]*?\s(href|action|src)\s*=\s*("[^"]*"|'[^']*'|[^\s"'>]+)`)
+
+// hashKey returns the key links are signed with for the transaction
+func (tx *Transaction) hashKey() []byte {
+	key := tx.WAF.HashKey
+	if tx.WAF.HashKeyMode == HashKeyRemoteIP {
+		key = append(key[:len(key):len(key)], tx.variables.remoteAddr.Get()...)
+	}
+	return key
+}
+
+func (tx *Transaction) hashParam() string {
+	if tx.WAF.HashParam == "" {
+		return defaultHashParam
+	}
+	return tx.WAF.HashParam
+}
+
+// hashSignature returns the HMAC-SHA1 signature of the path and query of the uri
+func (tx *Transaction) hashSignature(u *url.URL) string {
+	link := u.EscapedPath()
+	if u.RawQuery != "" {
+		link += "?" + u.RawQuery
+	}
+	mac := hmac.New(sha1.New, tx.hashKey())
+	mac.Write([]byte(link))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// ValidateHash returns true if the uri carries a valid signature of the hash
+// engine, or if the transaction doesn't enforce them. It is used by the
+// @validateHash operator.
+func (tx *Transaction) ValidateHash(uri string) bool {
+	if !tx.HashEnforcement {
+		return true
+	}
+	u, err := url.Parse(uri)
+	if err != nil {
+		return false
+	}
+	param := tx.hashParam() + "="
+	var signature string
+	var query []string
+	for _, p := range strings.Split(u.RawQuery, "&") {
+		if v, ok := strings.CutPrefix(p, param); ok && signature == "" {
+			signature = v
+			continue
+		}
+		query = append(query, p)
+	}
+	if signature == "" {
+		return false
+	}
+	u.RawQuery = strings.Join(query, "&")
+	return hmac.Equal([]byte(signature), []byte(tx.hashSignature(u)))
+}
+
+// hashResponseBody signs the links of the HTML response body selected by the
+// hash methods, appending their signature as the hash parameter
+func (tx *Transaction) hashResponseBody() error {
+	if !tx.HashEngine || len(tx.WAF.HashMethods) == 0 || len(tx.WAF.HashKey) == 0 {
+		return nil
+	}
+	if !strings.Contains(tx.variables.responseContentType.Get(), "html") {
+		return nil
+	}
+
+	reader, err := tx.responseBodyBuffer.Reader()
+	if err != nil {
+		return err
+	}
+	body, err := io.ReadAll(reader)
+	if err != nil {
+		return err
+	}
+	base, err := url.Parse(tx.variables.requestURIRaw.Get())
+	if err != nil {
+		return err
+	}
+	host, _ := tx.variables.requestHeaders.GetFirst("host")
+
+	var sb strings.Builder
+	signed := 0
+	last := 0
+	for _, m := range hashLinkRegex.FindAllStringSubmatchIndex(string(body), -1) {
+		element, attr := strings.ToLower(string(body[m[2]:m[3]])), strings.ToLower(string(body[m[4]:m[5]]))
+		typ, ok := hashLinkTypes[element+" "+attr]
+		if !ok {
+			continue
+		}
+		value := string(body[m[6]:m[7]])
+		quote := ""
+		if value[0] == '"' || value[0] == '\'' {
+			quote = value[:1]
+			value = value[1 : len(value)-1]
+		}
+		link := html.UnescapeString(value)
+		if !tx.hashMethodsMatch(typ, link) {
+			continue
+		}
+		u, err := url.Parse(link)
+		if err != nil || (u.Scheme != "" && u.Scheme != "http" && u.Scheme != "https") ||
+			(u.Host != "" && !strings.EqualFold(u.Host, host)) || (u.Path == "" && u.RawQuery == "") {
+			// only the links to the protected site are signed
+			continue
+		}
+
+		separator := "?"
+		if u.RawQuery != "" || u.ForceQuery {
+			separator = "&amp;"
+		}
+		signature := tx.hashSignature(base.ResolveReference(u))
+		// the signature goes before the fragment, if any
+		valueEnd := m[6] + len(quote) + len(value)
+		if i := strings.IndexByte(value, '#'); i >= 0 {
+			valueEnd = m[6] + len(quote) + i
+		}
+		sb.Write(body[last:valueEnd])
+		sb.WriteString(separator + tx.hashParam() + "=" + signature)
+		last = valueEnd
+		signed++
+	}
+	if signed == 0 {
+		return nil
+	}
+	sb.Write(body[last:])
+
+	if int64(sb.Len()) > tx.responseBodyBuffer.options.Limit {
+		tx.debugLogger.Warn().
+			Int("links", signed).
+			Msg("Response body links not signed, the signed body exceeds the response body limit")
+		return nil
+	}
+	if err := tx.responseBodyBuffer.Reset(); err != nil {
+		return err
+	}
+	if _, err := tx.responseBodyBuffer.Write([]byte(sb.String())); err != nil {
+		return err
+	}
+	tx.debugLogger.Debug().Int("links", signed).Msg("Signed response body links")
+	return nil
+}
+
+func (tx *Transaction) hashMethodsMatch(typ HashLinkType, link string) bool {
+	for _, m := range tx.WAF.HashMethods {
+		if m.Type == typ && m.Match(link) {
+			return true
+		}
+	}
+	return false
+}
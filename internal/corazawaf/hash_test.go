@@ -0,0 +1,136 @@
+// Copyright 2024 Juan Pablo Tosso and the OWASP Coraza contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package corazawaf
+
+import (
+	"io"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+func newHashTestWAF() *WAF {
+	waf := NewWAF()
+	waf.ResponseBodyAccess = true
+	waf.ResponseBodyMimeTypes = []string{"text/html"}
+	waf.HashEngine = true
+	waf.HashKey = []byte("secret")
+	waf.HashMethods = []HashMethod{
+		{Type: HashHref, Match: regexp.MustCompile(`account`).MatchString},
+		{Type: HashFormAction, Match: func(string) bool { return true }},
+	}
+	return waf
+}
+
+// signResponseBody returns the body after being processed by a transaction of the WAF
+func signResponseBody(t *testing.T, waf *WAF, uri, body string) string {
+	t.Helper()
+	tx := waf.NewTransaction()
+	defer tx.Close()
+	tx.ProcessConnection("127.0.0.1", 0, "", 0)
+	tx.ProcessURI(uri, "GET", "HTTP/1.1")
+	tx.AddRequestHeader("Host", "example.com")
+	tx.ProcessRequestHeaders()
+	tx.AddResponseHeader("Content-Type", "text/html")
+	tx.ProcessResponseHeaders(200, "HTTP/1.1")
+	if _, _, err := tx.WriteResponseBody([]byte(body)); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := tx.ProcessResponseBody(); err != nil {
+		t.Fatal(err)
+	}
+	r, err := tx.ResponseBodyReader()
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(b)
+}
+
+func TestHashResponseBody(t *testing.T) {
+	waf := newHashTestWAF()
+	body := signResponseBody(t, waf, "/shop/index.html", `<html>
+<a href="/account/edit?id=1#top">edit</a>
+<a class='x' href='account/view'>view</a>
+<a href="/about">about</a>
+<a href="https://other.com/account">other</a>
+<a href="http://example.com/account">self</a>
+<form method="post" action="/login"></form>
+<img src="/account/avatar.png">
+</html>`)
+
+	signedLink := regexp.MustCompile(`(?:href|action)=["']([^"']*hmac=[0-9a-f]{40})`)
+	var links []string
+	for _, m := range signedLink.FindAllStringSubmatch(body, -1) {
+		links = append(links, m[1])
+	}
+	if want, have := 4, len(links); want != have {
+		t.Fatalf("unexpected number of signed links, want %d, have %d: %s", want, have, body)
+	}
+	if !strings.Contains(body, `/account/edit?id=1&amp;hmac=`) || !strings.Contains(body, `#top"`) {
+		t.Errorf("expected the signature to be appended to the query before the fragment: %s", body)
+	}
+
+	tx := waf.NewTransaction()
+	defer tx.Close()
+	tx.ProcessConnection("127.0.0.1", 0, "", 0)
+	for _, want := range []string{"/account/edit?id=1&hmac=", "/shop/account/view?hmac=", "/login?hmac="} {
+		found := false
+		for _, link := range links {
+			link = strings.ReplaceAll(link, "&amp;", "&")
+			link = strings.TrimPrefix(link, "http://example.com")
+			if strings.HasPrefix(link, "account/") {
+				link = "/shop/" + link
+			}
+			if strings.HasPrefix(link, want) {
+				found = true
+				if !tx.ValidateHash(link) {
+					t.Errorf("expected %q to be valid", link)
+				}
+				if tx.ValidateHash(strings.Replace(link, "hmac=", "hmac=0", 1)) {
+					t.Errorf("expected tampered %q to be invalid", link)
+				}
+			}
+		}
+		if !found {
+			t.Errorf("expected a link starting with %q in %v", want, links)
+		}
+	}
+	if tx.ValidateHash("/account/edit?id=2") {
+		t.Error("expected a link without signature to be invalid")
+	}
+
+	tx.HashEnforcement = false
+	if !tx.ValidateHash("/account/edit?id=2") {
+		t.Error("expected the signature not to be enforced")
+	}
+}
+
+func TestHashKeyRemoteIP(t *testing.T) {
+	waf := newHashTestWAF()
+	waf.HashKeyMode = HashKeyRemoteIP
+	body := signResponseBody(t, waf, "/", `<a href="/account">account</a>`)
+	link := strings.TrimSuffix(strings.TrimPrefix(body, `<a href="`), `">account</a>`)
+
+	for addr, want := range map[string]bool{"127.0.0.1": true, "10.0.0.1": false} {
+		tx := waf.NewTransaction()
+		tx.ProcessConnection(addr, 0, "", 0)
+		if have := tx.ValidateHash(link); want != have {
+			t.Errorf("unexpected validation of %q from %s, want %t, have %t", link, addr, want, have)
+		}
+		tx.Close()
+	}
+}
+
+func TestHashResponseBodyDisabled(t *testing.T) {
+	waf := newHashTestWAF()
+	waf.HashEngine = false
+	body := `<a href="/account">account</a>`
+	if want, have := body, signResponseBody(t, waf, "/", body); want != have {
+		t.Errorf("unexpected body, want %q, have %q", want, have)
+	}
+}
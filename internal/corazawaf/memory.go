@@ -0,0 +1,114 @@
+// Copyright 2024 Juan Pablo Tosso and the OWASP Coraza contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package corazawaf
+
+import (
+	"strconv"
+
+	"github.com/ad3n/seclang/internal/collections"
+	"github.com/ad3n/seclang/internal/corazatypes"
+	"github.com/corazawaf/coraza/v3/collection"
+	"github.com/corazawaf/coraza/v3/types"
+	"github.com/corazawaf/coraza/v3/types/variables"
+)
+
+// initMemoryCounter accounts the keys and values of the collections of the
+// transaction in its memory counter as they change. Collections derived from
+// others, like ARGS or ARGS_NAMES, are not counted again.
+func (tx *Transaction) initMemoryCounter() {
+	tx.variables.All(func(_ variables.RuleVariable, col collection.Collection) bool {
+		if c, ok := col.(interface {
+			SetMemoryCounter(*collections.MemoryCounter)
+		}); ok {
+			c.SetMemoryCounter(&tx.memory)
+		}
+		return true
+	})
+}
+
+// MemoryUsage returns the approximate memory used by the data of the transaction,
+// in bytes: the keys and values of the collections, the bodies buffered in memory
+// and the matched data. Collections derived from others, like ARGS or ARGS_NAMES,
+// are not counted again.
+func (tx *Transaction) MemoryUsage() int64 {
+	usage := tx.memory.Usage()
+	for _, b := range []*BodyBuffer{tx.requestBodyBuffer, tx.responseBodyBuffer} {
+		if b.writer == nil {
+			usage += b.length
+		}
+	}
+	return usage
+}
+
+// initMemoryVariables creates MEMORY_USAGE and MEMORY_LIMIT_EXCEEDED, they
+// are derived from the memory counter of the transaction when they are read
+func (tx *Transaction) initMemoryVariables() {
+	tx.variables.memoryUsage = collections.NewLazySingle(corazatypes.MemoryUsage, func() string {
+		return strconv.FormatInt(tx.MemoryUsage(), 10)
+	})
+	tx.variables.memoryLimitExceeded = collections.NewLazySingle(corazatypes.MemoryLimitExceeded, func() string {
+		if tx.memoryLimitExceeded {
+			return "1"
+		}
+		return ""
+	})
+}
+
+// reserveMemory returns whether size bytes of data, e.g. a header or an
+// argument, can be added to the transaction without exceeding
+// TransactionMemoryLimit. Otherwise the transaction is flagged and the data
+// must be dropped, so the data added after the limit is exceeded doesn't
+// amplify the memory used.
+func (tx *Transaction) reserveMemory(size int) bool {
+	limit := tx.WAF.TransactionMemoryLimit
+	if limit <= 0 {
+		return true
+	}
+	if tx.memoryLimitExceeded {
+		return false
+	}
+	usage := tx.MemoryUsage() + int64(size)
+	if usage <= limit {
+		return true
+	}
+	tx.exceedMemoryLimit(usage)
+	return false
+}
+
+// exceedMemoryLimit flags the transaction as exceeding TransactionMemoryLimit
+func (tx *Transaction) exceedMemoryLimit(usage int64) {
+	tx.memoryLimitExceeded = true
+	tx.debugLogger.Warn().
+		Str("memory_usage", strconv.FormatInt(usage, 10)).
+		Str("memory_limit", strconv.FormatInt(tx.WAF.TransactionMemoryLimit, 10)).
+		Bool("reject", tx.WAF.TransactionMemoryLimitReject).
+		Msg("Transaction exceeded the memory limit")
+}
+
+// checkMemoryLimit flags the transaction once its memory exceeds
+// TransactionMemoryLimit, e.g. because of a body, and unless the limit action
+// is Log, interrupts it before evaluating the rule. The interruption is only
+// enforced when the rule engine is On. It returns true if the transaction was
+// interrupted.
+func (tx *Transaction) checkMemoryLimit(r *Rule) bool {
+	limit := tx.WAF.TransactionMemoryLimit
+	if limit <= 0 {
+		return false
+	}
+	if !tx.memoryLimitExceeded {
+		usage := tx.MemoryUsage()
+		if usage <= limit {
+			return false
+		}
+		tx.exceedMemoryLimit(usage)
+	}
+	if tx.WAF.TransactionMemoryLimitReject {
+		tx.Interrupt(&types.Interruption{
+			RuleID: r.ID_,
+			Status: 413,
+			Action: "deny",
+		})
+	}
+	return tx.interruption != nil
+}
@@ -0,0 +1,117 @@
+// Copyright 2024 Juan Pablo Tosso and the OWASP Coraza contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package corazawaf
+
+import (
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/corazawaf/coraza/v3/types"
+)
+
+func TestMemoryUsage(t *testing.T) {
+	tx := NewWAF().NewTransaction()
+	defer tx.Close()
+
+	before := tx.MemoryUsage()
+	tx.AddGetRequestArgument("a", "bbb")
+	// ARGS and ARGS_NAMES are views of ARGS_GET and are not counted again
+	if want, have := before+4, tx.MemoryUsage(); want != have {
+		t.Errorf("unexpected memory usage, want %d, have %d", want, have)
+	}
+
+	tx.RequestBodyAccess = true
+	if _, _, err := tx.WriteRequestBody([]byte("body")); err != nil {
+		t.Fatal(err)
+	}
+	if want, have := before+8, tx.MemoryUsage(); want != have {
+		t.Errorf("unexpected memory usage, want %d, have %d", want, have)
+	}
+
+	tx.variables.argsGet.Set("a", []string{"b"})
+	if want, have := before+6, tx.MemoryUsage(); want != have {
+		t.Errorf("unexpected memory usage, want %d, have %d", want, have)
+	}
+	tx.variables.argsGet.Remove("a")
+	if want, have := before+4, tx.MemoryUsage(); want != have {
+		t.Errorf("unexpected memory usage, want %d, have %d", want, have)
+	}
+}
+
+func TestMemoryUsageOfPooledTransaction(t *testing.T) {
+	waf := NewWAF()
+	tx := waf.NewTransaction()
+	before := tx.MemoryUsage()
+	tx.AddRequestHeader("X-Large", strings.Repeat("a", 2048))
+	if err := tx.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	tx = waf.NewTransaction()
+	defer tx.Close()
+	if want, have := before, tx.MemoryUsage(); want != have {
+		t.Errorf("unexpected memory usage, want %d, have %d", want, have)
+	}
+}
+
+func TestTransactionMemoryLimit(t *testing.T) {
+	tests := map[string]struct {
+		reject    bool
+		engine    types.RuleEngineStatus
+		header    string
+		interrupt bool
+		exceeded  bool
+	}{
+		"under the limit": {reject: true, engine: types.RuleEngineOn, header: "small"},
+		"reject":          {reject: true, engine: types.RuleEngineOn, header: strings.Repeat("a", 2048), interrupt: true, exceeded: true},
+		"log":             {reject: false, engine: types.RuleEngineOn, header: strings.Repeat("a", 2048), exceeded: true},
+		"detection only":  {reject: true, engine: types.RuleEngineDetectionOnly, header: strings.Repeat("a", 2048), exceeded: true},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			waf := NewWAF()
+			waf.RuleEngine = tt.engine
+			waf.TransactionMemoryLimit = 1024
+			waf.TransactionMemoryLimitReject = tt.reject
+			r := NewRule()
+			r.ID_ = 1
+			r.Phase_ = types.PhaseRequestHeaders
+			if err := waf.Rules.Add(r); err != nil {
+				t.Fatal(err)
+			}
+			tx := waf.NewTransaction()
+			defer tx.Close()
+
+			tx.AddRequestHeader("X-Large", tt.header)
+			// the headers exceeding the limit are dropped
+			if want, have := !tt.exceeded, len(tx.variables.requestHeaders.Get("x-large")) > 0; want != have {
+				t.Errorf("unexpected header added, want %t, have %t", want, have)
+			}
+			tx.AddRequestHeader("X-Small", "a")
+			if want, have := !tt.exceeded, len(tx.variables.requestHeaders.Get("x-small")) > 0; want != have {
+				t.Errorf("unexpected header added after the limit, want %t, have %t", want, have)
+			}
+			it := tx.ProcessRequestHeaders()
+			if want, have := tt.interrupt, it != nil; want != have {
+				t.Fatalf("unexpected interruption, want %t, have %t", want, have)
+			}
+			if it != nil {
+				if it.Status != 413 {
+					t.Errorf("unexpected status, want 413, have %d", it.Status)
+				}
+				if it.RuleID != 1 {
+					t.Errorf("unexpected rule id, want 1, have %d", it.RuleID)
+				}
+			}
+			exceeded := tx.variables.memoryLimitExceeded.Get() == "1"
+			if want, have := tt.exceeded, exceeded; want != have {
+				t.Errorf("unexpected memory limit exceeded flag, want %t, have %t", want, have)
+			}
+			if want, have := strconv.FormatInt(tx.MemoryUsage(), 10), tx.variables.memoryUsage.Get(); want != have {
+				t.Errorf("unexpected memory usage, want %s, have %s", want, have)
+			}
+		})
+	}
+}
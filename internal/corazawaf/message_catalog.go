@@ -0,0 +1,65 @@
+// Copyright 2024 Juan Pablo Tosso and the OWASP Coraza contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package corazawaf
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/ad3n/seclang/experimental/plugins/macro"
+	"github.com/ad3n/seclang/experimental/plugins/plugintypes"
+)
+
+// MessageCatalog maps rule ids to the messages that replace their msg when the
+// audit log is written, e.g. to present them in another language without
+// editing the rule files.
+type MessageCatalog map[int]macro.Macro
+
+// ParseMessageCatalog reads a catalog with one "ID MESSAGE" entry per line,
+// empty lines and lines starting with # are ignored. Messages can use macros,
+// which are expanded when the audit log is written.
+func ParseMessageCatalog(data []byte) (MessageCatalog, error) {
+	catalog := MessageCatalog{}
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || line[0] == '#' {
+			continue
+		}
+		id, msg, _ := strings.Cut(line, " ")
+		ruleID, err := strconv.Atoi(id)
+		if err != nil {
+			return nil, fmt.Errorf("invalid rule id %q in line %d", id, n)
+		}
+		m, err := macro.NewMacro(strings.TrimSpace(msg))
+		if err != nil {
+			return nil, fmt.Errorf("invalid message for rule %d in line %d: %s", ruleID, n, err.Error())
+		}
+		catalog[ruleID] = m
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return catalog, nil
+}
+
+// Merge adds the messages of other to the catalog, replacing the messages
+// of the rules already in the catalog
+func (c MessageCatalog) Merge(other MessageCatalog) {
+	for id, m := range other {
+		c[id] = m
+	}
+}
+
+// message returns the message of the catalog for the rule expanded with the
+// transaction, or msg if the rule is not in the catalog
+func (c MessageCatalog) message(tx plugintypes.TransactionState, ruleID int, msg string) string {
+	if m, ok := c[ruleID]; ok {
+		return m.Expand(tx)
+	}
+	return msg
+}
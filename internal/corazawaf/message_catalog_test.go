@@ -0,0 +1,48 @@
+// Copyright 2024 Juan Pablo Tosso and the OWASP Coraza contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package corazawaf
+
+import (
+	"testing"
+)
+
+func TestParseMessageCatalog(t *testing.T) {
+	catalog, err := ParseMessageCatalog([]byte(`
+# comment
+942100 Ataque de inyección SQL
+949110   Puntuación superada (total: %{TX.score})
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want, have := 2, len(catalog); want != have {
+		t.Fatalf("unexpected number of messages, want %d, have %d", want, have)
+	}
+
+	waf := NewWAF()
+	waf.MessageCatalog = catalog
+	tx := waf.NewTransaction()
+	defer tx.Close()
+	tx.variables.tx.Set("score", []string{"15"})
+	tests := map[int]string{
+		942100: "Ataque de inyección SQL",
+		949110: "Puntuación superada (total: 15)",
+		1:      "original",
+	}
+	for id, want := range tests {
+		if have := tx.LocalizedMessage(id, "original"); want != have {
+			t.Errorf("unexpected message for rule %d, want %q, have %q", id, want, have)
+		}
+	}
+
+	for name, data := range map[string]string{
+		"invalid id":    "abc message",
+		"empty message": "1",
+		"invalid macro": "1 %{}",
+	} {
+		if _, err := ParseMessageCatalog([]byte(data)); err == nil {
+			t.Errorf("expected error for %s", name)
+		}
+	}
+}
@@ -0,0 +1,37 @@
+// Copyright 2024 Juan Pablo Tosso and the OWASP Coraza contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package corazawaf
+
+import (
+	"sync"
+)
+
+// sharedOperatorValues keeps the values shared by the operators of a WAF,
+// e.g. the caches of their lookups
+type sharedOperatorValues struct {
+	mu     sync.Mutex
+	values map[any]any
+}
+
+// get returns the value of the key, created with create if it isn't set
+func (s *sharedOperatorValues) get(key any, create func() any) any {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if v, ok := s.values[key]; ok {
+		return v
+	}
+	if s.values == nil {
+		s.values = map[any]any{}
+	}
+	v := create()
+	s.values[key] = v
+	return v
+}
+
+// reset drops the values
+func (s *sharedOperatorValues) reset() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.values = nil
+}
@@ -0,0 +1,32 @@
+// Copyright 2024 Juan Pablo Tosso and the OWASP Coraza contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package corazawaf
+
+import "testing"
+
+func TestSharedOperatorValue(t *testing.T) {
+	type key struct{}
+	waf := NewWAF()
+	creates := 0
+	create := func() any {
+		creates++
+		return creates
+	}
+
+	for i := 0; i < 3; i++ {
+		tx := waf.NewTransaction()
+		if want, have := 1, tx.SharedOperatorValue(key{}, create); want != have {
+			t.Errorf("unexpected shared value, want %v, have %v", want, have)
+		}
+		tx.Close()
+	}
+	if want, have := 2, NewWAF().NewTransaction().SharedOperatorValue(key{}, create); want != have {
+		t.Errorf("unexpected value shared with another WAF, want %v, have %v", want, have)
+	}
+
+	waf.Apply(waf.Stage())
+	if want, have := 3, waf.NewTransaction().SharedOperatorValue(key{}, create); want != have {
+		t.Errorf("unexpected value kept after Apply, want %v, have %v", want, have)
+	}
+}
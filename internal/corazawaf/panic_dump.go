@@ -0,0 +1,232 @@
+// Copyright 2024 Juan Pablo Tosso and the OWASP Coraza contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package corazawaf
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"runtime/debug"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ad3n/seclang/internal/corazatypes"
+	"github.com/corazawaf/coraza/v3/collection"
+	"github.com/corazawaf/coraza/v3/types"
+	"github.com/corazawaf/coraza/v3/types/variables"
+)
+
+// PanicDump writes a forensic dump of the transactions whose rule evaluation
+// panics, as a JSON line with the phase, the rule being evaluated, the panic,
+// its stack and the variables of the transaction. The values are sanitized:
+// bodies, cookies, credentials, the request line, URI and path, the matched
+// values and the captures are redacted and the other values are truncated.
+// Dumps are written with a single call, so concurrent transactions don't
+// interleave.
+type PanicDump struct {
+	mu sync.Mutex
+	w  io.Writer
+
+	// Recover stops the panic once dumped and interrupts the transaction with
+	// a 500 status, as its remaining rules are not evaluated. Otherwise the
+	// panic is propagated to the connector.
+	Recover bool
+}
+
+// NewPanicDump returns a panic dump writing to w
+func NewPanicDump(w io.Writer, recoverPanics bool) *PanicDump {
+	return &PanicDump{w: w, Recover: recoverPanics}
+}
+
+// SetPanicDumpPath writes the panic dumps to the file at path, which can be
+// /dev/stdout or /dev/stderr. Panics are not handled if the path is empty.
+func (w *WAF) SetPanicDumpPath(path string, recoverPanics bool) error {
+	if path == "" {
+		w.PanicDump = nil
+		return nil
+	}
+	o, err := resolveLogPath(path)
+	if err != nil {
+		return err
+	}
+	w.PanicDump = NewPanicDump(o, recoverPanics)
+	return nil
+}
+
+func (d *PanicDump) write(data []byte) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	_, err := d.w.Write(data)
+	return err
+}
+
+// panicDumpValueLimit is the length above which the values of the dump are truncated
+const panicDumpValueLimit = 256
+
+// panicDumpRedactedVariables are the variables whose values are always redacted
+var panicDumpRedactedVariables = map[variables.RuleVariable]bool{
+	variables.RequestBody:            true,
+	variables.ResponseBody:           true,
+	corazatypes.StreamInputBody:      true,
+	corazatypes.StreamOutputBody:     true,
+	variables.RequestCookies:         true,
+	variables.FilesTmpContent:        true,
+	variables.ArgsPost:               true,
+	variables.XML:                    true,
+	variables.RequestXML:             true,
+	variables.ResponseXML:            true,
+	variables.QueryString:            true,
+	variables.RequestURI:             true,
+	variables.RequestURIRaw:          true,
+	variables.RequestLine:            true,
+	corazatypes.RequestURLNormalized: true,
+	corazatypes.RequestPathSegments:  true,
+	corazatypes.ResponseCookies:      true,
+	variables.MatchedVar:             true,
+	// the values of the multipart fields before their transcoding
+	corazatypes.MultipartOriginalValues: true,
+	variables.MatchedVars:               true,
+}
+
+// panicDumpSensitiveKeys redact the values whose key contains one of them
+var panicDumpSensitiveKeys = []string{
+	"authorization",
+	"cookie",
+	"passw",
+	"secret",
+	"token",
+	"session",
+	"apikey",
+	"api_key",
+	"api-key",
+}
+
+type panicDumpEntry struct {
+	Time          string                      `json:"time"`
+	TransactionID string                      `json:"transaction_id"`
+	Phase         int                         `json:"phase"`
+	Rule          *panicDumpRule              `json:"rule,omitempty"`
+	Panic         string                      `json:"panic"`
+	Stack         string                      `json:"stack"`
+	Variables     map[string][]panicDumpValue `json:"variables"`
+}
+
+type panicDumpRule struct {
+	ID   int    `json:"id"`
+	File string `json:"file,omitempty"`
+	Line int    `json:"line,omitempty"`
+}
+
+type panicDumpValue struct {
+	Key   string `json:"key,omitempty"`
+	Value string `json:"value"`
+}
+
+// rulePanicked dumps the transaction whose rule r panicked with v while
+// evaluating the phase, then panics again with v unless the dump recovers
+func (tx *Transaction) rulePanicked(v any, phase types.RulePhase, r *Rule) {
+	stack := debug.Stack()
+	tx.debugLogger.Error().
+		Int("phase", int(phase)).
+		Int("rule_id", ruleID(r)).
+		Str("panic", fmt.Sprint(v)).
+		Bool("recover", tx.WAF.PanicDump.Recover).
+		Msg("Rule evaluation panicked")
+
+	if err := tx.WAF.PanicDump.write(tx.panicDump(v, phase, r, stack)); err != nil {
+		tx.debugLogger.Error().
+			Err(err).
+			Msg("Failed to write panic dump")
+	}
+	if !tx.WAF.PanicDump.Recover {
+		panic(v)
+	}
+	tx.Capture = false
+	tx.Skip = 0
+	// the remaining rules of the phase are not evaluated, the transaction
+	// fails closed
+	tx.Interrupt(&types.Interruption{
+		RuleID: ruleID(r),
+		Status: 500,
+		Action: "deny",
+	})
+}
+
+func ruleID(r *Rule) int {
+	if r == nil {
+		return 0
+	}
+	return r.ID_
+}
+
+// panicDump returns the sanitized dump of the transaction as a JSON line
+func (tx *Transaction) panicDump(v any, phase types.RulePhase, r *Rule, stack []byte) []byte {
+	entry := panicDumpEntry{
+		Time:          tx.WAF.now().Format(time.RFC3339Nano),
+		TransactionID: tx.id,
+		Phase:         int(phase),
+		Panic:         fmt.Sprint(v),
+		Stack:         string(stack),
+		Variables:     map[string][]panicDumpValue{},
+	}
+	if r != nil {
+		entry.Rule = &panicDumpRule{ID: r.ID_, File: r.File_, Line: r.Line_}
+	}
+	tx.variables.All(func(rv variables.RuleVariable, col collection.Collection) bool {
+		name := corazatypes.VariableName(rv)
+		for _, md := range col.FindAll() {
+			// unset single values
+			if md.Key() == "" && md.Value() == "" {
+				continue
+			}
+			origin := rv
+			if rv == variables.Args && len(tx.variables.argsPost.Get(md.Key())) > 0 {
+				// ARGS holds the values of ARGS_POST too
+				origin = variables.ArgsPost
+			}
+			entry.Variables[name] = append(entry.Variables[name], panicDumpValue{
+				Key:   md.Key(),
+				Value: sanitizePanicDumpValue(origin, md.Key(), md.Value(), tx.isCapture(rv, md.Key())),
+			})
+		}
+		return true
+	})
+
+	// the entry only holds strings and numbers, it can't fail to marshal
+	data, _ := json.Marshal(entry)
+	return append(data, '\n')
+}
+
+// isCapture returns whether the value of the variable at key holds a part of
+// a matched value: the numbered captures, TX:0 to TX:9 by default, and the
+// named captures
+func (tx *Transaction) isCapture(rv variables.RuleVariable, key string) bool {
+	if rv != variables.TX {
+		return false
+	}
+	key = strings.ToLower(key)
+	_, err := strconv.Atoi(key)
+	return err == nil || tx.namedCaptures[key]
+}
+
+// sanitizePanicDumpValue redacts the sensitive values and the captures,
+// keeping their length, and truncates the others to panicDumpValueLimit bytes
+func sanitizePanicDumpValue(rv variables.RuleVariable, key, value string, capture bool) string {
+	if value == "" {
+		return ""
+	}
+	key = strings.ToLower(key)
+	if capture || panicDumpRedactedVariables[rv] || slices.ContainsFunc(panicDumpSensitiveKeys, func(s string) bool {
+		return strings.Contains(key, s)
+	}) {
+		return "[redacted " + strconv.Itoa(len(value)) + " bytes]"
+	}
+	if len(value) > panicDumpValueLimit {
+		return value[:panicDumpValueLimit] + "[truncated " + strconv.Itoa(len(value)-panicDumpValueLimit) + " bytes]"
+	}
+	return value
+}
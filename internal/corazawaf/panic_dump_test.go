@@ -0,0 +1,183 @@
+// Copyright 2024 Juan Pablo Tosso and the OWASP Coraza contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package corazawaf
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/ad3n/seclang/experimental/plugins/plugintypes"
+	"github.com/ad3n/seclang/internal/corazatypes"
+	"github.com/corazawaf/coraza/v3/types"
+	"github.com/corazawaf/coraza/v3/types/variables"
+)
+
+type panicOperator struct{}
+
+func (*panicOperator) Evaluate(plugintypes.TransactionState, string) bool {
+	panic("operator failure")
+}
+
+func newPanicDumpWAF(t *testing.T, out *bytes.Buffer, recoverPanics bool) *WAF {
+	t.Helper()
+	waf := NewWAF()
+	waf.PanicDump = NewPanicDump(out, recoverPanics)
+	r := NewRule()
+	r.ID_ = 1
+	r.File_ = "rules.conf"
+	r.Line_ = 3
+	r.Phase_ = types.PhaseRequestHeaders
+	if err := r.AddVariable(variables.RequestHeaders, "", false); err != nil {
+		t.Fatal(err)
+	}
+	r.SetOperator(&panicOperator{}, "@panic", "")
+	if err := waf.Rules.Add(r); err != nil {
+		t.Fatal(err)
+	}
+	next := NewRule()
+	next.ID_ = 2
+	next.Phase_ = types.PhaseRequestHeaders
+	if err := waf.Rules.Add(next); err != nil {
+		t.Fatal(err)
+	}
+	return waf
+}
+
+func TestPanicDumpRecover(t *testing.T) {
+	out := &bytes.Buffer{}
+	waf := newPanicDumpWAF(t, out, true)
+	tx := waf.NewTransactionWithOptions(Options{ID: "panicking"})
+	tx.AddRequestHeader("Authorization", "Bearer abc")
+	tx.AddRequestHeader("User-Agent", strings.Repeat("a", 300))
+	tx.AddRequestHeader("Host", "example.com")
+	tx.AddGetRequestArgument("q", "select")
+	tx.AddPostRequestArgument("card", "4111")
+	it := tx.ProcessRequestHeaders()
+	if it == nil || it.Status != 500 || it.RuleID != 1 {
+		t.Errorf("unexpected interruption %+v", it)
+	}
+	if want, have := 0, len(tx.MatchedRules()); want != have {
+		t.Errorf("unexpected rules evaluated after the panic, want %d, have %d", want, have)
+	}
+
+	var dump struct {
+		TransactionID string `json:"transaction_id"`
+		Phase         int    `json:"phase"`
+		Rule          struct {
+			ID   int    `json:"id"`
+			File string `json:"file"`
+			Line int    `json:"line"`
+		} `json:"rule"`
+		Panic     string `json:"panic"`
+		Stack     string `json:"stack"`
+		Variables map[string][]struct {
+			Key   string `json:"key"`
+			Value string `json:"value"`
+		} `json:"variables"`
+	}
+	if err := json.Unmarshal(out.Bytes(), &dump); err != nil {
+		t.Fatalf("unexpected invalid dump %q: %s", out.String(), err.Error())
+	}
+	if want, have := "panicking", dump.TransactionID; want != have {
+		t.Errorf("unexpected transaction id, want %q, have %q", want, have)
+	}
+	if want, have := 1, dump.Phase; want != have {
+		t.Errorf("unexpected phase, want %d, have %d", want, have)
+	}
+	if dump.Rule.ID != 1 || dump.Rule.File != "rules.conf" || dump.Rule.Line != 3 {
+		t.Errorf("unexpected rule %+v", dump.Rule)
+	}
+	if want, have := "operator failure", dump.Panic; want != have {
+		t.Errorf("unexpected panic, want %q, have %q", want, have)
+	}
+	if !strings.Contains(dump.Stack, "panicOperator") {
+		t.Errorf("expected the stack to contain the panicking operator, have %q", dump.Stack)
+	}
+
+	headers := map[string]string{}
+	for _, v := range dump.Variables["REQUEST_HEADERS"] {
+		headers[v.Key] = v.Value
+	}
+	for key, want := range map[string]string{
+		"Authorization": "[redacted 10 bytes]",
+		"User-Agent":    strings.Repeat("a", 256) + "[truncated 44 bytes]",
+		"Host":          "example.com",
+	} {
+		if have := headers[key]; want != have {
+			t.Errorf("unexpected value of %s, want %q, have %q", key, want, have)
+		}
+	}
+	args := map[string]string{}
+	for _, v := range dump.Variables["ARGS"] {
+		args[v.Key] = v.Value
+	}
+	// ARGS holds the values of ARGS_POST too
+	for key, want := range map[string]string{
+		"q":    "select",
+		"card": "[redacted 4 bytes]",
+	} {
+		if have := args[key]; want != have {
+			t.Errorf("unexpected value of ARGS:%s, want %q, have %q", key, want, have)
+		}
+	}
+}
+
+func TestPanicDumpPanic(t *testing.T) {
+	out := &bytes.Buffer{}
+	waf := newPanicDumpWAF(t, out, false)
+	tx := waf.NewTransaction()
+	tx.AddRequestHeader("Host", "example.com")
+	defer func() {
+		if want, have := "operator failure", recover(); want != have {
+			t.Errorf("unexpected panic, want %v, have %v", want, have)
+		}
+		if out.Len() == 0 {
+			t.Error("expected the transaction to be dumped before panicking")
+		}
+	}()
+	tx.ProcessRequestHeaders()
+}
+
+func TestSanitizePanicDumpValue(t *testing.T) {
+	tests := []struct {
+		variable variables.RuleVariable
+		key      string
+		value    string
+		want     string
+	}{
+		{variables.Args, "q", "select", "select"},
+		{variables.Args, "q", "", ""},
+		{variables.Args, "Password", "hunter2", "[redacted 7 bytes]"},
+		{variables.ArgsPost, "csrf_token", "abc", "[redacted 3 bytes]"},
+		{variables.RequestHeaders, "x-api-key", "abcd", "[redacted 4 bytes]"},
+		{variables.ResponseHeaders, "set-cookie", "a=b", "[redacted 3 bytes]"},
+		{variables.RequestCookies, "lang", "en", "[redacted 2 bytes]"},
+		{variables.RequestBody, "", "a=b", "[redacted 3 bytes]"},
+		{variables.RequestURI, "", "/?card=4111", "[redacted 11 bytes]"},
+		{variables.QueryString, "", "card=4111", "[redacted 9 bytes]"},
+		{variables.RequestLine, "", "GET /?card=4111 HTTP/1.1", "[redacted 24 bytes]"},
+		{variables.MatchedVar, "", "4111", "[redacted 4 bytes]"},
+		{variables.MatchedVars, "ARGS:card", "4111", "[redacted 4 bytes]"},
+		{variables.TX, "1", "4111", "[redacted 4 bytes]"},
+		{variables.TX, "anomaly_score", "5", "5"},
+		{variables.TX, "User", "admin", "[redacted 5 bytes]"},
+		{corazatypes.MultipartOriginalValues, "name", "caf\xe9", "[redacted 4 bytes]"},
+		{corazatypes.ResponseCookies, "sid", "abc", "[redacted 3 bytes]"},
+		{corazatypes.RequestPathSegments, "1", "4111", "[redacted 4 bytes]"},
+		{variables.ArgsPost, "card", "4111", "[redacted 4 bytes]"},
+		{variables.XML, "", "<card>4111</card>", "[redacted 17 bytes]"},
+		{variables.RequestFilename, "", strings.Repeat("x", 257), strings.Repeat("x", 256) + "[truncated 1 bytes]"},
+	}
+	tx := NewWAF().NewTransaction()
+	tx.Capture = true
+	// named captures are redacted like the numbered ones
+	tx.CaptureNamedField("user", "admin")
+	for _, tt := range tests {
+		if have := sanitizePanicDumpValue(tt.variable, tt.key, tt.value, tx.isCapture(tt.variable, tt.key)); tt.want != have {
+			t.Errorf("unexpected value of %s:%s, want %q, have %q", corazatypes.VariableName(tt.variable), tt.key, tt.want, have)
+		}
+	}
+}
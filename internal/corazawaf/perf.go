@@ -0,0 +1,79 @@
+// Copyright 2024 Juan Pablo Tosso and the OWASP Coraza contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package corazawaf
+
+import (
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ad3n/seclang/internal/collections"
+	"github.com/ad3n/seclang/internal/corazatypes"
+	"github.com/corazawaf/coraza/v3/types"
+	"github.com/corazawaf/coraza/v3/types/variables"
+)
+
+// rulePerf is the duration of a rule that took longer than SecRulePerfTime
+type rulePerf struct {
+	id       int
+	duration time.Duration
+}
+
+// initPerfVariables creates the PERF_* variables, they are derived from the
+// stopwatches of the phases and the rules exceeding SecRulePerfTime when they
+// are read. The durations are in microseconds.
+func (tx *Transaction) initPerfVariables() {
+	v := &tx.variables
+	v.perfCombined = collections.NewLazySingle(corazatypes.PerfCombined, func() string {
+		combined := int64(0)
+		for _, d := range tx.stopWatches {
+			combined += d
+		}
+		return strconv.FormatInt(combined/1000, 10)
+	})
+	for i := range v.perfPhases {
+		phase := types.RulePhase(i + 1)
+		v.perfPhases[i] = collections.NewLazySingle(corazatypes.PerfPhase1+variables.RuleVariable(i), func() string {
+			return strconv.FormatInt(tx.stopWatches[phase]/1000, 10)
+		})
+	}
+	v.perfRules = collections.NewLazyMap(corazatypes.PerfRules, func(m *collections.Map) {
+		for _, p := range tx.perfRules {
+			m.Set(strconv.Itoa(p.id), []string{strconv.FormatInt(p.duration.Microseconds(), 10)})
+		}
+	})
+}
+
+// recordRulePerf records the duration of the rule if it exceeds SecRulePerfTime,
+// rules evaluated in several phases accumulate their durations
+func (tx *Transaction) recordRulePerf(id int, duration time.Duration) {
+	if duration < tx.WAF.RulePerfTime {
+		return
+	}
+	i := 0
+	for ; i < len(tx.perfRules); i++ {
+		if tx.perfRules[i].id == id {
+			tx.perfRules[i].duration += duration
+			break
+		}
+	}
+	if i == len(tx.perfRules) {
+		tx.perfRules = append(tx.perfRules, rulePerf{id: id, duration: duration})
+	}
+	tx.variables.perfRules.Invalidate()
+	tx.debugLogger.Debug().
+		Int("rule_id", id).
+		Str("duration", tx.perfRules[i].duration.String()).
+		Msg("Rule exceeded SecRulePerfTime")
+}
+
+// rulesPerformanceInfo returns the rules that took longer than SecRulePerfTime
+// with their duration in microseconds, e.g. 942100=1520, 942110=1043
+func (tx *Transaction) rulesPerformanceInfo() string {
+	info := make([]string, 0, len(tx.perfRules))
+	for _, p := range tx.perfRules {
+		info = append(info, strconv.Itoa(p.id)+"="+strconv.FormatInt(p.duration.Microseconds(), 10))
+	}
+	return strings.Join(info, ", ")
+}
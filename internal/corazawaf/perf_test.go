@@ -0,0 +1,78 @@
+// Copyright 2024 Juan Pablo Tosso and the OWASP Coraza contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package corazawaf
+
+import (
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ad3n/seclang/experimental/plugins/plugintypes"
+	"github.com/ad3n/seclang/internal/corazatypes"
+	"github.com/corazawaf/coraza/v3/collection"
+	"github.com/corazawaf/coraza/v3/types"
+	"github.com/corazawaf/coraza/v3/types/variables"
+)
+
+func TestRulePerf(t *testing.T) {
+	waf := NewWAF()
+	waf.RulePerfTime = time.Millisecond
+	tx := waf.NewTransaction()
+	defer tx.Close()
+	tx.AuditLogParts = types.AuditLogParts("ABH")
+
+	tx.recordRulePerf(1, 500*time.Microsecond)
+	tx.recordRulePerf(2, 2*time.Millisecond)
+	tx.recordRulePerf(3, time.Millisecond)
+	tx.recordRulePerf(2, 1500*time.Microsecond)
+
+	if have := tx.variables.perfRules.Get("1"); len(have) != 0 {
+		t.Errorf("unexpected fast rule duration, have %q", have)
+	}
+	if want, have := "3500", tx.variables.perfRules.Get("2"); len(have) != 1 || want != have[0] {
+		t.Errorf("unexpected rule duration, want %q, have %q", want, have)
+	}
+	// the durations read are updated
+	tx.recordRulePerf(2, time.Millisecond)
+	if want, have := "4500", tx.variables.perfRules.Get("2"); len(have) != 1 || want != have[0] {
+		t.Errorf("unexpected rule duration, want %q, have %q", want, have)
+	}
+	if want, have := "1000", tx.variables.perfRules.Get("3"); len(have) != 1 || want != have[0] {
+		t.Errorf("unexpected rule duration, want %q, have %q", want, have)
+	}
+	p, ok := tx.AuditLog().Transaction().Producer().(plugintypes.AuditLogTransactionProducerRulesPerformance)
+	if !ok {
+		t.Fatal("unexpected producer without rules performance")
+	}
+	if want, have := "2=4500, 3=1000", p.RulesPerformanceInfo(); want != have {
+		t.Errorf("unexpected rules performance info, want %q, have %q", want, have)
+	}
+}
+
+func TestPhasePerf(t *testing.T) {
+	waf := NewWAF()
+	tx := waf.NewTransaction()
+	defer tx.Close()
+
+	tx.stopWatches[types.PhaseRequestHeaders] = int64(3 * time.Millisecond)
+	tx.stopWatches[types.PhaseRequestBody] = int64(2 * time.Millisecond)
+
+	for v, want := range map[variables.RuleVariable]string{
+		corazatypes.PerfPhase1:   "3000",
+		corazatypes.PerfPhase2:   "2000",
+		corazatypes.PerfPhase3:   "0",
+		corazatypes.PerfCombined: "5000",
+	} {
+		if have := tx.Collection(v).(collection.Single).Get(); want != have {
+			t.Errorf("unexpected %s, want %q, have %q", corazatypes.VariableName(v), want, have)
+		}
+	}
+	for _, key := range tx.variables.tx.FindRegex(regexp.MustCompile("^perf_")) {
+		t.Errorf("unexpected TX key %s", key.Key())
+	}
+	if !strings.Contains(tx.GetStopWatch(), "p1=3000000, p2=2000000") {
+		t.Errorf("unexpected stopwatch %q", tx.GetStopWatch())
+	}
+}
@@ -0,0 +1,130 @@
+// Copyright 2024 Juan Pablo Tosso and the OWASP Coraza contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package corazawaf
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/ad3n/seclang/internal/collections"
+	"github.com/ad3n/seclang/internal/corazatypes"
+	"github.com/corazawaf/coraza/v3/types/variables"
+)
+
+// persistentCollectionNames are the names the persistent collections are
+// stored with in the PersistentStore of the WAF
+var persistentCollectionNames = map[variables.RuleVariable]string{
+	corazatypes.Global:   "global",
+	corazatypes.IP:       "ip",
+	corazatypes.Resource: "resource",
+	corazatypes.Session:  "session",
+	corazatypes.User:     "user",
+}
+
+// persistentCollection is a persistent collection initialized by initcol, it
+// keeps the data it was loaded with to only store the records that changed
+type persistentCollection struct {
+	variable variables.RuleVariable
+	key      string
+	loaded   map[string][]string
+}
+
+// InitCollection loads the record of the persistent collection for the key
+// from the PersistentStore of the WAF, or creates a new record flagged with
+// IS_NEW. The record is stored back when the transaction is closed if it
+// was changed, e.g. by setvar.
+func (tx *Transaction) InitCollection(variable variables.RuleVariable, key string) error {
+	name, ok := persistentCollectionNames[variable]
+	if !ok {
+		return fmt.Errorf("%s is not a persistent collection", corazatypes.VariableName(variable))
+	}
+	col := tx.Collection(variable).(*collections.Map)
+
+	// a collection initialized again is stored with its previous key first
+	for i, p := range tx.persistentCollections {
+		if p.variable == variable {
+			tx.persistCollection(p)
+			tx.persistentCollections = slices.Delete(tx.persistentCollections, i, i+1)
+			break
+		}
+	}
+
+	data, ok := tx.WAF.PersistentCollections.Get(name, key)
+	if !ok {
+		now := strconv.FormatInt(tx.WAF.now().Unix(), 10)
+		data = map[string][]string{
+			"CREATE_TIME":      {now},
+			"IS_NEW":           {"1"},
+			"KEY":              {key},
+			"LAST_UPDATE_TIME": {now},
+			"TIMEOUT":          {strconv.FormatInt(int64(tx.WAF.collectionTimeout().Seconds()), 10)},
+			"UPDATE_COUNTER":   {"0"},
+		}
+	}
+	col.Reset()
+	for field, values := range data {
+		col.Set(field, values)
+	}
+	tx.persistentCollections = append(tx.persistentCollections, persistentCollection{
+		variable: variable,
+		key:      key,
+		loaded:   collectionData(col),
+	})
+	tx.debugLogger.Debug().
+		Str("collection", name).
+		Str("key", key).
+		Bool("is_new", !ok).
+		Msg("Persistent collection initialized")
+	return nil
+}
+
+// collectionTimeout returns the time to live of the records created by initcol
+func (w *WAF) collectionTimeout() time.Duration {
+	if w.CollectionTimeout == 0 {
+		return collections.DefaultCollectionTimeout
+	}
+	return w.CollectionTimeout
+}
+
+// persistCollections stores the persistent collections changed by the
+// transaction
+func (tx *Transaction) persistCollections() {
+	for _, p := range tx.persistentCollections {
+		tx.persistCollection(p)
+	}
+	tx.persistentCollections = tx.persistentCollections[:0]
+}
+
+func (tx *Transaction) persistCollection(p persistentCollection) {
+	data := collectionData(tx.Collection(p.variable).(*collections.Map))
+	if maps.EqualFunc(data, p.loaded, slices.Equal) {
+		return
+	}
+	// only the fields changed by the transaction are stored, the ones changed
+	// meanwhile by the other writers of the record are kept
+	tx.WAF.PersistentCollections.Update(persistentCollectionNames[p.variable], p.key, func(stored map[string][]string) {
+		for field, values := range data {
+			if !slices.Equal(values, p.loaded[field]) {
+				stored[field] = values
+			}
+		}
+		for field := range p.loaded {
+			if _, ok := data[field]; !ok {
+				delete(stored, field)
+			}
+		}
+	})
+}
+
+// collectionData returns the values of the collection by key
+func collectionData(col *collections.Map) map[string][]string {
+	data := map[string][]string{}
+	for _, md := range col.FindAll() {
+		data[md.Key()] = append(data[md.Key()], md.Value())
+	}
+	return data
+}
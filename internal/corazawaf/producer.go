@@ -0,0 +1,128 @@
+// Copyright 2024 Juan Pablo Tosso and the OWASP Coraza contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package corazawaf
+
+import (
+	"path"
+	"runtime/debug"
+	"slices"
+	"strings"
+	"sync"
+)
+
+// engineModule is the module path used to look up the engine version
+const engineModule = "github.com/ad3n/seclang"
+
+// Producer identifies what produced an audit log: the connector embedding the
+// engine and the rulesets it runs. All formatters report it the same way so logs
+// can be grouped by connector, engine and ruleset versions.
+type Producer struct {
+	// Connector is the name of the connector, for example, apache-modcoraza
+	Connector string
+	// ConnectorVersion is the version of the connector
+	ConnectorVersion string
+	// Rulesets lists the rulesets loaded by the WAF, in the order they were
+	// declared with SecComponentSignature, see AddRuleset
+	Rulesets []Ruleset
+}
+
+// Ruleset identifies a set of rules, e.g. the OWASP CRS
+type Ruleset struct {
+	Name    string
+	Version string
+}
+
+// ParseRuleset parses a component signature like "OWASP_CRS/4.0.0", the version
+// follows the last slash and is optional.
+func ParseRuleset(signature string) Ruleset {
+	name, version, ok := cutLast(strings.TrimSpace(signature), "/")
+	if !ok || name == "" {
+		return Ruleset{Name: strings.TrimSpace(signature)}
+	}
+	return Ruleset{Name: name, Version: version}
+}
+
+// String returns the ruleset as a component signature, e.g. "OWASP_CRS/4.0.0"
+func (r Ruleset) String() string {
+	if r.Version == "" {
+		return r.Name
+	}
+	return r.Name + "/" + r.Version
+}
+
+// AddRuleset appends a ruleset unless it was already added, e.g. by a file
+// included twice, and reports whether it was added. The different versions
+// of a ruleset are all kept.
+func (p *Producer) AddRuleset(r Ruleset) bool {
+	if slices.Contains(p.Rulesets, r) {
+		return false
+	}
+	p.Rulesets = append(p.Rulesets, r)
+	return true
+}
+
+// RulesetNames returns the rulesets as component signatures
+func (p Producer) RulesetNames() []string {
+	if len(p.Rulesets) == 0 {
+		return nil
+	}
+	names := make([]string, 0, len(p.Rulesets))
+	for _, r := range p.Rulesets {
+		names = append(names, r.String())
+	}
+	return names
+}
+
+// ComponentSignatures returns the signatures of the rulesets the WAF runs,
+// e.g. "OWASP_CRS/4.0.0", in the order they were declared with
+// SecComponentSignature. They are reported by the audit logs, the
+// effective configuration and the capability report.
+func (w *WAF) ComponentSignatures() []string {
+	return w.Producer.RulesetNames()
+}
+
+// EngineName returns the name of the engine, the last element of its module
+// path
+func EngineName() string {
+	return path.Base(engineModule)
+}
+
+// EngineVersion returns the version of the engine module the binary was built
+// with, or "(devel)" when it is not known, e.g. in tests.
+func EngineVersion() string {
+	return engineVersion()
+}
+
+var engineVersion = sync.OnceValue(func() string {
+	bi, ok := debug.ReadBuildInfo()
+	if !ok {
+		return "(devel)"
+	}
+	if bi.Main.Path == engineModule {
+		return moduleVersion(bi.Main)
+	}
+	for _, dep := range bi.Deps {
+		if dep.Path == engineModule {
+			if dep.Replace != nil {
+				return moduleVersion(*dep.Replace)
+			}
+			return moduleVersion(*dep)
+		}
+	}
+	return "(devel)"
+})
+
+func moduleVersion(m debug.Module) string {
+	if m.Version == "" {
+		return "(devel)"
+	}
+	return m.Version
+}
+
+func cutLast(s, sep string) (before, after string, found bool) {
+	if i := strings.LastIndex(s, sep); i >= 0 {
+		return s[:i], s[i+len(sep):], true
+	}
+	return s, "", false
+}
@@ -0,0 +1,84 @@
+// Copyright 2024 Juan Pablo Tosso and the OWASP Coraza contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package corazawaf
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/ad3n/seclang/experimental/plugins/plugintypes"
+	"github.com/corazawaf/coraza/v3/types"
+)
+
+func TestParseRuleset(t *testing.T) {
+	tests := []struct {
+		signature string
+		want      Ruleset
+	}{
+		{"OWASP_CRS/4.0.0", Ruleset{Name: "OWASP_CRS", Version: "4.0.0"}},
+		{"vendor/rules/1.2", Ruleset{Name: "vendor/rules", Version: "1.2"}},
+		{"custom", Ruleset{Name: "custom"}},
+		{"/1.0", Ruleset{Name: "/1.0"}},
+	}
+	for _, tt := range tests {
+		if want, have := tt.want, ParseRuleset(tt.signature); want != have {
+			t.Errorf("unexpected ruleset for %q, want %+v, have %+v", tt.signature, want, have)
+		}
+	}
+}
+
+func TestProducerAuditLog(t *testing.T) {
+	waf := NewWAF()
+	waf.Producer = Producer{
+		Connector:        "caddy-coraza",
+		ConnectorVersion: "2.0.0",
+		Rulesets:         []Ruleset{ParseRuleset("OWASP_CRS/4.0.0"), ParseRuleset("custom")},
+	}
+	waf.ServerSignature = "Caddy"
+	tx := waf.NewTransaction()
+	tx.AuditLogParts = types.AuditLogParts{types.AuditLogPartAuditLogTrailer}
+	p := tx.AuditLog().Transaction().Producer()
+	if want, have := "caddy-coraza", p.Connector(); want != have {
+		t.Errorf("unexpected connector, want %q, have %q", want, have)
+	}
+	if want, have := "2.0.0", p.Version(); want != have {
+		t.Errorf("unexpected connector version, want %q, have %q", want, have)
+	}
+	engine, ok := p.(plugintypes.AuditLogTransactionProducerEngine)
+	if !ok {
+		t.Fatal("unexpected producer without engine")
+	}
+	if want, have := "seclang", engine.EngineName(); want != have {
+		t.Errorf("unexpected engine name, want %q, have %q", want, have)
+	}
+	if want, have := EngineVersion(), engine.EngineVersion(); want != have {
+		t.Errorf("unexpected engine version, want %q, have %q", want, have)
+	}
+	if want, have := "Caddy", p.Server(); want != have {
+		t.Errorf("unexpected server, want %q, have %q", want, have)
+	}
+	if want, have := []string{"OWASP_CRS/4.0.0", "custom"}, p.Rulesets(); !slices.Equal(want, have) {
+		t.Errorf("unexpected rulesets, want %v, have %v", want, have)
+	}
+}
+
+func TestComponentSignatures(t *testing.T) {
+	waf := NewWAF()
+	if have := waf.ComponentSignatures(); have != nil {
+		t.Errorf("unexpected signatures, have %v", have)
+	}
+	for _, signature := range []string{"OWASP_CRS/4.0.0", "vendor/1.2", "OWASP_CRS/4.0.0", "OWASP_CRS/4.1.0"} {
+		waf.Producer.AddRuleset(ParseRuleset(signature))
+	}
+	want := []string{"OWASP_CRS/4.0.0", "vendor/1.2", "OWASP_CRS/4.1.0"}
+	if have := waf.ComponentSignatures(); !slices.Equal(want, have) {
+		t.Errorf("unexpected signatures, want %v, have %v", want, have)
+	}
+	if have := waf.EffectiveConfig().ComponentNames; !slices.Equal(want, have) {
+		t.Errorf("unexpected component names, want %v, have %v", want, have)
+	}
+	if have := waf.NewTransaction().AuditLog().Transaction().Producer().Rulesets(); !slices.Equal(want, have) {
+		t.Errorf("unexpected audit log rulesets, want %v, have %v", want, have)
+	}
+}
@@ -0,0 +1,80 @@
+// Copyright 2024 Juan Pablo Tosso and the OWASP Coraza contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package corazawaf
+
+import (
+	"strconv"
+	"strings"
+)
+
+// UnexpectedRequestBodyAction defines what happens with the body of a request
+// whose method doesn't define one, like GET, HEAD, DELETE or TRACE.
+type UnexpectedRequestBodyAction int
+
+const (
+	// UnexpectedRequestBodyInspect processes the body like the body of any
+	// other method, so rules can inspect it.
+	UnexpectedRequestBodyInspect UnexpectedRequestBodyAction = iota
+	// UnexpectedRequestBodyIgnore doesn't process the body, the request body
+	// variables stay empty. The body is still buffered for the connectors.
+	UnexpectedRequestBodyIgnore
+)
+
+// ParseUnexpectedRequestBodyAction parses Inspect or Ignore, case-insensitive
+func ParseUnexpectedRequestBodyAction(action string) (UnexpectedRequestBodyAction, bool) {
+	switch strings.ToLower(action) {
+	case "inspect":
+		return UnexpectedRequestBodyInspect, true
+	case "ignore":
+		return UnexpectedRequestBodyIgnore, true
+	}
+	return UnexpectedRequestBodyInspect, false
+}
+
+// methodDefinesBody returns false for the methods whose requests have no
+// defined body semantics, see RFC 9110 section 9.3
+func methodDefinesBody(method string) bool {
+	switch strings.ToUpper(method) {
+	case "GET", "HEAD", "DELETE", "TRACE":
+		return false
+	}
+	return true
+}
+
+// checkRequestBody exposes the presence of the request body to rules and
+// returns false if the body must not be processed. It sets:
+//   - TX:request_body_present when the headers declare a body or one was received
+//   - TX:request_body_unexpected when the method doesn't define a body
+//   - TX:request_body_length_mismatch when the received body is not as long as
+//     the Content-Length header, it is only known when the body is accessible
+//     and was not truncated by the limit.
+func (tx *Transaction) checkRequestBody() bool {
+	received := tx.requestBodyBuffer.length
+	contentLength, hasContentLength := tx.variables.requestHeaders.GetFirst("content-length")
+	declared, err := strconv.ParseInt(strings.TrimSpace(contentLength), 10, 64)
+	if err != nil {
+		hasContentLength = false
+	}
+	_, chunked := tx.variables.requestHeaders.GetFirst("transfer-encoding")
+	if received == 0 && !chunked && (!hasContentLength || declared == 0) {
+		return true
+	}
+
+	ctx := tx.variables.tx
+	ctx.Set("request_body_present", []string{"1"})
+	if hasContentLength && tx.RequestBodyAccess && tx.variables.inboundDataError.Get() != "1" && declared != received {
+		ctx.Set("request_body_length_mismatch", []string{"1"})
+	}
+	if methodDefinesBody(tx.variables.requestMethod.Get()) {
+		return true
+	}
+	ctx.Set("request_body_unexpected", []string{"1"})
+	if tx.WAF.UnexpectedRequestBodyAction == UnexpectedRequestBodyIgnore {
+		tx.debugLogger.Debug().
+			Str("method", tx.variables.requestMethod.Get()).
+			Msg("Skipping processing of the request body, the method doesn't define one")
+		return false
+	}
+	return true
+}
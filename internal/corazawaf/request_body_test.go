@@ -0,0 +1,104 @@
+// Copyright 2024 Juan Pablo Tosso and the OWASP Coraza contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package corazawaf
+
+import (
+	"testing"
+)
+
+func TestUnexpectedRequestBody(t *testing.T) {
+	tests := []struct {
+		name          string
+		method        string
+		headers       map[string]string
+		body          string
+		action        UnexpectedRequestBodyAction
+		wantVars      map[string]bool
+		wantProcessed bool
+	}{
+		{
+			name:     "get without body",
+			method:   "GET",
+			wantVars: map[string]bool{},
+		},
+		{
+			name:          "post with body",
+			method:        "POST",
+			headers:       map[string]string{"Content-Length": "3"},
+			body:          "a=b",
+			wantVars:      map[string]bool{"request_body_present": true},
+			wantProcessed: true,
+		},
+		{
+			name:          "get with inspected body",
+			method:        "GET",
+			headers:       map[string]string{"Content-Length": "3"},
+			body:          "a=b",
+			wantVars:      map[string]bool{"request_body_present": true, "request_body_unexpected": true},
+			wantProcessed: true,
+		},
+		{
+			name:     "get with ignored body",
+			method:   "get",
+			headers:  map[string]string{"Content-Length": "3"},
+			body:     "a=b",
+			action:   UnexpectedRequestBodyIgnore,
+			wantVars: map[string]bool{"request_body_present": true, "request_body_unexpected": true},
+		},
+		{
+			name:          "chunked delete",
+			method:        "DELETE",
+			headers:       map[string]string{"Transfer-Encoding": "chunked"},
+			body:          "a=b",
+			wantVars:      map[string]bool{"request_body_present": true, "request_body_unexpected": true},
+			wantProcessed: true,
+		},
+		{
+			name:     "declared body not received",
+			method:   "HEAD",
+			headers:  map[string]string{"Content-Length": "10"},
+			wantVars: map[string]bool{"request_body_present": true, "request_body_unexpected": true, "request_body_length_mismatch": true},
+		},
+		{
+			name:          "length mismatch",
+			method:        "PUT",
+			headers:       map[string]string{"Content-Length": "1"},
+			body:          "a=b",
+			wantVars:      map[string]bool{"request_body_present": true, "request_body_length_mismatch": true},
+			wantProcessed: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			waf := NewWAF()
+			waf.RequestBodyAccess = true
+			waf.UnexpectedRequestBodyAction = tt.action
+			tx := waf.NewTransaction()
+			defer tx.Close()
+			tx.ProcessURI("/", tt.method, "HTTP/1.1")
+			tx.AddRequestHeader("Content-Type", "application/x-www-form-urlencoded")
+			for k, v := range tt.headers {
+				tx.AddRequestHeader(k, v)
+			}
+			tx.ProcessRequestHeaders()
+			if _, _, err := tx.WriteRequestBody([]byte(tt.body)); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := tx.ProcessRequestBody(); err != nil {
+				t.Fatal(err)
+			}
+
+			for _, key := range []string{"request_body_present", "request_body_unexpected", "request_body_length_mismatch"} {
+				_, have := tx.variables.tx.GetFirst(key)
+				if want := tt.wantVars[key]; want != have {
+					t.Errorf("unexpected TX:%s, want %t, have %t", key, want, have)
+				}
+			}
+			if want, have := tt.wantProcessed, len(tx.variables.argsPost.Get("a")) == 1; want != have {
+				t.Errorf("unexpected body processing, want %t, have %t", want, have)
+			}
+		})
+	}
+}
@@ -0,0 +1,76 @@
+// Copyright 2024 Juan Pablo Tosso and the OWASP Coraza contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package corazawaf
+
+import (
+	"strings"
+
+	"github.com/ad3n/seclang/internal/collections"
+	"github.com/ad3n/seclang/internal/cookies"
+	"github.com/corazawaf/coraza/v3/types"
+)
+
+// responseCookieFlags are the attributes always recorded, 1 if the cookie
+// sets them and 0 otherwise, so rules can flag the missing ones
+var responseCookieFlags = []string{"secure", "httponly", "partitioned"}
+
+// addResponseCookie records the cookie of a Set-Cookie header in
+// RESPONSE_COOKIES and its attributes in RESPONSE_COOKIES_ATTRS. SameSite is
+// always recorded too, empty if not set, and the other attributes only if
+// set. The values of the cookies set several times are appended and their
+// attributes overwritten. The keys are case-insensitive.
+func (tx *Transaction) addResponseCookie(value string) {
+	c, ok := cookies.ParseSetCookie(value)
+	if !ok {
+		return
+	}
+	tx.variables.responseCookies.Add(c.Name, c.Value)
+	attrs := tx.variables.responseCookiesAttrs
+	prefix := c.Name + "."
+	for _, flag := range responseCookieFlags {
+		v := "0"
+		if _, ok := c.Attrs[flag]; ok {
+			v = "1"
+		}
+		attrs.Set(prefix+flag, []string{v})
+		delete(c.Attrs, flag)
+	}
+	attrs.Set(prefix+"samesite", []string{c.Attrs["samesite"]})
+	delete(c.Attrs, "samesite")
+	for k, v := range c.Attrs {
+		attrs.Set(prefix+k, []string{v})
+	}
+}
+
+// responseCookiesAttrs is RESPONSE_COOKIES_ATTRS, the attributes of the
+// cookies keyed by cookie and attribute, e.g. session.secure. The keys
+// without cookie select the attribute of every cookie, e.g. secure.
+type responseCookiesAttrs struct {
+	*collections.Map
+}
+
+func (c responseCookiesAttrs) Get(key string) []string {
+	if strings.Contains(key, ".") {
+		return c.Map.Get(key)
+	}
+	var values []string
+	for _, md := range c.FindString(key) {
+		values = append(values, md.Value())
+	}
+	return values
+}
+
+func (c responseCookiesAttrs) FindString(key string) []types.MatchData {
+	if key == "" || strings.Contains(key, ".") {
+		return c.Map.FindString(key)
+	}
+	suffix := "." + strings.ToLower(key)
+	var result []types.MatchData
+	for _, md := range c.Map.FindAll() {
+		if strings.HasSuffix(strings.ToLower(md.Key()), suffix) {
+			result = append(result, md)
+		}
+	}
+	return result
+}
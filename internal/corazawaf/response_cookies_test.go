@@ -0,0 +1,52 @@
+// Copyright 2024 Juan Pablo Tosso and the OWASP Coraza contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package corazawaf
+
+import (
+	"maps"
+	"slices"
+	"testing"
+)
+
+func TestResponseCookies(t *testing.T) {
+	tx := NewWAF().NewTransaction()
+	defer tx.Close()
+	tx.AddResponseHeader("Set-Cookie", "session=abc; Path=/; Secure; HttpOnly; SameSite=Strict")
+	tx.AddResponseHeader("set-cookie", "Theme=dark; Max-Age=3600")
+	tx.AddResponseHeader("Set-Cookie", "theme=light")
+	tx.AddResponseHeader("Set-Cookie", "=invalid; Secure")
+
+	for key, want := range map[string][]string{
+		"session": {"abc"},
+		"theme":   {"dark", "light"},
+		"":        nil,
+	} {
+		if have := tx.variables.responseCookies.Get(key); !slices.Equal(want, have) {
+			t.Errorf("unexpected cookie %s, want %q, have %q", key, want, have)
+		}
+	}
+	for key, want := range map[string][]string{
+		"session.secure":      {"1"},
+		"session.httponly":    {"1"},
+		"session.partitioned": {"0"},
+		"session.samesite":    {"Strict"},
+		"session.path":        {"/"},
+		"theme.secure":        {"0"},
+		"theme.samesite":      {""},
+		"theme.max-age":       {"3600"},
+		"max-age":             {"3600"},
+		"Path":                {"/"},
+	} {
+		if have := tx.variables.responseCookiesAttrs.Get(key); !slices.Equal(want, have) {
+			t.Errorf("unexpected attribute %s, want %q, have %q", key, want, have)
+		}
+	}
+	secure := map[string]string{}
+	for _, md := range tx.variables.responseCookiesAttrs.FindString("secure") {
+		secure[md.Key()] = md.Value()
+	}
+	if want := map[string]string{"session.secure": "1", "theme.secure": "0"}; !maps.Equal(want, secure) {
+		t.Errorf("unexpected secure attributes, want %q, have %q", want, secure)
+	}
+}
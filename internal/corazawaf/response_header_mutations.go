@@ -0,0 +1,32 @@
+// Copyright 2024 Juan Pablo Tosso and the OWASP Coraza contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package corazawaf
+
+// ResponseHeaderMutation is a response header set by the WAF, the connectors
+// apply them to the response before sending it
+type ResponseHeaderMutation struct {
+	Name  string
+	Value string
+}
+
+// SetResponseHeader sets a response header, replacing its values. It is
+// exposed in RESPONSE_HEADERS and recorded as a mutation the connector must
+// apply, see ResponseHeaderMutations.
+func (tx *Transaction) SetResponseHeader(name string, value string) {
+	if name == "" {
+		return
+	}
+	tx.variables.responseHeaders.Set(name, []string{value})
+	tx.responseHeaderMutations = append(tx.responseHeaderMutations, ResponseHeaderMutation{Name: name, Value: value})
+	tx.debugLogger.Debug().
+		Str("header", name).
+		Str("value", value).
+		Msg("Response header set")
+}
+
+// ResponseHeaderMutations returns the response headers set by the WAF in the
+// order they were set, connectors apply them after ProcessResponseHeaders.
+func (tx *Transaction) ResponseHeaderMutations() []ResponseHeaderMutation {
+	return tx.responseHeaderMutations
+}
@@ -8,12 +8,15 @@ import (
 	"regexp"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"unsafe"
 
 	"github.com/ad3n/seclang/experimental/plugins/macro"
 	"github.com/ad3n/seclang/experimental/plugins/plugintypes"
 	"github.com/ad3n/seclang/internal/corazarules"
+	"github.com/ad3n/seclang/internal/corazatypes"
 	"github.com/ad3n/seclang/internal/memoize"
+	"github.com/ad3n/seclang/internal/transformations"
 	"github.com/corazawaf/coraza/v3/debuglog"
 	"github.com/corazawaf/coraza/v3/types"
 	"github.com/corazawaf/coraza/v3/types/variables"
@@ -113,6 +116,10 @@ type Rule struct {
 	// by disruptive rules
 	DisruptiveStatus int
 
+	// DisruptiveBody is the response body of the interruptions triggered
+	// by the deny action, set by denybody
+	DisruptiveBody macro.Macro
+
 	// Message text to be macro expanded and logged
 	// In future versions we might use a special type of string that
 	// supports cached macro expansions. For performance
@@ -146,6 +153,32 @@ type Rule struct {
 	// chainedRules containing rules with just PhaseUnknown variables, may potentially
 	// be anticipated. This boolean ensures that it happens
 	withPhaseUnknownVariable bool
+
+	// activeWindows restricts the time the rule is evaluated, empty means always
+	activeWindows []ActiveWindow
+
+	// sampleRate is the percentage of the transactions the rule is evaluated
+	// for, zero means all of them
+	sampleRate float64
+
+	// initializedActions are the actions initialized with InitAction along
+	// with their arguments, see Definition
+	initializedActions []RuleActionDefinition
+
+	// operatorOptions are the options the operator was created with, see
+	// SetOperatorOptions
+	operatorOptions plugintypes.OperatorOptions
+
+	// disabled is set at runtime to skip the rule without re-parsing,
+	// it must be accessed atomically
+	disabled int32
+
+	// unicodeMap is the mapping used by the urlDecodeUni transformation
+	unicodeMap *transformations.UnicodeMap
+
+	// rxBudget overrides the budget of the WAF for the @rx operator of the
+	// rule, nil if it is not overridden
+	rxBudget *RxBudget
 }
 
 func (r *Rule) ParentID() int {
@@ -156,6 +189,15 @@ func (r *Rule) Status() int {
 	return r.DisruptiveStatus
 }
 
+// Location returns the file and line the rule was defined at
+func (r *Rule) Location() string {
+	file := r.File_
+	if file == "" {
+		file = "unknown file"
+	}
+	return fmt.Sprintf("%s:%d", file, r.Line_)
+}
+
 const chainLevelZero = 0
 
 // Evaluate will evaluate the current rule for the indicated transaction
@@ -238,7 +280,7 @@ func (r *Rule) doEvaluate(logger debuglog.Logger, phase types.RulePhase, tx *Tra
 
 			vLog := logger
 			if logger.Debug().IsEnabled() {
-				vLog = logger.With(debuglog.Str("variable", v.Variable.Name()))
+				vLog = logger.With(debuglog.Str("variable", corazatypes.VariableName(v.Variable)))
 			}
 			vLog.Debug().Msg("Expanding arguments for rule")
 
@@ -265,18 +307,20 @@ func (r *Rule) doEvaluate(logger debuglog.Logger, phase types.RulePhase, tx *Tra
 
 				// args represents the transformed variables
 				for _, carg := range args[:argsLen] {
-					evalLog := vLog.
-						Debug().
-						Str("operator_function", r.operator.Function).
-						Str("operator_data", r.operator.Data).
-						Str("arg", carg)
+					evalLog := vLog.Debug()
+					if evalLog.IsEnabled() {
+						evalLog = evalLog.
+							Str("operator_function", r.operator.Function).
+							Str("operator_data", r.operator.Data).
+							Str("arg", r.loggedValue(carg))
+					}
 
 					match := r.executeOperator(carg, tx)
 					if match {
 						mr := &corazarules.MatchData{
 							Variable_:   arg.Variable(),
 							Key_:        arg.Key(),
-							Value_:      carg,
+							Value_:      r.matchedValue(carg),
 							ChainLevel_: chainLevel,
 						}
 						// Set the txn variables for expansions before usage
@@ -297,14 +341,14 @@ func (r *Rule) doEvaluate(logger debuglog.Logger, phase types.RulePhase, tx *Tra
 						}
 
 						if !multiphaseEvaluation {
-							matchedValues = append(matchedValues, mr)
+							matchedValues = tx.appendMatchData(matchedValues, mr)
 						} else {
 							if isMultiphaseDoubleEvaluation(tx, phase, r, collectiveMatchedValues, mr) {
 								// This variables chain already matched, let's evaluate the next variable
 								continue
 							}
 							// For multiphase evaluation, the append to matchedValues is delayed after checking that the variable has not already matched
-							matchedValues = append(matchedValues, mr)
+							matchedValues = tx.appendMatchData(matchedValues, mr)
 							// For multiphase evaluation, the non disruptive actions execution is enforced here, after having checked that the rule
 							// has not already been matched against the same variables chain. If effectively enforces to skip the execution of non disruptive actions that are
 							// part of the last rule of the chain if the evaluated chained variables already matched. This avoids incrementing the CRS anomaly score multiple
@@ -437,7 +481,7 @@ func (r *Rule) matchVariable(tx *Transaction, m *corazarules.MatchData) {
 	if m.Variable() != variables.Unknown {
 		tx.DebugLogger().Debug().
 			Int("rule_id", rid).
-			Str("variable_name", m.Variable().Name()).
+			Str("variable_name", corazatypes.VariableName(m.Variable())).
 			Str("key", m.Key()).
 			Msg("Matching rule")
 	}
@@ -505,6 +549,16 @@ func newRuleVariableParams(v variables.RuleVariable, key string, re *regexp.Rege
 	}
 }
 
+// compileKeyRx compiles the regular expression of a variable key, the
+// expressions are shared by the rules using the same key
+func compileKeyRx(rx string) (*regexp.Regexp, error) {
+	re, err := memoize.Do(rx, func() (interface{}, error) { return regexp.Compile(rx) })
+	if err != nil {
+		return nil, err
+	}
+	return re.(*regexp.Regexp), nil
+}
+
 // AddVariable adds a variable to the rule
 // The key can be a regexp.Regexp, a string or nil, in case of regexp
 // it will be used to match the variable, in case of string it will
@@ -515,10 +569,9 @@ func (r *Rule) AddVariable(v variables.RuleVariable, key string, iscount bool) e
 	}
 	var re *regexp.Regexp
 	if isRegex, rx := hasRegex(key); isRegex {
-		if vare, err := memoize.Do(rx, func() (interface{}, error) { return regexp.Compile(rx) }); err != nil {
+		var err error
+		if re, err = compileKeyRx(rx); err != nil {
 			return err
-		} else {
-			re = vare.(*regexp.Regexp)
 		}
 	}
 
@@ -559,10 +612,9 @@ func needToSplitConcatenatedVariable(v variables.RuleVariable, ve variables.Rule
 func (r *Rule) AddVariableNegation(v variables.RuleVariable, key string) error {
 	var re *regexp.Regexp
 	if isRegex, rx := hasRegex(key); isRegex {
-		if vare, err := memoize.Do(rx, func() (interface{}, error) { return regexp.Compile(rx) }); err != nil {
+		var err error
+		if re, err = compileKeyRx(rx); err != nil {
 			return err
-		} else {
-			re = vare.(*regexp.Regexp)
 		}
 	}
 	// Prevent sigsev
@@ -616,6 +668,18 @@ func (r *Rule) AddTransformation(name string, t plugintypes.Transformation) erro
 	return nil
 }
 
+// SetUnicodeMap sets the best-fit mapping used by the urlDecodeUni
+// transformations added after it
+func (r *Rule) SetUnicodeMap(m *transformations.UnicodeMap) {
+	r.unicodeMap = m
+}
+
+// UnicodeMap returns the best-fit mapping used by urlDecodeUni, nil
+// if the lower byte of the code point is kept
+func (r *Rule) UnicodeMap() *transformations.UnicodeMap {
+	return r.unicodeMap
+}
+
 // ClearTransformations clears all the transformations
 // it is mostly used by the "none" transformation
 func (r *Rule) ClearTransformations() {
@@ -634,7 +698,22 @@ func (r *Rule) SetOperator(operator plugintypes.Operator, functionName string, p
 	}
 }
 
+// SetRxBudget overrides the budget of the WAF for the @rx operator of the rule
+func (r *Rule) SetRxBudget(b RxBudget) {
+	r.rxBudget = &b
+}
+
+// RxBudget returns the budget of the @rx operator of the rule, ok is false if
+// it doesn't override the one of the WAF
+func (r *Rule) RxBudget() (b RxBudget, ok bool) {
+	if r.rxBudget == nil {
+		return RxBudget{}, false
+	}
+	return *r.rxBudget, true
+}
+
 func (r *Rule) executeOperator(data string, tx *Transaction) (result bool) {
+	tx.ruleRxBudget = r.rxBudget
 	result = r.operator.Operator.Evaluate(tx, data)
 	if r.operator.Negation {
 		result = !result
@@ -642,6 +721,24 @@ func (r *Rule) executeOperator(data string, tx *Transaction) (result bool) {
 	return
 }
 
+// matchedValue returns the value matched by the operator, masked if the
+// operator masks its matches
+func (r *Rule) matchedValue(value string) string {
+	if m, ok := r.operator.Operator.(plugintypes.MaskingOperator); ok && !r.operator.Negation {
+		return m.Mask(value)
+	}
+	return value
+}
+
+// loggedValue returns the value evaluated by the operator as it is logged,
+// masked if the operator masks its matches, whether it matches or not
+func (r *Rule) loggedValue(value string) string {
+	if m, ok := r.operator.Operator.(plugintypes.MaskingOperator); ok {
+		return m.Mask(value)
+	}
+	return value
+}
+
 func (r *Rule) executeTransformationsMultimatch(value string) ([]string, []error) {
 	// The original value will be evaluated
 	res := []string{value}
@@ -684,3 +781,16 @@ func NewRule() *Rule {
 		},
 	}
 }
+
+// Disabled returns true if the rule has been disabled at runtime
+func (r *Rule) Disabled() bool {
+	return atomic.LoadInt32(&r.disabled) == 1
+}
+
+func (r *Rule) setDisabled(disabled bool) {
+	var v int32
+	if disabled {
+		v = 1
+	}
+	atomic.StoreInt32(&r.disabled, v)
+}
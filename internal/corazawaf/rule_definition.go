@@ -0,0 +1,151 @@
+// Copyright 2024 Juan Pablo Tosso and the OWASP Coraza contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package corazawaf
+
+import (
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/ad3n/seclang/experimental/plugins/plugintypes"
+	"github.com/corazawaf/coraza/v3/types/variables"
+)
+
+// RuleDefinition describes a compiled rule with the arguments its variables,
+// operator and actions were created with, so the rule can be created again
+// without parsing its directive, e.g. to restore a snapshot of the rules.
+// It reflects the changes made to the rule after it was parsed, like the
+// targets or actions updated by SecRuleUpdateTargetById and
+// SecRuleUpdateActionById.
+type RuleDefinition struct {
+	File    string
+	Line    int
+	Raw     string
+	SecMark string
+
+	Variables []RuleVariableDefinition
+	// Operator is nil for the rules without operator, e.g. SecAction
+	Operator *RuleOperatorDefinition
+	// Actions are the actions in the order they were initialized, including
+	// the metadata actions and the default actions of the rule phase
+	Actions []RuleActionDefinition
+
+	// Chain is the definition of the chained rule, if any
+	Chain *RuleDefinition
+}
+
+// RuleVariableDefinition describes a variable of a rule
+type RuleVariableDefinition struct {
+	Variable variables.RuleVariable
+	// Key is the key selected by the rule, regular expressions are enclosed
+	// in slashes
+	Key   string
+	Count bool
+	// Exceptions are the keys excluded, e.g. id for ARGS|!ARGS:id
+	Exceptions []string
+}
+
+// RuleOperatorDefinition describes the operator of a rule
+type RuleOperatorDefinition struct {
+	// Function is the operator as written in the rule, e.g. !@rx
+	Function string
+	// Options are the options the operator was created with, including its
+	// arguments. The root and the datasets, including their feeds, are not
+	// kept as they are provided by the WAF compiling the rule again.
+	Options plugintypes.OperatorOptions
+}
+
+// Name returns the name the operator is registered with, e.g. rx for !@rx
+func (d RuleOperatorDefinition) Name() string {
+	return strings.TrimPrefix(strings.TrimPrefix(d.Function, "!"), "@")
+}
+
+// RuleActionDefinition describes an action of a rule
+type RuleActionDefinition struct {
+	Name      string
+	Arguments string
+}
+
+// InitAction initializes the action with its arguments for the rule and
+// keeps them in the definition of the rule. The actions that are not
+// metadata actions still have to be added with AddAction.
+func (r *Rule) InitAction(name string, arguments string, action plugintypes.Action) error {
+	if err := action.Init(r, arguments); err != nil {
+		return err
+	}
+	r.initializedActions = append(r.initializedActions, RuleActionDefinition{Name: name, Arguments: arguments})
+	return nil
+}
+
+// SetOperatorOptions keeps the options the operator of the rule was created
+// with in the definition of the rule
+func (r *Rule) SetOperatorOptions(options plugintypes.OperatorOptions) {
+	options.Root = nil
+	options.Datasets = nil
+	options.DatasetFeeds = nil
+	r.operatorOptions = options
+}
+
+// AddVariableDefinition adds the variable described by d, along with its
+// exceptions
+func (r *Rule) AddVariableDefinition(d RuleVariableDefinition) error {
+	var (
+		re  *regexp.Regexp
+		err error
+	)
+	if isRegex, rx := hasRegex(d.Key); isRegex {
+		if re, err = compileKeyRx(rx); err != nil {
+			return err
+		}
+	}
+	params := newRuleVariableParams(d.Variable, d.Key, re, d.Count)
+	for _, key := range d.Exceptions {
+		exception := ruleVariableException{KeyStr: key}
+		if isRegex, rx := hasRegex(key); isRegex {
+			if exception.KeyRx, err = compileKeyRx(rx); err != nil {
+				return err
+			}
+		}
+		params.Exceptions = append(params.Exceptions, exception)
+	}
+	r.variables = append(r.variables, params)
+	return nil
+}
+
+// Definition returns the definition of the rule and of its chain
+func (r *Rule) Definition() RuleDefinition {
+	d := RuleDefinition{
+		File:    r.File_,
+		Line:    r.Line_,
+		Raw:     r.Raw_,
+		SecMark: r.SecMark_,
+		Actions: slices.Clone(r.initializedActions),
+	}
+	for _, v := range r.variables {
+		vd := RuleVariableDefinition{
+			Variable: v.Variable,
+			Key:      v.KeyStr,
+			Count:    v.Count,
+		}
+		if v.KeyRx != nil {
+			vd.Key = "/" + v.KeyRx.String() + "/"
+		}
+		for _, e := range v.Exceptions {
+			vd.Exceptions = append(vd.Exceptions, e.KeyStr)
+		}
+		d.Variables = append(d.Variables, vd)
+	}
+	if r.operator != nil {
+		d.Operator = &RuleOperatorDefinition{
+			Function: r.operator.Function,
+			Options:  r.operatorOptions,
+		}
+		d.Operator.Options.Arguments = r.operator.Data
+	}
+	if r.Chain != nil {
+		chain := r.Chain.Definition()
+		d.Chain = &chain
+	}
+	return d
+}
@@ -6,6 +6,7 @@ package corazawaf
 import (
 	"strings"
 
+	"github.com/ad3n/seclang/internal/corazatypes"
 	"github.com/corazawaf/coraza/v3/types"
 	"github.com/corazawaf/coraza/v3/types/variables"
 )
@@ -112,8 +113,18 @@ func minPhase(v variables.RuleVariable) types.RulePhase {
 		return types.PhaseRequestHeaders
 	case variables.RequestBody:
 		return types.PhaseRequestBody
+	case corazatypes.StreamInputBody:
+		return types.PhaseRequestBody
 	case variables.RequestBodyLength:
 		return types.PhaseRequestBody
+	case corazatypes.XMLExternalEntity:
+		return types.PhaseRequestBody
+	case corazatypes.MultipartOriginalValues:
+		return types.PhaseRequestBody
+	case corazatypes.MultipartCharsetError:
+		return types.PhaseRequestBody
+	case corazatypes.MultipartFileLimitExceeded:
+		return types.PhaseRequestBody
 	case variables.RequestFilename:
 		return types.PhaseRequestHeaders
 	case variables.RequestLine:
@@ -126,12 +137,26 @@ func minPhase(v variables.RuleVariable) types.RulePhase {
 		return types.PhaseRequestHeaders
 	case variables.RequestURIRaw:
 		return types.PhaseRequestHeaders
+	case corazatypes.RequestURLNormalized:
+		return types.PhaseRequestHeaders
+	case corazatypes.RequestPathSegments:
+		return types.PhaseRequestHeaders
 	case variables.ResponseBody:
 		return types.PhaseResponseBody
+	case corazatypes.StreamOutputBody:
+		return types.PhaseResponseBody
 	case variables.ResponseContentLength:
 		return types.PhaseResponseBody
 	case variables.ResponseProtocol:
 		return types.PhaseResponseHeaders
+	case corazatypes.ResponseCookies:
+		return types.PhaseResponseHeaders
+	case corazatypes.RequestContentTypeAnomalies:
+		return types.PhaseRequestHeaders
+	case corazatypes.ResponseCookiesAttrs:
+		return types.PhaseResponseHeaders
+	case corazatypes.SecurityHeaders:
+		return types.PhaseResponseHeaders
 	case variables.ResponseStatus:
 		return types.PhaseResponseHeaders
 	case variables.ServerAddr:
@@ -354,7 +379,7 @@ func isMultiphaseDoubleEvaluation(tx *Transaction, phase types.RulePhase, r *Rul
 						if n != 0 {
 							res.WriteString(" - ")
 						}
-						res.WriteString(m.Variable().Name())
+						res.WriteString(corazatypes.VariableName(m.Variable()))
 					}
 					rid := r.ID_
 					if rid == 0 {
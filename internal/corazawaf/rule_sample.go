@@ -0,0 +1,44 @@
+// Copyright 2024 Juan Pablo Tosso and the OWASP Coraza contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package corazawaf
+
+import (
+	"fmt"
+	"hash/fnv"
+	"strconv"
+	"strings"
+)
+
+// sampleBuckets is the number of buckets the transactions are hashed to, it
+// allows sample rates down to 0.01%
+const sampleBuckets = 10000
+
+// ParseSampleRate parses the percentage of the transactions a rule is
+// evaluated for, e.g. 10 or 0.5%, it must be greater than 0 and at most 100
+func ParseSampleRate(data string) (float64, error) {
+	rate, err := strconv.ParseFloat(strings.TrimSuffix(strings.TrimSpace(data), "%"), 64)
+	if err != nil || !(rate > 0 && rate <= 100) {
+		return 0, fmt.Errorf("invalid sample rate %q, expected a percentage greater than 0 and at most 100", data)
+	}
+	return rate, nil
+}
+
+// SetSampleRate restricts the evaluation of the rule to the percentage rate
+// of the transactions
+func (r *Rule) SetSampleRate(rate float64) {
+	r.sampleRate = rate
+}
+
+// IsSampled returns true if the rule has to be evaluated for the transaction
+// with the ID. The transactions are selected by the hash of their ID, so a
+// transaction sampled at some rate is sampled by all the rules with the same
+// or a higher rate.
+func (r *Rule) IsSampled(txID string) bool {
+	if r.sampleRate == 0 || r.sampleRate >= 100 {
+		return true
+	}
+	h := fnv.New64a()
+	h.Write([]byte(txID))
+	return float64(h.Sum64()%sampleBuckets) < r.sampleRate*sampleBuckets/100
+}
@@ -0,0 +1,91 @@
+// Copyright 2024 Juan Pablo Tosso and the OWASP Coraza contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package corazawaf
+
+import (
+	"strconv"
+	"testing"
+
+	"github.com/corazawaf/coraza/v3/types"
+)
+
+func TestRuleIsSampled(t *testing.T) {
+	r := NewRule()
+	if !r.IsSampled("abc") {
+		t.Error("expected a rule without sample rate to be always evaluated")
+	}
+
+	tenPercent, twentyPercent := NewRule(), NewRule()
+	tenPercent.SetSampleRate(10)
+	twentyPercent.SetSampleRate(20)
+	sampled := 0
+	for i := 0; i < 10000; i++ {
+		id := "tx" + strconv.Itoa(i)
+		if tenPercent.IsSampled(id) {
+			sampled++
+			if !twentyPercent.IsSampled(id) {
+				t.Fatalf("expected %s sampled at 10%% to be sampled at 20%%", id)
+			}
+		}
+		if want, have := tenPercent.IsSampled(id), tenPercent.IsSampled(id); want != have {
+			t.Fatalf("unexpected non deterministic sampling of %s", id)
+		}
+	}
+	if sampled < 900 || sampled > 1100 {
+		t.Errorf("unexpected number of sampled transactions, want about 1000, have %d", sampled)
+	}
+}
+
+func TestSampledRuleEvaluation(t *testing.T) {
+	waf := NewWAF()
+	r := NewRule()
+	r.ID_ = 1
+	r.Phase_ = types.PhaseRequestHeaders
+	r.SetSampleRate(50)
+	if err := waf.Rules.Add(r); err != nil {
+		t.Fatal(err)
+	}
+
+	evaluated := 0
+	for i := 0; i < 100; i++ {
+		id := "tx" + strconv.Itoa(i)
+		tx := waf.NewTransactionWithOptions(Options{ID: id})
+		waf.Rules.Eval(types.PhaseRequestHeaders, tx)
+		if want, have := r.IsSampled(id), len(tx.MatchedRules()) == 1; want != have {
+			t.Errorf("unexpected evaluation of %s, want %t, have %t", id, want, have)
+		}
+		if len(tx.MatchedRules()) == 1 {
+			evaluated++
+		}
+		tx.Close()
+	}
+	if evaluated == 0 || evaluated == 100 {
+		t.Errorf("unexpected number of evaluations, have %d", evaluated)
+	}
+}
+
+func TestParseSampleRate(t *testing.T) {
+	tests := []struct {
+		data string
+		want float64
+	}{
+		{"10", 10},
+		{" 0.5% ", 0.5},
+		{"100", 100},
+	}
+	for _, tt := range tests {
+		have, err := ParseSampleRate(tt.data)
+		if err != nil {
+			t.Errorf("unexpected error for %q: %s", tt.data, err.Error())
+		}
+		if want := tt.want; want != have {
+			t.Errorf("unexpected rate of %q, want %v, have %v", tt.data, want, have)
+		}
+	}
+	for _, data := range []string{"", "0", "100.5", "NaN", "x"} {
+		if _, err := ParseSampleRate(data); err == nil {
+			t.Errorf("expected error for %q", data)
+		}
+	}
+}
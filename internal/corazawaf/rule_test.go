@@ -4,8 +4,10 @@
 package corazawaf
 
 import (
+	"bytes"
 	"errors"
 	"strconv"
+	"strings"
 	"testing"
 
 	"github.com/ad3n/seclang/experimental/plugins/macro"
@@ -616,3 +618,78 @@ func TestExpandMacroAfterWholeRuleEvaluation(t *testing.T) {
 		t.Errorf("Expected ArgsGet-data, got %s", matchdata[0].Data())
 	}
 }
+
+func TestRuleMatchLimit(t *testing.T) {
+	r := NewRule()
+	r.ID_ = 1
+	r.LogID_ = "1"
+	if err := r.AddVariable(variables.ArgsGet, "", false); err != nil {
+		t.Error(err)
+	}
+	r.SetOperator(&dummyEqOperator{}, "@eq", "0")
+
+	waf := NewWAF()
+	waf.RuleMatchLimit = 2
+	tx := waf.NewTransaction()
+	for i := 0; i < 5; i++ {
+		tx.AddGetRequestArgument("arg"+strconv.Itoa(i), "0")
+	}
+
+	var matchedValues []types.MatchData
+	matchdata := r.doEvaluate(debuglog.Noop(), types.PhaseRequestHeaders, tx, &matchedValues, 0, tx.transformationCache)
+	if len(matchdata) != 2 {
+		t.Errorf("Expected 2 matchdata because of the rule match limit, got %d", len(matchdata))
+	}
+	if len(tx.MatchedRules()) != 1 {
+		t.Fatalf("Expected 1 matched rule, got %d", len(tx.MatchedRules()))
+	}
+	mr := tx.MatchedRules()[0].(*corazarules.MatchedRule)
+	if !mr.Truncated() {
+		t.Error("Expected matched rule to be flagged as truncated")
+	}
+	if !strings.Contains(mr.ErrorLog(), `[truncated "true"]`) {
+		t.Errorf("Expected the error log to flag the truncated matches, got %s", mr.ErrorLog())
+	}
+}
+
+type dummyMaskingOperator struct{}
+
+func (dummyMaskingOperator) Evaluate(_ plugintypes.TransactionState, value string) bool {
+	return strings.HasPrefix(value, "4111")
+}
+
+func (dummyMaskingOperator) Mask(value string) string {
+	return strings.Repeat("*", len(value))
+}
+
+func TestRuleDebugLogMasksValues(t *testing.T) {
+	for _, function := range []string{"@masking", "!@masking"} {
+		t.Run(function, func(t *testing.T) {
+			r := NewRule()
+			r.ID_ = 1
+			r.LogID_ = "1"
+			if err := r.AddVariable(variables.ArgsGet, "", false); err != nil {
+				t.Fatal(err)
+			}
+			r.SetOperator(dummyMaskingOperator{}, function, "")
+
+			tx := NewWAF().NewTransaction()
+			tx.AddGetRequestArgument("card", "4111111111111111")
+			tx.AddGetRequestArgument("other", "5500000000000004")
+
+			debugLog := &bytes.Buffer{}
+			logger := debuglog.Default().WithLevel(debuglog.LevelDebug).WithOutput(debugLog)
+			var matchedValues []types.MatchData
+			r.doEvaluate(logger, types.PhaseRequestHeaders, tx, &matchedValues, 0, tx.transformationCache)
+
+			if !strings.Contains(debugLog.String(), "arg=") {
+				t.Fatalf("unexpected debug log, want the evaluated values, have %q", debugLog.String())
+			}
+			for _, value := range []string{"4111111111111111", "5500000000000004"} {
+				if strings.Contains(debugLog.String(), value) {
+					t.Errorf("unexpected debug log, want %q masked, have %q", value, debugLog.String())
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,154 @@
+// Copyright 2024 Juan Pablo Tosso and the OWASP Coraza contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package corazawaf
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const minutesPerDay = 24 * 60
+
+var weekdays = map[string]time.Weekday{
+	"sun": time.Sunday,
+	"mon": time.Monday,
+	"tue": time.Tuesday,
+	"wed": time.Wednesday,
+	"thu": time.Thursday,
+	"fri": time.Friday,
+	"sat": time.Saturday,
+}
+
+// ActiveWindow restricts the evaluation of a rule to some weekdays
+// and a time of day range, for example "Mon-Fri 09:00-17:00".
+type ActiveWindow struct {
+	// days is a bitmask indexed by time.Weekday, zero means every day
+	days uint8
+	// from and to are minutes since midnight, the range is [from, to).
+	// If to is lower than from the range spans midnight.
+	from int
+	to   int
+}
+
+// ParseActiveWindow parses a window with the syntax [DAYS] [HH:MM-HH:MM].
+// DAYS is a comma separated list of days (Mon) or ranges of days (Mon-Fri),
+// at least one of the two parts is required.
+func ParseActiveWindow(data string) (ActiveWindow, error) {
+	w := ActiveWindow{from: 0, to: minutesPerDay}
+	fields := strings.Fields(data)
+	if len(fields) == 0 || len(fields) > 2 {
+		return w, fmt.Errorf("invalid active window %q, expected [DAYS] [HH:MM-HH:MM]", data)
+	}
+	for _, f := range fields {
+		var err error
+		if strings.Contains(f, ":") {
+			w.from, w.to, err = parseTimeRange(f)
+		} else {
+			w.days, err = parseDays(f)
+		}
+		if err != nil {
+			return w, err
+		}
+	}
+	return w, nil
+}
+
+func parseDays(data string) (uint8, error) {
+	var days uint8
+	for _, d := range strings.Split(data, ",") {
+		start, end, isRange := strings.Cut(d, "-")
+		s, ok := weekdays[strings.ToLower(start)]
+		if !ok {
+			return 0, fmt.Errorf("invalid weekday %q", start)
+		}
+		e := s
+		if isRange {
+			if e, ok = weekdays[strings.ToLower(end)]; !ok {
+				return 0, fmt.Errorf("invalid weekday %q", end)
+			}
+		}
+		// ranges like Fri-Mon wrap around the end of the week
+		for day := s; ; day = (day + 1) % 7 {
+			days |= 1 << day
+			if day == e {
+				break
+			}
+		}
+	}
+	return days, nil
+}
+
+func parseTimeRange(data string) (int, int, error) {
+	start, end, ok := strings.Cut(data, "-")
+	if !ok {
+		return 0, 0, fmt.Errorf("invalid time range %q, expected HH:MM-HH:MM", data)
+	}
+	from, err := parseTimeOfDay(start)
+	if err != nil {
+		return 0, 0, err
+	}
+	to, err := parseTimeOfDay(end)
+	if err != nil {
+		return 0, 0, err
+	}
+	if from == to {
+		return 0, 0, fmt.Errorf("invalid time range %q, start and end are equal", data)
+	}
+	return from, to, nil
+}
+
+func parseTimeOfDay(data string) (int, error) {
+	h, m, ok := strings.Cut(data, ":")
+	if !ok {
+		return 0, fmt.Errorf("invalid time %q, expected HH:MM", data)
+	}
+	hour, err := strconv.Atoi(h)
+	if err != nil || hour < 0 || hour > 24 {
+		return 0, fmt.Errorf("invalid hour in %q", data)
+	}
+	minute, err := strconv.Atoi(m)
+	if err != nil || minute < 0 || minute > 59 || (hour == 24 && minute != 0) {
+		return 0, fmt.Errorf("invalid minute in %q", data)
+	}
+	return hour*60 + minute, nil
+}
+
+func (w ActiveWindow) hasDay(d time.Weekday) bool {
+	return w.days == 0 || w.days&(1<<d) != 0
+}
+
+// Contains returns true if t is inside the window. For ranges spanning
+// midnight the days refer to the day the range starts.
+func (w ActiveWindow) Contains(t time.Time) bool {
+	minute := t.Hour()*60 + t.Minute()
+	day := t.Weekday()
+	if w.from < w.to {
+		return minute >= w.from && minute < w.to && w.hasDay(day)
+	}
+	if minute >= w.from {
+		return w.hasDay(day)
+	}
+	return minute < w.to && w.hasDay((day+6)%7)
+}
+
+// AddActiveWindow restricts the rule to be evaluated only inside w.
+// When multiple windows are added the rule is active if any of them matches.
+func (r *Rule) AddActiveWindow(w ActiveWindow) {
+	r.activeWindows = append(r.activeWindows, w)
+}
+
+// IsActiveAt returns true if the rule has to be evaluated at time t
+func (r *Rule) IsActiveAt(t time.Time) bool {
+	if len(r.activeWindows) == 0 {
+		return true
+	}
+	for _, w := range r.activeWindows {
+		if w.Contains(t) {
+			return true
+		}
+	}
+	return false
+}
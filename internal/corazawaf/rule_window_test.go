@@ -0,0 +1,117 @@
+// Copyright 2024 Juan Pablo Tosso and the OWASP Coraza contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package corazawaf
+
+import (
+	"testing"
+	"time"
+
+	"github.com/corazawaf/coraza/v3/types"
+)
+
+func TestActiveWindowContains(t *testing.T) {
+	// 2024-01-05 is a Friday
+	at := func(day int, hour int, minute int) time.Time {
+		return time.Date(2024, time.January, day, hour, minute, 0, 0, time.UTC)
+	}
+	tests := []struct {
+		window string
+		time   time.Time
+		want   bool
+	}{
+		{"Mon-Fri", at(5, 12, 0), true},
+		{"Mon-Fri", at(6, 12, 0), false},
+		{"Sat,Sun", at(7, 12, 0), true},
+		{"Fri-Mon", at(8, 12, 0), true},
+		{"Fri-Mon", at(9, 12, 0), false},
+		{"09:00-17:00", at(5, 9, 0), true},
+		{"09:00-17:00", at(5, 17, 0), false},
+		{"Mon-Fri 09:00-17:00", at(6, 10, 0), false},
+		{"22:00-06:00", at(5, 23, 0), true},
+		{"22:00-06:00", at(5, 5, 59), true},
+		{"22:00-06:00", at(5, 6, 0), false},
+		// the window started on Friday night
+		{"Fri 22:00-06:00", at(6, 3, 0), true},
+		{"Fri 22:00-06:00", at(5, 3, 0), false},
+		// Monday morning belongs to the window started on Sunday night
+		{"Sun-Thu 18:00-08:00", at(8, 3, 0), true},
+		{"Mon-Fri 18:00-08:00", at(8, 3, 0), false},
+		{"Fri 18:00-24:00", at(5, 23, 59), true},
+	}
+	for _, tc := range tests {
+		w, err := ParseActiveWindow(tc.window)
+		if err != nil {
+			t.Fatalf("unexpected error for %q: %s", tc.window, err.Error())
+		}
+		if have := w.Contains(tc.time); have != tc.want {
+			t.Errorf("unexpected result for %q at %s, want %t, have %t", tc.window, tc.time, tc.want, have)
+		}
+	}
+}
+
+func TestRuleGroupSkipsInactiveRules(t *testing.T) {
+	waf := NewWAF()
+	// Saturday
+	waf.Clock = func() time.Time { return time.Date(2024, time.January, 6, 12, 0, 0, 0, time.Local) }
+
+	r := NewRule()
+	r.ID_ = 1
+	r.Phase_ = types.PhaseRequestHeaders
+	w, err := ParseActiveWindow("Mon-Fri")
+	if err != nil {
+		t.Fatal(err)
+	}
+	r.AddActiveWindow(w)
+	if err := waf.Rules.Add(r); err != nil {
+		t.Fatal(err)
+	}
+
+	tx := waf.NewTransaction()
+	waf.Rules.Eval(types.PhaseRequestHeaders, tx)
+	if len(tx.MatchedRules()) != 0 {
+		t.Error("expected rule to be skipped outside of its active window")
+	}
+
+	// Monday
+	waf.Clock = func() time.Time { return time.Date(2024, time.January, 8, 12, 0, 0, 0, time.Local) }
+	tx = waf.NewTransaction()
+	waf.Rules.Eval(types.PhaseRequestHeaders, tx)
+	if len(tx.MatchedRules()) != 1 {
+		t.Error("expected rule to be evaluated inside its active window")
+	}
+}
+
+func TestRuleGroupReachesDisabledMarkers(t *testing.T) {
+	waf := NewWAF()
+	marker := NewRule()
+	marker.SecMark_ = "END"
+	marker.Phase_ = 0
+	if err := waf.Rules.Add(marker); err != nil {
+		t.Fatal(err)
+	}
+	r := NewRule()
+	r.ID_ = 1
+	r.Phase_ = types.PhaseRequestHeaders
+	if err := waf.Rules.Add(r); err != nil {
+		t.Fatal(err)
+	}
+	waf.Rules.DisableByID(0)
+
+	tx := waf.NewTransaction()
+	tx.SkipAfter = "END"
+	waf.Rules.Eval(types.PhaseRequestHeaders, tx)
+	if len(tx.MatchedRules()) != 1 {
+		t.Error("expected the rules after a disabled marker to be evaluated")
+	}
+}
+
+func TestWAFWithoutClock(t *testing.T) {
+	waf := NewWAF()
+	waf.Clock = nil
+	tx := waf.NewTransaction()
+	defer tx.Close()
+	if tx.Timestamp == 0 {
+		t.Error("expected the transaction to be timestamped without clock")
+	}
+}
@@ -5,6 +5,8 @@ package corazawaf
 
 import (
 	"fmt"
+	"regexp"
+	"slices"
 	"time"
 
 	"github.com/ad3n/seclang/internal/corazatypes"
@@ -19,18 +21,24 @@ import (
 // after compilation
 type RuleGroup struct {
 	rules []Rule
+	// tagIndex partitions the rules by tag for Transaction.SelectRulesByTag
+	tagIndex *ruleTagIndex
 }
 
 // Add a rule to the collection
-// Will return an error if the ID is already used
+// Will return an error if the ID is already used, the error
+// contains the file and line of both rules
 func (rg *RuleGroup) Add(rule *Rule) error {
 	if rule == nil {
 		// this is an ugly solution but chains should not return rules
 		return nil
 	}
 
-	if rule.ID_ != 0 && rg.FindByID(rule.ID_) != nil {
-		return fmt.Errorf("there is a another rule with id %d", rule.ID_)
+	if rule.ID_ != 0 {
+		if existing := rg.FindByID(rule.ID_); existing != nil {
+			return fmt.Errorf("there is another rule with id %d defined at %s, duplicated at %s",
+				rule.ID_, existing.Location(), rule.Location())
+		}
 	}
 
 	numInferred := 0
@@ -48,6 +56,7 @@ func (rg *RuleGroup) Add(rule *Rule) error {
 	}
 
 	rg.rules = append(rg.rules, *rule)
+	rg.invalidateTagIndex()
 	return nil
 }
 
@@ -71,6 +80,7 @@ func (rg *RuleGroup) DeleteByID(id int) {
 	for i, r := range rg.rules {
 		if r.ID_ == id {
 			rg.rules = append(rg.rules[:i], rg.rules[i+1:]...)
+			rg.invalidateTagIndex()
 			return
 		}
 	}
@@ -85,6 +95,7 @@ func (rg *RuleGroup) DeleteByRange(start, end int) {
 		}
 	}
 	rg.rules = kept
+	rg.invalidateTagIndex()
 }
 
 // DeleteByMsg deletes rules with the given message.
@@ -96,6 +107,7 @@ func (rg *RuleGroup) DeleteByMsg(msg string) {
 		}
 	}
 	rg.rules = kept
+	rg.invalidateTagIndex()
 }
 
 // DeleteByTag deletes rules with the given tag.
@@ -107,6 +119,83 @@ func (rg *RuleGroup) DeleteByTag(tag string) {
 		}
 	}
 	rg.rules = kept
+	rg.invalidateTagIndex()
+}
+
+// DeleteByMsgRegex deletes rules whose message matches the regular
+// expression and returns the IDs of the removed rules
+func (rg *RuleGroup) DeleteByMsgRegex(re *regexp.Regexp) []int {
+	return rg.deleteWhere(func(r *Rule) bool {
+		return r.Msg != nil && re.MatchString(r.Msg.String())
+	})
+}
+
+// DeleteByTagRegex deletes rules with any tag matching the regular
+// expression and returns the IDs of the removed rules
+func (rg *RuleGroup) DeleteByTagRegex(re *regexp.Regexp) []int {
+	return rg.deleteWhere(func(r *Rule) bool {
+		return slices.ContainsFunc(r.Tags_, re.MatchString)
+	})
+}
+
+// deleteWhere deletes the rules matching the filter and returns their IDs
+func (rg *RuleGroup) deleteWhere(filter func(r *Rule) bool) []int {
+	var (
+		kept    []Rule
+		removed []int
+	)
+	for i := range rg.rules {
+		if filter(&rg.rules[i]) {
+			removed = append(removed, rg.rules[i].ID_)
+			continue
+		}
+		kept = append(kept, rg.rules[i])
+	}
+	rg.rules = kept
+	rg.invalidateTagIndex()
+	return removed
+}
+
+// DisableByID disables the rules with the given IDs at runtime. Disabled
+// rules are skipped by new evaluations without re-parsing, it is safe to
+// call it while transactions are being processed.
+func (rg *RuleGroup) DisableByID(ids ...int) {
+	rg.setDisabled(true, func(r *Rule) bool { return slices.Contains(ids, r.ID_) })
+}
+
+// EnableByID enables the rules with the given IDs disabled by DisableByID
+func (rg *RuleGroup) EnableByID(ids ...int) {
+	rg.setDisabled(false, func(r *Rule) bool { return slices.Contains(ids, r.ID_) })
+}
+
+// DisableByRange disables the rules with ID between start and end at runtime
+func (rg *RuleGroup) DisableByRange(start, end int) {
+	rg.setDisabled(true, func(r *Rule) bool { return r.ID_ >= start && r.ID_ <= end })
+}
+
+// EnableByRange enables the rules with ID between start and end
+func (rg *RuleGroup) EnableByRange(start, end int) {
+	rg.setDisabled(false, func(r *Rule) bool { return r.ID_ >= start && r.ID_ <= end })
+}
+
+// DisableByTag disables the rules with the given tag at runtime
+func (rg *RuleGroup) DisableByTag(tag string) {
+	rg.setDisabled(true, func(r *Rule) bool { return utils.InSlice(tag, r.Tags_) })
+}
+
+// EnableByTag enables the rules with the given tag
+func (rg *RuleGroup) EnableByTag(tag string) {
+	rg.setDisabled(false, func(r *Rule) bool { return utils.InSlice(tag, r.Tags_) })
+}
+
+// setDisabled changes the disabled flag of the rules matching the filter,
+// the slice is not modified so it doesn't race with Eval
+func (rg *RuleGroup) setDisabled(disabled bool, filter func(r *Rule) bool) {
+	for i := range rg.rules {
+		if filter(&rg.rules[i]) {
+			rg.rules[i].setDisabled(disabled)
+		}
+	}
 }
 
 // Count returns the count of rules
@@ -118,26 +207,50 @@ func (rg *RuleGroup) Count() int {
 // Rules are evaluated in syntactic order and the evaluation finishes
 // as soon as an interruption has been triggered.
 // Returns true if transaction is disrupted
-func (rg *RuleGroup) Eval(phase types.RulePhase, tx *Transaction) bool {
+func (rg *RuleGroup) Eval(phase types.RulePhase, tx *Transaction) (interrupted bool) {
 	tx.DebugLogger().Debug().
 		Int("phase", int(phase)).
 		Msg("Evaluating phase")
 
 	tx.lastPhase = phase
+	if tx.watchdog != nil {
+		tx.watchdog.phase.Store(int32(phase))
+	}
 	usedRules := 0
 	ts := time.Now().UnixNano()
 	transformationCache := tx.transformationCache
 	for k := range transformationCache {
 		delete(transformationCache, k)
 	}
+	count := len(rg.rules)
+	if tx.selectedRules != nil {
+		count = len(tx.selectedRules)
+	}
+	// current is the rule being evaluated, for the panic dump
+	var current *Rule
+	if tx.WAF.PanicDump != nil {
+		defer func() {
+			if v := recover(); v != nil {
+				tx.rulePanicked(v, phase, current)
+				interrupted = tx.interruption != nil
+			}
+		}()
+	}
 RulesLoop:
-	for i := range rg.rules {
+	for n := 0; n < count; n++ {
+		i := n
+		if tx.selectedRules != nil {
+			i = tx.selectedRules[n]
+		}
 		r := &rg.rules[i]
 		// if there is already an interruption and the phase isn't logging
 		// we break the loop
 		if tx.interruption != nil && phase != types.PhaseLogging {
 			break RulesLoop
 		}
+		if phase != types.PhaseLogging && tx.checkMemoryLimit(r) {
+			break RulesLoop
+		}
 		// Rules with phase 0 will always run
 		if r.Phase_ != 0 && r.Phase_ != phase {
 			// Execute the rule in inferred phases too if multiphase evaluation is enabled
@@ -183,6 +296,30 @@ RulesLoop:
 			// Skipping rule
 			continue
 		}
+
+		// the markers above are reached even if they are disabled, inactive
+		// or not sampled, otherwise skipAfter would skip the rest of the phase
+		if r.Disabled() {
+			tx.DebugLogger().Debug().
+				Int("rule_id", r.ID_).
+				Msg("Skipping disabled rule")
+			continue
+		}
+
+		if !r.IsActiveAt(time.Unix(0, tx.Timestamp)) {
+			tx.DebugLogger().Debug().
+				Int("rule_id", r.ID_).
+				Msg("Skipping rule outside of its active window")
+			continue
+		}
+
+		if !r.IsSampled(tx.id) {
+			tx.DebugLogger().Debug().
+				Int("rule_id", r.ID_).
+				Msg("Skipping rule for a transaction out of its sample")
+			continue
+		}
+
 		switch tx.AllowType {
 		case corazatypes.AllowTypeUnset:
 			break
@@ -215,8 +352,16 @@ RulesLoop:
 		// TODO these lines are SUPER SLOW
 		// we reset matched_vars, matched_vars_names, etc
 		tx.variables.matchedVars.Reset()
+		tx.matchesTruncated = false
 
-		r.Evaluate(phase, tx, transformationCache)
+		current = r
+		if tx.WAF.RulePerfTime > 0 {
+			start := time.Now()
+			r.Evaluate(phase, tx, transformationCache)
+			tx.recordRulePerf(r.ID_, time.Since(start))
+		} else {
+			r.Evaluate(phase, tx, transformationCache)
+		}
 		tx.Capture = false // we reset captures
 		usedRules++
 	}
@@ -0,0 +1,117 @@
+// Copyright 2024 Juan Pablo Tosso and the OWASP Coraza contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package corazawaf
+
+import (
+	"slices"
+
+	"github.com/ad3n/seclang/internal/corazatypes"
+	"github.com/corazawaf/coraza/v3/types"
+)
+
+// RuleFilter selects the rules returned by RuleGroup.Query,
+// zero values match every rule
+type RuleFilter struct {
+	// Phase of the rules
+	Phase types.RulePhase
+	// Tag the rules must contain
+	Tag string
+	// MinID and MaxID delimit the range of IDs, both inclusive.
+	// A zero MaxID means no upper bound
+	MinID int
+	MaxID int
+	// File the rules were loaded from
+	File string
+}
+
+func (f RuleFilter) match(r *Rule) bool {
+	if f.Phase != 0 && r.Phase_ != f.Phase {
+		return false
+	}
+	if f.Tag != "" && !slices.Contains(r.Tags_, f.Tag) {
+		return false
+	}
+	if r.ID_ < f.MinID || (f.MaxID != 0 && r.ID_ > f.MaxID) {
+		return false
+	}
+	return f.File == "" || r.File_ == f.File
+}
+
+// RuleInfo is a read-only description of a loaded rule
+type RuleInfo struct {
+	ID       int
+	Phase    types.RulePhase
+	Msg      string
+	Severity types.RuleSeverity
+	Tags     []string
+	File     string
+	Line     int
+	// Operator is the name of the operator (e.g. @rx or !@pm),
+	// empty for SecAction and SecMarker
+	Operator string
+	// Variables using the seclang syntax, e.g. ARGS:id, &ARGS or !ARGS:id
+	Variables []string
+	Disabled  bool
+	// Chain describes the chained rule, nil if the rule isn't chained
+	Chain *RuleInfo
+}
+
+// Query returns the description of the rules matching the filter in
+// evaluation order. The result is a copy, modifying it doesn't affect
+// the rules.
+func (rg *RuleGroup) Query(filter RuleFilter) []RuleInfo {
+	var res []RuleInfo
+	for i := range rg.rules {
+		if r := &rg.rules[i]; filter.match(r) {
+			res = append(res, r.info())
+		}
+	}
+	return res
+}
+
+func (r *Rule) info() RuleInfo {
+	info := RuleInfo{
+		ID:       r.ID_,
+		Phase:    r.Phase_,
+		Severity: r.Severity_,
+		Tags:     slices.Clone(r.Tags_),
+		File:     r.File_,
+		Line:     r.Line_,
+		Disabled: r.Disabled(),
+	}
+	if r.Msg != nil {
+		info.Msg = r.Msg.String()
+	}
+	if r.operator != nil {
+		info.Operator = r.operator.Function
+	}
+	var exceptions []string
+	for _, v := range r.variables {
+		info.Variables = append(info.Variables, variableString(corazatypes.VariableName(v.Variable), v.Count, v.KeyStr))
+		for _, e := range v.Exceptions {
+			ex := "!" + variableString(corazatypes.VariableName(v.Variable), false, e.KeyStr)
+			if !slices.Contains(exceptions, ex) {
+				exceptions = append(exceptions, ex)
+			}
+		}
+	}
+	info.Variables = append(info.Variables, exceptions...)
+	if r.Chain != nil {
+		chain := r.Chain.info()
+		info.Chain = &chain
+	}
+	return info
+}
+
+// variableString returns the variable using the seclang syntax,
+// regular expression keys already contain the slashes
+func variableString(name string, count bool, key string) string {
+	if count {
+		name = "&" + name
+	}
+	if key == "" {
+		return name
+	}
+	return name + ":" + key
+}
@@ -0,0 +1,82 @@
+// Copyright 2024 Juan Pablo Tosso and the OWASP Coraza contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package corazawaf
+
+import (
+	"errors"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/corazawaf/coraza/v3/types"
+)
+
+// ruleTagIndex partitions the rules of a group by tag, it is built on first
+// use so the tags updated while parsing, e.g. by SecRuleUpdateActionById,
+// are indexed. The group replaces it every time its rules change.
+type ruleTagIndex struct {
+	once sync.Once
+	// rules contains the positions of the rules of each tag, in order
+	rules map[string][]int
+	// markers contains the positions of the SecMarker rules, they are part
+	// of every partition so skipAfter keeps working
+	markers []int
+}
+
+func (idx *ruleTagIndex) build(rules []Rule) {
+	idx.rules = map[string][]int{}
+	for i := range rules {
+		if rules[i].SecMark_ != "" {
+			idx.markers = append(idx.markers, i)
+		}
+		for _, tag := range rules[i].Tags_ {
+			if positions := idx.rules[tag]; len(positions) == 0 || positions[len(positions)-1] != i {
+				idx.rules[tag] = append(positions, i)
+			}
+		}
+	}
+}
+
+// invalidateTagIndex discards the tag index after the rules changed
+func (rg *RuleGroup) invalidateTagIndex() {
+	rg.tagIndex = &ruleTagIndex{}
+}
+
+// positionsByTag returns the positions of the rules with any of the tags, along
+// with the SecMarker rules, in order
+func (rg *RuleGroup) positionsByTag(tags []string) []int {
+	idx := rg.tagIndex
+	if idx == nil {
+		return []int{}
+	}
+	idx.once.Do(func() { idx.build(rg.rules) })
+	positions := slices.Clone(idx.markers)
+	for _, tag := range tags {
+		positions = append(positions, idx.rules[tag]...)
+	}
+	slices.Sort(positions)
+	return slices.Compact(positions)
+}
+
+// SelectRulesByTag restricts the rules evaluated by the transaction to the ones
+// with any of the tags, e.g. "api" for the API routes, other rules are skipped
+// without being checked. The rules are partitioned by tag once, so selecting them
+// doesn't depend on the number of rules. It must be called before the request
+// headers are processed, calling it again replaces the selection and calling it
+// without tags evaluates every rule again.
+func (tx *Transaction) SelectRulesByTag(tags ...string) error {
+	if tx.lastPhase >= types.PhaseRequestHeaders {
+		return errors.New("rules must be selected before processing the request headers")
+	}
+	if len(tags) == 0 {
+		tx.selectedRules = nil
+		return nil
+	}
+	tx.selectedRules = tx.WAF.Rules.positionsByTag(tags)
+	tx.debugLogger.Debug().
+		Str("tags", strings.Join(tags, ",")).
+		Int("rules", len(tx.selectedRules)).
+		Msg("Selected rules by tag")
+	return nil
+}
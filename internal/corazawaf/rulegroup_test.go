@@ -4,9 +4,13 @@
 package corazawaf
 
 import (
+	"regexp"
+	"slices"
 	"testing"
 
 	"github.com/ad3n/seclang/experimental/plugins/macro"
+	"github.com/corazawaf/coraza/v3/types"
+	"github.com/corazawaf/coraza/v3/types/variables"
 )
 
 func newTestRule(id int) *Rule {
@@ -55,6 +59,33 @@ func TestRuleGroupDeleteByMsg(t *testing.T) {
 	}
 }
 
+func TestRuleGroupDeleteByRegex(t *testing.T) {
+	rg := NewRuleGroup()
+	for _, id := range []int{1, 2, 3} {
+		r := newTestRule(id)
+		if id == 2 {
+			r.Msg, _ = macro.NewMacro("other")
+			r.Tags_ = []string{"other"}
+		}
+		if err := rg.Add(r); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := rg.Add(NewRule()); err != nil {
+		t.Fatal(err)
+	}
+
+	if want, have := []int{1, 3}, rg.DeleteByMsgRegex(regexp.MustCompile("^te")); !slices.Equal(want, have) {
+		t.Errorf("unexpected removed rules, want %v, have %v", want, have)
+	}
+	if want, have := []int{2}, rg.DeleteByTagRegex(regexp.MustCompile("oth")); !slices.Equal(want, have) {
+		t.Errorf("unexpected removed rules, want %v, have %v", want, have)
+	}
+	if want, have := 1, rg.Count(); want != have {
+		t.Errorf("unexpected rule count, want %d, have %d", want, have)
+	}
+}
+
 func TestRuleGroupDeleteByID(t *testing.T) {
 	var (
 		r1 = newTestRule(1)
@@ -85,3 +116,188 @@ func TestRuleGroupDeleteByID(t *testing.T) {
 		t.Fatal("Unexpected remaining rule in the rulegroup")
 	}
 }
+
+func TestRuleGroupDisable(t *testing.T) {
+	waf := NewWAF()
+	for _, id := range []int{1, 2, 3} {
+		r := newTestRule(id)
+		r.Phase_ = 1
+		if err := waf.Rules.Add(r); err != nil {
+			t.Fatalf("Failed to add rule to rulegroup: %s", err.Error())
+		}
+	}
+
+	matched := func() []int {
+		tx := waf.NewTransaction()
+		defer tx.Close()
+		tx.ProcessRequestHeaders()
+		var ids []int
+		for _, mr := range tx.MatchedRules() {
+			ids = append(ids, mr.Rule().ID())
+		}
+		return ids
+	}
+
+	waf.Rules.DisableByID(1)
+	waf.Rules.DisableByRange(3, 10)
+	if have := matched(); len(have) != 1 || have[0] != 2 {
+		t.Errorf("unexpected matched rules, want [2], have %v", have)
+	}
+	if !waf.Rules.FindByID(1).Disabled() {
+		t.Error("expected rule 1 to be disabled")
+	}
+
+	waf.Rules.EnableByRange(1, 3)
+	waf.Rules.DisableByTag("test")
+	if have := matched(); len(have) != 0 {
+		t.Errorf("unexpected matched rules, want none, have %v", have)
+	}
+
+	waf.Rules.EnableByTag("test")
+	if have := matched(); len(have) != 3 {
+		t.Errorf("unexpected matched rules, want 3, have %v", have)
+	}
+}
+
+func TestSelectRulesByTag(t *testing.T) {
+	waf := NewWAF()
+	for id, tags := range [][]string{nil, {"api"}, {"web"}, {"api", "web"}, nil} {
+		r := newTestRule(id + 1)
+		r.Phase_ = 1
+		r.Tags_ = tags
+		if id == 4 {
+			r.ID_ = 0
+			r.SecMark_ = "END"
+		}
+		if err := waf.Rules.Add(r); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if want, have := []int{2, 3, 4}, waf.Rules.positionsByTag([]string{"web", "missing"}); !slices.Equal(want, have) {
+		t.Errorf("unexpected positions, want %v, have %v", want, have)
+	}
+
+	tests := []struct {
+		tags []string
+		want []int
+	}{
+		{nil, []int{1, 2, 3, 4}},
+		{[]string{"api"}, []int{2, 4}},
+		{[]string{"web", "api"}, []int{2, 3, 4}},
+		{[]string{"missing"}, nil},
+	}
+	for _, tc := range tests {
+		tx := waf.NewTransaction()
+		if err := tx.SelectRulesByTag(tc.tags...); err != nil {
+			t.Fatal(err)
+		}
+		tx.ProcessRequestHeaders()
+		var have []int
+		for _, mr := range tx.MatchedRules() {
+			have = append(have, mr.Rule().ID())
+		}
+		if !slices.Equal(tc.want, have) {
+			t.Errorf("unexpected matched rules for tags %v, want %v, have %v", tc.tags, tc.want, have)
+		}
+		if err := tx.SelectRulesByTag("api"); err == nil {
+			t.Error("expected error when selecting rules after phase 1")
+		}
+		tx.Close()
+	}
+
+	// the index is rebuilt when the rules change
+	waf.Rules.DeleteByID(2)
+	tx := waf.NewTransaction()
+	defer tx.Close()
+	if err := tx.SelectRulesByTag("api"); err != nil {
+		t.Fatal(err)
+	}
+	tx.ProcessRequestHeaders()
+	if have := tx.MatchedRules(); len(have) != 1 || have[0].Rule().ID() != 4 {
+		t.Errorf("unexpected matched rules after deleting rule 2, want [4], have %v", have)
+	}
+}
+
+func TestRuleGroupQuery(t *testing.T) {
+	rg := NewRuleGroup()
+	for _, r := range []struct {
+		id    int
+		phase types.RulePhase
+		tag   string
+		file  string
+	}{
+		{1, types.PhaseRequestHeaders, "test", "a.conf"},
+		{2, types.PhaseRequestHeaders, "other", "a.conf"},
+		{3, types.PhaseRequestBody, "test", "b.conf"},
+	} {
+		rule := newTestRule(r.id)
+		rule.Phase_ = r.phase
+		rule.Tags_ = []string{r.tag}
+		rule.File_ = r.file
+		rule.Severity_ = types.RuleSeverityCritical
+		if err := rule.AddVariable(variables.RequestHeaders, "User-Agent", false); err != nil {
+			t.Fatal(err)
+		}
+		if err := rule.AddVariable(variables.RequestCookies, "/^sess/", true); err != nil {
+			t.Fatal(err)
+		}
+		if err := rule.AddVariableNegation(variables.RequestHeaders, "referer"); err != nil {
+			t.Fatal(err)
+		}
+		rule.SetOperator(nil, "!@rx", "bot")
+		if err := rg.Add(rule); err != nil {
+			t.Fatal(err)
+		}
+	}
+	rg.DisableByID(2)
+
+	ids := func(rules []RuleInfo) []int {
+		var res []int
+		for _, r := range rules {
+			res = append(res, r.ID)
+		}
+		return res
+	}
+	for name, test := range map[string]struct {
+		filter RuleFilter
+		want   []int
+	}{
+		"all":      {RuleFilter{}, []int{1, 2, 3}},
+		"phase":    {RuleFilter{Phase: types.PhaseRequestHeaders}, []int{1, 2}},
+		"tag":      {RuleFilter{Tag: "test"}, []int{1, 3}},
+		"range":    {RuleFilter{MinID: 2, MaxID: 3}, []int{2, 3}},
+		"min id":   {RuleFilter{MinID: 3}, []int{3}},
+		"file":     {RuleFilter{File: "a.conf"}, []int{1, 2}},
+		"combined": {RuleFilter{Tag: "test", File: "b.conf"}, []int{3}},
+		"no match": {RuleFilter{Tag: "missing"}, nil},
+	} {
+		t.Run(name, func(t *testing.T) {
+			if have := ids(rg.Query(test.filter)); !slices.Equal(test.want, have) {
+				t.Errorf("unexpected rules, want %v, have %v", test.want, have)
+			}
+		})
+	}
+
+	info := rg.Query(RuleFilter{MinID: 2, MaxID: 2})[0]
+	if want, have := "test", info.Msg; want != have {
+		t.Errorf("unexpected msg, want %q, have %q", want, have)
+	}
+	if want, have := "!@rx", info.Operator; want != have {
+		t.Errorf("unexpected operator, want %q, have %q", want, have)
+	}
+	if want, have := []string{"REQUEST_HEADERS:user-agent", "&REQUEST_COOKIES:/^sess/", "!REQUEST_HEADERS:referer"}, info.Variables; !slices.Equal(want, have) {
+		t.Errorf("unexpected variables, want %v, have %v", want, have)
+	}
+	if want, have := types.RuleSeverityCritical, info.Severity; want != have {
+		t.Errorf("unexpected severity, want %v, have %v", want, have)
+	}
+	if !info.Disabled {
+		t.Error("expected rule to be reported as disabled")
+	}
+
+	// the result must not share memory with the rules
+	info.Tags[0] = "changed"
+	if rg.FindByID(2).Tags_[0] != "other" {
+		t.Error("unexpected change of the rule tags")
+	}
+}
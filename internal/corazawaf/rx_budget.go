@@ -0,0 +1,77 @@
+// Copyright 2024 Juan Pablo Tosso and the OWASP Coraza contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package corazawaf
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// RxBudget bounds the evaluation of the @rx operator, a zero limit is not
+// enforced. The time limit can't interrupt a match, the expressions are
+// matched in linear time so the input limit is what bounds it, exceeding it
+// is reported for the rules to react.
+type RxBudget struct {
+	// InputLimit is the number of bytes of the value inspected, the rest of
+	// the value is ignored
+	InputLimit int
+	// TimeLimit is the duration of a match above which the budget is exceeded
+	TimeLimit time.Duration
+}
+
+// ParseRxBudget parses a budget in the form "[input=BYTES] [time=DURATION]",
+// or "off" for an unbounded one
+func ParseRxBudget(opts string) (RxBudget, error) {
+	var b RxBudget
+	if strings.EqualFold(opts, "off") {
+		return b, nil
+	}
+	fields := strings.Fields(opts)
+	if len(fields) == 0 {
+		return b, errors.New("expected input=BYTES, time=DURATION or off")
+	}
+	for _, f := range fields {
+		key, value, _ := strings.Cut(f, "=")
+		switch strings.ToLower(key) {
+		case "input":
+			n, err := strconv.Atoi(value)
+			if err != nil || n < 0 {
+				return b, fmt.Errorf("invalid rx input limit %q", value)
+			}
+			b.InputLimit = n
+		case "time":
+			d, err := time.ParseDuration(value)
+			if err != nil || d < 0 {
+				return b, fmt.Errorf("invalid rx time limit %q", value)
+			}
+			b.TimeLimit = d
+		default:
+			return b, fmt.Errorf("unknown rx budget option %q", f)
+		}
+	}
+	return b, nil
+}
+
+// RxBudget returns the limits of the @rx evaluations of the transaction, the
+// ones of the rule being evaluated if it overrides them, otherwise the ones of
+// the WAF
+func (tx *Transaction) RxBudget() (inputLimit int, timeLimit time.Duration) {
+	b := tx.WAF.RxBudget
+	if tx.ruleRxBudget != nil {
+		b = *tx.ruleRxBudget
+	}
+	return b.InputLimit, b.TimeLimit
+}
+
+// RxBudgetExceeded reports that an @rx evaluation exceeded the limit, "input"
+// or "time", of its budget. It is exposed to rules through RX_BUDGET_EXCEEDED.
+func (tx *Transaction) RxBudgetExceeded(limit string) {
+	tx.debugLogger.Debug().
+		Str("limit", limit).
+		Msg("Regex evaluation exceeded its budget")
+	tx.variables.rxBudgetExceeded.Set("1")
+}
@@ -0,0 +1,65 @@
+// Copyright 2024 Juan Pablo Tosso and the OWASP Coraza contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package corazawaf
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseRxBudget(t *testing.T) {
+	tests := []struct {
+		opts    string
+		want    RxBudget
+		invalid bool
+	}{
+		{opts: "input=65536 time=5ms", want: RxBudget{InputLimit: 65536, TimeLimit: 5 * time.Millisecond}},
+		{opts: "TIME=1s", want: RxBudget{TimeLimit: time.Second}},
+		{opts: "Off", want: RxBudget{}},
+		{opts: "", invalid: true},
+		{opts: "input=many", invalid: true},
+		{opts: "time=-1ms", invalid: true},
+		{opts: "input", invalid: true},
+		{opts: "steps=10", invalid: true},
+	}
+	for _, tt := range tests {
+		b, err := ParseRxBudget(tt.opts)
+		if tt.invalid {
+			if err == nil {
+				t.Errorf("expected error for %q", tt.opts)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("unexpected error for %q: %s", tt.opts, err.Error())
+			continue
+		}
+		if b != tt.want {
+			t.Errorf("unexpected budget of %q, want %+v, have %+v", tt.opts, tt.want, b)
+		}
+	}
+}
+
+func TestTransactionRxBudget(t *testing.T) {
+	waf := NewWAF()
+	waf.RxBudget = RxBudget{InputLimit: 100, TimeLimit: time.Millisecond}
+	tx := waf.NewTransaction()
+	defer tx.Close()
+	if input, limit := tx.RxBudget(); input != 100 || limit != time.Millisecond {
+		t.Errorf("unexpected budget of the WAF: %d, %s", input, limit)
+	}
+
+	r := NewRule()
+	r.SetRxBudget(RxBudget{InputLimit: 10})
+	r.SetOperator(&dummyEqOperator{}, "@eq", "0")
+	r.executeOperator("0", tx)
+	if input, limit := tx.RxBudget(); input != 10 || limit != 0 {
+		t.Errorf("unexpected budget of the rule: %d, %s", input, limit)
+	}
+
+	tx.RxBudgetExceeded("input")
+	if want, have := "1", tx.variables.rxBudgetExceeded.Get(); want != have {
+		t.Errorf("unexpected RX_BUDGET_EXCEEDED, want %q, have %q", want, have)
+	}
+}
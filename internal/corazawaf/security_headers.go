@@ -0,0 +1,192 @@
+// Copyright 2024 Juan Pablo Tosso and the OWASP Coraza contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package corazawaf
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/corazawaf/coraza/v3/types"
+)
+
+// The security headers evaluated by SecSecurityHeadersEngine
+const (
+	cspHeader  = "Content-Security-Policy"
+	hstsHeader = "Strict-Transport-Security"
+	xfoHeader  = "X-Frame-Options"
+)
+
+// The statuses of the security headers, SECURITY_HEADERS:csp, :hsts and :xfo
+const (
+	securityHeaderOK       = "ok"
+	securityHeaderWeak     = "weak"
+	securityHeaderMissing  = "missing"
+	securityHeaderInjected = "injected"
+)
+
+// minHSTSMaxAge is the max-age below which HSTS is weak, 180 days
+const minHSTSMaxAge = 180 * 24 * 60 * 60
+
+// defaultSecurityHeaders are the values injected for the missing headers
+// unless configured with SecSecurityHeaderDefault, the content security
+// policies are specific to each application so none is injected by default
+var defaultSecurityHeaders = map[string]string{
+	strings.ToLower(hstsHeader): "max-age=31536000; includeSubDomains",
+	strings.ToLower(xfoHeader):  "SAMEORIGIN",
+}
+
+// SetSecurityHeaderDefault configures the value injected when a security
+// header is missing, an empty value disables its injection
+func (w *WAF) SetSecurityHeaderDefault(header string, value string) error {
+	header = strings.ToLower(header)
+	switch header {
+	case strings.ToLower(cspHeader), strings.ToLower(hstsHeader), strings.ToLower(xfoHeader):
+	default:
+		return fmt.Errorf("unsupported security header %q", header)
+	}
+	if w.securityHeaderDefaults == nil {
+		w.securityHeaderDefaults = map[string]string{}
+	}
+	w.securityHeaderDefaults[header] = value
+	return nil
+}
+
+// securityHeaderDefault returns the value injected when the header is missing
+func (w *WAF) securityHeaderDefault(header string) string {
+	header = strings.ToLower(header)
+	if v, ok := w.securityHeaderDefaults[header]; ok {
+		return v
+	}
+	return defaultSecurityHeaders[header]
+}
+
+// evaluateSecurityHeaders records the status of the security headers of the
+// response in SECURITY_HEADERS, the reason of the weak ones in their
+// <key>_reason, and injects the missing ones when the engine is On
+func (tx *Transaction) evaluateSecurityHeaders() {
+	if tx.WAF.SecurityHeadersEngine == types.RuleEngineOff {
+		return
+	}
+	headers := tx.variables.responseHeaders
+
+	frameAncestors := false
+	csp, ok := headers.GetFirst(strings.ToLower(cspHeader))
+	weakness := ""
+	if ok {
+		weakness, frameAncestors = cspWeakness(csp)
+	}
+	tx.setSecurityHeader("csp", cspHeader, ok, weakness)
+
+	hsts, ok := headers.GetFirst(strings.ToLower(hstsHeader))
+	weakness = ""
+	if ok {
+		weakness = hstsWeakness(hsts)
+	}
+	tx.setSecurityHeader("hsts", hstsHeader, ok, weakness)
+
+	xfo, ok := headers.GetFirst(strings.ToLower(xfoHeader))
+	weakness = ""
+	if ok {
+		weakness = xfoWeakness(xfo)
+	}
+	// frame-ancestors supersedes X-Frame-Options
+	tx.setSecurityHeader("xfo", xfoHeader, ok || frameAncestors, weakness)
+}
+
+func (tx *Transaction) setSecurityHeader(key string, header string, present bool, weakness string) {
+	status := securityHeaderOK
+	switch {
+	case !present:
+		status = securityHeaderMissing
+		if tx.WAF.SecurityHeadersEngine != types.RuleEngineOn {
+			break
+		}
+		if v := tx.WAF.securityHeaderDefault(header); v != "" {
+			tx.SetResponseHeader(header, v)
+			status = securityHeaderInjected
+		}
+	case weakness != "":
+		status = securityHeaderWeak
+		tx.variables.securityHeaders.Set(key+"_reason", []string{weakness})
+	}
+	tx.variables.securityHeaders.Set(key, []string{status})
+}
+
+// cspWeakness returns why the content security policy doesn't protect from
+// script injections, empty if it does, and whether it sets frame-ancestors
+func cspWeakness(policy string) (string, bool) {
+	directives := map[string][]string{}
+	for _, d := range strings.Split(policy, ";") {
+		fields := strings.Fields(d)
+		if len(fields) == 0 {
+			continue
+		}
+		name := strings.ToLower(fields[0])
+		// the first occurrence of a directive wins
+		if _, ok := directives[name]; !ok {
+			directives[name] = fields[1:]
+		}
+	}
+	_, frameAncestors := directives["frame-ancestors"]
+	sources, ok := directives["script-src"]
+	if !ok {
+		if sources, ok = directives["default-src"]; !ok {
+			return "no script-src or default-src", frameAncestors
+		}
+	}
+	// 'unsafe-inline' is ignored by the browsers when a nonce or hash is set
+	hashed := false
+	for _, s := range sources {
+		s = strings.ToLower(s)
+		for _, prefix := range []string{"'nonce-", "'sha256-", "'sha384-", "'sha512-"} {
+			hashed = hashed || strings.HasPrefix(s, prefix)
+		}
+	}
+	var reasons []string
+	for _, s := range sources {
+		switch s = strings.ToLower(s); s {
+		case "'unsafe-inline'":
+			if !hashed {
+				reasons = append(reasons, "unsafe-inline")
+			}
+		case "'unsafe-eval'":
+			reasons = append(reasons, "unsafe-eval")
+		case "*", "http:", "https:", "data:":
+			reasons = append(reasons, "wildcard source "+s)
+		}
+	}
+	return strings.Join(reasons, ", "), frameAncestors
+}
+
+// hstsWeakness returns why the HSTS policy is weak, empty if it is not
+func hstsWeakness(policy string) string {
+	for _, d := range strings.Split(policy, ";") {
+		name, value, _ := strings.Cut(d, "=")
+		if !strings.EqualFold(strings.TrimSpace(name), "max-age") {
+			continue
+		}
+		age, err := strconv.Atoi(strings.Trim(strings.TrimSpace(value), `"`))
+		switch {
+		case err != nil || age < 0:
+			return "invalid max-age"
+		case age < minHSTSMaxAge:
+			return "max-age below 180 days"
+		}
+		return ""
+	}
+	return "missing max-age"
+}
+
+// xfoWeakness returns why the X-Frame-Options value is weak, empty if it is not
+func xfoWeakness(value string) string {
+	value = strings.ToUpper(strings.TrimSpace(value))
+	switch {
+	case value == "DENY" || value == "SAMEORIGIN":
+		return ""
+	case strings.HasPrefix(value, "ALLOW-FROM"):
+		return "ALLOW-FROM is not supported by browsers"
+	}
+	return "invalid value"
+}
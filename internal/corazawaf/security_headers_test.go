@@ -0,0 +1,179 @@
+// Copyright 2024 Juan Pablo Tosso and the OWASP Coraza contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package corazawaf
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/corazawaf/coraza/v3/types"
+)
+
+func TestCSPWeakness(t *testing.T) {
+	tests := []struct {
+		policy         string
+		weakness       string
+		frameAncestors bool
+	}{
+		{"default-src 'self'", "", false},
+		{"default-src 'self'; frame-ancestors 'none'", "", true},
+		{"script-src 'self' 'unsafe-inline'", "unsafe-inline", false},
+		{"script-src 'self' 'unsafe-inline' 'nonce-abc'", "", false},
+		{"script-src 'self' 'unsafe-inline' 'sha256-abc'", "", false},
+		{"default-src *; script-src 'self'", "", false},
+		{"default-src * 'unsafe-eval'", "wildcard source *, unsafe-eval", false},
+		{"script-src https:; script-src 'self'", "wildcard source https:", false},
+		{"img-src 'self'", "no script-src or default-src", false},
+		{"", "no script-src or default-src", false},
+	}
+	for _, tt := range tests {
+		weakness, frameAncestors := cspWeakness(tt.policy)
+		if want, have := tt.weakness, weakness; want != have {
+			t.Errorf("unexpected weakness of %q, want %q, have %q", tt.policy, want, have)
+		}
+		if want, have := tt.frameAncestors, frameAncestors; want != have {
+			t.Errorf("unexpected frame-ancestors of %q, want %t, have %t", tt.policy, want, have)
+		}
+	}
+}
+
+func TestHSTSWeakness(t *testing.T) {
+	tests := []struct {
+		policy   string
+		weakness string
+	}{
+		{"max-age=31536000; includeSubDomains", ""},
+		{`max-age="15552000"`, ""},
+		{"includeSubDomains; MAX-AGE=15552000", ""},
+		{"max-age=3600", "max-age below 180 days"},
+		{"max-age=0", "max-age below 180 days"},
+		{"max-age=forever", "invalid max-age"},
+		{"includeSubDomains", "missing max-age"},
+	}
+	for _, tt := range tests {
+		if want, have := tt.weakness, hstsWeakness(tt.policy); want != have {
+			t.Errorf("unexpected weakness of %q, want %q, have %q", tt.policy, want, have)
+		}
+	}
+}
+
+func TestXFOWeakness(t *testing.T) {
+	tests := []struct {
+		value    string
+		weakness string
+	}{
+		{"DENY", ""},
+		{" sameorigin ", ""},
+		{"ALLOW-FROM https://example.com", "ALLOW-FROM is not supported by browsers"},
+		{"ALLOWALL", "invalid value"},
+	}
+	for _, tt := range tests {
+		if want, have := tt.weakness, xfoWeakness(tt.value); want != have {
+			t.Errorf("unexpected weakness of %q, want %q, have %q", tt.value, want, have)
+		}
+	}
+}
+
+func TestEvaluateSecurityHeaders(t *testing.T) {
+	tests := []struct {
+		name      string
+		engine    types.RuleEngineStatus
+		defaults  map[string]string
+		headers   map[string]string
+		want      map[string][]string
+		mutations []ResponseHeaderMutation
+	}{
+		{
+			name:   "off",
+			engine: types.RuleEngineOff,
+			want:   map[string][]string{"csp": nil, "hsts": nil},
+		},
+		{
+			name:   "detection only",
+			engine: types.RuleEngineDetectionOnly,
+			headers: map[string]string{
+				"Content-Security-Policy":   "script-src 'unsafe-inline'",
+				"Strict-Transport-Security": "max-age=31536000",
+			},
+			want: map[string][]string{
+				"csp":         {"weak"},
+				"csp_reason":  {"unsafe-inline"},
+				"hsts":        {"ok"},
+				"hsts_reason": nil,
+				"xfo":         {"missing"},
+			},
+		},
+		{
+			name:    "frame-ancestors",
+			engine:  types.RuleEngineOn,
+			headers: map[string]string{"Content-Security-Policy": "default-src 'self'; frame-ancestors 'self'"},
+			want: map[string][]string{
+				"csp":  {"ok"},
+				"hsts": {"injected"},
+				"xfo":  {"ok"},
+			},
+			mutations: []ResponseHeaderMutation{{Name: "Strict-Transport-Security", Value: "max-age=31536000; includeSubDomains"}},
+		},
+		{
+			name:   "injection",
+			engine: types.RuleEngineOn,
+			defaults: map[string]string{
+				"content-security-policy":   "default-src 'self'",
+				"strict-transport-security": "",
+			},
+			want: map[string][]string{
+				"csp":  {"injected"},
+				"hsts": {"missing"},
+				"xfo":  {"injected"},
+			},
+			mutations: []ResponseHeaderMutation{
+				{Name: "Content-Security-Policy", Value: "default-src 'self'"},
+				{Name: "X-Frame-Options", Value: "SAMEORIGIN"},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			waf := NewWAF()
+			waf.SecurityHeadersEngine = tt.engine
+			for header, value := range tt.defaults {
+				if err := waf.SetSecurityHeaderDefault(header, value); err != nil {
+					t.Fatal(err)
+				}
+			}
+			tx := waf.NewTransaction()
+			defer tx.Close()
+			for header, value := range tt.headers {
+				tx.AddResponseHeader(header, value)
+			}
+			tx.ProcessResponseHeaders(200, "HTTP/1.1")
+			for key, want := range tt.want {
+				if have := tx.variables.securityHeaders.Get(key); !slices.Equal(want, have) {
+					t.Errorf("unexpected %s, want %q, have %q", key, want, have)
+				}
+			}
+			if want, have := tt.mutations, tx.ResponseHeaderMutations(); !slices.Equal(want, have) {
+				t.Errorf("unexpected mutations, want %v, have %v", want, have)
+			}
+			for _, m := range tt.mutations {
+				if have, _ := tx.variables.responseHeaders.GetFirst(m.Name); have != m.Value {
+					t.Errorf("unexpected %s response header, want %q, have %q", m.Name, m.Value, have)
+				}
+			}
+		})
+	}
+}
+
+func TestSetSecurityHeaderDefault(t *testing.T) {
+	waf := NewWAF()
+	if err := waf.SetSecurityHeaderDefault("Server", "none"); err == nil {
+		t.Error("expected error for an unsupported header")
+	}
+	if err := waf.SetSecurityHeaderDefault("X-Frame-Options", "DENY"); err != nil {
+		t.Fatal(err)
+	}
+	if want, have := "DENY", waf.securityHeaderDefault("x-frame-options"); want != have {
+		t.Errorf("unexpected default, want %q, have %q", want, have)
+	}
+}
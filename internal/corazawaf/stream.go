@@ -0,0 +1,70 @@
+// Copyright 2024 Juan Pablo Tosso and the OWASP Coraza contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package corazawaf
+
+import (
+	"io"
+
+	"github.com/ad3n/seclang/internal/collections"
+
+	"github.com/corazawaf/coraza/v3/types"
+)
+
+// streamBody returns the buffer and the variable of the body streamed in the
+// current phase, nil if the stream inspection of the body is disabled
+func (tx *Transaction) streamBody() (*BodyBuffer, *collections.Single) {
+	switch tx.lastPhase {
+	case types.PhaseRequestBody:
+		if tx.WAF.StreamInBodyInspection {
+			return tx.requestBodyBuffer, tx.variables.streamInputBody
+		}
+	case types.PhaseResponseBody:
+		if tx.WAF.StreamOutBodyInspection {
+			return tx.responseBodyBuffer, tx.variables.streamOutputBody
+		}
+	}
+	return nil, nil
+}
+
+// setStreamBody sets the stream variable to the raw content of the buffer,
+// it holds the body regardless of the body processor
+func setStreamBody(buffer *BodyBuffer, v *collections.Single) error {
+	reader, err := buffer.Reader()
+	if err != nil {
+		return err
+	}
+	body, err := io.ReadAll(reader)
+	if err != nil {
+		return err
+	}
+	v.Set(string(body))
+	return nil
+}
+
+// RewriteStreamBody replaces the body streamed in the current phase, the request
+// body in phase 2 and the response body in phase 4, with the replacement. It is
+// used by the @rsub operator. It returns false if the stream inspection of the
+// body is disabled, original is not the body or the replacement exceeds the body limit.
+func (tx *Transaction) RewriteStreamBody(original, replacement string) bool {
+	buffer, v := tx.streamBody()
+	if buffer == nil || v.Get() != original {
+		return false
+	}
+	if int64(len(replacement)) > buffer.options.Limit {
+		tx.debugLogger.Warn().
+			Int("length", len(replacement)).
+			Msg("Body not rewritten, the replacement exceeds the body limit")
+		return false
+	}
+	if err := buffer.Reset(); err != nil {
+		tx.debugLogger.Error().Err(err).Msg("Failed to reset the body buffer")
+		return false
+	}
+	if _, err := buffer.Write([]byte(replacement)); err != nil {
+		tx.debugLogger.Error().Err(err).Msg("Failed to rewrite the body")
+		return false
+	}
+	v.Set(replacement)
+	return true
+}
@@ -28,6 +28,7 @@ import (
 	"github.com/ad3n/seclang/internal/environment"
 	stringsutil "github.com/ad3n/seclang/internal/strings"
 	urlutil "github.com/ad3n/seclang/internal/url"
+	"github.com/ad3n/seclang/internal/xmlschema"
 	"github.com/corazawaf/coraza/v3/collection"
 	"github.com/corazawaf/coraza/v3/debuglog"
 	"github.com/corazawaf/coraza/v3/types"
@@ -91,6 +92,10 @@ type Transaction struct {
 	// Rules with this id are going to be skipped while processing a phase
 	ruleRemoveByID []int
 
+	// selectedRules contains the positions of the only rules evaluated, all
+	// the rules are evaluated when it is nil
+	selectedRules []int
+
 	// ruleRemoveTargetByID is used by ctl to remove rule targets by id during the
 	// transaction. All other "target removers" like "ByTag" are an abstraction of "ById"
 	// For example, if you want to remove REQUEST_HEADERS:User-Agent from rule 85:
@@ -105,9 +110,45 @@ type Transaction struct {
 	// We must reuse it in the future
 	Capture bool
 
+	// captureCount is the number of fields captured by the last operator,
+	// rules read it as CAPTURE_COUNT
+	captureCount int
+
+	// namedCaptures are the lowercased TX keys set by CaptureNamedField, the
+	// panic dumps redact them like the numbered captures
+	namedCaptures map[string]bool
+
 	// Contains duration in useconds per phase
 	stopWatches map[types.RulePhase]int64
 
+	// perfRules contains the rules that took longer than SecRulePerfTime
+	perfRules []rulePerf
+
+	// normalizedURL is the normalized URL of the request, it is derived from
+	// the request when REQUEST_URL_NORMALIZED or REQUEST_PATH_SEGMENTS is read
+	normalizedURL *normalizedURL
+
+	// memory accounts the keys and values of the collections and the matched
+	// data, see MemoryUsage
+	memory collections.MemoryCounter
+	// memoryLimitExceeded is set once the memory exceeds TransactionMemoryLimit
+	memoryLimitExceeded bool
+
+	// persistentCollections are the persistent collections initialized with
+	// initcol, they are stored when the transaction is closed
+	persistentCollections []persistentCollection
+
+	// ruleRxBudget is the budget of the rule being evaluated, nil if it
+	// doesn't override the one of the WAF
+	ruleRxBudget *RxBudget
+
+	// responseHeaderMutations are the response headers set by the WAF
+	responseHeaderMutations []ResponseHeaderMutation
+
+	// watchdog flags the transaction if it is not closed in time, it is nil
+	// unless TransactionWatchdogTimeout is set
+	watchdog *txWatchdog
+
 	// Contains a WAF instance for the current transaction
 	WAF *WAF
 
@@ -120,9 +161,20 @@ type Transaction struct {
 	// it will write to the audit log
 	audit bool
 
+	// matchesTruncated is set when the rule being evaluated reached the
+	// rule match limit, it is reset before evaluating each rule
+	matchesTruncated bool
+
+	// idTracked is true if the ID was registered as in flight in the WAF
+	idTracked bool
+
 	variables TransactionVariables
 
 	transformationCache map[transformationKey]*transformationValue
+
+	// operatorValues are the values computed by the operators once per
+	// transaction, see OperatorValue
+	operatorValues map[any]any
 }
 
 func (tx *Transaction) ID() string {
@@ -209,6 +261,58 @@ func (tx *Transaction) Collection(idx variables.RuleVariable) collection.Collect
 		return tx.variables.highestSeverity
 	case variables.StatusLine:
 		return tx.variables.statusLine
+	case corazatypes.StreamInputBody:
+		return tx.variables.streamInputBody
+	case corazatypes.StreamOutputBody:
+		return tx.variables.streamOutputBody
+	case corazatypes.PerfCombined:
+		return tx.variables.perfCombined
+	case corazatypes.PerfPhase1, corazatypes.PerfPhase2, corazatypes.PerfPhase3, corazatypes.PerfPhase4, corazatypes.PerfPhase5:
+		return tx.variables.perfPhases[idx-corazatypes.PerfPhase1]
+	case corazatypes.PerfRules:
+		return tx.variables.perfRules
+	case corazatypes.RequestURLNormalized:
+		return tx.variables.requestURLNormalized
+	case corazatypes.RequestPathSegments:
+		return tx.variables.requestPathSegments
+	case corazatypes.ResponseCookies:
+		return tx.variables.responseCookies
+	case corazatypes.ResponseCookiesAttrs:
+		return tx.variables.responseCookiesAttrs
+	case corazatypes.RequestContentTypeAnomalies:
+		return tx.variables.contentTypeAnomalies
+	case corazatypes.RemoteScore:
+		return tx.variables.remoteScore
+	case corazatypes.RxBudgetExceeded:
+		return tx.variables.rxBudgetExceeded
+	case corazatypes.SecurityHeaders:
+		return tx.variables.securityHeaders
+	case corazatypes.MemoryUsage:
+		return tx.variables.memoryUsage
+	case corazatypes.MemoryLimitExceeded:
+		return tx.variables.memoryLimitExceeded
+	case corazatypes.CaptureCount:
+		return tx.variables.captureCount
+	case corazatypes.XMLExternalEntity:
+		return tx.variables.xmlExternalEntity
+	case corazatypes.MultipartOriginalValues:
+		return tx.variables.multipartOriginalValues
+	case corazatypes.MultipartCharsetError:
+		return tx.variables.multipartCharsetError
+	case corazatypes.MultipartFileLimitExceeded:
+		return tx.variables.multipartFileLimitExceeded
+	case corazatypes.YaraMatches:
+		return tx.variables.yaraMatches
+	case corazatypes.Global:
+		return tx.variables.global
+	case corazatypes.IP:
+		return tx.variables.ip
+	case corazatypes.Resource:
+		return tx.variables.resource
+	case corazatypes.Session:
+		return tx.variables.session
+	case corazatypes.User:
+		return tx.variables.user
 	case variables.Duration:
 		return tx.variables.duration
 	case variables.ResponseHeadersNames:
@@ -327,6 +431,31 @@ func (tx *Transaction) RequestBodyReader() (io.Reader, error) {
 	return tx.requestBodyBuffer.Reader()
 }
 
+// OperatorValue returns the value an operator stored for the transaction
+// with SetOperatorValue, e.g. the request body it decoded. The values are
+// released when the transaction is closed.
+func (tx *Transaction) OperatorValue(key any) (any, bool) {
+	v, ok := tx.operatorValues[key]
+	return v, ok
+}
+
+// SetOperatorValue stores a value computed by an operator for the
+// transaction, the key is defined by the operator like a context key
+func (tx *Transaction) SetOperatorValue(key any, value any) {
+	if tx.operatorValues == nil {
+		tx.operatorValues = map[any]any{}
+	}
+	tx.operatorValues[key] = value
+}
+
+// SharedOperatorValue returns the value shared by the operators of the WAF
+// for the key, created with create by the first of them, e.g. the cache of
+// the lookups of a DNS zone. The values are dropped when the WAF is updated
+// with Apply, as the rules using them may change.
+func (tx *Transaction) SharedOperatorValue(key any, create func() any) any {
+	return tx.WAF.operatorValues.get(key, create)
+}
+
 // AddRequestHeader Adds a request header
 //
 // With this method it is possible to feed Coraza with a request header.
@@ -336,6 +465,10 @@ func (tx *Transaction) AddRequestHeader(key string, value string) {
 	if key == "" {
 		return
 	}
+	if !tx.reserveMemory(len(key) + len(value)) {
+		tx.debugLogger.Warn().Msg("skipping request header, over the memory limit")
+		return
+	}
 	keyl := strings.ToLower(key)
 	tx.variables.requestHeaders.Add(key, value)
 
@@ -378,13 +511,20 @@ func (tx *Transaction) AddResponseHeader(key string, value string) {
 	if key == "" {
 		return
 	}
+	if !tx.reserveMemory(len(key) + len(value)) {
+		tx.debugLogger.Warn().Msg("skipping response header, over the memory limit")
+		return
+	}
 	keyl := strings.ToLower(key)
 	tx.variables.responseHeaders.Add(key, value)
 
 	// Most headers can be managed like that
-	if keyl == "content-type" {
+	switch keyl {
+	case "content-type":
 		name, _, _ := strings.Cut(value, ";")
 		tx.variables.responseContentType.Set(name)
+	case "set-cookie":
+		tx.addResponseCookie(value)
 	}
 }
 
@@ -396,26 +536,90 @@ func (tx *Transaction) Capturing() bool {
 // that supports capture, like @rx
 func (tx *Transaction) CaptureField(index int, value string) {
 	if tx.Capture {
+		if index >= tx.CaptureLimit() {
+			return
+		}
 		tx.debugLogger.Debug().
 			Int("field", index).
 			Str("value", value).
 			Msg("Capturing field")
 		i := strconv.Itoa(index)
 		tx.variables.tx.SetIndex(i, 0, value)
+		tx.captureCount = index + 1
+	}
+}
+
+// initCaptureCount creates CAPTURE_COUNT, it is derived from the fields
+// captured by the last operator when it is read
+func (tx *Transaction) initCaptureCount() {
+	tx.variables.captureCount = collections.NewLazySingle(corazatypes.CaptureCount, func() string {
+		return strconv.Itoa(tx.captureCount)
+	})
+}
+
+// CaptureNamedField sets TX:<name> to the value captured by a named group of
+// an operator, e.g. (?P<user>\w+) of @rx. Unlike the numbered fields, named
+// fields are not bounded by the capture limit and are not reset after the
+// rule, like variables set with setvar.
+func (tx *Transaction) CaptureNamedField(name, value string) {
+	if !tx.Capture {
+		return
+	}
+	tx.debugLogger.Debug().
+		Str("field", name).
+		Str("value", value).
+		Msg("Capturing named field")
+	tx.variables.tx.Set(name, []string{value})
+	if tx.namedCaptures == nil {
+		tx.namedCaptures = map[string]bool{}
 	}
+	tx.namedCaptures[strings.ToLower(name)] = true
+}
+
+// CaptureLimit returns the maximum number of fields captured by an operator,
+// captures with an index over the limit are ignored by CaptureField
+func (tx *Transaction) CaptureLimit() int {
+	if tx.WAF.CaptureLimit <= 0 {
+		return corazarules.DefaultCaptureLimit
+	}
+	return tx.WAF.CaptureLimit
+}
+
+// LocalizedMessage returns the message of the catalog for the rule, or msg if
+// the rule is not in the catalog. It can be used to present the message of the
+// rule that interrupted the transaction, e.g. in block pages.
+func (tx *Transaction) LocalizedMessage(ruleID int, msg string) string {
+	return tx.WAF.MessageCatalog.message(tx, ruleID, msg)
+}
+
+// GeoDatabase returns the database used by the @geoLookup operator, it is
+// nil if no database was configured
+func (tx *Transaction) GeoDatabase() plugintypes.GeoDatabase {
+	return tx.WAF.GeoDatabase
+}
+
+// URLReputation returns the provider used by the @gsbLookup operator, it is
+// nil if no provider was configured
+func (tx *Transaction) URLReputation() plugintypes.URLReputation {
+	return tx.WAF.URLReputation
+}
+
+// PersistentStore returns the store of the persistent collections of the WAF,
+// the @rbl operator records there the results of its asynchronous lookups
+func (tx *Transaction) PersistentStore() *collections.PersistentStore {
+	return tx.WAF.PersistentCollections
 }
 
 // this function is used to control which variables are reset after a new rule is evaluated
 func (tx *Transaction) resetCaptures() {
 	tx.debugLogger.Debug().
 		Msg("Reseting captured variables")
-	// We reset capture 0-9
+	// We reset captures from 0 to the capture limit
 	ctx := tx.variables.tx
-	// RUNE 48 = 0
-	// RUNE 57 = 9
-	for i := rune(48); i <= 57; i++ {
-		ctx.SetIndex(string(i), 0, "")
+	for i := 0; i < tx.CaptureLimit(); i++ {
+		ctx.SetIndex(strconv.Itoa(i), 0, "")
 	}
+	tx.captureCount = 0
 }
 
 // ParseRequestReader Parses binary request including body,
@@ -452,11 +656,8 @@ func (tx *Transaction) ParseRequestReader(data io.Reader) (*types.Interruption,
 	if it := tx.ProcessRequestHeaders(); it != nil {
 		return it, nil
 	}
-	ctcol := tx.variables.requestHeaders.Get("content-type")
-	ct := ""
-	if len(ctcol) > 0 {
-		ct, _, _ = strings.Cut(ctcol[0], ";")
-	}
+	ct, _ := tx.variables.requestHeaders.GetFirst("content-type")
+	ct, _, _ = strings.Cut(ct, ";")
 	for scanner.Scan() {
 		it, _, err := tx.WriteRequestBody(scanner.Bytes())
 		if err != nil {
@@ -487,9 +688,9 @@ func (tx *Transaction) ParseRequestReader(data io.Reader) (*types.Interruption,
 func (tx *Transaction) matchVariable(match *corazarules.MatchData) {
 	var varName string
 	if match.Key_ != "" {
-		varName = match.Variable().Name() + ":" + match.Key_
+		varName = corazatypes.VariableName(match.Variable()) + ":" + match.Key_
 	} else {
-		varName = match.Variable().Name()
+		varName = corazatypes.VariableName(match.Variable())
 	}
 	// Array of values
 	matchedVars := tx.variables.matchedVars
@@ -503,6 +704,22 @@ func (tx *Transaction) matchVariable(match *corazarules.MatchData) {
 	matchedVarName.Set(varName)
 }
 
+// appendMatchData appends md to mds unless the rule match limit is reached,
+// in that case the match data is dropped and the rule is flagged as truncated.
+// Actions are still evaluated for dropped matches.
+func (tx *Transaction) appendMatchData(mds []types.MatchData, md types.MatchData) []types.MatchData {
+	if limit := tx.WAF.RuleMatchLimit; limit > 0 && len(mds) >= limit {
+		if !tx.matchesTruncated {
+			tx.debugLogger.Warn().
+				Int("limit", limit).
+				Msg("Rule match limit reached, dropping match data")
+		}
+		tx.matchesTruncated = true
+		return mds
+	}
+	return append(mds, md)
+}
+
 // MatchRule Matches a rule to be logged
 func (tx *Transaction) MatchRule(r *Rule, mds []types.MatchData) {
 	tx.debugLogger.Debug().Int("rule_id", r.ID_).Msg("Rule matched")
@@ -528,6 +745,7 @@ func (tx *Transaction) MatchRule(r *Rule, mds []types.MatchData) {
 		Rule_:            &r.RuleMetadata,
 		Log_:             r.Log,
 		MatchedDatas_:    mds,
+		Truncated_:       tx.matchesTruncated,
 		Context_:         tx.context,
 	}
 	// Populate MatchedRule disruption related fields only if the Engine is capable of performing disruptive actions
@@ -556,9 +774,15 @@ func (tx *Transaction) MatchRule(r *Rule, mds []types.MatchData) {
 	}
 
 	tx.matchedRules = append(tx.matchedRules, mr)
+	for _, md := range mds {
+		tx.memory.Add(len(md.Key()) + len(md.Value()) + len(md.Message()) + len(md.Data()))
+	}
 	if tx.WAF.ErrorLogCb != nil && r.Log {
 		tx.WAF.ErrorLogCb(mr)
 	}
+	if tx.WAF.MatchedLogger != nil && r.Log {
+		tx.WAF.MatchedLogger.LogMatched(mr.ModSecurityErrorLog(), mr)
+	}
 
 }
 
@@ -593,14 +817,14 @@ func (tx *Transaction) GetField(rv ruleVariableParams) []types.MatchData {
 			matches = m.FindRegex(rv.KeyRx)
 		} else {
 			// This should probably never happen, selectability is checked at parsing time
-			tx.debugLogger.Error().Str("collection", rv.Variable.Name()).Msg("attempted to use regex with non-selectable collection")
+			tx.debugLogger.Error().Str("collection", corazatypes.VariableName(rv.Variable)).Msg("attempted to use regex with non-selectable collection")
 		}
 	case rv.KeyStr != "":
 		if m, ok := col.(collection.Keyed); ok {
 			matches = m.FindString(rv.KeyStr)
 		} else {
 			// This should probably never happen, selectability is checked at parsing time
-			tx.debugLogger.Error().Str("collection", rv.Variable.Name()).Msg("attempted to use string with non-selectable collection")
+			tx.debugLogger.Error().Str("collection", corazatypes.VariableName(rv.Variable)).Msg("attempted to use string with non-selectable collection")
 		}
 	default:
 		matches = col.FindAll()
@@ -695,7 +919,12 @@ func (tx *Transaction) ProcessConnection(client string, cPort int, server string
 
 // ExtractGetArguments transforms an url encoded string to a map and creates ARGS_GET
 func (tx *Transaction) ExtractGetArguments(uri string) {
-	data := urlutil.ParseQuery(uri, '&')
+	var data map[string][]string
+	if tx.WAF.FlattenArrayArguments {
+		data = urlutil.ParseQueryFlatten(uri, '&')
+	} else {
+		data = urlutil.ParseQuery(uri, '&')
+	}
 	for k, vs := range data {
 		for _, v := range vs {
 			tx.AddGetRequestArgument(k, v)
@@ -709,6 +938,10 @@ func (tx *Transaction) AddGetRequestArgument(key string, value string) {
 		tx.debugLogger.Warn().Msg("skipping get request argument, over limit")
 		return
 	}
+	if !tx.reserveMemory(len(key) + len(value)) {
+		tx.debugLogger.Warn().Msg("skipping get request argument, over the memory limit")
+		return
+	}
 	tx.variables.argsGet.Add(key, value)
 }
 
@@ -718,6 +951,10 @@ func (tx *Transaction) AddPostRequestArgument(key string, value string) {
 		tx.debugLogger.Warn().Msg("skipping post request argument, over limit")
 		return
 	}
+	if !tx.reserveMemory(len(key) + len(value)) {
+		tx.debugLogger.Warn().Msg("skipping post request argument, over the memory limit")
+		return
+	}
 	tx.variables.argsPost.Add(key, value)
 }
 
@@ -727,6 +964,10 @@ func (tx *Transaction) AddPathRequestArgument(key string, value string) {
 		tx.debugLogger.Warn().Msg("skipping path request argument, over limit")
 		return
 	}
+	if !tx.reserveMemory(len(key) + len(value)) {
+		tx.debugLogger.Warn().Msg("skipping path request argument, over the memory limit")
+		return
+	}
 	tx.variables.argsPath.Add(key, value)
 }
 
@@ -740,6 +981,10 @@ func (tx *Transaction) AddResponseArgument(key string, value string) {
 		tx.debugLogger.Warn().Msg("skipping response argument, over limit")
 		return
 	}
+	if !tx.reserveMemory(len(key) + len(value)) {
+		tx.debugLogger.Warn().Msg("skipping response argument, over the memory limit")
+		return
+	}
 	tx.variables.responseArgs.Add(key, value)
 }
 
@@ -835,6 +1080,9 @@ func (tx *Transaction) ProcessRequestHeaders() *types.Interruption {
 		return tx.interruption
 	}
 
+	tx.resetNormalizedURL()
+	tx.setContentTypeAnomalies()
+	tx.checkBan()
 	tx.WAF.Rules.Eval(types.PhaseRequestHeaders, tx)
 	return tx.interruption
 }
@@ -1019,15 +1267,18 @@ func (tx *Transaction) ProcessRequestBody() (*types.Interruption, error) {
 		return nil, nil
 	}
 
-	// we won't process empty request bodies or disabled RequestBodyAccess
-	if !tx.RequestBodyAccess || tx.requestBodyBuffer.length == 0 {
+	// we won't process empty request bodies, disabled RequestBodyAccess or
+	// the ignored bodies of methods that don't define one
+	if !tx.checkRequestBody() || !tx.RequestBodyAccess || tx.requestBodyBuffer.length == 0 {
 		tx.WAF.Rules.Eval(types.PhaseRequestBody, tx)
 		return tx.interruption, nil
 	}
-	mime := ""
-	if m := tx.variables.requestHeaders.Get("content-type"); len(m) > 0 {
-		mime = m[0]
+	if tx.WAF.StreamInBodyInspection {
+		if err := setStreamBody(tx.requestBodyBuffer, tx.variables.streamInputBody); err != nil {
+			return nil, err
+		}
 	}
+	mime, _ := tx.variables.requestHeaders.GetFirst("content-type")
 
 	reader, err := tx.requestBodyBuffer.Reader()
 	if err != nil {
@@ -1063,8 +1314,12 @@ func (tx *Transaction) ProcessRequestBody() (*types.Interruption, error) {
 		Msg("Attempting to process request body")
 
 	if err := bodyprocessor.ProcessRequest(reader, tx.Variables(), plugintypes.BodyProcessorOptions{
-		Mime:        mime,
-		StoragePath: tx.WAF.UploadDir,
+		Mime:                  mime,
+		StoragePath:           tx.WAF.uploadDir(),
+		FileMode:              tx.WAF.UploadFileMode,
+		FileLimit:             tx.WAF.UploadFileLimit,
+		XMLEntityResolver:     tx.WAF.xmlEntityResolver(),
+		FlattenArrayArguments: tx.WAF.FlattenArrayArguments,
 	}); err != nil {
 		tx.debugLogger.Error().Err(err).Msg("Failed to process request body")
 		tx.generateRequestBodyError(err)
@@ -1102,6 +1357,7 @@ func (tx *Transaction) ProcessResponseHeaders(code int, proto string) *types.Int
 	tx.variables.responseStatus.Set(c)
 	tx.variables.responseProtocol.Set(proto)
 
+	tx.evaluateSecurityHeaders()
 	tx.WAF.Rules.Eval(types.PhaseResponseHeaders, tx)
 	return tx.interruption
 }
@@ -1280,6 +1536,12 @@ func (tx *Transaction) ProcessResponseBody() (*types.Interruption, error) {
 		return tx.interruption, nil
 	}
 
+	if tx.WAF.StreamOutBodyInspection {
+		if err := setStreamBody(tx.responseBodyBuffer, tx.variables.streamOutputBody); err != nil {
+			return tx.interruption, err
+		}
+	}
+
 	reader, err := tx.responseBodyBuffer.Reader()
 	if err != nil {
 		return tx.interruption, err
@@ -1309,6 +1571,11 @@ func (tx *Transaction) ProcessResponseBody() (*types.Interruption, error) {
 		tx.variables.responseBody.Set(buf.String())
 	}
 	tx.WAF.Rules.Eval(types.PhaseResponseBody, tx)
+	if tx.interruption == nil {
+		if err := tx.hashResponseBody(); err != nil {
+			tx.debugLogger.Error().Err(err).Msg("Failed to sign response body links")
+		}
+	}
 	return tx.interruption, nil
 }
 
@@ -1323,6 +1590,8 @@ func (tx *Transaction) ProcessLogging() {
 		tx.WAF.Rules.Eval(types.PhaseLogging, tx)
 	}
 
+	tx.writeGuardianLog()
+
 	if tx.AuditEngine == types.AuditEngineOff {
 		// Audit engine disabled
 		tx.debugLogger.Debug().
@@ -1377,6 +1646,76 @@ func (tx *Transaction) IsResponseBodyAccessible() bool {
 	return tx.ResponseBodyAccess
 }
 
+// SetRequestBodyAccess enables or disables the request body access for this
+// transaction only, overriding SecRequestBodyAccess. Connectors can use it to
+// skip the body handling of allow-listed endpoints. It returns an error once
+// the request headers phase is over.
+func (tx *Transaction) SetRequestBodyAccess(enabled bool) error {
+	if tx.lastPhase > types.PhaseRequestHeaders {
+		return errors.New("cannot change request body access after request headers phase")
+	}
+	tx.RequestBodyAccess = enabled
+	return nil
+}
+
+// SetResponseBodyAccess enables or disables the response body access for this
+// transaction only, overriding SecResponseBodyAccess. It returns an error once
+// the response headers phase is over.
+func (tx *Transaction) SetResponseBodyAccess(enabled bool) error {
+	if tx.lastPhase > types.PhaseResponseHeaders {
+		return errors.New("cannot change response body access after response headers phase")
+	}
+	tx.ResponseBodyAccess = enabled
+	return nil
+}
+
+// SetRequestBodyLimit overrides SecRequestBodyLimit for this transaction only,
+// so endpoints identified in phase 1 can accept larger bodies. The limit is
+// capped to SecRequestBodyLimitMax. It returns an error once the request
+// headers phase is over or if the limit is not positive.
+func (tx *Transaction) SetRequestBodyLimit(limit int64) error {
+	if tx.lastPhase > types.PhaseRequestHeaders {
+		return errors.New("cannot change request body limit after request headers phase")
+	}
+	if limit <= 0 {
+		return errors.New("request body limit should be bigger than 0")
+	}
+	if max := tx.WAF.requestBodyLimitMax(); limit > max {
+		tx.debugLogger.Warn().
+			Str("limit", strconv.FormatInt(limit, 10)).
+			Str("max", strconv.FormatInt(max, 10)).
+			Msg("Request body limit capped to the maximum")
+		limit = max
+	}
+	tx.RequestBodyLimit = limit
+	tx.requestBodyBuffer.options.Limit = limit
+	return nil
+}
+
+// SetResponseBodyLimit overrides SecResponseBodyLimit for this transaction only.
+// The limit is capped to SecResponseBodyLimitMax. It returns an error once the
+// response headers phase is over or if the limit is not positive.
+func (tx *Transaction) SetResponseBodyLimit(limit int64) error {
+	if tx.lastPhase > types.PhaseResponseHeaders {
+		return errors.New("cannot change response body limit after response headers phase")
+	}
+	if limit <= 0 {
+		return errors.New("response body limit should be bigger than 0")
+	}
+	if max := tx.WAF.responseBodyLimitMax(); limit > max {
+		tx.debugLogger.Warn().
+			Str("limit", strconv.FormatInt(limit, 10)).
+			Str("max", strconv.FormatInt(max, 10)).
+			Msg("Response body limit capped to the maximum")
+		limit = max
+	}
+	tx.ResponseBodyLimit = limit
+	// the response body is only buffered in memory
+	tx.responseBodyBuffer.options.Limit = limit
+	tx.responseBodyBuffer.options.MemoryLimit = limit
+	return nil
+}
+
 // IsInterrupted will return true if the transaction was interrupted
 func (tx *Transaction) IsInterrupted() bool {
 	return tx.interruption != nil
@@ -1491,12 +1830,17 @@ func (tx *Transaction) AuditLog() *auditlog.Log {
 		case types.AuditLogPartAuditLogTrailer:
 			auditLogPartAuditLogTrailerSet = true
 			al.Transaction_.Producer_ = &auditlog.TransactionProducer{
-				Connector_:  tx.WAF.ProducerConnector,
-				Version_:    tx.WAF.ProducerConnectorVersion,
-				Server_:     "",
-				RuleEngine_: tx.RuleEngine.String(),
-				Stopwatch_:  tx.GetStopWatch(),
-				Rulesets_:   tx.WAF.ComponentNames,
+				Connector_:     tx.WAF.Producer.Connector,
+				Version_:       tx.WAF.Producer.ConnectorVersion,
+				EngineName_:    EngineName(),
+				EngineVersion_: EngineVersion(),
+				Server_:        tx.WAF.ServerSignature,
+				RuleEngine_:    tx.RuleEngine.String(),
+				Stopwatch_:     tx.GetStopWatch(),
+				Rulesets_:      tx.WAF.Producer.RulesetNames(),
+				Labels_:        tx.WAF.labels,
+
+				RulesPerformanceInfo_: tx.rulesPerformanceInfo(),
 			}
 		case types.AuditLogPartRulesMatched:
 			auditLogPartRulesMatchedSet = true
@@ -1508,22 +1852,24 @@ func (tx *Transaction) AuditLog() *auditlog.Log {
 				if ok && mrWithlog.Log() {
 					r := mr.Rule()
 					for _, matchData := range mr.MatchedDatas() {
+						msg := tx.LocalizedMessage(r.ID(), matchData.Message())
 						newAlEntry := auditlog.Message{
-							Actionset_: strings.Join(tx.WAF.ComponentNames, " "),
-							Message_:   matchData.Message(),
+							Actionset_: strings.Join(tx.WAF.Producer.RulesetNames(), " "),
+							Message_:   msg,
 							Data_: &auditlog.MessageData{
-								File_:     mr.Rule().File(),
-								Line_:     mr.Rule().Line(),
-								ID_:       r.ID(),
-								Rev_:      r.Revision(),
-								Msg_:      matchData.Message(),
-								Data_:     matchData.Data(),
-								Severity_: r.Severity(),
-								Ver_:      r.Version(),
-								Maturity_: r.Maturity(),
-								Accuracy_: r.Accuracy(),
-								Tags_:     r.Tags(),
-								Raw_:      r.Raw(),
+								File_:      mr.Rule().File(),
+								Line_:      mr.Rule().Line(),
+								ID_:        r.ID(),
+								Rev_:       r.Revision(),
+								Msg_:       msg,
+								Data_:      matchData.Data(),
+								Severity_:  r.Severity(),
+								Ver_:       r.Version(),
+								Maturity_:  r.Maturity(),
+								Accuracy_:  r.Accuracy(),
+								Tags_:      r.Tags(),
+								Raw_:       r.Raw(),
+								Truncated_: mrWithlog.Truncated(),
 							},
 						}
 						// If AuditLogPartAuditLogTrailer (H) is set, we expect to log the error messages emitted by the rules
@@ -1555,14 +1901,44 @@ func (tx *Transaction) AuditLog() *auditlog.Log {
 	return al
 }
 
+// AuditEntry returns the audit log entry of the transaction as ProcessLogging
+// would write it with the configured parts, regardless of the audit engine.
+// It can be called at any phase, e.g. by connectors attaching it to their own
+// logs or error responses, and the entry remains valid once the transaction
+// is closed.
+func (tx *Transaction) AuditEntry() plugintypes.AuditLog {
+	al := tx.AuditLog()
+	// ARGS is a view of the transaction collections, which are reset when
+	// the transaction is closed, so the arguments are copied
+	args := collections.NewMap(variables.Args)
+	for _, md := range tx.variables.args.FindAll() {
+		args.Add(md.Key(), md.Value())
+	}
+	al.Transaction_.Request_.Args_ = collections.NewConcatKeyed(variables.Args, args)
+	return al
+}
+
 // Close closes the transaction after phase 5
 // This method helps the GC to clean up the transaction faster and release resources
 // It also allows caches the transaction back into the sync.Pool
 func (tx *Transaction) Close() error {
+	tx.stopWatchdog()
 	defer tx.WAF.txPool.Put(tx)
+	return tx.release()
+}
+
+// release removes the temporary files of the transaction and resets its
+// variables and buffers so it can be reused
+func (tx *Transaction) release() error {
+	if tx.idTracked {
+		tx.WAF.inflightTransactionIDs.remove(tx.id)
+		tx.idTracked = false
+	}
+
+	tx.persistCollections()
 
 	var errs []error
-	if environment.HasAccessToFS {
+	if environment.HasAccessToFS && !tx.WAF.UploadKeepFiles {
 		// TODO(jcchavezs): filesTmpNames should probably be a new kind of collection that
 		// is aware of the files and then attempt to delete them when the collection
 		// is resetted or an item is removed.
@@ -1574,6 +1950,10 @@ func (tx *Transaction) Close() error {
 	}
 
 	tx.variables.reset()
+	clear(tx.operatorValues)
+	// the collections are empty, only the matched data is still accounted
+	tx.memory.Reset()
+	tx.memoryLimitExceeded = false
 	if err := tx.requestBodyBuffer.Reset(); err != nil {
 		errs = append(errs, fmt.Errorf("reseting request body buffer: %v", err))
 	}
@@ -1592,6 +1972,7 @@ func (tx *Transaction) Close() error {
 			Bool("is_interrupted", false).
 			Msg("Transaction finished")
 	}
+	tx.WAF.transactionClosed()
 
 	if len(errs) == 0 {
 		return nil
@@ -1655,91 +2036,121 @@ func (tx *Transaction) setTimeVariables() {
 
 // TransactionVariables has pointers to all the variables of the transaction
 type TransactionVariables struct {
-	args                     *collections.ConcatKeyed
-	argsCombinedSize         *collections.SizeCollection
-	argsGet                  *collections.NamedCollection
-	argsGetNames             collection.Keyed
-	argsNames                *collections.ConcatKeyed
-	argsPath                 *collections.NamedCollection
-	argsPost                 *collections.NamedCollection
-	argsPostNames            collection.Keyed
-	duration                 *collections.Single
-	env                      *collections.Map
-	files                    *collections.Map
-	filesCombinedSize        *collections.Single
-	filesNames               *collections.Map
-	filesSizes               *collections.Map
-	filesTmpContent          *collections.Map
-	filesTmpNames            *collections.Map
-	fullRequestLength        *collections.Single
-	geo                      *collections.Map
-	highestSeverity          *collections.Single
-	inboundDataError         *collections.Single
-	matchedVar               *collections.Single
-	matchedVarName           *collections.Single
-	matchedVars              *collections.NamedCollection
-	matchedVarsNames         collection.Keyed
-	multipartDataAfter       *collections.Single
-	multipartFilename        *collections.Map
-	multipartName            *collections.Map
-	multipartPartHeaders     *collections.Map
-	multipartStrictError     *collections.Single
-	outboundDataError        *collections.Single
-	queryString              *collections.Single
-	remoteAddr               *collections.Single
-	remoteHost               *collections.Single
-	remotePort               *collections.Single
-	reqbodyError             *collections.Single
-	reqbodyErrorMsg          *collections.Single
-	reqbodyProcessor         *collections.Single
-	reqbodyProcessorError    *collections.Single
-	reqbodyProcessorErrorMsg *collections.Single
-	requestBasename          *collections.Single
-	requestBody              *collections.Single
-	requestBodyLength        *collections.Single
-	requestCookies           *collections.NamedCollection
-	requestCookiesNames      collection.Keyed
-	requestFilename          *collections.Single
-	requestHeaders           *collections.NamedCollection
-	requestHeadersNames      collection.Keyed
-	requestLine              *collections.Single
-	requestMethod            *collections.Single
-	requestProtocol          *collections.Single
-	requestURI               *collections.Single
-	requestURIRaw            *collections.Single
-	requestXML               *collections.Map
-	responseBody             *collections.Single
-	responseContentLength    *collections.Single
-	responseContentType      *collections.Single
-	responseHeaders          *collections.NamedCollection
-	responseHeadersNames     collection.Keyed
-	responseProtocol         *collections.Single
-	responseStatus           *collections.Single
-	responseXML              *collections.Map
-	responseArgs             *collections.Map
-	resBodyProcessor         *collections.Single
-	rule                     *collections.Map
-	serverAddr               *collections.Single
-	serverName               *collections.Single
-	serverPort               *collections.Single
-	statusLine               *collections.Single
-	tx                       *collections.Map
-	uniqueID                 *collections.Single
-	urlencodedError          *collections.Single
-	xml                      *collections.Map
-	resBodyError             *collections.Single
-	resBodyErrorMsg          *collections.Single
-	resBodyProcessorError    *collections.Single
-	resBodyProcessorErrorMsg *collections.Single
-	time                     *collections.Single
-	timeDay                  *collections.Single
-	timeEpoch                *collections.Single
-	timeHour                 *collections.Single
-	timeMin                  *collections.Single
-	timeMon                  *collections.Single
-	timeSec                  *collections.Single
-	timeWday                 *collections.Single
-	timeYear                 *collections.Single
+	args                       *collections.ConcatKeyed
+	argsCombinedSize           *collections.SizeCollection
+	argsGet                    *collections.NamedCollection
+	argsGetNames               collection.Keyed
+	argsNames                  *collections.ConcatKeyed
+	argsPath                   *collections.NamedCollection
+	argsPost                   *collections.NamedCollection
+	argsPostNames              collection.Keyed
+	duration                   *collections.Single
+	env                        *collections.Map
+	files                      *collections.Map
+	filesCombinedSize          *collections.Single
+	filesNames                 *collections.Map
+	filesSizes                 *collections.Map
+	filesTmpContent            *collections.Map
+	filesTmpNames              *collections.Map
+	fullRequestLength          *collections.Single
+	geo                        *collections.Map
+	highestSeverity            *collections.Single
+	inboundDataError           *collections.Single
+	matchedVar                 *collections.Single
+	matchedVarName             *collections.Single
+	matchedVars                *collections.NamedCollection
+	matchedVarsNames           collection.Keyed
+	multipartDataAfter         *collections.Single
+	multipartFilename          *collections.Map
+	multipartName              *collections.Map
+	multipartPartHeaders       *collections.Map
+	multipartStrictError       *collections.Single
+	outboundDataError          *collections.Single
+	queryString                *collections.Single
+	remoteAddr                 *collections.Single
+	remoteHost                 *collections.Single
+	remotePort                 *collections.Single
+	reqbodyError               *collections.Single
+	reqbodyErrorMsg            *collections.Single
+	reqbodyProcessor           *collections.Single
+	reqbodyProcessorError      *collections.Single
+	reqbodyProcessorErrorMsg   *collections.Single
+	requestBasename            *collections.Single
+	requestBody                *collections.Single
+	requestBodyLength          *collections.Single
+	requestCookies             *collections.NamedCollection
+	requestCookiesNames        collection.Keyed
+	requestFilename            *collections.Single
+	requestHeaders             *collections.NamedCollection
+	requestHeadersNames        collection.Keyed
+	requestLine                *collections.Single
+	requestMethod              *collections.Single
+	requestProtocol            *collections.Single
+	requestURI                 *collections.Single
+	requestURIRaw              *collections.Single
+	requestXML                 *collections.Map
+	responseBody               *collections.Single
+	responseContentLength      *collections.Single
+	responseContentType        *collections.Single
+	responseHeaders            *collections.NamedCollection
+	responseHeadersNames       collection.Keyed
+	responseProtocol           *collections.Single
+	responseStatus             *collections.Single
+	responseXML                *collections.Map
+	responseArgs               *collections.Map
+	resBodyProcessor           *collections.Single
+	rule                       *collections.Map
+	serverAddr                 *collections.Single
+	serverName                 *collections.Single
+	serverPort                 *collections.Single
+	statusLine                 *collections.Single
+	streamInputBody            *collections.Single
+	streamOutputBody           *collections.Single
+	responseCookies            *collections.Map
+	responseCookiesAttrs       responseCookiesAttrs
+	contentTypeAnomalies       *collections.Map
+	remoteScore                *collections.Single
+	rxBudgetExceeded           *collections.Single
+	securityHeaders            *collections.Map
+	xmlExternalEntity          *collections.Single
+	multipartOriginalValues    *collections.Map
+	multipartCharsetError      *collections.Single
+	multipartFileLimitExceeded *collections.Single
+	yaraMatches                *collections.Map
+	perfCombined               *collections.LazySingle
+	perfPhases                 [types.PhaseLogging]*collections.LazySingle
+	perfRules                  *collections.LazyMap
+	memoryUsage                *collections.LazySingle
+	memoryLimitExceeded        *collections.LazySingle
+	captureCount               *collections.LazySingle
+	requestURLNormalized       *collections.LazySingle
+	requestPathSegments        *collections.LazyMap
+	global                     *collections.Map
+	ip                         *collections.Map
+	resource                   *collections.Map
+	session                    *collections.Map
+	user                       *collections.Map
+	tx                         *collections.Map
+	uniqueID                   *collections.Single
+	urlencodedError            *collections.Single
+	xml                        *collections.Map
+	resBodyError               *collections.Single
+	resBodyErrorMsg            *collections.Single
+	resBodyProcessorError      *collections.Single
+	resBodyProcessorErrorMsg   *collections.Single
+	time                       *collections.Single
+	timeDay                    *collections.Single
+	timeEpoch                  *collections.Single
+	timeHour                   *collections.Single
+	timeMin                    *collections.Single
+	timeMon                    *collections.Single
+	timeSec                    *collections.Single
+	timeWday                   *collections.Single
+	timeYear                   *collections.Single
+
+	// requestXMLDocument is the request body decoded by the XML body
+	// processor, validated by the rules against XML Schemas
+	requestXMLDocument *xmlschema.Document
 }
 
 func NewTransactionVariables() *TransactionVariables {
@@ -1782,6 +2193,24 @@ func NewTransactionVariables() *TransactionVariables {
 	v.serverPort = collections.NewSingle(variables.ServerPort)
 	v.highestSeverity = collections.NewSingle(variables.HighestSeverity)
 	v.statusLine = collections.NewSingle(variables.StatusLine)
+	v.streamInputBody = collections.NewSingle(corazatypes.StreamInputBody)
+	v.streamOutputBody = collections.NewSingle(corazatypes.StreamOutputBody)
+	v.responseCookies = collections.NewMap(corazatypes.ResponseCookies)
+	v.responseCookiesAttrs = responseCookiesAttrs{collections.NewMap(corazatypes.ResponseCookiesAttrs)}
+	v.contentTypeAnomalies = collections.NewMap(corazatypes.RequestContentTypeAnomalies)
+	v.remoteScore = collections.NewSingle(corazatypes.RemoteScore)
+	v.rxBudgetExceeded = collections.NewSingle(corazatypes.RxBudgetExceeded)
+	v.securityHeaders = collections.NewMap(corazatypes.SecurityHeaders)
+	v.xmlExternalEntity = collections.NewSingle(corazatypes.XMLExternalEntity)
+	v.multipartOriginalValues = collections.NewMap(corazatypes.MultipartOriginalValues)
+	v.multipartCharsetError = collections.NewSingle(corazatypes.MultipartCharsetError)
+	v.multipartFileLimitExceeded = collections.NewSingle(corazatypes.MultipartFileLimitExceeded)
+	v.yaraMatches = collections.NewMap(corazatypes.YaraMatches)
+	v.global = collections.NewMap(corazatypes.Global)
+	v.ip = collections.NewMap(corazatypes.IP)
+	v.resource = collections.NewMap(corazatypes.Resource)
+	v.session = collections.NewMap(corazatypes.Session)
+	v.user = collections.NewMap(corazatypes.User)
 	v.duration = collections.NewSingle(variables.Duration)
 	v.resBodyError = collections.NewSingle(variables.ResBodyError)
 	v.resBodyErrorMsg = collections.NewSingle(variables.ResBodyErrorMsg)
@@ -2118,6 +2547,24 @@ func (v *TransactionVariables) RequestXML() collection.Map {
 	return v.requestXML
 }
 
+// RequestXMLDocument returns the request body decoded by the XML body
+// processor, nil if the body wasn't processed as XML
+func (v *TransactionVariables) RequestXMLDocument() *xmlschema.Document {
+	return v.requestXMLDocument
+}
+
+// SetRequestXMLDocument keeps the request body decoded by the XML body
+// processor
+func (v *TransactionVariables) SetRequestXMLDocument(doc *xmlschema.Document) {
+	v.requestXMLDocument = doc
+}
+
+// XMLExternalEntity returns XML_EXTERNAL_ENTITY, set by the XML body
+// processor
+func (v *TransactionVariables) XMLExternalEntity() collection.Single {
+	return v.xmlExternalEntity
+}
+
 func (v *TransactionVariables) ResponseXML() collection.Map {
 	return v.responseXML
 }
@@ -2158,6 +2605,24 @@ func (v *TransactionVariables) MultipartStrictError() collection.Single {
 	return v.multipartStrictError
 }
 
+// MultipartOriginalValues returns MULTIPART_ORIGINAL_VALUES, set by the
+// multipart body processor
+func (v *TransactionVariables) MultipartOriginalValues() collection.Map {
+	return v.multipartOriginalValues
+}
+
+// MultipartCharsetError returns MULTIPART_CHARSET_ERROR, set by the multipart
+// body processor
+func (v *TransactionVariables) MultipartCharsetError() collection.Single {
+	return v.multipartCharsetError
+}
+
+// MultipartFileLimitExceeded returns MULTIPART_FILE_LIMIT_EXCEEDED, set by the
+// multipart body processor
+func (v *TransactionVariables) MultipartFileLimitExceeded() collection.Single {
+	return v.multipartFileLimitExceeded
+}
+
 // All iterates over the variables. We return both variable and its collection, i.e. key/value, to follow
 // general range iteration in Go which always has a key and value (key is int index for slices). Notably,
 // this is consistent with discussions for custom iterable types in a future language version
@@ -2367,6 +2832,90 @@ func (v *TransactionVariables) All(f func(v variables.RuleVariable, col collecti
 	if !f(variables.StatusLine, v.statusLine) {
 		return
 	}
+	if !f(corazatypes.StreamInputBody, v.streamInputBody) {
+		return
+	}
+	if !f(corazatypes.StreamOutputBody, v.streamOutputBody) {
+		return
+	}
+	if !f(corazatypes.ResponseCookies, v.responseCookies) {
+		return
+	}
+	if !f(corazatypes.ResponseCookiesAttrs, v.responseCookiesAttrs) {
+		return
+	}
+	if !f(corazatypes.RequestContentTypeAnomalies, v.contentTypeAnomalies) {
+		return
+	}
+	if !f(corazatypes.RemoteScore, v.remoteScore) {
+		return
+	}
+	if !f(corazatypes.RxBudgetExceeded, v.rxBudgetExceeded) {
+		return
+	}
+	if !f(corazatypes.SecurityHeaders, v.securityHeaders) {
+		return
+	}
+	if !f(corazatypes.XMLExternalEntity, v.xmlExternalEntity) {
+		return
+	}
+	if !f(corazatypes.MultipartOriginalValues, v.multipartOriginalValues) {
+		return
+	}
+	if !f(corazatypes.MultipartCharsetError, v.multipartCharsetError) {
+		return
+	}
+	if !f(corazatypes.MultipartFileLimitExceeded, v.multipartFileLimitExceeded) {
+		return
+	}
+	if !f(corazatypes.YaraMatches, v.yaraMatches) {
+		return
+	}
+	if !f(corazatypes.Global, v.global) {
+		return
+	}
+	if !f(corazatypes.IP, v.ip) {
+		return
+	}
+	if !f(corazatypes.Resource, v.resource) {
+		return
+	}
+	if !f(corazatypes.Session, v.session) {
+		return
+	}
+	if !f(corazatypes.User, v.user) {
+		return
+	}
+	if v.perfCombined == nil {
+		// the derived variables are only set for the transactions
+		return
+	}
+	if !f(corazatypes.PerfCombined, v.perfCombined) {
+		return
+	}
+	for i, c := range v.perfPhases {
+		if !f(corazatypes.PerfPhase1+variables.RuleVariable(i), c) {
+			return
+		}
+	}
+	if !f(corazatypes.PerfRules, v.perfRules) {
+		return
+	}
+	if !f(corazatypes.MemoryUsage, v.memoryUsage) {
+		return
+	}
+	if !f(corazatypes.MemoryLimitExceeded, v.memoryLimitExceeded) {
+		return
+	}
+	if !f(corazatypes.CaptureCount, v.captureCount) {
+		return
+	}
+	if !f(corazatypes.RequestURLNormalized, v.requestURLNormalized) {
+		return
+	}
+	if !f(corazatypes.RequestPathSegments, v.requestPathSegments) {
+		return
+	}
 	if !f(variables.TX, v.tx) {
 		return
 	}
@@ -2434,4 +2983,5 @@ func (v *TransactionVariables) reset() {
 		}
 		return true
 	})
+	v.requestXMLDocument = nil
 }
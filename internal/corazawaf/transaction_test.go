@@ -7,9 +7,13 @@ import (
 	"bytes"
 	"fmt"
 	"io"
+	"io/fs"
 	"net/http"
+	"os"
+	"path/filepath"
 	"regexp"
 	"runtime/debug"
+	"slices"
 	"strconv"
 	"strings"
 	"testing"
@@ -134,6 +138,108 @@ func TestTxMultipart(t *testing.T) {
 	}
 }
 
+func TestTxMultipartCharsets(t *testing.T) {
+	tx := NewWAF().NewTransaction()
+	body := []string{
+		"--boundary",
+		"Content-Disposition: form-data; name=\"latin\"",
+		"Content-Type: text/plain; charset=ISO-8859-1",
+		"",
+		"caf\xe9",
+		"--boundary",
+		"Content-Disposition: form-data; name=\"latin\"",
+		"Content-Type: text/plain; charset=ISO-8859-1",
+		"",
+		"na\xefve",
+		"--boundary",
+		"Content-Disposition: form-data; name=\"b64\"",
+		"Content-Transfer-Encoding: base64",
+		"",
+		"PHNjcmlwdD4=",
+		"--boundary",
+		"Content-Disposition: form-data; name=\"qp\"",
+		"Content-Transfer-Encoding: quoted-printable",
+		"",
+		"=3Cscript=3E",
+		"--boundary",
+		"Content-Disposition: form-data; name=\"sjis\"",
+		"Content-Type: text/plain; charset=Shift_JIS",
+		"",
+		"abc",
+		"--boundary--",
+	}
+	data := strings.Join(body, "\r\n")
+	headers := []string{
+		"POST / HTTP/1.1",
+		"Host: localhost:8000",
+		"Content-Type: multipart/form-data; boundary=boundary",
+		fmt.Sprintf("Content-Length: %d", len(data)),
+	}
+	data = strings.Join(headers, "\r\n") + "\r\n\r\n" + data + "\r\n"
+	tx.RequestBodyAccess = true
+	tx.RequestBodyLimit = 9999999
+	if _, err := tx.ParseRequestReader(strings.NewReader(data)); err != nil {
+		t.Fatal("Failed to parse multipart request: " + err.Error())
+	}
+	exp := map[string]string{
+		"%{args_post.latin}":                 "caf\u00e9",
+		"%{multipart_original_values.latin}": "caf\xe9",
+		"%{args_post.b64}":                   "<script>",
+		"%{args_post.qp}":                    "<script>",
+		"%{args_post.sjis}":                  "abc",
+		"%{multipart_charset_error}":         "1",
+	}
+
+	validateMacroExpansion(exp, tx, t)
+	// the original values of the repeated fields are all kept
+	if want, have := []string{"caf\xe9", "na\xefve"}, tx.variables.multipartOriginalValues.Get("latin"); !slices.Equal(want, have) {
+		t.Errorf("unexpected MULTIPART_ORIGINAL_VALUES:latin, want %q, have %q", want, have)
+	}
+
+	if err := tx.Close(); err != nil {
+		t.Fatalf("Failed to close transaction: %s", err.Error())
+	}
+}
+
+func TestTxXMLExternalEntity(t *testing.T) {
+	waf := NewWAF()
+	waf.RequestBodyAccess = true
+	tx := waf.NewTransaction()
+	defer tx.Close()
+	tx.AddRequestHeader("Content-Type", "text/xml")
+	tx.variables.reqbodyProcessor.Set("XML")
+	tx.ProcessRequestHeaders()
+	if _, _, err := tx.WriteRequestBody([]byte(`<!DOCTYPE foo [<!ENTITY xxe SYSTEM "file:///etc/passwd">]><foo>&xxe;</foo>`)); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := tx.ProcessRequestBody(); err != nil {
+		t.Fatal(err)
+	}
+	validateMacroExpansion(map[string]string{"%{xml_external_entity}": "1"}, tx, t)
+	if have := tx.variables.tx.Get("xml_external_entity"); len(have) != 0 {
+		t.Errorf("unexpected TX:xml_external_entity %q", have)
+	}
+	if want, have := []string{"&xxe;"}, tx.variables.requestXML.Get("/*"); !slices.Equal(want, have) {
+		t.Errorf("expected the entity not to be resolved, want %q, have %q", want, have)
+	}
+}
+
+func TestTxFlattenArrayArguments(t *testing.T) {
+	waf := NewWAF()
+	waf.FlattenArrayArguments = true
+	tx := waf.NewTransaction()
+	tx.ProcessURI("/?ids[]=1&ids[]=2&user[name]=x", "GET", "HTTP/1.1")
+	exp := map[string]string{
+		"%{args_get.ids[0]}":     "1",
+		"%{args_get.ids[1]}":     "2",
+		"%{args_get.user[name]}": "x",
+	}
+	validateMacroExpansion(exp, tx, t)
+	if err := tx.Close(); err != nil {
+		t.Fatalf("Failed to close transaction: %s", err.Error())
+	}
+}
+
 func TestTxResponse(t *testing.T) {
 	/*
 		tx := NewWAF().NewTransaction()
@@ -397,6 +503,41 @@ func TestWriteRequestBodyIsNopWhenBodyIsNotAccesible(t *testing.T) {
 	}
 }
 
+func TestSetBodyAccess(t *testing.T) {
+	waf := NewWAF()
+	waf.RequestBodyAccess = true
+	waf.ResponseBodyAccess = true
+	tx := waf.NewTransaction()
+	defer tx.Close()
+
+	tx.ProcessRequestHeaders()
+	if err := tx.SetRequestBodyAccess(false); err != nil {
+		t.Fatalf("unexpected error: %s", err.Error())
+	}
+	if _, n, err := tx.WriteRequestBody([]byte("abc")); err != nil || n != 0 {
+		t.Errorf("expected request body to be skipped, have %d bytes written and error %v", n, err)
+	}
+	if _, err := tx.ProcessRequestBody(); err != nil {
+		t.Fatalf("unexpected error: %s", err.Error())
+	}
+	if err := tx.SetRequestBodyAccess(true); err == nil {
+		t.Error("expected error when changing request body access after request headers phase")
+	}
+	if err := tx.SetResponseBodyAccess(false); err != nil {
+		t.Fatalf("unexpected error: %s", err.Error())
+	}
+	if tx.IsResponseBodyAccessible() {
+		t.Error("expected response body access to be disabled")
+	}
+	tx.ProcessResponseHeaders(200, "HTTP/1.1")
+	if _, err := tx.ProcessResponseBody(); err != nil {
+		t.Fatalf("unexpected error: %s", err.Error())
+	}
+	if err := tx.SetResponseBodyAccess(true); err == nil {
+		t.Error("expected error when changing response body access after response headers phase")
+	}
+}
+
 func TestResponseHeader(t *testing.T) {
 	tx := makeTransaction(t)
 	tx.AddResponseHeader("content-type", "test")
@@ -752,6 +893,57 @@ func TestAuditLogFields(t *testing.T) {
 	}
 }
 
+func TestAuditEntry(t *testing.T) {
+	tx := makeTransaction(t)
+	tx.AuditEngine = types.AuditEngineOff
+	tx.AuditLogParts = types.AuditLogParts("ABFHZ")
+	id := tx.id
+
+	al := tx.AuditEntry()
+	// the entry remains valid once the transaction is closed
+	if err := tx.Close(); err != nil {
+		t.Fatalf("Failed to close transaction: %s", err.Error())
+	}
+
+	if want, have := id, al.Transaction().ID(); want != have {
+		t.Errorf("unexpected transaction id, want %q, have %q", want, have)
+	}
+	if want, have := "test456", al.Transaction().Request().Headers()["x-test-header"]; len(have) != 1 || want != have[0] {
+		t.Errorf("unexpected request header, want %q, have %q", want, have)
+	}
+	if want, have := []string{"123"}, al.Transaction().Request().Args().Get("id"); len(have) != 1 || want[0] != have[0] {
+		t.Errorf("unexpected request argument, want %q, have %q", want, have)
+	}
+	if al.Transaction().Producer() == nil {
+		t.Error("expected the trailer part to be set")
+	}
+}
+
+func TestWAFLabels(t *testing.T) {
+	var debugLog bytes.Buffer
+	waf := NewWAF()
+	waf.Logger = debuglog.Default().WithLevel(debuglog.LevelDebug).WithOutput(&debugLog)
+	waf.SetLabel("cluster", "eu-west-1")
+	waf.SetLabel("listener", "public")
+	tx := waf.NewTransaction()
+	tx.AuditLogParts = types.AuditLogParts("ABH")
+	tx.ProcessRequestHeaders()
+
+	p, ok := tx.AuditLog().Transaction().Producer().(plugintypes.AuditLogTransactionProducerLabels)
+	if !ok {
+		t.Fatal("unexpected producer without labels")
+	}
+	if want, have := "public", p.Labels()["listener"]; want != have {
+		t.Errorf("unexpected producer label, want %q, have %q", want, have)
+	}
+	if want, have := `cluster="eu-west-1" listener="public"`, debugLog.String(); !strings.Contains(have, want) {
+		t.Errorf("expected debug log to contain %q, have %q", want, have)
+	}
+	if err := tx.Close(); err != nil {
+		t.Fatalf("Failed to close transaction: %s", err.Error())
+	}
+}
+
 func TestResetCapture(t *testing.T) {
 	tx := makeTransaction(t)
 	tx.Capture = true
@@ -759,15 +951,43 @@ func TestResetCapture(t *testing.T) {
 	if tx.variables.tx.Get("5")[0] != "test" {
 		t.Fatal("failed to set capture field from tx")
 	}
+	if want, have := "6", tx.variables.captureCount.Get(); want != have {
+		t.Fatalf("unexpected capture count, want %q, have %q", want, have)
+	}
 	tx.resetCaptures()
 	if tx.variables.tx.Get("5")[0] != "" {
 		t.Fatal("failed to reset capture field from tx")
 	}
+	if want, have := "0", tx.variables.captureCount.Get(); want != have {
+		t.Fatalf("failed to reset capture count from tx, want %q, have %q", want, have)
+	}
+	// the count is not a TX key
+	if have := tx.variables.tx.Get("capture_count"); len(have) != 0 {
+		t.Errorf("unexpected TX:capture_count %q", have)
+	}
 	if err := tx.Close(); err != nil {
 		t.Fatalf("Failed to close transaction: %s", err.Error())
 	}
 }
 
+func TestCaptureLimit(t *testing.T) {
+	waf := NewWAF()
+	waf.CaptureLimit = 12
+	tx := waf.NewTransaction()
+	tx.Capture = true
+	tx.CaptureField(11, "eleven")
+	tx.CaptureField(12, "twelve")
+	if want, have := "eleven", tx.variables.tx.Get("11"); len(have) != 1 || have[0] != want {
+		t.Errorf("unexpected TX:11, want %q, have %q", want, have)
+	}
+	if have := tx.variables.tx.Get("12"); len(have) != 0 {
+		t.Errorf("expected TX:12 over the limit to be ignored, have %q", have)
+	}
+	if want, have := "12", tx.variables.captureCount.Get(); want != have {
+		t.Errorf("unexpected capture count, want %q, have %q", want, have)
+	}
+}
+
 func TestRelevantAuditLogging(t *testing.T) {
 	tests := []struct {
 		name         string
@@ -901,6 +1121,60 @@ func TestLogCallback(t *testing.T) {
 	}
 }
 
+type matchedLoggerFunc func(line string, rule types.MatchedRule)
+
+func (f matchedLoggerFunc) LogMatched(line string, rule types.MatchedRule) {
+	f(line, rule)
+}
+
+func TestMatchedLogger(t *testing.T) {
+	waf := NewWAF()
+	var lines []string
+	waf.SetMatchedLogger(matchedLoggerFunc(func(line string, rule types.MatchedRule) {
+		if want, have := 1, rule.Rule().ID(); want != have {
+			t.Errorf("unexpected rule, want %d, have %d", want, have)
+		}
+		lines = append(lines, line)
+	}))
+	tx := waf.NewTransaction()
+	defer tx.Close()
+	tx.ProcessConnection("10.0.0.1", 1234, "127.0.0.1", 80)
+
+	rule := NewRule()
+	rule.ID_ = 1
+	rule.LogID_ = "1"
+	rule.Phase_ = 1
+	rule.Log = true
+	rule.Msg, _ = macro.NewMacro("SQL Injection Attack")
+	rule.Tags_ = []string{"attack-sqli"}
+	tx.MatchRule(rule, []types.MatchData{
+		&corazarules.MatchData{
+			Variable_: variables.Args,
+			Key_:      "id",
+			Message_:  "SQL Injection Attack",
+		},
+	})
+
+	rule.ID_ = 2
+	rule.Log = false
+	tx.MatchRule(rule, []types.MatchData{&corazarules.MatchData{Variable_: variables.Args}})
+
+	if want, have := 1, len(lines); want != have {
+		t.Fatalf("unexpected number of lines, want %d, have %d", want, have)
+	}
+	for _, expected := range []string{
+		`[client "10.0.0.1"] ModSecurity: Warning. SQL Injection Attack `,
+		`[id "1"]`,
+		`[msg "SQL Injection Attack"]`,
+		`[tag "attack-sqli"]`,
+		`[unique_id "` + tx.id + `"]`,
+	} {
+		if !strings.Contains(lines[0], expected) {
+			t.Errorf("expected line to contain %q, have %q", expected, lines[0])
+		}
+	}
+}
+
 func TestHeaderSetters(t *testing.T) {
 	waf := NewWAF()
 	tx := waf.NewTransaction()
@@ -1457,6 +1731,20 @@ func validateMacroExpansion(tests map[string]string, tx *Transaction, t *testing
 	}
 }
 
+func TestMacroDefaultValue(t *testing.T) {
+	tx := makeTransaction(t)
+	defer tx.Close()
+	tx.variables.tx.Set("block_status", []string{"406"})
+	validateMacroExpansion(map[string]string{
+		"%{tx.block_status|403}":                      "406",
+		"%{tx.missing_status|403}":                    "403",
+		"status %{tx.missing_status|403} for %{tx.a}": "status 403 for tx.a",
+		"%{tx.missing|}":                              "",
+		"%{request_method|GET}":                       tx.variables.requestMethod.Get(),
+		"%{response_content_type|text/html}":          "text/html",
+	}, tx, t)
+}
+
 func TestMacro(t *testing.T) {
 	tx := makeTransaction(t)
 	tx.variables.tx.Set("some", []string{"secretly"})
@@ -1771,6 +2059,78 @@ func TestForceRequestBodyOverride(t *testing.T) {
 	}
 }
 
+func TestTxUploadFiles(t *testing.T) {
+	if !environment.HasAccessToFS {
+		t.Skip("skipping test as it requires access to filesystem")
+	}
+	body := strings.Join([]string{
+		"--boundary",
+		"Content-Disposition: form-data; name=\"a\"; filename=\"a.txt\"",
+		"",
+		"first",
+		"--boundary",
+		"Content-Disposition: form-data; name=\"b\"; filename=\"b.txt\"",
+		"",
+		"second",
+		"--boundary--",
+	}, "\r\n")
+	for _, keep := range []bool{false, true} {
+		t.Run(fmt.Sprintf("keep files %t", keep), func(t *testing.T) {
+			waf := NewWAF()
+			waf.RequestBodyAccess = true
+			waf.UploadDir = t.TempDir()
+			waf.UploadFileMode = 0640
+			waf.UploadFileLimit = 1
+			waf.UploadKeepFiles = keep
+			tx := waf.NewTransaction()
+			tx.AddRequestHeader("Content-Type", "multipart/form-data; boundary=boundary")
+			tx.ProcessRequestHeaders()
+			if _, _, err := tx.WriteRequestBody([]byte(body)); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := tx.ProcessRequestBody(); err != nil {
+				t.Fatal(err)
+			}
+
+			files := tx.variables.filesTmpNames.Get("")
+			if want, have := 1, len(files); want != have {
+				t.Fatalf("unexpected number of stored files, want %d, have %d", want, have)
+			}
+			if want, have := waf.UploadDir, filepath.Dir(files[0]); want != have {
+				t.Errorf("unexpected upload directory, want %q, have %q", want, have)
+			}
+			content, err := os.ReadFile(files[0])
+			if err != nil {
+				t.Fatal(err)
+			}
+			if want, have := "first", string(content); want != have {
+				t.Errorf("unexpected file content, want %q, have %q", want, have)
+			}
+			info, err := os.Stat(files[0])
+			if err != nil {
+				t.Fatal(err)
+			}
+			if want, have := fs.FileMode(0640), info.Mode().Perm(); want != have {
+				t.Errorf("unexpected file mode, want %v, have %v", want, have)
+			}
+			if want, have := "1", tx.variables.multipartFileLimitExceeded.Get(); want != have {
+				t.Errorf("unexpected MULTIPART_FILE_LIMIT_EXCEEDED, want %q, have %q", want, have)
+			}
+			if want, have := 2, len(tx.variables.files.Get("")); want != have {
+				t.Errorf("expected the files over the limit to be inspected, want %d, have %d", want, have)
+			}
+
+			if err := tx.Close(); err != nil {
+				t.Fatal(err)
+			}
+			_, err = os.Stat(files[0])
+			if want, have := keep, err == nil; want != have {
+				t.Errorf("unexpected file presence after close, want %t, have %t", want, have)
+			}
+		})
+	}
+}
+
 func TestCloseFails(t *testing.T) {
 	if !environment.HasAccessToFS {
 		t.Skip("skipping test as it requires access to filesystem")
@@ -1863,3 +2223,76 @@ func TestRequestFilename(t *testing.T) {
 		})
 	}
 }
+
+func TestSetBodyLimits(t *testing.T) {
+	waf := NewWAF()
+	waf.RequestBodyAccess = true
+	waf.RequestBodyLimit = 10
+	waf.RequestBodyLimitMax = 20
+	waf.ResponseBodyLimit = 10
+	if err := waf.Validate(); err != nil {
+		t.Fatal(err)
+	}
+
+	tx := waf.NewTransaction()
+	if err := tx.SetRequestBodyLimit(0); err == nil {
+		t.Error("expected error for a zero limit")
+	}
+	if err := tx.SetRequestBodyLimit(100); err != nil {
+		t.Fatal(err)
+	}
+	if want, have := int64(20), tx.RequestBodyLimit; want != have {
+		t.Errorf("unexpected request body limit, want %d, have %d", want, have)
+	}
+	// the response body limit can't be raised without maximum
+	if err := tx.SetResponseBodyLimit(100); err != nil {
+		t.Fatal(err)
+	}
+	if want, have := int64(10), tx.ResponseBodyLimit; want != have {
+		t.Errorf("unexpected response body limit, want %d, have %d", want, have)
+	}
+	tx.ProcessRequestHeaders()
+	it, n, err := tx.WriteRequestBody([]byte("0123456789abcde"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if it != nil {
+		t.Error("unexpected interruption within the raised limit")
+	}
+	if want, have := 15, n; want != have {
+		t.Errorf("unexpected written bytes, want %d, have %d", want, have)
+	}
+	if _, err := tx.ProcessRequestBody(); err != nil {
+		t.Fatal(err)
+	}
+	if err := tx.SetRequestBodyLimit(15); err == nil {
+		t.Error("expected error after the request headers phase")
+	}
+	tx.Close()
+
+	// pooled buffers don't keep the limit of the previous transaction
+	tx = waf.NewTransaction()
+	defer tx.Close()
+	tx.ProcessRequestHeaders()
+	if it, _, _ := tx.WriteRequestBody([]byte("0123456789abcde")); it == nil {
+		t.Error("expected interruption over the configured limit")
+	}
+
+	for name, w := range map[string]*WAF{
+		"request max below limit":  {RequestBodyLimit: 10, RequestBodyLimitMax: 5},
+		"response max over 1gb":    {ResponseBodyLimit: 10, ResponseBodyLimitMax: _1gb + 1},
+		"request max over 1gb":     {RequestBodyLimit: 10, RequestBodyLimitMax: _1gb + 1},
+		"response max below limit": {ResponseBodyLimit: 10, ResponseBodyLimitMax: 5},
+	} {
+		waf := NewWAF()
+		if w.RequestBodyLimit != 0 {
+			waf.RequestBodyLimit, waf.RequestBodyLimitMax = w.RequestBodyLimit, w.RequestBodyLimitMax
+		}
+		if w.ResponseBodyLimit != 0 {
+			waf.ResponseBodyLimit, waf.ResponseBodyLimitMax = w.ResponseBodyLimit, w.ResponseBodyLimitMax
+		}
+		if err := waf.Validate(); err == nil {
+			t.Errorf("expected validation error for %s", name)
+		}
+	}
+}
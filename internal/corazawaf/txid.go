@@ -0,0 +1,38 @@
+// Copyright 2024 Juan Pablo Tosso and the OWASP Coraza contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package corazawaf
+
+import (
+	"sync"
+)
+
+// transactionIDs keeps track of the IDs of the transactions in flight
+// to detect connectors reusing the same ID for concurrent transactions
+type transactionIDs struct {
+	mu  sync.Mutex
+	ids map[string]int
+}
+
+// add registers id as in flight, it returns true if another
+// transaction with the same ID is still in flight
+func (t *transactionIDs) add(id string) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.ids == nil {
+		t.ids = map[string]int{}
+	}
+	t.ids[id]++
+	return t.ids[id] > 1
+}
+
+// remove unregisters a transaction previously registered with add
+func (t *transactionIDs) remove(id string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.ids[id] <= 1 {
+		delete(t.ids, id)
+		return
+	}
+	t.ids[id]--
+}
@@ -0,0 +1,64 @@
+// Copyright 2024 Juan Pablo Tosso and the OWASP Coraza contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package corazawaf
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/corazawaf/coraza/v3/debuglog"
+)
+
+func TestDuplicateTransactionIDs(t *testing.T) {
+	var debugLog bytes.Buffer
+	waf := NewWAF()
+	waf.Logger = debuglog.Default().WithLevel(debuglog.LevelWarn).WithOutput(&debugLog)
+	waf.DetectDuplicateTransactionIDs = true
+
+	tx1 := waf.NewTransactionWithOptions(Options{ID: "abc"})
+	if debugLog.Len() != 0 {
+		t.Fatalf("unexpected log for the first transaction: %s", debugLog.String())
+	}
+	tx2 := waf.NewTransactionWithOptions(Options{ID: "abc"})
+	if want, have := "Duplicate transaction ID", debugLog.String(); !strings.Contains(have, want) {
+		t.Errorf("expected log to contain %q, have %q", want, have)
+	}
+	if err := tx1.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := tx2.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	debugLog.Reset()
+	tx3 := waf.NewTransactionWithOptions(Options{ID: "abc"})
+	defer tx3.Close()
+	if debugLog.Len() != 0 {
+		t.Errorf("unexpected log once the previous transactions are closed: %s", debugLog.String())
+	}
+}
+
+func TestTransactionIDValidator(t *testing.T) {
+	waf := NewWAF()
+	waf.TransactionIDValidator = func(id string) error {
+		if strings.ContainsAny(id, " \n") {
+			return errors.New("invalid characters")
+		}
+		return nil
+	}
+
+	tx := waf.NewTransactionWithOptions(Options{ID: "valid-id"})
+	if want, have := "valid-id", tx.ID(); want != have {
+		t.Errorf("unexpected transaction ID, want %q, have %q", want, have)
+	}
+	tx.Close()
+
+	tx = waf.NewTransactionWithOptions(Options{ID: "invalid\nid"})
+	if tx.ID() == "invalid\nid" || tx.ID() == "" {
+		t.Errorf("expected the invalid transaction ID to be replaced, have %q", tx.ID())
+	}
+	tx.Close()
+}
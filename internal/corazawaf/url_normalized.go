@@ -0,0 +1,148 @@
+// Copyright 2024 Juan Pablo Tosso and the OWASP Coraza contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package corazawaf
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/ad3n/seclang/internal/collections"
+	"github.com/ad3n/seclang/internal/corazatypes"
+	urlutil "github.com/ad3n/seclang/internal/url"
+)
+
+// maxRequestPathSegments is the maximum number of segments of the normalized
+// path in REQUEST_PATH_SEGMENTS, the following segments are ignored
+const maxRequestPathSegments = 128
+
+// normalizedURL is the URL of the request after canonicalization
+type normalizedURL struct {
+	url      string
+	segments []string
+}
+
+// defaultPorts are the ports removed from the normalized hosts
+var defaultPorts = map[string]string{
+	"http":  "80",
+	"https": "443",
+}
+
+// initURLVariables creates REQUEST_URL_NORMALIZED and REQUEST_PATH_SEGMENTS,
+// they are derived from the request when they are read
+func (tx *Transaction) initURLVariables() {
+	v := &tx.variables
+	v.requestURLNormalized = collections.NewLazySingle(corazatypes.RequestURLNormalized, func() string {
+		return tx.requestNormalizedURL().url
+	})
+	v.requestPathSegments = collections.NewLazyMap(corazatypes.RequestPathSegments, func(m *collections.Map) {
+		for i, s := range tx.requestNormalizedURL().segments {
+			m.Set(strconv.Itoa(i), []string{s})
+		}
+	})
+}
+
+// resetNormalizedURL derives the normalized URL from the request again the
+// next time it is read, e.g. once the request headers are added
+func (tx *Transaction) resetNormalizedURL() {
+	tx.normalizedURL = nil
+	tx.variables.requestPathSegments.Invalidate()
+}
+
+// requestNormalizedURL returns the normalized URL of the request and the
+// segments of its path. The scheme and host are the ones of the request URI
+// in absolute form, otherwise the scheme is https if the server port is 443
+// and http if not, and the host is the Host header or the server name. The
+// URL is only made of the path and query if none is known.
+func (tx *Transaction) requestNormalizedURL() *normalizedURL {
+	if tx.normalizedURL != nil {
+		return tx.normalizedURL
+	}
+	tx.normalizedURL = &normalizedURL{}
+	raw := tx.variables.requestURIRaw.Get()
+	if raw == "" {
+		return tx.normalizedURL
+	}
+	if i := strings.IndexByte(raw, '#'); i != -1 {
+		raw = raw[:i]
+	}
+	rawPath, query, hasQuery := strings.Cut(raw, "?")
+
+	scheme, host := "", ""
+	if i := strings.Index(rawPath, "://"); i > 0 && !strings.ContainsRune(rawPath[:i], '/') {
+		scheme = strings.ToLower(rawPath[:i])
+		host, rawPath, _ = strings.Cut(rawPath[i+3:], "/")
+		rawPath = "/" + rawPath
+	} else {
+		scheme = "http"
+		if tx.variables.serverPort.Get() == "443" {
+			scheme = "https"
+		}
+		var ok bool
+		if host, ok = tx.variables.requestHeaders.GetFirst("host"); !ok {
+			host = tx.variables.serverName.Get()
+		}
+	}
+
+	path, segments := normalizePath(rawPath)
+	if len(segments) > maxRequestPathSegments {
+		segments = segments[:maxRequestPathSegments]
+	}
+	tx.normalizedURL.segments = segments
+
+	var sb strings.Builder
+	if host = normalizeHost(host, scheme); host != "" {
+		sb.WriteString(scheme)
+		sb.WriteString("://")
+		sb.WriteString(host)
+	}
+	sb.WriteString(path)
+	if hasQuery && query != "" {
+		sb.WriteByte('?')
+		sb.WriteString(query)
+	}
+	tx.normalizedURL.url = sb.String()
+	return tx.normalizedURL
+}
+
+// normalizePath decodes the escapes of the path once, replaces backslashes
+// with slashes and resolves the empty, . and .. segments, the trailing slash
+// is kept. It returns the path and its segments.
+func normalizePath(p string) (string, []string) {
+	p = strings.ReplaceAll(urlutil.PathUnescape(p), `\`, "/")
+	var segments []string
+	for _, s := range strings.Split(p, "/") {
+		switch s {
+		case "", ".":
+		case "..":
+			if len(segments) > 0 {
+				segments = segments[:len(segments)-1]
+			}
+		default:
+			segments = append(segments, s)
+		}
+	}
+	path := "/" + strings.Join(segments, "/")
+	if len(segments) > 0 && strings.HasSuffix(p, "/") {
+		path += "/"
+	}
+	return path, segments
+}
+
+// normalizeHost lowercases the host and removes the user info, the trailing
+// dot and the default port of the scheme
+func normalizeHost(host string, scheme string) string {
+	host = strings.ToLower(host)
+	if i := strings.LastIndexByte(host, '@'); i != -1 {
+		host = host[i+1:]
+	}
+	name, port := host, ""
+	if i := strings.LastIndexByte(host, ':'); i != -1 && !strings.ContainsRune(host[i:], ']') {
+		name, port = host[:i], host[i+1:]
+	}
+	name = strings.TrimSuffix(name, ".")
+	if port == "" || port == defaultPorts[scheme] {
+		return name
+	}
+	return name + ":" + port
+}
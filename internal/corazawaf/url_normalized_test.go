@@ -0,0 +1,75 @@
+// Copyright 2024 Juan Pablo Tosso and the OWASP Coraza contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package corazawaf
+
+import (
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestNormalizedURL(t *testing.T) {
+	tests := []struct {
+		uri      string
+		host     string
+		port     int
+		url      string
+		segments []string
+	}{
+		{"/a/b?x=1", "Example.COM", 80, "http://example.com/a/b?x=1", []string{"a", "b"}},
+		{"/a//./b/../c/", "example.com:80", 80, "http://example.com/a/c/", []string{"a", "c"}},
+		{"/a%2Fb/%2e%2e/c%20d#frag", "example.com.", 443, "https://example.com/a/c d", []string{"a", "c d"}},
+		{`/a\b\..\c?`, "example.com:8080", 8080, "http://example.com:8080/a/c", []string{"a", "c"}},
+		{"/../../etc/passwd", "[::1]:443", 443, "https://[::1]/etc/passwd", []string{"etc", "passwd"}},
+		{"HTTPS://user@Example.com:443/x?y", "ignored", 80, "https://example.com/x?y", []string{"x"}},
+		{"http://example.com", "", 80, "http://example.com/", nil},
+		{"/", "", 80, "/", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.uri, func(t *testing.T) {
+			tx := NewWAF().NewTransaction()
+			defer tx.Close()
+			tx.ProcessConnection("127.0.0.1", 12345, "127.0.0.1", tt.port)
+			tx.ProcessURI(tt.uri, "GET", "HTTP/1.1")
+			if tt.host != "" {
+				tx.AddRequestHeader("Host", tt.host)
+			}
+			tx.ProcessRequestHeaders()
+
+			if have := tx.variables.requestURLNormalized.Get(); have != tt.url {
+				t.Errorf("unexpected normalized URL, want %q, have %q", tt.url, have)
+			}
+			if have := pathSegments(tx); !slices.Equal(tt.segments, have) {
+				t.Errorf("unexpected segments, want %q, have %q", tt.segments, have)
+			}
+		})
+	}
+}
+
+func TestNormalizedURLSegmentLimit(t *testing.T) {
+	tx := NewWAF().NewTransaction()
+	defer tx.Close()
+	tx.ProcessURI(strings.Repeat("/a", maxRequestPathSegments+10), "GET", "HTTP/1.1")
+	tx.ProcessRequestHeaders()
+
+	if want, have := maxRequestPathSegments, len(pathSegments(tx)); want != have {
+		t.Errorf("unexpected number of segments, want %d, have %d", want, have)
+	}
+	if want, have := "/"+strings.Repeat("a/", maxRequestPathSegments+9)+"a", tx.variables.requestURLNormalized.Get(); want != have {
+		t.Errorf("unexpected normalized URL, want %q, have %q", want, have)
+	}
+}
+
+// pathSegments returns the values of REQUEST_PATH_SEGMENTS in order
+func pathSegments(tx *Transaction) []string {
+	var segments []string
+	for i := 0; ; i++ {
+		s := tx.variables.requestPathSegments.Get(strconv.Itoa(i))
+		if len(s) == 0 {
+			return segments
+		}
+		segments = append(segments, s[0])
+	}
+}
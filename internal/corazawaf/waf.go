@@ -11,14 +11,20 @@ import (
 	"io/fs"
 	"os"
 	"regexp"
+	"sort"
 	"strconv"
+	"sync/atomic"
 	"time"
 
 	"github.com/ad3n/seclang/experimental/plugins/plugintypes"
 	"github.com/ad3n/seclang/internal/auditlog"
+	"github.com/ad3n/seclang/internal/bodyprocessors"
+	"github.com/ad3n/seclang/internal/collections"
+	"github.com/ad3n/seclang/internal/corazarules"
 	"github.com/ad3n/seclang/internal/environment"
 	stringutils "github.com/ad3n/seclang/internal/strings"
 	"github.com/ad3n/seclang/internal/sync"
+	"github.com/ad3n/seclang/internal/transformations"
 	"github.com/corazawaf/coraza/v3/debuglog"
 	"github.com/corazawaf/coraza/v3/types"
 )
@@ -33,7 +39,9 @@ import (
 // All WAF instance fields are immutable, if you update any
 // of them in runtime you might create concurrency issues
 type WAF struct {
-	txPool sync.Pool
+	// wafState is the state of the WAF that is not part of its
+	// configuration, it is kept when the configuration changes, see Apply
+	*wafState
 
 	// ruleGroup object, contains all rules and helpers
 	Rules RuleGroup
@@ -62,9 +70,6 @@ type WAF struct {
 	// Web Application id, apps sharing the same id will share persistent collections
 	WebAppID string
 
-	// Add significant rule components to audit log
-	ComponentNames []string
-
 	// If true WAF engine will fail when remote rules cannot be loaded
 	AbortOnRemoteRulesFail bool
 
@@ -80,14 +85,17 @@ type WAF struct {
 	// Path to store data files (ex. cache)
 	DataDir string
 
-	// If true, the WAF will store the uploaded files in the UploadDir
-	// directory
+	// If true, the uploaded files stored in the UploadDir directory are
+	// kept when the transaction is closed instead of being removed, e.g.
+	// to be scanned by an antivirus
 	UploadKeepFiles bool
 	// UploadFileMode instructs the waf to set the file mode for uploaded files
 	UploadFileMode fs.FileMode
-	// UploadFileLimit is the maximum size of the uploaded file to be stored
+	// UploadFileLimit is the maximum number of uploaded files stored per
+	// request, 0 means no limit
 	UploadFileLimit int
-	// UploadDir is the directory where the uploaded files will be stored
+	// UploadDir is the directory where the uploaded files will be stored,
+	// TmpDir is used when it is empty
 	UploadDir string
 
 	// Request body in memory limit excluding the size of any files being transported in the request.
@@ -96,23 +104,41 @@ type WAF struct {
 
 	RequestBodyLimitAction types.BodyLimitAction
 
+	// RequestBodyLimitMax is the maximum request body limit transactions can
+	// set with ctl:requestBodyLimit, it defaults to RequestBodyLimit
+	RequestBodyLimitMax int64
+
+	// ResponseBodyLimitMax is the maximum response body limit transactions can
+	// set with ctl:responseBodyLimit, it defaults to ResponseBodyLimit
+	ResponseBodyLimitMax int64
+
+	// UnexpectedRequestBodyAction defines whether the bodies of the methods that
+	// don't define one, like GET, are processed
+	UnexpectedRequestBodyAction UnexpectedRequestBodyAction
+
 	ResponseBodyLimitAction types.BodyLimitAction
 
 	ArgumentSeparator string
 
-	// ProducerConnector is used by connectors to identify the producer
-	// on audit logs, for example, apache-modcoraza
-	ProducerConnector string
+	// Producer identifies the connector and the rulesets on audit logs
+	Producer Producer
 
-	// ProducerConnectorVersion is used by connectors to identify the producer
-	// version on audit logs
-	ProducerConnectorVersion string
+	// labels identify the WAF instance (e.g. cluster, node or listener) on
+	// audit logs and debug logs, they are set with SetLabel
+	labels map[string]string
+	// labelFields are the labels sorted by key, added to the transaction
+	// debug logs
+	labelFields []debuglog.ContextField
 
 	// Used for the debug logger
 	Logger debuglog.Logger
 
 	ErrorLogCb func(rule types.MatchedRule)
 
+	// MatchedLogger receives the matched rules formatted as ModSecurity
+	// error.log lines
+	MatchedLogger plugintypes.MatchedLogger
+
 	// Audit mode status
 	AuditEngine types.AuditEngineStatus
 
@@ -135,6 +161,184 @@ type WAF struct {
 
 	// Configures the maximum number of ARGS that will be accepted for processing.
 	ArgumentLimit int
+
+	// FlattenArrayArguments makes url-encoded arguments using array keys like a[]=1
+	// be indexed the way applications parse them (a[0]) instead of kept raw
+	FlattenArrayArguments bool
+
+	// XMLExternalEntity makes the XML body processor resolve the external
+	// entities of the request bodies with the registered resolver
+	XMLExternalEntity bool
+
+	// UnicodeMap is the best-fit mapping used by urlDecodeUni to decode %uXXXX
+	// sequences, it applies to the rules parsed after it is set. nil keeps
+	// the lower byte of the code point
+	UnicodeMap *transformations.UnicodeMap
+
+	// RuleMatchLimit is the maximum number of match data kept for each rule,
+	// 0 means unlimited
+	RuleMatchLimit int
+
+	// GeoDatabase resolves client addresses for the @geoLookup operator,
+	// without database the operator matches every address
+	GeoDatabase plugintypes.GeoDatabase
+
+	// URLReputation looks up the URLs of the @gsbLookup operator, without
+	// provider the operator doesn't match
+	URLReputation plugintypes.URLReputation
+
+	// CaptureLimit is the maximum number of groups captured into TX:0, TX:1...
+	// by operators with the capture action, it defaults to 10 (TX:0-TX:9)
+	CaptureLimit int
+
+	// HashEngine signs the links of the HTML response bodies selected by
+	// HashMethods and enforces their signature with @validateHash
+	HashEngine bool
+
+	// HashKey is the key links are signed with using HMAC-SHA1
+	HashKey []byte
+
+	// HashKeyMode is the value the key is combined with
+	HashKeyMode HashKeyMode
+
+	// HashParam is the parameter signed links carry the signature in,
+	// it defaults to hmac
+	HashParam string
+
+	// HashMethods select the links of the response bodies to sign
+	HashMethods []HashMethod
+
+	// StreamInBodyInspection exposes the raw request body as STREAM_INPUT_BODY
+	// and allows @rsub to rewrite it
+	StreamInBodyInspection bool
+
+	// StreamOutBodyInspection exposes the raw response body as STREAM_OUTPUT_BODY
+	// and allows @rsub to rewrite it
+	StreamOutBodyInspection bool
+
+	// MessageCatalog replaces the msg of the rules in the audit log, rules
+	// missing in the catalog keep their msg
+	MessageCatalog MessageCatalog
+
+	// ConnEngine enables the connection engine, connectors report the client
+	// connections with TrackConnection to enforce the state limits
+	ConnEngine types.RuleEngineStatus
+
+	// ConnReadStateLimit limits the connections of a client reading requests
+	ConnReadStateLimit ConnLimit
+
+	// ConnWriteStateLimit limits the connections of a client writing responses
+	ConnWriteStateLimit ConnLimit
+
+	// SecurityHeadersEngine evaluates the security headers of the responses
+	// into SECURITY_HEADERS, the missing ones are injected when it is On
+	SecurityHeadersEngine types.RuleEngineStatus
+
+	// securityHeaderDefaults are the values injected for the missing security
+	// headers by lowercase name, see SetSecurityHeaderDefault
+	securityHeaderDefaults map[string]string
+
+	// GuardianLog receives a summary of every transaction, in addition to
+	// the audit log
+	GuardianLog *GuardianLog
+
+	// RulePerfTime is the duration above which rules are exposed in PERF_RULES
+	// and logged in the audit log, rules are not timed when it is 0
+	RulePerfTime time.Duration
+
+	// RxBudget bounds the evaluations of @rx, rules can override it with the
+	// rxBudget action
+	RxBudget RxBudget
+
+	// dependencyFailureModes contains the failure mode of each external dependency
+	dependencyFailureModes map[string]DependencyFailureMode
+
+	// DetectDuplicateTransactionIDs logs a warning when a transaction is created
+	// with the ID of another transaction still in flight
+	DetectDuplicateTransactionIDs bool
+
+	// TransactionIDValidator validates the IDs provided by connectors through
+	// NewTransactionWithOptions, transactions with an invalid ID get a random one
+	TransactionIDValidator func(id string) error
+
+	// TransactionWatchdogTimeout is the duration after which transactions not
+	// closed yet are logged as leaked, transactions are not watched when it is 0
+	TransactionWatchdogTimeout time.Duration
+
+	// PanicDump writes a forensic dump of the transactions whose rule
+	// evaluation panics, panics are not handled when it is nil
+	PanicDump *PanicDump
+
+	// TransactionMemoryLimit is the approximate memory, in bytes, the data of a
+	// transaction can use, it is checked before evaluating each rule. The
+	// memory is not limited when it is 0.
+	TransactionMemoryLimit int64
+
+	// TransactionMemoryLimitReject interrupts the transactions exceeding
+	// TransactionMemoryLimit, otherwise they are only flagged
+	TransactionMemoryLimitReject bool
+
+	// BanStore keeps the clients banned by the ban action, transactions
+	// from banned clients are interrupted before evaluating any rule
+	BanStore BanStore
+
+	// PersistentCollections keeps the records of the persistent collections
+	// across transactions, their time to live is set with SecCollectionTimeout
+	PersistentCollections *collections.PersistentStore
+
+	// CollectionTimeout is the time to live of the records created by initcol,
+	// collections.DefaultCollectionTimeout when it is 0
+	CollectionTimeout time.Duration
+
+	// datasets holds the datasets defined with SecDataset, see Datasets
+	datasets map[string][]string
+	// datasetFeeds holds the values of the datasets updated at runtime, see
+	// DatasetFeeds
+	datasetFeeds *DatasetFeeds
+
+	// Clock returns the current time, it is used to timestamp transactions
+	// and evaluate the active windows of rules. It defaults to time.Now
+	Clock func() time.Time
+}
+
+// wafState holds the transactions and the connections of a WAF
+type wafState struct {
+	txPool sync.Pool
+
+	// conns counts the connections of the clients when ConnEngine is enabled
+	conns connTracker
+
+	// inflightTransactionIDs contains the IDs of the transactions in flight,
+	// it is only populated when DetectDuplicateTransactionIDs is enabled
+	inflightTransactionIDs transactionIDs
+
+	// leakedTransactions counts the transactions that exceeded the watchdog timeout
+	leakedTransactions atomic.Int64
+
+	// transactions counts the transactions in flight, the resources of a
+	// closing WAF are released once they are closed
+	transactions atomic.Int64
+
+	// closing is set by Close, closed once the resources are released
+	closing atomic.Bool
+	closed  atomic.Bool
+
+	// operatorValues are the values shared by the operators of the WAF, e.g.
+	// the caches of their lookups, see Transaction.SharedOperatorValue
+	operatorValues sharedOperatorValues
+
+	// stagedFrom is the WAF a WAF returned by Stage copied its configuration
+	// from, the resources shared with it are not closed by the staged WAF
+	stagedFrom *WAF
+}
+
+// now returns the current time of the clock of the WAF, time.Now if no clock
+// is set
+func (w *WAF) now() time.Time {
+	if w.Clock == nil {
+		return time.Now()
+	}
+	return w.Clock()
 }
 
 // Options is used to pass options to the WAF instance
@@ -154,20 +358,55 @@ func (w *WAF) NewTransaction() *Transaction {
 // NewTransactionWithOptions Creates a new initialized transaction for this WAF
 // instance with the provided options
 func (w *WAF) NewTransactionWithOptions(opts Options) *Transaction {
+	return w.newTransaction(w.transactionOptions(opts))
+}
+
+// TryNewTransactionWithOptions creates a new transaction like
+// NewTransactionWithOptions unless the WAF is closing, in which case it
+// returns false as the resources of the WAF may already be released. The
+// check and the count of the transaction in flight are a single step, so a
+// concurrent Close cannot release the resources of the returned transaction.
+func (w *WAF) TryNewTransactionWithOptions(opts Options) (*Transaction, bool) {
+	w.transactions.Add(1)
+	if w.closing.Load() {
+		w.transactionClosed()
+		return nil, false
+	}
+	return w.initTransaction(w.transactionOptions(opts)), true
+}
+
+// transactionOptions sets the defaults of the options of a new transaction
+func (w *WAF) transactionOptions(opts Options) Options {
 	if opts.ID == "" {
 		opts.ID = stringutils.RandomString(19)
+	} else if w.TransactionIDValidator != nil {
+		if err := w.TransactionIDValidator(opts.ID); err != nil {
+			id := stringutils.RandomString(19)
+			w.Logger.Warn().
+				Str("tx_id", opts.ID).
+				Str("new_tx_id", id).
+				Err(err).
+				Msg("Invalid transaction ID, using a random one")
+			opts.ID = id
+		}
 	}
 
 	if opts.Context == nil {
 		opts.Context = context.Background()
 	}
 
-	return w.newTransaction(opts)
+	return opts
 }
 
 // NewTransactionWithID Creates a new initialized transaction for this WAF instance
 // Using the specified ID
 func (w *WAF) newTransaction(opts Options) *Transaction {
+	w.transactions.Add(1)
+	return w.initTransaction(opts)
+}
+
+// initTransaction initializes a transaction already counted in flight
+func (w *WAF) initTransaction(opts Options) *Transaction {
 	tx := w.txPool.Get().(*Transaction)
 	tx.id = opts.ID
 	tx.context = opts.Context
@@ -184,19 +423,37 @@ func (w *WAF) newTransaction(opts Options) *Transaction {
 	tx.ResponseBodyAccess = w.ResponseBodyAccess
 	tx.ResponseBodyLimit = int64(w.ResponseBodyLimit)
 	tx.RuleEngine = w.RuleEngine
-	tx.HashEngine = false
-	tx.HashEnforcement = false
+	tx.HashEngine = w.HashEngine
+	tx.HashEnforcement = w.HashEngine
 	tx.lastPhase = 0
 	tx.ruleRemoveByID = nil
+	tx.selectedRules = nil
 	tx.ruleRemoveTargetByID = map[int][]ruleVariableParams{}
 	tx.Skip = 0
 	tx.AllowType = 0
 	tx.Capture = false
+	tx.captureCount = 0
+	tx.namedCaptures = nil
 	tx.stopWatches = map[types.RulePhase]int64{}
+	tx.perfRules = tx.perfRules[:0]
+	tx.normalizedURL = nil
+	tx.ruleRxBudget = nil
+	tx.responseHeaderMutations = nil
 	tx.WAF = w
 	tx.debugLogger = w.Logger.With(debuglog.Str("tx_id", tx.id))
-	tx.Timestamp = time.Now().UnixNano()
+	if len(w.labelFields) > 0 {
+		tx.debugLogger = tx.debugLogger.With(w.labelFields...)
+	}
+	tx.Timestamp = w.now().UnixNano()
 	tx.audit = false
+	tx.matchesTruncated = false
+	tx.idTracked = false
+	if w.DetectDuplicateTransactionIDs {
+		if w.inflightTransactionIDs.add(tx.id) {
+			tx.debugLogger.Warn().Msg("Duplicate transaction ID, another transaction with the same ID is in flight")
+		}
+		tx.idTracked = true
+	}
 
 	// Always non-nil if buffers / collections were already initialized so we don't do any of them
 	// based on the presence of RequestBodyBuffer.
@@ -221,8 +478,17 @@ func (w *WAF) newTransaction(opts Options) *Transaction {
 		})
 
 		tx.variables = *NewTransactionVariables()
+		tx.initPerfVariables()
+		tx.initMemoryVariables()
+		tx.initCaptureCount()
+		tx.initURLVariables()
+		tx.initMemoryCounter()
 		tx.transformationCache = map[transformationKey]*transformationValue{}
 	}
+	// the limits of pooled buffers may have been overridden by the previous transaction
+	tx.requestBodyBuffer.options.Limit = w.RequestBodyLimit
+	tx.responseBodyBuffer.options.Limit = w.ResponseBodyLimit
+	tx.responseBodyBuffer.options.MemoryLimit = w.ResponseBodyLimit
 
 	// set capture variables
 	for i := 0; i <= 10; i++ {
@@ -244,6 +510,11 @@ func (w *WAF) newTransaction(opts Options) *Transaction {
 	tx.variables.uniqueID.Set(tx.id)
 	tx.setTimeVariables()
 
+	tx.watchdog = nil
+	if w.TransactionWatchdogTimeout > 0 {
+		tx.startWatchdog(w.TransactionWatchdogTimeout)
+	}
+
 	tx.debugLogger.Debug().Msg("Transaction started")
 
 	return tx
@@ -292,8 +563,10 @@ func NewWAF() *WAF {
 	}
 
 	waf := &WAF{
-		// Initializing pool for transactions
-		txPool: sync.NewPool(func() interface{} { return new(Transaction) }),
+		wafState: &wafState{
+			// Initializing pool for transactions
+			txPool: sync.NewPool(func() interface{} { return new(Transaction) }),
+		},
 		// These defaults are unavoidable as they are zero values for the variables
 		RuleEngine:                types.RuleEngineOn,
 		RequestBodyAccess:         false,
@@ -313,7 +586,18 @@ func NewWAF() *WAF {
 		AuditLogFormat: "Native",
 		Logger:         logger,
 		ArgumentLimit:  1000,
+		CaptureLimit:   corazarules.DefaultCaptureLimit,
+		Clock:          time.Now,
+		BanStore:       NewMemoryBanStore(),
+		ConnEngine:     types.RuleEngineOff,
+
+		SecurityHeadersEngine: types.RuleEngineOff,
+
+		TransactionMemoryLimitReject: true,
+		PersistentCollections:        collections.NewPersistentStore(),
 	}
+	// records expire according to the clock of the WAF, even if replaced
+	waf.PersistentCollections.Clock = waf.now
 
 	if environment.HasAccessToFS {
 		waf.TmpDir = os.TempDir()
@@ -327,6 +611,32 @@ func (w *WAF) SetDebugLogOutput(wr io.Writer) {
 	w.Logger = w.Logger.WithOutput(wr)
 }
 
+// SetLabel sets a label identifying the WAF instance, labels are added to
+// the audit log producer information and to the transaction debug logs
+// so processes running multiple WAF instances can tell which one produced an event
+func (w *WAF) SetLabel(key, value string) {
+	if w.labels == nil {
+		w.labels = map[string]string{}
+	}
+	w.labels[key] = value
+
+	keys := make([]string, 0, len(w.labels))
+	for k := range w.labels {
+		keys = append(keys, k)
+	}
+	// sorted to keep the logs stable
+	sort.Strings(keys)
+	w.labelFields = make([]debuglog.ContextField, 0, len(keys))
+	for _, k := range keys {
+		w.labelFields = append(w.labelFields, debuglog.Str(k, w.labels[k]))
+	}
+}
+
+// Labels returns the labels of the WAF instance
+func (w *WAF) Labels() map[string]string {
+	return w.labels
+}
+
 // SetDebugLogLevel changes the debug level of the WAF instance
 func (w *WAF) SetDebugLogLevel(lvl debuglog.Level) error {
 	if !lvl.Valid() {
@@ -339,7 +649,29 @@ func (w *WAF) SetDebugLogLevel(lvl debuglog.Level) error {
 
 // SetAuditLogWriter sets the audit log writer
 func (w *WAF) SetAuditLogWriter(alw plugintypes.AuditLogWriter) {
+	if alw == w.auditLogWriter {
+		return
+	}
+	if w.auditLogWriterInitialized && (w.stagedFrom == nil || w.auditLogWriter != w.stagedFrom.auditLogWriter) {
+		if err := w.auditLogWriter.Close(); err != nil {
+			w.Logger.Error().Err(err).Msg("Failed to close the previous audit log writer")
+		}
+	}
 	w.auditLogWriter = alw
+	w.auditLogWriterInitialized = false
+}
+
+// SetGuardianLog sets the guardian log and closes the previous one, unless
+// it is still used by the WAF the WAF was staged from
+func (w *WAF) SetGuardianLog(g *GuardianLog) {
+	previous := w.GuardianLog
+	w.GuardianLog = g
+	if previous == nil || previous == g || w.inheritsGuardianLog(previous) {
+		return
+	}
+	if err := previous.Close(); err != nil {
+		w.Logger.Error().Err(err).Msg("Failed to close the previous guardian log")
+	}
 }
 
 // AuditLogWriter returns the audit log writer. If the writer is not initialized,
@@ -371,6 +703,49 @@ func (w *WAF) InitAuditLogWriter() error {
 	return nil
 }
 
+// Close releases the resources of the WAF, like the audit log writer it
+// initialized and the guardian log, once the transactions in flight are
+// closed, e.g. after the WAF was replaced by a reloaded one. Transactions
+// must not be created once the WAF is closed.
+func (w *WAF) Close() error {
+	w.closing.Store(true)
+	if w.transactions.Load() > 0 {
+		// the last transaction releases the resources
+		return nil
+	}
+	return w.close()
+}
+
+// close releases the resources of the WAF, only the first call does
+func (w *WAF) close() error {
+	if !w.closed.CompareAndSwap(false, true) {
+		return nil
+	}
+	var errs []error
+	if w.auditLogWriterInitialized {
+		if err := w.auditLogWriter.Close(); err != nil {
+			errs = append(errs, fmt.Errorf("closing audit log writer: %v", err))
+		}
+	}
+	if w.GuardianLog != nil {
+		if err := w.GuardianLog.Close(); err != nil {
+			errs = append(errs, fmt.Errorf("closing guardian log: %v", err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// transactionClosed releases the resources of a closing WAF once its last
+// transaction in flight is closed
+func (w *WAF) transactionClosed() {
+	if w.transactions.Add(-1) > 0 || !w.closing.Load() {
+		return
+	}
+	if err := w.close(); err != nil {
+		w.Logger.Error().Err(err).Msg("Failed to close WAF")
+	}
+}
+
 // SetErrorCallback sets the callback function for error logging
 // The error callback receives all the error data and some
 // helpers to write modsecurity style logs
@@ -378,6 +753,12 @@ func (w *WAF) SetErrorCallback(cb func(rule types.MatchedRule)) {
 	w.ErrorLogCb = cb
 }
 
+// SetMatchedLogger sets the logger receiving the matched rules formatted
+// as ModSecurity error.log lines, it can be used along with the error callback
+func (w *WAF) SetMatchedLogger(l plugintypes.MatchedLogger) {
+	w.MatchedLogger = l
+}
+
 func (w *WAF) SetRequestBodyInMemoryLimit(limit int64) {
 	w.requestBodyInMemoryLimit = &limit
 }
@@ -386,6 +767,37 @@ func (w *WAF) RequestBodyInMemoryLimit() *int64 {
 	return w.requestBodyInMemoryLimit
 }
 
+func (w *WAF) requestBodyLimitMax() int64 {
+	if w.RequestBodyLimitMax == 0 {
+		return w.RequestBodyLimit
+	}
+	return w.RequestBodyLimitMax
+}
+
+func (w *WAF) responseBodyLimitMax() int64 {
+	if w.ResponseBodyLimitMax == 0 {
+		return w.ResponseBodyLimit
+	}
+	return w.ResponseBodyLimitMax
+}
+
+// uploadDir returns the directory the uploaded files are stored in
+func (w *WAF) uploadDir() string {
+	if w.UploadDir == "" {
+		return w.TmpDir
+	}
+	return w.UploadDir
+}
+
+// xmlEntityResolver returns the resolver of the external entities of XML
+// bodies, or nil if they must not be resolved
+func (w *WAF) xmlEntityResolver() plugintypes.XMLEntityResolver {
+	if !w.XMLExternalEntity {
+		return nil
+	}
+	return bodyprocessors.GetXMLEntityResolver()
+}
+
 // Validate validates the waf after all the settings have been set.
 func (w *WAF) Validate() error {
 	if w.RequestBodyLimit <= 0 {
@@ -414,9 +826,25 @@ func (w *WAF) Validate() error {
 		return errors.New("response body limit should be at most 1GB")
 	}
 
+	if w.RequestBodyLimitMax != 0 && (w.RequestBodyLimitMax < w.RequestBodyLimit || w.RequestBodyLimitMax > _1gb) {
+		return errors.New("request body limit maximum should be between the request body limit and 1GB")
+	}
+
+	if w.ResponseBodyLimitMax != 0 && (w.ResponseBodyLimitMax < w.ResponseBodyLimit || w.ResponseBodyLimitMax > _1gb) {
+		return errors.New("response body limit maximum should be between the response body limit and 1GB")
+	}
+
 	if w.ArgumentLimit <= 0 {
 		return errors.New("argument limit should be bigger than 0")
 	}
 
+	if w.RuleMatchLimit < 0 {
+		return errors.New("rule match limit should not be negative")
+	}
+
+	if w.CaptureLimit <= 0 {
+		return errors.New("capture limit should be bigger than 0")
+	}
+
 	return nil
 }
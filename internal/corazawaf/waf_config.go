@@ -0,0 +1,228 @@
+// Copyright 2024 Juan Pablo Tosso and the OWASP Coraza contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package corazawaf
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+
+	"github.com/corazawaf/coraza/v3/types"
+)
+
+// EffectiveConfig is the configuration a WAF ended up with after parsing,
+// including the defaults of the settings that were not configured.
+// Values use the directive syntax, e.g. "DetectionOnly" for the rule engine,
+// so they can be compared with the intended configuration.
+type EffectiveConfig struct {
+	RuleEngine string `json:"rule_engine" yaml:"rule_engine"`
+	Rules      int    `json:"rules" yaml:"rules"`
+
+	RequestBodyAccess           bool   `json:"request_body_access" yaml:"request_body_access"`
+	RequestBodyLimit            int64  `json:"request_body_limit" yaml:"request_body_limit"`
+	RequestBodyInMemoryLimit    int64  `json:"request_body_in_memory_limit" yaml:"request_body_in_memory_limit"`
+	RequestBodyNoFilesLimit     int64  `json:"request_body_no_files_limit" yaml:"request_body_no_files_limit"`
+	RequestBodyLimitAction      string `json:"request_body_limit_action" yaml:"request_body_limit_action"`
+	RequestBodyLimitMax         int64  `json:"request_body_limit_max" yaml:"request_body_limit_max"`
+	RequestBodyUnexpectedAction string `json:"request_body_unexpected_action" yaml:"request_body_unexpected_action"`
+
+	ResponseBodyAccess      bool     `json:"response_body_access" yaml:"response_body_access"`
+	ResponseBodyLimit       int64    `json:"response_body_limit" yaml:"response_body_limit"`
+	ResponseBodyLimitAction string   `json:"response_body_limit_action" yaml:"response_body_limit_action"`
+	ResponseBodyLimitMax    int64    `json:"response_body_limit_max" yaml:"response_body_limit_max"`
+	ResponseBodyMimeTypes   []string `json:"response_body_mime_types" yaml:"response_body_mime_types"`
+
+	ArgumentLimit         int    `json:"argument_limit" yaml:"argument_limit"`
+	ArgumentSeparator     string `json:"argument_separator" yaml:"argument_separator"`
+	FlattenArrayArguments bool   `json:"flatten_array_arguments" yaml:"flatten_array_arguments"`
+	XMLExternalEntity     bool   `json:"xml_external_entity" yaml:"xml_external_entity"`
+	RuleMatchLimit        int    `json:"rule_match_limit" yaml:"rule_match_limit"`
+	CaptureLimit          int    `json:"capture_limit" yaml:"capture_limit"`
+	UnicodeCodePage       int    `json:"unicode_code_page,omitempty" yaml:"unicode_code_page,omitempty"`
+
+	TransactionMemoryLimit       int64  `json:"transaction_memory_limit" yaml:"transaction_memory_limit"`
+	TransactionMemoryLimitAction string `json:"transaction_memory_limit_action" yaml:"transaction_memory_limit_action"`
+
+	AuditEngine            string `json:"audit_engine" yaml:"audit_engine"`
+	AuditLogParts          string `json:"audit_log_parts" yaml:"audit_log_parts"`
+	AuditLogFormat         string `json:"audit_log_format" yaml:"audit_log_format"`
+	AuditLogRelevantStatus string `json:"audit_log_relevant_status,omitempty" yaml:"audit_log_relevant_status,omitempty"`
+	AuditLog               string `json:"audit_log,omitempty" yaml:"audit_log,omitempty"`
+	AuditLogFileMode       string `json:"audit_log_file_mode" yaml:"audit_log_file_mode"`
+	AuditLogStorageDir     string `json:"audit_log_storage_dir,omitempty" yaml:"audit_log_storage_dir,omitempty"`
+	AuditLogDirMode        string `json:"audit_log_dir_mode" yaml:"audit_log_dir_mode"`
+
+	UploadKeepFiles bool   `json:"upload_keep_files" yaml:"upload_keep_files"`
+	UploadFileMode  string `json:"upload_file_mode" yaml:"upload_file_mode"`
+	UploadFileLimit int    `json:"upload_file_limit" yaml:"upload_file_limit"`
+	UploadDir       string `json:"upload_dir,omitempty" yaml:"upload_dir,omitempty"`
+	TmpDir          string `json:"tmp_dir,omitempty" yaml:"tmp_dir,omitempty"`
+	DataDir         string `json:"data_dir,omitempty" yaml:"data_dir,omitempty"`
+
+	WebAppID                      string            `json:"web_app_id,omitempty" yaml:"web_app_id,omitempty"`
+	SensorID                      string            `json:"sensor_id,omitempty" yaml:"sensor_id,omitempty"`
+	ServerSignature               string            `json:"server_signature,omitempty" yaml:"server_signature,omitempty"`
+	ComponentNames                []string          `json:"component_names,omitempty" yaml:"component_names,omitempty"`
+	Labels                        map[string]string `json:"labels,omitempty" yaml:"labels,omitempty"`
+	AbortOnRemoteRulesFail        bool              `json:"abort_on_remote_rules_fail" yaml:"abort_on_remote_rules_fail"`
+	DetectDuplicateTransactionIDs bool              `json:"detect_duplicate_transaction_ids" yaml:"detect_duplicate_transaction_ids"`
+	DependencyFailureModes        map[string]string `json:"dependency_failure_modes,omitempty" yaml:"dependency_failure_modes,omitempty"`
+	StreamInBodyInspection        bool              `json:"stream_in_body_inspection" yaml:"stream_in_body_inspection"`
+	StreamOutBodyInspection       bool              `json:"stream_out_body_inspection" yaml:"stream_out_body_inspection"`
+	GuardianLog                   bool              `json:"guardian_log" yaml:"guardian_log"`
+	RulePerfTime                  int64             `json:"rule_perf_time" yaml:"rule_perf_time"`
+	RxInputLimit                  int               `json:"rx_input_limit" yaml:"rx_input_limit"`
+	RxTimeLimit                   int64             `json:"rx_time_limit" yaml:"rx_time_limit"`
+	CollectionTimeout             int64             `json:"collection_timeout" yaml:"collection_timeout"`
+	TransactionWatchdogTimeout    int64             `json:"transaction_watchdog_timeout" yaml:"transaction_watchdog_timeout"`
+	PanicDump                     bool              `json:"panic_dump" yaml:"panic_dump"`
+	PanicDumpRecover              bool              `json:"panic_dump_recover" yaml:"panic_dump_recover"`
+	ConnEngine                    string            `json:"conn_engine" yaml:"conn_engine"`
+	SecurityHeadersEngine         string            `json:"security_headers_engine" yaml:"security_headers_engine"`
+	ConnReadStateLimit            int               `json:"conn_read_state_limit" yaml:"conn_read_state_limit"`
+	ConnWriteStateLimit           int               `json:"conn_write_state_limit" yaml:"conn_write_state_limit"`
+	HashEngine                    bool              `json:"hash_engine" yaml:"hash_engine"`
+	HashParam                     string            `json:"hash_param,omitempty" yaml:"hash_param,omitempty"`
+}
+
+// EffectiveConfig returns the settings the WAF ended up with, it is meant
+// to be called once the rules are parsed.
+func (w *WAF) EffectiveConfig() EffectiveConfig {
+	c := EffectiveConfig{
+		RuleEngine: w.RuleEngine.String(),
+		Rules:      w.Rules.Count(),
+
+		RequestBodyAccess:           w.RequestBodyAccess,
+		RequestBodyLimit:            w.RequestBodyLimit,
+		RequestBodyInMemoryLimit:    w.RequestBodyLimit,
+		RequestBodyNoFilesLimit:     w.RequestBodyNoFilesLimit,
+		RequestBodyLimitAction:      bodyLimitActionString(w.RequestBodyLimitAction),
+		RequestBodyLimitMax:         w.requestBodyLimitMax(),
+		RequestBodyUnexpectedAction: unexpectedRequestBodyActionString(w.UnexpectedRequestBodyAction),
+
+		ResponseBodyAccess:      w.ResponseBodyAccess,
+		ResponseBodyLimit:       w.ResponseBodyLimit,
+		ResponseBodyLimitAction: bodyLimitActionString(w.ResponseBodyLimitAction),
+		ResponseBodyLimitMax:    w.responseBodyLimitMax(),
+		ResponseBodyMimeTypes:   slices.Clone(w.ResponseBodyMimeTypes),
+
+		ArgumentLimit:         w.ArgumentLimit,
+		ArgumentSeparator:     w.ArgumentSeparator,
+		FlattenArrayArguments: w.FlattenArrayArguments,
+		XMLExternalEntity:     w.XMLExternalEntity,
+		RuleMatchLimit:        w.RuleMatchLimit,
+		CaptureLimit:          w.CaptureLimit,
+
+		TransactionMemoryLimit:       w.TransactionMemoryLimit,
+		TransactionMemoryLimitAction: "Log",
+
+		AuditEngine:        auditEngineString(w.AuditEngine),
+		AuditLogParts:      auditLogPartsString(w.AuditLogParts),
+		AuditLogFormat:     w.AuditLogFormat,
+		AuditLog:           w.AuditLogWriterConfig.Target,
+		AuditLogFileMode:   fmt.Sprintf("%04o", uint32(w.AuditLogWriterConfig.FileMode)),
+		AuditLogStorageDir: w.AuditLogWriterConfig.Dir,
+		AuditLogDirMode:    fmt.Sprintf("%04o", uint32(w.AuditLogWriterConfig.DirMode)),
+
+		UploadKeepFiles: w.UploadKeepFiles,
+		UploadFileMode:  fmt.Sprintf("%04o", uint32(w.UploadFileMode)),
+		UploadFileLimit: w.UploadFileLimit,
+		UploadDir:       w.UploadDir,
+		TmpDir:          w.TmpDir,
+		DataDir:         w.DataDir,
+
+		WebAppID:                      w.WebAppID,
+		SensorID:                      w.SensorID,
+		ServerSignature:               w.ServerSignature,
+		ComponentNames:                w.ComponentSignatures(),
+		Labels:                        maps.Clone(w.labels),
+		AbortOnRemoteRulesFail:        w.AbortOnRemoteRulesFail,
+		DetectDuplicateTransactionIDs: w.DetectDuplicateTransactionIDs,
+		StreamInBodyInspection:        w.StreamInBodyInspection,
+		StreamOutBodyInspection:       w.StreamOutBodyInspection,
+		GuardianLog:                   w.GuardianLog != nil,
+		RulePerfTime:                  w.RulePerfTime.Microseconds(),
+		RxInputLimit:                  w.RxBudget.InputLimit,
+		RxTimeLimit:                   w.RxBudget.TimeLimit.Microseconds(),
+		CollectionTimeout:             int64(w.collectionTimeout().Seconds()),
+		TransactionWatchdogTimeout:    int64(w.TransactionWatchdogTimeout.Seconds()),
+		PanicDump:                     w.PanicDump != nil,
+		PanicDumpRecover:              w.PanicDump != nil && w.PanicDump.Recover,
+		ConnEngine:                    w.ConnEngine.String(),
+		SecurityHeadersEngine:         w.SecurityHeadersEngine.String(),
+		ConnReadStateLimit:            w.ConnReadStateLimit.Limit,
+		ConnWriteStateLimit:           w.ConnWriteStateLimit.Limit,
+		HashEngine:                    w.HashEngine,
+	}
+	if w.TransactionMemoryLimitReject {
+		c.TransactionMemoryLimitAction = "Reject"
+	}
+	if w.HashEngine {
+		c.HashParam = defaultHashParam
+		if w.HashParam != "" {
+			c.HashParam = w.HashParam
+		}
+	}
+	// the in memory limit defaults to the request body limit, like transactions do
+	if w.requestBodyInMemoryLimit != nil {
+		c.RequestBodyInMemoryLimit = *w.requestBodyInMemoryLimit
+	}
+	if w.AuditLogRelevantStatus != nil {
+		c.AuditLogRelevantStatus = w.AuditLogRelevantStatus.String()
+	}
+	if w.UnicodeMap != nil {
+		c.UnicodeCodePage = w.UnicodeMap.CodePage()
+	}
+	if len(w.dependencyFailureModes) > 0 {
+		c.DependencyFailureModes = make(map[string]string, len(w.dependencyFailureModes))
+		for feature, mode := range w.dependencyFailureModes {
+			c.DependencyFailureModes[feature] = dependencyFailureModeString(mode)
+		}
+	}
+	return c
+}
+
+func bodyLimitActionString(a types.BodyLimitAction) string {
+	if a == types.BodyLimitActionReject {
+		return "Reject"
+	}
+	return "ProcessPartial"
+}
+
+func unexpectedRequestBodyActionString(a UnexpectedRequestBodyAction) string {
+	if a == UnexpectedRequestBodyIgnore {
+		return "Ignore"
+	}
+	return "Inspect"
+}
+
+func auditEngineString(s types.AuditEngineStatus) string {
+	switch s {
+	case types.AuditEngineOn:
+		return "On"
+	case types.AuditEngineRelevantOnly:
+		return "RelevantOnly"
+	}
+	return "Off"
+}
+
+// auditLogPartsString returns the parts as SecAuditLogParts expects them,
+// the mandatory A and Z parts are not stored in the parts
+func auditLogPartsString(parts types.AuditLogParts) string {
+	var sb strings.Builder
+	sb.WriteByte('A')
+	for _, p := range parts {
+		sb.WriteByte(byte(p))
+	}
+	sb.WriteByte('Z')
+	return sb.String()
+}
+
+func dependencyFailureModeString(m DependencyFailureMode) string {
+	if m == DependencyFailClosed {
+		return "FailClosed"
+	}
+	return "FailOpen"
+}
@@ -0,0 +1,71 @@
+// Copyright 2024 Juan Pablo Tosso and the OWASP Coraza contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package corazawaf
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/corazawaf/coraza/v3/types"
+)
+
+func TestEffectiveConfig(t *testing.T) {
+	waf := NewWAF()
+	waf.RuleEngine = types.RuleEngineDetectionOnly
+	waf.RequestBodyLimit = 1000
+	waf.RequestBodyLimitAction = types.BodyLimitActionReject
+	waf.SetLabel("cluster", "eu-west-1")
+	waf.SetDependencyFailureMode("rbl", DependencyFailClosed)
+	rule := NewRule()
+	rule.ID_ = 1
+	if err := waf.Rules.Add(rule); err != nil {
+		t.Fatal(err)
+	}
+
+	c := waf.EffectiveConfig()
+	if want, have := "DetectionOnly", c.RuleEngine; want != have {
+		t.Errorf("unexpected rule engine, want %q, have %q", want, have)
+	}
+	if want, have := 1, c.Rules; want != have {
+		t.Errorf("unexpected rules, want %d, have %d", want, have)
+	}
+	// the in memory limit defaults to the request body limit
+	if want, have := int64(1000), c.RequestBodyInMemoryLimit; want != have {
+		t.Errorf("unexpected request body in memory limit, want %d, have %d", want, have)
+	}
+	if want, have := "Reject", c.RequestBodyLimitAction; want != have {
+		t.Errorf("unexpected request body limit action, want %q, have %q", want, have)
+	}
+	if want, have := "ABCFHZ", c.AuditLogParts; want != have {
+		t.Errorf("unexpected audit log parts, want %q, have %q", want, have)
+	}
+	if want, have := "FailClosed", c.DependencyFailureModes["rbl"]; want != have {
+		t.Errorf("unexpected dependency failure mode, want %q, have %q", want, have)
+	}
+
+	waf.SetRequestBodyInMemoryLimit(500)
+	waf.SetLabel("cluster", "us-east-1")
+	if want, have := int64(500), waf.EffectiveConfig().RequestBodyInMemoryLimit; want != have {
+		t.Errorf("unexpected request body in memory limit, want %d, have %d", want, have)
+	}
+	// the exported config must not change with the WAF
+	if want, have := "eu-west-1", c.Labels["cluster"]; want != have {
+		t.Errorf("unexpected label, want %q, have %q", want, have)
+	}
+
+	data, err := json.Marshal(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var doc map[string]interface{}
+	if err := json.Unmarshal(data, &doc); err != nil {
+		t.Fatal(err)
+	}
+	if want, have := float64(1000), doc["argument_limit"]; want != have {
+		t.Errorf("unexpected argument_limit, want %v, have %v", want, have)
+	}
+	if want, have := "DetectionOnly", doc["rule_engine"]; want != have {
+		t.Errorf("unexpected rule_engine, want %v, have %v", want, have)
+	}
+}
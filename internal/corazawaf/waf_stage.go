@@ -0,0 +1,120 @@
+// Copyright 2024 Juan Pablo Tosso and the OWASP Coraza contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package corazawaf
+
+import (
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+)
+
+// Stage returns a WAF holding a copy of the configuration and the rules of
+// the WAF, the changes made to it, e.g. by compiling directives, are applied
+// to the WAF with Apply. The staged WAF doesn't share the transactions and
+// the connections of the WAF.
+func (w *WAF) Stage() *WAF {
+	staged := NewWAF()
+	staged.copyConfig(w)
+	staged.stagedFrom = w
+	return staged
+}
+
+// Apply replaces the configuration and the rules of the WAF with the ones of
+// a WAF returned by Stage, the transactions and the connections of the WAF
+// are kept, the values shared by its operators are dropped. The guardian log
+// and the audit log writer replaced by the staged ones are closed. It must
+// not be called while the WAF evaluates transactions.
+func (w *WAF) Apply(staged *WAF) {
+	var errs []error
+	if w.GuardianLog != nil && w.GuardianLog != staged.GuardianLog {
+		if err := w.GuardianLog.Close(); err != nil {
+			errs = append(errs, fmt.Errorf("closing guardian log: %v", err))
+		}
+	}
+	if w.auditLogWriterInitialized && w.auditLogWriter != staged.auditLogWriter {
+		if err := w.auditLogWriter.Close(); err != nil {
+			errs = append(errs, fmt.Errorf("closing audit log writer: %v", err))
+		}
+	}
+	if err := errors.Join(errs...); err != nil {
+		w.Logger.Error().Err(err).Msg("Failed to close the replaced resources of the WAF")
+	}
+	w.copyConfig(staged)
+	w.operatorValues.reset()
+}
+
+// Discard releases the resources opened by a WAF returned by Stage whose
+// changes are not applied, e.g. the guardian log of a SecGuardianLog
+// directive, the ones shared with the WAF it was staged from are kept
+func (w *WAF) Discard() error {
+	var errs []error
+	if w.GuardianLog != nil && !w.inheritsGuardianLog(w.GuardianLog) {
+		if err := w.GuardianLog.Close(); err != nil {
+			errs = append(errs, fmt.Errorf("closing guardian log: %v", err))
+		}
+	}
+	if w.auditLogWriterInitialized && (w.stagedFrom == nil || w.auditLogWriter != w.stagedFrom.auditLogWriter) {
+		if err := w.auditLogWriter.Close(); err != nil {
+			errs = append(errs, fmt.Errorf("closing audit log writer: %v", err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// inheritsGuardianLog returns whether g is the guardian log of the WAF the
+// WAF was staged from, which must not be closed by the staged WAF
+func (w *WAF) inheritsGuardianLog(g *GuardianLog) bool {
+	return w.stagedFrom != nil && w.stagedFrom.GuardianLog == g
+}
+
+// copyConfig copies the configuration and the rules of from, the slices and
+// maps changed by the directives are cloned so the WAFs don't share them
+func (w *WAF) copyConfig(from *WAF) {
+	state := w.wafState
+	*w = *from
+	w.wafState = state
+	w.Rules = from.Rules.clone()
+	w.ResponseBodyMimeTypes = slices.Clone(from.ResponseBodyMimeTypes)
+	w.labels = maps.Clone(from.labels)
+	w.labelFields = slices.Clone(from.labelFields)
+	w.AuditLogParts = slices.Clone(from.AuditLogParts)
+	w.Producer.Rulesets = slices.Clone(from.Producer.Rulesets)
+	w.HashKey = slices.Clone(from.HashKey)
+	w.HashMethods = slices.Clone(from.HashMethods)
+	w.MessageCatalog = maps.Clone(from.MessageCatalog)
+	w.securityHeaderDefaults = maps.Clone(from.securityHeaderDefaults)
+	w.dependencyFailureModes = maps.Clone(from.dependencyFailureModes)
+	w.datasets = maps.Clone(from.datasets)
+}
+
+// clone returns a copy of the group whose rules can be changed without
+// changing the rules of the group
+func (rg *RuleGroup) clone() RuleGroup {
+	c := RuleGroup{rules: make([]Rule, len(rg.rules))}
+	for i := range rg.rules {
+		rg.rules[i].cloneInto(&c.rules[i])
+	}
+	c.invalidateTagIndex()
+	return c
+}
+
+// cloneInto copies the rule and its chain into c, the slices changed by the
+// directives updating rules are cloned
+func (r *Rule) cloneInto(c *Rule) {
+	*c = *r
+	c.Tags_ = slices.Clone(r.Tags_)
+	c.variables = slices.Clone(r.variables)
+	for i := range c.variables {
+		c.variables[i].Exceptions = slices.Clone(r.variables[i].Exceptions)
+	}
+	c.transformations = slices.Clone(r.transformations)
+	c.actions = slices.Clone(r.actions)
+	c.activeWindows = slices.Clone(r.activeWindows)
+	c.initializedActions = slices.Clone(r.initializedActions)
+	if r.Chain != nil {
+		c.Chain = &Rule{}
+		r.Chain.cloneInto(c.Chain)
+	}
+}
@@ -4,6 +4,8 @@
 package corazawaf
 
 import (
+	"bytes"
+	"errors"
 	"io"
 	"os"
 	"testing"
@@ -105,6 +107,10 @@ func TestValidate(t *testing.T) {
 			expectErr:  true,
 			customizer: func(w *WAF) { w.ArgumentLimit = -1 },
 		},
+		"capture limit equal to 0": {
+			expectErr:  true,
+			customizer: func(w *WAF) { w.CaptureLimit = 0 },
+		},
 	}
 
 	for name, tCase := range testCases {
@@ -124,3 +130,151 @@ func TestValidate(t *testing.T) {
 		})
 	}
 }
+
+type closeRecorder struct {
+	io.Writer
+	closed bool
+}
+
+func (c *closeRecorder) Close() error {
+	c.closed = true
+	return nil
+}
+
+func TestWAFClose(t *testing.T) {
+	waf := NewWAF()
+	guardian := &closeRecorder{Writer: io.Discard}
+	waf.GuardianLog = NewGuardianLog(func() (io.Writer, error) { return guardian, nil })
+
+	tx := waf.NewTransaction()
+	tx.ProcessLogging()
+	if err := waf.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if guardian.closed {
+		t.Error("unexpected guardian log closed with a transaction in flight")
+	}
+	if err := tx.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if !guardian.closed {
+		t.Error("expected guardian log closed once the last transaction is closed")
+	}
+
+	// a WAF without transactions in flight is closed right away
+	waf = NewWAF()
+	guardian = &closeRecorder{Writer: io.Discard}
+	waf.GuardianLog = NewGuardianLog(func() (io.Writer, error) { return guardian, nil })
+	tx = waf.NewTransaction()
+	tx.ProcessLogging()
+	if err := tx.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := waf.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if !guardian.closed {
+		t.Error("expected guardian log closed")
+	}
+
+	// a closing WAF does not create transactions anymore
+	if _, ok := waf.TryNewTransactionWithOptions(Options{}); ok {
+		t.Error("unexpected transaction created by a closed WAF")
+	}
+	if n := waf.transactions.Load(); n != 0 {
+		t.Errorf("unexpected transactions in flight: %d", n)
+	}
+	tx, ok := NewWAF().TryNewTransactionWithOptions(Options{ID: "abc"})
+	if !ok || tx.ID() != "abc" {
+		t.Error("expected transaction created by an open WAF")
+	}
+}
+
+func TestWAFStageResources(t *testing.T) {
+	newGuardian := func() (*GuardianLog, *closeRecorder) {
+		r := &closeRecorder{Writer: io.Discard}
+		g := NewGuardianLog(func() (io.Writer, error) { return r, nil })
+		// the writer is opened by the first line
+		if err := g.write("line\n"); err != nil {
+			t.Fatal(err)
+		}
+		return g, r
+	}
+
+	waf := NewWAF()
+	current, currentRecorder := newGuardian()
+	waf.GuardianLog = current
+
+	// a rolled back stage closes the guardian logs it opened only
+	staged := waf.Stage()
+	first, firstRecorder := newGuardian()
+	staged.SetGuardianLog(first)
+	if currentRecorder.closed {
+		t.Error("unexpected guardian log of the WAF closed by the staged WAF")
+	}
+	second, secondRecorder := newGuardian()
+	staged.SetGuardianLog(second)
+	if !firstRecorder.closed {
+		t.Error("expected the replaced staged guardian log closed")
+	}
+	if err := staged.Discard(); err != nil {
+		t.Fatal(err)
+	}
+	if !secondRecorder.closed {
+		t.Error("expected the staged guardian log closed by Discard")
+	}
+	if currentRecorder.closed || waf.GuardianLog != current {
+		t.Error("unexpected guardian log of the WAF changed by Discard")
+	}
+
+	// an applied stage closes the guardian log it replaces
+	staged = waf.Stage()
+	replacement, replacementRecorder := newGuardian()
+	staged.SetGuardianLog(replacement)
+	waf.Apply(staged)
+	if !currentRecorder.closed {
+		t.Error("expected the replaced guardian log closed by Apply")
+	}
+	if replacementRecorder.closed || waf.GuardianLog != replacement {
+		t.Error("expected the staged guardian log kept by Apply")
+	}
+}
+
+type failingWriter struct {
+	closeRecorder
+}
+
+func (*failingWriter) Write([]byte) (int, error) {
+	return 0, errors.New("broken pipe")
+}
+
+func TestGuardianLogReopen(t *testing.T) {
+	broken := &failingWriter{}
+	var out bytes.Buffer
+	opens := 0
+	g := NewGuardianLog(func() (io.Writer, error) {
+		opens++
+		if opens == 1 {
+			return broken, nil
+		}
+		return &out, nil
+	})
+	if err := g.write("dropped\n"); err != nil {
+		t.Fatal(err)
+	}
+	if err := g.write("written\n"); err != nil {
+		t.Fatal(err)
+	}
+	if err := g.Close(); err == nil {
+		t.Error("expected the dropped line reported")
+	}
+	if !broken.closed {
+		t.Error("expected the failed writer closed")
+	}
+	if want, have := 2, opens; want != have {
+		t.Errorf("unexpected opens, want %d, have %d", want, have)
+	}
+	if want, have := "written\n", out.String(); want != have {
+		t.Errorf("unexpected guardian log, want %q, have %q", want, have)
+	}
+}
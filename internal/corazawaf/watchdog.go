@@ -0,0 +1,58 @@
+// Copyright 2024 Juan Pablo Tosso and the OWASP Coraza contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package corazawaf
+
+import (
+	"sync/atomic"
+	"time"
+
+	"github.com/corazawaf/coraza/v3/debuglog"
+)
+
+// txWatchdog logs a transaction that is not closed within the
+// TransactionWatchdogTimeout of the WAF, e.g. leaked by a connector. It doesn't
+// release the transaction, which is not safe for concurrent use.
+type txWatchdog struct {
+	timer *time.Timer
+	// logger is the logger of the transaction when the watchdog started,
+	// the transaction fields can't be read from the timer goroutine
+	logger debuglog.Logger
+	// phase is the last phase evaluated, it is kept here for the same reason
+	phase atomic.Int32
+}
+
+// startWatchdog watches the transaction until it is closed
+func (tx *Transaction) startWatchdog(timeout time.Duration) {
+	wd := &txWatchdog{logger: tx.debugLogger}
+	started := time.Now()
+	wd.timer = time.AfterFunc(timeout, func() {
+		wd.expire(tx.WAF, started)
+	})
+	tx.watchdog = wd
+}
+
+// stopWatchdog stops watching the transaction
+func (tx *Transaction) stopWatchdog() {
+	if wd := tx.watchdog; wd != nil {
+		wd.timer.Stop()
+		tx.watchdog = nil
+	}
+}
+
+// expire runs in the timer goroutine once the transaction is not closed within
+// the timeout
+func (wd *txWatchdog) expire(w *WAF, started time.Time) {
+	wd.logger.Warn().
+		Str("age", time.Since(started).String()).
+		Int("last_phase", int(wd.phase.Load())).
+		Msg("Transaction not closed within the watchdog timeout, it may have been leaked by the connector")
+	// counted once done, so the counter can be used to wait for the watchdog
+	w.leakedTransactions.Add(1)
+}
+
+// LeakedTransactions returns the number of transactions not closed within
+// TransactionWatchdogTimeout since the WAF was created
+func (w *WAF) LeakedTransactions() int64 {
+	return w.leakedTransactions.Load()
+}
@@ -0,0 +1,79 @@
+// Copyright 2024 Juan Pablo Tosso and the OWASP Coraza contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package corazawaf
+
+import (
+	"testing"
+	"time"
+)
+
+// waitFor polls cond until it returns true or the test times out
+func waitFor(t *testing.T, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatal("timed out waiting for the watchdog")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestTransactionWatchdog(t *testing.T) {
+	t.Run("closed in time", func(t *testing.T) {
+		waf := NewWAF()
+		waf.TransactionWatchdogTimeout = time.Hour
+		tx := waf.NewTransaction()
+		if tx.watchdog == nil {
+			t.Fatal("expected the transaction to be watched")
+		}
+		if err := tx.Close(); err != nil {
+			t.Fatal(err)
+		}
+		if want, have := int64(0), waf.LeakedTransactions(); want != have {
+			t.Errorf("unexpected leaked transactions, want %d, have %d", want, have)
+		}
+	})
+
+	t.Run("log", func(t *testing.T) {
+		waf := NewWAF()
+		waf.TransactionWatchdogTimeout = 10 * time.Millisecond
+		tx := waf.NewTransaction()
+		waitFor(t, func() bool { return waf.LeakedTransactions() == 1 })
+		if err := tx.Close(); err != nil {
+			t.Errorf("unexpected error closing a transaction the watchdog only logged: %v", err)
+		}
+	})
+
+	t.Run("resources kept", func(t *testing.T) {
+		waf := NewWAF()
+		waf.TransactionWatchdogTimeout = 10 * time.Millisecond
+		tx := waf.NewTransaction()
+		tx.RequestBodyAccess = true
+		if _, _, err := tx.WriteRequestBody([]byte("leaked body")); err != nil {
+			t.Fatal(err)
+		}
+		waitFor(t, func() bool { return waf.LeakedTransactions() == 1 })
+		// the transaction may still be in use, it keeps working
+		if want, have := int64(len("leaked body")), tx.requestBodyBuffer.length; want != have {
+			t.Errorf("unexpected request body length, want %d, have %d", want, have)
+		}
+		if it := tx.ProcessRequestHeaders(); it != nil {
+			t.Errorf("unexpected interruption %v", it)
+		}
+		if err := tx.Close(); err != nil {
+			t.Fatal(err)
+		}
+	})
+
+	t.Run("disabled", func(t *testing.T) {
+		tx := NewWAF().NewTransaction()
+		if tx.watchdog != nil {
+			t.Error("unexpected watchdog")
+		}
+		if err := tx.Close(); err != nil {
+			t.Fatal(err)
+		}
+	})
+}
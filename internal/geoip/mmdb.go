@@ -0,0 +1,390 @@
+// Copyright 2024 Juan Pablo Tosso and the OWASP Coraza contributors
+// SPDX-License-Identifier: Apache-2.0
+
+// Package geoip reads MaxMind DB (MMDB) files, like GeoLite2-City, to
+// resolve addresses to the keys of the GEO collection.
+// See https://maxmind.github.io/MaxMind-DB/ for the format specification.
+package geoip
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"net"
+	"strconv"
+)
+
+// metadataMarker precedes the metadata section at the end of the file
+var metadataMarker = []byte("\xab\xcd\xefMaxMind.com")
+
+// dataSectionSeparator is the size of the zeroed bytes between the search
+// tree and the data section
+const dataSectionSeparator = 16
+
+// maxDepth bounds the nesting of maps and arrays to protect against
+// malformed files
+const maxDepth = 32
+
+// Reader looks up addresses in a MaxMind DB loaded in memory.
+// It is safe for concurrent use.
+type Reader struct {
+	tree       []byte
+	section    []byte
+	nodeCount  uint
+	recordSize uint
+	ipVersion  uint
+	// ipv4Start is the node where IPv4 lookups start in an IPv6 tree
+	ipv4Start uint
+	dbType    string
+}
+
+// Open parses the metadata of a MaxMind DB, data must not be modified
+// while the reader is in use.
+func Open(data []byte) (*Reader, error) {
+	i := bytes.LastIndex(data, metadataMarker)
+	if i < 0 {
+		return nil, errors.New("invalid MaxMind DB: metadata not found")
+	}
+	d := decoder{data: data[i+len(metadataMarker):]}
+	v, _, err := d.decode(0, 0)
+	if err != nil {
+		return nil, fmt.Errorf("invalid MaxMind DB metadata: %s", err.Error())
+	}
+	meta, ok := v.(map[string]interface{})
+	if !ok {
+		return nil, errors.New("invalid MaxMind DB metadata: not a map")
+	}
+
+	r := &Reader{}
+	r.nodeCount = uint(toUint(meta["node_count"]))
+	r.recordSize = uint(toUint(meta["record_size"]))
+	r.ipVersion = uint(toUint(meta["ip_version"]))
+	r.dbType, _ = meta["database_type"].(string)
+	if major := toUint(meta["binary_format_major_version"]); major != 2 {
+		return nil, fmt.Errorf("unsupported MaxMind DB format version %d", major)
+	}
+	if r.recordSize != 24 && r.recordSize != 28 && r.recordSize != 32 {
+		return nil, fmt.Errorf("unsupported MaxMind DB record size %d", r.recordSize)
+	}
+	if r.ipVersion != 4 && r.ipVersion != 6 {
+		return nil, fmt.Errorf("unsupported MaxMind DB ip version %d", r.ipVersion)
+	}
+
+	treeSize := r.nodeCount * r.recordSize / 4
+	if treeSize+dataSectionSeparator > uint(i) {
+		return nil, errors.New("invalid MaxMind DB: search tree out of bounds")
+	}
+	r.tree = data[:treeSize]
+	r.section = data[treeSize+dataSectionSeparator : i]
+
+	if r.ipVersion == 6 {
+		// IPv4 addresses are stored as ::a.b.c.d, we skip the first 96 bits once
+		node := uint(0)
+		for b := 0; b < 96 && node < r.nodeCount; b++ {
+			node = r.record(node, 0)
+		}
+		r.ipv4Start = node
+	}
+	return r, nil
+}
+
+// DatabaseType returns the type of the database, e.g. GeoLite2-City
+func (r *Reader) DatabaseType() string {
+	return r.dbType
+}
+
+// Lookup returns the GEO collection fields of the address and false if the
+// address is not in the database
+func (r *Reader) Lookup(ip net.IP) (map[string]string, bool, error) {
+	v, found, err := r.lookup(ip)
+	if err != nil || !found {
+		return nil, found, err
+	}
+	m, ok := v.(map[string]interface{})
+	if !ok {
+		return nil, false, errors.New("unexpected MaxMind DB record, expected a map")
+	}
+	return geoFields(m), true, nil
+}
+
+// lookup returns the decoded record of the address
+func (r *Reader) lookup(ip net.IP) (interface{}, bool, error) {
+	node := uint(0)
+	bits := 128
+	if ip4 := ip.To4(); ip4 != nil {
+		ip = ip4
+		bits = 32
+		if r.ipVersion == 6 {
+			node = r.ipv4Start
+		}
+	} else if ip = ip.To16(); ip == nil {
+		return nil, false, errors.New("invalid ip address")
+	} else if r.ipVersion == 4 {
+		return nil, false, nil
+	}
+
+	for i := 0; i < bits && node < r.nodeCount; i++ {
+		bit := uint(ip[i>>3]>>(7-uint(i&7))) & 1
+		node = r.record(node, bit)
+	}
+	if node == r.nodeCount {
+		return nil, false, nil
+	}
+	if node < r.nodeCount {
+		return nil, false, errors.New("invalid MaxMind DB: search tree too deep")
+	}
+	offset := node - r.nodeCount - dataSectionSeparator
+	if offset >= uint(len(r.section)) {
+		return nil, false, errors.New("invalid MaxMind DB: record out of bounds")
+	}
+	d := decoder{data: r.section}
+	v, _, err := d.decode(offset, 0)
+	if err != nil {
+		return nil, false, err
+	}
+	return v, true, nil
+}
+
+// record returns the left (bit 0) or right (bit 1) record of the node
+func (r *Reader) record(node uint, bit uint) uint {
+	switch r.recordSize {
+	case 24:
+		b := r.tree[node*6+bit*3:]
+		return uint(b[0])<<16 | uint(b[1])<<8 | uint(b[2])
+	case 28:
+		b := r.tree[node*7:]
+		if bit == 0 {
+			return uint(b[3]&0xf0)<<20 | uint(b[0])<<16 | uint(b[1])<<8 | uint(b[2])
+		}
+		return uint(b[3]&0x0f)<<24 | uint(b[4])<<16 | uint(b[5])<<8 | uint(b[6])
+	default:
+		return uint(binary.BigEndian.Uint32(r.tree[node*8+bit*4:]))
+	}
+}
+
+// geoFields maps a GeoIP2/GeoLite2 record to the GEO collection keys
+func geoFields(m map[string]interface{}) map[string]string {
+	fields := map[string]string{}
+	set := func(key string, v interface{}) {
+		switch v := v.(type) {
+		case string:
+			if v != "" {
+				fields[key] = v
+			}
+		case float64:
+			fields[key] = strconv.FormatFloat(v, 'f', -1, 64)
+		case uint64:
+			fields[key] = strconv.FormatUint(v, 10)
+		case int64:
+			fields[key] = strconv.FormatInt(v, 10)
+		}
+	}
+	set("COUNTRY_CODE", path(m, "country", "iso_code"))
+	set("COUNTRY_NAME", path(m, "country", "names", "en"))
+	set("COUNTRY_CONTINENT", path(m, "continent", "code"))
+	if subdivisions, ok := m["subdivisions"].([]interface{}); ok && len(subdivisions) > 0 {
+		if sub, ok := subdivisions[0].(map[string]interface{}); ok {
+			set("REGION", path(sub, "iso_code"))
+		}
+	}
+	set("CITY", path(m, "city", "names", "en"))
+	set("POSTAL_CODE", path(m, "postal", "code"))
+	set("LATITUDE", path(m, "location", "latitude"))
+	set("LONGITUDE", path(m, "location", "longitude"))
+	set("DMA_CODE", path(m, "location", "metro_code"))
+	return fields
+}
+
+// path returns the value of nested maps
+func path(m map[string]interface{}, keys ...string) interface{} {
+	var v interface{} = m
+	for _, k := range keys {
+		mm, ok := v.(map[string]interface{})
+		if !ok {
+			return nil
+		}
+		v = mm[k]
+	}
+	return v
+}
+
+func toUint(v interface{}) uint64 {
+	if u, ok := v.(uint64); ok {
+		return u
+	}
+	return 0
+}
+
+// decoder decodes the MaxMind DB data section format
+type decoder struct {
+	data []byte
+}
+
+var errOutOfBounds = errors.New("invalid MaxMind DB: data out of bounds")
+
+// decode returns the value at the offset and the offset of the next value
+func (d *decoder) decode(offset uint, depth int) (interface{}, uint, error) {
+	if depth > maxDepth {
+		return nil, 0, errors.New("invalid MaxMind DB: data nested too deep")
+	}
+	typ, size, offset, err := d.control(offset)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	if typ == 1 {
+		// pointers are resolved in place, the next value follows the pointer
+		pointer, next, err := d.pointer(size, offset)
+		if err != nil {
+			return nil, 0, err
+		}
+		v, _, err := d.decode(pointer, depth+1)
+		return v, next, err
+	}
+
+	switch typ {
+	case 7: // map
+		m := make(map[string]interface{}, min(size, 64))
+		for i := uint(0); i < size; i++ {
+			var k, v interface{}
+			k, offset, err = d.decode(offset, depth+1)
+			if err != nil {
+				return nil, 0, err
+			}
+			key, ok := k.(string)
+			if !ok {
+				return nil, 0, errors.New("invalid MaxMind DB: map key is not a string")
+			}
+			v, offset, err = d.decode(offset, depth+1)
+			if err != nil {
+				return nil, 0, err
+			}
+			m[key] = v
+		}
+		return m, offset, nil
+	case 11: // array
+		a := make([]interface{}, 0, min(size, 64))
+		for i := uint(0); i < size; i++ {
+			var v interface{}
+			v, offset, err = d.decode(offset, depth+1)
+			if err != nil {
+				return nil, 0, err
+			}
+			a = append(a, v)
+		}
+		return a, offset, nil
+	case 14: // boolean, the value is the size
+		return size != 0, offset, nil
+	}
+
+	if offset+size > uint(len(d.data)) {
+		return nil, 0, errOutOfBounds
+	}
+	b := d.data[offset : offset+size]
+	next := offset + size
+	switch typ {
+	case 2: // utf8 string
+		return string(b), next, nil
+	case 3: // double
+		if size != 8 {
+			return nil, 0, errors.New("invalid MaxMind DB: double size")
+		}
+		return math.Float64frombits(binary.BigEndian.Uint64(b)), next, nil
+	case 15: // float
+		if size != 4 {
+			return nil, 0, errors.New("invalid MaxMind DB: float size")
+		}
+		return float64(math.Float32frombits(binary.BigEndian.Uint32(b))), next, nil
+	case 4: // bytes
+		return append([]byte(nil), b...), next, nil
+	case 5, 6, 9: // uint16, uint32, uint64
+		if size > 8 {
+			return nil, 0, errors.New("invalid MaxMind DB: unsigned integer size")
+		}
+		var u uint64
+		for _, c := range b {
+			u = u<<8 | uint64(c)
+		}
+		return u, next, nil
+	case 8: // int32
+		if size > 4 {
+			return nil, 0, errors.New("invalid MaxMind DB: int32 size")
+		}
+		var u uint32
+		for _, c := range b {
+			u = u<<8 | uint32(c)
+		}
+		return int64(int32(u)), next, nil
+	case 10: // uint128, only kept as bytes as no GEO field uses it
+		return append([]byte(nil), b...), next, nil
+	}
+	return nil, 0, fmt.Errorf("invalid MaxMind DB: unknown data type %d", typ)
+}
+
+// control parses the control byte at the offset and returns the type, the
+// size (or the pointer bits) and the offset of the payload
+func (d *decoder) control(offset uint) (typ uint, size uint, next uint, err error) {
+	if offset >= uint(len(d.data)) {
+		return 0, 0, 0, errOutOfBounds
+	}
+	ctrl := d.data[offset]
+	offset++
+	typ = uint(ctrl >> 5)
+	if typ == 0 {
+		// extended type
+		if offset >= uint(len(d.data)) {
+			return 0, 0, 0, errOutOfBounds
+		}
+		typ = 7 + uint(d.data[offset])
+		offset++
+	}
+	size = uint(ctrl & 0x1f)
+	if typ == 1 {
+		return typ, size, offset, nil
+	}
+	if size >= 29 {
+		n := size - 28
+		if offset+n > uint(len(d.data)) {
+			return 0, 0, 0, errOutOfBounds
+		}
+		var s uint
+		for _, c := range d.data[offset : offset+n] {
+			s = s<<8 | uint(c)
+		}
+		switch size {
+		case 29:
+			size = 29 + s
+		case 30:
+			size = 285 + s
+		default:
+			size = 65821 + s
+		}
+		offset += n
+	}
+	return typ, size, offset, nil
+}
+
+// pointer returns the offset a pointer with the given size bits points to
+// and the offset following the pointer
+func (d *decoder) pointer(bits uint, offset uint) (uint, uint, error) {
+	n := (bits>>3)&3 + 1
+	if offset+n > uint(len(d.data)) {
+		return 0, 0, errOutOfBounds
+	}
+	var p uint
+	if n < 4 {
+		p = bits & 7
+	}
+	for _, c := range d.data[offset : offset+n] {
+		p = p<<8 | uint(c)
+	}
+	switch n {
+	case 2:
+		p += 2048
+	case 3:
+		p += 526336
+	}
+	return p, offset + n, nil
+}
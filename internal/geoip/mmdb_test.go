@@ -0,0 +1,264 @@
+// Copyright 2024 Juan Pablo Tosso and the OWASP Coraza contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package geoip
+
+import (
+	"encoding/binary"
+	"fmt"
+	"maps"
+	"math"
+	"net"
+	"sort"
+	"testing"
+)
+
+var london = map[string]interface{}{
+	"city":      map[string]interface{}{"names": map[string]interface{}{"en": "London"}},
+	"continent": map[string]interface{}{"code": "EU"},
+	"country": map[string]interface{}{
+		"iso_code": "GB",
+		"names":    map[string]interface{}{"en": "United Kingdom", "de": "Vereinigtes Königreich"},
+	},
+	"location": map[string]interface{}{
+		"latitude":   51.5142,
+		"longitude":  -0.0931,
+		"metro_code": uint64(0),
+	},
+	"postal":       map[string]interface{}{"code": "EC2V"},
+	"subdivisions": []interface{}{map[string]interface{}{"iso_code": "ENG"}},
+}
+
+var mountainView = map[string]interface{}{
+	"country": map[string]interface{}{"iso_code": "US"},
+	"city":    map[string]interface{}{"names": map[string]interface{}{"en": "Mountain View"}},
+}
+
+func TestLookup(t *testing.T) {
+	networks := map[string]map[string]interface{}{
+		"81.2.69.0/24":  london,
+		"2001:db8::/32": mountainView,
+	}
+	for _, ipVersion := range []int{4, 6} {
+		for _, recordSize := range []int{24, 28, 32} {
+			t.Run(fmt.Sprintf("ipv%d/%d bits", ipVersion, recordSize), func(t *testing.T) {
+				r, err := Open(buildTestDB(t, ipVersion, recordSize, networks))
+				if err != nil {
+					t.Fatal(err)
+				}
+				if want, have := "Test-City", r.DatabaseType(); want != have {
+					t.Errorf("unexpected database type, want %q, have %q", want, have)
+				}
+
+				fields, found, err := r.Lookup(net.ParseIP("81.2.69.142"))
+				if err != nil {
+					t.Fatal(err)
+				}
+				if !found {
+					t.Fatal("expected address to be found")
+				}
+				want := map[string]string{
+					"COUNTRY_CODE":      "GB",
+					"COUNTRY_NAME":      "United Kingdom",
+					"COUNTRY_CONTINENT": "EU",
+					"REGION":            "ENG",
+					"CITY":              "London",
+					"POSTAL_CODE":       "EC2V",
+					"LATITUDE":          "51.5142",
+					"LONGITUDE":         "-0.0931",
+					"DMA_CODE":          "0",
+				}
+				if !maps.Equal(want, fields) {
+					t.Errorf("unexpected fields, want %v, have %v", want, fields)
+				}
+
+				if _, found, err := r.Lookup(net.ParseIP("81.2.70.1")); err != nil || found {
+					t.Errorf("expected address to be missing, have found=%t, err=%v", found, err)
+				}
+
+				fields, found, err = r.Lookup(net.ParseIP("2001:db8::1"))
+				if err != nil {
+					t.Fatal(err)
+				}
+				if want, have := ipVersion == 6, found; want != have {
+					t.Fatalf("unexpected ipv6 lookup result, want %t, have %t", want, have)
+				}
+				if found {
+					if want, have := "Mountain View", fields["CITY"]; want != have {
+						t.Errorf("unexpected city, want %q, have %q", want, have)
+					}
+				}
+			})
+		}
+	}
+}
+
+func TestOpenInvalid(t *testing.T) {
+	valid := buildTestDB(t, 4, 24, map[string]map[string]interface{}{"81.2.69.0/24": london})
+	tests := map[string][]byte{
+		"empty":            nil,
+		"no metadata":      valid[:len(valid)-60],
+		"truncated tree":   valid[len(valid)-(len(metadataMarker)+120):],
+		"garbage metadata": append([]byte("\x00"), append(metadataMarker, 0xff, 0xff)...),
+	}
+	for name, data := range tests {
+		t.Run(name, func(t *testing.T) {
+			if _, err := Open(data); err == nil {
+				t.Error("expected error")
+			}
+		})
+	}
+}
+
+// buildTestDB writes a MaxMind DB containing the networks
+func buildTestDB(t *testing.T, ipVersion, recordSize int, networks map[string]map[string]interface{}) []byte {
+	t.Helper()
+	type node struct {
+		children [2]*node
+		id       int
+		data     int
+	}
+	root := &node{}
+	var section []byte
+	// shared strings are written once and referenced with pointers
+	strings := map[string]int{}
+
+	cidrs := make([]string, 0, len(networks))
+	for cidr := range networks {
+		cidrs = append(cidrs, cidr)
+	}
+	sort.Strings(cidrs)
+	for _, cidr := range cidrs {
+		_, ipnet, err := net.ParseCIDR(cidr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ip := ipnet.IP
+		ones, _ := ipnet.Mask.Size()
+		if ip4 := ip.To4(); ip4 != nil && ipVersion == 6 {
+			ip = append(make(net.IP, 12), ip4...)
+			ones += 96
+		} else if ip4 == nil && ipVersion == 4 {
+			continue
+		}
+		n := root
+		for i := 0; i < ones; i++ {
+			bit := ip[i>>3] >> (7 - uint(i&7)) & 1
+			if n.children[bit] == nil {
+				n.children[bit] = &node{}
+			}
+			n = n.children[bit]
+		}
+		n.data = len(section) + 1
+		section = append(section, encodeTestValue(networks[cidr], len(section), strings)...)
+	}
+
+	// internal nodes are numbered in breadth first order
+	var nodes []*node
+	queue := []*node{root}
+	for len(queue) > 0 {
+		n := queue[0]
+		queue = queue[1:]
+		if n.data != 0 {
+			continue
+		}
+		n.id = len(nodes)
+		nodes = append(nodes, n)
+		for _, c := range n.children {
+			if c != nil {
+				queue = append(queue, c)
+			}
+		}
+	}
+	nodeCount := len(nodes)
+	record := func(c *node) uint32 {
+		switch {
+		case c == nil:
+			return uint32(nodeCount)
+		case c.data != 0:
+			return uint32(nodeCount + dataSectionSeparator + c.data - 1)
+		}
+		return uint32(c.id)
+	}
+
+	var db []byte
+	for _, n := range nodes {
+		l, r := record(n.children[0]), record(n.children[1])
+		switch recordSize {
+		case 24:
+			db = append(db, byte(l>>16), byte(l>>8), byte(l), byte(r>>16), byte(r>>8), byte(r))
+		case 28:
+			db = append(db, byte(l>>16), byte(l>>8), byte(l), byte(l>>20)&0xf0|byte(r>>24)&0x0f, byte(r>>16), byte(r>>8), byte(r))
+		case 32:
+			db = binary.BigEndian.AppendUint32(db, l)
+			db = binary.BigEndian.AppendUint32(db, r)
+		}
+	}
+	db = append(db, make([]byte, dataSectionSeparator)...)
+	db = append(db, section...)
+	db = append(db, metadataMarker...)
+	db = append(db, encodeTestValue(map[string]interface{}{
+		"binary_format_major_version": uint64(2),
+		"database_type":               "Test-City",
+		"ip_version":                  uint64(ipVersion),
+		"node_count":                  uint64(nodeCount),
+		"record_size":                 uint64(recordSize),
+	}, 0, nil)...)
+	return db
+}
+
+// encodeTestValue encodes the value written at the offset of the data section
+// in the MaxMind DB data format, strings already written are encoded as pointers
+func encodeTestValue(v interface{}, offset int, strings map[string]int) []byte {
+	ctrl := func(typ int, size int) []byte {
+		var b []byte
+		if typ > 7 {
+			b = []byte{0, byte(typ - 7)}
+		} else {
+			b = []byte{byte(typ << 5)}
+		}
+		if size >= 29 {
+			b[0] |= 29
+			return append(b, byte(size-29))
+		}
+		b[0] |= byte(size)
+		return b
+	}
+	switch v := v.(type) {
+	case string:
+		if p, ok := strings[v]; ok {
+			return []byte{1<<5 | byte(p>>8)&7, byte(p)}
+		}
+		if strings != nil {
+			strings[v] = offset
+		}
+		return append(ctrl(2, len(v)), v...)
+	case float64:
+		return binary.BigEndian.AppendUint64(ctrl(3, 8), math.Float64bits(v))
+	case uint64:
+		var b []byte
+		for u := v; u > 0; u >>= 8 {
+			b = append([]byte{byte(u)}, b...)
+		}
+		return append(ctrl(9, len(b)), b...)
+	case []interface{}:
+		b := ctrl(11, len(v))
+		for _, e := range v {
+			b = append(b, encodeTestValue(e, offset+len(b), strings)...)
+		}
+		return b
+	case map[string]interface{}:
+		keys := make([]string, 0, len(v))
+		for k := range v {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		b := ctrl(7, len(v))
+		for _, k := range keys {
+			b = append(b, encodeTestValue(k, offset+len(b), strings)...)
+			b = append(b, encodeTestValue(v[k], offset+len(b), strings)...)
+		}
+		return b
+	}
+	panic(fmt.Sprintf("unsupported type %T", v))
+}
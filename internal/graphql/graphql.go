@@ -0,0 +1,128 @@
+// Copyright 2024 Juan Pablo Tosso and the OWASP Coraza contributors
+// SPDX-License-Identifier: Apache-2.0
+
+// Package graphql measures the cost of executing GraphQL queries, to reject
+// the ones exhausting the resources of the servers. It parses the executable
+// documents, the operations and the fragments, following the fragment spreads
+// without executing nor validating the documents against a schema.
+package graphql
+
+import (
+	"errors"
+	"fmt"
+)
+
+// MaxNesting is the number of nested selection sets, lists and objects above
+// which the documents are not parsed
+const MaxNesting = 256
+
+// ErrTooDeep is returned for the documents nested over MaxNesting, their
+// depth exceeds any sensible limit
+var ErrTooDeep = fmt.Errorf("document nested over %d levels", MaxNesting)
+
+// maxCount caps the counts of fields and aliases, the fragments spread
+// several times can make them grow exponentially
+const maxCount = 1 << 30
+
+// Stats are the measures of the most expensive operation of a document, with
+// the fragment spreads expanded
+type Stats struct {
+	// Depth is the maximum number of nested fields
+	Depth int
+	// Aliases is the number of aliased fields
+	Aliases int
+	// Fields is the number of fields
+	Fields int
+}
+
+// document is a parsed executable document
+type document struct {
+	operations [][]selection
+	fragments  map[string][]selection
+}
+
+// Analyze parses a document and returns the measures of its most expensive
+// operation
+func Analyze(query string) (Stats, error) {
+	p := &parser{lex: lexer{src: query}}
+	doc, err := p.parse()
+	if err != nil {
+		if errors.Is(err, ErrTooDeep) {
+			return Stats{}, err
+		}
+		return Stats{}, fmt.Errorf("invalid GraphQL document: %s", err.Error())
+	}
+	a := &analyzer{doc: doc, fragments: map[string]Stats{}, visiting: map[string]bool{}}
+	var res Stats
+	for _, op := range doc.operations {
+		s, err := a.selectionSet(op)
+		if err != nil {
+			return Stats{}, fmt.Errorf("invalid GraphQL document: %s", err.Error())
+		}
+		res.Depth = max(res.Depth, s.Depth)
+		res.Aliases = max(res.Aliases, s.Aliases)
+		res.Fields = max(res.Fields, s.Fields)
+	}
+	return res, nil
+}
+
+// analyzer measures the selection sets, the measures of the fragments are
+// computed once
+type analyzer struct {
+	doc       *document
+	fragments map[string]Stats
+	// visiting are the fragments being measured, spreading them again is a cycle
+	visiting map[string]bool
+}
+
+func (a *analyzer) selectionSet(set []selection) (Stats, error) {
+	var res Stats
+	for _, sel := range set {
+		var s Stats
+		var err error
+		if sel.spread != "" {
+			s, err = a.fragment(sel.spread)
+		} else {
+			s, err = a.selectionSet(sel.set)
+		}
+		if err != nil {
+			return Stats{}, err
+		}
+		if sel.field {
+			s.Depth++
+			s.Fields = add(s.Fields, 1)
+			if sel.alias {
+				s.Aliases = add(s.Aliases, 1)
+			}
+		}
+		res.Depth = max(res.Depth, s.Depth)
+		res.Aliases = add(res.Aliases, s.Aliases)
+		res.Fields = add(res.Fields, s.Fields)
+	}
+	return res, nil
+}
+
+func (a *analyzer) fragment(name string) (Stats, error) {
+	if s, ok := a.fragments[name]; ok {
+		return s, nil
+	}
+	set, ok := a.doc.fragments[name]
+	if !ok {
+		return Stats{}, fmt.Errorf("unknown fragment %s", name)
+	}
+	if a.visiting[name] {
+		return Stats{}, fmt.Errorf("fragment %s spreads itself", name)
+	}
+	a.visiting[name] = true
+	s, err := a.selectionSet(set)
+	if err != nil {
+		return Stats{}, err
+	}
+	delete(a.visiting, name)
+	a.fragments[name] = s
+	return s, nil
+}
+
+func add(a, b int) int {
+	return min(a+b, maxCount)
+}
@@ -0,0 +1,90 @@
+// Copyright 2024 Juan Pablo Tosso and the OWASP Coraza contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package graphql
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestAnalyze(t *testing.T) {
+	tests := []struct {
+		query string
+		want  Stats
+	}{
+		{"{ me }", Stats{Depth: 1, Fields: 1}},
+		{"{ me { name friends { name } } }", Stats{Depth: 3, Fields: 4}},
+		{
+			`query Users($first: Int = 10, $ids: [ID!]!) @cached(ttl: 60) {
+				users(first: $first, filter: {ids: $ids, active: true, tags: ["a", "b"]}) @include(if: true) {
+					id
+					name # the display name
+					bio(format: """markdown "quoted" text""")
+				}
+			}`,
+			Stats{Depth: 2, Fields: 4},
+		},
+		{"{ a: me { id } b: me { id } c: me { id } }", Stats{Depth: 2, Aliases: 3, Fields: 6}},
+		{
+			`query { user { ...Profile ... on Admin { permissions { name } } } }
+			fragment Profile on User { name friends { ...Name } }
+			fragment Name on User { first: name }`,
+			Stats{Depth: 3, Aliases: 1, Fields: 6},
+		},
+		// the most expensive operation
+		{"query A { a { b { c } } } query B { x y z w }", Stats{Depth: 3, Fields: 4}},
+		// fragments spread several times multiply the fields
+		{
+			`{ ...F3 }
+			fragment F3 on Q { a: f { ...F2 } b: f { ...F2 } }
+			fragment F2 on Q { a: f { ...F1 } b: f { ...F1 } }
+			fragment F1 on Q { a: f b: f }`,
+			Stats{Depth: 3, Aliases: 14, Fields: 14},
+		},
+	}
+	for _, tt := range tests {
+		have, err := Analyze(tt.query)
+		if err != nil {
+			t.Errorf("unexpected error for %q: %s", tt.query, err.Error())
+			continue
+		}
+		if want := tt.want; want != have {
+			t.Errorf("unexpected stats of %q, want %+v, have %+v", tt.query, want, have)
+		}
+	}
+}
+
+func TestAnalyzeErrors(t *testing.T) {
+	tests := []string{
+		"",
+		"not a query",
+		"{ }",
+		"{ me ",
+		"{ me(id: ) }",
+		`{ me(name: "unterminated) }`,
+		"{ ...Missing }",
+		"{ ...A } fragment A on Q { ...B } fragment B on Q { ...A }",
+		"{ ...A } fragment A on Q { a } fragment A on Q { b }",
+		"fragment A on Q { a }",
+		"type Query { me: User }",
+		"{ me } %",
+	}
+	for _, query := range tests {
+		if _, err := Analyze(query); err == nil {
+			t.Errorf("expected error for %q", query)
+		}
+	}
+}
+
+func TestAnalyzeTooDeep(t *testing.T) {
+	query := strings.Repeat("{ a ", MaxNesting) + "{ a }" + strings.Repeat("}", MaxNesting)
+	if _, err := Analyze(query); !errors.Is(err, ErrTooDeep) {
+		t.Errorf("unexpected error, want %v, have %v", ErrTooDeep, err)
+	}
+	query = "{ a(v: " + strings.Repeat("[", MaxNesting+1) + strings.Repeat("]", MaxNesting+1) + ") }"
+	if _, err := Analyze(query); !errors.Is(err, ErrTooDeep) {
+		t.Errorf("unexpected error, want %v, have %v", ErrTooDeep, err)
+	}
+}
@@ -0,0 +1,489 @@
+// Copyright 2024 Juan Pablo Tosso and the OWASP Coraza contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package graphql
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+type tokenKind int
+
+const (
+	tokenEOF tokenKind = iota
+	tokenPunct
+	tokenName
+	// tokenValue is a number or a string
+	tokenValue
+)
+
+type token struct {
+	kind tokenKind
+	text string
+}
+
+// lexer returns the tokens of a document, the commas, whitespaces and
+// comments are ignored
+type lexer struct {
+	src string
+	pos int
+}
+
+func (l *lexer) next() (token, error) {
+	for l.pos < len(l.src) {
+		c := l.src[l.pos]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r' || c == ',':
+			l.pos++
+		case c == '#':
+			for l.pos < len(l.src) && l.src[l.pos] != '\n' && l.src[l.pos] != '\r' {
+				l.pos++
+			}
+		case strings.HasPrefix(l.src[l.pos:], "\ufeff"):
+			l.pos += len("\ufeff")
+		default:
+			return l.token()
+		}
+	}
+	return token{kind: tokenEOF}, nil
+}
+
+func (l *lexer) token() (token, error) {
+	start := l.pos
+	c := l.src[l.pos]
+	switch {
+	case strings.IndexByte("!$&()[]{}:=@|", c) >= 0:
+		l.pos++
+		return token{kind: tokenPunct, text: l.src[start:l.pos]}, nil
+	case strings.HasPrefix(l.src[l.pos:], "..."):
+		l.pos += 3
+		return token{kind: tokenPunct, text: "..."}, nil
+	case c == '_' || isLetter(c):
+		for l.pos < len(l.src) && (l.src[l.pos] == '_' || isLetter(l.src[l.pos]) || isDigit(l.src[l.pos])) {
+			l.pos++
+		}
+		return token{kind: tokenName, text: l.src[start:l.pos]}, nil
+	case c == '-' || isDigit(c):
+		l.pos++
+		for l.pos < len(l.src) && (isDigit(l.src[l.pos]) || strings.IndexByte(".eE+-", l.src[l.pos]) >= 0) {
+			l.pos++
+		}
+		return token{kind: tokenValue, text: l.src[start:l.pos]}, nil
+	case strings.HasPrefix(l.src[l.pos:], `"""`):
+		// block strings end at the first unescaped """
+		for i := l.pos + 3; i+3 <= len(l.src); i++ {
+			if strings.HasPrefix(l.src[i:], `\"""`) {
+				i += 3
+				continue
+			}
+			if strings.HasPrefix(l.src[i:], `"""`) {
+				l.pos = i + 3
+				return token{kind: tokenValue, text: l.src[start:l.pos]}, nil
+			}
+		}
+		return token{}, errors.New("unterminated block string")
+	case c == '"':
+		for i := l.pos + 1; i < len(l.src); i++ {
+			switch l.src[i] {
+			case '\\':
+				i++
+			case '\n', '\r':
+				return token{}, errors.New("unterminated string")
+			case '"':
+				l.pos = i + 1
+				return token{kind: tokenValue, text: l.src[start:l.pos]}, nil
+			}
+		}
+		return token{}, errors.New("unterminated string")
+	}
+	return token{}, fmt.Errorf("unexpected character %q at %d", c, l.pos)
+}
+
+func isLetter(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z'
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+// selection is a field, an inline fragment or a fragment spread of a
+// selection set
+type selection struct {
+	field bool
+	alias bool
+	// spread is the name of the fragment of a spread
+	spread string
+	set    []selection
+}
+
+// parser parses the executable documents, the operations and the fragments
+type parser struct {
+	lex lexer
+	tok token
+	// nesting is the number of selection sets and values being parsed
+	nesting int
+}
+
+func (p *parser) advance() error {
+	var err error
+	p.tok, err = p.lex.next()
+	return err
+}
+
+func (p *parser) is(text string) bool {
+	return p.tok.kind == tokenPunct && p.tok.text == text
+}
+
+func (p *parser) expect(text string) error {
+	if !p.is(text) {
+		return p.unexpected()
+	}
+	return p.advance()
+}
+
+func (p *parser) name() (string, error) {
+	if p.tok.kind != tokenName {
+		return "", p.unexpected()
+	}
+	name := p.tok.text
+	return name, p.advance()
+}
+
+func (p *parser) unexpected() error {
+	if p.tok.kind == tokenEOF {
+		return errors.New("unexpected end of document")
+	}
+	return fmt.Errorf("unexpected %q at %d", p.tok.text, p.lex.pos-len(p.tok.text))
+}
+
+func (p *parser) enter() error {
+	p.nesting++
+	if p.nesting > MaxNesting {
+		return ErrTooDeep
+	}
+	return nil
+}
+
+func (p *parser) parse() (*document, error) {
+	doc := &document{fragments: map[string][]selection{}}
+	if err := p.advance(); err != nil {
+		return nil, err
+	}
+	for p.tok.kind != tokenEOF {
+		if p.is("{") {
+			set, err := p.selectionSet()
+			if err != nil {
+				return nil, err
+			}
+			doc.operations = append(doc.operations, set)
+			continue
+		}
+		if p.tok.kind != tokenName {
+			return nil, p.unexpected()
+		}
+		switch p.tok.text {
+		case "query", "mutation", "subscription":
+			set, err := p.operation()
+			if err != nil {
+				return nil, err
+			}
+			doc.operations = append(doc.operations, set)
+		case "fragment":
+			name, set, err := p.fragment()
+			if err != nil {
+				return nil, err
+			}
+			if _, ok := doc.fragments[name]; ok {
+				return nil, fmt.Errorf("fragment %s is defined twice", name)
+			}
+			doc.fragments[name] = set
+		default:
+			return nil, fmt.Errorf("unsupported definition %s", p.tok.text)
+		}
+	}
+	if len(doc.operations) == 0 {
+		return nil, errors.New("missing operation")
+	}
+	return doc, nil
+}
+
+// operation parses OperationType Name? VariableDefinitions? Directives? SelectionSet
+func (p *parser) operation() ([]selection, error) {
+	if err := p.advance(); err != nil {
+		return nil, err
+	}
+	if p.tok.kind == tokenName {
+		if err := p.advance(); err != nil {
+			return nil, err
+		}
+	}
+	if p.is("(") {
+		if err := p.variableDefinitions(); err != nil {
+			return nil, err
+		}
+	}
+	if err := p.directives(); err != nil {
+		return nil, err
+	}
+	return p.selectionSet()
+}
+
+// fragment parses fragment Name TypeCondition Directives? SelectionSet
+func (p *parser) fragment() (string, []selection, error) {
+	if err := p.advance(); err != nil {
+		return "", nil, err
+	}
+	if p.tok.kind == tokenName && p.tok.text == "on" {
+		return "", nil, p.unexpected()
+	}
+	name, err := p.name()
+	if err != nil {
+		return "", nil, err
+	}
+	if err := p.typeCondition(); err != nil {
+		return "", nil, err
+	}
+	if err := p.directives(); err != nil {
+		return "", nil, err
+	}
+	set, err := p.selectionSet()
+	return name, set, err
+}
+
+func (p *parser) typeCondition() error {
+	if p.tok.kind != tokenName || p.tok.text != "on" {
+		return p.unexpected()
+	}
+	if err := p.advance(); err != nil {
+		return err
+	}
+	_, err := p.name()
+	return err
+}
+
+// variableDefinitions parses ( ($Name : Type DefaultValue? Directives?)+ )
+func (p *parser) variableDefinitions() error {
+	if err := p.advance(); err != nil {
+		return err
+	}
+	for !p.is(")") {
+		if err := p.expect("$"); err != nil {
+			return err
+		}
+		if _, err := p.name(); err != nil {
+			return err
+		}
+		if err := p.expect(":"); err != nil {
+			return err
+		}
+		if err := p.typeRef(); err != nil {
+			return err
+		}
+		if p.is("=") {
+			if err := p.advance(); err != nil {
+				return err
+			}
+			if err := p.value(); err != nil {
+				return err
+			}
+		}
+		if err := p.directives(); err != nil {
+			return err
+		}
+	}
+	return p.advance()
+}
+
+// typeRef parses Name, [Type] and their non null Type!
+func (p *parser) typeRef() error {
+	if err := p.enter(); err != nil {
+		return err
+	}
+	defer func() { p.nesting-- }()
+	if p.is("[") {
+		if err := p.advance(); err != nil {
+			return err
+		}
+		if err := p.typeRef(); err != nil {
+			return err
+		}
+		if err := p.expect("]"); err != nil {
+			return err
+		}
+	} else if _, err := p.name(); err != nil {
+		return err
+	}
+	if p.is("!") {
+		return p.advance()
+	}
+	return nil
+}
+
+// directives parses (@Name Arguments?)*
+func (p *parser) directives() error {
+	for p.is("@") {
+		if err := p.advance(); err != nil {
+			return err
+		}
+		if _, err := p.name(); err != nil {
+			return err
+		}
+		if p.is("(") {
+			if err := p.arguments(); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// arguments parses ( (Name : Value)+ )
+func (p *parser) arguments() error {
+	if err := p.advance(); err != nil {
+		return err
+	}
+	if p.is(")") {
+		return p.unexpected()
+	}
+	for !p.is(")") {
+		if _, err := p.name(); err != nil {
+			return err
+		}
+		if err := p.expect(":"); err != nil {
+			return err
+		}
+		if err := p.value(); err != nil {
+			return err
+		}
+	}
+	return p.advance()
+}
+
+// value parses a variable, a scalar, an enum, a list or an object
+func (p *parser) value() error {
+	if err := p.enter(); err != nil {
+		return err
+	}
+	defer func() { p.nesting-- }()
+	switch {
+	case p.is("$"):
+		if err := p.advance(); err != nil {
+			return err
+		}
+		_, err := p.name()
+		return err
+	case p.tok.kind == tokenName || p.tok.kind == tokenValue:
+		return p.advance()
+	case p.is("["):
+		if err := p.advance(); err != nil {
+			return err
+		}
+		for !p.is("]") {
+			if err := p.value(); err != nil {
+				return err
+			}
+		}
+		return p.advance()
+	case p.is("{"):
+		if err := p.advance(); err != nil {
+			return err
+		}
+		for !p.is("}") {
+			if _, err := p.name(); err != nil {
+				return err
+			}
+			if err := p.expect(":"); err != nil {
+				return err
+			}
+			if err := p.value(); err != nil {
+				return err
+			}
+		}
+		return p.advance()
+	}
+	return p.unexpected()
+}
+
+// selectionSet parses { Selection+ }
+func (p *parser) selectionSet() ([]selection, error) {
+	if err := p.enter(); err != nil {
+		return nil, err
+	}
+	defer func() { p.nesting-- }()
+	if err := p.expect("{"); err != nil {
+		return nil, err
+	}
+	if p.is("}") {
+		return nil, p.unexpected()
+	}
+	var set []selection
+	for !p.is("}") {
+		sel, err := p.selection()
+		if err != nil {
+			return nil, err
+		}
+		set = append(set, sel)
+	}
+	return set, p.advance()
+}
+
+// selection parses a field Alias? Name Arguments? Directives? SelectionSet?,
+// a fragment spread ...Name Directives? or an inline fragment
+// ...TypeCondition? Directives? SelectionSet
+func (p *parser) selection() (selection, error) {
+	var sel selection
+	if p.is("...") {
+		if err := p.advance(); err != nil {
+			return sel, err
+		}
+		if p.tok.kind == tokenName && p.tok.text != "on" {
+			sel.spread = p.tok.text
+			if err := p.advance(); err != nil {
+				return sel, err
+			}
+			return sel, p.directives()
+		}
+		if p.tok.kind == tokenName {
+			if err := p.typeCondition(); err != nil {
+				return sel, err
+			}
+		}
+		if err := p.directives(); err != nil {
+			return sel, err
+		}
+		var err error
+		sel.set, err = p.selectionSet()
+		return sel, err
+	}
+
+	sel.field = true
+	if _, err := p.name(); err != nil {
+		return sel, err
+	}
+	if p.is(":") {
+		sel.alias = true
+		if err := p.advance(); err != nil {
+			return sel, err
+		}
+		if _, err := p.name(); err != nil {
+			return sel, err
+		}
+	}
+	if p.is("(") {
+		if err := p.arguments(); err != nil {
+			return sel, err
+		}
+	}
+	if err := p.directives(); err != nil {
+		return sel, err
+	}
+	if p.is("{") {
+		var err error
+		sel.set, err = p.selectionSet()
+		return sel, err
+	}
+	return sel, nil
+}
@@ -0,0 +1,176 @@
+// Copyright 2024 Juan Pablo Tosso and the OWASP Coraza contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package io
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// ErrPathEscapesRoot is returned when a path resolves outside of the
+// directory of a ConfinedFS
+var ErrPathEscapesRoot = errors.New("path escapes the root directory")
+
+// ErrNotConfinable is returned for the programs and the remote sources, which
+// can't be confined to the directory of a ConfinedFS
+var ErrNotConfinable = errors.New("not allowed when the files are confined to a directory")
+
+// maxSymlinks is the number of symbolic links Resolve follows, like the
+// limit of the kernel
+const maxSymlinks = 40
+
+// IsConfined returns true if the root is a ConfinedFS
+func IsConfined(root fs.FS) bool {
+	_, ok := root.(ConfinedFS)
+	return ok
+}
+
+// ConfinedFS implements fs.FS serving a directory as the root of the filesystem,
+// like chroot: absolute paths are resolved from the directory, relative paths
+// too, and paths escaping it, with .. or through symbolic links, are rejected.
+type ConfinedFS struct {
+	dir string
+}
+
+// NewConfinedFS returns a ConfinedFS for an existing directory
+func NewConfinedFS(dir string) (ConfinedFS, error) {
+	abs, err := filepath.Abs(dir)
+	if err != nil {
+		return ConfinedFS{}, err
+	}
+	abs, err = filepath.EvalSymlinks(abs)
+	if err != nil {
+		return ConfinedFS{}, err
+	}
+	info, err := os.Stat(abs)
+	if err != nil {
+		return ConfinedFS{}, err
+	}
+	if !info.IsDir() {
+		return ConfinedFS{}, fmt.Errorf("%s is not a directory", dir)
+	}
+	return ConfinedFS{dir: abs}, nil
+}
+
+// Dir returns the directory the filesystem is confined to
+func (c ConfinedFS) Dir() string {
+	return c.dir
+}
+
+// Resolve returns the path in the host filesystem of the name, which doesn't
+// need to exist. It returns ErrPathEscapesRoot if the name resolves outside of
+// the directory.
+func (c ConfinedFS) Resolve(name string) (string, error) {
+	host, err := c.join(name)
+	if err != nil {
+		return "", err
+	}
+	return c.resolve(name, host, 0)
+}
+
+// resolve resolves the existing part of the host path, so symbolic links
+// can't point outside of the directory. Dangling symbolic links are followed
+// too, as creating the file would create their target.
+func (c ConfinedFS) resolve(name string, host string, links int) (string, error) {
+	existing, rest := host, ""
+	for {
+		resolved, err := filepath.EvalSymlinks(existing)
+		if err == nil {
+			if !c.contains(resolved) {
+				return "", fmt.Errorf("%s: %w", name, ErrPathEscapesRoot)
+			}
+			return filepath.Join(resolved, rest), nil
+		}
+		if info, lerr := os.Lstat(existing); lerr == nil && info.Mode()&fs.ModeSymlink != 0 {
+			if links == maxSymlinks {
+				return "", fmt.Errorf("%s: too many levels of symbolic links", name)
+			}
+			target, err := os.Readlink(existing)
+			if err != nil {
+				return "", err
+			}
+			if !filepath.IsAbs(target) {
+				target = filepath.Join(filepath.Dir(existing), target)
+			}
+			return c.resolve(name, filepath.Join(target, rest), links+1)
+		}
+		parent := filepath.Dir(existing)
+		if existing == c.dir || parent == existing {
+			return "", err
+		}
+		rest = filepath.Join(filepath.Base(existing), rest)
+		existing = parent
+	}
+}
+
+// join returns the path in the host filesystem of the name, without
+// resolving symbolic links
+func (c ConfinedFS) join(name string) (string, error) {
+	rel := path.Clean(strings.TrimLeft(filepath.ToSlash(name), "/"))
+	if rel == ".." || strings.HasPrefix(rel, "../") {
+		return "", fmt.Errorf("%s: %w", name, ErrPathEscapesRoot)
+	}
+	return filepath.Join(c.dir, filepath.FromSlash(rel)), nil
+}
+
+func (c ConfinedFS) contains(host string) bool {
+	rel, err := filepath.Rel(c.dir, host)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+func (c ConfinedFS) Open(name string) (fs.File, error) {
+	host, err := c.Resolve(name)
+	if err != nil {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: err}
+	}
+	return os.Open(host)
+}
+
+func (c ConfinedFS) ReadFile(name string) ([]byte, error) {
+	host, err := c.Resolve(name)
+	if err != nil {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: err}
+	}
+	return os.ReadFile(host)
+}
+
+func (c ConfinedFS) ReadDir(name string) ([]fs.DirEntry, error) {
+	host, err := c.Resolve(name)
+	if err != nil {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: err}
+	}
+	return os.ReadDir(host)
+}
+
+// Glob returns the names of the files matching the pattern in the same form
+// as the pattern, absolute or relative to the directory. Matches escaping the
+// directory through symbolic links are left out.
+func (c ConfinedFS) Glob(pattern string) ([]string, error) {
+	host, err := c.join(pattern)
+	if err != nil {
+		return nil, err
+	}
+	matches, err := filepath.Glob(host)
+	if err != nil {
+		return nil, err
+	}
+	names := make([]string, 0, len(matches))
+	for _, m := range matches {
+		if _, err := c.Resolve(m[len(c.dir):]); err != nil {
+			continue
+		}
+		name, _ := filepath.Rel(c.dir, m)
+		name = filepath.ToSlash(name)
+		if path.IsAbs(pattern) {
+			name = "/" + name
+		}
+		names = append(names, name)
+	}
+	return names, nil
+}
@@ -0,0 +1,101 @@
+// Copyright 2024 Juan Pablo Tosso and the OWASP Coraza contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package io
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestConfinedFS(t *testing.T) {
+	outside := t.TempDir()
+	if err := os.WriteFile(filepath.Join(outside, "secret.conf"), []byte("secret"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	dir := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(dir, "rules"), 0700); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"rules/a.conf", "rules/b.conf"} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(name), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.Symlink(outside, filepath.Join(dir, "rules", "escape")); err != nil {
+		t.Skipf("symbolic links not supported: %v", err)
+	}
+	if err := os.Symlink(filepath.Join(outside, "secret.conf"), filepath.Join(dir, "rules", "c.conf")); err != nil {
+		t.Fatal(err)
+	}
+	// dangling links are followed when the file is created
+	if err := os.Symlink(filepath.Join(outside, "new.log"), filepath.Join(dir, "rules", "dangling.log")); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink("missing.log", filepath.Join(dir, "rules", "inside.log")); err != nil {
+		t.Fatal(err)
+	}
+
+	root, err := NewConfinedFS(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, name := range []string{"rules/a.conf", "/rules/a.conf", "rules/../rules/a.conf"} {
+		data, err := fs.ReadFile(root, name)
+		if err != nil {
+			t.Errorf("unexpected error reading %s: %v", name, err)
+			continue
+		}
+		if want, have := "rules/a.conf", string(data); want != have {
+			t.Errorf("unexpected content of %s, want %q, have %q", name, want, have)
+		}
+	}
+
+	for _, name := range []string{
+		"../secret.conf",
+		"/../secret.conf",
+		"rules/../../secret.conf",
+		"rules/escape/secret.conf",
+		"rules/c.conf",
+		"rules/escape/new.log",
+		"rules/dangling.log",
+	} {
+		if _, err := root.Resolve(name); !errors.Is(err, ErrPathEscapesRoot) {
+			t.Errorf("unexpected error resolving %s, want %v, have %v", name, ErrPathEscapesRoot, err)
+		}
+	}
+
+	// files to be created are resolved too
+	if want, have := filepath.Join(root.Dir(), "logs", "audit.log"), mustResolve(t, root, "/logs/audit.log"); want != have {
+		t.Errorf("unexpected path, want %q, have %q", want, have)
+	}
+
+	if want, have := filepath.Join(root.Dir(), "rules", "missing.log"), mustResolve(t, root, "rules/inside.log"); want != have {
+		t.Errorf("unexpected path, want %q, have %q", want, have)
+	}
+
+	matches, err := fs.Glob(root, "/rules/*.conf")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want, have := []string{"/rules/a.conf", "/rules/b.conf"}, matches; len(want) != len(have) || want[0] != have[0] || want[1] != have[1] {
+		t.Errorf("unexpected matches, want %q, have %q", want, have)
+	}
+
+	if _, err := NewConfinedFS(filepath.Join(dir, "rules", "a.conf")); err == nil {
+		t.Error("expected error confining to a file")
+	}
+}
+
+func mustResolve(t *testing.T, root ConfinedFS, name string) string {
+	t.Helper()
+	host, err := root.Resolve(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return host
+}
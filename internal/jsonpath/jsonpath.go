@@ -0,0 +1,230 @@
+// Copyright 2024 Juan Pablo Tosso and the OWASP Coraza contributors
+// SPDX-License-Identifier: Apache-2.0
+
+// Package jsonpath evaluates JSONPath expressions against JSON documents. It
+// supports the root $, the child members .name, ['name'] and ["name"], the
+// array indexes [n], negative ones counting from the end, the wildcards .*
+// and [*] and the recursive descent .., e.g. $..password or $.items[*].id.
+// Filters, slices and unions are not supported.
+package jsonpath
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"slices"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// Path is a compiled JSONPath expression, it is safe for concurrent use
+type Path struct {
+	expr  string
+	steps []step
+}
+
+// step selects the children of a node, the members named name, the element
+// at index or all of them if wildcard, in the node and its descendants if
+// recursive
+type step struct {
+	name      string
+	index     int
+	isIndex   bool
+	wildcard  bool
+	recursive bool
+}
+
+// Compile parses a JSONPath expression
+func Compile(expr string) (*Path, error) {
+	p := &Path{expr: expr}
+	if !strings.HasPrefix(expr, "$") {
+		return nil, fmt.Errorf("invalid JSONPath %q: it must start with $", expr)
+	}
+	for i := 1; i < len(expr); {
+		s := step{}
+		member := true
+		switch {
+		case strings.HasPrefix(expr[i:], ".."):
+			s.recursive = true
+			i += 2
+			member = i == len(expr) || expr[i] != '['
+		case expr[i] == '.':
+			i++
+		case expr[i] == '[':
+			member = false
+		default:
+			return nil, fmt.Errorf("invalid JSONPath %q: unexpected %q at %d", expr, expr[i], i)
+		}
+		n := i
+		if member {
+			for n < len(expr) && expr[n] != '.' && expr[n] != '[' {
+				n++
+			}
+			if n == i {
+				return nil, fmt.Errorf("invalid JSONPath %q: empty member name at %d", expr, i)
+			}
+			if name := expr[i:n]; name == "*" {
+				s.wildcard = true
+			} else {
+				s.name = name
+			}
+		} else {
+			var err error
+			if n, err = parseBracket(expr, i, &s); err != nil {
+				return nil, err
+			}
+		}
+		p.steps = append(p.steps, s)
+		i = n
+	}
+	return p, nil
+}
+
+// parseBracket parses the bracket at i into s and returns the index following it
+func parseBracket(expr string, i int, s *step) (int, error) {
+	i++
+	if i < len(expr) && (expr[i] == '\'' || expr[i] == '"') {
+		quote := expr[i]
+		var name strings.Builder
+		for i++; i < len(expr) && expr[i] != quote; i++ {
+			if expr[i] == '\\' && i+1 < len(expr) {
+				i++
+			}
+			name.WriteByte(expr[i])
+		}
+		if i+1 >= len(expr) || expr[i+1] != ']' {
+			return 0, fmt.Errorf("invalid JSONPath %q: unterminated member name", expr)
+		}
+		s.name = name.String()
+		return i + 2, nil
+	}
+	end := strings.IndexByte(expr[i:], ']')
+	if end == -1 {
+		return 0, fmt.Errorf("invalid JSONPath %q: unterminated bracket", expr)
+	}
+	inner := strings.TrimSpace(expr[i : i+end])
+	if inner == "*" {
+		s.wildcard = true
+		return i + end + 1, nil
+	}
+	index, err := strconv.Atoi(inner)
+	if err != nil {
+		return 0, fmt.Errorf("invalid JSONPath %q: unsupported selector [%s]", expr, inner)
+	}
+	s.index, s.isIndex = index, true
+	return i + end + 1, nil
+}
+
+// Split splits s at the first whitespace that is not quoted, into a JSONPath
+// expression and the rest
+func Split(s string) (string, string) {
+	var quote rune
+	for i, r := range s {
+		switch {
+		case quote != 0:
+			if r == quote {
+				quote = 0
+			}
+		case r == '\'' || r == '"':
+			quote = r
+		case unicode.IsSpace(r):
+			return s[:i], strings.TrimSpace(s[i:])
+		}
+	}
+	return s, ""
+}
+
+// String returns the expression the path was compiled from
+func (p *Path) String() string {
+	return p.expr
+}
+
+// Eval returns the nodes of the document, decoded with encoding/json,
+// selected by the path, in document order. The members of the objects are
+// visited in the order of their names.
+func (p *Path) Eval(doc any) []any {
+	nodes := []any{doc}
+	for _, s := range p.steps {
+		var next []any
+		for _, n := range nodes {
+			next = s.apply(n, next)
+		}
+		if len(next) == 0 {
+			return nil
+		}
+		nodes = next
+	}
+	return nodes
+}
+
+func (s step) apply(node any, res []any) []any {
+	switch n := node.(type) {
+	case map[string]any:
+		if s.wildcard {
+			for _, k := range slices.Sorted(maps.Keys(n)) {
+				res = append(res, n[k])
+			}
+		} else if v, ok := n[s.name]; ok && !s.isIndex {
+			res = append(res, v)
+		}
+		if s.recursive {
+			for _, k := range slices.Sorted(maps.Keys(n)) {
+				res = s.apply(n[k], res)
+			}
+		}
+	case []any:
+		switch {
+		case s.wildcard:
+			res = append(res, n...)
+		case s.isIndex:
+			i := s.index
+			if i < 0 {
+				i += len(n)
+			}
+			if i >= 0 && i < len(n) {
+				res = append(res, n[i])
+			}
+		}
+		if s.recursive {
+			for _, v := range n {
+				res = s.apply(v, res)
+			}
+		}
+	}
+	return res
+}
+
+// Decode decodes a JSON document, the numbers are kept as json.Number so
+// they are compared with their original text
+func Decode(data []byte) (any, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	var doc any
+	if err := dec.Decode(&doc); err != nil {
+		return nil, err
+	}
+	return doc, nil
+}
+
+// Text returns the text of a node: the strings unquoted, the numbers and
+// booleans as written, null as an empty string and the objects and arrays
+// as compact JSON
+func Text(node any) string {
+	switch n := node.(type) {
+	case nil:
+		return ""
+	case string:
+		return n
+	case json.Number:
+		return n.String()
+	case bool:
+		return strconv.FormatBool(n)
+	}
+	data, err := json.Marshal(node)
+	if err != nil {
+		return ""
+	}
+	return string(data)
+}
@@ -0,0 +1,84 @@
+// Copyright 2024 Juan Pablo Tosso and the OWASP Coraza contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package jsonpath
+
+import (
+	"slices"
+	"testing"
+)
+
+const document = `{
+	"user": {"name": "alice", "roles": ["admin", "dev"], "password": "s3cret"},
+	"items": [
+		{"id": 1, "price": 10.50, "tags": null},
+		{"id": 2, "price": 2000, "meta": {"password": "hunter2"}}
+	],
+	"a b": true
+}`
+
+func TestEval(t *testing.T) {
+	doc, err := Decode([]byte(document))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		expr string
+		want []string
+	}{
+		{"$.user.name", []string{"alice"}},
+		{"$['user'][\"name\"]", []string{"alice"}},
+		{"$.user.roles", []string{`["admin","dev"]`}},
+		{"$.user.roles[*]", []string{"admin", "dev"}},
+		{"$.user.roles[-1]", []string{"dev"}},
+		{"$.user.roles[2]", nil},
+		{"$.items[*].price", []string{"10.50", "2000"}},
+		{"$.items[0].tags", []string{""}},
+		{"$.items.*.id", []string{"1", "2"}},
+		{"$..password", []string{"hunter2", "s3cret"}},
+		{"$..[0]", []string{`{"id":1,"price":10.50,"tags":null}`, "admin"}},
+		{"$['a b']", []string{"true"}},
+		{"$.missing.name", nil},
+		{"$.user.name[0]", nil},
+		{"$", []string{`{"a b":true,"items":[{"id":1,"price":10.50,"tags":null},{"id":2,"meta":{"password":"hunter2"},"price":2000}],"user":{"name":"alice","password":"s3cret","roles":["admin","dev"]}}`}},
+	}
+	for _, tt := range tests {
+		p, err := Compile(tt.expr)
+		if err != nil {
+			t.Errorf("unexpected error for %q: %s", tt.expr, err.Error())
+			continue
+		}
+		var have []string
+		for _, n := range p.Eval(doc) {
+			have = append(have, Text(n))
+		}
+		if want := tt.want; !slices.Equal(want, have) {
+			t.Errorf("unexpected result of %q, want %q, have %q", tt.expr, want, have)
+		}
+	}
+}
+
+func TestCompileErrors(t *testing.T) {
+	for _, expr := range []string{"", "user.name", "$.", "$.user.", "$[", "$['name", "$[1:2]", "$[?(@.id)]", "$x"} {
+		if _, err := Compile(expr); err == nil {
+			t.Errorf("expected error for %q", expr)
+		}
+	}
+}
+
+func TestSplit(t *testing.T) {
+	tests := []struct {
+		s, expr, rest string
+	}{
+		{"$.a", "$.a", ""},
+		{"$.a @streq b", "$.a", "@streq b"},
+		{"$['a b'] !@rx ^c", "$['a b']", "!@rx ^c"},
+		{`$["a 'b"]	@eq 1`, `$["a 'b"]`, "@eq 1"},
+	}
+	for _, tt := range tests {
+		expr, rest := Split(tt.s)
+		if expr != tt.expr || rest != tt.rest {
+			t.Errorf("unexpected split of %q, want %q %q, have %q %q", tt.s, tt.expr, tt.rest, expr, rest)
+		}
+	}
+}
@@ -0,0 +1,603 @@
+// Copyright 2024 Juan Pablo Tosso and the OWASP Coraza contributors
+// SPDX-License-Identifier: Apache-2.0
+
+// Package jsonschema validates JSON documents against a JSON Schema. It supports
+// the validation keywords of drafts 4 to 2020-12 used to describe API payloads:
+// type, enum, const, the numeric, string, array and object constraints, the
+// allOf, anyOf, oneOf and not combinators and $ref to the definitions of the same
+// document. Annotations like format or description are ignored, as well as the
+// keywords it doesn't know.
+package jsonschema
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// Schema is a compiled JSON Schema, it is safe for concurrent use
+type Schema struct {
+	root *node
+}
+
+// node is a compiled schema or subschema
+type node struct {
+	// always is set for the boolean schemas true and false
+	always *bool
+
+	ref *node
+
+	types    []string
+	enum     []any
+	constant any
+	hasConst bool
+
+	minimum, maximum                   *float64
+	exclusiveMinimum, exclusiveMaximum *float64
+	multipleOf                         *float64
+
+	minLength, maxLength *int
+	pattern              *regexp.Regexp
+
+	prefixItems     []*node
+	additionalItems *node
+	minItems        *int
+	maxItems        *int
+	uniqueItems     bool
+
+	properties           map[string]*node
+	propertyNames        []string
+	patternProperties    []patternProperty
+	additionalProperties *node
+	required             []string
+	minProperties        *int
+	maxProperties        *int
+
+	allOf, anyOf, oneOf []*node
+	not                 *node
+}
+
+type patternProperty struct {
+	re     *regexp.Regexp
+	schema *node
+}
+
+// ValidationError is the first error found validating a document
+type ValidationError struct {
+	// Path is the JSON pointer of the invalid value, empty for the document
+	Path    string
+	Message string
+}
+
+func (e *ValidationError) Error() string {
+	if e.Path == "" {
+		return e.Message
+	}
+	return e.Path + ": " + e.Message
+}
+
+// compiler compiles the subschemas of a document, keyed by JSON pointer so
+// references to the same definition share the node
+type compiler struct {
+	doc   any
+	nodes map[string]*node
+	refs  map[*node]string
+}
+
+// Compile compiles a JSON Schema document
+func Compile(data []byte) (*Schema, error) {
+	var doc any
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("invalid schema: %s", err.Error())
+	}
+	c := &compiler{doc: doc, nodes: map[string]*node{}, refs: map[*node]string{}}
+	root, err := c.compile(doc, "")
+	if err != nil {
+		return nil, err
+	}
+	// references are resolved once the document is compiled, as they can
+	// point to any part of it, including the schemas referencing them
+	for len(c.refs) > 0 {
+		for n, ref := range c.refs {
+			delete(c.refs, n)
+			target, err := c.resolve(ref)
+			if err != nil {
+				return nil, err
+			}
+			n.ref = target
+		}
+	}
+	return &Schema{root: root}, nil
+}
+
+// resolve returns the node of a reference to the document
+func (c *compiler) resolve(ref string) (*node, error) {
+	ptr, ok := strings.CutPrefix(ref, "#")
+	if !ok {
+		return nil, fmt.Errorf("unsupported reference %q, only references to the same document are supported", ref)
+	}
+	if n, ok := c.nodes[ptr]; ok {
+		return n, nil
+	}
+	v := c.doc
+	if ptr != "" {
+		for _, token := range strings.Split(strings.TrimPrefix(ptr, "/"), "/") {
+			token = strings.ReplaceAll(strings.ReplaceAll(token, "~1", "/"), "~0", "~")
+			switch t := v.(type) {
+			case map[string]any:
+				v, ok = t[token]
+			case []any:
+				i, err := strconv.Atoi(token)
+				ok = err == nil && i >= 0 && i < len(t)
+				if ok {
+					v = t[i]
+				}
+			default:
+				ok = false
+			}
+			if !ok {
+				return nil, fmt.Errorf("unresolved reference %q", ref)
+			}
+		}
+	}
+	return c.compile(v, ptr)
+}
+
+func (c *compiler) compile(v any, ptr string) (*node, error) {
+	if n, ok := c.nodes[ptr]; ok {
+		return n, nil
+	}
+	n := &node{}
+	c.nodes[ptr] = n
+	switch s := v.(type) {
+	case bool:
+		n.always = &s
+		return n, nil
+	case map[string]any:
+		return n, c.compileObject(n, s, ptr)
+	}
+	return nil, fmt.Errorf("invalid schema at %q: expected an object or a boolean", ptr)
+}
+
+func (c *compiler) compileObject(n *node, s map[string]any, ptr string) error {
+	var err error
+	sub := func(key string) (*node, error) {
+		if v, ok := s[key]; ok {
+			return c.compile(v, ptr+"/"+escape(key))
+		}
+		return nil, nil
+	}
+	subs := func(key string) ([]*node, error) {
+		v, ok := s[key]
+		if !ok {
+			return nil, nil
+		}
+		list, ok := v.([]any)
+		if !ok {
+			return nil, fmt.Errorf("invalid schema at %q: %s must be an array", ptr, key)
+		}
+		nodes := make([]*node, 0, len(list))
+		for i, item := range list {
+			sn, err := c.compile(item, ptr+"/"+key+"/"+strconv.Itoa(i))
+			if err != nil {
+				return nil, err
+			}
+			nodes = append(nodes, sn)
+		}
+		return nodes, nil
+	}
+
+	if ref, ok := s["$ref"].(string); ok {
+		c.refs[n] = ref
+	}
+
+	switch t := s["type"].(type) {
+	case string:
+		n.types = []string{t}
+	case []any:
+		for _, item := range t {
+			if name, ok := item.(string); ok {
+				n.types = append(n.types, name)
+			}
+		}
+	}
+	if enum, ok := s["enum"].([]any); ok {
+		n.enum = enum
+	}
+	n.constant, n.hasConst = s["const"]
+
+	n.minimum = number(s, "minimum")
+	n.maximum = number(s, "maximum")
+	n.multipleOf = number(s, "multipleOf")
+	n.exclusiveMinimum = number(s, "exclusiveMinimum")
+	n.exclusiveMaximum = number(s, "exclusiveMaximum")
+	// draft 4 flags the bounds as exclusive with booleans
+	if b, _ := s["exclusiveMinimum"].(bool); b {
+		n.exclusiveMinimum, n.minimum = n.minimum, nil
+	}
+	if b, _ := s["exclusiveMaximum"].(bool); b {
+		n.exclusiveMaximum, n.maximum = n.maximum, nil
+	}
+
+	n.minLength = integer(s, "minLength")
+	n.maxLength = integer(s, "maxLength")
+	if pattern, ok := s["pattern"].(string); ok {
+		if n.pattern, err = regexp.Compile(pattern); err != nil {
+			return fmt.Errorf("invalid schema at %q: %s", ptr, err.Error())
+		}
+	}
+
+	// before 2020-12 items could be an array, which is now prefixItems
+	if _, ok := s["items"].([]any); ok {
+		if n.prefixItems, err = subs("items"); err != nil {
+			return err
+		}
+		if n.additionalItems, err = sub("additionalItems"); err != nil {
+			return err
+		}
+	} else {
+		if n.prefixItems, err = subs("prefixItems"); err != nil {
+			return err
+		}
+		if n.additionalItems, err = sub("items"); err != nil {
+			return err
+		}
+	}
+	n.minItems = integer(s, "minItems")
+	n.maxItems = integer(s, "maxItems")
+	n.uniqueItems, _ = s["uniqueItems"].(bool)
+
+	if props, ok := s["properties"].(map[string]any); ok {
+		n.properties = make(map[string]*node, len(props))
+		for name, v := range props {
+			if n.properties[name], err = c.compile(v, ptr+"/properties/"+escape(name)); err != nil {
+				return err
+			}
+			n.propertyNames = append(n.propertyNames, name)
+		}
+		sort.Strings(n.propertyNames)
+	}
+	if props, ok := s["patternProperties"].(map[string]any); ok {
+		patterns := make([]string, 0, len(props))
+		for pattern := range props {
+			patterns = append(patterns, pattern)
+		}
+		sort.Strings(patterns)
+		for _, pattern := range patterns {
+			re, err := regexp.Compile(pattern)
+			if err != nil {
+				return fmt.Errorf("invalid schema at %q: %s", ptr, err.Error())
+			}
+			sn, err := c.compile(props[pattern], ptr+"/patternProperties/"+escape(pattern))
+			if err != nil {
+				return err
+			}
+			n.patternProperties = append(n.patternProperties, patternProperty{re, sn})
+		}
+	}
+	if n.additionalProperties, err = sub("additionalProperties"); err != nil {
+		return err
+	}
+	if required, ok := s["required"].([]any); ok {
+		for _, name := range required {
+			if name, ok := name.(string); ok {
+				n.required = append(n.required, name)
+			}
+		}
+	}
+	n.minProperties = integer(s, "minProperties")
+	n.maxProperties = integer(s, "maxProperties")
+
+	if n.allOf, err = subs("allOf"); err != nil {
+		return err
+	}
+	if n.anyOf, err = subs("anyOf"); err != nil {
+		return err
+	}
+	if n.oneOf, err = subs("oneOf"); err != nil {
+		return err
+	}
+	if n.not, err = sub("not"); err != nil {
+		return err
+	}
+
+	// definitions are compiled so invalid ones are reported even if unused
+	for _, key := range []string{"$defs", "definitions"} {
+		if defs, ok := s[key].(map[string]any); ok {
+			for name, v := range defs {
+				if _, err := c.compile(v, ptr+"/"+key+"/"+escape(name)); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return nil
+}
+
+func escape(token string) string {
+	return strings.ReplaceAll(strings.ReplaceAll(token, "~", "~0"), "/", "~1")
+}
+
+func number(s map[string]any, key string) *float64 {
+	if f, ok := s[key].(float64); ok {
+		return &f
+	}
+	return nil
+}
+
+func integer(s map[string]any, key string) *int {
+	if f, ok := s[key].(float64); ok {
+		i := int(f)
+		return &i
+	}
+	return nil
+}
+
+// ValidateJSON parses and validates a JSON document, it returns a
+// *ValidationError with the first error found
+func (s *Schema) ValidateJSON(data []byte) error {
+	var v any
+	if err := json.Unmarshal(data, &v); err != nil {
+		return &ValidationError{Message: "invalid JSON: " + err.Error()}
+	}
+	return s.Validate(v)
+}
+
+// Validate validates a document decoded by encoding/json, it returns a
+// *ValidationError with the first error found
+func (s *Schema) Validate(v any) error {
+	if msg, path := s.root.validate(v, ""); msg != "" {
+		return &ValidationError{Path: path, Message: msg}
+	}
+	return nil
+}
+
+// validate returns the message and the path of the first error
+func (n *node) validate(v any, path string) (string, string) {
+	if n.always != nil {
+		if *n.always {
+			return "", ""
+		}
+		return "not allowed", path
+	}
+	if n.ref != nil {
+		if msg, p := n.ref.validate(v, path); msg != "" {
+			return msg, p
+		}
+	}
+
+	if len(n.types) > 0 {
+		valid := false
+		for _, t := range n.types {
+			if hasType(v, t) {
+				valid = true
+				break
+			}
+		}
+		if !valid {
+			return fmt.Sprintf("expected %s, have %s", strings.Join(n.types, " or "), typeOf(v)), path
+		}
+	}
+	if n.enum != nil {
+		valid := false
+		for _, e := range n.enum {
+			if reflect.DeepEqual(e, v) {
+				valid = true
+				break
+			}
+		}
+		if !valid {
+			return "value is not one of the allowed values", path
+		}
+	}
+	if n.hasConst && !reflect.DeepEqual(n.constant, v) {
+		return "value is not the expected constant", path
+	}
+
+	var msg, p string
+	switch t := v.(type) {
+	case float64:
+		msg = n.validateNumber(t)
+		p = path
+	case string:
+		msg = n.validateString(t)
+		p = path
+	case []any:
+		msg, p = n.validateArray(t, path)
+	case map[string]any:
+		msg, p = n.validateObject(t, path)
+	}
+	if msg != "" {
+		return msg, p
+	}
+
+	for _, sn := range n.allOf {
+		if msg, p := sn.validate(v, path); msg != "" {
+			return msg, p
+		}
+	}
+	if len(n.anyOf) > 0 && n.count(n.anyOf, v, path, 1) == 0 {
+		return "value doesn't match any of the schemas of anyOf", path
+	}
+	if len(n.oneOf) > 0 {
+		if matches := n.count(n.oneOf, v, path, 2); matches != 1 {
+			return fmt.Sprintf("value must match exactly one of the schemas of oneOf, it matches %d", matches), path
+		}
+	}
+	if n.not != nil {
+		if msg, _ := n.not.validate(v, path); msg == "" {
+			return "value must not match the schema of not", path
+		}
+	}
+	return "", ""
+}
+
+// count returns how many schemas the value matches, up to limit
+func (n *node) count(schemas []*node, v any, path string, limit int) int {
+	matches := 0
+	for _, sn := range schemas {
+		if msg, _ := sn.validate(v, path); msg == "" {
+			matches++
+			if matches == limit {
+				break
+			}
+		}
+	}
+	return matches
+}
+
+func (n *node) validateNumber(f float64) string {
+	switch {
+	case n.minimum != nil && f < *n.minimum:
+		return fmt.Sprintf("%v is less than the minimum %v", f, *n.minimum)
+	case n.maximum != nil && f > *n.maximum:
+		return fmt.Sprintf("%v is greater than the maximum %v", f, *n.maximum)
+	case n.exclusiveMinimum != nil && f <= *n.exclusiveMinimum:
+		return fmt.Sprintf("%v must be greater than %v", f, *n.exclusiveMinimum)
+	case n.exclusiveMaximum != nil && f >= *n.exclusiveMaximum:
+		return fmt.Sprintf("%v must be less than %v", f, *n.exclusiveMaximum)
+	case n.multipleOf != nil && *n.multipleOf > 0 && !isInteger(f / *n.multipleOf):
+		return fmt.Sprintf("%v is not a multiple of %v", f, *n.multipleOf)
+	}
+	return ""
+}
+
+func (n *node) validateString(s string) string {
+	length := -1
+	if n.minLength != nil || n.maxLength != nil {
+		length = utf8.RuneCountInString(s)
+	}
+	switch {
+	case n.minLength != nil && length < *n.minLength:
+		return fmt.Sprintf("string is shorter than %d characters", *n.minLength)
+	case n.maxLength != nil && length > *n.maxLength:
+		return fmt.Sprintf("string is longer than %d characters", *n.maxLength)
+	case n.pattern != nil && !n.pattern.MatchString(s):
+		return fmt.Sprintf("string doesn't match the pattern %q", n.pattern.String())
+	}
+	return ""
+}
+
+func (n *node) validateArray(a []any, path string) (string, string) {
+	switch {
+	case n.minItems != nil && len(a) < *n.minItems:
+		return fmt.Sprintf("array has less than %d items", *n.minItems), path
+	case n.maxItems != nil && len(a) > *n.maxItems:
+		return fmt.Sprintf("array has more than %d items", *n.maxItems), path
+	}
+	if n.uniqueItems {
+		for i := range a {
+			for j := i + 1; j < len(a); j++ {
+				if reflect.DeepEqual(a[i], a[j]) {
+					return fmt.Sprintf("items %d and %d are equal", i, j), path
+				}
+			}
+		}
+	}
+	for i, item := range a {
+		sn := n.additionalItems
+		if i < len(n.prefixItems) {
+			sn = n.prefixItems[i]
+		}
+		if sn == nil {
+			continue
+		}
+		if msg, p := sn.validate(item, path+"/"+strconv.Itoa(i)); msg != "" {
+			return msg, p
+		}
+	}
+	return "", ""
+}
+
+func (n *node) validateObject(o map[string]any, path string) (string, string) {
+	switch {
+	case n.minProperties != nil && len(o) < *n.minProperties:
+		return fmt.Sprintf("object has less than %d properties", *n.minProperties), path
+	case n.maxProperties != nil && len(o) > *n.maxProperties:
+		return fmt.Sprintf("object has more than %d properties", *n.maxProperties), path
+	}
+	for _, name := range n.required {
+		if _, ok := o[name]; !ok {
+			return fmt.Sprintf("missing required property %q", name), path
+		}
+	}
+	for _, name := range n.propertyNames {
+		if v, ok := o[name]; ok {
+			if msg, p := n.properties[name].validate(v, path+"/"+escape(name)); msg != "" {
+				return msg, p
+			}
+		}
+	}
+	if len(n.patternProperties) == 0 && n.additionalProperties == nil {
+		return "", ""
+	}
+
+	// properties are validated in order so the first error is deterministic
+	names := make([]string, 0, len(o))
+	for name := range o {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		_, matched := n.properties[name]
+		for _, pp := range n.patternProperties {
+			if !pp.re.MatchString(name) {
+				continue
+			}
+			matched = true
+			if msg, p := pp.schema.validate(o[name], path+"/"+escape(name)); msg != "" {
+				return msg, p
+			}
+		}
+		if matched || n.additionalProperties == nil {
+			continue
+		}
+		if a := n.additionalProperties.always; a != nil && !*a {
+			return fmt.Sprintf("additional property %q is not allowed", name), path
+		}
+		if msg, p := n.additionalProperties.validate(o[name], path+"/"+escape(name)); msg != "" {
+			return msg, p
+		}
+	}
+	return "", ""
+}
+
+func isInteger(f float64) bool {
+	return !math.IsInf(f, 0) && math.Trunc(f) == f
+}
+
+func hasType(v any, t string) bool {
+	switch t {
+	case "integer":
+		f, ok := v.(float64)
+		return ok && isInteger(f)
+	case "number":
+		_, ok := v.(float64)
+		return ok
+	}
+	return typeOf(v) == t
+}
+
+func typeOf(v any) string {
+	switch v.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case float64:
+		return "number"
+	case string:
+		return "string"
+	case []any:
+		return "array"
+	case map[string]any:
+		return "object"
+	}
+	return "unknown"
+}
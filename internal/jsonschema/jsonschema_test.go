@@ -0,0 +1,94 @@
+// Copyright 2024 Juan Pablo Tosso and the OWASP Coraza contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package jsonschema
+
+import (
+	"errors"
+	"testing"
+)
+
+const userSchema = `{
+	"$schema": "https://json-schema.org/draft/2020-12/schema",
+	"type": "object",
+	"required": ["name", "age"],
+	"additionalProperties": false,
+	"properties": {
+		"name": {"type": "string", "minLength": 2, "maxLength": 5, "pattern": "^[a-z]+$"},
+		"age": {"type": "integer", "minimum": 0, "exclusiveMaximum": 150},
+		"role": {"enum": ["admin", "user"]},
+		"tags": {"type": "array", "items": {"type": "string"}, "maxItems": 2, "uniqueItems": true},
+		"address": {"$ref": "#/$defs/address"},
+		"contact": {"oneOf": [{"required": ["email"]}, {"required": ["phone"]}]},
+		"parent": {"$ref": "#"}
+	},
+	"$defs": {
+		"address": {
+			"type": "object",
+			"properties": {"zip": {"type": ["string", "null"], "not": {"const": "00000"}}}
+		}
+	}
+}`
+
+func TestValidate(t *testing.T) {
+	schema, err := Compile([]byte(userSchema))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		doc  string
+		want string
+	}{
+		{`{"name": "ann", "age": 30}`, ""},
+		{`{"name": "ann", "age": 30, "role": "user", "tags": ["a", "b"], "address": {"zip": null}}`, ""},
+		{`{"name": "ann", "age": 30, "contact": {"email": "a@b"}}`, ""},
+		{`{"name": "ann", "age": 30, "parent": {"name": "bob", "age": 60}}`, ""},
+		{`[]`, "expected object, have array"},
+		{`{"name": "ann"}`, `missing required property "age"`},
+		{`{"name": "ann", "age": 30.5}`, "/age: expected integer, have number"},
+		{`{"name": "ann", "age": 150}`, "/age: 150 must be less than 150"},
+		{`{"name": "ann", "age": -1}`, "/age: -1 is less than the minimum 0"},
+		{`{"name": "a", "age": 30}`, "/name: string is shorter than 2 characters"},
+		{`{"name": "annabel", "age": 30}`, "/name: string is longer than 5 characters"},
+		{`{"name": "Ann", "age": 30}`, `/name: string doesn't match the pattern "^[a-z]+$"`},
+		{`{"name": "ann", "age": 30, "role": "root"}`, "/role: value is not one of the allowed values"},
+		{`{"name": "ann", "age": 30, "tags": ["a", 1]}`, "/tags/1: expected string, have number"},
+		{`{"name": "ann", "age": 30, "tags": ["a", "a"]}`, "/tags: items 0 and 1 are equal"},
+		{`{"name": "ann", "age": 30, "tags": ["a", "b", "c"]}`, "/tags: array has more than 2 items"},
+		{`{"name": "ann", "age": 30, "address": {"zip": "00000"}}`, "/address/zip: value must not match the schema of not"},
+		{`{"name": "ann", "age": 30, "contact": {"email": "a@b", "phone": "1"}}`, "/contact: value must match exactly one of the schemas of oneOf, it matches 2"},
+		{`{"name": "ann", "age": 30, "parent": {"name": "bob"}}`, `/parent: missing required property "age"`},
+		{`{"name": "ann", "age": 30, "admin": true}`, `additional property "admin" is not allowed`},
+		{`{"name": "ann",`, "invalid JSON: unexpected end of JSON input"},
+	}
+	for _, tt := range tests {
+		err := schema.ValidateJSON([]byte(tt.doc))
+		have := ""
+		if err != nil {
+			have = err.Error()
+		}
+		if tt.want != have {
+			t.Errorf("unexpected result for %s, want %q, have %q", tt.doc, tt.want, have)
+		}
+		var verr *ValidationError
+		if err != nil && !errors.As(err, &verr) {
+			t.Errorf("expected a validation error, have %T", err)
+		}
+	}
+}
+
+func TestCompileErrors(t *testing.T) {
+	for _, schema := range []string{
+		`{`,
+		`"string"`,
+		`{"properties": {"a": 1}}`,
+		`{"pattern": "("}`,
+		`{"$ref": "#/$defs/missing"}`,
+		`{"$ref": "https://example.com/schema.json"}`,
+		`{"allOf": {}}`,
+	} {
+		if _, err := Compile([]byte(schema)); err == nil {
+			t.Errorf("expected error compiling %s", schema)
+		}
+	}
+}
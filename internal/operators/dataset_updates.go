@@ -0,0 +1,50 @@
+// Copyright 2024 Juan Pablo Tosso and the OWASP Coraza contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package operators
+
+import (
+	"sync/atomic"
+
+	"github.com/ad3n/seclang/experimental/plugins/plugintypes"
+)
+
+// datasetBuilder builds an operator matching the values of a dataset
+type datasetBuilder func(values []string) (plugintypes.Operator, error)
+
+// datasetOperator matches the values of a dataset, the ones it was compiled
+// with until the dataset is updated in the feeds of the WAF
+type datasetOperator struct {
+	compiled plugintypes.Operator
+	updated  *atomic.Pointer[plugintypes.Operator]
+}
+
+var _ plugintypes.Operator = (*datasetOperator)(nil)
+
+// newDatasetOperator returns the operator named name matching the dataset,
+// compiled with the values of the WAF, or built with the values of the last
+// update of the dataset in the feeds of the WAF if any
+func newDatasetOperator(options plugintypes.OperatorOptions, name, dataset string, build datasetBuilder, compile func() (plugintypes.Operator, error)) (plugintypes.Operator, error) {
+	updated := &atomic.Pointer[plugintypes.Operator]{}
+	if options.DatasetFeeds != nil {
+		var err error
+		if updated, err = options.DatasetFeeds.Subscribe(dataset, name, build); err != nil {
+			return nil, err
+		}
+	}
+	if updated.Load() != nil {
+		return &datasetOperator{updated: updated}, nil
+	}
+	compiled, err := compile()
+	if err != nil {
+		return nil, err
+	}
+	return &datasetOperator{compiled: compiled, updated: updated}, nil
+}
+
+func (o *datasetOperator) Evaluate(tx plugintypes.TransactionState, value string) bool {
+	if op := o.updated.Load(); op != nil {
+		return (*op).Evaluate(tx, value)
+	}
+	return o.compiled.Evaluate(tx, value)
+}
@@ -0,0 +1,120 @@
+// Copyright 2024 Juan Pablo Tosso and the OWASP Coraza contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package operators
+
+import (
+	"errors"
+	"slices"
+	"sync"
+	"testing"
+
+	"github.com/ad3n/seclang/experimental/plugins/plugintypes"
+	"github.com/ad3n/seclang/internal/corazawaf"
+)
+
+type datasetValuesMatch []string
+
+func (m datasetValuesMatch) Evaluate(_ plugintypes.TransactionState, value string) bool {
+	return slices.Contains(m, value)
+}
+
+func TestUpdateDataset(t *testing.T) {
+	build := func(values []string) (plugintypes.Operator, error) {
+		if slices.Contains(values, "invalid") {
+			return nil, errors.New("invalid value")
+		}
+		return datasetValuesMatch(values), nil
+	}
+	compile := func(values ...string) func() (plugintypes.Operator, error) {
+		return func() (plugintypes.Operator, error) { return datasetValuesMatch(values), nil }
+	}
+	feeds := &corazawaf.DatasetFeeds{}
+	options := plugintypes.OperatorOptions{DatasetFeeds: feeds}
+
+	op, err := newDatasetOperator(options, "testDatasetMatch", "test_update", build, compile("a"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !op.Evaluate(nil, "a") || op.Evaluate(nil, "b") {
+		t.Error("expected the compiled values to match before the update")
+	}
+
+	if err := feeds.Update("test_update", []string{"b"}); err != nil {
+		t.Fatal(err)
+	}
+	if op.Evaluate(nil, "a") || !op.Evaluate(nil, "b") {
+		t.Error("expected the updated values to match")
+	}
+
+	if err := feeds.Update("test_update", []string{"c", "invalid"}); err == nil {
+		t.Error("expected error updating with invalid values")
+	}
+	if !op.Evaluate(nil, "b") {
+		t.Error("expected the previous values to be kept after a failed update")
+	}
+
+	later, err := newDatasetOperator(options, "testDatasetMatch", "test_update", build, compile("a"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if later.Evaluate(nil, "a") || !later.Evaluate(nil, "b") {
+		t.Error("expected the operators compiled after the update to match the updated values")
+	}
+
+	other, err := newDatasetOperator(options, "testDatasetMatch", "test_other", build, compile("a"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !other.Evaluate(nil, "a") {
+		t.Error("unexpected update of another dataset")
+	}
+
+	// the feeds of another WAF are not updated
+	otherWAF, err := newDatasetOperator(plugintypes.OperatorOptions{DatasetFeeds: &corazawaf.DatasetFeeds{}}, "testDatasetMatch", "test_update", build, compile("a"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !otherWAF.Evaluate(nil, "a") || otherWAF.Evaluate(nil, "b") {
+		t.Error("unexpected update of the dataset of another WAF")
+	}
+}
+
+func TestUpdateDatasetConcurrently(t *testing.T) {
+	feeds := &corazawaf.DatasetFeeds{}
+	op, err := newPMFromDataset(plugintypes.OperatorOptions{
+		Arguments:    "test_concurrent",
+		Datasets:     map[string][]string{"test_concurrent": {"attack"}},
+		DatasetFeeds: feeds,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	waf := corazawaf.NewWAF()
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			tx := waf.NewTransaction()
+			defer tx.Close()
+			for j := 0; j < 100; j++ {
+				// either the previous or the new values match
+				if !op.Evaluate(tx, "attack exploit") {
+					t.Error("expected a match during the updates")
+					return
+				}
+			}
+		}()
+	}
+	for i := 0; i < 10; i++ {
+		values := []string{"attack"}
+		if i%2 == 0 {
+			values = []string{"exploit"}
+		}
+		if err := feeds.Update("test_concurrent", values); err != nil {
+			t.Fatal(err)
+		}
+	}
+	wg.Wait()
+}
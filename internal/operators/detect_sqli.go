@@ -11,6 +11,15 @@ import (
 	"github.com/ad3n/seclang/experimental/plugins/plugintypes"
 )
 
+// sqliFingerprintKey is the TX key of the libinjection fingerprint of the
+// last value @detectSQLi matched, e.g. 1UEnk for a union based injection
+const sqliFingerprintKey = "sqli_fingerprint"
+
+// detectSQLi matches the values libinjection detects as SQL injections. The
+// fingerprint of the tokens of the value is captured in TX:0 and set in
+// TX:sqli_fingerprint, for follow-up rules and the audit log to tell the
+// kinds of attack apart, e.g.
+// SecRule ARGS "@detectSQLi" "id:1,phase:2,deny,logdata:'%{TX.sqli_fingerprint}'"
 type detectSQLi struct{}
 
 var _ plugintypes.Operator = (*detectSQLi)(nil)
@@ -25,6 +34,7 @@ func (o *detectSQLi) Evaluate(tx plugintypes.TransactionState, value string) boo
 		return false
 	}
 	tx.CaptureField(0, fingerprint)
+	tx.Variables().TX().Set(sqliFingerprintKey, []string{fingerprint})
 	return true
 }
 
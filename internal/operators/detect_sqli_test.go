@@ -4,6 +4,7 @@
 package operators
 
 import (
+	"strings"
 	"testing"
 
 	"github.com/ad3n/seclang/internal/corazawaf"
@@ -28,3 +29,31 @@ func FuzzSQLi(f *testing.F) {
 		_ = sqli.Evaluate(tx, tc)
 	})
 }
+
+func TestDetectSQLiFingerprint(t *testing.T) {
+	tests := []struct {
+		value       string
+		match       bool
+		fingerprint string
+	}{
+		{"this is not isqli", false, ""},
+		{"' or ''='", true, "s&sos"},
+		{"1 union select password from users", true, "1UEnk"},
+	}
+	sqli := &detectSQLi{}
+	waf := corazawaf.NewWAF()
+	for _, tt := range tests {
+		tx := waf.NewTransaction()
+		tx.Capture = true
+		if want, have := tt.match, sqli.Evaluate(tx, tt.value); want != have {
+			t.Errorf("unexpected result for %q, want %t, have %t", tt.value, want, have)
+		}
+		if want, have := tt.fingerprint, strings.Join(tx.Variables().TX().Get(sqliFingerprintKey), ","); want != have {
+			t.Errorf("unexpected fingerprint of %q, want %q, have %q", tt.value, want, have)
+		}
+		if want, have := tt.fingerprint, strings.Join(tx.Variables().TX().Get("0"), ","); want != have {
+			t.Errorf("unexpected capture of %q, want %q, have %q", tt.value, want, have)
+		}
+		tx.Close()
+	}
+}
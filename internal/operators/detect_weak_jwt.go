@@ -0,0 +1,165 @@
+// Copyright 2024 Juan Pablo Tosso and the OWASP Coraza contributors
+// SPDX-License-Identifier: Apache-2.0
+
+//go:build !coraza.disabled_operators.detectWeakJWT
+
+package operators
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/json"
+	"hash"
+	"net"
+	"net/url"
+	"slices"
+	"strings"
+
+	"github.com/ad3n/seclang/experimental/plugins/plugintypes"
+)
+
+// weakJWTSecrets are the HMAC secrets of the tutorials and default
+// configurations, the tokens signed with them are forgeable
+var weakJWTSecrets = []string{
+	"", "secret", "password", "changeme", "key", "jwt", "test", "123456",
+	"your-256-bit-secret", "your-384-bit-secret", "your-512-bit-secret",
+	"secretkey", "jwt_secret", "jwtsecret", "supersecret", "admin",
+}
+
+// weakJWTHashes are the hashes of the HMAC algorithms, a key shorter than
+// the size of the hash is weak
+var weakJWTHashes = map[string]func() hash.Hash{
+	"HS256": sha256.New,
+	"HS384": sha512.New384,
+	"HS512": sha512.New,
+}
+
+// @detectWeakJWT decodes the JSON web token of the input, optionally prefixed
+// by Bearer, without verifying it and matches if it is forgeable:
+// - the alg header is none or missing
+// - the alg header is HS256, HS384 or HS512 and the token is signed with a
+// well known secret or carries a jwk header with a key shorter than the hash
+// - the kid or jku header is a URL pointing to another host
+// The argument is the space separated list of the trusted hosts, the server
+// name set by the connector (SERVER_NAME) if empty. The Host header is never
+// trusted as the client controls it. The reason of the match is captured in
+// TX:0.
+type detectWeakJWT struct {
+	hosts []string
+}
+
+var _ plugintypes.Operator = (*detectWeakJWT)(nil)
+
+func newDetectWeakJWT(options plugintypes.OperatorOptions) (plugintypes.Operator, error) {
+	var hosts []string
+	for _, h := range strings.Fields(options.Arguments) {
+		hosts = append(hosts, strings.ToLower(h))
+	}
+	return &detectWeakJWT{hosts: hosts}, nil
+}
+
+func (o *detectWeakJWT) Evaluate(tx plugintypes.TransactionState, value string) bool {
+	reason := o.weakness(tx, value)
+	if reason == "" {
+		return false
+	}
+	if tx.Capturing() {
+		tx.CaptureField(0, reason)
+	}
+	return true
+}
+
+// weakness returns why the token is forgeable, empty if it is not or if the
+// input is not a token
+func (o *detectWeakJWT) weakness(tx plugintypes.TransactionState, value string) string {
+	value = strings.TrimSpace(value)
+	if len(value) > 7 && strings.EqualFold(value[:7], "bearer ") {
+		value = strings.TrimSpace(value[7:])
+	}
+	parts := strings.Split(value, ".")
+	if len(parts) != 3 {
+		return ""
+	}
+	raw, err := decodeJWTSegment(parts[0])
+	if err != nil {
+		return ""
+	}
+	var header map[string]interface{}
+	if err := json.Unmarshal(raw, &header); err != nil {
+		return ""
+	}
+
+	alg, _ := header["alg"].(string)
+	if alg == "" || strings.EqualFold(alg, "none") {
+		return "alg none"
+	}
+	if newHash, ok := weakJWTHashes[strings.ToUpper(alg)]; ok {
+		if reason := hmacWeakness(newHash, header, parts); reason != "" {
+			return reason
+		}
+	}
+	for _, name := range []string{"kid", "jku"} {
+		v, _ := header[name].(string)
+		if host := jwtURLHost(v); host != "" && !o.trusted(tx, host) {
+			return name + " points to " + host
+		}
+	}
+	return ""
+}
+
+// hmacWeakness returns why the key of a token signed with HMAC is weak
+func hmacWeakness(newHash func() hash.Hash, header map[string]interface{}, parts []string) string {
+	signature, err := decodeJWTSegment(parts[2])
+	if err != nil {
+		return ""
+	}
+	signed := parts[0] + "." + parts[1]
+	for _, secret := range weakJWTSecrets {
+		mac := hmac.New(newHash, []byte(secret))
+		mac.Write([]byte(signed))
+		if hmac.Equal(signature, mac.Sum(nil)) {
+			return "weak secret"
+		}
+	}
+	if jwk, ok := header["jwk"].(map[string]interface{}); ok {
+		k, _ := jwk["k"].(string)
+		if key, err := decodeJWTSegment(k); err == nil && len(key) < newHash().Size() {
+			return "short key"
+		}
+	}
+	return ""
+}
+
+// decodeJWTSegment decodes a base64url segment, with or without padding
+func decodeJWTSegment(s string) ([]byte, error) {
+	return base64.RawURLEncoding.DecodeString(strings.TrimRight(s, "="))
+}
+
+// jwtURLHost returns the lowercased host of the header if it is a URL
+func jwtURLHost(v string) string {
+	if !strings.Contains(v, "//") {
+		return ""
+	}
+	u, err := url.Parse(v)
+	if err != nil {
+		return ""
+	}
+	return strings.ToLower(u.Hostname())
+}
+
+func (o *detectWeakJWT) trusted(tx plugintypes.TransactionState, host string) bool {
+	if len(o.hosts) > 0 {
+		return slices.Contains(o.hosts, host)
+	}
+	serverName := tx.Variables().ServerName().Get()
+	if h, _, err := net.SplitHostPort(serverName); err == nil {
+		serverName = h
+	}
+	return serverName != "" && strings.ToLower(serverName) == host
+}
+
+func init() {
+	Register("detectWeakJWT", newDetectWeakJWT)
+}
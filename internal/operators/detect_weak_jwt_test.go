@@ -0,0 +1,81 @@
+// Copyright 2024 Juan Pablo Tosso and the OWASP Coraza contributors
+// SPDX-License-Identifier: Apache-2.0
+
+//go:build !coraza.disabled_operators.detectWeakJWT
+
+package operators
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"testing"
+
+	"github.com/ad3n/seclang/experimental/plugins/plugintypes"
+	"github.com/ad3n/seclang/internal/corazawaf"
+)
+
+func signJWT(header string, secret string) string {
+	signed := base64.RawURLEncoding.EncodeToString([]byte(header)) + "." +
+		base64.RawURLEncoding.EncodeToString([]byte(`{"sub":"admin"}`))
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(signed))
+	return signed + "." + base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+func TestDetectWeakJWT(t *testing.T) {
+	const strong = "4f1b8c2e9d7a6b5c3e2f1a0b9c8d7e6f"
+	tests := []struct {
+		hosts  string
+		token  string
+		reason string
+	}{
+		{"", signJWT(`{"alg":"HS256"}`, strong), ""},
+		{"", "Bearer " + signJWT(`{"alg":"HS256"}`, strong), ""},
+		{"", signJWT(`{"alg":"none"}`, ""), "alg none"},
+		{"", signJWT(`{"alg":"NONE","typ":"JWT"}`, strong), "alg none"},
+		{"", signJWT(`{"typ":"JWT"}`, strong), "alg none"},
+		{"", "bearer " + signJWT(`{"alg":"HS256"}`, "secret"), "weak secret"},
+		{"", signJWT(`{"alg":"HS256"}`, ""), "weak secret"},
+		{"", signJWT(`{"alg":"HS256","jwk":{"kty":"oct","k":"c2hvcnQ"}}`, strong), "short key"},
+		{"", signJWT(`{"alg":"HS256","jku":"https://evil.example/keys.json"}`, strong), "jku points to evil.example"},
+		{"", signJWT(`{"alg":"HS256","jku":"https://WWW.Example.com:8443/keys.json"}`, strong), ""},
+		{"", signJWT(`{"alg":"HS256","jku":"https://attacker.example/keys.json"}`, strong), "jku points to attacker.example"},
+		{"", signJWT(`{"alg":"HS256","kid":"//evil.example/key"}`, strong), "kid points to evil.example"},
+		{"", signJWT(`{"alg":"HS256","kid":"2024-01"}`, strong), ""},
+		{"keys.example", signJWT(`{"alg":"HS256","jku":"https://keys.example/keys.json"}`, strong), ""},
+		{"keys.example", signJWT(`{"alg":"HS256","jku":"https://www.example.com/keys.json"}`, strong), "jku points to www.example.com"},
+		{"", "not.a.token", ""},
+		{"", "session=abc", ""},
+	}
+	waf := corazawaf.NewWAF()
+	for _, tt := range tests {
+		op, err := newDetectWeakJWT(plugintypes.OperatorOptions{Arguments: tt.hosts})
+		if err != nil {
+			t.Fatal(err)
+		}
+		tx := waf.NewTransaction()
+		tx.SetServerName("www.example.com")
+		// the Host header is controlled by the client
+		tx.AddRequestHeader("Host", "attacker.example")
+		tx.Capture = true
+		if want, have := tt.reason != "", op.Evaluate(tx, tt.token); want != have {
+			t.Errorf("unexpected result for %q (hosts %q), want %t, have %t", tt.token, tt.hosts, want, have)
+		}
+		if want, have := tt.reason, tx.Variables().TX().Get("0"); tt.reason != "" && (len(have) != 1 || have[0] != want) {
+			t.Errorf("unexpected capture for %q, want %q, have %q", tt.token, want, have)
+		}
+		tx.Close()
+	}
+	// no host is trusted without hosts nor server name
+	op, err := newDetectWeakJWT(plugintypes.OperatorOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tx := waf.NewTransaction()
+	defer tx.Close()
+	tx.AddRequestHeader("Host", "www.example.com")
+	if !op.Evaluate(tx, signJWT(`{"alg":"HS256","jku":"https://www.example.com/keys.json"}`, strong)) {
+		t.Error("expected a match for a jku without trusted hosts")
+	}
+}
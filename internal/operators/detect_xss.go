@@ -6,21 +6,145 @@
 package operators
 
 import (
+	"fmt"
+	"regexp"
+	"strings"
+
 	"github.com/corazawaf/libinjection-go"
 
 	"github.com/ad3n/seclang/experimental/plugins/plugintypes"
 )
 
-type detectXSS struct{}
+// xssHeuristicKey is the TX key of the heuristic that fired for the last
+// value @detectXSS matched
+const xssHeuristicKey = "xss_heuristic"
+
+// xssContext returns the heuristic detecting an XSS in the value inserted in
+// a context of the page, empty if there is none
+type xssContext func(value string) string
+
+// xssContexts are the contexts @detectXSS can inspect values for:
+// - html: anywhere in the page, libinjection detects the markup executing
+// scripts, it is the default
+// - attribute: in a quoted attribute value, the value must close the quote
+// to add an event handler or a tag
+// - url: in a URL attribute, e.g. href, the value must have a scheme
+// executing scripts
+// - js: in a JavaScript string literal, the value must close the string or
+// the script element
+var xssContexts = map[string]xssContext{
+	"html":      xssHTML,
+	"attribute": xssAttribute,
+	"url":       xssURL,
+	"js":        xssJS,
+}
+
+// detectXSS matches the values that are XSS in any of the contexts of the
+// argument, a comma separated list, html if empty. Inspecting the values in
+// the context they are inserted in reduces the false positives, e.g. of the
+// markup of rich text fields only inserted in attributes. The heuristic that
+// fired is captured in TX:0 and set in TX:xss_heuristic, e.g.
+// SecRule ARGS:title "@detectXSS attribute,js" "id:1,phase:2,deny,logdata:'%{TX.xss_heuristic}'"
+type detectXSS struct {
+	contexts []xssContext
+}
 
 var _ plugintypes.Operator = (*detectXSS)(nil)
 
-func newDetectXSS(plugintypes.OperatorOptions) (plugintypes.Operator, error) {
-	return &detectXSS{}, nil
+func newDetectXSS(options plugintypes.OperatorOptions) (plugintypes.Operator, error) {
+	if strings.TrimSpace(options.Arguments) == "" {
+		return &detectXSS{contexts: []xssContext{xssHTML}}, nil
+	}
+	var contexts []xssContext
+	for _, name := range strings.Split(options.Arguments, ",") {
+		context, ok := xssContexts[strings.ToLower(strings.TrimSpace(name))]
+		if !ok {
+			return nil, fmt.Errorf("invalid @detectXSS context %q, expected html, attribute, url or js", strings.TrimSpace(name))
+		}
+		contexts = append(contexts, context)
+	}
+	return &detectXSS{contexts: contexts}, nil
+}
+
+func (o *detectXSS) Evaluate(tx plugintypes.TransactionState, value string) bool {
+	for _, context := range o.contexts {
+		heuristic := context(value)
+		if heuristic == "" {
+			continue
+		}
+		if tx.Capturing() {
+			tx.CaptureField(0, heuristic)
+		}
+		tx.Variables().TX().Set(xssHeuristicKey, []string{heuristic})
+		return true
+	}
+	return false
+}
+
+func xssHTML(value string) string {
+	if libinjection.IsXSS(value) {
+		return "libinjection"
+	}
+	return ""
 }
 
-func (o *detectXSS) Evaluate(_ plugintypes.TransactionState, value string) bool {
-	return libinjection.IsXSS(value)
+var (
+	// xssEventHandler is a quote closing the attribute followed by an event
+	// handler attribute, e.g. " autofocus onfocus=
+	xssEventHandler = regexp.MustCompile("(?i)[\"'`][^<>]*?[\\s/\"'`]on[a-z]+\\s*=")
+	// xssTagClose is a quote closing the attribute and its tag
+	xssTagClose = regexp.MustCompile("[\"'`][\\s/]*>")
+)
+
+func xssAttribute(value string) string {
+	if xssEventHandler.MatchString(value) {
+		return "event handler"
+	}
+	if loc := xssTagClose.FindStringIndex(value); loc != nil && libinjection.IsXSS(value[loc[1]:]) {
+		return "tag injection"
+	}
+	return ""
+}
+
+// xssDataMediaTypes are the media types of the data URLs browsers render as
+// documents
+var xssDataMediaTypes = []string{"text/html", "image/svg+xml", "application/xhtml+xml", "text/xml", "application/xml"}
+
+func xssURL(value string) string {
+	// browsers ignore the leading control characters and spaces and the
+	// tabs and newlines of URLs
+	url := strings.TrimLeft(value, "\x00\x01\x02\x03\x04\x05\x06\x07\x08\t\n\x0b\x0c\r\x0e\x0f\x10\x11\x12\x13\x14\x15\x16\x17\x18\x19\x1a\x1b\x1c\x1d\x1e\x1f ")
+	url = strings.ToLower(strings.NewReplacer("\t", "", "\n", "", "\r", "").Replace(url))
+	if strings.HasPrefix(url, "javascript:") || strings.HasPrefix(url, "vbscript:") {
+		return "script scheme"
+	}
+	if mediaType, ok := strings.CutPrefix(url, "data:"); ok {
+		for _, t := range xssDataMediaTypes {
+			if strings.HasPrefix(strings.TrimSpace(mediaType), t) {
+				return "data scheme"
+			}
+		}
+	}
+	return ""
+}
+
+var (
+	// xssStringBreakout is a quote not escaped by a backslash followed by an
+	// operator or the end of a statement, e.g. ';alert(1)// or "-alert(1)-"
+	xssStringBreakout = regexp.MustCompile("(?:^|[^\\\\])(?:\\\\\\\\)*[\"'`]\\s*[-+*/%;,)}\\]|&^?:<>=]")
+	xssScriptClose    = regexp.MustCompile(`(?i)</script`)
+)
+
+func xssJS(value string) string {
+	switch {
+	case xssScriptClose.MatchString(value):
+		return "script close"
+	case xssStringBreakout.MatchString(value):
+		return "string breakout"
+	case strings.Contains(value, "${"):
+		return "template expression"
+	}
+	return ""
 }
 
 func init() {
@@ -4,8 +4,10 @@
 package operators
 
 import (
+	"strings"
 	"testing"
 
+	"github.com/ad3n/seclang/experimental/plugins/plugintypes"
 	"github.com/ad3n/seclang/internal/corazawaf"
 )
 
@@ -21,7 +23,7 @@ func FuzzXSS(f *testing.F) {
 	for _, tc := range xssTests {
 		f.Add(tc)
 	}
-	xss := &detectXSS{}
+	xss := &detectXSS{contexts: []xssContext{xssHTML}}
 	waf := corazawaf.NewWAF()
 	f.Fuzz(func(t *testing.T, tc string) {
 		tx := waf.NewTransaction()
@@ -29,3 +31,63 @@ func FuzzXSS(f *testing.F) {
 		_ = xss.Evaluate(tx, tc)
 	})
 }
+
+func TestDetectXSSContexts(t *testing.T) {
+	tests := []struct {
+		contexts  string
+		value     string
+		heuristic string
+	}{
+		{"", "this is not an XSS", ""},
+		{"", "<script>alert(1)</script>", "libinjection"},
+		{"html", "<a href=\"javascript:alert(1)\">", "libinjection"},
+		// rich text is only an XSS out of attributes
+		{"attribute", "<b>bold</b> and <i>italic</i>", ""},
+		{"attribute", "it's \"quoted\"", ""},
+		{"attribute", "x\" onmouseover=\"alert(1)", "event handler"},
+		{"attribute", "x' autofocus onfocus=alert(1) '", "event handler"},
+		{"attribute", "x\"/onload=alert(1)//", "event handler"},
+		{"attribute", "\"><script>alert(1)</script>", "tag injection"},
+		{"attribute", "\"> bold", ""},
+		{"url", "https://example.com/?q=javascript:", ""},
+		{"url", "JavaScript:alert(1)", "script scheme"},
+		{"url", " \x01java\tscript:alert(1)", "script scheme"},
+		{"url", "vbscript:msgbox(1)", "script scheme"},
+		{"url", "data:text/html;base64,PHNjcmlwdD4=", "data scheme"},
+		{"url", "data:image/png;base64,iVBORw0KGgo=", ""},
+		{"js", "O'Brien", ""},
+		{"js", "say \\\"hello\\\"", ""},
+		{"js", "';alert(1)//", "string breakout"},
+		{"js", "\"-alert(1)-\"", "string breakout"},
+		{"js", "\\\\';alert(1)//", "string breakout"},
+		{"js", "</script><script>alert(1)", "script close"},
+		{"js", "${alert(1)}", "template expression"},
+		{"attribute,url", "javascript:alert(1)", "script scheme"},
+		{"attribute,url", "<b>bold</b>", ""},
+	}
+	waf := corazawaf.NewWAF()
+	for _, tt := range tests {
+		xss, err := newDetectXSS(plugintypes.OperatorOptions{Arguments: tt.contexts})
+		if err != nil {
+			t.Fatal(err)
+		}
+		tx := waf.NewTransaction()
+		tx.Capture = true
+		if want, have := tt.heuristic != "", xss.Evaluate(tx, tt.value); want != have {
+			t.Errorf("unexpected result for %q in %q, want %t, have %t", tt.value, tt.contexts, want, have)
+		}
+		if want, have := tt.heuristic, strings.Join(tx.Variables().TX().Get(xssHeuristicKey), ","); want != have {
+			t.Errorf("unexpected heuristic for %q in %q, want %q, have %q", tt.value, tt.contexts, want, have)
+		}
+		if want, have := tt.heuristic, strings.Join(tx.Variables().TX().Get("0"), ","); want != have {
+			t.Errorf("unexpected capture for %q in %q, want %q, have %q", tt.value, tt.contexts, want, have)
+		}
+		tx.Close()
+	}
+
+	for _, arguments := range []string{"css", "html,", "attribute,,url"} {
+		if _, err := newDetectXSS(plugintypes.OperatorOptions{Arguments: arguments}); err == nil {
+			t.Errorf("expected error for contexts %q", arguments)
+		}
+	}
+}
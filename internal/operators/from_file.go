@@ -8,17 +8,27 @@ import (
 	"io/fs"
 	"os"
 	"path"
+	"slices"
+	"strings"
 )
 
 var errEmptyDirs = errors.New("empty dirs")
 
 func loadFromFile(filepath string, dirs []string, root fs.FS) ([]byte, error) {
+	_, content, err := findFile(filepath, dirs, root)
+	return content, err
+}
+
+// findFile returns the path and content of the file in the first directory
+// it exists in
+func findFile(filepath string, dirs []string, root fs.FS) (string, []byte, error) {
 	if path.IsAbs(filepath) {
-		return fs.ReadFile(root, filepath)
+		content, err := fs.ReadFile(root, filepath)
+		return filepath, content, err
 	}
 
 	if len(dirs) == 0 {
-		return nil, errEmptyDirs
+		return "", nil, errEmptyDirs
 	}
 
 	// handling files by operators is hard because we must know the paths where we can
@@ -36,12 +46,32 @@ func loadFromFile(filepath string, dirs []string, root fs.FS) ([]byte, error) {
 			if os.IsNotExist(err) {
 				continue
 			} else {
-				return nil, err
+				return "", nil, err
 			}
 		}
 
-		return content, nil
+		return absFilepath, content, nil
 	}
 
-	return nil, err
+	return "", nil, err
+}
+
+// cutOptions splits the trailing KEY=VALUE options of the arguments of an
+// operator from its main argument, e.g. a path that may contain spaces. Only
+// the given keys are options.
+func cutOptions(args string, keys ...string) (string, map[string]string) {
+	main, options := strings.TrimSpace(args), map[string]string{}
+	for {
+		i := strings.LastIndexAny(main, " \t")
+		if i < 0 {
+			break
+		}
+		key, value, ok := strings.Cut(main[i+1:], "=")
+		if _, seen := options[key]; !ok || seen || !slices.Contains(keys, key) {
+			break
+		}
+		options[key] = value
+		main = strings.TrimSpace(main[:i])
+	}
+	return main, options
 }
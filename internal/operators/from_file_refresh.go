@@ -0,0 +1,198 @@
+// Copyright 2024 Juan Pablo Tosso and the OWASP Coraza contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package operators
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/ad3n/seclang/experimental/plugins/plugintypes"
+	"github.com/ad3n/seclang/internal/io"
+	"github.com/corazawaf/coraza/v3/debuglog"
+)
+
+// operatorSource reads the content an operator is built from
+type operatorSource interface {
+	// read returns the content, false if it didn't change since the
+	// previous read
+	read() ([]byte, bool, error)
+	String() string
+}
+
+// fileSource is a file of the root, its modification time and size tell if
+// it changed
+type fileSource struct {
+	root    fs.FS
+	path    string
+	modTime time.Time
+	size    int64
+}
+
+func (s *fileSource) read() ([]byte, bool, error) {
+	info, err := fs.Stat(s.root, s.path)
+	if err != nil {
+		return nil, false, err
+	}
+	if info.ModTime().Equal(s.modTime) && info.Size() == s.size {
+		return nil, false, nil
+	}
+	data, err := fs.ReadFile(s.root, s.path)
+	if err != nil {
+		return nil, false, err
+	}
+	s.modTime, s.size = info.ModTime(), info.Size()
+	return data, true, nil
+}
+
+func (s *fileSource) String() string {
+	return s.path
+}
+
+// refreshingOperator evaluates an operator built from a file or a remote
+// list, e.g. a list of addresses updated by a threat intelligence feed, and
+// rebuilds it when the source changes. The source is polled at most once per
+// interval, when the operator is evaluated. It is read and the operator
+// rebuilt in the background, the transactions keep using the previous
+// operator until the new one is swapped in, and keep it if the source can't
+// be read or is invalid.
+type refreshingOperator struct {
+	name     string
+	source   operatorSource
+	interval time.Duration
+	build    func(data []byte) (plugintypes.Operator, error)
+	now      func() time.Time
+	// fail overrides the dependency failure mode of the WAF, open or
+	// closed, see newOperatorFromFile
+	fail string
+
+	// current is nil until the source was read once
+	current    atomic.Pointer[plugintypes.Operator]
+	checked    atomic.Int64
+	refreshing atomic.Bool
+}
+
+var _ plugintypes.Operator = (*refreshingOperator)(nil)
+
+// newOperatorFromFile builds an operator from the file or the https URL of
+// the arguments:
+//
+//	FILE|URL [refresh=DURATION] [fail=open|closed]
+//
+// The operator is refreshed if the refresh option is set. A remote list that
+// can't be fetched when the rules are loaded is an error, unless it is
+// refreshed: the transactions then report a dependency failure of the
+// operator until the list is fetched. The fail option overrides the
+// SecDependencyFailureMode of the operator.
+func newOperatorFromFile(name string, options plugintypes.OperatorOptions, build func(data []byte) (plugintypes.Operator, error)) (plugintypes.Operator, error) {
+	file, opts := cutOptions(options.Arguments, "refresh", "fail")
+	fail, ok := opts["fail"]
+	if ok && fail != "open" && fail != "closed" {
+		return nil, fmt.Errorf("invalid %s fail mode %q, expected open or closed", name, fail)
+	}
+	var interval time.Duration
+	if value, ok := opts["refresh"]; ok {
+		var err error
+		interval, err = time.ParseDuration(value)
+		if err != nil || interval <= 0 {
+			return nil, fmt.Errorf("invalid %s refresh interval %q", name, value)
+		}
+	}
+
+	var (
+		source operatorSource
+		data   []byte
+		err    error
+	)
+	switch {
+	case strings.HasPrefix(file, "https://"):
+		if io.IsConfined(options.Root) {
+			return nil, fmt.Errorf("remote %s list %q: %w", name, file, io.ErrNotConfinable)
+		}
+		if source, err = newRemoteSource(file); err != nil {
+			return nil, err
+		}
+		if data, _, err = source.read(); err != nil && interval == 0 {
+			return nil, err
+		}
+	case strings.HasPrefix(file, "http://"):
+		return nil, fmt.Errorf("remote %s list %q must use https", name, file)
+	default:
+		var path string
+		if path, data, err = findFile(file, options.Path, options.Root); err != nil {
+			return nil, err
+		}
+		if interval == 0 {
+			return build(data)
+		}
+		info, err := fs.Stat(options.Root, path)
+		if err != nil {
+			return nil, err
+		}
+		source = &fileSource{root: options.Root, path: path, modTime: info.ModTime(), size: info.Size()}
+	}
+
+	o := &refreshingOperator{
+		name:     name,
+		source:   source,
+		interval: interval,
+		build:    build,
+		now:      time.Now,
+		fail:     fail,
+	}
+	if err == nil {
+		op, err := build(data)
+		if err != nil {
+			return nil, err
+		}
+		if interval == 0 {
+			return op, nil
+		}
+		o.current.Store(&op)
+	}
+	o.checked.Store(o.now().UnixNano())
+	return o, nil
+}
+
+func (o *refreshingOperator) Evaluate(tx plugintypes.TransactionState, value string) bool {
+	now := o.now()
+	if now.UnixNano()-o.checked.Load() >= int64(o.interval) && o.refreshing.CompareAndSwap(false, true) {
+		o.checked.Store(now.UnixNano())
+		logger := tx.DebugLogger()
+		go func() {
+			defer o.refreshing.Store(false)
+			o.refresh(logger)
+		}()
+	}
+	op := o.current.Load()
+	if op == nil {
+		failed := tx.DependencyFailed(o.name, errors.New(o.source.String()+" is not available"))
+		if o.fail != "" {
+			return o.fail == "closed"
+		}
+		return failed
+	}
+	return (*op).Evaluate(tx, value)
+}
+
+// refresh rebuilds the operator if the source changed
+func (o *refreshingOperator) refresh(logger debuglog.Logger) {
+	data, changed, err := o.source.read()
+	if err == nil && !changed {
+		return
+	}
+	var op plugintypes.Operator
+	if err == nil {
+		op, err = o.build(data)
+	}
+	if err != nil {
+		logger.Warn().Str("operator", o.name).Str("source", o.source.String()).Err(err).Msg("Cannot refresh the operator")
+		return
+	}
+	o.current.Store(&op)
+	logger.Debug().Str("operator", o.name).Str("source", o.source.String()).Msg("Refreshed the operator")
+}
@@ -0,0 +1,115 @@
+// Copyright 2024 Juan Pablo Tosso and the OWASP Coraza contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package operators
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/ad3n/seclang/experimental/plugins/plugintypes"
+	"github.com/ad3n/seclang/internal/corazawaf"
+)
+
+func TestOperatorFromFileRefresh(t *testing.T) {
+	tests := []struct {
+		name    string
+		newOp   plugintypes.OperatorFactory
+		content string
+		updated string
+		before  string
+		after   string
+	}{
+		{"ipMatchFromFile", newIPMatchFromFile, "# blocked\n10.0.0.1\n", "10.0.0.1\n192.168.0.0/24\n", "10.0.0.1", "192.168.0.7"},
+		{"pmFromFile", newPMFromFile, "sqlmap\n", "sqlmap\nnikto\n", "sqlmap/1.0", "Nikto/2.1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			file := filepath.Join(dir, "list.txt")
+			if err := os.WriteFile(file, []byte(tt.content), 0o600); err != nil {
+				t.Fatal(err)
+			}
+			op, err := tt.newOp(plugintypes.OperatorOptions{
+				Arguments: "list.txt refresh=1m",
+				Path:      []string{"."},
+				Root:      os.DirFS(dir),
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			ro, ok := op.(*refreshingOperator)
+			if !ok {
+				t.Fatalf("unexpected operator %T", op)
+			}
+			now := time.Now()
+			ro.now = func() time.Time { return now }
+
+			tx := corazawaf.NewWAF().NewTransaction()
+			if !op.Evaluate(tx, tt.before) || op.Evaluate(tx, tt.after) {
+				t.Fatal("unexpected result of the initial list")
+			}
+
+			if err := os.WriteFile(file, []byte(tt.updated), 0o600); err != nil {
+				t.Fatal(err)
+			}
+			modTime := now.Add(time.Hour)
+			if err := os.Chtimes(file, modTime, modTime); err != nil {
+				t.Fatal(err)
+			}
+			// the file is not polled before the interval elapsed
+			op.Evaluate(tx, tt.after)
+			time.Sleep(10 * time.Millisecond)
+			if op.Evaluate(tx, tt.after) {
+				t.Fatal("unexpected refresh before the interval")
+			}
+
+			now = now.Add(time.Minute)
+			deadline := time.Now().Add(5 * time.Second)
+			for !op.Evaluate(tx, tt.after) {
+				if time.Now().After(deadline) {
+					t.Fatal("expected the list to be refreshed")
+				}
+				time.Sleep(time.Millisecond)
+			}
+			if !op.Evaluate(tx, tt.before) {
+				t.Error("expected match of the refreshed list")
+			}
+
+			// an unreadable file keeps the current operator
+			if err := os.Remove(file); err != nil {
+				t.Fatal(err)
+			}
+			now = now.Add(time.Minute)
+			op.Evaluate(tx, tt.after)
+			for ro.refreshing.Load() {
+				time.Sleep(time.Millisecond)
+			}
+			if !op.Evaluate(tx, tt.after) {
+				t.Error("expected the operator to be kept when the file is removed")
+			}
+		})
+	}
+}
+
+func TestOperatorFromFileRefreshOptions(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "ips.txt"), []byte("10.0.0.1\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	root := os.DirFS(dir)
+	op, err := newIPMatchFromFile(plugintypes.OperatorOptions{Arguments: "ips.txt", Path: []string{"."}, Root: root})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := op.(*refreshingOperator); ok {
+		t.Error("unexpected refreshing operator without the refresh option")
+	}
+	for _, args := range []string{"ips.txt refresh=x", "ips.txt refresh=0s", "ips.txt refresh=-1m", "missing.txt refresh=1m"} {
+		if _, err := newIPMatchFromFile(plugintypes.OperatorOptions{Arguments: args, Path: []string{"."}, Root: root}); err == nil {
+			t.Errorf("expected error for %q", args)
+		}
+	}
+}
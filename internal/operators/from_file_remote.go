@@ -0,0 +1,77 @@
+// Copyright 2024 Juan Pablo Tosso and the OWASP Coraza contributors
+// SPDX-License-Identifier: Apache-2.0
+
+//go:build !tinygo
+// +build !tinygo
+
+package operators
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+)
+
+// maxRemoteListSize limits the size of a remote list to avoid exhausting
+// memory with a misbehaving server
+const maxRemoteListSize = 64 << 20
+
+// remoteListClient fetches the remote lists of the FromFile operators
+var remoteListClient = &http.Client{
+	Timeout: 10 * time.Second,
+}
+
+// remoteSource is a list served over https, e.g. a blocklist shared by a
+// fleet. The ETag and Last-Modified of the previous response are used to
+// revalidate it, so the unchanged lists are not downloaded again.
+type remoteSource struct {
+	url          string
+	etag         string
+	lastModified string
+}
+
+func newRemoteSource(url string) (operatorSource, error) {
+	if _, err := http.NewRequest(http.MethodGet, url, nil); err != nil {
+		return nil, fmt.Errorf("invalid remote list %q: %s", url, err.Error())
+	}
+	return &remoteSource{url: url}, nil
+}
+
+func (s *remoteSource) read() ([]byte, bool, error) {
+	req, err := http.NewRequest(http.MethodGet, s.url, nil)
+	if err != nil {
+		return nil, false, err
+	}
+	if s.etag != "" {
+		req.Header.Set("If-None-Match", s.etag)
+	}
+	if s.lastModified != "" {
+		req.Header.Set("If-Modified-Since", s.lastModified)
+	}
+	res, err := remoteListClient.Do(req)
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to fetch %s: %s", s.url, err.Error())
+	}
+	defer res.Body.Close()
+
+	switch {
+	case res.StatusCode == http.StatusNotModified && (s.etag != "" || s.lastModified != ""):
+		return nil, false, nil
+	case res.StatusCode != http.StatusOK:
+		return nil, false, fmt.Errorf("failed to fetch %s: unexpected status %d", s.url, res.StatusCode)
+	}
+	data, err := io.ReadAll(io.LimitReader(res.Body, maxRemoteListSize+1))
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to read %s: %s", s.url, err.Error())
+	}
+	if len(data) > maxRemoteListSize {
+		return nil, false, fmt.Errorf("remote list %s is bigger than %d bytes", s.url, maxRemoteListSize)
+	}
+	s.etag, s.lastModified = res.Header.Get("ETag"), res.Header.Get("Last-Modified")
+	return data, true, nil
+}
+
+func (s *remoteSource) String() string {
+	return s.url
+}
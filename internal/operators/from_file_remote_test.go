@@ -0,0 +1,175 @@
+// Copyright 2024 Juan Pablo Tosso and the OWASP Coraza contributors
+// SPDX-License-Identifier: Apache-2.0
+
+//go:build !tinygo
+// +build !tinygo
+
+package operators
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ad3n/seclang/experimental/plugins/plugintypes"
+	"github.com/ad3n/seclang/internal/corazawaf"
+)
+
+// listServer serves a list revalidated with its ETag
+type listServer struct {
+	mu          sync.Mutex
+	list        string
+	version     int
+	fetched     int
+	notModified int
+	down        bool
+}
+
+func (s *listServer) set(list string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.list = list
+	s.version++
+}
+
+func (s *listServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.down {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		return
+	}
+	etag := fmt.Sprintf(`"v%d"`, s.version)
+	if r.Header.Get("If-None-Match") == etag {
+		s.notModified++
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+	s.fetched++
+	w.Header().Set("ETag", etag)
+	fmt.Fprint(w, s.list)
+}
+
+func newListServer(t *testing.T, list string) (*listServer, string) {
+	t.Helper()
+	s := &listServer{list: list}
+	srv := httptest.NewTLSServer(s)
+	t.Cleanup(srv.Close)
+	client := remoteListClient
+	remoteListClient = srv.Client()
+	t.Cleanup(func() { remoteListClient = client })
+	return s, srv.URL + "/list.txt"
+}
+
+func TestOperatorFromRemoteList(t *testing.T) {
+	s, url := newListServer(t, "10.0.0.1\n")
+	op, err := newIPMatchFromFile(plugintypes.OperatorOptions{Arguments: url + " refresh=1m"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ro := op.(*refreshingOperator)
+	now := time.Now()
+	ro.now = func() time.Time { return now }
+	tx := corazawaf.NewWAF().NewTransaction()
+	if !op.Evaluate(tx, "10.0.0.1") || op.Evaluate(tx, "10.0.0.2") {
+		t.Fatal("unexpected result of the initial list")
+	}
+
+	// the unchanged list is revalidated with its ETag
+	now = now.Add(time.Minute)
+	waitRefresh(t, op, ro, "10.0.0.1")
+	s.mu.Lock()
+	fetched, notModified := s.fetched, s.notModified
+	s.mu.Unlock()
+	if fetched != 1 || notModified != 1 {
+		t.Errorf("unexpected requests, want 1 fetch and 1 revalidation, have %d and %d", fetched, notModified)
+	}
+
+	s.set("10.0.0.2\n")
+	now = now.Add(time.Minute)
+	deadline := time.Now().Add(5 * time.Second)
+	for !op.Evaluate(tx, "10.0.0.2") {
+		if time.Now().After(deadline) {
+			t.Fatal("expected the list to be refreshed")
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	// the list is kept while the server is down
+	s.mu.Lock()
+	s.down = true
+	s.mu.Unlock()
+	now = now.Add(time.Minute)
+	waitRefresh(t, op, ro, "10.0.0.2")
+	if !op.Evaluate(tx, "10.0.0.2") {
+		t.Error("expected the list to be kept when the server is down")
+	}
+}
+
+// waitRefresh evaluates the operator to trigger a refresh and waits for it
+func waitRefresh(t *testing.T, op plugintypes.Operator, ro *refreshingOperator, value string) {
+	t.Helper()
+	op.Evaluate(corazawaf.NewWAF().NewTransaction(), value)
+	for ro.refreshing.Load() {
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestOperatorFromRemoteListUnavailable(t *testing.T) {
+	s, url := newListServer(t, "sqlmap\n")
+	s.down = true
+
+	if _, err := newPMFromFile(plugintypes.OperatorOptions{Arguments: url}); err == nil {
+		t.Error("expected error for an unavailable list that is not refreshed")
+	}
+
+	tests := []struct {
+		args    string
+		mode    corazawaf.DependencyFailureMode
+		matched bool
+	}{
+		{url + " refresh=1m", corazawaf.DependencyFailOpen, false},
+		{url + " refresh=1m", corazawaf.DependencyFailClosed, true},
+		{url + " refresh=1m fail=closed", corazawaf.DependencyFailOpen, true},
+		{url + " refresh=1m fail=open", corazawaf.DependencyFailClosed, false},
+	}
+	for _, tt := range tests {
+		op, err := newPMFromFile(plugintypes.OperatorOptions{Arguments: tt.args})
+		if err != nil {
+			t.Fatal(err)
+		}
+		waf := corazawaf.NewWAF()
+		waf.SetDependencyFailureMode("pmFromFile", tt.mode)
+		tx := waf.NewTransaction()
+		if want, have := tt.matched, op.Evaluate(tx, "sqlmap/1.0"); want != have {
+			t.Errorf("unexpected match of %q with the mode %d, want %t, have %t", tt.args, tt.mode, want, have)
+		}
+		if want, have := "1", tx.Variables().TX().Get("dependency_error_pmfromfile"); len(have) != 1 || have[0] != want {
+			t.Errorf("unexpected dependency health of %q, want %q, have %v", tt.args, want, have)
+		}
+	}
+
+	// the list is used once the server is back
+	op, err := newPMFromFile(plugintypes.OperatorOptions{Arguments: url + " refresh=1m"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.mu.Lock()
+	s.down = false
+	s.mu.Unlock()
+	ro := op.(*refreshingOperator)
+	ro.now = func() time.Time { return time.Now().Add(time.Minute) }
+	waitRefresh(t, op, ro, "sqlmap/1.0")
+	if !op.Evaluate(corazawaf.NewWAF().NewTransaction(), "sqlmap/1.0") {
+		t.Error("expected match once the list is fetched")
+	}
+
+	for _, args := range []string{"http://example.com/list.txt", url + " fail=maybe", "https://%zz/list.txt"} {
+		if _, err := newPMFromFile(plugintypes.OperatorOptions{Arguments: args}); err == nil {
+			t.Errorf("expected error for %q", args)
+		}
+	}
+}
@@ -0,0 +1,15 @@
+// Copyright 2024 Juan Pablo Tosso and the OWASP Coraza contributors
+// SPDX-License-Identifier: Apache-2.0
+
+//go:build tinygo
+// +build tinygo
+
+package operators
+
+import (
+	"errors"
+)
+
+func newRemoteSource(string) (operatorSource, error) {
+	return nil, errors.New("remote lists are not supported by TinyGo")
+}
@@ -6,11 +6,56 @@
 package operators
 
 import (
+	"net"
+
 	"github.com/ad3n/seclang/experimental/plugins/plugintypes"
 )
 
+// geoLookup resolves the address with the database configured with
+// SecGeoLookupDb and populates the GEO collection. Without database every
+// address matches. Like in ModSecurity, the GEO collection holds the result of
+// the last lookup only, e.g. when REMOTE_ADDR and X-Forwarded-For are looked up.
+type geoLookup struct{}
+
+var _ plugintypes.Operator = (*geoLookup)(nil)
+
 func newGeoLookup(plugintypes.OperatorOptions) (plugintypes.Operator, error) {
-	return &unconditionalMatch{}, nil
+	return &geoLookup{}, nil
+}
+
+func (o *geoLookup) Evaluate(tx plugintypes.TransactionState, value string) bool {
+	var db plugintypes.GeoDatabase
+	if g, ok := tx.(interface {
+		GeoDatabase() plugintypes.GeoDatabase
+	}); ok {
+		db = g.GeoDatabase()
+	}
+	if db == nil {
+		return true
+	}
+
+	ip := net.ParseIP(value)
+	if ip == nil {
+		tx.DebugLogger().Debug().
+			Str("value", value).
+			Msg("Invalid address for geoLookup")
+		return false
+	}
+	geo := tx.Variables().Geo()
+	for _, md := range geo.FindAll() {
+		geo.Remove(md.Key())
+	}
+	fields, found, err := db.Lookup(ip)
+	if err != nil {
+		return tx.DependencyFailed("geolookup", err)
+	}
+	if !found {
+		return false
+	}
+	for k, v := range fields {
+		geo.Set(k, []string{v})
+	}
+	return true
 }
 
 func init() {
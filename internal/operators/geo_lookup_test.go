@@ -0,0 +1,92 @@
+// Copyright 2024 Juan Pablo Tosso and the OWASP Coraza contributors
+// SPDX-License-Identifier: Apache-2.0
+
+//go:build !coraza.disabled_operators.geoLookup
+
+package operators
+
+import (
+	"errors"
+	"net"
+	"testing"
+
+	"github.com/ad3n/seclang/experimental/plugins/plugintypes"
+	"github.com/ad3n/seclang/internal/corazawaf"
+)
+
+type testGeoDatabase map[string]map[string]string
+
+func (db testGeoDatabase) Lookup(ip net.IP) (map[string]string, bool, error) {
+	if ip.String() == "192.0.2.1" {
+		return nil, false, errors.New("corrupted database")
+	}
+	fields, ok := db[ip.String()]
+	return fields, ok, nil
+}
+
+func TestGeoLookup(t *testing.T) {
+	db := testGeoDatabase{"81.2.69.142": {"COUNTRY_CODE": "GB", "CITY": "London"}}
+	tests := []struct {
+		name    string
+		db      plugintypes.GeoDatabase
+		input   string
+		want    bool
+		country string
+	}{
+		{name: "found", db: db, input: "81.2.69.142", want: true, country: "GB"},
+		{name: "not found", db: db, input: "81.2.69.143", want: false},
+		{name: "invalid address", db: db, input: "not an ip", want: false},
+		{name: "lookup error", db: db, input: "192.0.2.1", want: false},
+		{name: "no database", input: "81.2.69.143", want: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			op, err := newGeoLookup(plugintypes.OperatorOptions{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			waf := corazawaf.NewWAF()
+			waf.GeoDatabase = tt.db
+			tx := waf.NewTransaction()
+			if want, have := tt.want, op.Evaluate(tx, tt.input); want != have {
+				t.Errorf("unexpected result, want %t, have %t", want, have)
+			}
+			country := ""
+			if v := tx.Variables().Geo().Get("COUNTRY_CODE"); len(v) > 0 {
+				country = v[0]
+			}
+			if want, have := tt.country, country; want != have {
+				t.Errorf("unexpected GEO:COUNTRY_CODE, want %q, have %q", want, have)
+			}
+		})
+	}
+}
+
+func TestGeoLookupResetsGeo(t *testing.T) {
+	db := testGeoDatabase{
+		"81.2.69.142": {"COUNTRY_CODE": "GB", "CITY": "London"},
+		"2.125.160.1": {"COUNTRY_CODE": "GB"},
+	}
+	op, err := newGeoLookup(plugintypes.OperatorOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	waf := corazawaf.NewWAF()
+	waf.GeoDatabase = db
+	tx := waf.NewTransaction()
+	for _, tt := range []struct {
+		input string
+		city  []string
+	}{
+		{"81.2.69.142", []string{"London"}},
+		{"2.125.160.1", nil},
+		{"81.2.69.142", []string{"London"}},
+		{"81.2.69.143", nil},
+	} {
+		op.Evaluate(tx, tt.input)
+		if want, have := tt.city, tx.Variables().Geo().Get("CITY"); len(want) != len(have) || len(want) > 0 && want[0] != have[0] {
+			t.Errorf("unexpected GEO:CITY after looking up %s, want %q, have %q", tt.input, want, have)
+		}
+	}
+}
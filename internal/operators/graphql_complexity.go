@@ -0,0 +1,102 @@
+// Copyright 2024 Juan Pablo Tosso and the OWASP Coraza contributors
+// SPDX-License-Identifier: Apache-2.0
+
+//go:build !coraza.disabled_operators.graphQLComplexity
+
+package operators
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/ad3n/seclang/experimental/plugins/plugintypes"
+	"github.com/ad3n/seclang/internal/graphql"
+)
+
+// graphQLComplexity parses the value as a GraphQL query and matches if its most
+// expensive operation exceeds one of the limits of the argument, a comma
+// separated list of:
+// - maxDepth: the number of nested fields
+// - maxAliases: the number of aliased fields
+// - maxFields: the number of fields
+// The fragment spreads are expanded, so fragments spread several times count
+// as many times. The queries nested too deep to be parsed exceed any depth
+// limit, the values that are not queries don't match. The exceeded limit and
+// the measure are captured in TX:0, e.g. "depth 12". The query is read from
+// the variable holding it, ARGS_GET:query, ARGS_POST:json.query for the JSON
+// bodies or REQUEST_BODY for the application/graphql ones, e.g.
+// SecRule ARGS_POST:json.query "@graphQLComplexity maxDepth=10,maxAliases=20" "id:1,phase:2,deny"
+type graphQLComplexity struct {
+	maxDepth   int
+	maxAliases int
+	maxFields  int
+}
+
+var _ plugintypes.Operator = (*graphQLComplexity)(nil)
+
+func newGraphQLComplexity(options plugintypes.OperatorOptions) (plugintypes.Operator, error) {
+	o := &graphQLComplexity{}
+	for _, limit := range strings.Split(options.Arguments, ",") {
+		name, value, ok := strings.Cut(strings.TrimSpace(limit), "=")
+		if !ok {
+			return nil, fmt.Errorf("invalid @graphQLComplexity limit %q, expected NAME=VALUE", limit)
+		}
+		n, err := strconv.Atoi(strings.TrimSpace(value))
+		if err != nil || n <= 0 {
+			return nil, fmt.Errorf("invalid @graphQLComplexity limit %q, expected a positive number", limit)
+		}
+		switch strings.TrimSpace(name) {
+		case "maxDepth":
+			o.maxDepth = n
+		case "maxAliases":
+			o.maxAliases = n
+		case "maxFields":
+			o.maxFields = n
+		default:
+			return nil, fmt.Errorf("invalid @graphQLComplexity limit %q, expected maxDepth, maxAliases or maxFields", name)
+		}
+	}
+	return o, nil
+}
+
+func (o *graphQLComplexity) Evaluate(tx plugintypes.TransactionState, value string) bool {
+	exceeded := o.exceeded(tx, value)
+	if exceeded == "" {
+		return false
+	}
+	if tx.Capturing() {
+		tx.CaptureField(0, exceeded)
+	}
+	return true
+}
+
+// exceeded returns the limit the query exceeds and its measure, empty if it
+// exceeds none
+func (o *graphQLComplexity) exceeded(tx plugintypes.TransactionState, value string) string {
+	stats, err := graphql.Analyze(value)
+	if errors.Is(err, graphql.ErrTooDeep) {
+		if o.maxDepth > 0 {
+			return "depth " + strconv.Itoa(graphql.MaxNesting) + "+"
+		}
+		return ""
+	}
+	if err != nil {
+		tx.DebugLogger().Debug().Err(err).Msg("Failed to parse the GraphQL query")
+		return ""
+	}
+	switch {
+	case o.maxDepth > 0 && stats.Depth > o.maxDepth:
+		return "depth " + strconv.Itoa(stats.Depth)
+	case o.maxAliases > 0 && stats.Aliases > o.maxAliases:
+		return "aliases " + strconv.Itoa(stats.Aliases)
+	case o.maxFields > 0 && stats.Fields > o.maxFields:
+		return "fields " + strconv.Itoa(stats.Fields)
+	}
+	return ""
+}
+
+func init() {
+	Register("graphQLComplexity", newGraphQLComplexity)
+}
@@ -0,0 +1,53 @@
+// Copyright 2024 Juan Pablo Tosso and the OWASP Coraza contributors
+// SPDX-License-Identifier: Apache-2.0
+
+//go:build !coraza.disabled_operators.graphQLComplexity
+
+package operators
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/ad3n/seclang/experimental/plugins/plugintypes"
+	"github.com/ad3n/seclang/internal/corazawaf"
+)
+
+func TestGraphQLComplexity(t *testing.T) {
+	op, err := newGraphQLComplexity(plugintypes.OperatorOptions{Arguments: "maxDepth=3, maxAliases=2,maxFields=6"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		query    string
+		exceeded string
+	}{
+		{"{ me { name friends { name } } }", ""},
+		{"{ me { friends { friends { name } } } }", "depth 4"},
+		{"{ a: me { id } b: me { id } }", ""},
+		{"{ a: me { id } b: me { id } c: me { id } }", "aliases 3"},
+		{"{ a b c d e f g }", "fields 7"},
+		{"{ ...F ...F } fragment F on Query { a b c d }", "fields 8"},
+		{strings.Repeat("{ a ", 300) + strings.Repeat("}", 300), "depth 256+"},
+		{"not a query", ""},
+		{"", ""},
+	}
+	waf := corazawaf.NewWAF()
+	for _, tt := range tests {
+		tx := waf.NewTransaction()
+		tx.Capture = true
+		if want, have := tt.exceeded != "", op.Evaluate(tx, tt.query); want != have {
+			t.Errorf("unexpected result for %q, want %t, have %t", tt.query, want, have)
+		}
+		if want, have := tt.exceeded, strings.Join(tx.Variables().TX().Get("0"), ""); want != have {
+			t.Errorf("unexpected capture for %q, want %q, have %q", tt.query, want, have)
+		}
+		tx.Close()
+	}
+
+	for _, args := range []string{"", "maxDepth", "maxDepth=0", "maxDepth=ten", "maxCost=10", "maxDepth=10,"} {
+		if _, err := newGraphQLComplexity(plugintypes.OperatorOptions{Arguments: args}); err == nil {
+			t.Errorf("expected error for %q", args)
+		}
+	}
+}
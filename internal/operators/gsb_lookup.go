@@ -0,0 +1,177 @@
+// Copyright 2024 Juan Pablo Tosso and the OWASP Coraza contributors
+// SPDX-License-Identifier: Apache-2.0
+
+//go:build !coraza.disabled_operators.gsbLookup
+
+package operators
+
+import (
+	"fmt"
+	"reflect"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/ad3n/seclang/experimental/plugins/plugintypes"
+	"github.com/ad3n/seclang/internal/memoize"
+	"github.com/ad3n/seclang/internal/urlreputation"
+)
+
+const (
+	// gsbThreatKey is the TX key of the threat of the last URL @gsbLookup
+	// matched
+	gsbThreatKey = "gsb_threat"
+	// the default time to live of the listed and not listed URLs
+	defaultGSBTTL         = 5 * time.Minute
+	defaultGSBNegativeTTL = time.Minute
+	// maxGSBURLs bounds the distinct URLs looked up for each value, the
+	// values with more URLs match with the gsbTooManyURLs threat
+	maxGSBURLs = 100
+	// gsbTooManyURLs is the threat of the values with more than maxGSBURLs
+	// distinct URLs, which are not all looked up
+	gsbTooManyURLs = "TOO_MANY_URLS"
+)
+
+// defaultGSBExpr finds the http and https URLs of the values
+const defaultGSBExpr = `(?i)https?://[^\s"'<>]+`
+
+// gsbLookup looks up the URLs of the value with the URL reputation provider
+// configured with SecGsbLookupDb, e.g. the links submitted through the forms
+// of a site to catch the phishing and malware URLs. The URLs are the matches
+// of the regular expression of the argument, or of its first group, the http
+// and https URLs by default. The listed URL is captured in TX:0 and the threat
+// it is listed for is set in TX:gsb_threat. Without provider no URL matches.
+//
+// The URLs are cached in their canonical form, so the same URL written
+// differently is looked up once. The values with more than 100 distinct
+// URLs match with the TOO_MANY_URLS threat once the first 100 are looked
+// up, so benign links can't hide a listed one.
+//
+// The results are cached in memory, the listed URLs for ttl and the not
+// listed ones for negative_ttl, a zero duration disables the cache. The cache
+// is shared by the rules of the WAF looking up the URLs with the same
+// provider, and the concurrent lookups of a URL share the same request to the
+// provider.
+//
+//	@gsbLookup [EXPRESSION] [ttl=DURATION] [negative_ttl=DURATION]
+type gsbLookup struct {
+	re          *regexp.Regexp
+	ttl         time.Duration
+	negativeTTL time.Duration
+	// cache is used when the transaction doesn't share the cache of the
+	// provider with the other rules
+	cache *lookupCache[gsbResult]
+}
+
+var _ plugintypes.Operator = (*gsbLookup)(nil)
+
+// gsbCacheKey is the key of the cache shared by the rules looking up the URLs
+// with the provider
+type gsbCacheKey struct {
+	provider plugintypes.URLReputation
+}
+
+type gsbResult struct {
+	threat string
+	listed bool
+}
+
+func newGSBLookup(options plugintypes.OperatorOptions) (plugintypes.Operator, error) {
+	expr, opts := cutOptions(options.Arguments, "ttl", "negative_ttl")
+	// cutOptions keeps the first field, which is an option without expression
+	if key, value, ok := strings.Cut(expr, "="); ok && (key == "ttl" || key == "negative_ttl") {
+		if _, seen := opts[key]; !seen {
+			opts[key] = value
+			expr = ""
+		}
+	}
+	if expr == "" {
+		expr = defaultGSBExpr
+	}
+	re, err := memoize.Do(expr, func() (interface{}, error) { return regexp.Compile(expr) })
+	if err != nil {
+		return nil, err
+	}
+	o := &gsbLookup{
+		re:          re.(*regexp.Regexp),
+		ttl:         defaultGSBTTL,
+		negativeTTL: defaultGSBNegativeTTL,
+	}
+	for key, dst := range map[string]*time.Duration{"ttl": &o.ttl, "negative_ttl": &o.negativeTTL} {
+		value, ok := opts[key]
+		if !ok {
+			continue
+		}
+		d, err := time.ParseDuration(value)
+		if err != nil || d < 0 {
+			return nil, fmt.Errorf("invalid gsbLookup %s %q", key, value)
+		}
+		*dst = d
+	}
+	o.cache = newLookupCache[gsbResult](time.Now)
+	return o, nil
+}
+
+func (o *gsbLookup) cacheTTL(res gsbResult) time.Duration {
+	if res.listed {
+		return o.ttl
+	}
+	return o.negativeTTL
+}
+
+func (o *gsbLookup) Evaluate(tx plugintypes.TransactionState, value string) bool {
+	var provider plugintypes.URLReputation
+	if r, ok := tx.(interface {
+		URLReputation() plugintypes.URLReputation
+	}); ok {
+		provider = r.URLReputation()
+	}
+	if provider == nil {
+		return false
+	}
+	cache := o.cache
+	// the providers that can't be compared, e.g. funcs, can't key the cache
+	if reflect.TypeOf(provider).Comparable() {
+		cache = sharedLookupCache(tx, gsbCacheKey{provider: provider}, o.cache)
+	}
+
+	seen := map[string]struct{}{}
+	for _, m := range o.re.FindAllStringSubmatch(value, -1) {
+		url := m[0]
+		if len(m) > 1 && m[1] != "" {
+			url = m[1]
+		}
+		key, ok := urlreputation.Canonical(url)
+		if !ok {
+			key = url
+		}
+		if _, ok := seen[key]; ok {
+			continue
+		}
+		if len(seen) == maxGSBURLs {
+			tx.Variables().TX().Set(gsbThreatKey, []string{gsbTooManyURLs})
+			return true
+		}
+		seen[key] = struct{}{}
+		res, err := cache.do(key, o.cacheTTL, func() (gsbResult, error) {
+			threat, listed, err := provider.Lookup(url)
+			return gsbResult{threat: threat, listed: listed}, err
+		})
+		if err != nil {
+			return tx.DependencyFailed("gsblookup", err)
+		}
+		if !res.listed {
+			continue
+		}
+		if tx.Capturing() {
+			tx.CaptureField(0, url)
+		}
+		tx.Variables().TX().Set(gsbThreatKey, []string{res.threat})
+		return true
+	}
+	return false
+}
+
+func init() {
+	Register("gsbLookup", newGSBLookup)
+}
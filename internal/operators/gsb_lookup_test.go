@@ -0,0 +1,199 @@
+// Copyright 2024 Juan Pablo Tosso and the OWASP Coraza contributors
+// SPDX-License-Identifier: Apache-2.0
+
+//go:build !coraza.disabled_operators.gsbLookup
+
+package operators
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/ad3n/seclang/experimental/plugins/plugintypes"
+	"github.com/ad3n/seclang/internal/corazawaf"
+)
+
+type fakeURLReputation struct {
+	threats map[string]string
+	err     error
+	lookups atomic.Int32
+}
+
+func (r *fakeURLReputation) Lookup(url string) (string, bool, error) {
+	r.lookups.Add(1)
+	if r.err != nil {
+		return "", false, r.err
+	}
+	threat, ok := r.threats[url]
+	return threat, ok, nil
+}
+
+func TestGSBLookup(t *testing.T) {
+	provider := &fakeURLReputation{threats: map[string]string{
+		"http://phishing.example.com/login": "SOCIAL_ENGINEERING",
+		"evil.example.com/payload.exe":      "MALWARE",
+	}}
+	waf := corazawaf.NewWAF()
+	waf.URLReputation = provider
+
+	tests := []struct {
+		arguments string
+		value     string
+		match     bool
+		capture   string
+		threat    string
+	}{
+		{
+			value:   "see http://example.com/ and http://phishing.example.com/login now",
+			match:   true,
+			capture: "http://phishing.example.com/login",
+			threat:  "SOCIAL_ENGINEERING",
+		},
+		{value: "http://example.com/", match: false},
+		{value: "no url", match: false},
+		{
+			value:   strings.Repeat("http://example.com/ ", 500) + gsbBenignURLs(maxGSBURLs-2) + "http://phishing.example.com/login",
+			match:   true,
+			capture: "http://phishing.example.com/login",
+			threat:  "SOCIAL_ENGINEERING",
+		},
+		{
+			value:  gsbBenignURLs(maxGSBURLs) + "http://phishing.example.com/login",
+			match:  true,
+			threat: gsbTooManyURLs,
+		},
+		{
+			arguments: `=\"https?://(.*?)\"`,
+			value:     `<a href="https://evil.example.com/payload.exe">`,
+			match:     true,
+			capture:   "evil.example.com/payload.exe",
+			threat:    "MALWARE",
+		},
+	}
+	for _, tt := range tests {
+		op, err := newGSBLookup(plugintypes.OperatorOptions{Arguments: tt.arguments})
+		if err != nil {
+			t.Fatal(err)
+		}
+		tx := waf.NewTransaction()
+		tx.Capture = true
+		if want, have := tt.match, op.Evaluate(tx, tt.value); want != have {
+			t.Errorf("unexpected result for %q, want %t, have %t", tt.value, want, have)
+		}
+		if want, have := tt.capture, strings.Join(tx.Variables().TX().Get("0"), ","); want != have {
+			t.Errorf("unexpected capture of %q, want %q, have %q", tt.value, want, have)
+		}
+		if want, have := tt.threat, strings.Join(tx.Variables().TX().Get(gsbThreatKey), ","); want != have {
+			t.Errorf("unexpected threat of %q, want %q, have %q", tt.value, want, have)
+		}
+		tx.Close()
+	}
+}
+
+// gsbBenignURLs returns n distinct URLs not listed
+func gsbBenignURLs(n int) string {
+	var sb strings.Builder
+	for i := 0; i < n; i++ {
+		sb.WriteString("http://example.com/" + strconv.Itoa(i) + " ")
+	}
+	return sb.String()
+}
+
+func TestGSBLookupCache(t *testing.T) {
+	provider := &fakeURLReputation{threats: map[string]string{"http://phishing.example.com/": "SOCIAL_ENGINEERING"}}
+	waf := corazawaf.NewWAF()
+	waf.URLReputation = provider
+	op, err := newGSBLookup(plugintypes.OperatorOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 10; i++ {
+		tx := waf.NewTransaction()
+		if !op.Evaluate(tx, "http://phishing.example.com/") {
+			t.Error("expected the listed URL to match")
+		}
+		if op.Evaluate(tx, "http://example.com/") {
+			t.Error("unexpected match of the URL not listed")
+		}
+		tx.Close()
+	}
+	if want, have := int32(2), provider.lookups.Load(); want != have {
+		t.Errorf("unexpected lookups, want %d, have %d", want, have)
+	}
+
+	// the URLs are cached in their canonical form
+	tx := waf.NewTransaction()
+	if op.Evaluate(tx, "HTTP://Example.COM:80/./ http://example.com// http://example.com/#top") {
+		t.Error("unexpected match of the URL not listed")
+	}
+	tx.Close()
+	if want, have := int32(2), provider.lookups.Load(); want != have {
+		t.Errorf("unexpected lookups of the same canonical URL, want %d, have %d", want, have)
+	}
+
+	// the rules looking up the URLs with the provider share the cache
+	op, err = newGSBLookup(plugintypes.OperatorOptions{Arguments: `(?i)(https?://\S+)`})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tx = waf.NewTransaction()
+	if !op.Evaluate(tx, "http://phishing.example.com/") {
+		t.Error("expected the listed URL to match")
+	}
+	tx.Close()
+	if want, have := int32(2), provider.lookups.Load(); want != have {
+		t.Errorf("unexpected lookups of another rule, want %d, have %d", want, have)
+	}
+
+	op, err = newGSBLookup(plugintypes.OperatorOptions{Arguments: "ttl=0s negative_ttl=0s"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	waf = corazawaf.NewWAF()
+	waf.URLReputation = provider
+	provider.lookups.Store(0)
+	for i := 0; i < 3; i++ {
+		tx := waf.NewTransaction()
+		op.Evaluate(tx, "http://phishing.example.com/")
+		tx.Close()
+	}
+	if want, have := int32(3), provider.lookups.Load(); want != have {
+		t.Errorf("unexpected lookups without cache, want %d, have %d", want, have)
+	}
+}
+
+func TestGSBLookupDependencyFailure(t *testing.T) {
+	op, err := newGSBLookup(plugintypes.OperatorOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	waf := corazawaf.NewWAF()
+	tx := waf.NewTransaction()
+	if op.Evaluate(tx, "http://phishing.example.com/") {
+		t.Error("unexpected match without provider")
+	}
+	tx.Close()
+
+	waf.URLReputation = &fakeURLReputation{err: errors.New("quota exceeded")}
+	waf.SetDependencyFailureMode("gsbLookup", corazawaf.DependencyFailClosed)
+	tx = waf.NewTransaction()
+	defer tx.Close()
+	if !op.Evaluate(tx, "http://phishing.example.com/") {
+		t.Error("expected a match failing closed")
+	}
+	if want, have := "1", strings.Join(tx.Variables().TX().Get("dependency_error_gsblookup"), ","); want != have {
+		t.Errorf("unexpected dependency error, want %q, have %q", want, have)
+	}
+}
+
+func TestGSBLookupOptions(t *testing.T) {
+	for _, arguments := range []string{"ttl=1", "negative_ttl=-1s", "(unclosed"} {
+		if _, err := newGSBLookup(plugintypes.OperatorOptions{Arguments: arguments}); err == nil {
+			t.Errorf("expected error for %q", arguments)
+		}
+	}
+}
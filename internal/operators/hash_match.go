@@ -0,0 +1,112 @@
+// Copyright 2024 Juan Pablo Tosso and the OWASP Coraza contributors
+// SPDX-License-Identifier: Apache-2.0
+
+//go:build !coraza.disabled_operators.hashMatch
+
+package operators
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/md5"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/ad3n/seclang/experimental/plugins/plugintypes"
+)
+
+// hashMatchAlgorithms are the hash functions @hashMatch can use
+var hashMatchAlgorithms = map[string]func() hash.Hash{
+	"md5":    md5.New,
+	"sha1":   sha1.New,
+	"sha256": sha256.New,
+}
+
+// hashMatch matches the values whose hash is in a list, e.g. of known
+// malicious files or of leaked passwords. The digests are kept sorted in a
+// single slice and searched with a binary search, so the large lists take
+// their size in memory. The matched digest is captured in TX:0.
+type hashMatch struct {
+	newHash func() hash.Hash
+	// digests are the sorted digests of the list, without duplicates
+	digests []byte
+	size    int
+}
+
+var _ plugintypes.Operator = (*hashMatch)(nil)
+
+// newHashMatch loads the list of hexadecimal digests of a file or of a list
+// served over https, one per line, see newOperatorFromFile for the options.
+// The characters following a colon are ignored, e.g. the counts of the
+// leaked passwords lists. The argument starts with the hash function, md5,
+// sha1 or sha256:
+//
+//	@hashMatch sha256 malware-sha256.txt refresh=1h
+//	@hashMatch sha1 https://lists.example.com/leaked-sha1.txt refresh=24h
+func newHashMatch(options plugintypes.OperatorOptions) (plugintypes.Operator, error) {
+	algorithm, file, _ := strings.Cut(strings.TrimSpace(options.Arguments), " ")
+	newHash, ok := hashMatchAlgorithms[strings.ToLower(algorithm)]
+	if !ok {
+		return nil, fmt.Errorf("invalid @hashMatch algorithm %q, expected md5, sha1 or sha256", algorithm)
+	}
+	options.Arguments = file
+	return newOperatorFromFile("hashMatch", options, func(data []byte) (plugintypes.Operator, error) {
+		return parseHashList(newHash, data)
+	})
+}
+
+func parseHashList(newHash func() hash.Hash, data []byte) (*hashMatch, error) {
+	o := &hashMatch{newHash: newHash, size: newHash().Size()}
+	var digests [][]byte
+	sc := bufio.NewScanner(bytes.NewReader(data))
+	for n := 1; sc.Scan(); n++ {
+		l := strings.TrimSpace(sc.Text())
+		if len(l) == 0 || l[0] == '#' {
+			continue
+		}
+		l, _, _ = strings.Cut(l, ":")
+		digest, err := hex.DecodeString(strings.TrimSpace(l))
+		if err != nil || len(digest) != o.size {
+			return nil, fmt.Errorf("invalid digest %q at line %d, expected %d hexadecimal characters", l, n, o.size*2)
+		}
+		digests = append(digests, digest)
+	}
+	if err := sc.Err(); err != nil {
+		return nil, err
+	}
+	slices.SortFunc(digests, bytes.Compare)
+	digests = slices.CompactFunc(digests, bytes.Equal)
+	o.digests = make([]byte, 0, len(digests)*o.size)
+	for _, d := range digests {
+		o.digests = append(o.digests, d...)
+	}
+	return o, nil
+}
+
+func (o *hashMatch) Evaluate(tx plugintypes.TransactionState, value string) bool {
+	h := o.newHash()
+	h.Write([]byte(value))
+	digest := h.Sum(nil)
+
+	n := len(o.digests) / o.size
+	i := sort.Search(n, func(i int) bool {
+		return bytes.Compare(o.digests[i*o.size:(i+1)*o.size], digest) >= 0
+	})
+	if i == n || !bytes.Equal(o.digests[i*o.size:(i+1)*o.size], digest) {
+		return false
+	}
+	if tx.Capturing() {
+		tx.CaptureField(0, hex.EncodeToString(digest))
+	}
+	return true
+}
+
+func init() {
+	Register("hashMatch", newHashMatch)
+}
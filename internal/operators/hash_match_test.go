@@ -0,0 +1,94 @@
+// Copyright 2024 Juan Pablo Tosso and the OWASP Coraza contributors
+// SPDX-License-Identifier: Apache-2.0
+
+//go:build !coraza.disabled_operators.hashMatch
+
+package operators
+
+import (
+	"crypto/sha1"
+	"crypto/sha256"
+	"strings"
+	"testing"
+
+	"github.com/ad3n/seclang/experimental/plugins/plugintypes"
+	"github.com/ad3n/seclang/internal/corazawaf"
+	"github.com/ad3n/seclang/internal/io"
+)
+
+func TestHashMatch(t *testing.T) {
+	op, err := newHashMatch(plugintypes.OperatorOptions{
+		Arguments: "sha1 testdata/op/leaked-sha1.txt",
+		Path:      []string{"."},
+		Root:      io.OSFS{},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want, have := 2, len(op.(*hashMatch).digests)/sha1.Size; want != have {
+		t.Errorf("unexpected digests, want %d, have %d", want, have)
+	}
+	tests := []struct {
+		value   string
+		match   bool
+		capture string
+	}{
+		{"password", true, "5baa61e4c9b93f3f0682250b6cf8331b7ee68fd8"},
+		{"123456", true, "7c4a8d09ca3762af61e59520943dc26494f8941b"},
+		{"hello", false, ""},
+		{"", false, ""},
+	}
+	waf := corazawaf.NewWAF()
+	for _, tt := range tests {
+		tx := waf.NewTransaction()
+		tx.Capture = true
+		if want, have := tt.match, op.Evaluate(tx, tt.value); want != have {
+			t.Errorf("unexpected result for %q, want %t, have %t", tt.value, want, have)
+		}
+		if want, have := tt.capture, strings.Join(tx.Variables().TX().Get("0"), ","); want != have {
+			t.Errorf("unexpected capture of %q, want %q, have %q", tt.value, want, have)
+		}
+		tx.Close()
+	}
+}
+
+func TestHashMatchList(t *testing.T) {
+	tests := []struct {
+		name string
+		list string
+		err  bool
+	}{
+		{name: "sha256 digests", list: "2f293f67aa33f2ce247b28d6fb2fef2623cfde731f96b3d7f84ae74e9e192bdd\n\n# comment\n"},
+		{name: "empty list", list: ""},
+		{name: "wrong length", list: "5baa61e4c9b93f3f0682250b6cf8331b7ee68fd8", err: true},
+		{name: "not hexadecimal", list: strings.Repeat("z", 64), err: true},
+	}
+	waf := corazawaf.NewWAF()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			o, err := parseHashList(sha256.New, []byte(tt.list))
+			if tt.err {
+				if err == nil {
+					t.Error("expected error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			tx := waf.NewTransaction()
+			defer tx.Close()
+			if want, have := strings.Contains(tt.list, "2f293f"), o.Evaluate(tx, "malware"); want != have {
+				t.Errorf("unexpected result, want %t, have %t", want, have)
+			}
+		})
+	}
+}
+
+func TestHashMatchAlgorithm(t *testing.T) {
+	for _, args := range []string{"", "sha512 list.txt", "list.txt"} {
+		if _, err := newHashMatch(plugintypes.OperatorOptions{Arguments: args}); err == nil {
+			t.Errorf("expected error for %q", args)
+		}
+	}
+}
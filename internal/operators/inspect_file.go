@@ -8,33 +8,63 @@ package operators
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"os/exec"
 	"time"
 
 	"github.com/ad3n/seclang/experimental/plugins/plugintypes"
+	"github.com/ad3n/seclang/internal/io"
 )
 
+// inspectFile runs a script with the path of the file, it matches if the
+// output doesn't start with 1. The file can also be streamed to an antivirus,
+// see fileScanner.
 type inspectFile struct {
-	path string
+	path    string
+	timeout time.Duration
 }
 
 var _ plugintypes.Operator = (*inspectFile)(nil)
 
 func newInspectFile(options plugintypes.OperatorOptions) (plugintypes.Operator, error) {
-	return &inspectFile{path: options.Arguments}, nil
+	target, timeout, err := inspectFileArguments(options.Arguments)
+	if err != nil {
+		return nil, err
+	}
+	if scanner, ok, err := newFileScanner(target, timeout); ok {
+		if err == nil && scanner.network == "unix" {
+			if root, confined := options.Root.(io.ConfinedFS); confined {
+				scanner.address, err = root.Resolve(scanner.address)
+			}
+		}
+		return scanner, err
+	}
+	// the script is a program of the host, it can't be confined
+	if io.IsConfined(options.Root) {
+		return nil, fmt.Errorf("inspectFile script %q: %w", target, io.ErrNotConfinable)
+	}
+	return &inspectFile{path: target, timeout: timeout}, nil
 }
 
 func (o *inspectFile) Evaluate(tx plugintypes.TransactionState, value string) bool {
-	// TODO parametrize timeout
 	// TODO add relative path capabilities
 	// TODO add lua special support
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	ctx, cancel := context.WithTimeout(context.Background(), o.timeout)
 	defer cancel()
 	// Add /bin/bash to context?
 	cmd := exec.CommandContext(ctx, o.path, value)
 	output, err := cmd.CombinedOutput()
-	if ctx.Err() == context.DeadlineExceeded || err != nil {
-		return false
+	if ctx.Err() == context.DeadlineExceeded {
+		return tx.DependencyFailed("inspectFile", ctx.Err())
+	}
+	if err != nil {
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) {
+			// the script ran but failed, this is not a dependency failure
+			return false
+		}
+		return tx.DependencyFailed("inspectFile", err)
 	}
 	return len(output) > 0 && output[0] != '1'
 }
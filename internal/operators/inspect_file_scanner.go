@@ -0,0 +1,261 @@
+// Copyright 2024 Juan Pablo Tosso and the OWASP Coraza contributors
+// SPDX-License-Identifier: Apache-2.0
+
+//go:build !coraza.disabled_operators.inspectFile
+
+package operators
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/textproto"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ad3n/seclang/experimental/plugins/plugintypes"
+)
+
+const (
+	defaultInspectFileTimeout = 10 * time.Second
+	defaultClamdPort          = "3310"
+	defaultICAPPort           = "1344"
+	// scanChunkSize is the size of the chunks the files are streamed in
+	scanChunkSize = 32 * 1024
+)
+
+// inspectFileArguments splits the target of @inspectFile, a script or the URL
+// of an antivirus, from its options:
+//
+//	@inspectFile TARGET [timeout=DURATION]
+func inspectFileArguments(args string) (string, time.Duration, error) {
+	target, options := cutOptions(args, "timeout")
+	timeout := defaultInspectFileTimeout
+	if value, ok := options["timeout"]; ok {
+		d, err := time.ParseDuration(value)
+		if err != nil || d <= 0 {
+			return "", 0, fmt.Errorf("invalid inspectFile timeout %q", value)
+		}
+		timeout = d
+	}
+	if target == "" {
+		return "", 0, errors.New("missing the inspectFile script or scanner")
+	}
+	return target, timeout, nil
+}
+
+// fileScanner streams the uploaded files to an antivirus and matches the
+// infected ones, the name of the threat is captured in TX:0. The antivirus is
+// either clamd, clamd://HOST[:PORT] or clamd:///PATH/TO/SOCKET, or an ICAP
+// server, icap://HOST[:PORT]/SERVICE, the files being sent in RESPMOD
+// requests. The connection and scan failures are dependency failures.
+type fileScanner struct {
+	network string
+	address string
+	// service is the ICAP service URL, empty for clamd
+	service string
+	host    string
+	timeout time.Duration
+}
+
+var _ plugintypes.Operator = (*fileScanner)(nil)
+
+// newFileScanner returns the scanner of the URL, false if target is not the
+// URL of a supported antivirus
+func newFileScanner(target string, timeout time.Duration) (*fileScanner, bool, error) {
+	scheme, _, ok := strings.Cut(target, "://")
+	if !ok || scheme != "clamd" && scheme != "icap" {
+		return nil, false, nil
+	}
+	u, err := url.Parse(target)
+	if err != nil {
+		return nil, true, fmt.Errorf("invalid inspectFile scanner: %s", err.Error())
+	}
+	s := &fileScanner{network: "tcp", timeout: timeout}
+	switch {
+	case scheme == "clamd" && u.Host == "":
+		if u.Path == "" {
+			return nil, true, errors.New("missing the clamd socket")
+		}
+		s.network, s.address = "unix", u.Path
+	case scheme == "clamd":
+		s.address = hostPort(u, defaultClamdPort)
+	default:
+		if u.Host == "" {
+			return nil, true, errors.New("missing the ICAP server")
+		}
+		s.address = hostPort(u, defaultICAPPort)
+		s.service = target
+		s.host = u.Host
+	}
+	return s, true, nil
+}
+
+func hostPort(u *url.URL, defaultPort string) string {
+	if u.Port() != "" {
+		return u.Host
+	}
+	return net.JoinHostPort(u.Hostname(), defaultPort)
+}
+
+func (o *fileScanner) Evaluate(tx plugintypes.TransactionState, value string) bool {
+	f, err := os.Open(value)
+	if err != nil {
+		tx.DebugLogger().Debug().
+			Str("file", value).
+			Err(err).
+			Msg("Cannot open the file to inspect")
+		return false
+	}
+	defer f.Close()
+
+	threat, infected, err := o.scan(f)
+	if err != nil {
+		return tx.DependencyFailed("inspectFile", err)
+	}
+	if infected {
+		tx.CaptureField(0, threat)
+	}
+	return infected
+}
+
+// scan sends the file to the antivirus and returns the name of the threat
+// found, if any
+func (o *fileScanner) scan(r io.Reader) (string, bool, error) {
+	conn, err := net.DialTimeout(o.network, o.address, o.timeout)
+	if err != nil {
+		return "", false, err
+	}
+	defer conn.Close()
+	if err := conn.SetDeadline(time.Now().Add(o.timeout)); err != nil {
+		return "", false, err
+	}
+	if o.service != "" {
+		return o.scanICAP(conn, r)
+	}
+	return scanClamd(conn, r)
+}
+
+// scanClamd scans the file with the INSTREAM command of clamd, the file is
+// sent in chunks prefixed with their length and terminated by an empty one
+func scanClamd(conn net.Conn, r io.Reader) (string, bool, error) {
+	w := bufio.NewWriter(conn)
+	if _, err := w.WriteString("zINSTREAM\x00"); err != nil {
+		return "", false, err
+	}
+	buf := make([]byte, scanChunkSize)
+	for {
+		n, err := r.Read(buf)
+		if n > 0 {
+			if err := binary.Write(w, binary.BigEndian, uint32(n)); err != nil {
+				return "", false, err
+			}
+			if _, err := w.Write(buf[:n]); err != nil {
+				return "", false, err
+			}
+		}
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return "", false, err
+		}
+	}
+	if err := binary.Write(w, binary.BigEndian, uint32(0)); err != nil {
+		return "", false, err
+	}
+	if err := w.Flush(); err != nil {
+		return "", false, err
+	}
+
+	reply, err := bufio.NewReader(conn).ReadString(0)
+	if err != nil && err != io.EOF {
+		return "", false, err
+	}
+	// stream: OK, stream: THREAT FOUND or MESSAGE ERROR
+	reply = strings.TrimSpace(strings.TrimSuffix(reply, "\x00"))
+	result := strings.TrimPrefix(reply, "stream: ")
+	switch {
+	case result == "OK":
+		return "", false, nil
+	case strings.HasSuffix(result, " FOUND"):
+		return strings.TrimSuffix(result, " FOUND"), true, nil
+	}
+	return "", false, fmt.Errorf("clamd: unexpected reply %q", reply)
+}
+
+// scanICAP sends the file as the body of an HTTP response to the RESPMOD
+// service, the server replies 204 if it doesn't modify it, i.e. it is clean
+func (o *fileScanner) scanICAP(conn net.Conn, r io.Reader) (string, bool, error) {
+	const resHeader = "HTTP/1.1 200 OK\r\nContent-Type: application/octet-stream\r\n\r\n"
+	w := bufio.NewWriter(conn)
+	fmt.Fprintf(w, "RESPMOD %s ICAP/1.0\r\n", o.service)
+	fmt.Fprintf(w, "Host: %s\r\n", o.host)
+	w.WriteString("Allow: 204\r\n")
+	w.WriteString("Connection: close\r\n")
+	fmt.Fprintf(w, "Encapsulated: res-hdr=0, res-body=%d\r\n\r\n", len(resHeader))
+	w.WriteString(resHeader)
+	buf := make([]byte, scanChunkSize)
+	for {
+		n, err := r.Read(buf)
+		if n > 0 {
+			fmt.Fprintf(w, "%x\r\n", n)
+			w.Write(buf[:n])
+			w.WriteString("\r\n")
+		}
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return "", false, err
+		}
+	}
+	w.WriteString("0\r\n\r\n")
+	if err := w.Flush(); err != nil {
+		return "", false, err
+	}
+
+	tp := textproto.NewReader(bufio.NewReader(conn))
+	status, err := tp.ReadLine()
+	if err != nil {
+		return "", false, err
+	}
+	proto, rest, _ := strings.Cut(status, " ")
+	codeText, _, _ := strings.Cut(rest, " ")
+	code, err := strconv.Atoi(codeText)
+	if !strings.HasPrefix(proto, "ICAP/") || err != nil {
+		return "", false, fmt.Errorf("icap: invalid status line %q", status)
+	}
+	header, err := tp.ReadMIMEHeader()
+	if err != nil && err != io.EOF {
+		return "", false, err
+	}
+	switch code {
+	case 204:
+		return "", false, nil
+	case 200:
+		return icapThreat(header), true, nil
+	}
+	return "", false, fmt.Errorf("icap: unexpected status %q", status)
+}
+
+// icapThreat returns the name of the threat reported by the ICAP server, with
+// the X-Infection-Found header, e.g. "Type=0; Resolution=2; Threat=EICAR;", or
+// X-Virus-ID
+func icapThreat(header textproto.MIMEHeader) string {
+	for _, field := range strings.Split(header.Get("X-Infection-Found"), ";") {
+		if threat, ok := strings.CutPrefix(strings.TrimSpace(field), "Threat="); ok {
+			return threat
+		}
+	}
+	if id := header.Get("X-Virus-ID"); id != "" {
+		return id
+	}
+	return "unknown"
+}
@@ -0,0 +1,217 @@
+// Copyright 2024 Juan Pablo Tosso and the OWASP Coraza contributors
+// SPDX-License-Identifier: Apache-2.0
+
+//go:build !tinygo
+// +build !tinygo
+
+package operators
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"io"
+	"net"
+	"net/textproto"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ad3n/seclang/experimental/plugins/plugintypes"
+	"github.com/ad3n/seclang/internal/corazawaf"
+)
+
+const eicar = `X5O!P%@AP[4\PZX54(P^)7CC)7}$EICAR-STANDARD-ANTIVIRUS-TEST-FILE!$H+H*`
+
+// serve accepts the connections of the listener and handles them with h
+func serve(t *testing.T, h func(conn net.Conn)) string {
+	t.Helper()
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { l.Close() })
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				h(conn)
+			}()
+		}
+	}()
+	return l.Addr().String()
+}
+
+// fakeClamd replies FOUND to the streams containing the EICAR test file
+func fakeClamd(conn net.Conn) {
+	r := bufio.NewReader(conn)
+	if cmd, err := r.ReadString(0); err != nil || cmd != "zINSTREAM\x00" {
+		conn.Write([]byte("UNKNOWN COMMAND\x00"))
+		return
+	}
+	var content bytes.Buffer
+	for {
+		var size uint32
+		if err := binary.Read(r, binary.BigEndian, &size); err != nil {
+			return
+		}
+		if size == 0 {
+			break
+		}
+		if _, err := io.CopyN(&content, r, int64(size)); err != nil {
+			return
+		}
+	}
+	if strings.Contains(content.String(), "EICAR") {
+		conn.Write([]byte("stream: Eicar-Signature FOUND\x00"))
+		return
+	}
+	conn.Write([]byte("stream: OK\x00"))
+}
+
+// fakeICAP replies 200 to the RESPMOD requests containing the EICAR test file
+func fakeICAP(conn net.Conn) {
+	tp := textproto.NewReader(bufio.NewReader(conn))
+	if line, err := tp.ReadLine(); err != nil || !strings.HasPrefix(line, "RESPMOD icap://") {
+		conn.Write([]byte("ICAP/1.0 400 Bad Request\r\n\r\n"))
+		return
+	}
+	if _, err := tp.ReadMIMEHeader(); err != nil {
+		return
+	}
+	// the encapsulated HTTP response header
+	if _, err := tp.ReadLine(); err != nil {
+		return
+	}
+	if _, err := tp.ReadMIMEHeader(); err != nil {
+		return
+	}
+	var content strings.Builder
+	for {
+		line, err := tp.ReadLine()
+		if err != nil {
+			return
+		}
+		size, err := strconv.ParseInt(line, 16, 64)
+		if err != nil {
+			return
+		}
+		chunk := make([]byte, size+2)
+		if _, err := io.ReadFull(tp.R, chunk); err != nil {
+			return
+		}
+		if size == 0 {
+			break
+		}
+		content.Write(chunk[:size])
+	}
+	if strings.Contains(content.String(), "EICAR") {
+		conn.Write([]byte("ICAP/1.0 200 OK\r\nX-Infection-Found: Type=0; Resolution=2; Threat=EICAR-Test;\r\nEncapsulated: null-body=0\r\n\r\n"))
+		return
+	}
+	conn.Write([]byte("ICAP/1.0 204 No Content\r\nEncapsulated: null-body=0\r\n\r\n"))
+}
+
+func TestInspectFileScanners(t *testing.T) {
+	dir := t.TempDir()
+	clean := filepath.Join(dir, "clean.txt")
+	infected := filepath.Join(dir, "infected.txt")
+	if err := os.WriteFile(clean, []byte(strings.Repeat("hello ", 20000)), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(infected, []byte(eicar), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	for name, target := range map[string]string{
+		"clamd": "clamd://" + serve(t, fakeClamd),
+		"icap":  "icap://" + serve(t, fakeICAP) + "/avscan",
+	} {
+		t.Run(name, func(t *testing.T) {
+			op, err := newInspectFile(plugintypes.OperatorOptions{Arguments: target + " timeout=2s"})
+			if err != nil {
+				t.Fatal(err)
+			}
+			tx := corazawaf.NewWAF().NewTransaction()
+			tx.Capture = true
+			if op.Evaluate(tx, clean) {
+				t.Error("unexpected match of a clean file")
+			}
+			if !op.Evaluate(tx, infected) {
+				t.Fatal("expected match of an infected file")
+			}
+			if want, have := map[string]string{"clamd": "Eicar-Signature", "icap": "EICAR-Test"}[name], tx.Variables().TX().Get("0"); len(have) != 1 || have[0] != want {
+				t.Errorf("unexpected threat, want %q, have %q", want, have)
+			}
+			if op.Evaluate(tx, filepath.Join(dir, "missing.txt")) {
+				t.Error("unexpected match of a missing file")
+			}
+		})
+	}
+}
+
+func TestInspectFileScannerFailure(t *testing.T) {
+	// the server closes the connections without replying
+	addr := serve(t, func(net.Conn) {})
+	file := filepath.Join(t.TempDir(), "file.txt")
+	if err := os.WriteFile(file, []byte("hello"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	for _, target := range []string{"clamd://" + addr, "icap://" + addr + "/avscan"} {
+		op, err := newInspectFile(plugintypes.OperatorOptions{Arguments: target})
+		if err != nil {
+			t.Fatal(err)
+		}
+		waf := corazawaf.NewWAF()
+		waf.SetDependencyFailureMode("inspectFile", corazawaf.DependencyFailClosed)
+		tx := waf.NewTransaction()
+		if !op.Evaluate(tx, file) {
+			t.Errorf("expected match of %s when the scanner fails closed", target)
+		}
+		if want, have := "1", tx.Variables().TX().Get("dependency_error_inspectfile"); len(have) != 1 || have[0] != want {
+			t.Errorf("unexpected dependency health of %s, want %q, have %v", target, want, have)
+		}
+	}
+}
+
+func TestInspectFileArguments(t *testing.T) {
+	tests := []struct {
+		args    string
+		target  string
+		timeout time.Duration
+	}{
+		{"/usr/bin/scan.sh", "/usr/bin/scan.sh", defaultInspectFileTimeout},
+		{"/usr/bin/scan.sh timeout=1m", "/usr/bin/scan.sh", time.Minute},
+		{`C:\Program Files\scan.bat timeout=5s`, `C:\Program Files\scan.bat`, 5 * time.Second},
+		{"clamd:///var/run/clamd.ctl", "clamd:///var/run/clamd.ctl", defaultInspectFileTimeout},
+	}
+	for _, tt := range tests {
+		target, timeout, err := inspectFileArguments(tt.args)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if target != tt.target || timeout != tt.timeout {
+			t.Errorf("unexpected arguments of %q, want %q %s, have %q %s", tt.args, tt.target, tt.timeout, target, timeout)
+		}
+	}
+
+	for _, args := range []string{"", "/bin/scan timeout=x", "/bin/scan timeout=-1s", "clamd://", "icap:///avscan"} {
+		if _, err := newInspectFile(plugintypes.OperatorOptions{Arguments: args}); err == nil {
+			t.Errorf("expected error for %q", args)
+		}
+	}
+	op, err := newInspectFile(plugintypes.OperatorOptions{Arguments: "clamd:///var/run/clamd.ctl"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s := op.(*fileScanner); s.network != "unix" || s.address != "/var/run/clamd.ctl" {
+		t.Errorf("unexpected clamd socket %s %s", s.network, s.address)
+	}
+}
@@ -13,6 +13,7 @@ import (
 	"testing"
 
 	"github.com/ad3n/seclang/experimental/plugins/plugintypes"
+	"github.com/ad3n/seclang/internal/corazawaf"
 )
 
 func TestInspectFileExitCode(t *testing.T) {
@@ -42,7 +43,8 @@ func TestInspectFileExitCode(t *testing.T) {
 			if err != nil {
 				t.Error("cannot init inspectfile operator")
 			}
-			if want, have := tt.exists, ipf.Evaluate(nil, "/?"); want != have {
+			tx := corazawaf.NewWAF().NewTransaction()
+			if want, have := tt.exists, ipf.Evaluate(tx, "/?"); want != have {
 				t.Errorf("inspectfile path %s: want %v, have %v", tt.path, want, have)
 			}
 		})
@@ -83,3 +85,20 @@ func TestInspectFileOutput(t *testing.T) {
 		})
 	}
 }
+
+func TestInspectFileDependencyFailure(t *testing.T) {
+	ipf, err := newInspectFile(plugintypes.OperatorOptions{Arguments: filepath.Join(t.TempDir(), "nonexistent.sh")})
+	if err != nil {
+		t.Fatal("cannot init inspectfile operator")
+	}
+
+	waf := corazawaf.NewWAF()
+	waf.SetDependencyFailureMode("inspectFile", corazawaf.DependencyFailClosed)
+	tx := waf.NewTransaction()
+	if !ipf.Evaluate(tx, "/?") {
+		t.Error("expected match when the scanner is missing and the dependency fails closed")
+	}
+	if want, have := "1", tx.Variables().TX().Get("dependency_error_inspectfile"); len(have) != 1 || have[0] != want {
+		t.Errorf("unexpected dependency health, want %q, have %v", want, have)
+	}
+}
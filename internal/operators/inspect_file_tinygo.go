@@ -1,19 +1,28 @@
 // Copyright 2022 Juan Pablo Tosso and the OWASP Coraza contributors
 // SPDX-License-Identifier: Apache-2.0
 
-//go:build tinygo
-// +build tinygo
+//go:build tinygo && !coraza.disabled_operators.inspectFile
+// +build tinygo,!coraza.disabled_operators.inspectFile
 
 package operators
 
 import (
+	"errors"
+
 	"github.com/ad3n/seclang/experimental/plugins/plugintypes"
 )
 
-type inspectFile struct{}
-
-func newInspectFile(plugintypes.OperatorOptions) (plugintypes.Operator, error) {
-	return &unconditionalMatch{}, nil
+// newInspectFile only supports the antivirus scanners, as TinyGo can't run
+// scripts
+func newInspectFile(options plugintypes.OperatorOptions) (plugintypes.Operator, error) {
+	target, timeout, err := inspectFileArguments(options.Arguments)
+	if err != nil {
+		return nil, err
+	}
+	if scanner, ok, err := newFileScanner(target, timeout); ok {
+		return scanner, err
+	}
+	return nil, errors.New("inspectFile scripts are not supported by TinyGo, use a clamd:// or icap:// scanner")
 }
 
 func init() {
@@ -19,12 +19,15 @@ func newIPMatchFromDataset(options plugintypes.OperatorOptions) (plugintypes.Ope
 		return nil, fmt.Errorf("dataset %q not found", data)
 	}
 
-	datasetParsed := strings.Join(dataset, ",")
+	return newDatasetOperator(options, "ipMatchFromDataset", data, buildIPMatchFromDataset, func() (plugintypes.Operator, error) {
+		return buildIPMatchFromDataset(dataset)
+	})
+}
 
-	opts := plugintypes.OperatorOptions{
-		Arguments: datasetParsed,
-	}
-	return newIPMatch(opts)
+func buildIPMatchFromDataset(values []string) (plugintypes.Operator, error) {
+	return newIPMatch(plugintypes.OperatorOptions{
+		Arguments: strings.Join(values, ","),
+	})
 }
 
 func init() {
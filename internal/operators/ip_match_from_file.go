@@ -13,33 +13,34 @@ import (
 	"github.com/ad3n/seclang/experimental/plugins/plugintypes"
 )
 
+// newIPMatchFromFile matches the addresses and networks listed in a file or a
+// list served over https, one per line, see newOperatorFromFile for the
+// options:
+//
+//	@ipMatchFromFile ips.txt refresh=5m
+//	@ipMatchFromFile https://lists.example.com/ips.txt refresh=5m fail=closed
 func newIPMatchFromFile(options plugintypes.OperatorOptions) (plugintypes.Operator, error) {
-	path := options.Arguments
-
-	data, err := loadFromFile(path, options.Path, options.Root)
-	if err != nil {
-		return nil, err
-	}
-
-	dataParsed := strings.Builder{}
-	sc := bufio.NewScanner(bytes.NewReader(data))
-	for sc.Scan() {
-		l := sc.Text()
-		l = strings.TrimSpace(l)
-		if len(l) == 0 {
-			continue
+	return newOperatorFromFile("ipMatchFromFile", options, func(data []byte) (plugintypes.Operator, error) {
+		dataParsed := strings.Builder{}
+		sc := bufio.NewScanner(bytes.NewReader(data))
+		for sc.Scan() {
+			l := sc.Text()
+			l = strings.TrimSpace(l)
+			if len(l) == 0 {
+				continue
+			}
+			if l[0] == '#' {
+				continue
+			}
+			dataParsed.WriteString(",")
+			dataParsed.WriteString(l)
 		}
-		if l[0] == '#' {
-			continue
-		}
-		dataParsed.WriteString(",")
-		dataParsed.WriteString(l)
-	}
 
-	opts := plugintypes.OperatorOptions{
-		Arguments: dataParsed.String(),
-	}
-	return newIPMatch(opts)
+		opts := plugintypes.OperatorOptions{
+			Arguments: dataParsed.String(),
+		}
+		return newIPMatch(opts)
+	})
 }
 
 func init() {
@@ -0,0 +1,89 @@
+// Copyright 2024 Juan Pablo Tosso and the OWASP Coraza contributors
+// SPDX-License-Identifier: Apache-2.0
+
+//go:build !coraza.disabled_operators.jsonPath
+
+package operators
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/ad3n/seclang/experimental/plugins/plugintypes"
+	"github.com/ad3n/seclang/internal/jsonpath"
+	"github.com/ad3n/seclang/internal/memoize"
+)
+
+// jsonPath evaluates a JSONPath expression against the request body processed
+// as JSON, whatever the value of the variable, and matches if any of the
+// selected values satisfies the comparison, an operator with its argument, or
+// if any value is selected without comparison, e.g.
+// SecRule REQBODY_PROCESSOR "@jsonPath $.items[*].price @gt 1000". The
+// comparison can be negated with !@. Without comparison or with a negated one
+// the first matching value is captured in TX:0, otherwise the comparison
+// captures as usual.
+type jsonPath struct {
+	path   *jsonpath.Path
+	cmp    plugintypes.Operator
+	negate bool
+
+	body *bodyDocument
+}
+
+var _ plugintypes.Operator = (*jsonPath)(nil)
+
+func newJSONPath(options plugintypes.OperatorOptions) (plugintypes.Operator, error) {
+	expr, rest := jsonpath.Split(strings.TrimSpace(options.Arguments))
+	path, err := memoize.Do("jsonpath:"+expr, func() (interface{}, error) { return jsonpath.Compile(expr) })
+	if err != nil {
+		return nil, err
+	}
+	o := &jsonPath{
+		path: path.(*jsonpath.Path),
+		body: &bodyDocument{processor: "JSON", decode: jsonpath.Decode},
+	}
+	if rest == "" {
+		return o, nil
+	}
+	if strings.HasPrefix(rest, "!@") {
+		o.negate = true
+		rest = rest[1:]
+	}
+	if !strings.HasPrefix(rest, "@") {
+		return nil, fmt.Errorf("invalid @jsonPath comparison %q, expected an operator", rest)
+	}
+	name, arg, _ := strings.Cut(rest[1:], " ")
+	cmpOptions := options
+	cmpOptions.Arguments = strings.TrimSpace(arg)
+	if o.cmp, err = Get(name, cmpOptions); err != nil {
+		return nil, fmt.Errorf("invalid @jsonPath comparison: %s", err.Error())
+	}
+	return o, nil
+}
+
+func (o *jsonPath) Evaluate(tx plugintypes.TransactionState, _ string) bool {
+	doc, ok := o.body.get(tx)
+	if !ok {
+		return false
+	}
+	for _, node := range o.path.Eval(doc) {
+		value := jsonpath.Text(node)
+		if o.cmp == nil {
+			if tx.Capturing() {
+				tx.CaptureField(0, value)
+			}
+			return true
+		}
+		if o.cmp.Evaluate(tx, value) != o.negate {
+			if o.negate && tx.Capturing() {
+				tx.CaptureField(0, value)
+			}
+			return true
+		}
+	}
+	return false
+}
+
+func init() {
+	Register("jsonPath", newJSONPath)
+}
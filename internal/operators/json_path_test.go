@@ -0,0 +1,97 @@
+// Copyright 2024 Juan Pablo Tosso and the OWASP Coraza contributors
+// SPDX-License-Identifier: Apache-2.0
+
+//go:build !coraza.disabled_operators.jsonPath
+
+package operators
+
+import (
+	"testing"
+
+	"github.com/ad3n/seclang/experimental/plugins/plugintypes"
+	"github.com/ad3n/seclang/internal/collections"
+	"github.com/ad3n/seclang/internal/corazawaf"
+	"github.com/corazawaf/coraza/v3/types/variables"
+)
+
+func TestJSONPath(t *testing.T) {
+	const body = `{"user": {"role": "admin"}, "items": [{"price": 10}, {"price": 2000}]}`
+	tests := []struct {
+		args      string
+		processor string
+		body      string
+		want      bool
+		capture   string
+	}{
+		{args: "$.user.role", processor: "JSON", body: body, want: true, capture: "admin"},
+		{args: "$.user.name", processor: "JSON", body: body, want: false},
+		{args: "$.user.role @streq admin", processor: "JSON", body: body, want: true},
+		{args: "$.user.role @streq guest", processor: "JSON", body: body, want: false},
+		{args: "$.items[*].price @gt 1000", processor: "JSON", body: body, want: true},
+		{args: "$.items[*].price !@gt 1000", processor: "JSON", body: body, want: true, capture: "10"},
+		{args: "$.items[*].price @gt 5000", processor: "JSON", body: body, want: false},
+		{args: "$.user.role @rx ^(ad)min$", processor: "JSON", body: body, want: true, capture: "admin"},
+		{args: "$.user.role", processor: "URLENCODED", body: body, want: false},
+		{args: "$.user.role", processor: "JSON", body: `{"user":`, want: false},
+	}
+	waf := corazawaf.NewWAF()
+	for _, tt := range tests {
+		op, err := newJSONPath(plugintypes.OperatorOptions{Arguments: tt.args})
+		if err != nil {
+			t.Fatal(err)
+		}
+		tx := waf.NewTransaction()
+		tx.Capture = true
+		tx.RequestBodyAccess = true
+		if _, _, err := tx.WriteRequestBody([]byte(tt.body)); err != nil {
+			t.Fatal(err)
+		}
+		tx.Collection(variables.ReqbodyProcessor).(*collections.Single).Set(tt.processor)
+		// the body is decoded once per transaction
+		for i := 0; i < 2; i++ {
+			if want, have := tt.want, op.Evaluate(tx, ""); want != have {
+				t.Errorf("unexpected result of %q, want %t, have %t", tt.args, want, have)
+			}
+		}
+		if tt.capture != "" {
+			if have := tx.Variables().TX().Get("0"); len(have) != 1 || have[0] != tt.capture {
+				t.Errorf("unexpected capture of %q, want %q, have %q", tt.args, tt.capture, have)
+			}
+		}
+		tx.Close()
+	}
+
+	for _, args := range []string{"", "user.role", "$.user.role streq admin", "$.user.role @missing", "$.a @rx ("} {
+		if _, err := newJSONPath(plugintypes.OperatorOptions{Arguments: args}); err == nil {
+			t.Errorf("expected error for %q", args)
+		}
+	}
+}
+
+func TestJSONPathTransactionsWithSameID(t *testing.T) {
+	op, err := newJSONPath(plugintypes.OperatorOptions{Arguments: "$.user.role @streq admin"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	waf := corazawaf.NewWAF()
+	// the decoded bodies are kept per transaction, not per ID
+	newTx := func(body string) *corazawaf.Transaction {
+		tx := waf.NewTransactionWithOptions(corazawaf.Options{ID: "same-id"})
+		tx.RequestBodyAccess = true
+		if _, _, err := tx.WriteRequestBody([]byte(body)); err != nil {
+			t.Fatal(err)
+		}
+		tx.Collection(variables.ReqbodyProcessor).(*collections.Single).Set("JSON")
+		return tx
+	}
+	guest := newTx(`{"user": {"role": "guest"}}`)
+	defer guest.Close()
+	admin := newTx(`{"user": {"role": "admin"}}`)
+	defer admin.Close()
+	if op.Evaluate(guest, "") {
+		t.Error("unexpected match of the guest body")
+	}
+	if !op.Evaluate(admin, "") {
+		t.Error("expected a match of the admin body")
+	}
+}
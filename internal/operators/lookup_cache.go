@@ -0,0 +1,151 @@
+// Copyright 2024 Juan Pablo Tosso and the OWASP Coraza contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package operators
+
+import (
+	"container/list"
+	"sync"
+	"time"
+
+	"github.com/ad3n/seclang/experimental/plugins/plugintypes"
+)
+
+// maxLookupCacheEntries bounds the memory used by each lookup cache
+const maxLookupCacheEntries = 10000
+
+// lookupCache caches the results of the operators looking up the values in
+// an external service, e.g. a DNS block list or a reputation API, for a time
+// to live depending on the result, e.g. shorter for the negative ones. The
+// concurrent lookups of a key share the first one, so a burst of requests
+// from an address queries the service once. Failed lookups are not cached.
+// Once full, the least recently used result is evicted, so a flood of new
+// keys doesn't evict the results still in use all at once.
+//
+// A cache is shared by the operators of the WAF looking up the same service,
+// see sharedLookupCache, each of them caching its results for its own time
+// to live.
+type lookupCache[V any] struct {
+	now func() time.Time
+
+	mu      sync.Mutex
+	entries map[string]*list.Element
+	// recent holds the *lookupCacheEntry, the most recently used first
+	recent  *list.List
+	pending map[string]*lookupCall[V]
+}
+
+type lookupCacheEntry[V any] struct {
+	key     string
+	value   V
+	expires time.Time
+}
+
+// lookupCall is a lookup in progress, done is closed once it completed
+type lookupCall[V any] struct {
+	done  chan struct{}
+	value V
+	err   error
+}
+
+func newLookupCache[V any](now func() time.Time) *lookupCache[V] {
+	return &lookupCache[V]{
+		now:     now,
+		entries: map[string]*list.Element{},
+		recent:  list.New(),
+		pending: map[string]*lookupCall[V]{},
+	}
+}
+
+// get returns the cached result of the key if it didn't expire
+func (c *lookupCache[V]) get(key string) (V, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	elem, ok := c.entries[key]
+	if !ok {
+		var zero V
+		return zero, false
+	}
+	e := elem.Value.(*lookupCacheEntry[V])
+	if !c.now().Before(e.expires) {
+		c.removeLocked(elem)
+		var zero V
+		return zero, false
+	}
+	c.recent.MoveToFront(elem)
+	return e.value, true
+}
+
+// do returns the cached result of the key, or looks it up, waiting for the
+// lookup of the key in progress if any. ttl returns how long the result is
+// cached, 0 not to cache it.
+func (c *lookupCache[V]) do(key string, ttl func(V) time.Duration, lookup func() (V, error)) (V, error) {
+	if v, ok := c.get(key); ok {
+		return v, nil
+	}
+	call, _ := c.start(key, ttl, lookup)
+	<-call.done
+	return call.value, call.err
+}
+
+// start looks up the key in the background unless it is being looked up,
+// started tells whether the returned lookup was started by this call
+func (c *lookupCache[V]) start(key string, ttl func(V) time.Duration, lookup func() (V, error)) (call *lookupCall[V], started bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if call, ok := c.pending[key]; ok {
+		return call, false
+	}
+	call = &lookupCall[V]{done: make(chan struct{})}
+	c.pending[key] = call
+	go func() {
+		call.value, call.err = lookup()
+		c.mu.Lock()
+		delete(c.pending, key)
+		if call.err == nil {
+			c.setLocked(key, call.value, ttl(call.value))
+		}
+		c.mu.Unlock()
+		close(call.done)
+	}()
+	return call, true
+}
+
+func (c *lookupCache[V]) setLocked(key string, value V, ttl time.Duration) {
+	if ttl <= 0 {
+		return
+	}
+	e := &lookupCacheEntry[V]{key: key, value: value, expires: c.now().Add(ttl)}
+	if elem, ok := c.entries[key]; ok {
+		elem.Value = e
+		c.recent.MoveToFront(elem)
+		return
+	}
+	if len(c.entries) >= maxLookupCacheEntries {
+		c.removeLocked(c.recent.Back())
+	}
+	c.entries[key] = c.recent.PushFront(e)
+}
+
+func (c *lookupCache[V]) removeLocked(elem *list.Element) {
+	c.recent.Remove(elem)
+	delete(c.entries, elem.Value.(*lookupCacheEntry[V]).key)
+}
+
+// sharedOperatorValues is implemented by the transactions giving access to
+// the values shared by the operators of their WAF
+type sharedOperatorValues interface {
+	SharedOperatorValue(key any, create func() any) any
+}
+
+// sharedLookupCache returns the cache of the service identified by key shared
+// by the operators of the WAF of the transaction, e.g. by all the rules
+// looking up a DNS zone, or the cache of the operator if the transaction
+// doesn't share values
+func sharedLookupCache[V any](tx plugintypes.TransactionState, key any, own *lookupCache[V]) *lookupCache[V] {
+	values, ok := tx.(sharedOperatorValues)
+	if !ok {
+		return own
+	}
+	return values.SharedOperatorValue(key, func() any { return newLookupCache[V](own.now) }).(*lookupCache[V])
+}
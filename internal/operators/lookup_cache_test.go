@@ -0,0 +1,136 @@
+// Copyright 2024 Juan Pablo Tosso and the OWASP Coraza contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package operators
+
+import (
+	"errors"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// minute caches the results for a minute
+func minute(bool) time.Duration {
+	return time.Minute
+}
+
+func TestLookupCacheStampede(t *testing.T) {
+	c := newLookupCache[bool](time.Now)
+	var lookups atomic.Int32
+	release := make(chan struct{})
+	lookup := func() (bool, error) {
+		lookups.Add(1)
+		<-release
+		return true, nil
+	}
+
+	call, started := c.start("1.2.3.4", minute, lookup)
+	if !started {
+		t.Fatal("expected the first lookup to start")
+	}
+	var wg sync.WaitGroup
+	for i := 0; i < 100; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if v, err := c.do("1.2.3.4", minute, lookup); err != nil || !v {
+				t.Errorf("unexpected result, want true, have %t, %v", v, err)
+			}
+		}()
+	}
+	if _, started := c.start("1.2.3.4", minute, lookup); started {
+		t.Error("unexpected lookup started while one is pending")
+	}
+	close(release)
+	wg.Wait()
+	<-call.done
+	if want, have := int32(1), lookups.Load(); want != have {
+		t.Errorf("unexpected lookups, want %d, have %d", want, have)
+	}
+	if v, ok := c.get("1.2.3.4"); !ok || !v {
+		t.Errorf("unexpected cached result, want true, have %t, %t", v, ok)
+	}
+}
+
+func TestLookupCacheTTL(t *testing.T) {
+	now := time.Now()
+	c := newLookupCache[bool](func() time.Time { return now })
+	ttl := func(listed bool) time.Duration {
+		if listed {
+			return time.Hour
+		}
+		return time.Minute
+	}
+
+	lookups := 0
+	lookup := func(listed bool) func() (bool, error) {
+		return func() (bool, error) {
+			lookups++
+			return listed, nil
+		}
+	}
+	for _, key := range []string{"listed", "not listed"} {
+		if _, err := c.do(key, ttl, lookup(key == "listed")); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	now = now.Add(2 * time.Minute)
+	if _, ok := c.get("not listed"); ok {
+		t.Error("unexpected not listed result cached after its ttl")
+	}
+	if v, ok := c.get("listed"); !ok || !v {
+		t.Errorf("unexpected listed result, want true, have %t, %t", v, ok)
+	}
+	if _, err := c.do("not listed", ttl, lookup(false)); err != nil {
+		t.Fatal(err)
+	}
+	if want, have := 3, lookups; want != have {
+		t.Errorf("unexpected lookups, want %d, have %d", want, have)
+	}
+}
+
+func TestLookupCacheErrors(t *testing.T) {
+	c := newLookupCache[bool](time.Now)
+	lookupErr := errors.New("timed out")
+	if _, err := c.do("1.2.3.4", minute, func() (bool, error) { return false, lookupErr }); !errors.Is(err, lookupErr) {
+		t.Errorf("unexpected error, want %v, have %v", lookupErr, err)
+	}
+	if _, ok := c.get("1.2.3.4"); ok {
+		t.Error("unexpected failed lookup cached")
+	}
+}
+
+func TestLookupCacheDisabled(t *testing.T) {
+	c := newLookupCache[bool](time.Now)
+	if _, err := c.do("1.2.3.4", func(bool) time.Duration { return 0 }, func() (bool, error) { return true, nil }); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := c.get("1.2.3.4"); ok {
+		t.Error("unexpected result cached with a zero ttl")
+	}
+}
+
+func TestLookupCacheEviction(t *testing.T) {
+	c := newLookupCache[bool](time.Now)
+	if _, err := c.do("hot", minute, func() (bool, error) { return true, nil }); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 2*maxLookupCacheEntries; i++ {
+		if _, err := c.do(strconv.Itoa(i), minute, func() (bool, error) { return false, nil }); err != nil {
+			t.Fatal(err)
+		}
+		if _, ok := c.get("hot"); !ok {
+			t.Fatalf("unexpected hot key evicted after %d keys", i)
+		}
+	}
+	if want, have := maxLookupCacheEntries, len(c.entries); want != have {
+		t.Errorf("unexpected entries, want %d, have %d", want, have)
+	}
+	if _, ok := c.get("0"); ok {
+		t.Error("expected the least recently used key evicted")
+	}
+}
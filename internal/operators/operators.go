@@ -5,18 +5,52 @@ package operators
 
 import (
 	"fmt"
+	"maps"
+	"slices"
 
 	"github.com/ad3n/seclang/experimental/plugins/plugintypes"
+	"github.com/ad3n/seclang/internal/corazarules"
 )
 
 var operators = map[string]plugintypes.OperatorFactory{}
 
-// Get returns an operator by name
+// middlewares are the middlewares wrapping the operators by name, in the
+// order they were registered
+var middlewares = map[string][]plugintypes.OperatorMiddleware{}
+
+// Get returns an operator by name, wrapped by its middlewares
 func Get(name string, options plugintypes.OperatorOptions) (plugintypes.Operator, error) {
-	if op, ok := operators[name]; ok {
-		return op(options)
+	factory, ok := operators[name]
+	if !ok {
+		return nil, fmt.Errorf("operator %s not found", name)
+	}
+	op, err := factory(options)
+	if err != nil {
+		return nil, err
+	}
+	wrapped := op
+	for _, mw := range middlewares[name] {
+		wrapped = mw(name, wrapped)
+	}
+	// the middlewares don't have to implement the optional interfaces of the
+	// operator they wrap, the matches must remain masked
+	if m, ok := op.(plugintypes.MaskingOperator); ok {
+		if _, ok := wrapped.(plugintypes.MaskingOperator); !ok {
+			wrapped = maskingOperator{Operator: wrapped, mask: m}
+		}
 	}
-	return nil, fmt.Errorf("operator %s not found", name)
+	return wrapped, nil
+}
+
+// maskingOperator forwards the masking of the wrapped operator to the
+// middlewares wrapping it
+type maskingOperator struct {
+	plugintypes.Operator
+	mask plugintypes.MaskingOperator
+}
+
+func (o maskingOperator) Mask(value string) string {
+	return o.mask.Mask(value)
 }
 
 // Register registers a new operator
@@ -24,3 +58,23 @@ func Get(name string, options plugintypes.OperatorOptions) (plugintypes.Operator
 func Register(name string, op plugintypes.OperatorFactory) {
 	operators[name] = op
 }
+
+// RegisterMiddleware wraps the operators named name created from now on with
+// mw, the middlewares registered last are the outermost
+func RegisterMiddleware(name string, mw plugintypes.OperatorMiddleware) {
+	middlewares[name] = append(middlewares[name], mw)
+}
+
+// Names returns the sorted names of the registered operators
+func Names() []string {
+	return slices.Sorted(maps.Keys(operators))
+}
+
+// captureLimit returns the maximum number of fields an operator should
+// capture for the transaction
+func captureLimit(tx plugintypes.TransactionState) int {
+	if l, ok := tx.(interface{ CaptureLimit() int }); ok {
+		return l.CaptureLimit()
+	}
+	return corazarules.DefaultCaptureLimit
+}
@@ -49,7 +49,6 @@ func TestOperators(t *testing.T) {
 	notImplemented := []string{
 		"containsWord",
 		"strmatch",
-		"verifyCC",
 		"verifycpf",
 		"verifyssn",
 		"verifysvnr",
@@ -0,0 +1,67 @@
+// Copyright 2024 Juan Pablo Tosso and the OWASP Coraza contributors
+// SPDX-License-Identifier: Apache-2.0
+
+//go:build !coraza.disabled_operators.orderedSubset
+
+package operators
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/ad3n/seclang/experimental/plugins/plugintypes"
+)
+
+// orderedSubset matches if the request headers listed in the argument, a
+// comma separated list of names, were received in the order of the list,
+// whatever the value of the variable. The headers of the list the request
+// doesn't have and the ones missing from the list are ignored, so the rules
+// assert the ordering of the browsers without requiring every header, e.g.
+// SecRule &REQUEST_HEADERS_NAMES "!@orderedSubset host,user-agent,accept" "id:1,phase:1,log"
+// The position of a header sent several times is the one of its first value.
+type orderedSubset struct {
+	// positions are the positions of the names in the list
+	positions map[string]int
+}
+
+var _ plugintypes.Operator = (*orderedSubset)(nil)
+
+func newOrderedSubset(options plugintypes.OperatorOptions) (plugintypes.Operator, error) {
+	positions := map[string]int{}
+	for _, name := range strings.Split(options.Arguments, ",") {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name == "" {
+			continue
+		}
+		if _, ok := positions[name]; ok {
+			return nil, errors.New("invalid @orderedSubset argument, " + name + " is listed twice")
+		}
+		positions[name] = len(positions)
+	}
+	if len(positions) == 0 {
+		return nil, errors.New("invalid @orderedSubset argument, expected a list of header names")
+	}
+	return &orderedSubset{positions: positions}, nil
+}
+
+func (o *orderedSubset) Evaluate(tx plugintypes.TransactionState, _ string) bool {
+	last := -1
+	seen := map[string]bool{}
+	for _, md := range tx.Variables().RequestHeadersNames().FindAll() {
+		name := strings.ToLower(md.Value())
+		pos, ok := o.positions[name]
+		if !ok || seen[name] {
+			continue
+		}
+		seen[name] = true
+		if pos < last {
+			return false
+		}
+		last = pos
+	}
+	return true
+}
+
+func init() {
+	Register("orderedSubset", newOrderedSubset)
+}
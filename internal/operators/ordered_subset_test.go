@@ -0,0 +1,50 @@
+// Copyright 2024 Juan Pablo Tosso and the OWASP Coraza contributors
+// SPDX-License-Identifier: Apache-2.0
+
+//go:build !coraza.disabled_operators.orderedSubset
+
+package operators
+
+import (
+	"testing"
+
+	"github.com/ad3n/seclang/experimental/plugins/plugintypes"
+	"github.com/ad3n/seclang/internal/corazawaf"
+)
+
+func TestOrderedSubset(t *testing.T) {
+	op, err := newOrderedSubset(plugintypes.OperatorOptions{Arguments: "Host, User-Agent, Accept, Accept-Language"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		headers []string
+		want    bool
+	}{
+		{[]string{"Host", "User-Agent", "Accept", "Accept-Language"}, true},
+		{[]string{"host", "connection", "user-agent", "cookie", "accept-language"}, true},
+		{[]string{"Host", "Accept", "Accept", "Accept-Language"}, true},
+		{[]string{"User-Agent", "Host", "Accept"}, false},
+		{[]string{"Host", "Accept-Language", "Accept"}, false},
+		{[]string{"Host", "Accept", "User-Agent", "Accept"}, false},
+		{[]string{"Connection"}, true},
+		{nil, true},
+	}
+	waf := corazawaf.NewWAF()
+	for _, tt := range tests {
+		tx := waf.NewTransaction()
+		for _, h := range tt.headers {
+			tx.AddRequestHeader(h, "value")
+		}
+		if want, have := tt.want, op.Evaluate(tx, ""); want != have {
+			t.Errorf("unexpected result for %q, want %t, have %t", tt.headers, want, have)
+		}
+		tx.Close()
+	}
+
+	for _, args := range []string{"", " , ", "host,accept,Host"} {
+		if _, err := newOrderedSubset(plugintypes.OperatorOptions{Arguments: args}); err == nil {
+			t.Errorf("expected error for %q", args)
+		}
+	}
+}
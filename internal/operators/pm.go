@@ -50,6 +50,7 @@ func pmEvaluate(matcher ahocorasick.AhoCorasick, tx plugintypes.TransactionState
 	}
 
 	var numMatches int
+	limit := captureLimit(tx)
 	for {
 		m := iter.Next()
 		if m == nil {
@@ -59,7 +60,7 @@ func pmEvaluate(matcher ahocorasick.AhoCorasick, tx plugintypes.TransactionState
 		tx.CaptureField(numMatches, value[m.Start():m.End()])
 
 		numMatches++
-		if numMatches == 10 {
+		if numMatches == limit {
 			return true
 		}
 	}
@@ -6,7 +6,10 @@
 package operators
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
+	"strings"
 
 	ahocorasick "github.com/petar-dambovaliev/aho-corasick"
 
@@ -20,16 +23,28 @@ func newPMFromDataset(options plugintypes.OperatorOptions) (plugintypes.Operator
 	if !ok {
 		return nil, fmt.Errorf("dataset %q not found", data)
 	}
-	builder := ahocorasick.NewAhoCorasickBuilder(ahocorasick.Opts{
+	return newDatasetOperator(options, "pmFromDataset", data, buildPMFromDataset, func() (plugintypes.Operator, error) {
+		builder := newDatasetMatcherBuilder()
+		// the values are part of the key as the WAFs can define datasets with
+		// the same name
+		sum := sha256.Sum256([]byte(strings.Join(dataset, "\n")))
+		m, _ := memoize.Do("pmFromDataset:"+hex.EncodeToString(sum[:]), func() (interface{}, error) { return builder.Build(dataset), nil })
+		return &pm{matcher: m.(ahocorasick.AhoCorasick)}, nil
+	})
+}
+
+func buildPMFromDataset(values []string) (plugintypes.Operator, error) {
+	builder := newDatasetMatcherBuilder()
+	return &pm{matcher: builder.Build(values)}, nil
+}
+
+func newDatasetMatcherBuilder() ahocorasick.AhoCorasickBuilder {
+	return ahocorasick.NewAhoCorasickBuilder(ahocorasick.Opts{
 		AsciiCaseInsensitive: true,
 		MatchOnlyWholeWords:  false,
 		MatchKind:            ahocorasick.LeftMostLongestMatch,
 		DFA:                  true,
 	})
-
-	m, _ := memoize.Do(data, func() (interface{}, error) { return builder.Build(dataset), nil })
-
-	return &pm{matcher: m.(ahocorasick.AhoCorasick)}, nil
 }
 
 func init() {
@@ -35,3 +35,19 @@ func TestPmFromDataset(t *testing.T) {
 		t.Error(fmt.Errorf("pmFromDataset should have failed"))
 	}
 }
+
+func TestPmFromDatasetSameName(t *testing.T) {
+	tx := corazawaf.NewWAF().NewTransaction()
+	for _, word := range []string{"attack", "exploit"} {
+		pm, err := newPMFromDataset(plugintypes.OperatorOptions{
+			Arguments: "test_same_name",
+			Datasets:  map[string][]string{"test_same_name": {word}},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !pm.Evaluate(tx, word) {
+			t.Errorf("expected the values of the dataset to match %q", word)
+		}
+	}
+}
@@ -8,6 +8,8 @@ package operators
 import (
 	"bufio"
 	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
 	"strings"
 
 	ahocorasick "github.com/petar-dambovaliev/aho-corasick"
@@ -16,38 +18,40 @@ import (
 	"github.com/ad3n/seclang/internal/memoize"
 )
 
+// newPMFromFile matches the phrases listed in a file or a list served over
+// https, one per line, see newOperatorFromFile for the options:
+//
+//	@pmFromFile bad-agents.txt refresh=1h
+//	@pmFromFile https://lists.example.com/bad-agents.txt refresh=1h
 func newPMFromFile(options plugintypes.OperatorOptions) (plugintypes.Operator, error) {
-	filepath := options.Arguments
-
-	data, err := loadFromFile(filepath, options.Path, options.Root)
-	if err != nil {
-		return nil, err
-	}
-
-	var lines []string
-	sc := bufio.NewScanner(bytes.NewReader(data))
-	for sc.Scan() {
-		l := sc.Text()
-		l = strings.TrimSpace(l)
-		if len(l) == 0 {
-			continue
+	return newOperatorFromFile("pmFromFile", options, func(data []byte) (plugintypes.Operator, error) {
+		var lines []string
+		sc := bufio.NewScanner(bytes.NewReader(data))
+		for sc.Scan() {
+			l := sc.Text()
+			l = strings.TrimSpace(l)
+			if len(l) == 0 {
+				continue
+			}
+			if l[0] == '#' {
+				continue
+			}
+			lines = append(lines, strings.ToLower(l))
 		}
-		if l[0] == '#' {
-			continue
-		}
-		lines = append(lines, strings.ToLower(l))
-	}
-
-	builder := ahocorasick.NewAhoCorasickBuilder(ahocorasick.Opts{
-		AsciiCaseInsensitive: true,
-		MatchOnlyWholeWords:  false,
-		MatchKind:            ahocorasick.LeftMostLongestMatch,
-		DFA:                  false,
-	})
 
-	m, _ := memoize.Do(strings.Join(options.Path, ",")+filepath, func() (interface{}, error) { return builder.Build(lines), nil })
+		builder := ahocorasick.NewAhoCorasickBuilder(ahocorasick.Opts{
+			AsciiCaseInsensitive: true,
+			MatchOnlyWholeWords:  false,
+			MatchKind:            ahocorasick.LeftMostLongestMatch,
+			DFA:                  false,
+		})
 
-	return &pm{matcher: m.(ahocorasick.AhoCorasick)}, nil
+		// the content is part of the key as the file can be refreshed
+		sum := sha256.Sum256(data)
+		m, _ := memoize.Do("pmFromFile:"+hex.EncodeToString(sum[:]), func() (interface{}, error) { return builder.Build(lines), nil })
+
+		return &pm{matcher: m.(ahocorasick.AhoCorasick)}, nil
+	})
 }
 
 func init() {
@@ -8,81 +8,290 @@ package operators
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"net"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/ad3n/seclang/experimental/plugins/plugintypes"
+	"github.com/ad3n/seclang/internal/collections"
 )
 
-const timeout = 500 * time.Millisecond
+const (
+	timeout = 500 * time.Millisecond
+	// the default time to live of the listed and not listed addresses
+	defaultRBLTTL         = 10 * time.Minute
+	defaultRBLNegativeTTL = time.Minute
+)
 
+// rbl looks up the address in a DNS block list:
+//
+//	@rbl SERVICE [timeout=DURATION] [ttl=DURATION] [negative_ttl=DURATION] [async]
+//
+// The results are cached in memory, the listed addresses for ttl and the not
+// listed ones for negative_ttl, a zero duration disables the cache. Failed
+// lookups are not cached. The cache is shared by the rules of the WAF looking
+// up the same service, and the concurrent lookups of an address share the
+// same DNS query.
+//
+// With async, the lookups not cached don't block the transaction: they run in
+// the background and the address doesn't match until the result is cached.
+// The result is also recorded in the IP collection of the persistent store,
+// the rbl.SERVICE field is 1 when the address is listed, 0 otherwise,
+// rbl.SERVICE.msg holds the TXT record and rbl.SERVICE.expires the Unix time
+// the result expires at, SERVICE being lower case. The rules read them with
+// initcol:ip=%{REMOTE_ADDR}, and the operator reads the results not expired
+// once evicted from the cache, or looked up by another WAF sharing the store.
+// The failures of the background lookups are logged instead of being reported
+// as dependency failures. It requires a positive ttl.
 type rbl struct {
-	service  string
-	resolver *net.Resolver
+	service     string
+	resolver    *net.Resolver
+	timeout     time.Duration
+	ttl         time.Duration
+	negativeTTL time.Duration
+	async       bool
+	now         func() time.Time
+	// cache is used when the transaction doesn't share the cache of the
+	// service with the other rules
+	cache *lookupCache[rblResult]
 }
 
 var _ plugintypes.Operator = (*rbl)(nil)
 
+// rblCacheKey is the key of the cache shared by the rules looking up the
+// service
+type rblCacheKey struct {
+	service string
+}
+
 func newRBL(options plugintypes.OperatorOptions) (plugintypes.Operator, error) {
-	data := options.Arguments
+	fields := strings.Fields(options.Arguments)
+	if len(fields) == 0 {
+		return nil, errors.New("missing the rbl service")
+	}
+	o := &rbl{
+		service:     fields[0],
+		resolver:    net.DefaultResolver,
+		timeout:     timeout,
+		ttl:         defaultRBLTTL,
+		negativeTTL: defaultRBLNegativeTTL,
+		now:         time.Now,
+	}
+	o.cache = newLookupCache[rblResult](func() time.Time { return o.now() })
+	for _, option := range fields[1:] {
+		key, value, hasValue := strings.Cut(option, "=")
+		if key == "async" && !hasValue {
+			o.async = true
+			continue
+		}
+		var d *time.Duration
+		switch key {
+		case "timeout":
+			d = &o.timeout
+		case "ttl":
+			d = &o.ttl
+		case "negative_ttl":
+			d = &o.negativeTTL
+		default:
+			return nil, fmt.Errorf("unknown rbl option %q", option)
+		}
+		v, err := time.ParseDuration(value)
+		if err != nil || v < 0 {
+			return nil, fmt.Errorf("invalid rbl option %q", option)
+		}
+		*d = v
+	}
+	if o.timeout == 0 {
+		return nil, errors.New("the rbl timeout must be positive")
+	}
+	if o.async && o.ttl == 0 {
+		return nil, errors.New("the asynchronous rbl lookups require the cache of the listed addresses")
+	}
+	return o, nil
+}
 
-	return &rbl{
-		service:  data,
-		resolver: net.DefaultResolver,
-	}, nil
+type rblResult struct {
+	listed bool
+	status string
+}
+
+func (o *rbl) cacheTTL(res rblResult) time.Duration {
+	if res.listed {
+		return o.ttl
+	}
+	return o.negativeTTL
 }
 
 // https://github.com/mrichman/godnsbl
 // https://github.com/SpiderLabs/ModSecurity/blob/b66224853b4e9d30e0a44d16b29d5ed3842a6b11/src/operators/rbl.cc
 func (o *rbl) Evaluate(tx plugintypes.TransactionState, ipAddr string) bool {
 	// TODO validate address
-	resC := make(chan bool)
-	ctx, cancel := context.WithCancel(context.Background())
+	cache := o.lookupCache(tx)
+	res, ok := cache.get(ipAddr)
+	if !ok && o.async {
+		if res, ok = o.recorded(persistentStore(tx), ipAddr); !ok {
+			o.lookupAsync(tx, cache, ipAddr)
+			return false
+		}
+	}
+	if !ok {
+		var err error
+		if res, err = cache.do(ipAddr, o.cacheTTL, func() (rblResult, error) { return o.lookup(ipAddr) }); err != nil {
+			return tx.DependencyFailed("rbl", err)
+		}
+	}
+	if res.status != "" {
+		tx.Variables().TX().Set("httpbl_msg", []string{res.status})
+		tx.CaptureField(0, res.status)
+	}
+	return res.listed
+}
 
-	defer func() {
-		cancel()
-	}()
+// lookup queries the block list, the A record tells if the address is listed
+// and the TXT record why
+func (o *rbl) lookup(ipAddr string) (rblResult, error) {
+	type result struct {
+		rblResult
+		err error
+	}
+	// the channel is buffered so the lookup does not leak if we time out
+	resC := make(chan result, 1)
+	ctx, cancel := context.WithTimeout(context.Background(), o.timeout)
+	defer cancel()
 
 	addr := fmt.Sprintf("%s.%s", ipAddr, o.service)
-	var captures []string
 	go func(ctx context.Context) {
-		defer func() {
-			close(resC)
-		}()
 		res, err := o.resolver.LookupHost(ctx, addr)
-
 		if err != nil {
-			resC <- false
+			resC <- result{err: lookupError(err)}
 			return
 		}
-		// var status string
-		if len(res) > 0 {
-			txt, err := o.resolver.LookupTXT(ctx, addr)
-			if err != nil {
-				resC <- false
-				return
-			}
-
-			if len(txt) > 0 {
-				status := txt[0]
-				captures = append(captures, status)
-				tx.Variables().TX().Set("httpbl_msg", []string{status})
-			}
+		if len(res) == 0 {
+			resC <- result{rblResult: rblResult{listed: true}}
+			return
 		}
-
-		resC <- true
+		txt, err := o.resolver.LookupTXT(ctx, addr)
+		if err != nil {
+			resC <- result{err: lookupError(err)}
+			return
+		}
+		r := result{rblResult: rblResult{listed: true}}
+		if len(txt) > 0 {
+			r.status = txt[0]
+		}
+		resC <- r
 	}(ctx)
 
 	select {
 	case res := <-resC:
-		if res && len(captures) > 0 {
-			tx.CaptureField(0, captures[0])
+		return res.rblResult, res.err
+	case <-ctx.Done():
+		return rblResult{}, fmt.Errorf("lookup of %s timed out", addr)
+	}
+}
+
+// lookupAsync looks up the address in the background, unless it is already
+// being looked up, and records the result in the cache and the IP collection
+func (o *rbl) lookupAsync(tx plugintypes.TransactionState, cache *lookupCache[rblResult], ipAddr string) {
+	call, started := cache.start(ipAddr, o.cacheTTL, func() (rblResult, error) { return o.lookup(ipAddr) })
+	if !started {
+		return
+	}
+
+	store := persistentStore(tx)
+	logger := tx.DebugLogger()
+	go func() {
+		<-call.done
+		if call.err != nil {
+			logger.Warn().
+				Str("address", ipAddr).
+				Err(call.err).
+				Msg("Asynchronous rbl lookup failed")
+			return
+		}
+		if store != nil {
+			o.record(store, ipAddr, call.value)
 		}
-		return res
-	case <-time.After(timeout):
-		return false
+	}()
+}
+
+// lookupCache returns the cache of the service shared by the rules of the WAF
+// of the transaction
+func (o *rbl) lookupCache(tx plugintypes.TransactionState) *lookupCache[rblResult] {
+	return sharedLookupCache(tx, rblCacheKey{service: strings.ToLower(o.service)}, o.cache)
+}
+
+// persistentStore returns the store of the persistent collections of the
+// transaction, nil if it has none
+func persistentStore(tx plugintypes.TransactionState) *collections.PersistentStore {
+	if s, ok := tx.(interface {
+		PersistentStore() *collections.PersistentStore
+	}); ok {
+		return s.PersistentStore()
+	}
+	return nil
+}
+
+// field returns the field of the IP collection holding the results of the
+// service
+func (o *rbl) field() string {
+	return "rbl." + strings.ToLower(o.service)
+}
+
+// recorded returns the result recorded in the IP collection of the address if
+// it didn't expire
+func (o *rbl) recorded(store *collections.PersistentStore, ipAddr string) (rblResult, bool) {
+	if store == nil {
+		return rblResult{}, false
+	}
+	data, ok := store.Get("ip", ipAddr)
+	if !ok {
+		return rblResult{}, false
+	}
+	field := o.field()
+	listed, expires := data[field], data[field+".expires"]
+	if len(listed) == 0 || len(expires) == 0 {
+		return rblResult{}, false
+	}
+	if t, err := strconv.ParseInt(expires[0], 10, 64); err != nil || !o.now().Before(time.Unix(t, 0)) {
+		return rblResult{}, false
+	}
+	res := rblResult{listed: listed[0] == "1"}
+	if msg := data[field+".msg"]; len(msg) > 0 {
+		res.status = msg[0]
+	}
+	return res, true
+}
+
+// record sets the result of the lookup in the IP collection of the address,
+// keeping its other fields and time to live
+func (o *rbl) record(store *collections.PersistentStore, ipAddr string, res rblResult) {
+	field := o.field()
+	expires := o.now().Add(o.cacheTTL(res)).Unix()
+	store.Update("ip", ipAddr, func(data map[string][]string) {
+		data[field] = []string{"0"}
+		if res.listed {
+			data[field] = []string{"1"}
+		}
+		data[field+".expires"] = []string{strconv.FormatInt(expires, 10)}
+		delete(data, field+".msg")
+		if res.status != "" {
+			data[field+".msg"] = []string{res.status}
+		}
+	})
+}
+
+// lookupError returns nil when the error only means the record does not exist,
+// which is how a not listed address is reported.
+func lookupError(err error) error {
+	var dnsErr *net.DNSError
+	if errors.As(err, &dnsErr) && dnsErr.IsNotFound {
+		return nil
 	}
+	return err
 }
 
 func init() {
@@ -7,7 +7,11 @@
 package operators
 
 import (
+	"context"
+	"errors"
+	"net"
 	"testing"
+	"time"
 
 	"github.com/foxcpp/go-mockdns"
 
@@ -87,3 +91,164 @@ func TestRbl(t *testing.T) {
 		}
 	})
 }
+
+func newRBLTestServer(t *testing.T, op *rbl) {
+	t.Helper()
+	srv, err := mockdns.NewServerWithLogger(map[string]mockdns.Zone{
+		"blocked.xbl.spamhaus.org.": {
+			A:   []string{"1.2.3.6"},
+			TXT: []string{"blocked"},
+		},
+	}, &testLogger{t}, false)
+	if err != nil {
+		t.Fatalf("Cannot start mockdns server: %v", err)
+	}
+	srv.PatchNet(op.resolver)
+	t.Cleanup(func() {
+		mockdns.UnpatchNet(op.resolver)
+		srv.Close()
+	})
+}
+
+// failingResolver fails every lookup
+func failingResolver() *net.Resolver {
+	return &net.Resolver{
+		PreferGo: true,
+		Dial: func(context.Context, string, string) (net.Conn, error) {
+			return nil, errors.New("unreachable")
+		},
+	}
+}
+
+func TestRblCache(t *testing.T) {
+	op, err := newRBL(plugintypes.OperatorOptions{Arguments: "xbl.spamhaus.org ttl=1m negative_ttl=10s"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	o := op.(*rbl)
+	o.resolver = &net.Resolver{}
+	newRBLTestServer(t, o)
+	now := time.Now()
+	o.now = func() time.Time { return now }
+
+	waf := corazawaf.NewWAF()
+	tx := waf.NewTransaction()
+	if !o.Evaluate(tx, "blocked") {
+		t.Fatal("expected blocked address to match")
+	}
+	if o.Evaluate(tx, "clean") {
+		t.Fatal("unexpected match of a not listed address")
+	}
+
+	// the cached results are used while they are fresh
+	o.resolver = failingResolver()
+	now = now.Add(30 * time.Second)
+	tx = waf.NewTransaction()
+	if !o.Evaluate(tx, "blocked") {
+		t.Error("expected the cached listed address to match")
+	}
+	if want, have := "blocked", tx.Variables().TX().Get("httpbl_msg"); len(have) != 1 || have[0] != want {
+		t.Errorf("unexpected httpbl_msg, want %q, have %q", want, have)
+	}
+	if o.Evaluate(tx, "clean") {
+		t.Error("unexpected match of a not listed address")
+	}
+	if want, have := "1", tx.Variables().TX().Get("dependency_error_rbl"); len(have) != 1 || have[0] != want {
+		t.Errorf("expected the expired negative result to be looked up again, have %q", have)
+	}
+
+	// the rules looking up the service share the cache
+	op, err = newRBL(plugintypes.OperatorOptions{Arguments: "XBL.spamhaus.org"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	other := op.(*rbl)
+	other.resolver = failingResolver()
+	if !other.Evaluate(waf.NewTransaction(), "blocked") {
+		t.Error("expected the result cached by another rule to match")
+	}
+	if other.Evaluate(corazawaf.NewWAF().NewTransaction(), "blocked") {
+		t.Error("unexpected result cached by the rule of another WAF")
+	}
+
+	now = now.Add(time.Minute)
+	tx = waf.NewTransaction()
+	if o.Evaluate(tx, "blocked") {
+		t.Error("expected the expired listed address to be looked up again")
+	}
+}
+
+func TestRblAsync(t *testing.T) {
+	op, err := newRBL(plugintypes.OperatorOptions{Arguments: "xbl.spamhaus.org async"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	o := op.(*rbl)
+	o.resolver = &net.Resolver{}
+	newRBLTestServer(t, o)
+
+	waf := corazawaf.NewWAF()
+	waf.PersistentCollections.Set("ip", "blocked", map[string][]string{"score": {"3"}})
+	if o.Evaluate(waf.NewTransaction(), "blocked") {
+		t.Fatal("expected the first lookup not to block the transaction")
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		if _, ok := o.recorded(waf.PersistentCollections, "blocked"); ok {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("the asynchronous lookup didn't complete")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if !o.Evaluate(waf.NewTransaction(), "blocked") {
+		t.Error("expected the cached result to match")
+	}
+	data, _ := waf.PersistentCollections.Get("ip", "blocked")
+	for field, want := range map[string]string{
+		"score":                    "3",
+		"rbl.xbl.spamhaus.org":     "1",
+		"rbl.xbl.spamhaus.org.msg": "blocked",
+	} {
+		if have := data[field]; len(have) != 1 || have[0] != want {
+			t.Errorf("unexpected %s, want %q, have %q", field, want, have)
+		}
+	}
+
+	// another operator reads the result recorded in the store without
+	// looking it up
+	op, err = newRBL(plugintypes.OperatorOptions{Arguments: "XBL.spamhaus.org async"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	other := op.(*rbl)
+	other.resolver = &net.Resolver{}
+	tx := waf.NewTransaction()
+	if !other.Evaluate(tx, "blocked") {
+		t.Error("expected the recorded result to match")
+	}
+	if want, have := "blocked", tx.Variables().TX().Get("httpbl_msg"); len(have) != 1 || have[0] != want {
+		t.Errorf("unexpected httpbl_msg, want %q, have %q", want, have)
+	}
+	other.now = func() time.Time { return time.Now().Add(o.ttl) }
+	if _, ok := other.recorded(waf.PersistentCollections, "blocked"); ok {
+		t.Error("unexpected expired result read")
+	}
+}
+
+func TestRblOptions(t *testing.T) {
+	for _, args := range []string{
+		"",
+		"xbl.spamhaus.org ttl",
+		"xbl.spamhaus.org ttl=-1s",
+		"xbl.spamhaus.org timeout=0s",
+		"xbl.spamhaus.org unknown=1",
+		"xbl.spamhaus.org async ttl=0s",
+		"xbl.spamhaus.org async=false",
+	} {
+		if _, err := newRBL(plugintypes.OperatorOptions{Arguments: args}); err == nil {
+			t.Errorf("expected error for %q", args)
+		}
+	}
+}
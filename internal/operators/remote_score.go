@@ -0,0 +1,239 @@
+// Copyright 2024 Juan Pablo Tosso and the OWASP Coraza contributors
+// SPDX-License-Identifier: Apache-2.0
+
+//go:build !tinygo && !coraza.disabled_operators.remoteScore
+
+package operators
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ad3n/seclang/experimental/plugins/plugintypes"
+	"github.com/ad3n/seclang/internal/collections"
+	"github.com/ad3n/seclang/internal/corazatypes"
+	seclangio "github.com/ad3n/seclang/internal/io"
+)
+
+const (
+	defaultRemoteScoreTimeout = 100 * time.Millisecond
+	// maxRemoteScoreResponseSize limits the size of the responses read from a
+	// misbehaving endpoint
+	maxRemoteScoreResponseSize = 64 << 10
+)
+
+// remoteScoreClient calls the endpoints of @remoteScore, the deadline of
+// each call is set by the operator
+var remoteScoreClient = &http.Client{}
+
+// remoteScoreCredentialHeaders are the request headers not sent to the
+// endpoint with send=tx, they carry the credentials of the client
+var remoteScoreCredentialHeaders = map[string]bool{
+	"authorization":       true,
+	"proxy-authorization": true,
+	"cookie":              true,
+}
+
+// remoteScore posts the value, or a summary of the transaction with send=tx,
+// to an https endpoint scoring it, e.g. a machine learning model, and matches
+// if the score is greater than the threshold. The endpoint receives a JSON
+// object and responds with {"score": NUMBER}. The score is captured in TX:0
+// and set in REMOTE_SCORE. The calls exceeding the timeout, 100ms by
+// default, and the errors are dependency failures. The summary of the
+// transaction leaves out the Authorization, Proxy-Authorization and Cookie
+// headers. The operator is not allowed when the files are confined to a
+// directory, e.g. for the rules of a tenant.
+//
+// With ttl, the scores of the values are cached for the duration. The cache
+// is shared by the rules of the WAF calling the same endpoint, and the
+// concurrent calls for a value share the same request.
+//
+//	@remoteScore URL threshold=NUMBER [timeout=DURATION] [send=value|tx] [ttl=DURATION]
+type remoteScore struct {
+	endpoint  string
+	threshold float64
+	timeout   time.Duration
+	// sendTx posts the summary of the transaction instead of the value
+	sendTx bool
+	ttl    time.Duration
+	// cache is used when the transaction doesn't share the cache of the
+	// endpoint with the other rules
+	cache *lookupCache[float64]
+}
+
+var _ plugintypes.Operator = (*remoteScore)(nil)
+
+// remoteScoreCacheKey is the key of the cache shared by the rules calling
+// the endpoint
+type remoteScoreCacheKey struct {
+	endpoint string
+}
+
+func newRemoteScore(options plugintypes.OperatorOptions) (plugintypes.Operator, error) {
+	endpoint, opts := cutOptions(options.Arguments, "threshold", "timeout", "send", "ttl")
+	u, err := url.Parse(endpoint)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, fmt.Errorf("invalid remoteScore endpoint %q", endpoint)
+	}
+	if u.Scheme != "https" {
+		return nil, fmt.Errorf("remoteScore endpoint %q must use https", endpoint)
+	}
+	if seclangio.IsConfined(options.Root) {
+		return nil, fmt.Errorf("remoteScore endpoint %q: %w", endpoint, seclangio.ErrNotConfinable)
+	}
+	o := &remoteScore{endpoint: endpoint, timeout: defaultRemoteScoreTimeout}
+
+	threshold, ok := opts["threshold"]
+	if !ok {
+		return nil, errors.New("missing the remoteScore threshold")
+	}
+	if o.threshold, err = strconv.ParseFloat(threshold, 64); err != nil {
+		return nil, fmt.Errorf("invalid remoteScore threshold %q", threshold)
+	}
+	if value, ok := opts["timeout"]; ok {
+		if o.timeout, err = time.ParseDuration(value); err != nil || o.timeout <= 0 {
+			return nil, fmt.Errorf("invalid remoteScore timeout %q", value)
+		}
+	}
+	switch send := opts["send"]; send {
+	case "", "value":
+	case "tx":
+		o.sendTx = true
+	default:
+		return nil, fmt.Errorf("invalid remoteScore send %q, expected value or tx", send)
+	}
+	if value, ok := opts["ttl"]; ok {
+		if o.ttl, err = time.ParseDuration(value); err != nil || o.ttl < 0 {
+			return nil, fmt.Errorf("invalid remoteScore ttl %q", value)
+		}
+	}
+	o.cache = newLookupCache[float64](time.Now)
+	return o, nil
+}
+
+// remoteScoreRequest is the body posted to the endpoint, the transaction
+// fields are only set with send=tx
+type remoteScoreRequest struct {
+	Value         string              `json:"value"`
+	TransactionID string              `json:"transaction_id,omitempty"`
+	ClientIP      string              `json:"client_ip,omitempty"`
+	Method        string              `json:"method,omitempty"`
+	URI           string              `json:"uri,omitempty"`
+	Protocol      string              `json:"protocol,omitempty"`
+	Headers       map[string][]string `json:"headers,omitempty"`
+	Args          map[string][]string `json:"args,omitempty"`
+}
+
+type remoteScoreResponse struct {
+	Score *float64 `json:"score"`
+}
+
+func (o *remoteScore) Evaluate(tx plugintypes.TransactionState, value string) bool {
+	var (
+		score float64
+		err   error
+	)
+	if o.sendTx {
+		score, err = o.call(o.transactionRequest(tx, value))
+	} else {
+		// the values are hashed so the cache doesn't keep large bodies
+		key := sha256.Sum256([]byte(value))
+		cache := sharedLookupCache(tx, remoteScoreCacheKey{endpoint: o.endpoint}, o.cache)
+		score, err = cache.do(string(key[:]), o.cacheTTL, func() (float64, error) {
+			return o.call(remoteScoreRequest{Value: value})
+		})
+	}
+	if err != nil {
+		return tx.DependencyFailed("remotescore", err)
+	}
+
+	s := strconv.FormatFloat(score, 'f', -1, 64)
+	if col, ok := tx.Collection(corazatypes.RemoteScore).(*collections.Single); ok {
+		col.Set(s)
+	}
+	if score <= o.threshold {
+		return false
+	}
+	if tx.Capturing() {
+		tx.CaptureField(0, s)
+	}
+	return true
+}
+
+func (o *remoteScore) cacheTTL(float64) time.Duration {
+	return o.ttl
+}
+
+func (o *remoteScore) transactionRequest(tx plugintypes.TransactionState, value string) remoteScoreRequest {
+	v := tx.Variables()
+	req := remoteScoreRequest{
+		Value:         value,
+		TransactionID: tx.ID(),
+		ClientIP:      v.RemoteAddr().Get(),
+		Method:        v.RequestMethod().Get(),
+		URI:           v.RequestURI().Get(),
+		Protocol:      v.RequestProtocol().Get(),
+		Headers:       map[string][]string{},
+		Args:          map[string][]string{},
+	}
+	for _, md := range v.RequestHeaders().FindAll() {
+		if remoteScoreCredentialHeaders[strings.ToLower(md.Key())] {
+			continue
+		}
+		req.Headers[md.Key()] = append(req.Headers[md.Key()], md.Value())
+	}
+	for _, md := range v.Args().FindAll() {
+		req.Args[md.Key()] = append(req.Args[md.Key()], md.Value())
+	}
+	return req
+}
+
+func (o *remoteScore) call(body remoteScoreRequest) (float64, error) {
+	data, err := json.Marshal(body)
+	if err != nil {
+		return 0, err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), o.timeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, o.endpoint, bytes.NewReader(data))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	res, err := remoteScoreClient.Do(req)
+	if err != nil {
+		if ctx.Err() != nil {
+			return 0, fmt.Errorf("scoring timed out after %s", o.timeout)
+		}
+		return 0, fmt.Errorf("scoring failed: %s", err.Error())
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return 0, fmt.Errorf("scoring failed: unexpected status %d", res.StatusCode)
+	}
+	var score remoteScoreResponse
+	if err := json.NewDecoder(io.LimitReader(res.Body, maxRemoteScoreResponseSize)).Decode(&score); err != nil {
+		if ctx.Err() != nil {
+			return 0, fmt.Errorf("scoring timed out after %s", o.timeout)
+		}
+		return 0, fmt.Errorf("invalid score response: %s", err.Error())
+	}
+	if score.Score == nil {
+		return 0, errors.New("invalid score response: missing score")
+	}
+	return *score.Score, nil
+}
+
+func init() {
+	Register("remoteScore", newRemoteScore)
+}
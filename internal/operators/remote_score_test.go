@@ -0,0 +1,178 @@
+// Copyright 2024 Juan Pablo Tosso and the OWASP Coraza contributors
+// SPDX-License-Identifier: Apache-2.0
+
+//go:build !tinygo && !coraza.disabled_operators.remoteScore
+
+package operators
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/ad3n/seclang/experimental/plugins/plugintypes"
+	"github.com/ad3n/seclang/internal/collections"
+	"github.com/ad3n/seclang/internal/corazatypes"
+	"github.com/ad3n/seclang/internal/corazawaf"
+	"github.com/ad3n/seclang/internal/io"
+)
+
+// newScoringServer scores the values by their number of quotes
+func newScoringServer(t *testing.T, calls *atomic.Int32) *httptest.Server {
+	t.Helper()
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		var req remoteScoreRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		switch req.Value {
+		case "slow":
+			time.Sleep(200 * time.Millisecond)
+		case "broken":
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		score := float64(strings.Count(req.Value, "'")) / 4
+		if req.Method != "" {
+			// the summary of the transaction is scored by its client
+			score = 0
+			if req.ClientIP == "203.0.113.7" && slices.Equal(req.Headers["User-Agent"], []string{"sqlmap"}) && slices.Equal(req.Args["id"], []string{"1"}) {
+				score = 1
+			}
+			// the credentials of the client are not sent
+			for _, name := range []string{"Authorization", "Cookie"} {
+				if _, ok := req.Headers[name]; ok {
+					score = 0
+				}
+			}
+		}
+		_ = json.NewEncoder(w).Encode(map[string]float64{"score": score})
+	}))
+	t.Cleanup(srv.Close)
+	client := remoteScoreClient
+	remoteScoreClient = srv.Client()
+	t.Cleanup(func() { remoteScoreClient = client })
+	return srv
+}
+
+func TestRemoteScore(t *testing.T) {
+	var calls atomic.Int32
+	srv := newScoringServer(t, &calls)
+	op, err := newRemoteScore(plugintypes.OperatorOptions{Arguments: srv.URL + " threshold=0.5 timeout=50ms"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		value   string
+		match   bool
+		score   string
+		failure bool
+	}{
+		{value: "1' OR '1'='1", match: true, score: "1"},
+		{value: "O'Reilly", match: false, score: "0.25"},
+		{value: "''", match: false, score: "0.5"},
+		{value: "slow", failure: true},
+		{value: "broken", failure: true},
+	}
+	waf := corazawaf.NewWAF()
+	for _, tt := range tests {
+		tx := waf.NewTransaction()
+		tx.Capture = true
+		if want, have := tt.match, op.Evaluate(tx, tt.value); want != have {
+			t.Errorf("unexpected result for %q, want %t, have %t", tt.value, want, have)
+		}
+		if want, have := tt.score, tx.Collection(corazatypes.RemoteScore).(*collections.Single).Get(); want != have {
+			t.Errorf("unexpected score of %q, want %q, have %q", tt.value, want, have)
+		}
+		if want, have := tt.failure, len(tx.Variables().TX().Get("dependency_error_remotescore")) > 0; want != have {
+			t.Errorf("unexpected dependency failure of %q, want %t, have %t", tt.value, want, have)
+		}
+		if tt.match {
+			if want, have := tt.score, strings.Join(tx.Variables().TX().Get("0"), ","); want != have {
+				t.Errorf("unexpected capture of %q, want %q, have %q", tt.value, want, have)
+			}
+		}
+		tx.Close()
+	}
+}
+
+func TestRemoteScoreCache(t *testing.T) {
+	var calls atomic.Int32
+	srv := newScoringServer(t, &calls)
+	op, err := newRemoteScore(plugintypes.OperatorOptions{Arguments: srv.URL + " threshold=0.5 ttl=1m"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	waf := corazawaf.NewWAF()
+	for i := 0; i < 5; i++ {
+		tx := waf.NewTransaction()
+		if !op.Evaluate(tx, "' OR '1'='1") {
+			t.Error("expected the value to match")
+		}
+		tx.Close()
+	}
+	if want, have := int32(1), calls.Load(); want != have {
+		t.Errorf("unexpected calls, want %d, have %d", want, have)
+	}
+}
+
+func TestRemoteScoreTransaction(t *testing.T) {
+	var calls atomic.Int32
+	srv := newScoringServer(t, &calls)
+	op, err := newRemoteScore(plugintypes.OperatorOptions{Arguments: srv.URL + " threshold=0.5 send=tx ttl=1m"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	waf := corazawaf.NewWAF()
+	for _, ua := range []string{"sqlmap", "curl"} {
+		tx := waf.NewTransaction()
+		tx.ProcessConnection("203.0.113.7", 4321, "127.0.0.1", 80)
+		tx.ProcessURI("/item?id=1", "GET", "HTTP/1.1")
+		tx.AddRequestHeader("User-Agent", ua)
+		tx.AddRequestHeader("Authorization", "Bearer secret")
+		tx.AddRequestHeader("Cookie", "sid=secret")
+		if want, have := ua == "sqlmap", op.Evaluate(tx, "1"); want != have {
+			t.Errorf("unexpected result for %s, want %t, have %t", ua, want, have)
+		}
+		tx.Close()
+	}
+	// the summaries of the transactions are not cached
+	if want, have := int32(2), calls.Load(); want != have {
+		t.Errorf("unexpected calls, want %d, have %d", want, have)
+	}
+}
+
+func TestRemoteScoreOptions(t *testing.T) {
+	for _, arguments := range []string{
+		"",
+		"https://scoring.example.com",
+		"ftp://scoring.example.com threshold=1",
+		"http://scoring.example.com threshold=1",
+		"https://scoring.example.com threshold=high",
+		"https://scoring.example.com threshold=1 timeout=0s",
+		"https://scoring.example.com threshold=1 send=body",
+		"https://scoring.example.com threshold=1 ttl=-1s",
+	} {
+		if _, err := newRemoteScore(plugintypes.OperatorOptions{Arguments: arguments}); err == nil {
+			t.Errorf("expected error for %q", arguments)
+		}
+	}
+
+	root, err := io.NewConfinedFS(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = newRemoteScore(plugintypes.OperatorOptions{Arguments: "https://scoring.example.com threshold=1", Root: root})
+	if !errors.Is(err, io.ErrNotConfinable) {
+		t.Errorf("unexpected error with confined files: %v", err)
+	}
+}
@@ -0,0 +1,20 @@
+// Copyright 2024 Juan Pablo Tosso and the OWASP Coraza contributors
+// SPDX-License-Identifier: Apache-2.0
+
+//go:build tinygo && !coraza.disabled_operators.remoteScore
+
+package operators
+
+import (
+	"errors"
+
+	"github.com/ad3n/seclang/experimental/plugins/plugintypes"
+)
+
+func newRemoteScore(plugintypes.OperatorOptions) (plugintypes.Operator, error) {
+	return nil, errors.New("remoteScore is not supported by TinyGo")
+}
+
+func init() {
+	Register("remoteScore", newRemoteScore)
+}
@@ -0,0 +1,98 @@
+// Copyright 2024 Juan Pablo Tosso and the OWASP Coraza contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package operators
+
+import (
+	"io"
+	"strings"
+
+	"github.com/ad3n/seclang/experimental/plugins/plugintypes"
+	"github.com/corazawaf/coraza/v3/collection"
+	"github.com/corazawaf/coraza/v3/types/variables"
+)
+
+// requestBodyReader is implemented by the transactions giving access to the
+// request body
+type requestBodyReader interface {
+	RequestBodyReader() (io.Reader, error)
+}
+
+// operatorValues is implemented by the transactions keeping the values the
+// operators compute once per transaction, like the request body decoded
+type operatorValues interface {
+	OperatorValue(key any) (any, bool)
+	SetOperatorValue(key any, value any)
+}
+
+// operatorValue returns the value stored in the transaction for the key
+func operatorValue(tx plugintypes.TransactionState, key any) (any, bool) {
+	if values, ok := tx.(operatorValues); ok {
+		return values.OperatorValue(key)
+	}
+	return nil, false
+}
+
+// setOperatorValue stores the value in the transaction for the key, if the
+// transaction keeps the values of the operators
+func setOperatorValue(tx plugintypes.TransactionState, key any, value any) {
+	if values, ok := tx.(operatorValues); ok {
+		values.SetOperatorValue(key, value)
+	}
+}
+
+// bodyDocument decodes the request body processed by a body processor for
+// the operators evaluating it whatever the value of the variable. The body is
+// decoded once per transaction and kept in the transaction for all the
+// operators of the processor, the operators are evaluated for every value of
+// the variable.
+type bodyDocument struct {
+	// processor is the body processor the body must have been processed with
+	processor string
+	decode    func(data []byte) (any, error)
+}
+
+// bodyDocumentKey is the key of the request body decoded for a processor in
+// the values of the operators of the transaction
+type bodyDocumentKey struct {
+	processor string
+}
+
+// decodedBody is the request body decoded, ok is false if it is not valid
+type decodedBody struct {
+	doc any
+	ok  bool
+}
+
+// get returns the request body decoded, false if it was not processed by
+// the processor or is not valid
+func (d *bodyDocument) get(tx plugintypes.TransactionState) (any, bool) {
+	processor, ok := tx.Collection(variables.ReqbodyProcessor).(collection.Single)
+	if !ok || !strings.EqualFold(processor.Get(), d.processor) {
+		return nil, false
+	}
+	body, ok := tx.(requestBodyReader)
+	if !ok {
+		return nil, false
+	}
+
+	key := bodyDocumentKey{processor: d.processor}
+	if v, ok := operatorValue(tx, key); ok {
+		cached := v.(decodedBody)
+		return cached.doc, cached.ok
+	}
+	var doc any
+	reader, err := body.RequestBodyReader()
+	var data []byte
+	if err == nil {
+		data, err = io.ReadAll(reader)
+	}
+	if err == nil {
+		doc, err = d.decode(data)
+	}
+	if err != nil {
+		tx.DebugLogger().Debug().Err(err).Str("processor", d.processor).Msg("Failed to decode the request body")
+	}
+	setOperatorValue(tx, key, decodedBody{doc: doc, ok: err == nil})
+	return doc, err == nil
+}
@@ -0,0 +1,86 @@
+// Copyright 2024 Juan Pablo Tosso and the OWASP Coraza contributors
+// SPDX-License-Identifier: Apache-2.0
+
+//go:build !coraza.disabled_operators.rsub
+
+package operators
+
+import (
+	"errors"
+	"regexp"
+	"strings"
+
+	"github.com/ad3n/seclang/experimental/plugins/plugintypes"
+	"github.com/ad3n/seclang/internal/memoize"
+)
+
+// rsub matches the values with a regular expression and rewrites the stream
+// variables, STREAM_INPUT_BODY and STREAM_OUTPUT_BODY, replacing the matches.
+// The argument uses the s/REGEX/REPLACEMENT/[i] syntax, the replacement can
+// reference the groups of the expression with $1, $2...
+type rsub struct {
+	re          *regexp.Regexp
+	replacement string
+}
+
+var _ plugintypes.Operator = (*rsub)(nil)
+
+func newRSub(options plugintypes.OperatorOptions) (plugintypes.Operator, error) {
+	expr, replacement, flags, err := parseSubstitution(options.Arguments)
+	if err != nil {
+		return nil, err
+	}
+	switch flags {
+	case "":
+	case "i":
+		expr = "(?i)" + expr
+	default:
+		return nil, errors.New("invalid @rsub flags, only i is supported")
+	}
+
+	re, err := memoize.Do(expr, func() (interface{}, error) { return regexp.Compile(expr) })
+	if err != nil {
+		return nil, err
+	}
+	return &rsub{re: re.(*regexp.Regexp), replacement: replacement}, nil
+}
+
+func (o *rsub) Evaluate(tx plugintypes.TransactionState, value string) bool {
+	if !o.re.MatchString(value) {
+		return false
+	}
+	// only the stream variables are rewritten, the transaction ignores other values
+	if s, ok := tx.(interface{ RewriteStreamBody(string, string) bool }); ok {
+		s.RewriteStreamBody(value, o.re.ReplaceAllString(value, o.replacement))
+	}
+	return true
+}
+
+// parseSubstitution splits s/REGEX/REPLACEMENT/FLAGS, slashes are escaped as \/
+func parseSubstitution(data string) (expr, replacement, flags string, err error) {
+	if !strings.HasPrefix(data, "s/") {
+		return "", "", "", errors.New("invalid @rsub argument, expected s/REGEX/REPLACEMENT/")
+	}
+	var parts []string
+	var sb strings.Builder
+	for i := 2; i < len(data); i++ {
+		switch c := data[i]; {
+		case c == '\\' && i+1 < len(data) && data[i+1] == '/':
+			sb.WriteByte('/')
+			i++
+		case c == '/' && len(parts) < 2:
+			parts = append(parts, sb.String())
+			sb.Reset()
+		default:
+			sb.WriteByte(c)
+		}
+	}
+	if len(parts) != 2 {
+		return "", "", "", errors.New("invalid @rsub argument, expected s/REGEX/REPLACEMENT/")
+	}
+	return parts[0], parts[1], sb.String(), nil
+}
+
+func init() {
+	Register("rsub", newRSub)
+}
@@ -0,0 +1,66 @@
+// Copyright 2024 Juan Pablo Tosso and the OWASP Coraza contributors
+// SPDX-License-Identifier: Apache-2.0
+
+//go:build !coraza.disabled_operators.rsub
+
+package operators
+
+import (
+	"testing"
+
+	"github.com/ad3n/seclang/experimental/plugins/plugintypes"
+	"github.com/ad3n/seclang/internal/corazawaf"
+)
+
+func TestParseSubstitution(t *testing.T) {
+	tests := []struct {
+		data                     string
+		expr, replacement, flags string
+		wantErr                  bool
+	}{
+		{data: "s/a/b/", expr: "a", replacement: "b"},
+		{data: "s/a/b/i", expr: "a", replacement: "b", flags: "i"},
+		{data: `s/<\/script>/<\/noscript>/`, expr: "</script>", replacement: "</noscript>"},
+		{data: `s/a\d/$0x/`, expr: `a\d`, replacement: "$0x"},
+		{data: "s/a/b", wantErr: true},
+		{data: "a/b/", wantErr: true},
+	}
+	for _, tt := range tests {
+		expr, replacement, flags, err := parseSubstitution(tt.data)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("expected error for %q", tt.data)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("unexpected error for %q: %s", tt.data, err)
+			continue
+		}
+		if expr != tt.expr || replacement != tt.replacement || flags != tt.flags {
+			t.Errorf("unexpected substitution for %q, want %q %q %q, have %q %q %q",
+				tt.data, tt.expr, tt.replacement, tt.flags, expr, replacement, flags)
+		}
+	}
+}
+
+func TestRSub(t *testing.T) {
+	op, err := newRSub(plugintypes.OperatorOptions{Arguments: "s/secret/redacted/i"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tx := corazawaf.NewWAF().NewTransaction()
+	defer tx.Close()
+	if !op.Evaluate(tx, "a SECRET value") {
+		t.Error("expected match")
+	}
+	if op.Evaluate(tx, "a public value") {
+		t.Error("unexpected match")
+	}
+
+	for _, args := range []string{"s/(/b/", "s/a/b/g", "a"} {
+		if _, err := newRSub(plugintypes.OperatorOptions{Arguments: args}); err == nil {
+			t.Errorf("expected error for %q", args)
+		}
+	}
+}
@@ -8,7 +8,10 @@ package operators
 import (
 	"fmt"
 	"regexp"
+	"slices"
 	"strconv"
+	"strings"
+	"time"
 	"unicode/utf8"
 
 	"rsc.io/binaryregexp"
@@ -17,92 +20,203 @@ import (
 	"github.com/ad3n/seclang/internal/memoize"
 )
 
+// regex is implemented by the regexp, binaryregexp and PCRE2 engines
+type regex interface {
+	MatchString(s string) bool
+	FindStringSubmatch(s string) []string
+	SubexpNames() []string
+}
+
+// limitedRegex is implemented by the engines aborting the matches over a
+// limit, PCRE2, ok is false when the match has been aborted
+type limitedRegex interface {
+	matchStringLimited(s string) (matched bool, ok bool)
+	findStringSubmatchLimited(s string) (match []string, ok bool)
+}
+
+// pcreLimits bound the matches of the expressions compiled with PCRE2, see
+// SecPcreMatchLimit and SecPcreMatchLimitRecursion, zero applies the default
+type pcreLimits struct {
+	match int
+	depth int
+}
+
+// rx matches the value with a regular expression, the engine is selected once
+// per pattern when it is compiled:
+//
+//   - regexp is used by default, the value is matched as utf8 text.
+//   - binaryregexp is used when the pattern matches bytes that are not valid utf8,
+//     e.g. \xac\xed. The value is matched byte by byte, non ASCII characters in the
+//     pattern are matched as their utf8 encoding. As a consequence, character classes
+//     with non ASCII characters like [é] match any of their bytes, unicode classes like
+//     \p{L} never match non ASCII text and case folding only applies to ASCII.
+//   - PCRE2 is used, when built with the coraza.rule.pcre2 tag, for the patterns the
+//     other engines reject, e.g. the lookarounds and backreferences of legacy rules.
+//     The value is matched byte by byte and the matches are bounded by the limits of
+//     SecPcreMatchLimit and SecPcreMatchLimitRecursion, a match aborted by a limit
+//     doesn't match and is reported as exceeding the budget of the transaction.
+//
+// The groups are captured in TX:0 to TX:9, or up to SecCaptureLimit, and the
+// named groups are also captured in TX:<name>, e.g. (?P<user>\w+) in TX:user,
+// whatever their index.
+//
+// The evaluation is bounded by the budget of the transaction, see SecRxBudget
+// and the rxBudget action: only the first bytes of the value up to the input
+// limit are matched and the matches longer than the time limit are reported.
 type rx struct {
-	re *regexp.Regexp
+	re regex
+	// names holds the names of the groups, nil if none is named
+	names []string
 }
 
 var _ plugintypes.Operator = (*rx)(nil)
 
 func newRX(options plugintypes.OperatorOptions) (plugintypes.Operator, error) {
-	var data string
-	if shouldNotUseMultilineRegexesOperatorByDefault {
-		// (?s) enables dotall mode, required by some CRS rules and matching ModSec behavior, see
-		// - https://github.com/google/re2/wiki/Syntax
-		// - Flag usage: https://groups.google.com/g/golang-nuts/c/jiVdamGFU9E
-		data = fmt.Sprintf("(?s)%s", options.Arguments)
-	} else {
-		// TODO: deprecate multiline modifier set by default in Coraza v4
-		// CRS rules will explicitly set the multiline modifier when needed
-		// Having it enabled by default can lead to false positives and less performance
-		// See https://github.com/corazawaf/coraza/pull/876
-		data = fmt.Sprintf("(?sm)%s", options.Arguments)
+	flags := defaultRxFlags()
+	if options.HasRxDefaultFlags {
+		flags = options.RxDefaultFlags
 	}
-
-	if matchesArbitraryBytes(data) {
-		// Use binary regex matcher if expression matches non-utf8 bytes. The binary matcher does
-		// not match unicode, meaning we cannot support expressions with both unicode and non-utf8
-		// matches. This should not be commonly needed.
-		return newBinaryRX(options)
+	data := options.Arguments
+	if flags != "" {
+		data = fmt.Sprintf("(?%s)%s", flags, options.Arguments)
 	}
 
-	re, err := memoize.Do(data, func() (interface{}, error) { return regexp.Compile(data) })
+	limits := pcreLimits{match: options.PcreMatchLimit, depth: options.PcreMatchLimitRecursion}
+	key := data
+	if limits != (pcreLimits{}) {
+		key = fmt.Sprintf("%s\x00pcre2:%d:%d", data, limits.match, limits.depth)
+	}
+	re, err := memoize.Do(key, func() (interface{}, error) { return compileRX(data, limits) })
 	if err != nil {
 		return nil, err
 	}
-	return &rx{re: re.(*regexp.Regexp)}, nil
+	o := &rx{re: re.(regex)}
+	o.names = captureNames(o.re)
+	return o, nil
 }
 
-func (o *rx) Evaluate(tx plugintypes.TransactionState, value string) bool {
-	if tx.Capturing() {
-		match := o.re.FindStringSubmatch(value)
-		if len(match) == 0 {
-			return false
-		}
-		for i, c := range match {
-			if i == 9 {
-				return true
-			}
-			tx.CaptureField(i, c)
+// captureNames returns the names of the groups of the expression, nil if
+// none is named. Numeric names are ignored as they would be confused with the
+// numbered fields.
+func captureNames(re regex) []string {
+	// the names are owned by the memoized expression
+	names := slices.Clone(re.SubexpNames())
+	named := false
+	for i, name := range names {
+		if _, err := strconv.Atoi(name); err == nil {
+			names[i] = ""
 		}
-		return true
-	} else {
-		return o.re.MatchString(value)
+		named = named || names[i] != ""
 	}
+	if !named {
+		return nil
+	}
+	return names
 }
 
-// binaryRx is exactly the same as rx, but using the binaryregexp package for matching
-// arbitrary bytes.
-type binaryRX struct {
-	re *binaryregexp.Regexp
+// compileRX compiles the expression with the engine able to match it, PCRE2
+// is only tried if the other engines reject the expression
+func compileRX(expr string, limits pcreLimits) (regex, error) {
+	if matchesArbitraryBytes(expr) {
+		// The binary matcher does not match unicode, non ASCII characters are
+		// rewritten as the bytes of their utf8 encoding to keep matching them.
+		re, err := binaryregexp.Compile(binaryPattern(expr))
+		if err != nil {
+			return compilePCRE2Fallback(expr, limits, err)
+		}
+		return re, nil
+	}
+	re, err := regexp.Compile(expr)
+	if err != nil {
+		return compilePCRE2Fallback(expr, limits, err)
+	}
+	return re, nil
 }
 
-var _ plugintypes.Operator = (*binaryRX)(nil)
+// compilePCRE2Fallback compiles the expression rejected by the other engines
+// with PCRE2, their error is returned if it is not available or rejects it too
+func compilePCRE2Fallback(expr string, limits pcreLimits, err error) (regex, error) {
+	if re, pcreErr := compilePCRE2(expr, limits); pcreErr == nil {
+		return re, nil
+	}
+	return nil, err
+}
 
-func newBinaryRX(options plugintypes.OperatorOptions) (plugintypes.Operator, error) {
-	data := options.Arguments
+// rxBudget is implemented by the transactions bounding the rx evaluations
+type rxBudget interface {
+	RxBudget() (inputLimit int, timeLimit time.Duration)
+	RxBudgetExceeded(limit string)
+}
 
-	re, err := memoize.Do(data, func() (interface{}, error) { return binaryregexp.Compile(data) })
-	if err != nil {
-		return nil, err
+func (o *rx) Evaluate(tx plugintypes.TransactionState, value string) bool {
+	b, ok := tx.(rxBudget)
+	if !ok {
+		return o.evaluate(tx, value)
+	}
+	inputLimit, timeLimit := b.RxBudget()
+	if inputLimit > 0 && len(value) > inputLimit {
+		value = value[:inputLimit]
+		b.RxBudgetExceeded("input")
+	}
+	if timeLimit <= 0 {
+		return o.evaluate(tx, value)
 	}
-	return &binaryRX{re: re.(*binaryregexp.Regexp)}, nil
+	start := time.Now()
+	res := o.evaluate(tx, value)
+	if time.Since(start) > timeLimit {
+		b.RxBudgetExceeded("time")
+	}
+	return res
 }
 
-func (o *binaryRX) Evaluate(tx plugintypes.TransactionState, value string) bool {
-	if tx.Capturing() {
-		match := o.re.FindStringSubmatch(value)
-		if len(match) == 0 {
-			return false
+func (o *rx) evaluate(tx plugintypes.TransactionState, value string) bool {
+	l, limited := o.re.(limitedRegex)
+	if !tx.Capturing() {
+		if !limited {
+			return o.re.MatchString(value)
 		}
-		for i, c := range match {
-			if i == 9 {
-				return true
-			}
-			tx.CaptureField(i, c)
+		matched, ok := l.matchStringLimited(value)
+		if !ok {
+			matchLimitExceeded(tx)
+		}
+		return matched
+	}
+	var match []string
+	if limited {
+		var ok bool
+		if match, ok = l.findStringSubmatchLimited(value); !ok {
+			matchLimitExceeded(tx)
 		}
-		return true
 	} else {
-		return o.re.MatchString(value)
+		match = o.re.FindStringSubmatch(value)
+	}
+	if len(match) == 0 {
+		return false
+	}
+	limit := captureLimit(tx)
+	for i, c := range match {
+		if i == limit {
+			break
+		}
+		tx.CaptureField(i, c)
+	}
+	if o.names != nil {
+		if n, ok := tx.(interface{ CaptureNamedField(name, value string) }); ok {
+			for i, name := range o.names {
+				if name != "" {
+					n.CaptureNamedField(name, match[i])
+				}
+			}
+		}
+	}
+	return true
+}
+
+// matchLimitExceeded reports a match aborted by the PCRE2 limits as exceeding
+// the budget of the transaction
+func matchLimitExceeded(tx plugintypes.TransactionState) {
+	if b, ok := tx.(rxBudget); ok {
+		b.RxBudgetExceeded("match")
 	}
 }
 
@@ -110,35 +224,101 @@ func init() {
 	Register("rx", newRX)
 }
 
-// matchesArbitraryBytes checks for control sequences for byte matches in the expression.
-// If the sequences are not valid utf8, it returns true.
+// matchesArbitraryBytes checks whether the expression matches bytes instead of
+// unicode code points. Go reads \xHH, \x{HH} and octal escapes as code points,
+// e.g. \xff matches "ÿ" encoded as \xc3\xbf, while ModSecurity matches the byte,
+// so any of those escapes over 0x7F requires the binary matcher. Expressions
+// that are not valid utf8 themselves require it too.
 func matchesArbitraryBytes(expr string) bool {
-	decoded := make([]byte, 0, len(expr))
+	if !utf8.ValidString(expr) {
+		return true
+	}
 	for i := 0; i < len(expr); i++ {
-		c := expr[i]
-		if c != '\\' {
-			decoded = append(decoded, c)
+		if expr[i] != '\\' {
 			continue
 		}
-		if i+3 >= len(expr) {
-			decoded = append(decoded, expr[i:]...)
-			break
+		v, n, ok := numericEscape(expr[i:])
+		if ok && v >= utf8.RuneSelf && v <= 0xff {
+			return true
 		}
-		if expr[i+1] != 'x' {
-			decoded = append(decoded, expr[i])
+		i += n - 1
+	}
+	return false
+}
+
+// binaryPattern rewrites the non ASCII characters of the expression, and the
+// escapes of code points that don't fit in a byte, as the \xHH escapes of
+// their utf8 bytes so the binary matcher matches their encoding.
+func binaryPattern(expr string) string {
+	var sb strings.Builder
+	sb.Grow(len(expr))
+	for i := 0; i < len(expr); i++ {
+		c := expr[i]
+		if c == '\\' {
+			v, n, ok := numericEscape(expr[i:])
+			if ok && v > 0xff && utf8.ValidRune(v) {
+				writeByteEscapes(&sb, string(v))
+			} else {
+				sb.WriteString(expr[i : i+n])
+			}
+			i += n - 1
 			continue
 		}
-
-		v, mb, _, err := strconv.UnquoteChar(expr[i:], 0)
-		if err != nil || mb {
-			// Wasn't a byte escape sequence, shouldn't happen in practice.
-			decoded = append(decoded, expr[i])
+		if c >= utf8.RuneSelf {
+			writeByteEscapes(&sb, expr[i:i+1])
 			continue
 		}
+		sb.WriteByte(c)
+	}
+	return sb.String()
+}
 
-		decoded = append(decoded, byte(v))
-		i += 3
+func writeByteEscapes(sb *strings.Builder, s string) {
+	for i := 0; i < len(s); i++ {
+		sb.WriteString(`\x`)
+		sb.WriteString(strconv.FormatUint(uint64(s[i])|0x100, 16)[1:])
 	}
+}
 
-	return !utf8.Valid(decoded)
+// numericEscape parses the escape sequence at the beginning of s and returns
+// the code point of \xHH, \x{H...} and octal escapes. n is the length of the
+// escape sequence, including any other kind of escape, so it can be skipped.
+func numericEscape(s string) (v rune, n int, ok bool) {
+	if len(s) < 2 {
+		return 0, len(s), false
+	}
+	switch c := s[1]; {
+	case c == 'x':
+		if len(s) > 2 && s[2] == '{' {
+			end := strings.IndexByte(s, '}')
+			if end < 0 {
+				return 0, 2, false
+			}
+			u, err := strconv.ParseUint(s[3:end], 16, 32)
+			if err != nil {
+				return 0, end + 1, false
+			}
+			return rune(u), end + 1, true
+		}
+		if len(s) < 4 {
+			return 0, 2, false
+		}
+		u, err := strconv.ParseUint(s[2:4], 16, 8)
+		if err != nil {
+			return 0, 2, false
+		}
+		return rune(u), 4, true
+	case c >= '0' && c <= '7':
+		// up to three octal digits, e.g. \377
+		n = 2
+		for n < 4 && n < len(s) && s[n] >= '0' && s[n] <= '7' {
+			n++
+		}
+		u, _ := strconv.ParseUint(s[1:n], 8, 16)
+		return rune(u), n, true
+	case c >= utf8.RuneSelf:
+		_, size := utf8.DecodeRuneInString(s[1:])
+		return 0, 1 + size, false
+	}
+	return 0, 2, false
 }
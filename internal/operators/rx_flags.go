@@ -0,0 +1,44 @@
+// Copyright 2024 Juan Pablo Tosso and the OWASP Coraza contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package operators
+
+import "strings"
+
+// defaultRxFlags returns the flags applied to the rx expressions when
+// SecRxDefaultFlags is not configured
+func defaultRxFlags() string {
+	if shouldNotUseMultilineRegexesOperatorByDefault {
+		// (?s) enables dotall mode, required by some CRS rules and matching ModSec behavior, see
+		// - https://github.com/google/re2/wiki/Syntax
+		// - Flag usage: https://groups.google.com/g/golang-nuts/c/jiVdamGFU9E
+		return "s"
+	}
+	// TODO: deprecate multiline modifier set by default in Coraza v4
+	// CRS rules will explicitly set the multiline modifier when needed
+	// Having it enabled by default can lead to false positives and less performance
+	// See https://github.com/corazawaf/coraza/pull/876
+	return "sm"
+}
+
+// ImplicitRxFlags returns the default flags of the rx operator that the
+// expression relies on, that is the ones it doesn't set or clear itself
+// with a leading flag group like (?s) or (?-m).
+func ImplicitRxFlags(expr string) string {
+	var explicit string
+	for strings.HasPrefix(expr, "(?") {
+		end := strings.IndexByte(expr, ')')
+		if end < 0 || strings.Trim(expr[2:end], "imsU-") != "" {
+			break
+		}
+		explicit += expr[2:end]
+		expr = expr[end+1:]
+	}
+	var implicit strings.Builder
+	for _, f := range defaultRxFlags() {
+		if !strings.ContainsRune(explicit, f) {
+			implicit.WriteRune(f)
+		}
+	}
+	return implicit.String()
+}
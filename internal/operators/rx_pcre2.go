@@ -0,0 +1,161 @@
+// Copyright 2024 Juan Pablo Tosso and the OWASP Coraza contributors
+// SPDX-License-Identifier: Apache-2.0
+
+//go:build !coraza.disabled_operators.rx && coraza.rule.pcre2
+
+package operators
+
+/*
+#cgo pkg-config: libpcre2-8
+#define PCRE2_CODE_UNIT_WIDTH 8
+#include <stdlib.h>
+#include <pcre2.h>
+*/
+import "C"
+
+import (
+	"bytes"
+	"fmt"
+	"runtime"
+	"unsafe"
+)
+
+// defaultPcreMatchLimit is the match and depth limit applied when
+// SecPcreMatchLimit or SecPcreMatchLimitRecursion are not set, the value
+// recommended by ModSecurity
+const defaultPcreMatchLimit = 1000
+
+// pcreUnset is the offset of the groups that don't participate in a match
+const pcreUnset = ^C.PCRE2_SIZE(0)
+
+// emptySubject is matched instead of the empty strings, which don't have
+// an address to pass to PCRE2
+var emptySubject = [1]byte{}
+
+// pcre2Regex is an expression compiled with PCRE2 without the UTF option,
+// the values are matched byte by byte like ModSecurity does
+type pcre2Regex struct {
+	code *C.pcre2_code
+	// mctx holds the limits, it is only read by the matches so it is shared
+	mctx *C.pcre2_match_context
+	// names has the name of each group, including the whole match
+	names []string
+}
+
+var _ limitedRegex = (*pcre2Regex)(nil)
+
+func compilePCRE2(expr string, limits pcreLimits) (regex, error) {
+	pattern := C.CString(expr)
+	defer C.free(unsafe.Pointer(pattern))
+	var (
+		errCode   C.int
+		errOffset C.PCRE2_SIZE
+	)
+	code := C.pcre2_compile(C.PCRE2_SPTR(unsafe.Pointer(pattern)), C.PCRE2_SIZE(len(expr)), 0, &errCode, &errOffset, nil)
+	if code == nil {
+		var msg [256]C.PCRE2_UCHAR
+		C.pcre2_get_error_message(errCode, &msg[0], C.PCRE2_SIZE(len(msg)))
+		return nil, fmt.Errorf("invalid PCRE2 expression at offset %d: %s", int(errOffset), C.GoString((*C.char)(unsafe.Pointer(&msg[0]))))
+	}
+	// JIT may not be supported on the platform, the matches are interpreted then
+	C.pcre2_jit_compile(code, C.PCRE2_JIT_COMPLETE)
+
+	mctx := C.pcre2_match_context_create(nil)
+	C.pcre2_set_match_limit(mctx, C.uint32_t(orDefault(limits.match)))
+	C.pcre2_set_depth_limit(mctx, C.uint32_t(orDefault(limits.depth)))
+
+	re := &pcre2Regex{code: code, mctx: mctx}
+	var captures C.uint32_t
+	C.pcre2_pattern_info(code, C.PCRE2_INFO_CAPTURECOUNT, unsafe.Pointer(&captures))
+	re.names = make([]string, int(captures)+1)
+	var (
+		count, size C.uint32_t
+		table       C.PCRE2_SPTR
+	)
+	C.pcre2_pattern_info(code, C.PCRE2_INFO_NAMECOUNT, unsafe.Pointer(&count))
+	if count > 0 {
+		C.pcre2_pattern_info(code, C.PCRE2_INFO_NAMEENTRYSIZE, unsafe.Pointer(&size))
+		C.pcre2_pattern_info(code, C.PCRE2_INFO_NAMETABLE, unsafe.Pointer(&table))
+		// each entry is the group number on two bytes followed by the
+		// zero terminated name
+		entries := unsafe.Slice((*byte)(unsafe.Pointer(table)), int(count)*int(size))
+		for i := 0; i < int(count); i++ {
+			e := entries[i*int(size) : (i+1)*int(size)]
+			name := e[2:]
+			if end := bytes.IndexByte(name, 0); end != -1 {
+				name = name[:end]
+			}
+			re.names[int(e[0])<<8|int(e[1])] = string(name)
+		}
+	}
+	runtime.SetFinalizer(re, (*pcre2Regex).free)
+	return re, nil
+}
+
+func orDefault(limit int) int {
+	if limit <= 0 {
+		return defaultPcreMatchLimit
+	}
+	return limit
+}
+
+func (re *pcre2Regex) MatchString(s string) bool {
+	matched, _ := re.matchStringLimited(s)
+	return matched
+}
+
+func (re *pcre2Regex) FindStringSubmatch(s string) []string {
+	match, _ := re.findStringSubmatchLimited(s)
+	return match
+}
+
+func (re *pcre2Regex) SubexpNames() []string {
+	return re.names
+}
+
+func (re *pcre2Regex) matchStringLimited(s string) (bool, bool) {
+	_, matched, ok := re.exec(s, false)
+	return matched, ok
+}
+
+func (re *pcre2Regex) findStringSubmatchLimited(s string) ([]string, bool) {
+	match, _, ok := re.exec(s, true)
+	return match, ok
+}
+
+// exec matches s, the groups are returned if submatch is set. ok is false
+// when the match is aborted by a limit.
+func (re *pcre2Regex) exec(s string, submatch bool) (match []string, matched bool, ok bool) {
+	md := C.pcre2_match_data_create_from_pattern(re.code, nil)
+	defer C.pcre2_match_data_free(md)
+	subject := unsafe.Pointer(&emptySubject[0])
+	if len(s) > 0 {
+		subject = unsafe.Pointer(unsafe.StringData(s))
+	}
+	rc := C.pcre2_match(re.code, C.PCRE2_SPTR(subject), C.PCRE2_SIZE(len(s)), 0, 0, md, re.mctx)
+	runtime.KeepAlive(s)
+	switch rc {
+	case C.PCRE2_ERROR_MATCHLIMIT, C.PCRE2_ERROR_DEPTHLIMIT, C.PCRE2_ERROR_HEAPLIMIT:
+		return nil, false, false
+	}
+	if rc < 0 {
+		return nil, false, true
+	}
+	if !submatch {
+		return nil, true, true
+	}
+	ovector := unsafe.Slice(C.pcre2_get_ovector_pointer(md), 2*len(re.names))
+	match = make([]string, len(re.names))
+	for i := range match {
+		start, end := ovector[2*i], ovector[2*i+1]
+		if start != pcreUnset {
+			match[i] = s[start:end]
+		}
+	}
+	return match, true, true
+}
+
+func (re *pcre2Regex) free() {
+	C.pcre2_match_context_free(re.mctx)
+	C.pcre2_code_free(re.code)
+}
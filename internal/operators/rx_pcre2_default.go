@@ -0,0 +1,13 @@
+// Copyright 2024 Juan Pablo Tosso and the OWASP Coraza contributors
+// SPDX-License-Identifier: Apache-2.0
+
+//go:build !coraza.disabled_operators.rx && !coraza.rule.pcre2
+
+package operators
+
+import "errors"
+
+// compilePCRE2 is only available when built with the coraza.rule.pcre2 tag
+func compilePCRE2(string, pcreLimits) (regex, error) {
+	return nil, errors.New("PCRE2 is not enabled, build with the coraza.rule.pcre2 tag")
+}
@@ -0,0 +1,104 @@
+// Copyright 2024 Juan Pablo Tosso and the OWASP Coraza contributors
+// SPDX-License-Identifier: Apache-2.0
+
+//go:build !coraza.disabled_operators.rx && coraza.rule.pcre2
+
+package operators
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/ad3n/seclang/experimental/plugins/plugintypes"
+	"github.com/ad3n/seclang/internal/collections"
+	"github.com/ad3n/seclang/internal/corazatypes"
+	"github.com/ad3n/seclang/internal/corazawaf"
+)
+
+func TestRxPCRE2(t *testing.T) {
+	tests := []struct {
+		pattern string
+		input   string
+		match   bool
+	}{
+		{`foo(?=bar)`, "foobar", true},
+		{`foo(?=bar)`, "foobaz", false},
+		{`(?<!no)thing`, "something", true},
+		{`(?<!no)thing`, "nothing", false},
+		{`(\w+)=\1`, "a=a", true},
+		{`(\w+)=\1`, "a=b", false},
+		// the bytes are matched without the UTF option
+		{`\xac\xed(?=\x00)`, "\xac\xed\x00\x05", true},
+		{`(?=)`, "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.pattern, func(t *testing.T) {
+			op, err := newRX(plugintypes.OperatorOptions{Arguments: tt.pattern})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, ok := op.(*rx).re.(*pcre2Regex); !ok {
+				t.Fatal("expected the expression to be compiled with PCRE2")
+			}
+			for _, capture := range []bool{false, true} {
+				tx := corazawaf.NewWAF().NewTransaction()
+				tx.Capture = capture
+				if want, have := tt.match, op.Evaluate(tx, tt.input); want != have {
+					t.Errorf("unexpected result with capture %t, want %t, have %t", capture, want, have)
+				}
+			}
+		})
+	}
+
+	if _, err := newRX(plugintypes.OperatorOptions{Arguments: `(?<=a+)b`}); err == nil {
+		t.Error("expected error for an expression rejected by both engines")
+	}
+	// RE2 keeps matching the expressions it supports
+	op, err := newRX(plugintypes.OperatorOptions{Arguments: `a+b`})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := op.(*rx).re.(*pcre2Regex); ok {
+		t.Error("unexpected PCRE2 expression")
+	}
+}
+
+func TestRxPCRE2Captures(t *testing.T) {
+	rx, err := newRX(plugintypes.OperatorOptions{Arguments: `(?P<key>\w+)=(\w+)?(?=;);\1`})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tx := corazawaf.NewWAF().NewTransaction()
+	tx.Capture = true
+	if !rx.Evaluate(tx, "id=;id") {
+		t.Fatal("expected rx to match")
+	}
+	for field, want := range map[string]string{"0": "id=;id", "1": "id", "2": "", "key": "id"} {
+		have := ""
+		if v := tx.Variables().TX().Get(field); len(v) > 0 {
+			have = v[0]
+		}
+		if want != have {
+			t.Errorf("unexpected TX:%s, want %q, have %q", field, want, have)
+		}
+	}
+}
+
+func TestRxPCRE2Limits(t *testing.T) {
+	input := strings.Repeat("a", 30) + "!"
+	for _, capture := range []bool{false, true} {
+		rx, err := newRX(plugintypes.OperatorOptions{Arguments: `^(\w+\s?)*(?<=a)$`, PcreMatchLimit: 100})
+		if err != nil {
+			t.Fatal(err)
+		}
+		tx := corazawaf.NewWAF().NewTransaction()
+		tx.Capture = capture
+		if rx.Evaluate(tx, input) {
+			t.Error("expected the aborted match not to match")
+		}
+		if v := tx.Collection(corazatypes.RxBudgetExceeded).(*collections.Single).Get(); v != "1" {
+			t.Errorf("expected the limit to be reported with capture %t, have %q", capture, v)
+		}
+	}
+}
@@ -8,9 +8,17 @@ package operators
 import (
 	"fmt"
 	"regexp"
+	"strconv"
+	"strings"
 	"testing"
+	"time"
+
+	"github.com/corazawaf/coraza/v3/collection"
+	"github.com/corazawaf/coraza/v3/types/variables"
 
 	"github.com/ad3n/seclang/experimental/plugins/plugintypes"
+	"github.com/ad3n/seclang/internal/collections"
+	"github.com/ad3n/seclang/internal/corazatypes"
 	"github.com/ad3n/seclang/internal/corazawaf"
 )
 
@@ -108,6 +116,210 @@ func TestRx(t *testing.T) {
 	}
 }
 
+func TestRxMixedPatterns(t *testing.T) {
+	tests := []struct {
+		pattern string
+		input   string
+		want    bool
+	}{
+		{
+			// unicode literals keep matching with byte escapes
+			pattern: `ハロー\xff`,
+			input:   "ハロー\xff",
+			want:    true,
+		},
+		{
+			pattern: `ハロー\xff`,
+			input:   "ハロー\xfe",
+			want:    false,
+		},
+		{
+			// code points over a byte are matched as their utf8 encoding
+			pattern: `\x{30CF}\xff`,
+			input:   "ハ\xff",
+			want:    true,
+		},
+		{
+			// valid utf8 byte escapes match the bytes, not the code points
+			pattern: `\xc3\xa9`,
+			input:   "é",
+			want:    true,
+		},
+		{
+			pattern: `\xc3\xa9`,
+			input:   "Ã©",
+			want:    false,
+		},
+		{
+			pattern: `\x{ff}`,
+			input:   "\xff",
+			want:    true,
+		},
+		{
+			pattern: `\377`,
+			input:   "\xff",
+			want:    true,
+		},
+		{
+			pattern: `[\x80-\xff]+`,
+			input:   "é",
+			want:    true,
+		},
+		{
+			// the binary matcher applies the default flags too
+			pattern: `\xff.*end`,
+			input:   "\xff\nend",
+			want:    true,
+		},
+	}
+
+	for _, tc := range tests {
+		tt := tc
+		t.Run(fmt.Sprintf("%s/%s", tt.pattern, tt.input), func(t *testing.T) {
+			rx, err := newRX(plugintypes.OperatorOptions{Arguments: tt.pattern})
+			if err != nil {
+				t.Fatal(err)
+			}
+			waf := corazawaf.NewWAF()
+			tx := waf.NewTransaction()
+			if want, have := tt.want, rx.Evaluate(tx, tt.input); want != have {
+				t.Errorf("unexpected result, want %t, have %t", want, have)
+			}
+			// capturing must match the same way
+			tx.Capture = true
+			if want, have := tt.want, rx.Evaluate(tx, tt.input); want != have {
+				t.Errorf("unexpected result capturing, want %t, have %t", want, have)
+			}
+		})
+	}
+}
+
+func TestMatchesArbitraryBytes(t *testing.T) {
+	tests := []struct {
+		expr string
+		want bool
+	}{
+		{expr: `hello`, want: false},
+		{expr: `ハロー`, want: false},
+		{expr: `\x41\x7f`, want: false},
+		{expr: `\xac\xed`, want: true},
+		{expr: `\xc3\xa9`, want: true},
+		{expr: `\x{ff}`, want: true},
+		{expr: `\x{30CF}`, want: false},
+		{expr: `\377`, want: true},
+		{expr: `\077`, want: false},
+		{expr: `\\xff`, want: false},
+		{expr: `\\\xff`, want: true},
+		{expr: `[\x80-\xff]`, want: true},
+		{expr: "\xff", want: true},
+		{expr: `\x`, want: false},
+		{expr: `\`, want: false},
+	}
+
+	for _, tt := range tests {
+		if want, have := tt.want, matchesArbitraryBytes(tt.expr); want != have {
+			t.Errorf("unexpected result for %q, want %t, have %t", tt.expr, want, have)
+		}
+	}
+}
+
+func TestRxDefaultFlags(t *testing.T) {
+	tests := []struct {
+		flags string
+		input string
+		want  bool
+	}{
+		{flags: "", input: "a\nb", want: false},
+		{flags: "m", input: "a\nb", want: true},
+		{flags: "i", input: "B", want: true},
+		{flags: "", input: "B", want: false},
+	}
+
+	for _, tt := range tests {
+		rx, err := newRX(plugintypes.OperatorOptions{
+			Arguments:         "^b",
+			RxDefaultFlags:    tt.flags,
+			HasRxDefaultFlags: true,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		tx := corazawaf.NewWAF().NewTransaction()
+		if want, have := tt.want, rx.Evaluate(tx, tt.input); want != have {
+			t.Errorf("unexpected result for flags %q and %q, want %t, have %t", tt.flags, tt.input, want, have)
+		}
+	}
+}
+
+func TestImplicitRxFlags(t *testing.T) {
+	tests := []struct {
+		expr string
+		want string
+	}{
+		{expr: "^admin", want: "sm"},
+		{expr: "(?i)^admin", want: "sm"},
+		{expr: "(?s)^admin", want: "m"},
+		{expr: "(?s-m)^admin", want: ""},
+		{expr: "(?s)(?-m)^admin", want: ""},
+		{expr: "(?i:a)(?sm)b", want: "sm"},
+		{expr: "(admin)(?sm)", want: "sm"},
+	}
+
+	for _, tt := range tests {
+		if want, have := tt.want, ImplicitRxFlags(tt.expr); want != have {
+			t.Errorf("unexpected implicit flags for %q, want %q, have %q", tt.expr, want, have)
+		}
+	}
+}
+
+func TestRxCaptureLimit(t *testing.T) {
+	pattern := `(a)(b)(c)(d)(e)(f)(g)(h)(i)(j)(k)(l)`
+	input := "abcdefghijkl"
+	tests := []struct {
+		limit int
+		want  int
+	}{
+		{limit: 10, want: 10},
+		{limit: 13, want: 13},
+		{limit: 20, want: 13},
+		{limit: 2, want: 2},
+	}
+
+	for _, tc := range tests {
+		tt := tc
+		t.Run(fmt.Sprintf("limit %d", tt.limit), func(t *testing.T) {
+			rx, err := newRX(plugintypes.OperatorOptions{Arguments: pattern})
+			if err != nil {
+				t.Fatal(err)
+			}
+			waf := corazawaf.NewWAF()
+			waf.CaptureLimit = tt.limit
+			tx := waf.NewTransaction()
+			tx.Capture = true
+			if !rx.Evaluate(tx, input) {
+				t.Fatal("expected rx to match")
+			}
+			txs := tx.Collection(variables.TX).(collection.Map)
+			if want, have := strconv.Itoa(tt.want), tx.Collection(corazatypes.CaptureCount).(collection.Single).Get(); want != have {
+				t.Errorf("unexpected capture count, want %q, have %q", want, have)
+			}
+			for i, c := range "abcdefghijkl" {
+				want := ""
+				if i+1 < tt.want {
+					want = string(c)
+				}
+				have := ""
+				if v := txs.Get(strconv.Itoa(i + 1)); len(v) > 0 {
+					have = v[0]
+				}
+				if want != have {
+					t.Errorf("unexpected TX:%d, want %q, have %q", i+1, want, have)
+				}
+			}
+		})
+	}
+}
+
 func BenchmarkRxSubstringVsMatch(b *testing.B) {
 	str := "hello world; heelloo Woorld; hello; heeeelloooo wooooooorld;hello world; heelloo Woorld; hello; heeeelloooo wooooooorld;hello world; heelloo Woorld; hello; heeeelloooo wooooooorld;hello world; heelloo Woorld; hello; heeeelloooo wooooooorld;hello world; heelloo Woorld; hello; heeeelloooo wooooooorld;hello world; heelloo Woorld; hello; heeeelloooo wooooooorld;hello world; heelloo Woorld; hello; heeeelloooo wooooooorld;"
 	rx := regexp.MustCompile(`((h.*e.*l.*l.*o.*)|\d+)`)
@@ -121,3 +333,89 @@ func BenchmarkRxSubstringVsMatch(b *testing.B) {
 		rx.FindAllString(str, 3)
 	})
 }
+
+func TestRxNamedCaptures(t *testing.T) {
+	tests := []struct {
+		pattern string
+		input   string
+		want    map[string]string
+	}{
+		{`user=(?P<user>\w+)(?:&role=(?P<role>\w+))?`, "user=admin&role=root", map[string]string{"0": "user=admin&role=root", "1": "admin", "user": "admin", "2": "root", "role": "root"}},
+		// the optional groups that don't participate are captured empty
+		{`user=(?P<user>\w+)(?:&role=(?P<role>\w+))?`, "user=admin", map[string]string{"user": "admin", "role": ""}},
+		// named groups are captured whatever the capture limit
+		{`(a)(b)(c)(d)(e)(f)(g)(h)(i)(j)(k)(?P<last>l)`, "abcdefghijkl", map[string]string{"9": "i", "10": "", "last": "l"}},
+		// binary expressions are supported too
+		{`(?P<magic>\xac\xed)`, "\xac\xed\x00\x05", map[string]string{"magic": "\xac\xed"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.pattern, func(t *testing.T) {
+			rx, err := newRX(plugintypes.OperatorOptions{Arguments: tt.pattern})
+			if err != nil {
+				t.Fatal(err)
+			}
+			tx := corazawaf.NewWAF().NewTransaction()
+			tx.Capture = true
+			tx.Variables().TX().Set("role", []string{"previous"})
+			if !rx.Evaluate(tx, tt.input) {
+				t.Fatal("expected rx to match")
+			}
+			for field, want := range tt.want {
+				have := ""
+				if v := tx.Variables().TX().Get(field); len(v) > 0 {
+					have = v[0]
+				}
+				if want != have {
+					t.Errorf("unexpected TX:%s, want %q, have %q", field, want, have)
+				}
+			}
+		})
+	}
+
+	// numeric names are not captured by name
+	rx, err := newRX(plugintypes.OperatorOptions{Arguments: `(a)(?P<1>b)`})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tx := corazawaf.NewWAF().NewTransaction()
+	tx.Capture = true
+	rx.Evaluate(tx, "ab")
+	if want, have := "a", tx.Variables().TX().Get("1"); len(have) != 1 || have[0] != want {
+		t.Errorf("unexpected TX:1, want %q, have %q", want, have)
+	}
+}
+
+func TestRxBudget(t *testing.T) {
+	tests := []struct {
+		name     string
+		budget   corazawaf.RxBudget
+		input    string
+		match    bool
+		exceeded bool
+	}{
+		{"unbounded", corazawaf.RxBudget{}, "aaaab", true, false},
+		{"within the input limit", corazawaf.RxBudget{InputLimit: 5}, "aaaab", true, false},
+		{"truncated input", corazawaf.RxBudget{InputLimit: 4}, "aaaab", false, true},
+		{"slow match", corazawaf.RxBudget{TimeLimit: time.Nanosecond}, strings.Repeat("a", 10000) + "b", true, true},
+	}
+
+	rx, err := newRX(plugintypes.OperatorOptions{Arguments: `a+b`})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			waf := corazawaf.NewWAF()
+			waf.RxBudget = tt.budget
+			tx := waf.NewTransaction()
+			if want, have := tt.match, rx.Evaluate(tx, tt.input); want != have {
+				t.Errorf("unexpected result, want %t, have %t", want, have)
+			}
+			exceeded := tx.Collection(corazatypes.RxBudgetExceeded).(*collections.Single).Get() == "1"
+			if want, have := tt.exceeded, exceeded; want != have {
+				t.Errorf("unexpected budget exceeded, want %t, have %t", want, have)
+			}
+		})
+	}
+}
@@ -0,0 +1,46 @@
+// Copyright 2024 Juan Pablo Tosso and the OWASP Coraza contributors
+// SPDX-License-Identifier: Apache-2.0
+
+//go:build !coraza.disabled_operators.validateHash
+
+package operators
+
+import (
+	"regexp"
+
+	"github.com/ad3n/seclang/experimental/plugins/plugintypes"
+	"github.com/ad3n/seclang/internal/memoize"
+)
+
+// validateHash matches the URIs selected by the expression that don't carry
+// a valid signature of the links signed by SecHashEngine
+type validateHash struct {
+	re *regexp.Regexp
+}
+
+var _ plugintypes.Operator = (*validateHash)(nil)
+
+func newValidateHash(options plugintypes.OperatorOptions) (plugintypes.Operator, error) {
+	data := options.Arguments
+
+	re, err := memoize.Do(data, func() (interface{}, error) { return regexp.Compile(data) })
+	if err != nil {
+		return nil, err
+	}
+	return &validateHash{re: re.(*regexp.Regexp)}, nil
+}
+
+func (o *validateHash) Evaluate(tx plugintypes.TransactionState, value string) bool {
+	if !o.re.MatchString(value) {
+		return false
+	}
+	v, ok := tx.(interface{ ValidateHash(uri string) bool })
+	if !ok {
+		return false
+	}
+	return !v.ValidateHash(value)
+}
+
+func init() {
+	Register("validateHash", newValidateHash)
+}
@@ -0,0 +1,46 @@
+// Copyright 2024 Juan Pablo Tosso and the OWASP Coraza contributors
+// SPDX-License-Identifier: Apache-2.0
+
+//go:build !coraza.disabled_operators.validateHash
+
+package operators
+
+import (
+	"testing"
+
+	"github.com/ad3n/seclang/experimental/plugins/plugintypes"
+	"github.com/ad3n/seclang/internal/corazawaf"
+)
+
+func TestValidateHash(t *testing.T) {
+	op, err := newValidateHash(plugintypes.OperatorOptions{Arguments: "^/account/"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	waf := corazawaf.NewWAF()
+	waf.HashEngine = true
+	waf.HashKey = []byte("secret")
+
+	tests := []struct {
+		uri     string
+		enforce bool
+		want    bool
+	}{
+		{uri: "/about", enforce: true, want: false},
+		{uri: "/account/edit", enforce: true, want: true},
+		{uri: "/account/edit?hmac=00", enforce: true, want: true},
+		{uri: "/account/edit", enforce: false, want: false},
+	}
+	for _, tt := range tests {
+		tx := waf.NewTransaction()
+		tx.HashEnforcement = tt.enforce
+		if want, have := tt.want, op.Evaluate(tx, tt.uri); want != have {
+			t.Errorf("unexpected result for %q (enforcement %t), want %t, have %t", tt.uri, tt.enforce, want, have)
+		}
+		tx.Close()
+	}
+
+	if _, err := newValidateHash(plugintypes.OperatorOptions{Arguments: "(^/account/"}); err == nil {
+		t.Error("expected error for invalid expression")
+	}
+}
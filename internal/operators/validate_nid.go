@@ -50,13 +50,11 @@ func newValidateNID(options plugintypes.OperatorOptions) (plugintypes.Operator,
 }
 
 func (o *validateNid) Evaluate(tx plugintypes.TransactionState, value string) bool {
-	matches := o.re.FindAllStringSubmatch(value, 11)
+	limit := captureLimit(tx)
+	matches := o.re.FindAllStringSubmatch(value, limit)
 
 	res := false
 	for i, m := range matches {
-		if i >= 10 {
-			break
-		}
 		// should we capture more than one NID?
 		if o.fn(m[0]) {
 			res = true
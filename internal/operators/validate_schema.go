@@ -0,0 +1,127 @@
+// Copyright 2024 Juan Pablo Tosso and the OWASP Coraza contributors
+// SPDX-License-Identifier: Apache-2.0
+
+//go:build !coraza.disabled_operators.validateSchema
+
+package operators
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/ad3n/seclang/experimental/plugins/plugintypes"
+	"github.com/ad3n/seclang/internal/jsonschema"
+	"github.com/ad3n/seclang/internal/memoize"
+	"github.com/ad3n/seclang/internal/xmlschema"
+	"github.com/corazawaf/coraza/v3/collection"
+	"github.com/corazawaf/coraza/v3/types/variables"
+)
+
+// schemaErrorKey is the TX key holding the first validation error
+const schemaErrorKey = "schema_error"
+
+// validateSchema matches the JSON documents that are not valid against the
+// JSON Schema of a file, e.g. the request body of an API. With a .xsd file it
+// validates the request body parsed by the XML body processor against the XML
+// Schema instead, like ModSecurity does, whatever the value of the variable,
+// e.g. SecRule XML "@validateSchema soap.xsd".
+type validateSchema struct {
+	schema *jsonschema.Schema
+	xsd    *xmlschema.Schema
+}
+
+// requestXMLDocumentReader is implemented by the transaction variables keeping
+// the request body decoded by the XML body processor
+type requestXMLDocumentReader interface {
+	RequestXMLDocument() *xmlschema.Document
+}
+
+// xsdResult is the result of the validation of the request body against an
+// XML Schema, kept in the transaction as the XML variable has many values
+type xsdResult struct {
+	err error
+}
+
+var _ plugintypes.Operator = (*validateSchema)(nil)
+
+func newValidateSchema(options plugintypes.OperatorOptions) (plugintypes.Operator, error) {
+	path := options.Arguments
+
+	data, err := loadFromFile(path, options.Path, options.Root)
+	if err != nil {
+		return nil, err
+	}
+	// the schemas are shared by content, the same path can be a different
+	// file in another root
+	sum := sha256.Sum256(data)
+	key := hex.EncodeToString(sum[:])
+	if strings.EqualFold(filepath.Ext(path), ".xsd") {
+		xsd, err := memoize.Do("xsd:"+key, func() (interface{}, error) {
+			return xmlschema.Compile(data)
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to compile schema %s: %s", path, err.Error())
+		}
+		return &validateSchema{xsd: xsd.(*xmlschema.Schema)}, nil
+	}
+	schema, err := memoize.Do("jsonschema:"+key, func() (interface{}, error) {
+		return jsonschema.Compile(data)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to compile schema %s: %s", path, err.Error())
+	}
+	return &validateSchema{schema: schema.(*jsonschema.Schema)}, nil
+}
+
+// Evaluate returns true if the value is not a valid document, the first error
+// is stored in TX:schema_error. Empty values, like the body of a request
+// without body, are not validated.
+func (o *validateSchema) Evaluate(tx plugintypes.TransactionState, value string) bool {
+	if o.xsd != nil {
+		return o.evaluateXML(tx)
+	}
+	if value == "" {
+		return false
+	}
+	err := o.schema.ValidateJSON([]byte(value))
+	if err == nil {
+		return false
+	}
+	tx.Variables().TX().Set(schemaErrorKey, []string{err.Error()})
+	return true
+}
+
+// evaluateXML returns true if the request body decoded by the XML body
+// processor is not a valid document, it returns false if the body wasn't
+// processed as XML.
+func (o *validateSchema) evaluateXML(tx plugintypes.TransactionState) bool {
+	processor, ok := tx.Collection(variables.ReqbodyProcessor).(collection.Single)
+	if !ok || !strings.EqualFold(processor.Get(), "XML") {
+		return false
+	}
+	reader, ok := tx.Variables().(requestXMLDocumentReader)
+	if !ok || reader.RequestXMLDocument() == nil {
+		return false
+	}
+
+	var res xsdResult
+	if v, ok := operatorValue(tx, o.xsd); ok {
+		res = v.(xsdResult)
+	} else {
+		res.err = o.xsd.ValidateDocument(reader.RequestXMLDocument())
+		setOperatorValue(tx, o.xsd, res)
+	}
+	err := res.err
+	if err == nil {
+		return false
+	}
+	tx.Variables().TX().Set(schemaErrorKey, []string{err.Error()})
+	return true
+}
+
+func init() {
+	Register("validateSchema", newValidateSchema)
+}
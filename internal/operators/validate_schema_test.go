@@ -0,0 +1,175 @@
+// Copyright 2024 Juan Pablo Tosso and the OWASP Coraza contributors
+// SPDX-License-Identifier: Apache-2.0
+
+//go:build !coraza.disabled_operators.validateSchema
+
+package operators
+
+import (
+	"testing"
+	"testing/fstest"
+
+	"github.com/ad3n/seclang/experimental/plugins/plugintypes"
+	"github.com/ad3n/seclang/internal/collections"
+	"github.com/ad3n/seclang/internal/corazawaf"
+	"github.com/corazawaf/coraza/v3/types/variables"
+)
+
+func TestValidateSchema(t *testing.T) {
+	root := fstest.MapFS{
+		"schemas/order.json": {Data: []byte(`{"type": "object", "required": ["id"], "properties": {"id": {"type": "integer"}}}`)},
+		"schemas/bad.json":   {Data: []byte(`{"type": "object", "pattern": "("}`)},
+	}
+	op, err := newValidateSchema(plugintypes.OperatorOptions{
+		Arguments: "order.json",
+		Path:      []string{"schemas"},
+		Root:      root,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		body      string
+		want      bool
+		wantError string
+	}{
+		{body: "", want: false},
+		{body: `{"id": 1}`, want: false},
+		{body: `{"id": "1"}`, want: true, wantError: "/id: expected integer, have string"},
+		{body: `{}`, want: true, wantError: `missing required property "id"`},
+		{body: `{"id":`, want: true, wantError: "invalid JSON: unexpected end of JSON input"},
+	}
+	waf := corazawaf.NewWAF()
+	for _, tt := range tests {
+		tx := waf.NewTransaction()
+		if want, have := tt.want, op.Evaluate(tx, tt.body); want != have {
+			t.Errorf("unexpected result for %q, want %t, have %t", tt.body, want, have)
+		}
+		have := tx.Variables().TX().Get(schemaErrorKey)
+		if tt.wantError == "" && len(have) != 0 {
+			t.Errorf("unexpected error for %q, have %q", tt.body, have)
+		}
+		if tt.wantError != "" && (len(have) != 1 || have[0] != tt.wantError) {
+			t.Errorf("unexpected error for %q, want %q, have %q", tt.body, tt.wantError, have)
+		}
+		tx.Close()
+	}
+
+	for _, file := range []string{"missing.json", "bad.json"} {
+		_, err := newValidateSchema(plugintypes.OperatorOptions{Arguments: file, Path: []string{"schemas"}, Root: root})
+		if err == nil {
+			t.Errorf("expected error loading %s", file)
+		}
+	}
+}
+
+func TestValidateSchemaXSD(t *testing.T) {
+	root := fstest.MapFS{
+		"schemas/order.xsd": {Data: []byte(`<xs:schema xmlns:xs="http://www.w3.org/2001/XMLSchema">
+			<xs:element name="order">
+				<xs:complexType>
+					<xs:sequence>
+						<xs:element name="id" type="xs:int"/>
+					</xs:sequence>
+				</xs:complexType>
+			</xs:element>
+		</xs:schema>`)},
+		"schemas/bad.xsd": {Data: []byte(`<xs:schema xmlns:xs="http://www.w3.org/2001/XMLSchema"><xs:element name="a" type="Missing"/></xs:schema>`)},
+	}
+	op, err := newValidateSchema(plugintypes.OperatorOptions{
+		Arguments: "order.xsd",
+		Path:      []string{"schemas"},
+		Root:      root,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		processor string
+		body      string
+		want      bool
+		wantError string
+	}{
+		{processor: "XML", body: `<order><id>1</id></order>`, want: false},
+		{processor: "XML", body: `<order><id>x</id></order>`, want: true, wantError: `/order/id: value "x" is not a valid integer`},
+		{processor: "XML", body: `<order><id>1</id>`, want: true, wantError: "invalid XML: XML syntax error on line 1: unexpected EOF"},
+		{processor: "JSON", body: `<order><id>x</id></order>`, want: false},
+	}
+	waf := corazawaf.NewWAF()
+	for _, tt := range tests {
+		tx := waf.NewTransaction()
+		processRequestBody(t, tx, tt.processor, tt.body)
+		// the XML variable has a value for each attribute and text
+		for _, value := range []string{"1", "x"} {
+			if want, have := tt.want, op.Evaluate(tx, value); want != have {
+				t.Errorf("unexpected result for %q, want %t, have %t", tt.body, want, have)
+			}
+		}
+		have := tx.Variables().TX().Get(schemaErrorKey)
+		if tt.wantError == "" && len(have) != 0 {
+			t.Errorf("unexpected error for %q, have %q", tt.body, have)
+		}
+		if tt.wantError != "" && (len(have) != 1 || have[0] != tt.wantError) {
+			t.Errorf("unexpected error for %q, want %q, have %q", tt.body, tt.wantError, have)
+		}
+		tx.Close()
+	}
+
+	if _, err := newValidateSchema(plugintypes.OperatorOptions{Arguments: "bad.xsd", Path: []string{"schemas"}, Root: root}); err == nil {
+		t.Error("expected error loading bad.xsd")
+	}
+}
+
+func TestValidateSchemaXSDPerTransaction(t *testing.T) {
+	schema := func(element string) *fstest.MapFile {
+		return &fstest.MapFile{Data: []byte(`<xs:schema xmlns:xs="http://www.w3.org/2001/XMLSchema">
+			<xs:element name="` + element + `" type="xs:string"/>
+		</xs:schema>`)}
+	}
+	// the same path is a different schema in another root
+	order, err := newValidateSchema(plugintypes.OperatorOptions{Arguments: "s.xsd", Path: []string{"."}, Root: fstest.MapFS{"s.xsd": schema("order")}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	invoice, err := newValidateSchema(plugintypes.OperatorOptions{Arguments: "s.xsd", Path: []string{"."}, Root: fstest.MapFS{"s.xsd": schema("invoice")}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	waf := corazawaf.NewWAF()
+	evaluate := func(op plugintypes.Operator, body string) bool {
+		t.Helper()
+		// the transactions reuse the same ID, which is set by the client
+		tx := waf.NewTransactionWithOptions(corazawaf.Options{ID: "same"})
+		defer tx.Close()
+		processRequestBody(t, tx, "XML", body)
+		return op.Evaluate(tx, "")
+	}
+	if evaluate(order, "<order>a</order>") {
+		t.Error("unexpected invalid order")
+	}
+	if !evaluate(order, "<invoice>a</invoice>") {
+		t.Error("expected invalid order in a transaction with the same ID")
+	}
+	if !evaluate(invoice, "<order>a</order>") {
+		t.Error("expected invalid invoice with the schema of another root")
+	}
+}
+
+// processRequestBody processes the body of a POST request with the body
+// processor
+func processRequestBody(t *testing.T, tx *corazawaf.Transaction, processor string, body string) {
+	t.Helper()
+	tx.RequestBodyAccess = true
+	tx.ProcessURI("/", "POST", "HTTP/1.1")
+	tx.Collection(variables.ReqbodyProcessor).(*collections.Single).Set(processor)
+	tx.ProcessRequestHeaders()
+	if _, _, err := tx.WriteRequestBody([]byte(body)); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := tx.ProcessRequestBody(); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,198 @@
+// Copyright 2024 Juan Pablo Tosso and the OWASP Coraza contributors
+// SPDX-License-Identifier: Apache-2.0
+
+//go:build !coraza.disabled_operators.verifyCC
+
+package operators
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/ad3n/seclang/experimental/plugins/plugintypes"
+	"github.com/ad3n/seclang/internal/memoize"
+)
+
+// ccNetworkKey is the TX key of the network of the last card number
+// @verifyCC matched
+const ccNetworkKey = "cc_network"
+
+// ccNetwork is a card network, identified by the ranges of the first digits
+// of its card numbers
+type ccNetwork struct {
+	name string
+	// ranges are the inclusive ranges of the prefixes, both bounds have the
+	// number of digits of the prefix
+	ranges    [][2]int
+	minLength int
+	maxLength int
+}
+
+// ccNetworks are the networks in the order they are identified, the more
+// specific ranges of a network come before the broader ranges of another one
+var ccNetworks = []ccNetwork{
+	{name: "amex", ranges: [][2]int{{34, 34}, {37, 37}}, minLength: 15, maxLength: 15},
+	{name: "diners", ranges: [][2]int{{300, 305}, {3095, 3095}, {36, 36}, {38, 39}}, minLength: 14, maxLength: 19},
+	{name: "jcb", ranges: [][2]int{{3528, 3589}, {3088, 3094}, {3096, 3102}, {3112, 3120}, {3158, 3159}, {3337, 3349}}, minLength: 16, maxLength: 19},
+	{name: "jcb", ranges: [][2]int{{1800, 1800}, {2131, 2131}}, minLength: 15, maxLength: 15},
+	{name: "enroute", ranges: [][2]int{{2014, 2014}, {2149, 2149}}, minLength: 15, maxLength: 15},
+	{name: "mir", ranges: [][2]int{{2200, 2204}}, minLength: 16, maxLength: 19},
+	{name: "mastercard", ranges: [][2]int{{51, 55}, {2221, 2720}}, minLength: 16, maxLength: 16},
+	{name: "visa", ranges: [][2]int{{4, 4}}, minLength: 13, maxLength: 19},
+	{name: "discover", ranges: [][2]int{{6011, 6011}, {622126, 622925}, {644, 649}, {65, 65}}, minLength: 16, maxLength: 19},
+	{name: "unionpay", ranges: [][2]int{{62, 62}, {81, 81}}, minLength: 16, maxLength: 19},
+	{name: "maestro", ranges: [][2]int{{50, 50}, {56, 58}, {6, 6}}, minLength: 12, maxLength: 19},
+	{name: "voyager", ranges: [][2]int{{8699, 8699}}, minLength: 15, maxLength: 15},
+}
+
+// ccUnknownNetwork is the network of the valid card numbers not matching
+// any network
+const ccUnknownNetwork = "unknown"
+
+// verifyCC matches the values containing a card number, a match of the
+// regular expression of the argument, or of its first group, whose digits
+// pass the Luhn checksum. The card number, masked to its first six and last
+// four digits, is captured in TX:0 and its network, e.g. visa or mastercard,
+// in TX:1 and set in TX:cc_network. The card numbers are masked in
+// MATCHED_VAR too, so they are not written to the logs, e.g.
+// SecRule ARGS "@verifyCC (?:^|[^\d])(\d{4}-?\d{4}-?\d{2}-?\d{2}-?\d{1,4})(?:[^\d]|$)" "id:1,phase:2,deny,logdata:'%{TX.cc_network} %{MATCHED_VAR}'"
+type verifyCC struct {
+	re *regexp.Regexp
+}
+
+var (
+	_ plugintypes.Operator        = (*verifyCC)(nil)
+	_ plugintypes.MaskingOperator = (*verifyCC)(nil)
+)
+
+func newVerifyCC(options plugintypes.OperatorOptions) (plugintypes.Operator, error) {
+	expr := options.Arguments
+	re, err := memoize.Do(expr, func() (interface{}, error) { return regexp.Compile(expr) })
+	if err != nil {
+		return nil, err
+	}
+	return &verifyCC{re: re.(*regexp.Regexp)}, nil
+}
+
+func (o *verifyCC) Evaluate(tx plugintypes.TransactionState, value string) bool {
+	start, end, network := o.find(value)
+	if network == "" {
+		return false
+	}
+	if tx.Capturing() {
+		tx.CaptureField(0, maskCardNumber(value[start:end]))
+		tx.CaptureField(1, network)
+	}
+	tx.Variables().TX().Set(ccNetworkKey, []string{network})
+	return true
+}
+
+// Mask masks the card numbers of the value
+func (o *verifyCC) Mask(value string) string {
+	var sb strings.Builder
+	last := 0
+	for {
+		start, end, network := o.find(value[last:])
+		if network == "" {
+			break
+		}
+		sb.WriteString(value[last : last+start])
+		sb.WriteString(maskCardNumber(value[last+start : last+end]))
+		last += end
+	}
+	if last == 0 {
+		return value
+	}
+	sb.WriteString(value[last:])
+	return sb.String()
+}
+
+// find returns the bounds and the network of the first valid card number of
+// the value, an empty network if there is none
+func (o *verifyCC) find(value string) (int, int, string) {
+	for _, m := range o.re.FindAllStringSubmatchIndex(value, -1) {
+		start, end := m[0], m[1]
+		if len(m) > 3 && m[2] >= 0 {
+			start, end = m[2], m[3]
+		}
+		if start == end {
+			continue
+		}
+		if network := cardNetwork(cardDigits(value[start:end])); network != "" {
+			return start, end, network
+		}
+	}
+	return 0, 0, ""
+}
+
+func cardDigits(s string) string {
+	var sb strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] >= '0' && s[i] <= '9' {
+			sb.WriteByte(s[i])
+		}
+	}
+	return sb.String()
+}
+
+// cardNetwork returns the network of the card number, unknown if it passes
+// the Luhn checksum without matching a network, and empty if it is invalid
+func cardNetwork(digits string) string {
+	if len(digits) < 12 || len(digits) > 19 || !luhn(digits) {
+		return ""
+	}
+	for _, n := range ccNetworks {
+		if len(digits) < n.minLength || len(digits) > n.maxLength {
+			continue
+		}
+		for _, r := range n.ranges {
+			l := len(strconv.Itoa(r[0]))
+			// the length is checked above, the prefix is made of digits
+			prefix, _ := strconv.Atoi(digits[:l])
+			if prefix >= r[0] && prefix <= r[1] {
+				return n.name
+			}
+		}
+	}
+	return ccUnknownNetwork
+}
+
+func luhn(digits string) bool {
+	sum := 0
+	double := false
+	for i := len(digits) - 1; i >= 0; i-- {
+		d := int(digits[i] - '0')
+		if double {
+			d *= 2
+			if d > 9 {
+				d -= 9
+			}
+		}
+		sum += d
+		double = !double
+	}
+	return sum%10 == 0
+}
+
+// maskCardNumber replaces the digits of the card number but the first six
+// and the last four with asterisks, keeping the separators
+func maskCardNumber(s string) string {
+	digits := len(cardDigits(s))
+	b := []byte(s)
+	n := 0
+	for i, c := range b {
+		if c < '0' || c > '9' {
+			continue
+		}
+		if n >= 6 && n < digits-4 {
+			b[i] = '*'
+		}
+		n++
+	}
+	return string(b)
+}
+
+func init() {
+	Register("verifyCC", newVerifyCC)
+}
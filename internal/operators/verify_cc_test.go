@@ -0,0 +1,103 @@
+// Copyright 2024 Juan Pablo Tosso and the OWASP Coraza contributors
+// SPDX-License-Identifier: Apache-2.0
+
+//go:build !coraza.disabled_operators.verifyCC
+
+package operators
+
+import (
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/ad3n/seclang/experimental/plugins/plugintypes"
+	"github.com/ad3n/seclang/internal/corazawaf"
+)
+
+func TestVerifyCCTestdata(t *testing.T) {
+	data, err := os.ReadFile("testdata/verifyCC.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	waf := corazawaf.NewWAF()
+	for _, tt := range unmarshalTests(t, data) {
+		op, err := newVerifyCC(plugintypes.OperatorOptions{Arguments: tt.Param})
+		if err != nil {
+			t.Fatal(err)
+		}
+		tx := waf.NewTransaction()
+		if want, have := tt.Ret == 1, op.Evaluate(tx, tt.Input); want != have {
+			t.Errorf("unexpected result of @verifyCC(%q, %q), want %t, have %t", tt.Param, tt.Input, want, have)
+		}
+		tx.Close()
+	}
+}
+
+func TestVerifyCCNetworks(t *testing.T) {
+	tests := []struct {
+		value   string
+		network string
+		masked  string
+	}{
+		{"4916545704601136", "visa", "491654******1136"},
+		{"4556324125126", "visa", "455632***5126"},
+		{"5484-6050-8915-8216", "mastercard", "5484-60**-****-8216"},
+		{"2221000000000009", "mastercard", "222100******0009"},
+		{"343918934573386", "amex", "343918*****3386"},
+		{"6011402777433576", "discover", "601140******3576"},
+		{"6221260000000000", "discover", "622126******0000"},
+		{"6200000000000005", "unionpay", "620000******0005"},
+		{"30162519308318", "diners", "301625****8318"},
+		{"36850112043985", "diners", "368501****3985"},
+		{"3530111333300000", "jcb", "353011******0000"},
+		{"3096676276259096", "jcb", "309667******9096"},
+		{"180013970064072", "jcb", "180013*****4072"},
+		{"2200000000000004", "mir", "220000******0004"},
+		{"201427829075664", "enroute", "201427*****5664"},
+		{"869974262335041", "voyager", "869974*****5041"},
+		{"6759649826438453", "maestro", "675964******8453"},
+		{"9000000000000001", "unknown", "900000******0001"},
+		{"1234567890012345", "", ""},
+	}
+	op, err := newVerifyCC(plugintypes.OperatorOptions{Arguments: `(\d[\d-]{10,21}\d)`})
+	if err != nil {
+		t.Fatal(err)
+	}
+	waf := corazawaf.NewWAF()
+	for _, tt := range tests {
+		tx := waf.NewTransaction()
+		tx.Capture = true
+		if want, have := tt.network != "", op.Evaluate(tx, tt.value); want != have {
+			t.Errorf("unexpected result for %q, want %t, have %t", tt.value, want, have)
+		}
+		if want, have := tt.network, strings.Join(tx.Variables().TX().Get(ccNetworkKey), ","); want != have {
+			t.Errorf("unexpected network of %q, want %q, have %q", tt.value, want, have)
+		}
+		if want, have := tt.network, strings.Join(tx.Variables().TX().Get("1"), ","); want != have {
+			t.Errorf("unexpected captured network of %q, want %q, have %q", tt.value, want, have)
+		}
+		if want, have := tt.masked, strings.Join(tx.Variables().TX().Get("0"), ","); want != have {
+			t.Errorf("unexpected captured card number of %q, want %q, have %q", tt.value, want, have)
+		}
+		tx.Close()
+	}
+}
+
+func TestVerifyCCMask(t *testing.T) {
+	op, err := newVerifyCC(plugintypes.OperatorOptions{Arguments: `(?:^|[^\d])(\d{4}-?\d{4}-?\d{2}-?\d{2}-?\d{1,4})(?:[^\d]|$)`})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := map[string]string{
+		"no card number":                         "no card number",
+		"invalid 5484605089158217":               "invalid 5484605089158217",
+		"card 5484605089158216":                  "card 548460******8216",
+		"4916545704601136,343918934573386,12345": "491654******1136,343918*****3386,12345",
+		"4916545704601136 and 4539501231827691":  "491654******1136 and 453950******7691",
+	}
+	for value, want := range tests {
+		if have := op.(plugintypes.MaskingOperator).Mask(value); want != have {
+			t.Errorf("unexpected mask of %q, want %q, have %q", value, want, have)
+		}
+	}
+}
@@ -0,0 +1,70 @@
+// Copyright 2024 Juan Pablo Tosso and the OWASP Coraza contributors
+// SPDX-License-Identifier: Apache-2.0
+
+//go:build !coraza.disabled_operators.xpath
+
+package operators
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/ad3n/seclang/experimental/plugins/plugintypes"
+	"github.com/ad3n/seclang/internal/xpath"
+)
+
+// xpathOp evaluates an XPath 1.0 expression against the request body
+// processed as XML, whatever the value of the variable, and matches if the
+// result converted to a boolean is true: a non empty node-set or string or a
+// number other than 0, e.g. a comparison. The argument starts with the
+// xmlns:prefix=namespace bindings of the prefixes of the expression, e.g.
+// SecRule REQBODY_PROCESSOR "@xpath xmlns:m=urn:bank //m:Transfer/m:Amount[. > 1000]"
+// The result converted to a string, the string-value of the first node of a
+// node-set, is captured in TX:0.
+type xpathOp struct {
+	expr *xpath.Expr
+	body *bodyDocument
+}
+
+var _ plugintypes.Operator = (*xpathOp)(nil)
+
+func newXPath(options plugintypes.OperatorOptions) (plugintypes.Operator, error) {
+	args := strings.TrimSpace(options.Arguments)
+	namespaces := map[string]string{}
+	for strings.HasPrefix(args, "xmlns:") {
+		binding, rest, _ := strings.Cut(args, " ")
+		prefix, namespace, ok := strings.Cut(strings.TrimPrefix(binding, "xmlns:"), "=")
+		if !ok || prefix == "" || namespace == "" {
+			return nil, fmt.Errorf("invalid @xpath namespace binding %q, expected xmlns:prefix=namespace", binding)
+		}
+		namespaces[prefix] = namespace
+		args = strings.TrimSpace(rest)
+	}
+	expr, err := xpath.Compile(args, namespaces)
+	if err != nil {
+		return nil, err
+	}
+	return &xpathOp{
+		expr: expr,
+		body: &bodyDocument{processor: "XML", decode: func(data []byte) (any, error) { return xpath.Parse(data) }},
+	}, nil
+}
+
+func (o *xpathOp) Evaluate(tx plugintypes.TransactionState, _ string) bool {
+	doc, ok := o.body.get(tx)
+	if !ok {
+		return false
+	}
+	res := o.expr.Evaluate(doc.(*xpath.Document))
+	if !res.Bool() {
+		return false
+	}
+	if tx.Capturing() {
+		tx.CaptureField(0, res.String())
+	}
+	return true
+}
+
+func init() {
+	Register("xpath", newXPath)
+}
@@ -0,0 +1,69 @@
+// Copyright 2024 Juan Pablo Tosso and the OWASP Coraza contributors
+// SPDX-License-Identifier: Apache-2.0
+
+//go:build !coraza.disabled_operators.xpath
+
+package operators
+
+import (
+	"testing"
+
+	"github.com/ad3n/seclang/experimental/plugins/plugintypes"
+	"github.com/ad3n/seclang/internal/collections"
+	"github.com/ad3n/seclang/internal/corazawaf"
+	"github.com/corazawaf/coraza/v3/types/variables"
+)
+
+func TestXPath(t *testing.T) {
+	const body = `<s:Envelope xmlns:s="http://schemas.xmlsoap.org/soap/envelope/"><s:Body>
+		<Transfer xmlns="urn:bank"><Amount>1500</Amount><To>DE89</To></Transfer>
+	</s:Body></s:Envelope>`
+	const ns = "xmlns:s=http://schemas.xmlsoap.org/soap/envelope/ xmlns:b=urn:bank "
+	tests := []struct {
+		args      string
+		processor string
+		body      string
+		want      bool
+		capture   string
+	}{
+		{args: ns + "/s:Envelope/s:Body/b:Transfer/b:Amount", processor: "XML", body: body, want: true, capture: "1500"},
+		{args: ns + "//b:Transfer[b:Amount > 1000]/b:To", processor: "XML", body: body, want: true, capture: "DE89"},
+		{args: ns + "//b:Amount > 5000", processor: "XML", body: body, want: false},
+		{args: ns + "count(//b:Transfer)", processor: "XML", body: body, want: true, capture: "1"},
+		{args: "//Transfer", processor: "XML", body: body, want: false},
+		{args: ns + "//b:Amount", processor: "JSON", body: body, want: false},
+		{args: ns + "//b:Amount", processor: "XML", body: `<Transfer>`, want: false},
+	}
+	waf := corazawaf.NewWAF()
+	for _, tt := range tests {
+		op, err := newXPath(plugintypes.OperatorOptions{Arguments: tt.args})
+		if err != nil {
+			t.Fatal(err)
+		}
+		tx := waf.NewTransaction()
+		tx.Capture = true
+		tx.RequestBodyAccess = true
+		if _, _, err := tx.WriteRequestBody([]byte(tt.body)); err != nil {
+			t.Fatal(err)
+		}
+		tx.Collection(variables.ReqbodyProcessor).(*collections.Single).Set(tt.processor)
+		// the body is parsed once per transaction
+		for i := 0; i < 2; i++ {
+			if want, have := tt.want, op.Evaluate(tx, ""); want != have {
+				t.Errorf("unexpected result of %q, want %t, have %t", tt.args, want, have)
+			}
+		}
+		if tt.capture != "" {
+			if have := tx.Variables().TX().Get("0"); len(have) != 1 || have[0] != tt.capture {
+				t.Errorf("unexpected capture of %q, want %q, have %q", tt.args, tt.capture, have)
+			}
+		}
+		tx.Close()
+	}
+
+	for _, args := range []string{"", "xmlns:b //b:a", "xmlns:=urn:bank //a", "//b:a", "//a["} {
+		if _, err := newXPath(plugintypes.OperatorOptions{Arguments: args}); err == nil {
+			t.Errorf("expected error for %q", args)
+		}
+	}
+}
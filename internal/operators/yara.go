@@ -0,0 +1,271 @@
+// Copyright 2024 Juan Pablo Tosso and the OWASP Coraza contributors
+// SPDX-License-Identifier: Apache-2.0
+
+//go:build !coraza.disabled_operators.yara && coraza.operators.yara
+
+package operators
+
+/*
+#cgo pkg-config: yara
+#include <stdio.h>
+#include <stdlib.h>
+#include <string.h>
+#include <yara.h>
+
+// yara_matches holds the identifiers of the matched rules, each one followed
+// by a new line
+typedef struct {
+	char *names;
+	size_t len;
+} yara_matches;
+
+static int yara_collect(YR_SCAN_CONTEXT *context, int message, void *message_data, void *user_data) {
+	if (message != CALLBACK_MSG_RULE_MATCHING) {
+		return CALLBACK_CONTINUE;
+	}
+	yara_matches *m = user_data;
+	const char *name = ((YR_RULE *)message_data)->identifier;
+	size_t n = strlen(name);
+	char *names = realloc(m->names, m->len + n + 1);
+	if (names == NULL) {
+		return CALLBACK_ERROR;
+	}
+	memcpy(names + m->len, name, n);
+	names[m->len + n] = '\n';
+	m->names = names;
+	m->len += n + 1;
+	return CALLBACK_CONTINUE;
+}
+
+static int yara_scan_mem(YR_RULES *rules, const uint8_t *data, size_t len, int timeout, yara_matches *m) {
+	return yr_rules_scan_mem(rules, data, len, 0, yara_collect, m, timeout);
+}
+
+static int yara_scan_file(YR_RULES *rules, const char *path, int timeout, yara_matches *m) {
+	return yr_rules_scan_file(rules, path, 0, yara_collect, m, timeout);
+}
+
+typedef struct {
+	const uint8_t *data;
+	size_t len;
+	size_t off;
+} yara_buffer;
+
+static size_t yara_buffer_read(void *ptr, size_t size, size_t count, void *user_data) {
+	yara_buffer *b = user_data;
+	if (size == 0) {
+		return 0;
+	}
+	size_t n = (b->len - b->off) / size;
+	if (n > count) {
+		n = count;
+	}
+	memcpy(ptr, b->data + b->off, n * size);
+	b->off += n * size;
+	return n;
+}
+
+// yara_load loads compiled rules from memory
+static int yara_load(const uint8_t *data, size_t len, YR_RULES **rules) {
+	yara_buffer b = {data, len, 0};
+	YR_STREAM s = {0};
+	s.user_data = &b;
+	s.read = yara_buffer_read;
+	return yr_rules_load_stream(&s, rules);
+}
+
+static void yara_compiler_error(int level, const char *file_name, int line_number, const YR_RULE *rule, const char *message, void *user_data) {
+	char *error = user_data;
+	if (level == YARA_ERROR_LEVEL_ERROR && error[0] == 0) {
+		snprintf(error, 256, "line %d: %s", line_number, message);
+	}
+}
+
+// yara_compile compiles the rules of the source, error is set to the first
+// compilation error and must hold 256 characters
+static int yara_compile(const char *source, char *error, YR_RULES **rules) {
+	YR_COMPILER *compiler;
+	int rc = yr_compiler_create(&compiler);
+	if (rc != ERROR_SUCCESS) {
+		return rc;
+	}
+	yr_compiler_set_callback(compiler, yara_compiler_error, error);
+	if (yr_compiler_add_string(compiler, source, NULL) > 0) {
+		yr_compiler_destroy(compiler);
+		return ERROR_INVALID_ARGUMENT;
+	}
+	rc = yr_compiler_get_rules(compiler, rules);
+	yr_compiler_destroy(compiler);
+	return rc;
+}
+*/
+import "C"
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"math"
+	"runtime"
+	"strings"
+	"sync"
+	"time"
+	"unsafe"
+
+	"github.com/ad3n/seclang/experimental/plugins/plugintypes"
+	"github.com/ad3n/seclang/internal/collections"
+	"github.com/ad3n/seclang/internal/corazatypes"
+)
+
+const defaultYaraTimeout = 10 * time.Second
+
+// yaraMagic starts the files of compiled rules
+var yaraMagic = []byte("YARA")
+
+var (
+	yaraInit    sync.Once
+	yaraInitErr error
+)
+
+// yara scans the values with YARA rules, e.g. the request body or, with the
+// files option, the uploaded files FILES_TMPNAMES holds the paths of. The
+// names of the matched rules are added to YARA_MATCHES keyed by name, the
+// first one is captured in TX:0. The failed and timed out scans are dependency failures.
+type yara struct {
+	rules   *C.YR_RULES
+	files   bool
+	timeout C.int
+}
+
+var _ plugintypes.Operator = (*yara)(nil)
+
+// newYara loads the rules of a file, either compiled with yarac or their
+// source, or the source rules of a list served over https, see
+// newOperatorFromFile for the refresh and fail options. The compiled rules
+// aren't loaded from remote lists, libyara doesn't validate them. It is only available when built with the
+// coraza.operators.yara tag, which requires cgo and libyara 4:
+//
+//	@yara malware.yarac files timeout=5s refresh=1h
+func newYara(options plugintypes.OperatorOptions) (plugintypes.Operator, error) {
+	yaraInit.Do(func() {
+		if rc := C.yr_initialize(); rc != C.ERROR_SUCCESS {
+			yaraInitErr = fmt.Errorf("cannot initialize YARA, error %d", int(rc))
+		}
+	})
+	if yaraInitErr != nil {
+		return nil, yaraInitErr
+	}
+
+	fields := strings.Fields(options.Arguments)
+	if len(fields) == 0 {
+		return nil, errors.New("missing the yara rules")
+	}
+	var (
+		files   bool
+		timeout = defaultYaraTimeout
+		rest    []string
+	)
+	for _, field := range fields[1:] {
+		key, value, _ := strings.Cut(field, "=")
+		switch key {
+		case "files":
+			files = true
+		case "timeout":
+			d, err := time.ParseDuration(value)
+			if err != nil || d <= 0 {
+				return nil, fmt.Errorf("invalid yara timeout %q", value)
+			}
+			timeout = d
+		default:
+			rest = append(rest, field)
+		}
+	}
+	// YARA counts the timeouts in seconds
+	seconds := C.int(math.Ceil(timeout.Seconds()))
+	remote := strings.HasPrefix(fields[0], "https://")
+	options.Arguments = strings.Join(append(fields[:1], rest...), " ")
+	return newOperatorFromFile("yara", options, func(data []byte) (plugintypes.Operator, error) {
+		rules, err := loadYaraRules(data, !remote)
+		if err != nil {
+			return nil, err
+		}
+		o := &yara{rules: rules, files: files, timeout: seconds}
+		runtime.SetFinalizer(o, (*yara).free)
+		return o, nil
+	})
+}
+
+// loadYaraRules compiles the source rules of data, or loads them if they
+// are compiled and compiled is true
+func loadYaraRules(data []byte, compiled bool) (*C.YR_RULES, error) {
+	var rules *C.YR_RULES
+	if bytes.HasPrefix(data, yaraMagic) {
+		if !compiled {
+			return nil, errors.New("compiled yara rules can't be loaded from a remote list, serve their source")
+		}
+		if rc := C.yara_load((*C.uint8_t)(unsafe.Pointer(&data[0])), C.size_t(len(data)), &rules); rc != C.ERROR_SUCCESS {
+			return nil, fmt.Errorf("invalid compiled yara rules, error %d", int(rc))
+		}
+		return rules, nil
+	}
+
+	source := C.CString(string(data))
+	defer C.free(unsafe.Pointer(source))
+	var msg [256]C.char
+	if rc := C.yara_compile(source, &msg[0], &rules); rc != C.ERROR_SUCCESS {
+		if msg[0] != 0 {
+			return nil, fmt.Errorf("invalid yara rules, %s", C.GoString(&msg[0]))
+		}
+		return nil, fmt.Errorf("invalid yara rules, error %d", int(rc))
+	}
+	return rules, nil
+}
+
+func (o *yara) Evaluate(tx plugintypes.TransactionState, value string) bool {
+	if len(value) == 0 {
+		return false
+	}
+	var (
+		m  C.yara_matches
+		rc C.int
+	)
+	if o.files {
+		path := C.CString(value)
+		rc = C.yara_scan_file(o.rules, path, o.timeout, &m)
+		C.free(unsafe.Pointer(path))
+	} else {
+		rc = C.yara_scan_mem(o.rules, (*C.uint8_t)(unsafe.Pointer(unsafe.StringData(value))), C.size_t(len(value)), o.timeout, &m)
+	}
+	runtime.KeepAlive(o)
+	runtime.KeepAlive(value)
+	defer C.free(unsafe.Pointer(m.names))
+
+	switch rc {
+	case C.ERROR_SUCCESS:
+	case C.ERROR_SCAN_TIMEOUT:
+		return tx.DependencyFailed("yara", errors.New("scan timed out"))
+	default:
+		return tx.DependencyFailed("yara", fmt.Errorf("scan failed, error %d", int(rc)))
+	}
+	if m.len == 0 {
+		return false
+	}
+	names := strings.Split(strings.TrimSuffix(C.GoStringN(m.names, C.int(m.len)), "\n"), "\n")
+	if tx.Capturing() {
+		tx.CaptureField(0, names[0])
+	}
+	if col, ok := tx.Collection(corazatypes.YaraMatches).(*collections.Map); ok {
+		for _, name := range names {
+			col.Set(name, []string{name})
+		}
+	}
+	return true
+}
+
+func (o *yara) free() {
+	C.yr_rules_destroy(o.rules)
+}
+
+func init() {
+	Register("yara", newYara)
+}
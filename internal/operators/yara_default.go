@@ -0,0 +1,22 @@
+// Copyright 2024 Juan Pablo Tosso and the OWASP Coraza contributors
+// SPDX-License-Identifier: Apache-2.0
+
+//go:build !coraza.disabled_operators.yara && !coraza.operators.yara
+
+package operators
+
+import (
+	"errors"
+
+	"github.com/ad3n/seclang/experimental/plugins/plugintypes"
+)
+
+// newYara fails unless built with the coraza.operators.yara tag, so the rules
+// using @yara are rejected instead of never matching
+func newYara(plugintypes.OperatorOptions) (plugintypes.Operator, error) {
+	return nil, errors.New("yara is not enabled, build with the coraza.operators.yara tag")
+}
+
+func init() {
+	Register("yara", newYara)
+}
@@ -0,0 +1,123 @@
+// Copyright 2024 Juan Pablo Tosso and the OWASP Coraza contributors
+// SPDX-License-Identifier: Apache-2.0
+
+//go:build !coraza.disabled_operators.yara && coraza.operators.yara
+
+package operators
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/ad3n/seclang/experimental/plugins/plugintypes"
+	"github.com/ad3n/seclang/internal/corazatypes"
+	"github.com/ad3n/seclang/internal/corazawaf"
+	"github.com/ad3n/seclang/internal/io"
+)
+
+func TestYara(t *testing.T) {
+	op, err := newYara(plugintypes.OperatorOptions{
+		Arguments: "testdata/op/eicar.yar timeout=1s",
+		Path:      []string{"."},
+		Root:      io.OSFS{},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		value   string
+		match   bool
+		matches []string
+	}{
+		{`X5O!P%@AP[4\PZX54(P^)7CC)7}$EICAR-STANDARD-ANTIVIRUS-TEST-FILE!$H+H*`, true, []string{"Eicar"}},
+		{"<?php eval($_POST['cmd']); ?>", true, []string{"WebShell"}},
+		{"EICAR-STANDARD-ANTIVIRUS-TEST-FILE eval($_GET[0])", true, []string{"Eicar", "WebShell"}},
+		{"hello", false, nil},
+		{"", false, nil},
+	}
+	waf := corazawaf.NewWAF()
+	for _, tt := range tests {
+		tx := waf.NewTransaction()
+		tx.Capture = true
+		if want, have := tt.match, op.Evaluate(tx, tt.value); want != have {
+			t.Errorf("unexpected result for %q, want %t, have %t", tt.value, want, have)
+		}
+		if want, have := tt.matches, yaraMatches(tx); !slices.Equal(want, have) {
+			t.Errorf("unexpected matches of %q, want %v, have %v", tt.value, want, have)
+		}
+		if tt.match {
+			if want, have := tt.matches[0], strings.Join(tx.Variables().TX().Get("0"), ","); want != have {
+				t.Errorf("unexpected capture of %q, want %q, have %q", tt.value, want, have)
+			}
+		}
+		tx.Close()
+	}
+}
+
+// yaraMatches returns the sorted names of YARA_MATCHES
+func yaraMatches(tx *corazawaf.Transaction) []string {
+	var names []string
+	for _, md := range tx.Collection(corazatypes.YaraMatches).FindAll() {
+		names = append(names, md.Key())
+	}
+	slices.Sort(names)
+	return names
+}
+
+func TestYaraFiles(t *testing.T) {
+	op, err := newYara(plugintypes.OperatorOptions{
+		Arguments: "testdata/op/eicar.yar files",
+		Path:      []string{"."},
+		Root:      io.OSFS{},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	upload := filepath.Join(t.TempDir(), "upload")
+	if err := os.WriteFile(upload, []byte("<?php eval($_REQUEST['c']);"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	waf := corazawaf.NewWAF()
+	tx := waf.NewTransaction()
+	defer tx.Close()
+	if !op.Evaluate(tx, upload) {
+		t.Error("expected the uploaded file to match")
+	}
+	if op.Evaluate(tx, filepath.Join(t.TempDir(), "missing")) {
+		t.Error("unexpected match of a missing file")
+	}
+	if want, have := []string{"WebShell"}, yaraMatches(tx); !slices.Equal(want, have) {
+		t.Errorf("unexpected matches, want %v, have %v", want, have)
+	}
+}
+
+func TestYaraInvalidRules(t *testing.T) {
+	tests := []struct {
+		name      string
+		arguments string
+	}{
+		{name: "missing rules", arguments: ""},
+		{name: "missing file", arguments: "testdata/op/missing.yar"},
+		{name: "invalid timeout", arguments: "testdata/op/eicar.yar timeout=0s"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := newYara(plugintypes.OperatorOptions{
+				Arguments: tt.arguments,
+				Path:      []string{"."},
+				Root:      io.OSFS{},
+			}); err == nil {
+				t.Error("expected error")
+			}
+		})
+	}
+	if _, err := loadYaraRules([]byte("YARA compiled"), false); err == nil {
+		t.Error("expected error loading compiled rules from a remote list")
+	}
+	if _, err := loadYaraRules([]byte("rule Broken { condition: }"), true); err == nil || !strings.Contains(err.Error(), "line 1") {
+		t.Errorf("unexpected error, want the line of the syntax error, have %v", err)
+	}
+}
@@ -65,7 +65,7 @@ func directiveSecComponentSignature(options *DirectiveOptions) error {
 	if len(options.Opts) == 0 {
 		return errEmptyOptions
 	}
-	options.WAF.ComponentNames = append(options.WAF.ComponentNames, options.Opts)
+	options.WAF.Producer.Rulesets = append(options.WAF.Producer.Rulesets, corazawaf.ParseRuleset(options.Opts))
 	return nil
 }
 
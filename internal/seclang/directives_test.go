@@ -140,7 +140,7 @@ func TestDirectives(t *testing.T) {
 	directiveCases := map[string][]directiveCase{
 		"SecComponentSignature": {
 			{"", expectErrorOnDirective},
-			{"name", func(w *corazawaf.WAF) bool { return len(w.ComponentNames) == 1 }},
+			{"name", func(w *corazawaf.WAF) bool { return len(w.Producer.Rulesets) == 1 }},
 		},
 		"SecMarker": {
 			{"", expectErrorOnDirective},
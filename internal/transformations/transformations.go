@@ -5,6 +5,8 @@ package transformations
 
 import (
 	"fmt"
+	"maps"
+	"slices"
 	"strings"
 
 	"github.com/ad3n/seclang/experimental/plugins/plugintypes"
@@ -18,6 +20,11 @@ func Register(name string, trans plugintypes.Transformation) {
 	transformations[strings.ToLower(name)] = trans
 }
 
+// Names returns the sorted names of the registered transformations
+func Names() []string {
+	return slices.Sorted(maps.Keys(transformations))
+}
+
 // GetTransformation returns a transformation by name
 // If the transformation is not found, it returns an error
 func GetTransformation(name string) (plugintypes.Transformation, error) {
@@ -0,0 +1,100 @@
+// Copyright 2024 Juan Pablo Tosso and the OWASP Coraza contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package transformations
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/ad3n/seclang/experimental/plugins/plugintypes"
+)
+
+// UnicodeMap is the best-fit mapping of a code page, as found in the
+// ModSecurity unicode.mapping file. It is used by urlDecodeUni to decode
+// %uXXXX sequences into the single byte of the code page instead of
+// just keeping the lower byte of the code point.
+type UnicodeMap struct {
+	codePage int
+	table    map[uint16]byte
+}
+
+// ParseUnicodeMap reads the mapping of the given code page from the
+// content of a unicode.mapping file. The file is a list of code page
+// header lines (the code page number followed by its description) each one
+// followed by lines of "UNICODE:BYTE" hexadecimal pairs.
+// It returns an error if the code page is not found.
+func ParseUnicodeMap(data []byte, codePage int) (*UnicodeMap, error) {
+	m := &UnicodeMap{
+		codePage: codePage,
+		table:    map[uint16]byte{},
+	}
+	found := false
+	processing := false
+	for _, line := range strings.Split(string(data), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+		// code page headers start with the number of the code page
+		if cp, err := strconv.Atoi(fields[0]); err == nil {
+			processing = cp == codePage
+			found = found || processing
+			continue
+		}
+		if !processing {
+			continue
+		}
+		for _, token := range fields {
+			code, value, ok := strings.Cut(token, ":")
+			if !ok {
+				continue
+			}
+			c, err := strconv.ParseUint(code, 16, 16)
+			if err != nil {
+				return nil, fmt.Errorf("invalid unicode code point in mapping %q", token)
+			}
+			v, err := strconv.ParseUint(value, 16, 8)
+			if err != nil {
+				return nil, fmt.Errorf("invalid byte in mapping %q", token)
+			}
+			m.table[uint16(c)] = byte(v)
+		}
+	}
+	if !found {
+		return nil, fmt.Errorf("code page %d not found in unicode map", codePage)
+	}
+	return m, nil
+}
+
+// CodePage returns the code page of the mapping
+func (m *UnicodeMap) CodePage() int {
+	return m.codePage
+}
+
+// Lookup returns the byte a unicode code point is mapped to,
+// false is returned if the code point is not part of the mapping
+func (m *UnicodeMap) Lookup(code uint16) (byte, bool) {
+	b, ok := m.table[code]
+	return b, ok
+}
+
+// Len returns the number of code points in the mapping
+func (m *UnicodeMap) Len() int {
+	return len(m.table)
+}
+
+// URLDecodeUniWithMap returns the urlDecodeUni transformation using the
+// mapping to decode %uXXXX sequences. Code points missing in the mapping
+// are decoded as urlDecodeUni would do without a mapping.
+func URLDecodeUniWithMap(m *UnicodeMap) plugintypes.Transformation {
+	return func(data string) (string, bool, error) {
+		for i := 0; i < len(data); i++ {
+			if data[i] == '%' || data[i] == '+' {
+				return inplaceUniDecode(data, []byte(data), i, m), true, nil
+			}
+		}
+		return data, false, nil
+	}
+}
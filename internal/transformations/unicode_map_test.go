@@ -0,0 +1,146 @@
+// Copyright 2024 Juan Pablo Tosso and the OWASP Coraza contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package transformations
+
+import (
+	"os"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func loadTestUnicodeMap(t *testing.T, codePage int) *UnicodeMap {
+	t.Helper()
+	data, err := os.ReadFile("testdata/unicode.mapping")
+	if err != nil {
+		t.Fatal(err)
+	}
+	m, err := ParseUnicodeMap(data, codePage)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return m
+}
+
+func TestParseUnicodeMap(t *testing.T) {
+	m := loadTestUnicodeMap(t, 20127)
+	if want, have := 20127, m.CodePage(); want != have {
+		t.Errorf("unexpected code page, want %d, have %d", want, have)
+	}
+	// the mapping of other code pages must not leak into the selected one
+	if _, ok := m.Lookup(0x20ac); ok {
+		t.Error("unexpected mapping for U+20AC in code page 20127")
+	}
+	if b, ok := m.Lookup(0x00e9); !ok || b != 'e' {
+		t.Errorf("unexpected mapping for U+00E9, want %q, have %q", 'e', b)
+	}
+
+	for name, test := range map[string]struct {
+		data     string
+		codePage int
+	}{
+		"missing code page":  {"1252 (ANSI - Latin I)\n00a1:21", 20127},
+		"invalid code point": {"20127 (US-ASCII)\n0zz1:21", 20127},
+		"invalid byte":       {"20127 (US-ASCII)\n00a1:2121", 20127},
+	} {
+		t.Run(name, func(t *testing.T) {
+			if _, err := ParseUnicodeMap([]byte(test.data), test.codePage); err == nil {
+				t.Error("expected error")
+			}
+		})
+	}
+}
+
+// Cases follow the urlDecodeUni regression tests of ModSecurity, decoding
+// with and without the best-fit mapping of the code page.
+func TestURLDecodeUniWithMap(t *testing.T) {
+	for _, test := range []struct {
+		codePage int
+		input    string
+		mapped   string
+		unmapped string
+	}{
+		{20127, "", "", ""},
+		{20127, "TestCase", "TestCase", "TestCase"},
+		{20127, "Test+Case", "Test Case", "Test Case"},
+		{20127, "%u0041%u0042", "AB", "AB"},
+		{20127, "caf%u00e9", "cafe", "caf\xe9"},
+		{20127, "%u00c0%u00c9%u00cd%u00d3%u00da", "AEIOU", "\xc0\xc9\xcd\xd3\xda"},
+		{20127, "adm%u0131n", "admin", "adm1n"},
+		{20127, "%u2018or%u2019", "'or'", "\x18or\x19"},
+		{20127, "%u2039script%u203a", "<script>", "9script:"},
+		{20127, "%uff1cscript%uff1e", "<script>", "<script>"},
+		{20127, "%uFF1Cscript%uFF1E", "<script>", "<script>"},
+		{20127, "..%u2215etc%u2215passwd", "../etc/passwd", "..\x15etc\x15passwd"},
+		{20127, "%u4e2d", "\x2d", "\x2d"},
+		{20127, "%u00e9%41", "eA", "\xe9A"},
+		{20127, "%u00g9", "%u00g9", "%u00g9"},
+		{20127, "%u00e", "%u00e", "%u00e"},
+		{20127, "%u", "%u", "%u"},
+		{20127, "%", "%", "%"},
+		{1252, "%u20ac", "\x80", "\xac"},
+		{1252, "%u201cquoted%u201d", "\x93quoted\x94", "\x1cquoted\x1d"},
+		{1252, "%u00e9", "\xe9", "\xe9"},
+		{28591, "%u0100dmin", "Admin", "\x00dmin"},
+		{28591, "%u00e9%u00ff", "\xe9\xff", "\xe9\xff"},
+		{28591, "%uffe0%uffe1%uffe5", "\xa2\xa3\xa5", "\xe0\xe1\xe5"},
+		{1251, "%u0430%u0434%u043c%u0438%u043d", "\xe0\xe4\xec\xe8\xed", "04<8="},
+		{437, "caf%u00e9", "caf\x82", "caf\xe9"},
+		{37, "%u0041%u0042", "\xc1\xc2", "AB"},
+		{932, "%uff71", "\xb1", "q"},
+		{932, "%uff1cscript%uff1e", "<script>", "<script>"},
+		{1252, "%u0102dmin", "Admin", "\x02dmin"},
+		{20127, "%u01cddmin", "Admin", "\xcddmin"},
+		{20127, "%u3008script%u3009", "<script>", "\x08script\x09"},
+	} {
+		m := loadTestUnicodeMap(t, test.codePage)
+		have, _, err := URLDecodeUniWithMap(m)(test.input)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if have != test.mapped {
+			t.Errorf("unexpected mapped decoding of %q with code page %d, want %q, have %q", test.input, test.codePage, test.mapped, have)
+		}
+		have, _, err = urlDecodeUni(test.input)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if have != test.unmapped {
+			t.Errorf("unexpected decoding of %q, want %q, have %q", test.input, test.unmapped, have)
+		}
+	}
+}
+
+// The regression tests of urlDecodeUni mostly use code points of ISO 8859-1,
+// which the mapping of code page 28591 keeps, and code points missing from
+// the mapping, so decoding them with the mapping doesn't change the output.
+// The case of the full-width signs U+FFE0-U+FFEE is skipped, they are mapped
+// to their ISO 8859-1 counterparts.
+func TestURLDecodeUniWithMapRegression(t *testing.T) {
+	data, err := os.ReadFile("testdata/urlDecodeUni.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	transformation := URLDecodeUniWithMap(loadTestUnicodeMap(t, 28591))
+	for _, test := range unmarshalTests(data) {
+		if strings.Contains(test.Input, "%uffe0") {
+			continue
+		}
+		input := strings.ReplaceAll(test.Input, `\u0000`, "\u0000")
+		output := strings.ReplaceAll(test.Output, `\u0000`, "\u0000")
+		if strings.Contains(input, `\x`) {
+			input, _ = strconv.Unquote(`"` + input + `"`)
+		}
+		if strings.Contains(output, `\x`) {
+			output, _ = strconv.Unquote(`"` + output + `"`)
+		}
+		have, _, err := transformation(input)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if have != output {
+			t.Errorf("unexpected mapped decoding of %q, want %q, have %q", input, output, have)
+		}
+	}
+}
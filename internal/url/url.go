@@ -4,6 +4,7 @@
 package url
 
 import (
+	"strconv"
 	"strings"
 )
 
@@ -13,8 +14,61 @@ func ParseQuery(query string, separator byte) map[string][]string {
 	return doParseQuery(query, separator, true)
 }
 
+// ParseQueryFlatten works like ParseQuery but flattens PHP/Rails style array
+// keys the same way applications parse them, empty brackets are replaced by
+// the next index of the array: a[]=1&a[]=2&a[b][]=3 returns a[0], a[1] and a[b][0].
+func ParseQueryFlatten(query string, separator byte) map[string][]string {
+	m := make(map[string][]string)
+	next := make(map[string]int)
+	walkQuery(query, separator, true, func(key, value string) {
+		key = flattenArrayKey(key, next)
+		m[key] = append(m[key], value)
+	})
+	return m
+}
+
+// flattenArrayKey replaces the empty brackets of key with the next index of
+// the array they refer to. next keeps the next index of each array path.
+// Keys with malformed brackets are returned as is.
+func flattenArrayKey(key string, next map[string]int) string {
+	i := strings.IndexByte(key, '[')
+	if i <= 0 || key[len(key)-1] != ']' {
+		return key
+	}
+	path := strings.Builder{}
+	path.WriteString(key[:i])
+	rest := key[i:]
+	for rest != "" {
+		end := strings.IndexByte(rest, ']')
+		if rest[0] != '[' || end < 0 {
+			return key
+		}
+		seg := rest[1:end]
+		rest = rest[end+1:]
+		parent := path.String()
+		if seg == "" {
+			seg = strconv.Itoa(next[parent])
+			next[parent]++
+		} else if n, err := strconv.Atoi(seg); err == nil && n >= next[parent] {
+			next[parent] = n + 1
+		}
+		path.WriteByte('[')
+		path.WriteString(seg)
+		path.WriteByte(']')
+	}
+	return path.String()
+}
+
 func doParseQuery(query string, separator byte, urlUnescape bool) map[string][]string {
 	m := make(map[string][]string)
+	walkQuery(query, separator, urlUnescape, func(key, value string) {
+		m[key] = append(m[key], value)
+	})
+	return m
+}
+
+// walkQuery calls fn for each key/value pair of query, in order
+func walkQuery(query string, separator byte, urlUnescape bool, fn func(key, value string)) {
 	for query != "" {
 		key := query
 		if i := strings.IndexByte(key, separator); i >= 0 {
@@ -33,19 +87,30 @@ func doParseQuery(query string, separator byte, urlUnescape bool) map[string][]s
 			key = queryUnescape(key)
 			value = queryUnescape(value)
 		}
-		m[key] = append(m[key], value)
+		fn(key, value)
 	}
-	return m
 }
 
 // queryUnescape is a non-strict version of net/url.QueryUnescape.
 func queryUnescape(input string) string {
+	return unescape(input, true)
+}
+
+// PathUnescape is a non-strict version of net/url.PathUnescape, invalid
+// escapes are kept as is.
+func PathUnescape(input string) string {
+	return unescape(input, false)
+}
+
+// unescape decodes the %XX escapes of the input, and + as a space if
+// plusAsSpace is set as in the query strings
+func unescape(input string, plusAsSpace bool) string {
 	ilen := len(input)
 	res := strings.Builder{}
 	res.Grow(ilen)
 	for i := 0; i < ilen; i++ {
 		ci := input[i]
-		if ci == '+' {
+		if ci == '+' && plusAsSpace {
 			res.WriteByte(' ')
 			continue
 		}
@@ -42,6 +42,22 @@ func TestQueryUnescape(t *testing.T) {
 	}
 }
 
+func TestPathUnescape(t *testing.T) {
+	tests := map[string]string{
+		"/a%20b":    "/a b",
+		"/a+b":      "/a+b",
+		"/a%2fb":    "/a/b",
+		"/a%zzb":    "/a%zzb",
+		"/%2e%2E/a": "/../a",
+		"/a%":       "/a%",
+	}
+	for in, want := range tests {
+		if have := PathUnescape(in); want != have {
+			t.Errorf("unexpected unescaped path of %q, want %q, have %q", in, want, have)
+		}
+	}
+}
+
 func BenchmarkQueryUnescape(b *testing.B) {
 	for i := 0; i < b.N; i++ {
 		for k := range queryUnescapePayloads {
@@ -49,3 +65,35 @@ func BenchmarkQueryUnescape(b *testing.B) {
 		}
 	}
 }
+
+func TestParseQueryFlatten(t *testing.T) {
+	tests := map[string]map[string][]string{
+		"a[]=1&a[]=2":        {"a[0]": {"1"}, "a[1]": {"2"}},
+		"a[b]=c":             {"a[b]": {"c"}},
+		"a[b][]=1&a[b][]=2":  {"a[b][0]": {"1"}, "a[b][1]": {"2"}},
+		"a[5]=1&a[]=2":       {"a[5]": {"1"}, "a[6]": {"2"}},
+		"a%5B%5D=1&a[]=2":    {"a[0]": {"1"}, "a[1]": {"2"}},
+		"a=1&a=2":            {"a": {"1", "2"}},
+		"a[=1&[]=2&a[b]c=3":  {"a[": {"1"}, "[]": {"2"}, "a[b]c": {"3"}},
+		"a[][x]=1&a[][x]=2":  {"a[0][x]": {"1"}, "a[1][x]": {"2"}},
+		"a[b]=1&a[]=2&c[]=3": {"a[b]": {"1"}, "a[0]": {"2"}, "c[0]": {"3"}},
+	}
+	for query, want := range tests {
+		have := ParseQueryFlatten(query, '&')
+		if len(have) != len(want) {
+			t.Errorf("unexpected keys for %q, want %v, have %v", query, want, have)
+			continue
+		}
+		for k, vs := range want {
+			if len(have[k]) != len(vs) {
+				t.Errorf("unexpected values of %q for %q, want %v, have %v", k, query, vs, have[k])
+				continue
+			}
+			for i, v := range vs {
+				if have[k][i] != v {
+					t.Errorf("unexpected value of %q for %q, want %q, have %q", k, query, v, have[k][i])
+				}
+			}
+		}
+	}
+}
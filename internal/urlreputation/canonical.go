@@ -0,0 +1,190 @@
+// Copyright 2024 Juan Pablo Tosso and the OWASP Coraza contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package urlreputation
+
+import (
+	"net"
+	"net/url"
+	"strings"
+)
+
+const (
+	// maxHostSuffixes and maxPathPrefixes bound the expressions looked up for
+	// a URL, like the Safe Browsing clients do
+	maxHostSuffixes = 4
+	maxPathPrefixes = 4
+	// maxUnescapes bounds the percent-decoding of the URLs encoded repeatedly
+	maxUnescapes = 16
+)
+
+// controlReplacer removes the tabs and line breaks of the URLs
+var controlReplacer = strings.NewReplacer("\t", "", "\r", "", "\n", "")
+
+// canonicalize returns the host and the path, including the query, of the
+// URL in the canonical form of the Safe Browsing lists: the host is
+// lowercased without its port, the path is resolved and both are
+// percent-decoded then re-encoded the same way whatever the client sent.
+func canonicalize(rawURL string) (string, string, bool) {
+	s := controlReplacer.Replace(strings.TrimSpace(rawURL))
+	s, _, _ = strings.Cut(s, "#")
+	if !strings.Contains(s, "://") {
+		s = "http://" + s
+	}
+	scheme, rest, _ := strings.Cut(s, "://")
+	if scheme = strings.ToLower(scheme); scheme != "http" && scheme != "https" {
+		return "", "", false
+	}
+	authority, path := rest, "/"
+	if i := strings.IndexAny(rest, "/?"); i != -1 {
+		authority, path = rest[:i], rest[i:]
+		if path[0] == '?' {
+			path = "/" + path
+		}
+	}
+	if i := strings.LastIndexByte(authority, '@'); i != -1 {
+		authority = authority[i+1:]
+	}
+
+	host := lowerASCII(unescape(authority))
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	host = strings.Trim(host, ".[]")
+	for strings.Contains(host, "..") {
+		host = strings.ReplaceAll(host, "..", ".")
+	}
+	if host == "" {
+		return "", "", false
+	}
+
+	path, query, hasQuery := strings.Cut(path, "?")
+	path = resolvePath(unescape(path))
+	if hasQuery {
+		path += "?" + unescape(query)
+	}
+	return escape(host), escape(path), true
+}
+
+// Canonical returns the URL in the canonical form of the Safe Browsing lists
+// without its scheme, e.g. example.com/a/b?x for HTTP://Example.com:80/a/./b?x,
+// so the URLs looked up the same way have the same canonical form. It returns
+// false for the invalid URLs.
+func Canonical(rawURL string) (string, bool) {
+	host, path, ok := canonicalize(rawURL)
+	if !ok {
+		return "", false
+	}
+	return host + path, true
+}
+
+// lowerASCII lowercases the ASCII letters, keeping the other bytes as is
+func lowerASCII(s string) string {
+	b := []byte(s)
+	for i, c := range b {
+		if c >= 'A' && c <= 'Z' {
+			b[i] = c + 'a' - 'A'
+		}
+	}
+	return string(b)
+}
+
+// unescape percent-decodes s until it doesn't change
+func unescape(s string) string {
+	for i := 0; i < maxUnescapes && strings.Contains(s, "%"); i++ {
+		u, err := url.PathUnescape(s)
+		if err != nil || u == s {
+			break
+		}
+		s = u
+	}
+	return s
+}
+
+// escape percent-encodes the control, space, non-ASCII, # and % characters
+func escape(s string) string {
+	const hex = "0123456789ABCDEF"
+	var sb strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if c <= ' ' || c >= 0x7f || c == '#' || c == '%' {
+			sb.WriteByte('%')
+			sb.WriteByte(hex[c>>4])
+			sb.WriteByte(hex[c&0xf])
+			continue
+		}
+		sb.WriteByte(c)
+	}
+	return sb.String()
+}
+
+// resolvePath removes the dot segments and the consecutive slashes of the path
+func resolvePath(path string) string {
+	segments := strings.Split(path, "/")
+	resolved := make([]string, 0, len(segments))
+	for _, s := range segments[1:] {
+		switch s {
+		case "", ".":
+		case "..":
+			if len(resolved) > 0 {
+				resolved = resolved[:len(resolved)-1]
+			}
+		default:
+			resolved = append(resolved, s)
+		}
+	}
+	res := "/" + strings.Join(resolved, "/")
+	// the trailing slash is significant, e.g. for /dir/ and /dir/.
+	if len(resolved) > 0 && (strings.HasSuffix(path, "/") || strings.HasSuffix(path, "/.") || strings.HasSuffix(path, "/..")) {
+		res += "/"
+	}
+	return res
+}
+
+// Expressions returns the host suffix and path prefix expressions the URL is
+// looked up with, e.g. a.b.example.com/1/2.html?x matches the entries for
+// example.com/ and b.example.com/1/. It returns nil for the invalid URLs.
+func Expressions(rawURL string) []string {
+	host, path, ok := canonicalize(rawURL)
+	if !ok {
+		return nil
+	}
+
+	hosts := []string{host}
+	if net.ParseIP(host) == nil {
+		components := strings.Split(host, ".")
+		first := 1
+		if len(components) > maxHostSuffixes+1 {
+			components = components[len(components)-maxHostSuffixes-1:]
+			first = 0
+		}
+		// the top level domain alone is not looked up
+		for i := first; i < len(components)-1; i++ {
+			hosts = append(hosts, strings.Join(components[i:], "."))
+		}
+	}
+
+	paths := []string{path}
+	p, _, hasQuery := strings.Cut(path, "?")
+	if hasQuery {
+		paths = append(paths, p)
+	}
+	prefix := "/"
+	for i, segments := 0, strings.Split(strings.TrimPrefix(p, "/"), "/"); i < maxPathPrefixes; i++ {
+		if prefix != p {
+			paths = append(paths, prefix)
+		}
+		if i >= len(segments)-1 {
+			break
+		}
+		prefix += segments[i] + "/"
+	}
+
+	expressions := make([]string, 0, len(hosts)*len(paths))
+	for _, h := range hosts {
+		for _, p := range paths {
+			expressions = append(expressions, h+p)
+		}
+	}
+	return expressions
+}
@@ -0,0 +1,119 @@
+// Copyright 2024 Juan Pablo Tosso and the OWASP Coraza contributors
+// SPDX-License-Identifier: Apache-2.0
+
+//go:build !tinygo
+// +build !tinygo
+
+package urlreputation
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/ad3n/seclang/experimental/plugins/plugintypes"
+)
+
+const (
+	defaultSafeBrowsingTimeout = 2 * time.Second
+	// maxSafeBrowsingResponseSize limits the size of the responses read from
+	// a misbehaving server
+	maxSafeBrowsingResponseSize = 1 << 20
+)
+
+// safeBrowsingThreats are the threat types the URLs are looked up for
+var safeBrowsingThreats = []string{"MALWARE", "SOCIAL_ENGINEERING", "UNWANTED_SOFTWARE", "POTENTIALLY_HARMFUL_APPLICATION"}
+
+// SafeBrowsing looks up the URLs with the threatMatches:find method of a
+// Safe Browsing v4 compatible API, e.g.
+// https://safebrowsing.googleapis.com/v4/threatMatches:find?key=KEY
+type SafeBrowsing struct {
+	endpoint string
+	client   *http.Client
+}
+
+var _ plugintypes.URLReputation = (*SafeBrowsing)(nil)
+
+// NewSafeBrowsing returns the provider of the threatMatches:find endpoint,
+// its URL includes the API key if any
+func NewSafeBrowsing(endpoint string) (plugintypes.URLReputation, error) {
+	u, err := url.Parse(endpoint)
+	if err != nil || u.Host == "" {
+		return nil, fmt.Errorf("invalid Safe Browsing endpoint %q", endpoint)
+	}
+	return &SafeBrowsing{
+		endpoint: endpoint,
+		client:   &http.Client{Timeout: defaultSafeBrowsingTimeout},
+	}, nil
+}
+
+type safeBrowsingRequest struct {
+	Client struct {
+		ClientID      string `json:"clientId"`
+		ClientVersion string `json:"clientVersion"`
+	} `json:"client"`
+	ThreatInfo struct {
+		ThreatTypes      []string `json:"threatTypes"`
+		PlatformTypes    []string `json:"platformTypes"`
+		ThreatEntryTypes []string `json:"threatEntryTypes"`
+		ThreatEntries    []struct {
+			URL string `json:"url"`
+		} `json:"threatEntries"`
+	} `json:"threatInfo"`
+}
+
+type safeBrowsingResponse struct {
+	Matches []struct {
+		ThreatType string `json:"threatType"`
+	} `json:"matches"`
+}
+
+func (s *SafeBrowsing) Lookup(u string) (string, bool, error) {
+	var body safeBrowsingRequest
+	body.Client.ClientID = "coraza"
+	body.Client.ClientVersion = "3"
+	body.ThreatInfo.ThreatTypes = safeBrowsingThreats
+	body.ThreatInfo.PlatformTypes = []string{"ANY_PLATFORM"}
+	body.ThreatInfo.ThreatEntryTypes = []string{"URL"}
+	body.ThreatInfo.ThreatEntries = []struct {
+		URL string `json:"url"`
+	}{{URL: u}}
+	data, err := json.Marshal(body)
+	if err != nil {
+		return "", false, err
+	}
+
+	res, err := s.client.Post(s.endpoint, "application/json", bytes.NewReader(data))
+	if err != nil {
+		// the error includes the endpoint and its key
+		return "", false, fmt.Errorf("safe browsing lookup failed: %s", redact(err))
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return "", false, fmt.Errorf("safe browsing lookup failed: unexpected status %d", res.StatusCode)
+	}
+	var matches safeBrowsingResponse
+	if err := json.NewDecoder(io.LimitReader(res.Body, maxSafeBrowsingResponseSize)).Decode(&matches); err != nil {
+		return "", false, fmt.Errorf("invalid safe browsing response: %s", err.Error())
+	}
+	if len(matches.Matches) == 0 {
+		return "", false, nil
+	}
+	return matches.Matches[0].ThreatType, true, nil
+}
+
+// redact returns the message of the error without the URL of the request
+func redact(err error) string {
+	if urlErr, ok := err.(*url.Error); ok {
+		return urlErr.Err.Error()
+	}
+	return err.Error()
+}
+
+func init() {
+	Register("https", NewSafeBrowsing)
+}
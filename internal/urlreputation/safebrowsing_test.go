@@ -0,0 +1,74 @@
+// Copyright 2024 Juan Pablo Tosso and the OWASP Coraza contributors
+// SPDX-License-Identifier: Apache-2.0
+
+//go:build !tinygo
+// +build !tinygo
+
+package urlreputation
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestSafeBrowsing(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("key") != "secret" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		var req safeBrowsingRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil || len(req.ThreatInfo.ThreatEntries) != 1 {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		if req.ThreatInfo.ThreatEntries[0].URL == "http://malware.testing.google.test/testing/malware/" {
+			_, _ = w.Write([]byte(`{"matches":[{"threatType":"MALWARE","platformType":"ANY_PLATFORM","threat":{"url":"http://malware.testing.google.test/testing/malware/"}}]}`))
+			return
+		}
+		_, _ = w.Write([]byte(`{}`))
+	}))
+	defer srv.Close()
+
+	p, err := NewSafeBrowsing(srv.URL + "/v4/threatMatches:find?key=secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	p.(*SafeBrowsing).client = srv.Client()
+	tests := []struct {
+		url    string
+		threat string
+		listed bool
+	}{
+		{"http://malware.testing.google.test/testing/malware/", "MALWARE", true},
+		{"http://example.com/", "", false},
+	}
+	for _, tt := range tests {
+		threat, listed, err := p.Lookup(tt.url)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if threat != tt.threat || listed != tt.listed {
+			t.Errorf("unexpected lookup of %q, want %q %t, have %q %t", tt.url, tt.threat, tt.listed, threat, listed)
+		}
+	}
+
+	p, err = NewSafeBrowsing(srv.URL + "/v4/threatMatches:find?key=wrong")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := p.Lookup("http://example.com/"); err == nil || !strings.Contains(err.Error(), "403") {
+		t.Errorf("unexpected error, want the status, have %v", err)
+	}
+
+	srv.Close()
+	if _, _, err := p.Lookup("http://example.com/"); err == nil || strings.Contains(err.Error(), "wrong") {
+		t.Errorf("unexpected error, want an error without the key, have %v", err)
+	}
+	if _, err := NewSafeBrowsing("https://"); err == nil {
+		t.Error("expected error for an endpoint without host")
+	}
+}
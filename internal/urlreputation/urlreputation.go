@@ -0,0 +1,117 @@
+// Copyright 2024 Juan Pablo Tosso and the OWASP Coraza contributors
+// SPDX-License-Identifier: Apache-2.0
+
+// Package urlreputation implements the URL reputation providers of the
+// @gsbLookup operator: local lists of URL expressions or of their SHA-256,
+// like the Safe Browsing lists, and Safe Browsing v4 compatible APIs.
+// See https://developers.google.com/safe-browsing/v4/urls-hashing for the
+// expressions a URL is looked up with.
+package urlreputation
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/ad3n/seclang/experimental/plugins/plugintypes"
+)
+
+// DefaultThreat is the threat of the entries of the local lists without one
+const DefaultThreat = "MALWARE"
+
+var (
+	mu        sync.RWMutex
+	factories = map[string]plugintypes.URLReputationFactory{}
+)
+
+// Register registers the factory of the providers of the URIs with the
+// scheme, it overwrites the factory already registered for the scheme
+func Register(scheme string, factory plugintypes.URLReputationFactory) {
+	mu.Lock()
+	defer mu.Unlock()
+	factories[strings.ToLower(scheme)] = factory
+}
+
+// Get returns the factory registered for the scheme
+func Get(scheme string) (plugintypes.URLReputationFactory, bool) {
+	mu.RLock()
+	defer mu.RUnlock()
+	f, ok := factories[strings.ToLower(scheme)]
+	return f, ok
+}
+
+// Names returns the registered schemes sorted
+func Names() []string {
+	mu.RLock()
+	defer mu.RUnlock()
+	names := make([]string, 0, len(factories))
+	for name := range factories {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// HashSet is a local list of URL expressions indexed by their SHA-256
+type HashSet struct {
+	threats map[[sha256.Size]byte]string
+}
+
+var _ plugintypes.URLReputation = (*HashSet)(nil)
+
+// ParseHashSet parses a list of one entry per line, either a URL expression,
+// e.g. evil.example.com/login/, or its hexadecimal SHA-256, optionally
+// followed by the threat it is listed for. Empty lines and lines starting
+// with # are ignored.
+func ParseHashSet(data []byte) (*HashSet, error) {
+	s := &HashSet{threats: map[[sha256.Size]byte]string{}}
+	sc := bufio.NewScanner(bytes.NewReader(data))
+	for n := 1; sc.Scan(); n++ {
+		fields := strings.Fields(sc.Text())
+		if len(fields) == 0 || strings.HasPrefix(fields[0], "#") {
+			continue
+		}
+		if len(fields) > 2 {
+			return nil, fmt.Errorf("invalid entry at line %d, expected an expression and a threat", n)
+		}
+		threat := DefaultThreat
+		if len(fields) == 2 {
+			threat = strings.ToUpper(fields[1])
+		}
+		var digest [sha256.Size]byte
+		if b, err := hex.DecodeString(fields[0]); err == nil && len(b) == sha256.Size {
+			copy(digest[:], b)
+		} else {
+			host, path, ok := canonicalize(fields[0])
+			if !ok {
+				return nil, fmt.Errorf("invalid expression %q at line %d", fields[0], n)
+			}
+			digest = sha256.Sum256([]byte(host + path))
+		}
+		s.threats[digest] = threat
+	}
+	if err := sc.Err(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// Lookup returns the threat of the first expression of the URL in the list
+func (s *HashSet) Lookup(url string) (string, bool, error) {
+	for _, e := range Expressions(url) {
+		if threat, ok := s.threats[sha256.Sum256([]byte(e))]; ok {
+			return threat, true, nil
+		}
+	}
+	return "", false, nil
+}
+
+// Len returns the number of entries of the list
+func (s *HashSet) Len() int {
+	return len(s.threats)
+}
@@ -0,0 +1,604 @@
+// Copyright 2024 Juan Pablo Tosso and the OWASP Coraza contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package xmlschema
+
+import (
+	"encoding/xml"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// compiler compiles the declarations of a schema document, the named
+// components are compiled when they are first referenced
+type compiler struct {
+	targetNS            string
+	qualifiedElements   bool
+	qualifiedAttributes bool
+
+	elementNodes   map[string]*node
+	complexNodes   map[string]*node
+	simpleNodes    map[string]*node
+	groupNodes     map[string]*node
+	attrGroupNodes map[string]*node
+	attrNodes      map[string]*node
+
+	elements     map[string]*element
+	complexTypes map[string]*typeDef
+	simpleTypes  map[string]*simpleType
+	// compiling holds the simple types and groups being compiled to detect
+	// circular definitions
+	compiling map[*node]bool
+}
+
+// Compile compiles a schema document
+func Compile(data []byte) (*Schema, error) {
+	root, err := parse(data, true)
+	if err != nil {
+		return nil, fmt.Errorf("invalid XML: %w", err)
+	}
+	if root.name != (xml.Name{Space: xsdNS, Local: "schema"}) {
+		return nil, fmt.Errorf("root element %q is not a schema", root.name.Local)
+	}
+	targetNS, _ := root.attr("targetNamespace")
+	elementForm, _ := root.attr("elementFormDefault")
+	attributeForm, _ := root.attr("attributeFormDefault")
+	c := &compiler{
+		targetNS:            targetNS,
+		qualifiedElements:   elementForm == "qualified",
+		qualifiedAttributes: attributeForm == "qualified",
+		elementNodes:        map[string]*node{},
+		complexNodes:        map[string]*node{},
+		simpleNodes:         map[string]*node{},
+		groupNodes:          map[string]*node{},
+		attrGroupNodes:      map[string]*node{},
+		attrNodes:           map[string]*node{},
+		elements:            map[string]*element{},
+		complexTypes:        map[string]*typeDef{},
+		simpleTypes:         map[string]*simpleType{},
+		compiling:           map[*node]bool{},
+	}
+
+	for _, n := range root.schemaChildren() {
+		var components map[string]*node
+		switch n.name.Local {
+		case "element":
+			components = c.elementNodes
+		case "complexType":
+			components = c.complexNodes
+		case "simpleType":
+			components = c.simpleNodes
+		case "group":
+			components = c.groupNodes
+		case "attributeGroup":
+			components = c.attrGroupNodes
+		case "attribute":
+			components = c.attrNodes
+		case "include", "import", "redefine", "override":
+			return nil, fmt.Errorf("xs:%s is not supported", n.name.Local)
+		default:
+			continue
+		}
+		name, ok := n.attr("name")
+		if !ok || name == "" {
+			return nil, fmt.Errorf("global xs:%s without name", n.name.Local)
+		}
+		if _, ok := components[name]; ok {
+			return nil, fmt.Errorf("duplicate xs:%s %q", n.name.Local, name)
+		}
+		components[name] = n
+	}
+
+	// compile everything to report the errors of the unused components too
+	for _, name := range sortedKeys(c.complexNodes) {
+		if _, err := c.complexType(name); err != nil {
+			return nil, err
+		}
+	}
+	for _, name := range sortedKeys(c.simpleNodes) {
+		if _, err := c.simpleType(name); err != nil {
+			return nil, err
+		}
+	}
+	s := &Schema{
+		elements:    map[xml.Name]*element{},
+		substitutes: map[*element][]*element{},
+	}
+	for _, name := range sortedKeys(c.elementNodes) {
+		e, err := c.globalElement(name)
+		if err != nil {
+			return nil, err
+		}
+		s.elements[e.name] = e
+	}
+	for _, name := range sortedKeys(c.elementNodes) {
+		group, ok := c.elementNodes[name].attr("substitutionGroup")
+		if !ok {
+			continue
+		}
+		_, local := c.qname(c.elementNodes[name], group)
+		head, err := c.globalElement(local)
+		if err != nil {
+			return nil, err
+		}
+		s.substitutes[head] = append(s.substitutes[head], c.elements[name])
+	}
+	return s, nil
+}
+
+func sortedKeys(m map[string]*node) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	slices.Sort(keys)
+	return keys
+}
+
+// qname resolves a qualified name used as value of an attribute of n
+func (c *compiler) qname(n *node, qname string) (string, string) {
+	qname = strings.TrimSpace(qname)
+	prefix, local, ok := strings.Cut(qname, ":")
+	if !ok {
+		return n.ns[""], qname
+	}
+	return n.ns[prefix], local
+}
+
+func (c *compiler) globalElement(name string) (*element, error) {
+	if e, ok := c.elements[name]; ok {
+		return e, nil
+	}
+	n, ok := c.elementNodes[name]
+	if !ok {
+		return nil, fmt.Errorf("undefined element %q", name)
+	}
+	e := &element{name: xml.Name{Space: c.targetNS, Local: name}}
+	c.elements[name] = e
+	if err := c.fillElement(e, n); err != nil {
+		return nil, err
+	}
+	return e, nil
+}
+
+func (c *compiler) fillElement(e *element, n *node) error {
+	nillable, _ := n.attr("nillable")
+	e.nillable = nillable == "true" || nillable == "1"
+	abstract, _ := n.attr("abstract")
+	e.abstract = abstract == "true" || abstract == "1"
+
+	var err error
+	if t, ok := n.attr("type"); ok {
+		e.typ, err = c.typeRef(n, t)
+	} else if ct := n.child("complexType"); ct != nil {
+		e.typ, err = c.compileComplexType(ct, &typeDef{})
+	} else if st := n.child("simpleType"); st != nil {
+		var simple *simpleType
+		simple, err = c.compileSimpleType(st)
+		e.typ = &typeDef{simple: simple, filled: true}
+	} else if group, ok := n.attr("substitutionGroup"); ok {
+		// the members of a substitution group have the type of the head by default
+		_, local := c.qname(n, group)
+		var head *element
+		if head, err = c.globalElement(local); err == nil {
+			e.typ = head.typ
+		}
+	}
+	if err != nil {
+		return fmt.Errorf("element %q: %w", e.name.Local, err)
+	}
+	if e.typ == nil {
+		e.typ = anyType
+	}
+	return nil
+}
+
+// typeRef returns the type referenced by a qualified name
+func (c *compiler) typeRef(n *node, qname string) (*typeDef, error) {
+	ns, local := c.qname(n, qname)
+	if ns != xsdNS {
+		if _, ok := c.complexNodes[local]; ok {
+			return c.complexType(local)
+		}
+		if _, ok := c.simpleNodes[local]; ok {
+			st, err := c.simpleType(local)
+			if err != nil {
+				return nil, err
+			}
+			return &typeDef{simple: st, filled: true}, nil
+		}
+	}
+	if local == "anyType" {
+		return anyType, nil
+	}
+	if st := builtin(local); st != nil {
+		return &typeDef{simple: st, filled: true}, nil
+	}
+	return nil, fmt.Errorf("undefined type %q", qname)
+}
+
+// simpleTypeRef returns the simple type referenced by a qualified name
+func (c *compiler) simpleTypeRef(n *node, qname string) (*simpleType, error) {
+	t, err := c.typeRef(n, qname)
+	if err != nil {
+		return nil, err
+	}
+	if t.simple == nil || len(t.attrs) > 0 {
+		return nil, fmt.Errorf("type %q is not a simple type", qname)
+	}
+	return t.simple, nil
+}
+
+func (c *compiler) complexType(name string) (*typeDef, error) {
+	if t, ok := c.complexTypes[name]; ok {
+		return t, nil
+	}
+	t := &typeDef{}
+	c.complexTypes[name] = t
+	if _, err := c.compileComplexType(c.complexNodes[name], t); err != nil {
+		return nil, fmt.Errorf("complex type %q: %w", name, err)
+	}
+	return t, nil
+}
+
+func (c *compiler) compileComplexType(n *node, t *typeDef) (*typeDef, error) {
+	mixed, _ := n.attr("mixed")
+	t.mixed = mixed == "true" || mixed == "1"
+
+	if sc := n.child("simpleContent"); sc != nil {
+		derivation, base, err := c.derivation(sc)
+		if err != nil {
+			return nil, err
+		}
+		if base.simple == nil {
+			return nil, fmt.Errorf("the base of a simple content must have a simple type")
+		}
+		t.simple = base.simple
+		if derivation.name.Local == "restriction" {
+			if t.simple, err = c.restrict(base.simple, derivation); err != nil {
+				return nil, err
+			}
+		}
+		t.attrs = slices.Clone(base.attrs)
+		t.anyAttr = base.anyAttr
+		if err := c.attributes(derivation, t); err != nil {
+			return nil, err
+		}
+	} else if cc := n.child("complexContent"); cc != nil {
+		if mixed, ok := cc.attr("mixed"); ok {
+			t.mixed = mixed == "true" || mixed == "1"
+		}
+		derivation, base, err := c.derivation(cc)
+		if err != nil {
+			return nil, err
+		}
+		if base.simple != nil {
+			return nil, fmt.Errorf("the base of a complex content must be a complex type")
+		}
+		content, err := c.model(derivation)
+		if err != nil {
+			return nil, err
+		}
+		if derivation.name.Local == "extension" {
+			t.content = sequence(base.content, content)
+			t.mixed = t.mixed || base.mixed
+		} else {
+			t.content = content
+		}
+		// the attributes are inherited by the restrictions unless prohibited
+		t.attrs = slices.Clone(base.attrs)
+		t.anyAttr = base.anyAttr
+		if err := c.attributes(derivation, t); err != nil {
+			return nil, err
+		}
+	} else {
+		content, err := c.model(n)
+		if err != nil {
+			return nil, err
+		}
+		t.content = content
+		if err := c.attributes(n, t); err != nil {
+			return nil, err
+		}
+	}
+	t.filled = true
+	return t, nil
+}
+
+// derivation returns the extension or restriction of a simple or complex
+// content along with its base type
+func (c *compiler) derivation(content *node) (*node, *typeDef, error) {
+	for _, d := range content.schemaChildren() {
+		if d.name.Local != "extension" && d.name.Local != "restriction" {
+			continue
+		}
+		baseName, ok := d.attr("base")
+		if !ok {
+			return nil, nil, fmt.Errorf("xs:%s without base", d.name.Local)
+		}
+		base, err := c.typeRef(d, baseName)
+		if err != nil {
+			return nil, nil, err
+		}
+		if !base.filled {
+			return nil, nil, fmt.Errorf("circular derivation of type %q", baseName)
+		}
+		return d, base, nil
+	}
+	return nil, nil, fmt.Errorf("xs:%s without extension or restriction", content.name.Local)
+}
+
+// sequence concatenates two content models
+func sequence(a, b *particle) *particle {
+	if a == nil {
+		return b
+	}
+	if b == nil {
+		return a
+	}
+	return &particle{kind: particleSequence, children: []*particle{a, b}, min: 1, max: 1}
+}
+
+// model returns the content model of a complex type or derivation, nil if
+// the content is empty
+func (c *compiler) model(n *node) (*particle, error) {
+	for _, m := range n.schemaChildren() {
+		switch m.name.Local {
+		case "sequence", "choice", "all", "group":
+			return c.particle(m)
+		}
+	}
+	return nil, nil
+}
+
+func (c *compiler) particle(n *node) (*particle, error) {
+	min, max, err := occurs(n)
+	if err != nil {
+		return nil, err
+	}
+	p := &particle{min: min, max: max}
+	switch n.name.Local {
+	case "element":
+		p.kind = particleElement
+		if ref, ok := n.attr("ref"); ok {
+			_, local := c.qname(n, ref)
+			if p.elem, err = c.globalElement(local); err != nil {
+				return nil, err
+			}
+			return p, nil
+		}
+		name, ok := n.attr("name")
+		if !ok || name == "" {
+			return nil, fmt.Errorf("xs:element without name or ref")
+		}
+		p.elem = &element{name: xml.Name{Local: name}}
+		form, ok := n.attr("form")
+		if form == "qualified" || !ok && c.qualifiedElements {
+			p.elem.name.Space = c.targetNS
+		}
+		if err := c.fillElement(p.elem, n); err != nil {
+			return nil, err
+		}
+	case "any":
+		p.kind = particleAny
+		switch namespace, _ := n.attr("namespace"); strings.TrimSpace(namespace) {
+		case "", "##any":
+		case "##other":
+			p.other, p.otherNS = true, c.targetNS
+		default:
+			p.namespaces = []string{}
+			for _, ns := range strings.Fields(namespace) {
+				switch ns {
+				case "##targetNamespace":
+					ns = c.targetNS
+				case "##local":
+					ns = ""
+				}
+				p.namespaces = append(p.namespaces, ns)
+			}
+		}
+	case "sequence", "choice", "all":
+		p.kind = map[string]particleKind{
+			"sequence": particleSequence,
+			"choice":   particleChoice,
+			"all":      particleAll,
+		}[n.name.Local]
+		for _, child := range n.schemaChildren() {
+			cp, err := c.particle(child)
+			if err != nil {
+				return nil, err
+			}
+			if p.kind == particleAll && (cp.kind != particleElement || cp.max > 1) {
+				return nil, fmt.Errorf("xs:all can only contain elements occurring at most once")
+			}
+			p.children = append(p.children, cp)
+		}
+	case "group":
+		ref, ok := n.attr("ref")
+		if !ok {
+			return nil, fmt.Errorf("xs:group without ref")
+		}
+		_, local := c.qname(n, ref)
+		group, ok := c.groupNodes[local]
+		if !ok {
+			return nil, fmt.Errorf("undefined group %q", local)
+		}
+		if c.compiling[group] {
+			return nil, fmt.Errorf("circular group %q", local)
+		}
+		c.compiling[group] = true
+		model, err := c.model(group)
+		delete(c.compiling, group)
+		if err != nil {
+			return nil, err
+		}
+		p.kind = particleSequence
+		if model != nil {
+			p.children = []*particle{model}
+		}
+	default:
+		return nil, fmt.Errorf("unexpected xs:%s in content model", n.name.Local)
+	}
+	return p, nil
+}
+
+// attributes adds the attributes declared by n to t
+func (c *compiler) attributes(n *node, t *typeDef) error {
+	for _, child := range n.schemaChildren() {
+		switch child.name.Local {
+		case "attribute":
+			a, err := c.attribute(child)
+			if err != nil {
+				return err
+			}
+			t.attrs = slices.DeleteFunc(t.attrs, func(b *attribute) bool { return b.name == a.name })
+			if !a.prohibited {
+				t.attrs = append(t.attrs, a)
+			}
+		case "attributeGroup":
+			ref, ok := child.attr("ref")
+			if !ok {
+				return fmt.Errorf("xs:attributeGroup without ref")
+			}
+			_, local := c.qname(child, ref)
+			group, ok := c.attrGroupNodes[local]
+			if !ok {
+				return fmt.Errorf("undefined attribute group %q", local)
+			}
+			if c.compiling[group] {
+				return fmt.Errorf("circular attribute group %q", local)
+			}
+			c.compiling[group] = true
+			err := c.attributes(group, t)
+			delete(c.compiling, group)
+			if err != nil {
+				return err
+			}
+		case "anyAttribute":
+			t.anyAttr = true
+		}
+	}
+	return nil
+}
+
+func (c *compiler) attribute(n *node) (*attribute, error) {
+	a := &attribute{}
+	use, _ := n.attr("use")
+	a.required = use == "required"
+	a.prohibited = use == "prohibited"
+
+	decl := n
+	if ref, ok := n.attr("ref"); ok {
+		ns, local := c.qname(n, ref)
+		if ns == xmlNS {
+			// xml:lang and the like are always allowed
+			a.name = xml.Name{Space: xmlNS, Local: local}
+			a.typ = builtin("string")
+			return a, nil
+		}
+		if decl, ok = c.attrNodes[local]; !ok {
+			return nil, fmt.Errorf("undefined attribute %q", local)
+		}
+		a.name = xml.Name{Space: c.targetNS, Local: local}
+	} else {
+		name, ok := n.attr("name")
+		if !ok || name == "" {
+			return nil, fmt.Errorf("xs:attribute without name or ref")
+		}
+		a.name = xml.Name{Local: name}
+		form, ok := n.attr("form")
+		if form == "qualified" || !ok && c.qualifiedAttributes {
+			a.name.Space = c.targetNS
+		}
+	}
+
+	var err error
+	if t, ok := decl.attr("type"); ok {
+		a.typ, err = c.simpleTypeRef(decl, t)
+	} else if st := decl.child("simpleType"); st != nil {
+		a.typ, err = c.compileSimpleType(st)
+	} else {
+		a.typ = builtin("anySimpleType")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("attribute %q: %w", a.name.Local, err)
+	}
+	return a, nil
+}
+
+func (c *compiler) simpleType(name string) (*simpleType, error) {
+	if st, ok := c.simpleTypes[name]; ok {
+		return st, nil
+	}
+	n := c.simpleNodes[name]
+	if c.compiling[n] {
+		return nil, fmt.Errorf("circular simple type %q", name)
+	}
+	c.compiling[n] = true
+	st, err := c.compileSimpleType(n)
+	delete(c.compiling, n)
+	if err != nil {
+		return nil, fmt.Errorf("simple type %q: %w", name, err)
+	}
+	c.simpleTypes[name] = st
+	return st, nil
+}
+
+func (c *compiler) compileSimpleType(n *node) (*simpleType, error) {
+	for _, d := range n.schemaChildren() {
+		switch d.name.Local {
+		case "restriction":
+			var base *simpleType
+			var err error
+			if b, ok := d.attr("base"); ok {
+				base, err = c.simpleTypeRef(d, b)
+			} else if st := d.child("simpleType"); st != nil {
+				base, err = c.compileSimpleType(st)
+			} else {
+				err = fmt.Errorf("xs:restriction without base")
+			}
+			if err != nil {
+				return nil, err
+			}
+			return c.restrict(base, d)
+		case "list":
+			var item *simpleType
+			var err error
+			if t, ok := d.attr("itemType"); ok {
+				item, err = c.simpleTypeRef(d, t)
+			} else if st := d.child("simpleType"); st != nil {
+				item, err = c.compileSimpleType(st)
+			} else {
+				err = fmt.Errorf("xs:list without item type")
+			}
+			if err != nil {
+				return nil, err
+			}
+			return newSimpleType("", collapse, item, nil), nil
+		case "union":
+			var members []*simpleType
+			if types, ok := d.attr("memberTypes"); ok {
+				for _, t := range strings.Fields(types) {
+					st, err := c.simpleTypeRef(d, t)
+					if err != nil {
+						return nil, err
+					}
+					members = append(members, st)
+				}
+			}
+			for _, m := range d.schemaChildren() {
+				st, err := c.compileSimpleType(m)
+				if err != nil {
+					return nil, err
+				}
+				members = append(members, st)
+			}
+			if len(members) == 0 {
+				return nil, fmt.Errorf("xs:union without member types")
+			}
+			return newSimpleType("", collapse, nil, members), nil
+		}
+	}
+	return nil, fmt.Errorf("simple type without restriction, list or union")
+}
@@ -0,0 +1,435 @@
+// Copyright 2024 Juan Pablo Tosso and the OWASP Coraza contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package xmlschema
+
+import (
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// whitespace is the normalization applied to a value before validating it
+type whitespace int
+
+const (
+	preserve whitespace = iota
+	replace
+	collapse
+)
+
+// simpleType is a built-in type, or a restriction, list or union of simple
+// types
+type simpleType struct {
+	// primitive is the built-in type the atomic types derive from
+	primitive  string
+	whitespace whitespace
+	list       *simpleType
+	union      []*simpleType
+
+	enumeration []string
+	// patterns holds the patterns of each derivation step, a value must match
+	// one pattern of every step
+	patterns [][]*pattern
+
+	length, minLength, maxLength int
+	totalDigits, fractionDigits  int
+	minInclusive, maxInclusive   *bound
+	minExclusive, maxExclusive   *bound
+	hasLength, hasMinLength      bool
+	hasMaxLength, hasTotalDigits bool
+	hasFractionDigits            bool
+}
+
+type pattern struct {
+	source string
+	re     *regexp.Regexp
+}
+
+// bound is the value of a range facet, numbers are compared by value and the
+// other types, like dates, lexically
+type bound struct {
+	value  string
+	number float64
+}
+
+func newSimpleType(primitive string, ws whitespace, list *simpleType, union []*simpleType) *simpleType {
+	return &simpleType{primitive: primitive, whitespace: ws, list: list, union: union}
+}
+
+// builtins maps the built-in types to their primitive type
+var builtins = map[string]string{
+	"anySimpleType": "anySimpleType",
+	"string":        "string", "normalizedString": "string", "token": "string",
+	"language": "string", "Name": "Name", "NCName": "NCName", "NMTOKEN": "NMTOKEN",
+	"ID": "NCName", "IDREF": "NCName", "ENTITY": "NCName",
+	"anyURI": "string", "QName": "string", "NOTATION": "string",
+	"boolean": "boolean",
+	"decimal": "decimal", "integer": "integer",
+	"nonPositiveInteger": "integer", "negativeInteger": "integer",
+	"nonNegativeInteger": "integer", "positiveInteger": "integer",
+	"long": "integer", "int": "integer", "short": "integer", "byte": "integer",
+	"unsignedLong": "integer", "unsignedInt": "integer", "unsignedShort": "integer", "unsignedByte": "integer",
+	"float": "float", "double": "float",
+	"date": "date", "dateTime": "dateTime", "time": "time",
+	"duration": "duration", "gYear": "string", "gYearMonth": "string",
+	"gMonth": "string", "gMonthDay": "string", "gDay": "string",
+	"base64Binary": "base64Binary", "hexBinary": "hexBinary",
+}
+
+// builtinLists are the built-in list types and their item type
+var builtinLists = map[string]string{
+	"NMTOKENS": "NMTOKEN",
+	"IDREFS":   "IDREF",
+	"ENTITIES": "ENTITY",
+}
+
+// integerRanges are the bounds of the built-in integer types
+var integerRanges = map[string][2]string{
+	"nonPositiveInteger": {"", "0"},
+	"negativeInteger":    {"", "-1"},
+	"nonNegativeInteger": {"0", ""},
+	"positiveInteger":    {"1", ""},
+	"long":               {"-9223372036854775808", "9223372036854775807"},
+	"int":                {"-2147483648", "2147483647"},
+	"short":              {"-32768", "32767"},
+	"byte":               {"-128", "127"},
+	"unsignedLong":       {"0", "18446744073709551615"},
+	"unsignedInt":        {"0", "4294967295"},
+	"unsignedShort":      {"0", "65535"},
+	"unsignedByte":       {"0", "255"},
+}
+
+// builtin returns the built-in type of the name, nil if there is none
+func builtin(name string) *simpleType {
+	if item, ok := builtinLists[name]; ok {
+		return newSimpleType("", collapse, builtin(item), nil)
+	}
+	primitive, ok := builtins[name]
+	if !ok {
+		return nil
+	}
+	ws := collapse
+	switch name {
+	case "string", "anySimpleType":
+		ws = preserve
+	case "normalizedString":
+		ws = replace
+	}
+	st := newSimpleType(primitive, ws, nil, nil)
+	if r, ok := integerRanges[name]; ok {
+		if r[0] != "" {
+			st.minInclusive = &bound{value: r[0]}
+			st.minInclusive.number, _ = strconv.ParseFloat(r[0], 64)
+		}
+		if r[1] != "" {
+			st.maxInclusive = &bound{value: r[1]}
+			st.maxInclusive.number, _ = strconv.ParseFloat(r[1], 64)
+		}
+	}
+	return st
+}
+
+// numeric reports whether the values of the type are compared as numbers
+func (st *simpleType) numeric() bool {
+	switch st.primitive {
+	case "decimal", "integer", "float":
+		return true
+	}
+	return false
+}
+
+// restrict returns the restriction of base with the facets of the node
+func (c *compiler) restrict(base *simpleType, n *node) (*simpleType, error) {
+	st := *base
+	st.patterns = slices.Clone(base.patterns)
+	var enumeration []string
+	var patterns []*pattern
+	for _, f := range n.schemaChildren() {
+		if f.name.Local == "simpleType" {
+			continue
+		}
+		value, ok := f.attr("value")
+		if !ok {
+			return nil, fmt.Errorf("xs:%s without value", f.name.Local)
+		}
+		var err error
+		switch f.name.Local {
+		case "enumeration":
+			enumeration = append(enumeration, normalize(value, st.whitespace))
+		case "pattern":
+			re, err := compilePattern(value)
+			if err != nil {
+				return nil, err
+			}
+			patterns = append(patterns, &pattern{source: value, re: re})
+		case "length":
+			st.length, err = facetInt(value)
+			st.hasLength = true
+		case "minLength":
+			st.minLength, err = facetInt(value)
+			st.hasMinLength = true
+		case "maxLength":
+			st.maxLength, err = facetInt(value)
+			st.hasMaxLength = true
+		case "totalDigits":
+			st.totalDigits, err = facetInt(value)
+			st.hasTotalDigits = true
+		case "fractionDigits":
+			st.fractionDigits, err = facetInt(value)
+			st.hasFractionDigits = true
+		case "minInclusive":
+			st.minInclusive, err = st.bound(value)
+		case "maxInclusive":
+			st.maxInclusive, err = st.bound(value)
+		case "minExclusive":
+			st.minExclusive, err = st.bound(value)
+		case "maxExclusive":
+			st.maxExclusive, err = st.bound(value)
+		case "whiteSpace":
+			switch value {
+			case "preserve":
+				st.whitespace = preserve
+			case "replace":
+				st.whitespace = replace
+			case "collapse":
+				st.whitespace = collapse
+			default:
+				err = fmt.Errorf("invalid whiteSpace %q", value)
+			}
+		case "assertion", "explicitTimezone":
+			// XSD 1.1 facets are not supported
+		default:
+			return nil, fmt.Errorf("unknown facet xs:%s", f.name.Local)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("xs:%s: %w", f.name.Local, err)
+		}
+	}
+	if enumeration != nil {
+		st.enumeration = enumeration
+	}
+	if patterns != nil {
+		st.patterns = append(st.patterns, patterns)
+	}
+	return &st, nil
+}
+
+func facetInt(value string) (int, error) {
+	i, err := strconv.Atoi(strings.TrimSpace(value))
+	if err != nil || i < 0 {
+		return 0, fmt.Errorf("invalid value %q", value)
+	}
+	return i, nil
+}
+
+func (st *simpleType) bound(value string) (*bound, error) {
+	b := &bound{value: strings.TrimSpace(value)}
+	if st.numeric() {
+		f, err := strconv.ParseFloat(b.value, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid value %q", value)
+		}
+		b.number = f
+	}
+	return b, nil
+}
+
+// compilePattern compiles a pattern facet, the patterns of XML Schema are
+// implicitly anchored
+func compilePattern(p string) (*regexp.Regexp, error) {
+	re, err := regexp.Compile("^(?:" + p + ")$")
+	if err != nil {
+		return nil, fmt.Errorf("invalid pattern %q: %w", p, err)
+	}
+	return re, nil
+}
+
+func normalize(value string, ws whitespace) string {
+	switch ws {
+	case replace:
+		return strings.Map(func(r rune) rune {
+			if r == '\t' || r == '\n' || r == '\r' {
+				return ' '
+			}
+			return r
+		}, value)
+	case collapse:
+		return strings.Join(strings.Fields(value), " ")
+	}
+	return value
+}
+
+// validate returns the reason the value is not valid, empty if it is
+func (st *simpleType) validate(value string) string {
+	v := normalize(value, st.whitespace)
+	length := utf8.RuneCountInString(v)
+	switch {
+	case st.list != nil:
+		items := strings.Fields(v)
+		for _, item := range items {
+			if msg := st.list.validate(item); msg != "" {
+				return msg
+			}
+		}
+		length = len(items)
+	case st.union != nil:
+		valid := false
+		for _, m := range st.union {
+			if m.validate(v) == "" {
+				valid = true
+				break
+			}
+		}
+		if !valid {
+			return fmt.Sprintf("value %q doesn't match any member type of the union", v)
+		}
+	default:
+		if msg := validatePrimitive(st.primitive, v); msg != "" {
+			return msg
+		}
+	}
+
+	if st.hasLength && length != st.length {
+		return fmt.Sprintf("value %q must have a length of %d", v, st.length)
+	}
+	if st.hasMinLength && length < st.minLength {
+		return fmt.Sprintf("value %q is shorter than %d", v, st.minLength)
+	}
+	if st.hasMaxLength && length > st.maxLength {
+		return fmt.Sprintf("value %q is longer than %d", v, st.maxLength)
+	}
+	if st.enumeration != nil && !slices.Contains(st.enumeration, v) {
+		return fmt.Sprintf("value %q is not one of the allowed values", v)
+	}
+	for _, step := range st.patterns {
+		if !slices.ContainsFunc(step, func(p *pattern) bool { return p.re.MatchString(v) }) {
+			return fmt.Sprintf("value %q doesn't match the pattern %q", v, step[0].source)
+		}
+	}
+	if msg := st.validateRange(v); msg != "" {
+		return msg
+	}
+	if st.hasTotalDigits || st.hasFractionDigits {
+		total, fraction := digits(v)
+		if st.hasTotalDigits && total > st.totalDigits {
+			return fmt.Sprintf("value %q has more than %d digits", v, st.totalDigits)
+		}
+		if st.hasFractionDigits && fraction > st.fractionDigits {
+			return fmt.Sprintf("value %q has more than %d fraction digits", v, st.fractionDigits)
+		}
+	}
+	return ""
+}
+
+func (st *simpleType) validateRange(v string) string {
+	if st.list != nil || st.union != nil {
+		return ""
+	}
+	cmp := func(b *bound) int {
+		if st.numeric() {
+			f, _ := strconv.ParseFloat(v, 64)
+			switch {
+			case f < b.number:
+				return -1
+			case f > b.number:
+				return 1
+			}
+			return 0
+		}
+		return strings.Compare(v, b.value)
+	}
+	switch {
+	case st.minInclusive != nil && cmp(st.minInclusive) < 0:
+		return fmt.Sprintf("value %s is less than the minimum %s", v, st.minInclusive.value)
+	case st.maxInclusive != nil && cmp(st.maxInclusive) > 0:
+		return fmt.Sprintf("value %s is greater than the maximum %s", v, st.maxInclusive.value)
+	case st.minExclusive != nil && cmp(st.minExclusive) <= 0:
+		return fmt.Sprintf("value %s must be greater than %s", v, st.minExclusive.value)
+	case st.maxExclusive != nil && cmp(st.maxExclusive) >= 0:
+		return fmt.Sprintf("value %s must be less than %s", v, st.maxExclusive.value)
+	}
+	return ""
+}
+
+// digits returns the number of significant digits and fraction digits of a
+// decimal
+func digits(v string) (int, int) {
+	v = strings.TrimLeft(v, "+-")
+	integer, fraction, _ := strings.Cut(v, ".")
+	integer = strings.TrimLeft(integer, "0")
+	fraction = strings.TrimRight(fraction, "0")
+	return len(integer) + len(fraction), len(fraction)
+}
+
+var (
+	decimalRegex  = regexp.MustCompile(`^[+-]?(\d+(\.\d*)?|\.\d+)$`)
+	integerRegex  = regexp.MustCompile(`^[+-]?\d+$`)
+	floatRegex    = regexp.MustCompile(`^([+-]?(\d+(\.\d*)?|\.\d+)([eE][+-]?\d+)?|[+-]?INF|NaN)$`)
+	nameRegex     = regexp.MustCompile(`^[\pL_:][\pL\pN._:\-]*$`)
+	ncNameRegex   = regexp.MustCompile(`^[\pL_][\pL\pN._\-]*$`)
+	nmtokenRegex  = regexp.MustCompile(`^[\pL\pN._:\-]+$`)
+	durationRegex = regexp.MustCompile(`^-?P(\d+Y)?(\d+M)?(\d+D)?(T(\d+H)?(\d+M)?(\d+(\.\d+)?S)?)?$`)
+	timezoneRegex = regexp.MustCompile(`(Z|[+-]\d{2}:\d{2})$`)
+)
+
+// validatePrimitive validates the lexical space of the primitive types
+func validatePrimitive(primitive, v string) string {
+	valid := true
+	switch primitive {
+	case "boolean":
+		valid = v == "true" || v == "false" || v == "1" || v == "0"
+	case "decimal":
+		valid = decimalRegex.MatchString(v)
+	case "integer":
+		valid = integerRegex.MatchString(v)
+	case "float":
+		valid = floatRegex.MatchString(v)
+	case "Name":
+		valid = nameRegex.MatchString(v)
+	case "NCName":
+		valid = ncNameRegex.MatchString(v)
+	case "NMTOKEN":
+		valid = nmtokenRegex.MatchString(v)
+	case "duration":
+		valid = durationRegex.MatchString(v) && v != "P" && v != "-P" && !strings.HasSuffix(v, "T")
+	case "date":
+		valid = validTime("2006-01-02", v)
+	case "dateTime":
+		valid = validTime("2006-01-02T15:04:05", v)
+	case "time":
+		valid = validTime("15:04:05", v)
+	case "base64Binary":
+		_, err := base64.StdEncoding.DecodeString(strings.Join(strings.Fields(v), ""))
+		valid = err == nil
+	case "hexBinary":
+		_, err := hex.DecodeString(v)
+		valid = err == nil
+	}
+	if !valid {
+		return fmt.Sprintf("value %q is not a valid %s", v, primitive)
+	}
+	return ""
+}
+
+// validTime validates a date or time with an optional timezone, the seconds
+// of the times can have a fraction
+func validTime(layout, v string) bool {
+	v = timezoneRegex.ReplaceAllString(v, "")
+	if layout != "2006-01-02" {
+		if s, fraction, ok := strings.Cut(v, "."); ok {
+			if fraction == "" || strings.Trim(fraction, "0123456789") != "" {
+				return false
+			}
+			v = s
+		}
+	}
+	_, err := time.Parse(layout, v)
+	return err == nil
+}
@@ -0,0 +1,349 @@
+// Copyright 2024 Juan Pablo Tosso and the OWASP Coraza contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package xmlschema
+
+import (
+	"fmt"
+	"strings"
+)
+
+// ValidateXML returns a *ValidationError if the document is not valid
+func (s *Schema) ValidateXML(data []byte) error {
+	root, err := parse(data, false)
+	return s.ValidateDocument(&Document{root: root, err: err})
+}
+
+// ValidateDocument returns a *ValidationError if the decoded document is not
+// valid
+func (s *Schema) ValidateDocument(d *Document) error {
+	if d.err != nil {
+		return &ValidationError{Message: "invalid XML: " + d.err.Error()}
+	}
+	root := d.root
+	e, ok := s.elements[root.name]
+	if !ok {
+		return &ValidationError{Message: fmt.Sprintf("no declaration for the root element %q", root.name.Local)}
+	}
+	if path, msg := s.validateElement(root, e, ""); msg != "" {
+		return &ValidationError{Path: path, Message: msg}
+	}
+	return nil
+}
+
+// validateElement returns the path of the first invalid element and the
+// reason it is invalid, or empty strings if the element is valid
+func (s *Schema) validateElement(n *node, e *element, parent string) (string, string) {
+	path := parent + "/" + n.name.Local
+	if e.abstract {
+		return path, "element is abstract"
+	}
+	t := e.typ
+	if msg := validateAttributes(n, t); msg != "" {
+		return path, msg
+	}
+	if isNil(n) {
+		if !e.nillable {
+			return path, "element is not nillable"
+		}
+		if len(n.children) > 0 || strings.TrimSpace(n.text) != "" {
+			return path, "nil element must be empty"
+		}
+		return "", ""
+	}
+	if t.simple != nil {
+		if len(n.children) > 0 {
+			return path, "element must not have child elements"
+		}
+		return path, t.simple.validate(n.text)
+	}
+	if !t.mixed && strings.TrimSpace(n.text) != "" {
+		return path, "text is not allowed in element content"
+	}
+	decls, msg := s.matchContent(t.content, n.children)
+	if msg != "" {
+		return path, msg
+	}
+	for i, child := range n.children {
+		decl := decls[i]
+		if decl == nil {
+			// the children matching a wildcard are validated when they are
+			// declared
+			if decl = s.elements[child.name]; decl == nil {
+				continue
+			}
+		}
+		if p, msg := s.validateElement(child, decl, path); msg != "" {
+			return p, msg
+		}
+	}
+	return "", ""
+}
+
+func isNil(n *node) bool {
+	for _, a := range n.attrs {
+		if a.Name.Space == xsiNS && a.Name.Local == "nil" {
+			v := strings.TrimSpace(a.Value)
+			return v == "true" || v == "1"
+		}
+	}
+	return false
+}
+
+func validateAttributes(n *node, t *typeDef) string {
+	seen := map[*attribute]bool{}
+	for _, a := range n.attrs {
+		if a.Name.Space == "xmlns" || a.Name.Space == "" && a.Name.Local == "xmlns" ||
+			a.Name.Space == xsiNS || a.Name.Space == xmlNS {
+			continue
+		}
+		var decl *attribute
+		for _, d := range t.attrs {
+			if d.name == a.Name {
+				decl = d
+				break
+			}
+		}
+		if decl == nil {
+			if t.anyAttr {
+				continue
+			}
+			return fmt.Sprintf("attribute %q is not allowed", a.Name.Local)
+		}
+		if msg := decl.typ.validate(a.Value); msg != "" {
+			return fmt.Sprintf("attribute %q: %s", a.Name.Local, msg)
+		}
+		seen[decl] = true
+	}
+	for _, d := range t.attrs {
+		if d.required && !seen[d] {
+			return fmt.Sprintf("missing required attribute %q", d.name.Local)
+		}
+	}
+	return ""
+}
+
+// assignment links the declarations matched by the children of an element,
+// the last one first
+type assignment struct {
+	decl *element
+	prev *assignment
+}
+
+// state is a position in the children of an element reached matching a
+// content model
+type state struct {
+	pos   int
+	decls *assignment
+}
+
+// maxStates bounds the states a content model examines per child element and
+// particle of the model, the models that would need more are too ambiguous to
+// be matched in linear time
+const maxStates = 64
+
+type matcher struct {
+	s        *Schema
+	children []*node
+	// furthest is the furthest position reached, to report the first
+	// unexpected element
+	furthest int
+	// seen marks the positions already kept by dedupe and advanced, a
+	// position is marked when it holds the current mark
+	seen []int
+	mark int
+	// budget is the number of states that can still be examined
+	budget int
+}
+
+// matchContent matches the children of an element against its content model,
+// it returns the declaration of each child, nil for the children matching a
+// wildcard
+func (s *Schema) matchContent(p *particle, children []*node) ([]*element, string) {
+	if p == nil {
+		if len(children) > 0 {
+			return nil, fmt.Sprintf("unexpected element %q", children[0].name.Local)
+		}
+		return nil, ""
+	}
+	m := &matcher{
+		s:        s,
+		children: children,
+		seen:     make([]int, len(children)+1),
+		budget:   maxStates * (len(children) + 1) * p.size(),
+	}
+	states := m.match(p, []state{{}})
+	if m.budget < 0 {
+		return nil, "content model is too ambiguous"
+	}
+	for _, st := range states {
+		if st.pos != len(children) {
+			continue
+		}
+		decls := make([]*element, len(children))
+		for i, a := len(children)-1, st.decls; i >= 0; i, a = i-1, a.prev {
+			decls[i] = a.decl
+		}
+		return decls, ""
+	}
+	if m.furthest < len(children) {
+		return nil, fmt.Sprintf("unexpected element %q", children[m.furthest].name.Local)
+	}
+	return nil, "missing required elements"
+}
+
+// match returns the states reached matching the particle with its
+// occurrences from the given states. The schemas are deterministic, so
+// there is at most one way to reach a position.
+func (m *matcher) match(p *particle, states []state) []state {
+	var result []state
+	if p.min == 0 {
+		result = append(result, states...)
+	}
+	current := states
+	for count := 1; p.max == unbounded || count <= p.max; count++ {
+		next := m.once(p, current)
+		if count > p.min {
+			// once the minimum is reached, stop repeating what doesn't
+			// consume elements
+			next = m.advanced(next, current)
+		}
+		if len(next) == 0 {
+			break
+		}
+		current = m.dedupe(next)
+		if count >= p.min {
+			result = append(result, current...)
+		}
+	}
+	return m.dedupe(result)
+}
+
+// once returns the states reached matching the particle once
+func (m *matcher) once(p *particle, states []state) []state {
+	if m.budget -= len(states); m.budget < 0 {
+		return nil
+	}
+	var next []state
+	switch p.kind {
+	case particleElement, particleAny:
+		for _, st := range states {
+			if st.pos >= len(m.children) {
+				continue
+			}
+			var decl *element
+			if p.kind == particleElement {
+				if decl = m.s.matches(m.children[st.pos], p.elem); decl == nil {
+					continue
+				}
+			} else if !p.allows(m.children[st.pos].name) {
+				continue
+			}
+			next = append(next, m.reach(state{pos: st.pos + 1, decls: &assignment{decl: decl, prev: st.decls}}))
+		}
+	case particleSequence:
+		next = states
+		for _, child := range p.children {
+			if next = m.match(child, next); len(next) == 0 {
+				break
+			}
+		}
+	case particleChoice:
+		for _, child := range p.children {
+			next = append(next, m.match(child, states)...)
+		}
+	case particleAll:
+		for _, st := range states {
+			if st, ok := m.all(p, st); ok {
+				next = append(next, st)
+			}
+		}
+	}
+	return m.dedupe(next)
+}
+
+// all matches the elements of an all model, in any order
+func (m *matcher) all(p *particle, st state) (state, bool) {
+	used := make([]bool, len(p.children))
+	for st.pos < len(m.children) {
+		found := false
+		for i, child := range p.children {
+			if used[i] {
+				continue
+			}
+			if decl := m.s.matches(m.children[st.pos], child.elem); decl != nil {
+				used[i], found = true, true
+				st = state{pos: st.pos + 1, decls: &assignment{decl: decl, prev: st.decls}}
+				break
+			}
+		}
+		if !found {
+			break
+		}
+	}
+	m.reach(st)
+	for i, child := range p.children {
+		if !used[i] && child.min > 0 {
+			return state{}, false
+		}
+	}
+	return st, true
+}
+
+func (m *matcher) reach(st state) state {
+	if st.pos > m.furthest {
+		m.furthest = st.pos
+	}
+	return st
+}
+
+// matches returns the declaration matching the element, e itself or a
+// member of its substitution group
+func (s *Schema) matches(n *node, e *element) *element {
+	if n.name == e.name && !e.abstract {
+		return e
+	}
+	for _, sub := range s.substitutes[e] {
+		if decl := s.matches(n, sub); decl != nil {
+			return decl
+		}
+	}
+	return nil
+}
+
+// size returns the number of particles of the model
+func (p *particle) size() int {
+	n := 1
+	for _, child := range p.children {
+		n += child.size()
+	}
+	return n
+}
+
+// advanced removes the states that didn't consume elements
+func (m *matcher) advanced(next, previous []state) []state {
+	m.mark++
+	for _, st := range previous {
+		m.seen[st.pos] = m.mark
+	}
+	var res []state
+	for _, st := range next {
+		if m.seen[st.pos] != m.mark {
+			res = append(res, st)
+		}
+	}
+	return res
+}
+
+// dedupe keeps the first state reaching each position
+func (m *matcher) dedupe(states []state) []state {
+	m.mark++
+	var res []state
+	for _, st := range states {
+		if m.seen[st.pos] != m.mark {
+			m.seen[st.pos] = m.mark
+			res = append(res, st)
+		}
+	}
+	return res
+}
@@ -0,0 +1,310 @@
+// Copyright 2024 Juan Pablo Tosso and the OWASP Coraza contributors
+// SPDX-License-Identifier: Apache-2.0
+
+// Package xmlschema validates XML documents against an XML Schema (XSD). It
+// supports the constructs used to describe SOAP and XML API payloads: global and
+// local element declarations, element references and substitution groups, named
+// and anonymous complex and simple types, sequence, choice and all models with
+// occurrence bounds, model and attribute groups, wildcards, simple and complex
+// content derivation, xsi:nil and the facets of the built-in types. Schemas
+// spanning several documents, with xs:include, xs:import or xs:redefine, are not
+// supported, and identity constraints like xs:key are ignored.
+package xmlschema
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+const (
+	xsdNS = "http://www.w3.org/2001/XMLSchema"
+	xsiNS = "http://www.w3.org/2001/XMLSchema-instance"
+	xmlNS = "http://www.w3.org/XML/1998/namespace"
+)
+
+// unbounded is the maximum number of occurrences of maxOccurs="unbounded"
+const unbounded = -1
+
+// Schema is a compiled XML Schema, it is safe for concurrent use
+type Schema struct {
+	elements map[xml.Name]*element
+	// substitutes lists the members of the substitution group of an element
+	substitutes map[*element][]*element
+}
+
+// ValidationError is the first error found validating a document
+type ValidationError struct {
+	// Path is the path of the invalid element, e.g. /order/item, empty for the
+	// document
+	Path    string
+	Message string
+}
+
+func (e *ValidationError) Error() string {
+	if e.Path == "" {
+		return e.Message
+	}
+	return e.Path + ": " + e.Message
+}
+
+type element struct {
+	name     xml.Name
+	typ      *typeDef
+	nillable bool
+	abstract bool
+}
+
+// typeDef is a simple type, or a complex type with simple content when
+// simple is set along with the attributes
+type typeDef struct {
+	simple *simpleType
+
+	mixed   bool
+	content *particle
+	attrs   []*attribute
+	anyAttr bool
+
+	// filled is false while a named complex type is being compiled, it can be
+	// referenced by its own content
+	filled bool
+}
+
+type attribute struct {
+	name       xml.Name
+	typ        *simpleType
+	required   bool
+	prohibited bool
+}
+
+type particleKind int
+
+const (
+	particleElement particleKind = iota
+	particleAny
+	particleSequence
+	particleChoice
+	particleAll
+)
+
+type particle struct {
+	kind     particleKind
+	elem     *element
+	children []*particle
+	min, max int
+	// namespaces lists the namespaces allowed by a wildcard, all of them when
+	// nil, otherNS excludes the target namespace and the unqualified names
+	namespaces []string
+	otherNS    string
+	other      bool
+}
+
+// allows reports whether the wildcard allows the element
+func (p *particle) allows(name xml.Name) bool {
+	if p.other {
+		return name.Space != p.otherNS && name.Space != ""
+	}
+	return p.namespaces == nil || slices.Contains(p.namespaces, name.Space)
+}
+
+// anyType accepts any attribute and content, the children are validated when
+// they are declared globally
+var anyType = &typeDef{
+	mixed:   true,
+	content: &particle{kind: particleAny, min: 0, max: unbounded},
+	anyAttr: true,
+	filled:  true,
+}
+
+// node is an element of a parsed document
+type node struct {
+	name     xml.Name
+	attrs    []xml.Attr
+	children []*node
+	text     string
+	// ns maps the prefixes in scope to their namespace, it is only kept for
+	// the schema documents to resolve the qualified names of the attributes
+	ns map[string]string
+}
+
+func (n *node) attr(name string) (string, bool) {
+	for _, a := range n.attrs {
+		if a.Name.Space == "" && a.Name.Local == name {
+			return a.Value, true
+		}
+	}
+	return "", false
+}
+
+// schemaChildren returns the children of a schema node in the XML Schema
+// namespace, skipping the annotations
+func (n *node) schemaChildren() []*node {
+	var children []*node
+	for _, c := range n.children {
+		if c.name.Space == xsdNS && c.name.Local != "annotation" {
+			children = append(children, c)
+		}
+	}
+	return children
+}
+
+func (n *node) child(local string) *node {
+	for _, c := range n.schemaChildren() {
+		if c.name.Local == local {
+			return c
+		}
+	}
+	return nil
+}
+
+// parse parses a document, keeping the namespaces in scope if withNS is set
+func parse(data []byte, withNS bool) (*node, error) {
+	dec := xml.NewDecoder(bytes.NewReader(data))
+	b := &DocumentBuilder{withNS: withNS}
+	for {
+		tok, err := dec.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		b.Token(tok)
+	}
+	d := b.Document()
+	return d.root, d.err
+}
+
+// Document is a decoded XML document, it can be validated against several
+// schemas without being decoded again
+type Document struct {
+	root *node
+	err  error
+}
+
+// DocumentBuilder builds a Document from the tokens of a decoder, e.g. the
+// one of the XML body processor. The zero value is ready to use.
+type DocumentBuilder struct {
+	withNS bool
+	root   *node
+	stack  []*node
+	text   []*strings.Builder
+	err    error
+}
+
+// Token adds the token to the document
+func (b *DocumentBuilder) Token(tok xml.Token) {
+	if b.err != nil {
+		return
+	}
+	switch tok := tok.(type) {
+	case xml.StartElement:
+		n := &node{name: tok.Name, attrs: tok.Attr}
+		if b.withNS {
+			n.ns = namespaces(tok.Attr, b.stack)
+		}
+		if len(b.stack) > 0 {
+			parent := b.stack[len(b.stack)-1]
+			parent.children = append(parent.children, n)
+		} else if b.root == nil {
+			b.root = n
+		} else {
+			b.err = errors.New("multiple root elements")
+			return
+		}
+		b.stack = append(b.stack, n)
+		b.text = append(b.text, &strings.Builder{})
+	case xml.EndElement:
+		if len(b.stack) > 0 {
+			b.closeElement()
+		}
+	case xml.CharData:
+		if len(b.text) > 0 {
+			b.text[len(b.text)-1].Write(tok)
+		}
+	}
+}
+
+// Fail records the error that stopped the decoding of the document, it is
+// reported by the validation
+func (b *DocumentBuilder) Fail(err error) {
+	if b.err == nil {
+		b.err = err
+	}
+}
+
+// Document returns the document built from the tokens, the elements still
+// open are closed
+func (b *DocumentBuilder) Document() *Document {
+	for len(b.stack) > 0 {
+		b.closeElement()
+	}
+	if b.err == nil && b.root == nil {
+		b.err = errors.New("missing root element")
+	}
+	return &Document{root: b.root, err: b.err}
+}
+
+func (b *DocumentBuilder) closeElement() {
+	b.stack[len(b.stack)-1].text = b.text[len(b.text)-1].String()
+	b.stack = b.stack[:len(b.stack)-1]
+	b.text = b.text[:len(b.text)-1]
+}
+
+func namespaces(attrs []xml.Attr, stack []*node) map[string]string {
+	var ns map[string]string
+	if len(stack) > 0 {
+		ns = stack[len(stack)-1].ns
+	}
+	copied := false
+	for _, a := range attrs {
+		var prefix string
+		switch {
+		case a.Name.Space == "xmlns":
+			prefix = a.Name.Local
+		case a.Name.Space == "" && a.Name.Local == "xmlns":
+		default:
+			continue
+		}
+		if !copied {
+			scope := make(map[string]string, len(ns)+1)
+			for k, v := range ns {
+				scope[k] = v
+			}
+			ns, copied = scope, true
+		}
+		ns[prefix] = a.Value
+	}
+	return ns
+}
+
+func occurs(n *node) (int, int, error) {
+	min, max := 1, 1
+	if v, ok := n.attr("minOccurs"); ok {
+		i, err := strconv.Atoi(strings.TrimSpace(v))
+		if err != nil || i < 0 {
+			return 0, 0, fmt.Errorf("invalid minOccurs %q", v)
+		}
+		min = i
+	}
+	if v, ok := n.attr("maxOccurs"); ok {
+		if v = strings.TrimSpace(v); v == "unbounded" {
+			max = unbounded
+		} else {
+			i, err := strconv.Atoi(v)
+			if err != nil || i < 0 {
+				return 0, 0, fmt.Errorf("invalid maxOccurs %q", v)
+			}
+			max = i
+		}
+	}
+	if max != unbounded && max < min {
+		return 0, 0, fmt.Errorf("maxOccurs %d is less than minOccurs %d", max, min)
+	}
+	return min, max, nil
+}
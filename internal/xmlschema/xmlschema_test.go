@@ -0,0 +1,310 @@
+// Copyright 2024 Juan Pablo Tosso and the OWASP Coraza contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package xmlschema
+
+import (
+	"encoding/xml"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+)
+
+const orderSchema = `<?xml version="1.0"?>
+<xs:schema xmlns:xs="http://www.w3.org/2001/XMLSchema"
+	xmlns:tns="urn:shop" targetNamespace="urn:shop" elementFormDefault="qualified">
+	<xs:element name="order" type="tns:Order"/>
+	<xs:element name="note" type="xs:string" abstract="true"/>
+	<xs:element name="gift" type="xs:string" substitutionGroup="tns:note"/>
+	<xs:complexType name="Order">
+		<xs:sequence>
+			<xs:element name="customer">
+				<xs:complexType>
+					<xs:all>
+						<xs:element name="name" type="tns:Name"/>
+						<xs:element name="email" type="xs:string" minOccurs="0"/>
+					</xs:all>
+				</xs:complexType>
+			</xs:element>
+			<xs:element name="item" type="tns:Item" maxOccurs="unbounded"/>
+			<xs:choice minOccurs="0">
+				<xs:element name="coupon" type="tns:Code"/>
+				<xs:element name="discount" type="xs:decimal" nillable="true"/>
+			</xs:choice>
+			<xs:element ref="tns:note" minOccurs="0"/>
+			<xs:any namespace="##other" processContents="lax" minOccurs="0"/>
+		</xs:sequence>
+		<xs:attribute name="id" type="xs:positiveInteger" use="required"/>
+		<xs:attribute name="date" type="xs:date"/>
+	</xs:complexType>
+	<xs:complexType name="Item">
+		<xs:simpleContent>
+			<xs:extension base="xs:string">
+				<xs:attributeGroup ref="tns:quantity"/>
+			</xs:extension>
+		</xs:simpleContent>
+	</xs:complexType>
+	<xs:attributeGroup name="quantity">
+		<xs:attribute name="qty">
+			<xs:simpleType>
+				<xs:restriction base="xs:int">
+					<xs:minInclusive value="1"/>
+					<xs:maxExclusive value="100"/>
+				</xs:restriction>
+			</xs:simpleType>
+		</xs:attribute>
+	</xs:attributeGroup>
+	<xs:simpleType name="Name">
+		<xs:restriction base="xs:token">
+			<xs:minLength value="2"/>
+			<xs:maxLength value="10"/>
+		</xs:restriction>
+	</xs:simpleType>
+	<xs:simpleType name="Code">
+		<xs:restriction base="xs:string">
+			<xs:pattern value="[A-Z]{3}\d{2}"/>
+		</xs:restriction>
+	</xs:simpleType>
+</xs:schema>`
+
+func TestValidate(t *testing.T) {
+	schema, err := Compile([]byte(orderSchema))
+	if err != nil {
+		t.Fatal(err)
+	}
+	order := func(attrs, content string) string {
+		return `<order xmlns="urn:shop" xmlns:xsi="http://www.w3.org/2001/XMLSchema-instance" ` + attrs + `>` + content + `</order>`
+	}
+	customer := `<customer><name>ann</name></customer>`
+	tests := []struct {
+		doc  string
+		want string
+	}{
+		{order(`id="1"`, customer+`<item>book</item>`), ""},
+		{order(`id="1" date="2024-02-29"`, `<customer><email>a@b</email><name> ann </name></customer><item qty="2">book</item><item>pen</item><coupon>ABC12</coupon>`), ""},
+		{order(`id="1"`, customer+`<item>book</item><discount xsi:nil="true"/><gift>card</gift>`), ""},
+		{order(`id="1"`, customer+`<item>book</item><x:ext xmlns:x="urn:other"><anything/></x:ext>`), ""},
+		{order(`id="1"`, customer+`<item>book</item><discount>1.5</discount>`), ""},
+		{`<order id="1"/>`, `no declaration for the root element "order"`},
+		{order(``, customer+`<item>book</item>`), `/order: missing required attribute "id"`},
+		{order(`id="0"`, customer+`<item>book</item>`), `/order: attribute "id": value 0 is less than the minimum 1`},
+		{order(`id="1" date="2024-02-30"`, customer+`<item>book</item>`), `/order: attribute "date": value "2024-02-30" is not a valid date`},
+		{order(`id="1" status="new"`, customer+`<item>book</item>`), `/order: attribute "status" is not allowed`},
+		{order(`id="1"`, customer), `/order: missing required elements`},
+		{order(`id="1"`, `<item>book</item>`), `/order: unexpected element "item"`},
+		{order(`id="1"`, customer+`<item>book</item><coupon>ABC12</coupon><discount>1</discount>`), `/order: unexpected element "discount"`},
+		{order(`id="1"`, customer+`<item>book</item>text`), `/order: text is not allowed in element content`},
+		{order(`id="1"`, `<customer><email>a@b</email></customer><item>book</item>`), `/order/customer: missing required elements`},
+		{order(`id="1"`, `<customer><name>a</name></customer><item>book</item>`), `/order/customer/name: value "a" is shorter than 2`},
+		{order(`id="1"`, customer+`<item qty="100">book</item>`), `/order/item: attribute "qty": value 100 must be less than 100`},
+		{order(`id="1"`, customer+`<item qty="x">book</item>`), `/order/item: attribute "qty": value "x" is not a valid integer`},
+		{order(`id="1"`, customer+`<item><b>book</b></item>`), `/order/item: element must not have child elements`},
+		{order(`id="1"`, customer+`<item>book</item><coupon>abc12</coupon>`), `/order/coupon: value "abc12" doesn't match the pattern "[A-Z]{3}\\d{2}"`},
+		{order(`id="1"`, customer+`<item>book</item><coupon xsi:nil="true"/>`), `/order/coupon: element is not nillable`},
+		{order(`id="1"`, customer+`<item>book</item><discount xsi:nil="true">1</discount>`), `/order/discount: nil element must be empty`},
+		{order(`id="1"`, customer+`<item>book</item><note>hi</note>`), `/order: unexpected element "note"`},
+		{`<order xmlns="urn:other" id="1"/>`, `no declaration for the root element "order"`},
+		{`<order xmlns="urn:shop" id="1">`, "invalid XML: XML syntax error on line 1: unexpected EOF"},
+	}
+	for _, tt := range tests {
+		err := schema.ValidateXML([]byte(tt.doc))
+		have := ""
+		if err != nil {
+			have = err.Error()
+		}
+		if tt.want != have {
+			t.Errorf("unexpected result for %s, want %q, have %q", tt.doc, tt.want, have)
+		}
+		var verr *ValidationError
+		if err != nil && !errors.As(err, &verr) {
+			t.Errorf("expected a validation error, have %T", err)
+		}
+	}
+}
+
+func TestValidateTypes(t *testing.T) {
+	const schemaTemplate = `<xs:schema xmlns:xs="http://www.w3.org/2001/XMLSchema">
+		<xs:element name="v" type="%s"/>
+	</xs:schema>`
+	tests := []struct {
+		typ   string
+		value string
+		valid bool
+	}{
+		{"xs:boolean", "true", true},
+		{"xs:boolean", "yes", false},
+		{"xs:byte", "127", true},
+		{"xs:byte", "128", false},
+		{"xs:unsignedShort", "-1", false},
+		{"xs:negativeInteger", "-1", true},
+		{"xs:negativeInteger", "0", false},
+		{"xs:decimal", "-1.50", true},
+		{"xs:decimal", "1e3", false},
+		{"xs:double", "1e3", true},
+		{"xs:double", "INF", true},
+		{"xs:double", "0x10", false},
+		{"xs:dateTime", "2024-01-02T03:04:05.123Z", true},
+		{"xs:dateTime", "2024-01-02 03:04:05", false},
+		{"xs:time", "23:59:59+01:00", true},
+		{"xs:duration", "P1DT2H", true},
+		{"xs:duration", "PT", false},
+		{"xs:base64Binary", "aGVsbG8=", true},
+		{"xs:base64Binary", "aGVsbG8", false},
+		{"xs:hexBinary", "0aFF", true},
+		{"xs:hexBinary", "0aF", false},
+		{"xs:NCName", "a-b", true},
+		{"xs:NCName", "a:b", false},
+		{"xs:NMTOKENS", "a b c", true},
+		{"xs:NMTOKENS", "a b ?", false},
+	}
+	for _, tt := range tests {
+		schema, err := Compile([]byte(fmtSchema(schemaTemplate, tt.typ)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = schema.ValidateXML([]byte("<v>" + tt.value + "</v>"))
+		if want, have := tt.valid, err == nil; want != have {
+			t.Errorf("unexpected result for %s %q, want %t, have %t (%v)", tt.typ, tt.value, want, have, err)
+		}
+	}
+}
+
+func TestValidateDerivation(t *testing.T) {
+	schema, err := Compile([]byte(`<xs:schema xmlns:xs="http://www.w3.org/2001/XMLSchema">
+		<xs:element name="node" type="Node"/>
+		<xs:complexType name="Base">
+			<xs:sequence>
+				<xs:element name="id" type="xs:int"/>
+			</xs:sequence>
+			<xs:attribute name="kind" type="xs:string"/>
+		</xs:complexType>
+		<xs:complexType name="Node">
+			<xs:complexContent>
+				<xs:extension base="Base">
+					<xs:sequence>
+						<xs:group ref="Children"/>
+					</xs:sequence>
+				</xs:extension>
+			</xs:complexContent>
+		</xs:complexType>
+		<xs:group name="Children">
+			<xs:sequence>
+				<xs:element name="node" type="Node" minOccurs="0" maxOccurs="2"/>
+			</xs:sequence>
+		</xs:group>
+	</xs:schema>`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		doc  string
+		want string
+	}{
+		{`<node kind="root"><id>1</id><node><id>2</id></node><node><id>3</id><node><id>4</id></node></node></node>`, ""},
+		{`<node><node><id>2</id></node></node>`, `/node: unexpected element "node"`},
+		{`<node><id>1</id><node><id>2</id></node><node><id>3</id></node><node><id>4</id></node></node>`, `/node: unexpected element "node"`},
+		{`<node><id>1</id><node><id>x</id></node></node>`, `/node/node/id: value "x" is not a valid integer`},
+	}
+	for _, tt := range tests {
+		have := ""
+		if err := schema.ValidateXML([]byte(tt.doc)); err != nil {
+			have = err.Error()
+		}
+		if tt.want != have {
+			t.Errorf("unexpected result for %s, want %q, have %q", tt.doc, tt.want, have)
+		}
+	}
+}
+
+func TestCompileErrors(t *testing.T) {
+	for _, schema := range []string{
+		`<xs:schema`,
+		`<schema/>`,
+		`<xs:schema xmlns:xs="http://www.w3.org/2001/XMLSchema"><xs:include schemaLocation="a.xsd"/></xs:schema>`,
+		`<xs:schema xmlns:xs="http://www.w3.org/2001/XMLSchema"><xs:element name="a" type="Missing"/></xs:schema>`,
+		`<xs:schema xmlns:xs="http://www.w3.org/2001/XMLSchema"><xs:element name="a"/><xs:element name="a"/></xs:schema>`,
+		`<xs:schema xmlns:xs="http://www.w3.org/2001/XMLSchema"><xs:simpleType name="a"><xs:restriction base="xs:string"><xs:pattern value="("/></xs:restriction></xs:simpleType></xs:schema>`,
+		`<xs:schema xmlns:xs="http://www.w3.org/2001/XMLSchema"><xs:simpleType name="a"><xs:restriction base="b"/></xs:simpleType><xs:simpleType name="b"><xs:restriction base="a"/></xs:simpleType></xs:schema>`,
+		`<xs:schema xmlns:xs="http://www.w3.org/2001/XMLSchema"><xs:complexType name="a"><xs:sequence><xs:element name="b" maxOccurs="x"/></xs:sequence></xs:complexType></xs:schema>`,
+	} {
+		if _, err := Compile([]byte(schema)); err == nil {
+			t.Errorf("expected error compiling %s", schema)
+		}
+	}
+}
+
+func TestValidateManyChildren(t *testing.T) {
+	schema, err := Compile([]byte(`<xs:schema xmlns:xs="http://www.w3.org/2001/XMLSchema">
+		<xs:element name="list">
+			<xs:complexType>
+				<xs:sequence maxOccurs="unbounded">
+					<xs:element name="a" minOccurs="0"/>
+					<xs:element name="b" minOccurs="0" maxOccurs="unbounded"/>
+				</xs:sequence>
+			</xs:complexType>
+		</xs:element>
+	</xs:schema>`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	children := strings.Repeat("<a/><b/><b/>", 20000)
+	tests := []struct {
+		doc  string
+		want string
+	}{
+		{"<list>" + children + "</list>", ""},
+		{"<list>" + children + "<c/></list>", `/list: unexpected element "c"`},
+	}
+	for _, tt := range tests {
+		have := ""
+		if err := schema.ValidateXML([]byte(tt.doc)); err != nil {
+			have = err.Error()
+		}
+		if tt.want != have {
+			t.Errorf("unexpected result, want %q, have %q", tt.want, have)
+		}
+	}
+}
+
+func TestValidateDocument(t *testing.T) {
+	schema, err := Compile([]byte(orderSchema))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		doc  string
+		want string
+	}{
+		{`<order xmlns="urn:shop" id="1"><customer><name>ann</name></customer><item>book</item></order>`, ""},
+		{`<order xmlns="urn:shop" id="1"><customer><name>ann</name></customer></order>`, `/order: missing required elements`},
+		{`<order xmlns="urn:shop" id="1"><customer>`, "invalid XML: XML syntax error on line 1: unexpected EOF"},
+		{`text`, "invalid XML: missing root element"},
+	}
+	for _, tt := range tests {
+		var b DocumentBuilder
+		dec := xml.NewDecoder(strings.NewReader(tt.doc))
+		dec.Strict = false
+		for {
+			tok, err := dec.Token()
+			if err != nil {
+				if err != io.EOF {
+					b.Fail(err)
+				}
+				break
+			}
+			b.Token(tok)
+		}
+		have := ""
+		if err := schema.ValidateDocument(b.Document()); err != nil {
+			have = err.Error()
+		}
+		if tt.want != have {
+			t.Errorf("unexpected result for %s, want %q, have %q", tt.doc, tt.want, have)
+		}
+	}
+}
+
+func fmtSchema(template, typ string) string {
+	return strings.Replace(template, "%s", typ, 1)
+}
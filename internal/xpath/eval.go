@@ -0,0 +1,479 @@
+// Copyright 2024 Juan Pablo Tosso and the OWASP Coraza contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package xpath
+
+import (
+	"math"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// value is a nodeSet, a string, a float64 or a bool
+type value any
+
+// nodeSet is a set of nodes in document order
+type nodeSet []*node
+
+// context is the context of the evaluation of an expression
+type context struct {
+	node      *node
+	pos, size int
+}
+
+type expr interface {
+	eval(c *context) value
+}
+
+type numberExpr float64
+
+func (e numberExpr) eval(*context) value {
+	return float64(e)
+}
+
+type stringExpr string
+
+func (e stringExpr) eval(*context) value {
+	return string(e)
+}
+
+type negateExpr struct {
+	e expr
+}
+
+func (e *negateExpr) eval(c *context) value {
+	return -toNumber(e.e.eval(c))
+}
+
+type binaryExpr struct {
+	op          string
+	left, right expr
+}
+
+func (e *binaryExpr) eval(c *context) value {
+	switch e.op {
+	case "or":
+		return toBool(e.left.eval(c)) || toBool(e.right.eval(c))
+	case "and":
+		return toBool(e.left.eval(c)) && toBool(e.right.eval(c))
+	case "=", "!=", "<", "<=", ">", ">=":
+		return compare(e.op, e.left.eval(c), e.right.eval(c))
+	case "|":
+		left, _ := e.left.eval(c).(nodeSet)
+		right, _ := e.right.eval(c).(nodeSet)
+		return union(append(slices.Clone(left), right...))
+	}
+	l, r := toNumber(e.left.eval(c)), toNumber(e.right.eval(c))
+	switch e.op {
+	case "+":
+		return l + r
+	case "-":
+		return l - r
+	case "*":
+		return l * r
+	case "div":
+		return l / r
+	}
+	return math.Mod(l, r)
+}
+
+// compare compares two values following the rules of XPath 1.0: the
+// node-sets are equal to a value if any of their nodes is
+func compare(op string, left, right value) bool {
+	if set, ok := left.(nodeSet); ok {
+		if _, ok := right.(bool); ok {
+			return compareAtoms(op, toBool(left), right)
+		}
+		for _, n := range set {
+			if compare(op, n.stringValue(), right) {
+				return true
+			}
+		}
+		return false
+	}
+	if set, ok := right.(nodeSet); ok {
+		if _, ok := left.(bool); ok {
+			return compareAtoms(op, left, toBool(right))
+		}
+		for _, n := range set {
+			if compare(op, left, n.stringValue()) {
+				return true
+			}
+		}
+		return false
+	}
+	return compareAtoms(op, left, right)
+}
+
+func compareAtoms(op string, left, right value) bool {
+	if op == "=" || op == "!=" {
+		var equal bool
+		_, lb := left.(bool)
+		_, rb := right.(bool)
+		_, ln := left.(float64)
+		_, rn := right.(float64)
+		switch {
+		case lb || rb:
+			equal = toBool(left) == toBool(right)
+		case ln || rn:
+			equal = toNumber(left) == toNumber(right)
+		default:
+			equal = toString(left) == toString(right)
+		}
+		return equal == (op == "=")
+	}
+	l, r := toNumber(left), toNumber(right)
+	switch op {
+	case "<":
+		return l < r
+	case "<=":
+		return l <= r
+	case ">":
+		return l > r
+	}
+	return l >= r
+}
+
+// union returns the nodes in document order without duplicates
+func union(nodes nodeSet) nodeSet {
+	slices.SortFunc(nodes, func(a, b *node) int { return a.order - b.order })
+	return slices.Compact(nodes)
+}
+
+type filterExpr struct {
+	e          expr
+	predicates []expr
+}
+
+func (e *filterExpr) eval(c *context) value {
+	set, ok := e.e.eval(c).(nodeSet)
+	if !ok {
+		return nodeSet(nil)
+	}
+	return filter(set, e.predicates)
+}
+
+// filter applies the predicates to the nodes, in the order of their axis
+func filter(nodes nodeSet, predicates []expr) nodeSet {
+	for _, pred := range predicates {
+		var kept nodeSet
+		for i, n := range nodes {
+			c := &context{node: n, pos: i + 1, size: len(nodes)}
+			v := pred.eval(c)
+			if f, ok := v.(float64); ok {
+				if f == float64(c.pos) {
+					kept = append(kept, n)
+				}
+			} else if toBool(v) {
+				kept = append(kept, n)
+			}
+		}
+		nodes = kept
+	}
+	return nodes
+}
+
+// pathExpr is a location path, relative to the context node, the root if
+// absolute or the node-set of start
+type pathExpr struct {
+	absolute bool
+	start    expr
+	steps    []*step
+}
+
+func (e *pathExpr) eval(c *context) value {
+	var nodes nodeSet
+	switch {
+	case e.start != nil:
+		set, ok := e.start.eval(c).(nodeSet)
+		if !ok {
+			return nodeSet(nil)
+		}
+		nodes = set
+	case e.absolute:
+		root := c.node
+		for root.parent != nil {
+			root = root.parent
+		}
+		nodes = nodeSet{root}
+	default:
+		nodes = nodeSet{c.node}
+	}
+	for _, s := range e.steps {
+		var next nodeSet
+		for _, n := range nodes {
+			next = append(next, s.apply(n)...)
+		}
+		nodes = union(next)
+		if len(nodes) == 0 {
+			break
+		}
+	}
+	return nodes
+}
+
+type axis int
+
+const (
+	axisChild axis = iota
+	axisDescendant
+	axisDescendantOrSelf
+	axisSelf
+	axisParent
+	axisAncestor
+	axisAncestorOrSelf
+	axisFollowingSibling
+	axisPrecedingSibling
+	axisFollowing
+	axisPreceding
+	axisAttribute
+)
+
+var axes = map[string]axis{
+	"child":              axisChild,
+	"descendant":         axisDescendant,
+	"descendant-or-self": axisDescendantOrSelf,
+	"self":               axisSelf,
+	"parent":             axisParent,
+	"ancestor":           axisAncestor,
+	"ancestor-or-self":   axisAncestorOrSelf,
+	"following-sibling":  axisFollowingSibling,
+	"preceding-sibling":  axisPrecedingSibling,
+	"following":          axisFollowing,
+	"preceding":          axisPreceding,
+	"attribute":          axisAttribute,
+}
+
+type testKind int
+
+const (
+	testName testKind = iota
+	testNode
+	testText
+	testComment
+	testPI
+)
+
+// nodeTest selects the nodes of an axis by type, or by name among the
+// principal node type of the axis, the attributes for the attribute axis
+// and the elements otherwise
+type nodeTest struct {
+	kind     testKind
+	space    string
+	local    string
+	anySpace bool
+}
+
+func (t nodeTest) matches(n *node, principal nodeType) bool {
+	switch t.kind {
+	case testNode:
+		return true
+	case testText:
+		return n.typ == textNode
+	case testComment:
+		return n.typ == commentNode
+	case testPI:
+		return false
+	}
+	if n.typ != principal {
+		return false
+	}
+	if t.anySpace {
+		return true
+	}
+	return n.space == t.space && (t.local == "*" || n.local == t.local)
+}
+
+type step struct {
+	axis       axis
+	test       nodeTest
+	predicates []expr
+}
+
+// apply returns the nodes of the axis of n selected by the step
+func (s *step) apply(n *node) nodeSet {
+	principal := elementNode
+	if s.axis == axisAttribute {
+		principal = attributeNode
+	}
+	var nodes nodeSet
+	visit := func(c *node) {
+		if s.test.matches(c, principal) {
+			nodes = append(nodes, c)
+		}
+	}
+	switch s.axis {
+	case axisChild:
+		for _, c := range n.children {
+			visit(c)
+		}
+	case axisDescendant, axisDescendantOrSelf:
+		if s.axis == axisDescendantOrSelf {
+			visit(n)
+		}
+		walk(n, visit)
+	case axisSelf:
+		visit(n)
+	case axisParent:
+		if n.parent != nil {
+			visit(n.parent)
+		}
+	case axisAncestor, axisAncestorOrSelf:
+		if s.axis == axisAncestorOrSelf {
+			visit(n)
+		}
+		for p := n.parent; p != nil; p = p.parent {
+			visit(p)
+		}
+	case axisFollowingSibling, axisPrecedingSibling:
+		if n.parent == nil || n.typ == attributeNode {
+			break
+		}
+		siblings := n.parent.children
+		i := slices.Index(siblings, n)
+		if s.axis == axisFollowingSibling {
+			for _, c := range siblings[i+1:] {
+				visit(c)
+			}
+		} else {
+			for j := i - 1; j >= 0; j-- {
+				visit(siblings[j])
+			}
+		}
+	case axisFollowing:
+		// the nodes after n in document order, but its descendants
+		for c := n; c.parent != nil; c = c.parent {
+			if c.typ == attributeNode {
+				walk(c.parent, visit)
+				continue
+			}
+			siblings := c.parent.children
+			for _, sib := range siblings[slices.Index(siblings, c)+1:] {
+				visit(sib)
+				walk(sib, visit)
+			}
+		}
+	case axisPreceding:
+		// the nodes before n in document order, but its ancestors, in
+		// reverse document order
+		var preceding nodeSet
+		root := n
+		for root.parent != nil {
+			root = root.parent
+		}
+		walk(root, func(c *node) {
+			if c.order < n.order && !isAncestor(c, n) {
+				preceding = append(preceding, c)
+			}
+		})
+		for i := len(preceding) - 1; i >= 0; i-- {
+			visit(preceding[i])
+		}
+	case axisAttribute:
+		for _, a := range n.attrs {
+			visit(a)
+		}
+	}
+	return filter(nodes, s.predicates)
+}
+
+// walk calls fn with the descendants of n in document order
+func walk(n *node, fn func(*node)) {
+	for _, c := range n.children {
+		fn(c)
+		walk(c, fn)
+	}
+}
+
+func isAncestor(a, n *node) bool {
+	for p := n.parent; p != nil; p = p.parent {
+		if p == a {
+			return true
+		}
+	}
+	return false
+}
+
+func toBool(v value) bool {
+	switch v := v.(type) {
+	case nodeSet:
+		return len(v) > 0
+	case string:
+		return v != ""
+	case float64:
+		return v != 0 && !math.IsNaN(v)
+	case bool:
+		return v
+	}
+	return false
+}
+
+func toString(v value) string {
+	switch v := v.(type) {
+	case nodeSet:
+		if len(v) == 0 {
+			return ""
+		}
+		return v[0].stringValue()
+	case string:
+		return v
+	case float64:
+		return formatNumber(v)
+	case bool:
+		return strconv.FormatBool(v)
+	}
+	return ""
+}
+
+func toNumber(v value) float64 {
+	switch v := v.(type) {
+	case float64:
+		return v
+	case bool:
+		if v {
+			return 1
+		}
+		return 0
+	}
+	return parseNumber(toString(v))
+}
+
+// parseNumber parses the Number of XPath 1.0, optionally negative and
+// surrounded by whitespace, it returns NaN for any other string
+func parseNumber(s string) float64 {
+	s = strings.Trim(s, " \t\r\n")
+	digits := strings.TrimPrefix(s, "-")
+	if digits == "" || digits == "." {
+		return math.NaN()
+	}
+	dot := false
+	for _, c := range digits {
+		switch {
+		case c == '.' && !dot:
+			dot = true
+		case c < '0' || c > '9':
+			return math.NaN()
+		}
+	}
+	f, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return math.NaN()
+	}
+	return f
+}
+
+func formatNumber(f float64) string {
+	switch {
+	case math.IsNaN(f):
+		return "NaN"
+	case math.IsInf(f, 1):
+		return "Infinity"
+	case math.IsInf(f, -1):
+		return "-Infinity"
+	case f == 0:
+		// -0 too
+		return "0"
+	}
+	return strconv.FormatFloat(f, 'f', -1, 64)
+}
@@ -0,0 +1,192 @@
+// Copyright 2024 Juan Pablo Tosso and the OWASP Coraza contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package xpath
+
+import (
+	"fmt"
+	"math"
+	"strings"
+	"unicode/utf8"
+)
+
+// function is a function of the core library, minArgs and maxArgs bound
+// its number of arguments, maxArgs is -1 if unbounded
+type function struct {
+	minArgs, maxArgs int
+	fn               func(c *context, args []expr) value
+}
+
+var functions = map[string]function{
+	// node-set functions
+	"last":     {0, 0, func(c *context, _ []expr) value { return float64(c.size) }},
+	"position": {0, 0, func(c *context, _ []expr) value { return float64(c.pos) }},
+	"count": {1, 1, func(c *context, args []expr) value {
+		set, _ := args[0].eval(c).(nodeSet)
+		return float64(len(set))
+	}},
+	"local-name": {0, 1, func(c *context, args []expr) value {
+		if n := contextNode(c, args); n != nil {
+			return n.local
+		}
+		return ""
+	}},
+	"namespace-uri": {0, 1, func(c *context, args []expr) value {
+		if n := contextNode(c, args); n != nil {
+			return n.space
+		}
+		return ""
+	}},
+	"name": {0, 1, func(c *context, args []expr) value {
+		if n := contextNode(c, args); n != nil {
+			return n.name()
+		}
+		return ""
+	}},
+
+	// string functions
+	"string": {0, 1, func(c *context, args []expr) value { return stringArg(c, args) }},
+	"concat": {2, -1, func(c *context, args []expr) value {
+		var sb strings.Builder
+		for _, a := range args {
+			sb.WriteString(toString(a.eval(c)))
+		}
+		return sb.String()
+	}},
+	"starts-with": {2, 2, func(c *context, args []expr) value {
+		return strings.HasPrefix(toString(args[0].eval(c)), toString(args[1].eval(c)))
+	}},
+	"contains": {2, 2, func(c *context, args []expr) value {
+		return strings.Contains(toString(args[0].eval(c)), toString(args[1].eval(c)))
+	}},
+	"substring-before": {2, 2, func(c *context, args []expr) value {
+		before, _, found := strings.Cut(toString(args[0].eval(c)), toString(args[1].eval(c)))
+		if !found {
+			return ""
+		}
+		return before
+	}},
+	"substring-after": {2, 2, func(c *context, args []expr) value {
+		_, after, _ := strings.Cut(toString(args[0].eval(c)), toString(args[1].eval(c)))
+		return after
+	}},
+	"substring":       {2, 3, substring},
+	"string-length":   {0, 1, func(c *context, args []expr) value { return float64(utf8.RuneCountInString(stringArg(c, args))) }},
+	"normalize-space": {0, 1, func(c *context, args []expr) value { return strings.Join(strings.Fields(stringArg(c, args)), " ") }},
+	"translate":       {3, 3, translate},
+	"boolean":         {1, 1, func(c *context, args []expr) value { return toBool(args[0].eval(c)) }},
+	"not":             {1, 1, func(c *context, args []expr) value { return !toBool(args[0].eval(c)) }},
+	"true":            {0, 0, func(*context, []expr) value { return true }},
+	"false":           {0, 0, func(*context, []expr) value { return false }},
+	"number":          {0, 1, func(c *context, args []expr) value { return numberArg(c, args) }},
+	"floor":           {1, 1, func(c *context, args []expr) value { return math.Floor(toNumber(args[0].eval(c))) }},
+	"ceiling":         {1, 1, func(c *context, args []expr) value { return math.Ceil(toNumber(args[0].eval(c))) }},
+	"round":           {1, 1, func(c *context, args []expr) value { return round(toNumber(args[0].eval(c))) }},
+	"sum": {1, 1, func(c *context, args []expr) value {
+		set, _ := args[0].eval(c).(nodeSet)
+		sum := 0.0
+		for _, n := range set {
+			sum += parseNumber(n.stringValue())
+		}
+		return sum
+	}},
+}
+
+// functionExpr is a call of a function of the core library
+type functionExpr struct {
+	f    function
+	args []expr
+}
+
+func newFunction(name string, args []expr) (expr, error) {
+	f, ok := functions[name]
+	if !ok {
+		return nil, fmt.Errorf("unsupported function %s()", name)
+	}
+	if len(args) < f.minArgs || f.maxArgs != -1 && len(args) > f.maxArgs {
+		return nil, fmt.Errorf("invalid number of arguments of %s()", name)
+	}
+	return &functionExpr{f: f, args: args}, nil
+}
+
+func (e *functionExpr) eval(c *context) value {
+	return e.f.fn(c, e.args)
+}
+
+// contextNode returns the first node of the node-set argument, or the context
+// node without argument
+func contextNode(c *context, args []expr) *node {
+	if len(args) == 0 {
+		return c.node
+	}
+	set, _ := args[0].eval(c).(nodeSet)
+	if len(set) == 0 {
+		return nil
+	}
+	return set[0]
+}
+
+// stringArg returns the argument converted to a string, or the string-value
+// of the context node without argument
+func stringArg(c *context, args []expr) string {
+	if len(args) == 0 {
+		return c.node.stringValue()
+	}
+	return toString(args[0].eval(c))
+}
+
+func numberArg(c *context, args []expr) float64 {
+	if len(args) == 0 {
+		return parseNumber(c.node.stringValue())
+	}
+	return toNumber(args[0].eval(c))
+}
+
+// round rounds half up, unlike math.Round which rounds half away from zero
+func round(f float64) float64 {
+	if math.IsNaN(f) || math.IsInf(f, 0) {
+		return f
+	}
+	if f < 0 && f >= -0.5 {
+		return math.Copysign(0, -1)
+	}
+	return math.Floor(f + 0.5)
+}
+
+// substring returns the characters at the positions p, from 1, such that
+// round(start) <= p < round(start) + round(length)
+func substring(c *context, args []expr) value {
+	s := []rune(toString(args[0].eval(c)))
+	start := round(toNumber(args[1].eval(c)))
+	end := math.Inf(1)
+	if len(args) == 3 {
+		end = start + round(toNumber(args[2].eval(c)))
+	}
+	var sb strings.Builder
+	for i, r := range s {
+		if p := float64(i + 1); p >= start && p < end {
+			sb.WriteRune(r)
+		}
+	}
+	return sb.String()
+}
+
+// translate replaces the characters of the second argument with the ones
+// at the same position in the third, or removes them if there is none
+func translate(c *context, args []expr) value {
+	s := toString(args[0].eval(c))
+	from := []rune(toString(args[1].eval(c)))
+	to := []rune(toString(args[2].eval(c)))
+	return strings.Map(func(r rune) rune {
+		for i, f := range from {
+			if f != r {
+				continue
+			}
+			if i < len(to) {
+				return to[i]
+			}
+			return -1
+		}
+		return r
+	}, s)
+}
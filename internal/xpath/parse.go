@@ -0,0 +1,479 @@
+// Copyright 2024 Juan Pablo Tosso and the OWASP Coraza contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package xpath
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+type tokenKind int
+
+const (
+	tokenEOF tokenKind = iota
+	tokenNumber
+	tokenLiteral
+	// tokenName is a QName, a prefix:* or * name test, or a function, node
+	// type or axis name
+	tokenName
+	// tokenOp is a punctuation or an operator, including and, or, div, mod
+	// and the multiplication *
+	tokenOp
+)
+
+type token struct {
+	kind tokenKind
+	text string
+	num  float64
+}
+
+// isOperator reports whether the token is an Operator of the grammar, after
+// which * is a name test and the names are not operator names
+func (t token) isOperator() bool {
+	if t.kind != tokenOp {
+		return false
+	}
+	switch t.text {
+	case ")", "]", ".", "..":
+		return false
+	}
+	return true
+}
+
+// lex splits the expression into tokens, applying the disambiguation rules
+// of the * and the operator names
+func lex(s string) ([]token, error) {
+	var tokens []token
+	// operatorContext is set when the next * or name is an operator, that is
+	// when there is a preceding token and it is not an Operator, @, ::, (, [
+	// or a comma
+	operatorContext := func() bool {
+		return len(tokens) > 0 && !tokens[len(tokens)-1].isOperator()
+	}
+	for i := 0; i < len(s); {
+		c := s[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			i++
+			continue
+		case c == '"' || c == '\'':
+			end := strings.IndexByte(s[i+1:], c)
+			if end == -1 {
+				return nil, errors.New("unterminated literal")
+			}
+			tokens = append(tokens, token{kind: tokenLiteral, text: s[i+1 : i+1+end]})
+			i += end + 2
+			continue
+		case c >= '0' && c <= '9' || c == '.' && i+1 < len(s) && s[i+1] >= '0' && s[i+1] <= '9':
+			n := i
+			for n < len(s) && (s[n] >= '0' && s[n] <= '9' || s[n] == '.') {
+				n++
+			}
+			f, err := strconv.ParseFloat(s[i:n], 64)
+			if err != nil {
+				return nil, fmt.Errorf("invalid number %q", s[i:n])
+			}
+			tokens = append(tokens, token{kind: tokenNumber, text: s[i:n], num: f})
+			i = n
+			continue
+		case c == '*':
+			if operatorContext() {
+				tokens = append(tokens, token{kind: tokenOp, text: "*"})
+			} else {
+				tokens = append(tokens, token{kind: tokenName, text: "*"})
+			}
+			i++
+			continue
+		case c == '$':
+			return nil, errors.New("variables are not supported")
+		}
+		if op := punctuation(s[i:]); op != "" {
+			tokens = append(tokens, token{kind: tokenOp, text: op})
+			i += len(op)
+			continue
+		}
+		name := ncName(s[i:])
+		if name == "" {
+			r, _ := utf8.DecodeRuneInString(s[i:])
+			return nil, fmt.Errorf("unexpected %q", r)
+		}
+		n := i + len(name)
+		if operatorContext() {
+			switch name {
+			case "and", "or", "div", "mod":
+				tokens = append(tokens, token{kind: tokenOp, text: name})
+				i = n
+				continue
+			}
+			return nil, fmt.Errorf("unexpected name %q, expected an operator", name)
+		}
+		// prefix:local and prefix:*, not the axis separator
+		if n < len(s) && s[n] == ':' && !strings.HasPrefix(s[n:], "::") {
+			if n+1 < len(s) && s[n+1] == '*' {
+				n += 2
+			} else if local := ncName(s[n+1:]); local != "" {
+				n += 1 + len(local)
+			} else {
+				return nil, fmt.Errorf("invalid name %q", s[i:n+1])
+			}
+		}
+		tokens = append(tokens, token{kind: tokenName, text: s[i:n]})
+		i = n
+	}
+	return append(tokens, token{kind: tokenEOF}), nil
+}
+
+// punctuation returns the punctuation or symbol operator at the start of s
+func punctuation(s string) string {
+	for _, op := range []string{"//", "..", "::", "!=", "<=", ">=", "/", ".", "(", ")", "[", "]", "@", ",", "|", "+", "-", "=", "<", ">"} {
+		if strings.HasPrefix(s, op) {
+			return op
+		}
+	}
+	return ""
+}
+
+func isNameStart(s string) bool {
+	r, _ := utf8.DecodeRuneInString(s)
+	return r == '_' || unicode.IsLetter(r)
+}
+
+// ncName returns the name without colon at the start of s
+func ncName(s string) string {
+	if !isNameStart(s) {
+		return ""
+	}
+	for i, r := range s {
+		if r != '_' && r != '-' && r != '.' && !unicode.IsLetter(r) && !unicode.IsDigit(r) {
+			return s[:i]
+		}
+	}
+	return s
+}
+
+// parser is a recursive descent parser of the grammar of XPath 1.0
+type parser struct {
+	tokens     []token
+	pos        int
+	namespaces map[string]string
+}
+
+func (p *parser) peek() token {
+	return p.tokens[p.pos]
+}
+
+func (p *parser) next() token {
+	t := p.tokens[p.pos]
+	if t.kind != tokenEOF {
+		p.pos++
+	}
+	return t
+}
+
+// accept consumes the next token if it is the operator op
+func (p *parser) accept(op string) bool {
+	if t := p.peek(); t.kind == tokenOp && t.text == op {
+		p.pos++
+		return true
+	}
+	return false
+}
+
+func (p *parser) expect(op string) error {
+	if !p.accept(op) {
+		return fmt.Errorf("expected %q, have %q", op, p.peek().text)
+	}
+	return nil
+}
+
+func (p *parser) parse() (expr, error) {
+	e, err := p.orExpr()
+	if err != nil {
+		return nil, err
+	}
+	if t := p.peek(); t.kind != tokenEOF {
+		return nil, fmt.Errorf("unexpected %q", t.text)
+	}
+	return e, nil
+}
+
+// binary parses the left associative operators ops whose operands are parsed
+// by operand
+func (p *parser) binary(operand func() (expr, error), ops ...string) (expr, error) {
+	left, err := operand()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		t := p.peek()
+		if t.kind != tokenOp || !slices.Contains(ops, t.text) {
+			return left, nil
+		}
+		p.pos++
+		right, err := operand()
+		if err != nil {
+			return nil, err
+		}
+		left = &binaryExpr{op: t.text, left: left, right: right}
+	}
+}
+
+func (p *parser) orExpr() (expr, error) {
+	return p.binary(p.andExpr, "or")
+}
+
+func (p *parser) andExpr() (expr, error) {
+	return p.binary(p.equalityExpr, "and")
+}
+
+func (p *parser) equalityExpr() (expr, error) {
+	return p.binary(p.relationalExpr, "=", "!=")
+}
+
+func (p *parser) relationalExpr() (expr, error) {
+	return p.binary(p.additiveExpr, "<", "<=", ">", ">=")
+}
+
+func (p *parser) additiveExpr() (expr, error) {
+	return p.binary(p.multiplicativeExpr, "+", "-")
+}
+
+func (p *parser) multiplicativeExpr() (expr, error) {
+	return p.binary(p.unaryExpr, "*", "div", "mod")
+}
+
+func (p *parser) unaryExpr() (expr, error) {
+	if p.accept("-") {
+		e, err := p.unaryExpr()
+		if err != nil {
+			return nil, err
+		}
+		return &negateExpr{e: e}, nil
+	}
+	return p.binary(p.pathExpr, "|")
+}
+
+// pathExpr parses a location path or a filter expression optionally
+// followed by a relative location path
+func (p *parser) pathExpr() (expr, error) {
+	t := p.peek()
+	primary := false
+	switch t.kind {
+	case tokenNumber, tokenLiteral:
+		primary = true
+	case tokenOp:
+		primary = t.text == "("
+	case tokenName:
+		// a function call, but not a node type test
+		if next := p.tokens[p.pos+1]; next.kind == tokenOp && next.text == "(" {
+			switch t.text {
+			case "node", "text", "comment", "processing-instruction":
+			default:
+				primary = true
+			}
+		}
+	}
+	if !primary {
+		return p.locationPath()
+	}
+
+	e, err := p.primaryExpr()
+	if err != nil {
+		return nil, err
+	}
+	preds, err := p.predicates()
+	if err != nil {
+		return nil, err
+	}
+	if len(preds) > 0 {
+		e = &filterExpr{e: e, predicates: preds}
+	}
+	path := &pathExpr{start: e}
+	switch {
+	case p.accept("/"):
+	case p.accept("//"):
+		path.steps = append(path.steps, descendantOrSelf())
+	default:
+		return e, nil
+	}
+	if err := p.relativePath(path); err != nil {
+		return nil, err
+	}
+	return path, nil
+}
+
+func (p *parser) primaryExpr() (expr, error) {
+	t := p.next()
+	switch t.kind {
+	case tokenNumber:
+		return numberExpr(t.num), nil
+	case tokenLiteral:
+		return stringExpr(t.text), nil
+	case tokenOp:
+		e, err := p.orExpr()
+		if err != nil {
+			return nil, err
+		}
+		return e, p.expect(")")
+	}
+	p.pos++ // (
+	var args []expr
+	if !p.accept(")") {
+		for {
+			arg, err := p.orExpr()
+			if err != nil {
+				return nil, err
+			}
+			args = append(args, arg)
+			if p.accept(")") {
+				break
+			}
+			if err := p.expect(","); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return newFunction(t.text, args)
+}
+
+func (p *parser) predicates() ([]expr, error) {
+	var preds []expr
+	for p.accept("[") {
+		e, err := p.orExpr()
+		if err != nil {
+			return nil, err
+		}
+		if err := p.expect("]"); err != nil {
+			return nil, err
+		}
+		preds = append(preds, e)
+	}
+	return preds, nil
+}
+
+func (p *parser) locationPath() (expr, error) {
+	path := &pathExpr{}
+	switch {
+	case p.accept("/"):
+		path.absolute = true
+		// the root alone
+		if !p.startsStep() {
+			return path, nil
+		}
+	case p.accept("//"):
+		path.absolute = true
+		path.steps = append(path.steps, descendantOrSelf())
+	}
+	if err := p.relativePath(path); err != nil {
+		return nil, err
+	}
+	return path, nil
+}
+
+// startsStep reports whether the next token starts a step
+func (p *parser) startsStep() bool {
+	t := p.peek()
+	switch t.kind {
+	case tokenName:
+		return true
+	case tokenOp:
+		return t.text == "." || t.text == ".." || t.text == "@"
+	}
+	return false
+}
+
+func (p *parser) relativePath(path *pathExpr) error {
+	for {
+		s, err := p.step()
+		if err != nil {
+			return err
+		}
+		path.steps = append(path.steps, s)
+		switch {
+		case p.accept("/"):
+		case p.accept("//"):
+			path.steps = append(path.steps, descendantOrSelf())
+		default:
+			return nil
+		}
+	}
+}
+
+func descendantOrSelf() *step {
+	return &step{axis: axisDescendantOrSelf, test: nodeTest{kind: testNode}}
+}
+
+func (p *parser) step() (*step, error) {
+	if p.accept(".") {
+		return &step{axis: axisSelf, test: nodeTest{kind: testNode}}, nil
+	}
+	if p.accept("..") {
+		return &step{axis: axisParent, test: nodeTest{kind: testNode}}, nil
+	}
+	s := &step{axis: axisChild}
+	if p.accept("@") {
+		s.axis = axisAttribute
+	} else if t := p.peek(); t.kind == tokenName && p.tokens[p.pos+1].kind == tokenOp && p.tokens[p.pos+1].text == "::" {
+		a, ok := axes[t.text]
+		if !ok {
+			return nil, fmt.Errorf("unsupported axis %q", t.text)
+		}
+		s.axis = a
+		p.pos += 2
+	}
+
+	t := p.next()
+	if t.kind != tokenName {
+		return nil, fmt.Errorf("expected a node test, have %q", t.text)
+	}
+	if p.accept("(") {
+		switch t.text {
+		case "node":
+			s.test.kind = testNode
+		case "text":
+			s.test.kind = testText
+		case "comment":
+			s.test.kind = testComment
+		case "processing-instruction":
+			s.test.kind = testPI
+			// the optional literal is ignored, the instructions are not kept
+			if p.peek().kind == tokenLiteral {
+				p.pos++
+			}
+		default:
+			return nil, fmt.Errorf("unexpected function %q in a step", t.text)
+		}
+		if err := p.expect(")"); err != nil {
+			return nil, err
+		}
+	} else {
+		s.test.kind = testName
+		prefix, local, ok := strings.Cut(t.text, ":")
+		if !ok {
+			prefix, local = "", t.text
+		}
+		switch {
+		case t.text == "*":
+			s.test.anySpace = true
+		case prefix != "":
+			space, ok := p.namespaces[prefix]
+			if !ok {
+				return nil, fmt.Errorf("unbound namespace prefix %q", prefix)
+			}
+			s.test.space = space
+		}
+		s.test.local = local
+	}
+	preds, err := p.predicates()
+	if err != nil {
+		return nil, err
+	}
+	s.predicates = preds
+	return s, nil
+}
@@ -0,0 +1,251 @@
+// Copyright 2024 Juan Pablo Tosso and the OWASP Coraza contributors
+// SPDX-License-Identifier: Apache-2.0
+
+// Package xpath evaluates XPath 1.0 expressions against XML documents. It
+// supports the location paths with all the axes but namespace, the
+// abbreviated syntax, the predicates, the unions, the operators and the core
+// function library but id and lang. The variables are not supported. As in
+// XPath 1.0, the names without prefix only select the elements without
+// namespace, the namespaces are bound to prefixes when compiling.
+package xpath
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// xmlNS is the namespace bound to the xml prefix
+const xmlNS = "http://www.w3.org/XML/1998/namespace"
+
+type nodeType int
+
+const (
+	rootNode nodeType = iota
+	elementNode
+	attributeNode
+	textNode
+	commentNode
+)
+
+// node is a node of a document, the attributes are not children of their
+// element but have it as parent
+type node struct {
+	typ      nodeType
+	prefix   string
+	space    string
+	local    string
+	value    string
+	parent   *node
+	children []*node
+	attrs    []*node
+	// order is the position of the node in document order
+	order int
+}
+
+// name returns the qualified name of the node as written in the document
+func (n *node) name() string {
+	if n.prefix == "" {
+		return n.local
+	}
+	return n.prefix + ":" + n.local
+}
+
+// stringValue returns the string-value of the node, the concatenation of the
+// text of the descendants of the root and elements
+func (n *node) stringValue() string {
+	if n.typ != rootNode && n.typ != elementNode {
+		return n.value
+	}
+	var sb strings.Builder
+	var walk func(*node)
+	walk = func(n *node) {
+		for _, c := range n.children {
+			switch c.typ {
+			case textNode:
+				sb.WriteString(c.value)
+			case elementNode:
+				walk(c)
+			}
+		}
+	}
+	walk(n)
+	return sb.String()
+}
+
+// Document is a parsed XML document, it is safe for concurrent use
+type Document struct {
+	root *node
+}
+
+// Parse parses an XML document. Only the predefined entities are expanded,
+// the ones declared in a DOCTYPE are not.
+func Parse(data []byte) (*Document, error) {
+	dec := xml.NewDecoder(bytes.NewReader(data))
+	root := &node{typ: rootNode}
+	order := 1
+	stack := []*node{root}
+	scopes := []map[string]string{{"xml": xmlNS}}
+	for {
+		tok, err := dec.RawToken()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		parent := stack[len(stack)-1]
+		switch tok := tok.(type) {
+		case xml.StartElement:
+			if parent == root && hasElement(root) {
+				return nil, errors.New("multiple root elements")
+			}
+			scope := namespaces(tok.Attr, scopes[len(scopes)-1])
+			n := &node{typ: elementNode, prefix: tok.Name.Space, local: tok.Name.Local, parent: parent, order: order}
+			n.space = scope[n.prefix]
+			order++
+			for _, a := range tok.Attr {
+				if a.Name.Space == "xmlns" || (a.Name.Space == "" && a.Name.Local == "xmlns") {
+					continue
+				}
+				attr := &node{typ: attributeNode, prefix: a.Name.Space, local: a.Name.Local, value: a.Value, parent: n, order: order}
+				if attr.prefix != "" {
+					attr.space = scope[attr.prefix]
+				}
+				n.attrs = append(n.attrs, attr)
+				order++
+			}
+			parent.children = append(parent.children, n)
+			stack = append(stack, n)
+			scopes = append(scopes, scope)
+		case xml.EndElement:
+			if parent == root || tok.Name != (xml.Name{Space: parent.prefix, Local: parent.local}) {
+				return nil, fmt.Errorf("unexpected end element </%s>", tok.Name.Local)
+			}
+			stack = stack[:len(stack)-1]
+			scopes = scopes[:len(scopes)-1]
+		case xml.CharData:
+			if parent == root {
+				continue
+			}
+			// adjacent text, e.g. CDATA sections, is a single node
+			if last := len(parent.children) - 1; last >= 0 && parent.children[last].typ == textNode {
+				parent.children[last].value += string(tok)
+				continue
+			}
+			parent.children = append(parent.children, &node{typ: textNode, value: string(tok), parent: parent, order: order})
+			order++
+		case xml.Comment:
+			parent.children = append(parent.children, &node{typ: commentNode, value: string(tok), parent: parent, order: order})
+			order++
+		}
+	}
+	if len(stack) != 1 {
+		return nil, errors.New("unexpected end of document")
+	}
+	if !hasElement(root) {
+		return nil, errors.New("missing root element")
+	}
+	return &Document{root: root}, nil
+}
+
+func hasElement(n *node) bool {
+	for _, c := range n.children {
+		if c.typ == elementNode {
+			return true
+		}
+	}
+	return false
+}
+
+// namespaces returns the prefixes in scope of an element, the default
+// namespace is bound to the empty prefix
+func namespaces(attrs []xml.Attr, parent map[string]string) map[string]string {
+	scope := parent
+	copied := false
+	for _, a := range attrs {
+		var prefix string
+		switch {
+		case a.Name.Space == "xmlns":
+			prefix = a.Name.Local
+		case a.Name.Space == "" && a.Name.Local == "xmlns":
+		default:
+			continue
+		}
+		if !copied {
+			scope = make(map[string]string, len(parent)+1)
+			for k, v := range parent {
+				scope[k] = v
+			}
+			copied = true
+		}
+		scope[prefix] = a.Value
+	}
+	return scope
+}
+
+// Expr is a compiled XPath expression, it is safe for concurrent use
+type Expr struct {
+	expr string
+	root expr
+}
+
+// Compile compiles an XPath expression, namespaces binds the prefixes of
+// the names of the expression to their namespace
+func Compile(expression string, namespaces map[string]string) (*Expr, error) {
+	p := &parser{namespaces: namespaces}
+	var err error
+	if p.tokens, err = lex(expression); err != nil {
+		return nil, fmt.Errorf("invalid XPath %q: %s", expression, err.Error())
+	}
+	root, err := p.parse()
+	if err != nil {
+		return nil, fmt.Errorf("invalid XPath %q: %s", expression, err.Error())
+	}
+	return &Expr{expr: expression, root: root}, nil
+}
+
+// String returns the expression the Expr was compiled from
+func (e *Expr) String() string {
+	return e.expr
+}
+
+// Evaluate evaluates the expression with the root of the document as context
+func (e *Expr) Evaluate(doc *Document) Result {
+	return Result{v: e.root.eval(&context{node: doc.root, pos: 1, size: 1})}
+}
+
+// Result is the result of an expression, a node-set, a string, a number or a
+// boolean
+type Result struct {
+	v value
+}
+
+// Bool returns the result converted with the boolean function: true for the
+// non empty node-sets and strings and the numbers other than zero and NaN
+func (r Result) Bool() bool {
+	return toBool(r.v)
+}
+
+// String returns the result converted with the string function, the
+// string-value of the first node of a node-set
+func (r Result) String() string {
+	return toString(r.v)
+}
+
+// Strings returns the string-values of the nodes of a node-set in document
+// order, or the result converted to a string if it is not a node-set
+func (r Result) Strings() []string {
+	set, ok := r.v.(nodeSet)
+	if !ok {
+		return []string{toString(r.v)}
+	}
+	res := make([]string, len(set))
+	for i, n := range set {
+		res[i] = n.stringValue()
+	}
+	return res
+}
@@ -0,0 +1,150 @@
+// Copyright 2024 Juan Pablo Tosso and the OWASP Coraza contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package xpath
+
+import (
+	"slices"
+	"testing"
+)
+
+const soap = `<?xml version="1.0"?>
+<soap:Envelope xmlns:soap="http://schemas.xmlsoap.org/soap/envelope/" xmlns:m="urn:bank">
+	<soap:Header><m:Token ttl="60">abc</m:Token></soap:Header>
+	<soap:Body>
+		<m:Transfer currency="EUR">
+			<m:From>FR76</m:From>
+			<m:To>DE89</m:To>
+			<m:Amount>1500.50</m:Amount>
+			<m:Note><![CDATA[<b>rent</b>]]> &amp; bills</m:Note>
+		</m:Transfer>
+		<m:Transfer currency="USD"><m:Amount>20</m:Amount></m:Transfer>
+		<!-- audit -->
+		<plain id="1">text</plain>
+	</soap:Body>
+</soap:Envelope>`
+
+var testNamespaces = map[string]string{
+	"s": "http://schemas.xmlsoap.org/soap/envelope/",
+	"b": "urn:bank",
+}
+
+func TestEvaluate(t *testing.T) {
+	doc, err := Parse([]byte(soap))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		expr string
+		want []string
+	}{
+		{"/s:Envelope/s:Body/b:Transfer/b:Amount", []string{"1500.50", "20"}},
+		{"//b:Amount[. > 1000]", []string{"1500.50"}},
+		{"//b:Transfer[@currency = 'USD']/b:Amount", []string{"20"}},
+		{"//b:Transfer[2]/@currency", []string{"USD"}},
+		{"//b:Transfer[last()]/@currency", []string{"USD"}},
+		{"(//b:Amount)[1]", []string{"1500.50"}},
+		{"//b:Note", []string{"<b>rent</b> & bills"}},
+		{"//b:Token/@ttl | //plain/@id", []string{"60", "1"}},
+		{"//plain", []string{"text"}},
+		{"//Body", nil},
+		{"//b:*[starts-with(local-name(), 'Fr')]", []string{"FR76"}},
+		{"//b:To/preceding-sibling::*", []string{"FR76"}},
+		{"//b:To/following-sibling::b:*[1]", []string{"1500.50"}},
+		{"//b:To/ancestor::*[3]/s:Header/b:Token", []string{"abc"}},
+		{"//b:Amount/parent::*/@currency", []string{"EUR", "USD"}},
+		{"//b:From/following::b:Amount", []string{"1500.50", "20"}},
+		{"//plain/preceding::b:Amount[1]", []string{"20"}},
+		{"//comment()", []string{" audit "}},
+		{"count(//b:Transfer)", []string{"2"}},
+		{"sum(//b:Amount)", []string{"1520.5"}},
+		{"sum(//b:Amount) div 4", []string{"380.125"}},
+		{"7 mod 3 * -2", []string{"-2"}},
+		{"name(//b:Token)", []string{"m:Token"}},
+		{"namespace-uri(//b:Token)", []string{"urn:bank"}},
+		{"concat(//b:From, '-', //b:To)", []string{"FR76-DE89"}},
+		{"substring('12345', 1.5, 2.6)", []string{"234"}},
+		{"substring-before('a=b', '=')", []string{"a"}},
+		{"substring-after('a=b', '=')", []string{"b"}},
+		{"translate('bar', 'abc', 'AB')", []string{"BAr"}},
+		{"normalize-space('  a   b ')", []string{"a b"}},
+		{"string-length('héllo')", []string{"5"}},
+		{"round(2.5) + round(-2.5) + floor(1.7) + ceiling(1.2)", []string{"4"}},
+		{"number('12a')", []string{"NaN"}},
+		{"1 div 0", []string{"Infinity"}},
+		{"//b:Amount = 20", []string{"true"}},
+		{"//b:Amount != 20", []string{"true"}},
+		{"not(//b:Amount = 30)", []string{"true"}},
+		{"//b:Missing = false()", []string{"true"}},
+		{"'1' = 1.0 and true() or false()", []string{"true"}},
+	}
+	for _, tt := range tests {
+		e, err := Compile(tt.expr, testNamespaces)
+		if err != nil {
+			t.Errorf("unexpected error for %q: %s", tt.expr, err.Error())
+			continue
+		}
+		have := e.Evaluate(doc).Strings()
+		if _, isSet := e.Evaluate(doc).v.(nodeSet); isSet && len(have) == 0 {
+			have = nil
+		}
+		if want := tt.want; !slices.Equal(want, have) {
+			t.Errorf("unexpected result of %q, want %q, have %q", tt.expr, want, have)
+		}
+	}
+}
+
+func TestResult(t *testing.T) {
+	doc, err := Parse([]byte(`<a><b>1</b><b>2</b></a>`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		expr string
+		bool bool
+		str  string
+	}{
+		{"/a/b", true, "1"},
+		{"/a/c", false, ""},
+		{"count(/a/b) - 2", false, "0"},
+		{"'false'", true, "false"},
+		{"1 = 2", false, "false"},
+	}
+	for _, tt := range tests {
+		r := mustCompile(t, tt.expr).Evaluate(doc)
+		if want, have := tt.bool, r.Bool(); want != have {
+			t.Errorf("unexpected boolean of %q, want %t, have %t", tt.expr, want, have)
+		}
+		if want, have := tt.str, r.String(); want != have {
+			t.Errorf("unexpected string of %q, want %q, have %q", tt.expr, want, have)
+		}
+	}
+}
+
+func mustCompile(t *testing.T, expr string) *Expr {
+	t.Helper()
+	e, err := Compile(expr, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return e
+}
+
+func TestCompileErrors(t *testing.T) {
+	for _, expr := range []string{
+		"", "/a/", "//", "a[", "a[1", "x:a", "$var", "foo()", "count()", "concat('a')",
+		"namespace::a", "a b", "'unterminated", "1 +", "(1", "a/text(1)", "@",
+	} {
+		if _, err := Compile(expr, testNamespaces); err == nil {
+			t.Errorf("expected error for %q", expr)
+		}
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	for _, doc := range []string{"", "text", "<a>", "<a></b>", "<a/><b/>", "<a>&unknown;</a>"} {
+		if _, err := Parse([]byte(doc)); err == nil {
+			t.Errorf("expected error for %q", doc)
+		}
+	}
+}
@@ -0,0 +1,70 @@
+// Copyright 2024 Juan Pablo Tosso and the OWASP Coraza contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package seclang
+
+import (
+	"regexp"
+	"strconv"
+)
+
+var (
+	// paranoiaLevelTagRegex finds the paranoia-level/N tags of the rules of
+	// the OWASP CRS
+	paranoiaLevelTagRegex = regexp.MustCompile(`(?i)\btag\s*:\s*'?paranoia-level/(\d+)`)
+	ruleIDRegex           = regexp.MustCompile(`(?i)\bid\s*:\s*'?(\d+)`)
+)
+
+// SetMaxParanoiaLevel drops the rules tagged paranoia-level/N with N above
+// level instead of loading them and skipping them at runtime, along with the
+// rest of their chain. It saves the memory and the evaluation of the rules of
+// the higher paranoia levels, e.g. of the OWASP CRS, level must not be lower
+// than its detection paranoia level. The updates of the targets or actions of
+// the dropped rules are ignored. 0, the default, loads every rule.
+func (p *Parser) SetMaxParanoiaLevel(level int) {
+	p.options.Parser.MaxParanoiaLevel = level
+	if p.options.Parser.droppedRuleIDs == nil {
+		p.options.Parser.droppedRuleIDs = map[int]struct{}{}
+	}
+}
+
+// aboveParanoiaLevel reports whether the rule with the raw actions is dropped
+// by the maximum paranoia level, it records its id. The rules chained to
+// another one are dropped with it.
+func aboveParanoiaLevel(options *DirectiveOptions, actions string) bool {
+	config := &options.Parser
+	if config.MaxParanoiaLevel <= 0 || getLastRuleExpectingChain(options.WAF) != nil {
+		return false
+	}
+	for _, m := range paranoiaLevelTagRegex.FindAllStringSubmatch(actions, -1) {
+		if level, err := strconv.Atoi(m[1]); err != nil || level <= config.MaxParanoiaLevel {
+			continue
+		}
+		if id := rawRuleID(config, actions); id != 0 {
+			config.droppedRuleIDs[id] = struct{}{}
+		}
+		options.WAF.Logger.Debug().
+			Str("file", config.ConfigFile).
+			Int("line", config.LastLine).
+			Msg("Dropping rule above the maximum paranoia level")
+		return true
+	}
+	return false
+}
+
+// ruleDropped reports whether the rule with the id was dropped by the maximum
+// paranoia level
+func ruleDropped(options *DirectiveOptions, id int) bool {
+	_, ok := options.Parser.droppedRuleIDs[id]
+	return ok
+}
+
+// rawRuleID returns the id of a rule from its raw actions, 0 if it has none
+func rawRuleID(config *ParserConfig, actions string) int {
+	m := ruleIDRegex.FindStringSubmatch(actions)
+	if m == nil {
+		return 0
+	}
+	id, _ := strconv.Atoi(config.RulePack.ruleIDs(m[1]))
+	return id
+}
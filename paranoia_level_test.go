@@ -0,0 +1,61 @@
+// Copyright 2024 Juan Pablo Tosso and the OWASP Coraza contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package seclang
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/ad3n/seclang/internal/corazawaf"
+)
+
+func TestMaxParanoiaLevel(t *testing.T) {
+	rules := `
+SecRule ARGS "@rx a" "id:1,phase:1,pass"
+SecRule ARGS "@rx a" "id:2,phase:1,pass,tag:'paranoia-level/1'"
+SecRule ARGS "@rx a" "id:3,phase:1,pass,tag:'paranoia-level/2'"
+SecRule ARGS "@rx a" "id:4,phase:1,pass,tag:'OWASP_CRS',tag:'paranoia-level/3',chain"
+    SecRule ARGS "@rx b" "chain"
+    SecRule ARGS "@rx c" "t:none"
+SecAction "id:5,phase:1,pass,tag:'paranoia-level/4'"
+SecRule ARGS "@rx a" "id:6,phase:1,pass,chain"
+    SecRule ARGS "@rx b" "tag:'paranoia-level/4'"
+SecRuleUpdateTargetById 4 "!ARGS:foo"
+SecRuleUpdateActionById 5 "t:lowercase"
+`
+	tests := []struct {
+		level int
+		want  string
+	}{
+		{0, "[1 2 3 4 5 6]"},
+		{1, "[1 2 6]"},
+		{3, "[1 2 3 4 6]"},
+	}
+	for _, tt := range tests {
+		waf := corazawaf.NewWAF()
+		p := NewParser(waf)
+		p.SetMaxParanoiaLevel(tt.level)
+		if err := p.FromString(rules); err != nil {
+			t.Fatalf("unexpected error for level %d: %v", tt.level, err)
+		}
+		var ids []int
+		for _, r := range waf.Rules.GetRules() {
+			ids = append(ids, r.ID_)
+		}
+		if have := fmt.Sprint(ids); tt.want != have {
+			t.Errorf("unexpected rules for level %d, want %s, have %s", tt.level, tt.want, have)
+		}
+		// the chained rules are kept with their parent
+		if rule := waf.Rules.FindByID(6); rule == nil || rule.Chain == nil {
+			t.Errorf("expected rule 6 to keep its chain for level %d", tt.level)
+		}
+	}
+
+	// the rules that are not loaded still can't be updated
+	p := NewParser(corazawaf.NewWAF())
+	p.SetMaxParanoiaLevel(1)
+	if err := p.FromString(`SecRuleUpdateTargetById 7 "!ARGS:foo"`); err == nil {
+		t.Error("expected error updating a missing rule")
+	}
+}
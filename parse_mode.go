@@ -0,0 +1,93 @@
+// Copyright 2024 Juan Pablo Tosso and the OWASP Coraza contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package seclang
+
+import (
+	"errors"
+	"strings"
+
+	utils "github.com/ad3n/seclang/internal/strings"
+)
+
+// ParseMode configures how the parser handles rules using unknown actions,
+// operators or variables
+type ParseMode int
+
+const (
+	// ParseModeStrict fails to parse configurations with rules using unknown
+	// actions, operators or variables. It is the default.
+	ParseModeStrict ParseMode = iota
+	// ParseModePermissive logs a warning and skips the rules using unknown
+	// actions, operators or variables, along with the rest of their chain, so
+	// partially compatible ModSecurity configurations can be loaded while they
+	// are migrated. Other errors still fail the parsing.
+	ParseModePermissive
+)
+
+// SetParseMode configures how rules using unknown actions, operators or
+// variables are handled, see ParseModeStrict and ParseModePermissive
+func (p *Parser) SetParseMode(mode ParseMode) {
+	p.options.Parser.ParseMode = mode
+}
+
+// unknownRuleError flags the errors of rules using unknown actions, operators
+// or variables, which the permissive parse mode downgrades to warnings
+type unknownRuleError struct {
+	err error
+}
+
+func (e unknownRuleError) Error() string {
+	return e.err.Error()
+}
+
+func (e unknownRuleError) Unwrap() error {
+	return e.err
+}
+
+// skipRule reports whether the rule of a SecRule or SecAction directive is
+// skipped, either by the permissive parse mode because it failed with err, by
+// the maximum paranoia level, or because it is chained to a skipped rule.
+// actions are the raw actions of the rule, used to know if the following
+// rules are chained to it.
+func skipRule(options *DirectiveOptions, actions string, err error) bool {
+	config := &options.Parser
+	switch {
+	case config.skippingChain:
+		config.skipReason = SkipReasonChain
+	case err == nil && aboveParanoiaLevel(options, actions):
+		config.skipReason = SkipReasonParanoiaLevel
+	default:
+		var unknown unknownRuleError
+		if config.ParseMode != ParseModePermissive || !errors.As(err, &unknown) {
+			return false
+		}
+		config.skipReason = SkipReasonParseMode
+		options.WAF.Logger.Warn().
+			Str("file", config.ConfigFile).
+			Int("line", config.LastLine).
+			Err(err).
+			Msg("Skipping rule with unknown actions, operators or variables")
+		// the rules already loaded of the chain can't be evaluated alone
+		if parent := getLastRuleExpectingChain(options.WAF); parent != nil {
+			options.WAF.Logger.Warn().
+				Int("rule_id", parent.ID_).
+				Msg("Skipping the chain of the rule")
+			options.WAF.Rules.DeleteByID(parent.ID_)
+		}
+	}
+	config.skipRuleID = rawRuleID(config, actions)
+	config.skippingChain = declaresChain(actions)
+	return true
+}
+
+// declaresChain returns true if the raw actions of a rule contain the chain
+// action, without parsing them as they may be unknown
+func declaresChain(actions string) bool {
+	for _, action := range strings.Split(utils.MaybeRemoveQuotes(actions), ",") {
+		if strings.EqualFold(strings.TrimSpace(action), "chain") {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,58 @@
+// Copyright 2024 Juan Pablo Tosso and the OWASP Coraza contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package seclang
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/ad3n/seclang/internal/corazawaf"
+)
+
+func TestParseMode(t *testing.T) {
+	rules := `
+SecRule ARGS "@rx a" "id:1,phase:1,pass"
+SecRule ARGS "@unknownOperator a" "id:2,phase:1,pass"
+SecRule UNKNOWN_VARIABLE "@rx a" "id:3,phase:1,pass"
+SecAction "id:4,phase:1,pass,unknownaction:1"
+SecRule ARGS "@rx a" "id:5,phase:1,pass,chain"
+    SecRule ARGS "@rx b" "chain,unknownaction"
+    SecRule ARGS "@rx c" "t:none"
+SecRule ARGS "@unknownOperator a" "id:6,phase:1,pass,chain"
+    SecRule ARGS "@rx b" "t:none"
+SecRule ARGS "@rx a" "id:7,phase:1,pass,chain"
+    SecRule ARGS "@rx b" "t:none"
+`
+	for _, invalid := range []string{
+		`SecRule ARGS "@unknownOperator a" "id:1,phase:1,pass"`,
+		`SecRule UNKNOWN_VARIABLE "@rx a" "id:1,phase:1,pass"`,
+		`SecAction "id:1,phase:1,pass,unknownaction:1"`,
+	} {
+		if err := NewParser(corazawaf.NewWAF()).FromString(invalid); err == nil {
+			t.Errorf("expected error in strict mode for %q", invalid)
+		}
+	}
+
+	waf := corazawaf.NewWAF()
+	p := NewParser(waf)
+	p.SetParseMode(ParseModePermissive)
+	if err := p.FromString(rules); err != nil {
+		t.Fatal(err)
+	}
+	var ids []int
+	for _, r := range waf.Rules.GetRules() {
+		ids = append(ids, r.ID_)
+	}
+	if want, have := "[1 7]", fmt.Sprint(ids); want != have {
+		t.Errorf("unexpected rules, want %s, have %s", want, have)
+	}
+	if rule := waf.Rules.FindByID(7); rule == nil || rule.Chain == nil {
+		t.Error("expected rule 7 to keep its chain")
+	}
+
+	// other errors still fail the parsing
+	if err := p.FromString(`SecRule ARGS "@rx a" "id:8,phase:6,pass"`); err == nil {
+		t.Error("expected error for an invalid phase")
+	}
+}
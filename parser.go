@@ -8,8 +8,10 @@ import (
 	"errors"
 	"fmt"
 	"io/fs"
+	"maps"
 	"os"
-	"path/filepath"
+	"slices"
+	"strconv"
 	"strings"
 
 	"github.com/ad3n/seclang/internal/corazawaf"
@@ -28,61 +30,85 @@ type Parser struct {
 	currentDir   string
 	root         fs.FS
 	includeCount int
+	remoteClient remoteIncludeClient
+	defines      map[string]bool
+	hooks        []DirectiveHook
+	observers    []LoadObserver
+	// windowsPaths forces the semantics of the Windows paths, see includePaths
+	windowsPaths bool
 }
 
+// DirectiveHook is called with every directive before it is evaluated.
+// Returning an error stops the parsing with that error.
+type DirectiveHook func(file string, line int, directive, args string) error
+
 // FromFile imports directives from a file
 // It will return error if any directive fails to parse
 // or the file does not exist.
 // If the path contains a *, it will be expanded to all
 // files in the directory matching the pattern.
 // It will return an error if there are no files matching the pattern.
+// Relative paths and patterns are resolved from the directory of the
+// including file. On Windows, the paths of the OS filesystem can use
+// backslashes and drive letters and the patterns ignore the case.
 func (p *Parser) FromFile(profilePath string) error {
 	originalDir := p.currentDir
+	// line numbers are relative to each file, the file and line of the
+	// including file are restored once the includes are processed
+	originalFile := p.currentFile
+	originalLine := p.currentLine
 
+	include := profilePath
+	// relative paths and patterns are resolved from the directory of the
+	// including file
+	paths := p.includePaths()
+	profilePath = paths.resolve(p.currentDir, strings.TrimSpace(profilePath))
 	var files []string
 	if strings.Contains(profilePath, "*") {
 		var err error
-		files, err = fs.Glob(p.root, profilePath)
+		files, err = paths.glob(p.root, profilePath)
 		if err != nil {
 			return fmt.Errorf("failed to glob: %s", err.Error())
 		}
 
 		if len(files) == 0 {
-			return fmt.Errorf("path %s is not valid", profilePath)
+			return fmt.Errorf("path %s is not valid", include)
 		}
 	} else {
 		files = append(files, profilePath)
 	}
 
 	for _, profilePath := range files {
-		profilePath = strings.TrimSpace(profilePath)
-		if !strings.HasPrefix(profilePath, "/") {
-			profilePath = filepath.Join(p.currentDir, profilePath)
-		}
 		p.currentFile = profilePath
+		p.currentLine = 0
 		lastDir := p.currentDir
-		p.currentDir = filepath.Dir(profilePath)
+		p.currentDir = paths.dir(profilePath)
 		file, err := fs.ReadFile(p.root, profilePath)
 		if err != nil {
 			// we don't use defer for this as tinygo does not seem to like it
 			p.currentDir = originalDir
-			p.currentFile = ""
+			p.currentFile = originalFile
+			p.currentLine = originalLine
 			return fmt.Errorf("failed to readfile: %s", err.Error())
 		}
+		p.emitFile(LoadEventFileOpened, include, originalFile, originalLine)
 
 		err = p.parseString(string(file))
 		if err != nil {
 			// we don't use defer for this as tinygo does not seem to like it
 			p.currentDir = originalDir
-			p.currentFile = ""
+			p.currentFile = originalFile
+			p.currentLine = originalLine
 			return fmt.Errorf("failed to parse string: %s", err.Error())
 		}
+		p.emitFile(LoadEventFileParsed, include, originalFile, originalLine)
 		// restore the lastDir post processing all includes
 		p.currentDir = lastDir
 	}
 	// we don't use defer for this as tinygo does not seem to like it
 	p.currentDir = originalDir
-	p.currentFile = ""
+	p.currentFile = originalFile
+	p.currentLine = originalLine
 
 	return nil
 }
@@ -98,10 +124,99 @@ func (p *Parser) FromString(data string) error {
 	return err
 }
 
+// Define sets a parameter for the <IfDefine> conditional blocks, directives
+// inside `<IfDefine NAME>` are only evaluated if NAME was defined and the ones
+// inside `<IfDefine !NAME>` only if it wasn't. Blocks can be nested but must be
+// closed with `</IfDefine>` in the same file they were opened.
+//
+// Example:
+// ```apache
+// <IfDefine PRODUCTION>
+// SecRuleEngine On
+// </IfDefine>
+// <IfDefine !PRODUCTION>
+// SecRuleEngine DetectionOnly
+// </IfDefine>
+// ```
+func (p *Parser) Define(name string) {
+	if p.defines == nil {
+		p.defines = map[string]bool{}
+	}
+	p.defines[name] = true
+}
+
+// Undefine removes a parameter set with Define
+func (p *Parser) Undefine(name string) {
+	delete(p.defines, name)
+}
+
+// OverrideDuplicateRuleIDs configures how rules reusing the ID of a previously
+// loaded rule are handled. By default the parser fails with an error pointing to
+// both definitions. When enabled, the last definition wins: the previous rule is
+// removed, the new one is evaluated at its own position and a warning is logged.
+// It is meant for deliberate overrides, e.g. replacing a rule of a vendored rule
+// set from a local file included afterwards. Parsers restoring a snapshot of
+// such rules must enable it too.
+func (p *Parser) OverrideDuplicateRuleIDs(enabled bool) {
+	p.options.Parser.OverrideDuplicateRuleIDs = enabled
+}
+
+// OnDirective registers a hook called with every directive before it is
+// evaluated, including Include directives, in the order they are parsed.
+// The directive keeps the case used in the configuration. Directives
+// skipped by <IfDefine> blocks are not reported. Hooks are called in the
+// order they were registered, the first error stops the parsing, which
+// allows to enforce policies on the configuration.
+//
+// Example:
+// ```go
+//
+//	p.OnDirective(func(file string, line int, directive, args string) error {
+//		if strings.EqualFold(directive, "SecRuleEngine") && strings.EqualFold(args, "Off") {
+//			return fmt.Errorf("%s:%d: SecRuleEngine Off is not allowed", file, line)
+//		}
+//		return nil
+//	})
+//
+// ```
+func (p *Parser) OnDirective(hook DirectiveHook) {
+	p.hooks = append(p.hooks, hook)
+}
+
 func (p *Parser) parseString(data string) error {
-	scanner := bufio.NewScanner(strings.NewReader(data))
+	err := p.parseLines(p.newScanner(strings.NewReader(data)))
+	p.reportImplicitRxFlags()
+	return err
+}
+
+// reportImplicitRxFlags logs the rules of the current file relying on the
+// implicit rx flags, whose matches change if the defaults of the rx operator
+// are changed, once per file
+func (p *Parser) reportImplicitRxFlags() {
+	ids := p.options.Parser.implicitRxFlagRules[p.currentFile]
+	if len(ids) == 0 {
+		return
+	}
+	delete(p.options.Parser.implicitRxFlagRules, p.currentFile)
+	list := make([]string, len(ids))
+	for i, id := range ids {
+		list[i] = strconv.Itoa(id)
+	}
+	p.options.WAF.Logger.Warn().
+		Str("file", p.currentFile).
+		Str("rule_ids", strings.Join(list, ",")).
+		Msg("Rules rely on the implicit rx flags, set them with SecRxDefaultFlags")
+}
+
+// parseLines evaluates the directives read by the scanner, only the lines
+// of the directive being read are kept in memory
+func (p *Parser) parseLines(scanner *bufio.Scanner) error {
 	var linebuffer strings.Builder
 	inBackticks := false
+	// conditions holds whether each open <IfDefine> block is satisfied,
+	// directives are skipped if any of them is not
+	var conditions []bool
+	skipped := 0
 	for scanner.Scan() {
 		p.currentLine++
 		line := strings.TrimSpace(scanner.Text())
@@ -114,6 +229,28 @@ func (p *Parser) parseString(data string) error {
 			continue
 		}
 
+		if !inBackticks && linebuffer.Len() == 0 && line[0] == '<' {
+			if name, ok := ifDefineBlock(line); ok {
+				negate := strings.HasPrefix(name, "!")
+				matched := p.defines[strings.TrimPrefix(name, "!")] != negate
+				if !matched {
+					skipped++
+				}
+				conditions = append(conditions, matched)
+				continue
+			}
+			if strings.EqualFold(line, "</IfDefine>") {
+				if len(conditions) == 0 {
+					return p.logAndReturnErr("unexpected </IfDefine> without <IfDefine>")
+				}
+				if !conditions[len(conditions)-1] {
+					skipped--
+				}
+				conditions = conditions[:len(conditions)-1]
+				continue
+			}
+		}
+
 		// Looks for a line like "SecDataset test `". The backtick starts an action list.
 		// The list will be closed only with a single "`" line.
 		if !inBackticks && line[lineLen-1] == '`' {
@@ -133,19 +270,44 @@ func (p *Parser) parseString(data string) error {
 			linebuffer.WriteString(strings.TrimSuffix(line, "\\"))
 		} else {
 			linebuffer.WriteString(line)
-			err := p.evaluateLine(linebuffer.String())
-			if err != nil {
-				return err
+			if skipped == 0 {
+				if err := p.evaluateLine(linebuffer.String()); err != nil {
+					return err
+				}
+			} else {
+				p.emitSkipped(linebuffer.String(), SkipReasonIfDefine, 0)
 			}
 			linebuffer.Reset()
 		}
 	}
+	if err := scanner.Err(); errors.Is(err, bufio.ErrTooLong) {
+		return fmt.Errorf("failed to read line %d: longer than %d bytes", p.currentLine+1, p.maxLineLength())
+	} else if err != nil {
+		return fmt.Errorf("failed to read line %d: %s", p.currentLine+1, err.Error())
+	}
 	if inBackticks {
 		return errors.New("backticks left open")
 	}
+	if len(conditions) > 0 {
+		return errors.New("<IfDefine> block left open")
+	}
 	return nil
 }
 
+// ifDefineBlock returns the parameter of a "<IfDefine NAME>" line
+func ifDefineBlock(line string) (string, bool) {
+	const prefix = "<ifdefine"
+	if len(line) <= len(prefix) || !strings.EqualFold(line[:len(prefix)], prefix) || line[len(line)-1] != '>' {
+		return "", false
+	}
+	rest := line[len(prefix) : len(line)-1]
+	if rest == "" || (rest[0] != ' ' && rest[0] != '\t') {
+		return "", false
+	}
+	name := strings.TrimSpace(rest)
+	return name, name != "" && name != "!"
+}
+
 func (p *Parser) evaluateLine(l string) error {
 	if l == "" || l[0] == '#' {
 		panic("invalid line")
@@ -160,6 +322,12 @@ func (p *Parser) evaluateLine(l string) error {
 		opts = strings.Trim(opts, `"`)
 	}
 
+	for _, hook := range p.hooks {
+		if err := hook(p.currentFile, p.currentLine, dir, opts); err != nil {
+			return err
+		}
+	}
+
 	if directive == "include" {
 		// this is a special hardcoded case
 		// we cannot add it as a directive type because there are recursion issues
@@ -169,12 +337,19 @@ func (p *Parser) evaluateLine(l string) error {
 			return p.logAndReturnErr(fmt.Sprintf("cannot include more than %d files", maxIncludeRecursion))
 		}
 		p.includeCount++
+		if strings.HasPrefix(opts, "https://") || strings.HasPrefix(opts, "http://") {
+			return p.includeRemote(opts)
+		}
 		return p.FromFile(opts)
 	}
 
 	d, ok := directivesMap[directive]
 	if !ok || d == nil {
-		return p.logAndReturnErr(fmt.Sprintf("unknown directive %q", directive))
+		h, ok := customDirectives[directive]
+		if !ok || h == nil {
+			return p.logAndReturnErr(fmt.Sprintf("unknown directive %q", directive))
+		}
+		d = customDirective(directive, h)
 	}
 
 	p.options.Raw = l
@@ -191,10 +366,62 @@ func (p *Parser) evaluateLine(l string) error {
 		p.options.Parser.WorkingDir = wd
 	}
 
+	count := p.options.WAF.Rules.Count()
+	p.options.Parser.skipReason = ""
 	if err := d(p.options); err != nil {
 		return fmt.Errorf("failed to compile the directive %q: %w", directive, err)
 	}
+	if len(p.observers) > 0 {
+		if reason := p.options.Parser.skipReason; reason != "" {
+			p.emitSkipped(l, reason, p.options.Parser.skipRuleID)
+		} else if ids := p.addedRules(count); len(ids) > 0 {
+			p.emit(LoadEvent{
+				Kind:      LoadEventRulesAdded,
+				File:      p.currentFile,
+				Line:      p.currentLine,
+				Directive: dir,
+				RuleIDs:   ids,
+			})
+		}
+	}
+	return nil
+}
+
+// includeRemote evaluates the directives of a file served over https,
+// optionally pinned with a checksum: Include https://host/rules.conf sha256:HEX
+func (p *Parser) includeRemote(opts string) error {
+	url, pin, _ := strings.Cut(opts, " ")
+	pin = strings.TrimSpace(pin)
+	if !strings.HasPrefix(url, "https://") {
+		return p.logAndReturnErr(fmt.Sprintf("remote include %q must use https", url))
+	}
+	if io.IsConfined(p.root) {
+		return p.logAndReturnErr(fmt.Sprintf("remote include %q is %s", url, io.ErrNotConfinable))
+	}
+	if pin != "" {
+		var ok bool
+		if pin, ok = strings.CutPrefix(pin, "sha256:"); !ok {
+			return p.logAndReturnErr(fmt.Sprintf("unsupported checksum %q, expected sha256:HEX", pin))
+		}
+		pin = strings.ToLower(pin)
+	}
+
+	data, err := p.fetchRemoteInclude(url, pin)
+	if err != nil {
+		return err
+	}
 
+	oldCurrentFile, oldCurrentLine := p.currentFile, p.currentLine
+	p.currentFile, p.currentLine = url, 0
+	p.emitFile(LoadEventFileOpened, url, oldCurrentFile, oldCurrentLine)
+	err = p.parseString(string(data))
+	if err == nil {
+		p.emitFile(LoadEventFileParsed, url, oldCurrentFile, oldCurrentLine)
+	}
+	p.currentFile, p.currentLine = oldCurrentFile, oldCurrentLine
+	if err != nil {
+		return fmt.Errorf("failed to parse string: %s", err.Error())
+	}
 	return nil
 }
 
@@ -213,6 +440,27 @@ func (p *Parser) SetRoot(root fs.FS) {
 	p.root = root
 }
 
+// ConfineFiles confines the files read and written by the directives to a
+// directory, for platforms loading configurations supplied by their users.
+// The directory is used as the root of the filesystem, like chroot: absolute
+// paths are resolved from it and paths escaping it, with .. or through
+// symbolic links, are rejected. It covers Include, the files of operators
+// such as @pmFromFile, the geo database, the unicode map, the message
+// catalogs and the paths of the audit log, debug log, uploads, data and
+// include cache. The programs, like the SecGuardianLog programs and the
+// @inspectFile scripts, and the remote sources, like the files included or
+// the operator lists fetched over https, SecGsbLookupDb URIs and
+// SecRemoteRules, are rejected as they can't be confined. It replaces the
+// root set with SetRoot.
+func (p *Parser) ConfineFiles(dir string) error {
+	root, err := io.NewConfinedFS(dir)
+	if err != nil {
+		return fmt.Errorf("invalid root directory: %s", err.Error())
+	}
+	p.root = root
+	return nil
+}
+
 // NewParser creates a new parser from a WAF instance
 // Rules and settings will be inserted into the WAF
 // rule container (RuleGroup).
@@ -220,7 +468,10 @@ func NewParser(waf *corazawaf.WAF) *Parser {
 	p := &Parser{
 		options: &DirectiveOptions{
 			WAF:      waf,
-			Datasets: make(map[string][]string),
+			Datasets: waf.Datasets(),
+			Parser: ParserConfig{
+				implicitRxFlagRules: map[string][]int{},
+			},
 		},
 		root: io.OSFS{},
 	}
@@ -228,14 +479,7 @@ func NewParser(waf *corazawaf.WAF) *Parser {
 }
 
 func NewDefaultParser() *Parser {
-	p := &Parser{
-		options: &DirectiveOptions{
-			WAF:      corazawaf.NewWAF(),
-			Datasets: make(map[string][]string),
-		},
-		root: io.OSFS{},
-	}
-	return p
+	return NewParser(corazawaf.NewWAF())
 }
 
 type ParserConfig struct {
@@ -249,4 +493,50 @@ type ParserConfig struct {
 	ConfigDir                   string
 	Root                        fs.FS
 	WorkingDir                  string
+	IncludeCacheDir             string
+	UnicodeMapFile              string
+	UnicodeCodePage             int
+	OverrideDuplicateRuleIDs    bool
+	RxDefaultFlags              string
+	HasRxDefaultFlags           bool
+	PcreMatchLimit              int
+	PcreMatchLimitRecursion     int
+	// RulePack is the namespace of the rules being loaded, see FromRulePack
+	RulePack RulePack
+	// SharedDatasets is the registry the datasets are shared with, see ShareDatasets
+	SharedDatasets *DatasetRegistry
+	// ParseMode configures how rules using unknown actions, operators or
+	// variables are handled, see SetParseMode
+	ParseMode ParseMode
+	// MaxParanoiaLevel drops the rules above this paranoia level, see
+	// SetMaxParanoiaLevel
+	MaxParanoiaLevel int
+	// MaxLineLength is the maximum length of the lines read by the parser,
+	// see SetMaxLineLength
+	MaxLineLength int
+	// droppedRuleIDs holds the IDs of the rules dropped by MaxParanoiaLevel
+	droppedRuleIDs map[int]struct{}
+	// implicitRxFlagRules holds the IDs of the rules relying on the implicit
+	// rx flags by file, until the parser reports them
+	implicitRxFlagRules map[string][]int
+	// skipReason and skipRuleID describe the rule skipped by the last
+	// directive, for the load events
+	skipReason SkipReason
+	skipRuleID int
+	// skippingChain is set while the rules chained to a rule skipped by the
+	// permissive parse mode are skipped
+	skippingChain bool
+}
+
+// clone returns a copy of the configuration whose slices and maps can be
+// changed without changing the ones of c
+func (c *ParserConfig) clone() ParserConfig {
+	clone := *c
+	clone.DisabledRuleActions = slices.Clone(c.DisabledRuleActions)
+	clone.DisabledRuleOperators = slices.Clone(c.DisabledRuleOperators)
+	clone.RuleDefaultActions = slices.Clone(c.RuleDefaultActions)
+	clone.SharedDatasets = c.SharedDatasets.clone()
+	clone.droppedRuleIDs = maps.Clone(c.droppedRuleIDs)
+	clone.implicitRxFlagRules = maps.Clone(c.implicitRxFlagRules)
+	return clone
 }
@@ -0,0 +1,167 @@
+// Copyright 2024 Juan Pablo Tosso and the OWASP Coraza contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package seclang
+
+import "strings"
+
+// LoadEventKind is the kind of a LoadEvent
+type LoadEventKind int
+
+const (
+	// LoadEventFileOpened is emitted when a file, or a remote include, is
+	// read and before its directives are evaluated
+	LoadEventFileOpened LoadEventKind = iota
+	// LoadEventFileParsed is emitted once all the directives of a file,
+	// including the ones of the files it includes, were evaluated
+	LoadEventFileParsed
+	// LoadEventRulesAdded is emitted when a directive adds rules to the WAF
+	LoadEventRulesAdded
+	// LoadEventDirectiveSkipped is emitted when a directive is not evaluated
+	// or a rule is not loaded, see SkipReason
+	LoadEventDirectiveSkipped
+)
+
+func (k LoadEventKind) String() string {
+	switch k {
+	case LoadEventFileOpened:
+		return "file_opened"
+	case LoadEventFileParsed:
+		return "file_parsed"
+	case LoadEventRulesAdded:
+		return "rules_added"
+	case LoadEventDirectiveSkipped:
+		return "directive_skipped"
+	}
+	return "unknown"
+}
+
+// SkipReason explains why a directive was skipped
+type SkipReason string
+
+const (
+	// SkipReasonIfDefine is used for the directives of an unsatisfied
+	// <IfDefine> block
+	SkipReasonIfDefine SkipReason = "IfDefine"
+	// SkipReasonParseMode is used for the rules with unknown actions,
+	// operators or variables skipped by the permissive parse mode
+	SkipReasonParseMode SkipReason = "ParseMode"
+	// SkipReasonParanoiaLevel is used for the rules above the maximum
+	// paranoia level
+	SkipReasonParanoiaLevel SkipReason = "ParanoiaLevel"
+	// SkipReasonChain is used for the rules chained to a skipped rule
+	SkipReasonChain SkipReason = "Chain"
+)
+
+// LoadEvent describes a step of the loading of a configuration
+type LoadEvent struct {
+	Kind LoadEventKind
+	// File is the file or URL being parsed, "_inline_" for the directives
+	// loaded with FromString or FromReader, the name given to FromNamedReader
+	File string
+	// Line is the line of the directive, 0 for the file events
+	Line int
+	// Include is the path, pattern or URL passed to Include or FromFile, or
+	// the name passed to FromNamedReader, that opened the file, for the file
+	// events
+	Include string
+	// Parent and ParentLine are the location of the Include directive that
+	// opened the file, Parent is empty for the files loaded with FromFile
+	Parent     string
+	ParentLine int
+	// Directive is the name of the directive, as written in the configuration
+	Directive string
+	// RuleIDs holds the IDs of the rules added by the directive, or of the
+	// rule skipped when known
+	RuleIDs []int
+	// Reason is set for LoadEventDirectiveSkipped
+	Reason SkipReason
+}
+
+// LoadObserver is called with the events of the loading of a configuration
+type LoadObserver func(LoadEvent)
+
+// OnLoadEvent registers an observer of the loading of the configuration,
+// called synchronously in the order of the events. It lets configuration
+// management tools verify which files were read and which rules each of
+// them contributed, e.g. when including globs or using merged filesystems.
+//
+// Example:
+// ```go
+//
+//	p.OnLoadEvent(func(e seclang.LoadEvent) {
+//		if e.Kind == seclang.LoadEventRulesAdded {
+//			fmt.Printf("%s:%d added rules %v\n", e.File, e.Line, e.RuleIDs)
+//		}
+//	})
+//
+// ```
+func (p *Parser) OnLoadEvent(observer LoadObserver) {
+	p.observers = append(p.observers, observer)
+}
+
+func (p *Parser) emit(e LoadEvent) {
+	for _, observer := range p.observers {
+		observer(e)
+	}
+}
+
+// emitFile emits a file event of the file being parsed, opened by the
+// Include or FromFile call with include from the parent location
+func (p *Parser) emitFile(kind LoadEventKind, include, parent string, parentLine int) {
+	if len(p.observers) == 0 {
+		return
+	}
+	p.emit(LoadEvent{
+		Kind:       kind,
+		File:       p.currentFile,
+		Include:    include,
+		Parent:     parent,
+		ParentLine: parentLine,
+	})
+}
+
+// emitSkipped emits the skipping of the directive of the current line, ruleID
+// is 0 if unknown
+func (p *Parser) emitSkipped(line string, reason SkipReason, ruleID int) {
+	if len(p.observers) == 0 {
+		return
+	}
+	dir, _, _ := strings.Cut(line, " ")
+	e := LoadEvent{
+		Kind:      LoadEventDirectiveSkipped,
+		File:      p.currentFile,
+		Line:      p.currentLine,
+		Directive: dir,
+		Reason:    reason,
+	}
+	if ruleID != 0 {
+		e.RuleIDs = []int{ruleID}
+	}
+	p.emit(e)
+}
+
+// addedRules returns the IDs of the rules added by the directive of the
+// current line, given the number of rules before it was evaluated. Rules are
+// appended to the WAF, a rule overriding a duplicate ID replaces one.
+func (p *Parser) addedRules(count int) []int {
+	rules := p.options.WAF.Rules.GetRules()
+	if len(rules) == 0 {
+		return nil
+	}
+	if len(rules) == count {
+		last := &rules[len(rules)-1]
+		if !p.options.Parser.OverrideDuplicateRuleIDs || last.File_ != p.currentFile || last.Line_ != p.currentLine {
+			return nil
+		}
+		count--
+	}
+	var ids []int
+	for i := max(count, 0); i < len(rules); i++ {
+		// markers are not reported as rules
+		if rules[i].SecMark_ == "" {
+			ids = append(ids, rules[i].ID_)
+		}
+	}
+	return ids
+}
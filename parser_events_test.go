@@ -0,0 +1,87 @@
+// Copyright 2024 Juan Pablo Tosso and the OWASP Coraza contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package seclang
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"github.com/ad3n/seclang/internal/corazawaf"
+)
+
+func TestLoadEvents(t *testing.T) {
+	root := fstest.MapFS{
+		"main.conf": &fstest.MapFile{Data: []byte(`SecRuleEngine On
+Include rules/*.conf
+<IfDefine LOCAL>
+Include local.conf
+</IfDefine>
+`)},
+		"rules/a.conf": &fstest.MapFile{Data: []byte(`SecRule ARGS "@rx a" "id:1,phase:1,pass"
+SecMarker END
+SecRule ARGS "@rx a" "id:2,phase:1,pass,tag:'paranoia-level/2',chain"
+    SecRule ARGS "@rx b" "t:none"
+`)},
+		"rules/b.conf": &fstest.MapFile{Data: []byte(`SecAction "id:3,phase:1,pass,unknownaction"
+SecRule ARGS "@rx a" "id:4,phase:1,pass,chain"
+    SecRule ARGS "@rx b" "t:none"
+`)},
+	}
+	p := NewParser(corazawaf.NewWAF())
+	p.SetRoot(root)
+	p.SetParseMode(ParseModePermissive)
+	p.SetMaxParanoiaLevel(1)
+	var events []string
+	p.OnLoadEvent(func(e LoadEvent) {
+		switch e.Kind {
+		case LoadEventFileOpened, LoadEventFileParsed:
+			events = append(events, fmt.Sprintf("%s %s (%s from %s:%d)", e.Kind, e.File, e.Include, e.Parent, e.ParentLine))
+		default:
+			events = append(events, fmt.Sprintf("%s %s:%d %s %v %s", e.Kind, e.File, e.Line, e.Directive, e.RuleIDs, e.Reason))
+		}
+	})
+	if err := p.FromFile("main.conf"); err != nil {
+		t.Fatal(err)
+	}
+	want := []string{
+		"file_opened main.conf (main.conf from :0)",
+		"file_opened rules/a.conf (rules/*.conf from main.conf:2)",
+		"rules_added rules/a.conf:1 SecRule [1] ",
+		"directive_skipped rules/a.conf:3 SecRule [2] ParanoiaLevel",
+		"directive_skipped rules/a.conf:4 SecRule [] Chain",
+		"file_parsed rules/a.conf (rules/*.conf from main.conf:2)",
+		"file_opened rules/b.conf (rules/*.conf from main.conf:2)",
+		"directive_skipped rules/b.conf:1 SecAction [3] ParseMode",
+		"rules_added rules/b.conf:2 SecRule [4] ",
+		"file_parsed rules/b.conf (rules/*.conf from main.conf:2)",
+		"directive_skipped main.conf:4 Include [] IfDefine",
+		"file_parsed main.conf (main.conf from :0)",
+	}
+	if have := strings.Join(events, "\n"); strings.Join(want, "\n") != have {
+		t.Errorf("unexpected events, want\n%s\nhave\n%s", strings.Join(want, "\n"), have)
+	}
+}
+
+func TestLoadEventsOverride(t *testing.T) {
+	p := NewParser(corazawaf.NewWAF())
+	p.OverrideDuplicateRuleIDs(true)
+	var ids [][]int
+	p.OnLoadEvent(func(e LoadEvent) {
+		if e.Kind == LoadEventRulesAdded {
+			ids = append(ids, e.RuleIDs)
+		}
+	})
+	err := p.FromString(`SecRule ARGS "@rx a" "id:1,phase:1,pass"
+SecRule ARGS "@rx a" "id:2,phase:1,pass"
+SecRule ARGS "@rx b" "id:1,phase:1,pass"
+SecRuleRemoveById 2`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want, have := "[[1] [2] [1]]", fmt.Sprint(ids); want != have {
+		t.Errorf("unexpected added rules, want %s, have %s", want, have)
+	}
+}
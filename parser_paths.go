@@ -0,0 +1,137 @@
+// Copyright 2024 Juan Pablo Tosso and the OWASP Coraza contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package seclang
+
+import (
+	"io/fs"
+	"path"
+	"runtime"
+	"slices"
+	"strings"
+
+	"github.com/ad3n/seclang/internal/io"
+)
+
+// includePaths implements the semantics of the paths of Include and FromFile.
+// The paths of an fs.FS are slash separated and case sensitive, they are
+// resolved with the path package whatever the platform. The paths of the OS
+// filesystem on Windows can also use backslashes, drive letters and UNC
+// shares, and the globs match the names case insensitively.
+type includePaths struct {
+	windows bool
+}
+
+// includePaths returns the semantics of the paths of the root of the parser
+func (p *Parser) includePaths() includePaths {
+	_, isOS := p.root.(io.OSFS)
+	return includePaths{windows: p.windowsPaths || isOS && runtime.GOOS == "windows"}
+}
+
+// resolve returns the path of name relative to dir, absolute paths are kept.
+// On Windows the backslashes are replaced with slashes, which Windows accepts
+// too.
+func (ip includePaths) resolve(dir, name string) string {
+	if !ip.windows {
+		if strings.HasPrefix(name, "/") {
+			return name
+		}
+		return path.Join(dir, name)
+	}
+	name = strings.ReplaceAll(name, `\`, "/")
+	if ip.isAbs(name) {
+		return name
+	}
+	share, dir := ip.cutShare(dir)
+	return share + path.Join(dir, name)
+}
+
+// isAbs reports whether the slash separated name is absolute, on Windows
+// paths starting with a drive letter or rooted in the current drive are
+// considered absolute
+func (ip includePaths) isAbs(name string) bool {
+	if strings.HasPrefix(name, "/") {
+		return true
+	}
+	return ip.windows && len(name) >= 2 && name[1] == ':' &&
+		('a' <= name[0] && name[0] <= 'z' || 'A' <= name[0] && name[0] <= 'Z')
+}
+
+// cutShare splits the "/" prefix of an UNC path, //server/share, which
+// path.Clean would remove
+func (ip includePaths) cutShare(name string) (string, string) {
+	if ip.windows && strings.HasPrefix(name, "//") {
+		return "/", name[1:]
+	}
+	return "", name
+}
+
+// dir returns the directory of the file
+func (ip includePaths) dir(name string) string {
+	share, name := ip.cutShare(name)
+	return share + path.Dir(name)
+}
+
+// glob returns the files matching the pattern, like fs.Glob. On Windows the
+// names are matched case insensitively, like the filesystem does.
+func (ip includePaths) glob(fsys fs.FS, pattern string) ([]string, error) {
+	if !ip.windows {
+		return fs.Glob(fsys, pattern)
+	}
+	if _, err := path.Match(pattern, ""); err != nil {
+		return nil, err
+	}
+	root := ""
+	switch {
+	case strings.HasPrefix(pattern, "//"):
+		root = "//"
+	case strings.HasPrefix(pattern, "/"):
+		root = "/"
+	}
+	var matches []string
+	if err := globFold(fsys, root, strings.Split(pattern[len(root):], "/"), &matches); err != nil {
+		return nil, err
+	}
+	slices.Sort(matches)
+	return matches, nil
+}
+
+// globFold appends to matches the files of dir matching the remaining
+// elements of the pattern, ignoring the case. Like fs.Glob, the directories
+// that can't be read are ignored.
+func globFold(fsys fs.FS, dir string, elems []string, matches *[]string) error {
+	join := func(name string) string {
+		if dir == "" || strings.HasSuffix(dir, "/") {
+			return dir + name
+		}
+		return dir + "/" + name
+	}
+	if len(elems) == 0 {
+		if _, err := fs.Stat(fsys, dir); err == nil {
+			*matches = append(*matches, dir)
+		}
+		return nil
+	}
+	elem := elems[0]
+	if !strings.ContainsAny(elem, `*?[`) {
+		return globFold(fsys, join(elem), elems[1:], matches)
+	}
+	readDir := dir
+	if readDir == "" {
+		readDir = "."
+	}
+	entries, err := fs.ReadDir(fsys, readDir)
+	if err != nil {
+		return nil
+	}
+	elem = strings.ToLower(elem)
+	for _, e := range entries {
+		if ok, _ := path.Match(elem, strings.ToLower(e.Name())); !ok {
+			continue
+		}
+		if err := globFold(fsys, join(e.Name()), elems[1:], matches); err != nil {
+			return err
+		}
+	}
+	return nil
+}
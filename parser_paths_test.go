@@ -0,0 +1,124 @@
+// Copyright 2024 Juan Pablo Tosso and the OWASP Coraza contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package seclang
+
+import (
+	"fmt"
+	"io/fs"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"github.com/ad3n/seclang/internal/corazawaf"
+)
+
+// windowsFS emulates the OS filesystem on Windows, the names can use
+// backslashes and are case insensitive. The names of the files of the map
+// are in lower case.
+type windowsFS struct {
+	files fstest.MapFS
+}
+
+func (w windowsFS) name(name string) string {
+	return strings.ToLower(strings.ReplaceAll(name, `\`, "/"))
+}
+
+func (w windowsFS) Open(name string) (fs.File, error) {
+	return w.files.Open(w.name(name))
+}
+
+func (w windowsFS) ReadDir(name string) ([]fs.DirEntry, error) {
+	return w.files.ReadDir(w.name(name))
+}
+
+func TestIncludePaths(t *testing.T) {
+	tests := []struct {
+		windows bool
+		dir     string
+		name    string
+		want    string
+		wantDir string
+	}{
+		{false, "", "rules/a.conf", "rules/a.conf", "rules"},
+		{false, "conf", "../rules/a.conf", "rules/a.conf", "rules"},
+		{false, "conf", "/etc/a.conf", "/etc/a.conf", "/etc"},
+		{false, "conf", `rules\a.conf`, `conf/rules\a.conf`, "conf"},
+		{true, "", `rules\a.conf`, "rules/a.conf", "rules"},
+		{true, "C:/waf", `rules\a.conf`, "C:/waf/rules/a.conf", "C:/waf/rules"},
+		{true, "C:/waf", `..\rules\a.conf`, "C:/rules/a.conf", "C:/rules"},
+		{true, "C:/waf", `d:\rules\a.conf`, "d:/rules/a.conf", "d:/rules"},
+		{true, "C:/waf", `\rules\a.conf`, "/rules/a.conf", "/rules"},
+		{true, "//server/share/waf", `rules\a.conf`, "//server/share/waf/rules/a.conf", "//server/share/waf/rules"},
+		{true, "C:/waf", `\\server\share\a.conf`, "//server/share/a.conf", "//server/share"},
+	}
+	for _, tt := range tests {
+		paths := includePaths{windows: tt.windows}
+		have := paths.resolve(tt.dir, tt.name)
+		if tt.want != have {
+			t.Errorf("unexpected path of %q in %q (windows %t), want %q, have %q", tt.name, tt.dir, tt.windows, tt.want, have)
+		}
+		if have := paths.dir(have); tt.wantDir != have {
+			t.Errorf("unexpected directory of %q (windows %t), want %q, have %q", tt.want, tt.windows, tt.wantDir, have)
+		}
+	}
+}
+
+func TestIncludeWindowsPaths(t *testing.T) {
+	root := windowsFS{files: fstest.MapFS{
+		"c:/waf/main.conf": &fstest.MapFile{Data: []byte(`Include Rules\*.CONF
+Include ..\shared\Exclusions.conf`)},
+		"c:/waf/rules/a.conf":         &fstest.MapFile{Data: []byte(`SecRule ARGS "@rx a" "id:1,phase:1,pass"`)},
+		"c:/waf/rules/b.conf":         &fstest.MapFile{Data: []byte(`SecRule ARGS "@rx b" "id:2,phase:1,pass"`)},
+		"c:/waf/rules/c.txt":          &fstest.MapFile{Data: []byte(`invalid`)},
+		"c:/shared/exclusions.conf":   &fstest.MapFile{Data: []byte(`Include D:\extra\*\*.conf`)},
+		"d:/extra/one/rules.conf":     &fstest.MapFile{Data: []byte(`SecRule ARGS "@rx c" "id:3,phase:1,pass"`)},
+		"d:/extra/two/rules.conf.bak": &fstest.MapFile{Data: []byte(`invalid`)},
+	}}
+
+	waf := corazawaf.NewWAF()
+	p := NewParser(waf)
+	p.SetRoot(root)
+	p.windowsPaths = true
+	var files []string
+	p.OnLoadEvent(func(e LoadEvent) {
+		if e.Kind == LoadEventFileOpened {
+			files = append(files, e.File)
+		}
+	})
+	if err := p.FromFile(`C:\waf\main.conf`); err != nil {
+		t.Fatal(err)
+	}
+	want := "[C:/waf/main.conf C:/waf/Rules/a.conf C:/waf/Rules/b.conf C:/shared/Exclusions.conf D:/extra/one/rules.conf]"
+	if have := fmt.Sprint(files); want != have {
+		t.Errorf("unexpected files, want %s, have %s", want, have)
+	}
+	if want, have := 3, waf.Rules.Count(); want != have {
+		t.Errorf("unexpected number of rules, want %d, have %d", want, have)
+	}
+
+	if err := p.FromFile(`C:\waf\*.missing`); err == nil {
+		t.Error("expected error for a pattern without matches")
+	}
+	if err := p.FromFile(`C:\waf\[*.conf`); err == nil {
+		t.Error("expected error for an invalid pattern")
+	}
+}
+
+func TestIncludeRelativeGlob(t *testing.T) {
+	root := fstest.MapFS{
+		"waf/main.conf":    &fstest.MapFile{Data: []byte(`Include rules/*.conf`)},
+		"waf/rules/a.conf": &fstest.MapFile{Data: []byte(`SecRule ARGS "@rx a" "id:1,phase:1,pass"`)},
+		"rules/b.conf":     &fstest.MapFile{Data: []byte(`SecRule ARGS "@rx b" "id:2,phase:1,pass"`)},
+	}
+	waf := corazawaf.NewWAF()
+	p := NewParser(waf)
+	p.SetRoot(root)
+	// the pattern is resolved from the directory of the including file
+	if err := p.FromFile("waf/main.conf"); err != nil {
+		t.Fatal(err)
+	}
+	if waf.Rules.Count() != 1 || waf.Rules.FindByID(1) == nil {
+		t.Errorf("expected only the rule of waf/rules/a.conf, have %d rules", waf.Rules.Count())
+	}
+}
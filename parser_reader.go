@@ -0,0 +1,84 @@
+// Copyright 2024 Juan Pablo Tosso and the OWASP Coraza contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package seclang
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"io/fs"
+)
+
+// FromReader imports directives from a reader, it is meant for large
+// generated configurations that shouldn't be loaded in memory at once.
+// Directives are evaluated as soon as they are read, including the ones
+// split across lines with backslashes or backtick blocks, so only the
+// directive being read is kept in memory.
+// It will return error if any directive fails to parse or the reader fails.
+func (p *Parser) FromReader(r io.Reader) error {
+	oldCurrentFile := p.currentFile
+	p.currentFile = "_inline_"
+	err := p.parseLines(p.newScanner(r))
+	p.reportImplicitRxFlags()
+	p.currentFile = oldCurrentFile
+	return err
+}
+
+// FromNamedReader imports directives from a reader like FromReader, name is
+// used as the file of the directives, e.g. in the location of the rules and
+// the load events, like for the files loaded with FromFile. It lets embedders
+// stream rules from object storage, databases or encrypted blobs without
+// writing temporary files. The relative includes are resolved like for
+// FromString, name doesn't need to be a path of the root.
+func (p *Parser) FromNamedReader(r io.Reader, name string) error {
+	oldCurrentFile, oldCurrentLine := p.currentFile, p.currentLine
+	p.currentFile, p.currentLine = name, 0
+	p.emitFile(LoadEventFileOpened, name, oldCurrentFile, oldCurrentLine)
+	err := p.parseLines(p.newScanner(r))
+	p.reportImplicitRxFlags()
+	if err == nil {
+		p.emitFile(LoadEventFileParsed, name, oldCurrentFile, oldCurrentLine)
+	}
+	p.currentFile, p.currentLine = oldCurrentFile, oldCurrentLine
+	return err
+}
+
+// defaultMaxLineLength is the default maximum length of the lines read by the
+// parser, larger than the 64KiB of bufio.Scanner for the generated rules
+// holding long lists, e.g. of @pm
+const defaultMaxLineLength = 16 << 20
+
+// SetMaxLineLength configures the maximum length in bytes of the lines read
+// by the parser, the configurations with longer lines fail to load. 0, or
+// less, restores the default of 16MiB.
+func (p *Parser) SetMaxLineLength(n int) {
+	p.options.Parser.MaxLineLength = n
+}
+
+// maxLineLength returns the maximum length of the lines read by the parser
+func (p *Parser) maxLineLength() int {
+	if n := p.options.Parser.MaxLineLength; n > 0 {
+		return n
+	}
+	return defaultMaxLineLength
+}
+
+// newScanner returns a scanner reading the lines of r up to the maximum line
+// length of the parser
+func (p *Parser) newScanner(r io.Reader) *bufio.Scanner {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(nil, p.maxLineLength())
+	return scanner
+}
+
+// FromFSFile imports the directives of an opened file, e.g. of an fs.FS that
+// is not the root of the parser, named after the name of the file. The file
+// is not closed.
+func (p *Parser) FromFSFile(f fs.File) error {
+	info, err := f.Stat()
+	if err != nil {
+		return fmt.Errorf("failed to stat file: %s", err.Error())
+	}
+	return p.FromNamedReader(f, info.Name())
+}
@@ -0,0 +1,161 @@
+// Copyright 2024 Juan Pablo Tosso and the OWASP Coraza contributors
+// SPDX-License-Identifier: Apache-2.0
+
+//go:build !tinygo
+// +build !tinygo
+
+package seclang
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/ad3n/seclang/internal/environment"
+)
+
+// remoteIncludeClient is the client used to fetch remote includes
+type remoteIncludeClient = *http.Client
+
+// maxRemoteIncludeSize limits the size of a remote include to avoid
+// exhausting memory with a misbehaving server
+const maxRemoteIncludeSize = 64 << 20
+
+var defaultRemoteIncludeClient = &http.Client{
+	Timeout: 10 * time.Second,
+}
+
+// remoteIncludeMeta is stored next to a cached include to revalidate it
+type remoteIncludeMeta struct {
+	URL          string `json:"url"`
+	ETag         string `json:"etag,omitempty"`
+	LastModified string `json:"last_modified,omitempty"`
+}
+
+// SetHTTPClient sets the client used to fetch remote includes,
+// by default a client with a 10 seconds timeout is used.
+func (p *Parser) SetHTTPClient(c *http.Client) {
+	p.remoteClient = c
+}
+
+// fetchRemoteInclude downloads an include from an https URL. If a cache directory
+// is configured, the cached copy is revalidated using ETag and Last-Modified and it
+// is used as fallback when the server is not reachable. If pin is not empty, the
+// content must match the sha256 checksum.
+func (p *Parser) fetchRemoteInclude(url string, pin string) ([]byte, error) {
+	client := p.remoteClient
+	if client == nil {
+		client = defaultRemoteIncludeClient
+	}
+
+	cacheDir := p.options.Parser.IncludeCacheDir
+	if !environment.HasAccessToFS {
+		cacheDir = ""
+	}
+	var cachedBody []byte
+	var meta remoteIncludeMeta
+	var bodyPath, metaPath string
+	if cacheDir != "" {
+		key := sha256.Sum256([]byte(url))
+		name := hex.EncodeToString(key[:])
+		bodyPath = filepath.Join(cacheDir, name+".conf")
+		metaPath = filepath.Join(cacheDir, name+".json")
+		if b, err := os.ReadFile(bodyPath); err == nil {
+			cachedBody = b
+			if m, err := os.ReadFile(metaPath); err == nil {
+				_ = json.Unmarshal(m, &meta)
+			}
+		}
+	}
+
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	if cachedBody != nil {
+		if meta.ETag != "" {
+			req.Header.Set("If-None-Match", meta.ETag)
+		}
+		if meta.LastModified != "" {
+			req.Header.Set("If-Modified-Since", meta.LastModified)
+		}
+	}
+
+	res, err := client.Do(req)
+	if err != nil {
+		if cachedBody == nil {
+			return nil, fmt.Errorf("failed to fetch %s: %s", url, err.Error())
+		}
+		p.options.WAF.Logger.Warn().
+			Str("url", url).
+			Err(err).
+			Msg("Failed to fetch remote include, using cached copy")
+		return cachedBody, verifyIncludePin(cachedBody, pin)
+	}
+	defer res.Body.Close()
+
+	switch {
+	case res.StatusCode == http.StatusNotModified && cachedBody != nil:
+		return cachedBody, verifyIncludePin(cachedBody, pin)
+	case res.StatusCode != http.StatusOK:
+		return nil, fmt.Errorf("failed to fetch %s: unexpected status %d", url, res.StatusCode)
+	}
+
+	body, err := io.ReadAll(io.LimitReader(res.Body, maxRemoteIncludeSize+1))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %s", url, err.Error())
+	}
+	if len(body) > maxRemoteIncludeSize {
+		return nil, fmt.Errorf("remote include %s is bigger than %d bytes", url, maxRemoteIncludeSize)
+	}
+	if err := verifyIncludePin(body, pin); err != nil {
+		return nil, err
+	}
+
+	if cacheDir != "" {
+		meta = remoteIncludeMeta{
+			URL:          url,
+			ETag:         res.Header.Get("ETag"),
+			LastModified: res.Header.Get("Last-Modified"),
+		}
+		if err := writeRemoteIncludeCache(cacheDir, bodyPath, metaPath, body, meta); err != nil {
+			p.options.WAF.Logger.Warn().
+				Str("url", url).
+				Err(err).
+				Msg("Failed to cache remote include")
+		}
+	}
+	return body, nil
+}
+
+func writeRemoteIncludeCache(dir, bodyPath, metaPath string, body []byte, meta remoteIncludeMeta) error {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return err
+	}
+	m, err := json.Marshal(meta)
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(bodyPath, body, 0600); err != nil {
+		return err
+	}
+	return os.WriteFile(metaPath, m, 0600)
+}
+
+func verifyIncludePin(body []byte, pin string) error {
+	if pin == "" {
+		return nil
+	}
+	sum := sha256.Sum256(body)
+	if hex.EncodeToString(sum[:]) != pin {
+		return errors.New("checksum mismatch for remote include")
+	}
+	return nil
+}
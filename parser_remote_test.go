@@ -0,0 +1,114 @@
+// Copyright 2024 Juan Pablo Tosso and the OWASP Coraza contributors
+// SPDX-License-Identifier: Apache-2.0
+
+//go:build !tinygo
+// +build !tinygo
+
+package seclang
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/ad3n/seclang/internal/corazawaf"
+)
+
+const remoteRules = `SecRule ARGS "@rx attack" "id:1,phase:2,deny"`
+
+func newRemoteRulesServer(t *testing.T, requests *int, revalidated *int) *httptest.Server {
+	t.Helper()
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		*requests++
+		if r.Header.Get("If-None-Match") == `"v1"` {
+			*revalidated++
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("ETag", `"v1"`)
+		fmt.Fprint(w, remoteRules)
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestRemoteInclude(t *testing.T) {
+	var requests, revalidated int
+	srv := newRemoteRulesServer(t, &requests, &revalidated)
+	cacheDir := t.TempDir()
+
+	load := func() (*corazawaf.WAF, error) {
+		waf := corazawaf.NewWAF()
+		p := NewParser(waf)
+		p.SetHTTPClient(srv.Client())
+		err := p.FromString(fmt.Sprintf("SecIncludeCacheDir %s\nInclude %s/rules.conf", cacheDir, srv.URL))
+		return waf, err
+	}
+
+	waf, err := load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if waf.Rules.Count() != 1 {
+		t.Fatalf("expected 1 rule, got %d", waf.Rules.Count())
+	}
+	if want, have := srv.URL+"/rules.conf", waf.Rules.GetRules()[0].File(); want != have {
+		t.Errorf("unexpected rule file, want %q, have %q", want, have)
+	}
+
+	// The cached copy is revalidated with the stored ETag
+	if waf, err = load(); err != nil {
+		t.Fatal(err)
+	}
+	if waf.Rules.Count() != 1 || revalidated != 1 {
+		t.Errorf("expected the cached copy to be revalidated, rules: %d, revalidated: %d", waf.Rules.Count(), revalidated)
+	}
+
+	// The cached copy is used when the server is not reachable
+	srv.Close()
+	if waf, err = load(); err != nil {
+		t.Fatal(err)
+	}
+	if waf.Rules.Count() != 1 {
+		t.Errorf("expected the cached copy to be used, got %d rules", waf.Rules.Count())
+	}
+}
+
+func TestRemoteIncludePin(t *testing.T) {
+	var requests, revalidated int
+	srv := newRemoteRulesServer(t, &requests, &revalidated)
+	sum := sha256.Sum256([]byte(remoteRules))
+
+	tests := []struct {
+		pin     string
+		wantErr bool
+	}{
+		{"sha256:" + hex.EncodeToString(sum[:]), false},
+		{"sha256:0000", true},
+		{"md5:0000", true},
+	}
+	for _, tc := range tests {
+		t.Run(tc.pin, func(t *testing.T) {
+			waf := corazawaf.NewWAF()
+			p := NewParser(waf)
+			p.SetHTTPClient(srv.Client())
+			err := p.FromString(fmt.Sprintf("Include %s/rules.conf %s", srv.URL, tc.pin))
+			if tc.wantErr && err == nil {
+				t.Error("expected error")
+			}
+			if !tc.wantErr && err != nil {
+				t.Errorf("unexpected error: %s", err.Error())
+			}
+		})
+	}
+}
+
+func TestRemoteIncludeRequiresHTTPS(t *testing.T) {
+	p := NewParser(corazawaf.NewWAF())
+	if err := p.FromString("Include http://example.com/rules.conf"); err == nil {
+		t.Error("expected error for plain http include")
+	}
+}
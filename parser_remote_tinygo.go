@@ -0,0 +1,15 @@
+// Copyright 2024 Juan Pablo Tosso and the OWASP Coraza contributors
+// SPDX-License-Identifier: Apache-2.0
+
+//go:build tinygo
+// +build tinygo
+
+package seclang
+
+import "errors"
+
+type remoteIncludeClient = struct{}
+
+func (p *Parser) fetchRemoteInclude(string, string) ([]byte, error) {
+	return nil, errors.New("remote includes are not supported")
+}
@@ -0,0 +1,139 @@
+// Copyright 2024 Juan Pablo Tosso and the OWASP Coraza contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package seclang
+
+import (
+	"encoding/gob"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/ad3n/seclang/experimental/plugins/plugintypes"
+	"github.com/ad3n/seclang/internal/actions"
+	"github.com/ad3n/seclang/internal/corazawaf"
+	"github.com/ad3n/seclang/internal/operators"
+)
+
+// snapshotFormatVersion must be increased every time the snapshot
+// layout or the meaning of its entries changes
+const snapshotFormatVersion = 3
+
+// ErrStaleSnapshot is returned by FromSnapshot when the snapshot was written
+// by an incompatible version of the parser or for another rules version.
+// Callers are expected to parse the rules again and write a new snapshot.
+var ErrStaleSnapshot = errors.New("stale rules snapshot")
+
+type rulesSnapshot struct {
+	Format  int
+	Version string
+	Rules   []corazawaf.RuleDefinition
+	// Datasets are the datasets the operators of the rules are created with
+	Datasets map[string][]string
+}
+
+// WriteSnapshot writes a binary snapshot of the rules compiled into the WAF
+// of the parser, as they are after the rules removed or updated by the
+// directives, along with the datasets of the parser. The configuration of
+// the WAF, e.g. SecRuleEngine, is not part of the snapshot and is set as
+// usual before restoring it.
+// version identifies the rule set (e.g. the CRS version or a hash of the
+// configuration) and must match the one passed to FromSnapshot.
+func (p *Parser) WriteSnapshot(w io.Writer, version string) error {
+	s := rulesSnapshot{
+		Format:   snapshotFormatVersion,
+		Version:  version,
+		Datasets: p.options.WAF.Datasets(),
+	}
+	for _, r := range p.options.WAF.Rules.GetRules() {
+		s.Rules = append(s.Rules, r.Definition())
+	}
+	return gob.NewEncoder(w).Encode(s)
+}
+
+// FromSnapshot adds the rules stored in a snapshot written by WriteSnapshot
+// to the WAF of the parser, without parsing their directives. It returns
+// ErrStaleSnapshot if the snapshot does not match the parser format or the
+// requested version.
+// Operators holding compiled state, like @rx or @pm, are built again
+// while restoring, and data files referenced by operators such as
+// @pmFromFile are still read from the parser root.
+func (p *Parser) FromSnapshot(r io.Reader, version string) error {
+	var s rulesSnapshot
+	if err := gob.NewDecoder(r).Decode(&s); err != nil {
+		return fmt.Errorf("failed to decode snapshot: %s", err.Error())
+	}
+	if s.Format != snapshotFormatVersion || s.Version != version {
+		return ErrStaleSnapshot
+	}
+
+	datasets := p.options.WAF.Datasets()
+	for name, values := range s.Datasets {
+		datasets[name] = values
+	}
+	for _, d := range s.Rules {
+		rule, err := p.compileDefinition(d)
+		if err == nil {
+			err = addRule(p.options.WAF, p.options.Parser, rule)
+		}
+		if err != nil {
+			return fmt.Errorf("failed to restore %s:%d: %w", d.File, d.Line, err)
+		}
+	}
+	return nil
+}
+
+// compileDefinition creates the rule described by the definition, and its
+// chain, with the operators and actions registered in the parser
+func (p *Parser) compileDefinition(d corazawaf.RuleDefinition) (*corazawaf.Rule, error) {
+	rule := corazawaf.NewRule()
+	rule.SetUnicodeMap(p.options.WAF.UnicodeMap)
+	for _, v := range d.Variables {
+		if err := rule.AddVariableDefinition(v); err != nil {
+			return nil, err
+		}
+	}
+	if op := d.Operator; op != nil {
+		opts := op.Options
+		opts.Root = p.root
+		opts.Datasets = p.options.WAF.Datasets()
+		opts.DatasetFeeds = p.options.WAF.DatasetFeeds()
+		if shared := p.options.Parser.SharedDatasets; shared != nil {
+			opts.Datasets = shared.resolve(opts.Datasets)
+		}
+		fn, err := operators.Get(op.Name(), opts)
+		if err != nil {
+			return nil, err
+		}
+		rule.SetOperator(fn, op.Function, opts.Arguments)
+		rule.SetOperatorOptions(opts)
+	}
+	for _, a := range d.Actions {
+		fn, err := actions.Get(a.Name)
+		if err != nil {
+			return nil, err
+		}
+		if err := rule.InitAction(a.Name, a.Arguments, fn); err != nil {
+			return nil, fmt.Errorf("failed to init action %s: %s", a.Name, err.Error())
+		}
+		if fn.Type() != plugintypes.ActionTypeMetadata {
+			if err := rule.AddAction(a.Name, fn); err != nil {
+				return nil, err
+			}
+		}
+	}
+	rule.File_, rule.Line_, rule.Raw_ = d.File, d.Line, d.Raw
+	if d.SecMark != "" {
+		rule.SecMark_ = d.SecMark
+		rule.ID_, rule.LogID_, rule.Phase_ = 0, "0", 0
+	}
+	if d.Chain != nil {
+		chain, err := p.compileDefinition(*d.Chain)
+		if err != nil {
+			return nil, err
+		}
+		chain.ParentID_, chain.LogID_, chain.Phase_ = rule.ID_, rule.LogID_, 0
+		rule.Chain = chain
+	}
+	return rule, nil
+}
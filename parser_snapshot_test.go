@@ -0,0 +1,84 @@
+// Copyright 2024 Juan Pablo Tosso and the OWASP Coraza contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package seclang
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/ad3n/seclang/internal/corazawaf"
+)
+
+func TestSnapshot(t *testing.T) {
+	dir := t.TempDir()
+	include := filepath.Join(dir, "rules.conf")
+	if err := os.WriteFile(include, []byte(`SecRule ARGS:id "@eq 0" "id:1,phase:1,deny,status:403"`), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	waf := corazawaf.NewWAF()
+	p := NewParser(waf)
+	if err := p.FromString("SecRuleEngine On\nInclude " + include); err != nil {
+		t.Fatal(err)
+	}
+	if err := p.FromString(`SecRule ARGS|!ARGS:skip "@rx ^x" "id:3,phase:1,deny,chain"
+    SecRule REQUEST_METHOD "@streq POST" ""
+SecRule ARGS "@rx ^y" "id:4,phase:1,deny"
+SecRuleRemoveById 4
+SecRuleUpdateTargetById 3 "!ARGS:other"`); err != nil {
+		t.Fatal(err)
+	}
+	if err := p.FromJSON([]byte(`[{"id": 2, "phase": 1, "variables": ["ARGS:id"], "operator": "@eq 1", "actions": [{"name": "deny"}]}]`)); err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	if err := p.WriteSnapshot(&buf, "v1"); err != nil {
+		t.Fatal(err)
+	}
+	snapshot := buf.Bytes()
+
+	// the snapshot must not depend on the included files anymore
+	if err := os.Remove(include); err != nil {
+		t.Fatal(err)
+	}
+
+	restored := corazawaf.NewWAF()
+	if err := NewParser(restored).FromSnapshot(bytes.NewReader(snapshot), "v1"); err != nil {
+		t.Fatal(err)
+	}
+	if want, have := waf.Rules.Count(), restored.Rules.Count(); want != have {
+		t.Errorf("unexpected number of rules, want %d, have %d", want, have)
+	}
+	if want, have := include, restored.Rules.FindByID(1).File(); want != have {
+		t.Errorf("unexpected rule file, want %q, have %q", want, have)
+	}
+	for _, id := range []string{"0", "1"} {
+		tx := restored.NewTransaction()
+		tx.AddGetRequestArgument("id", id)
+		if it := tx.ProcessRequestHeaders(); it == nil {
+			t.Errorf("expected restored rules to interrupt id=%s", id)
+		}
+	}
+
+	// the exceptions and the chain are restored
+	for arg, interrupted := range map[string]bool{"a": true, "skip": false, "other": false} {
+		tx := restored.NewTransaction()
+		tx.ProcessURI("/", "POST", "HTTP/1.1")
+		tx.AddGetRequestArgument(arg, "xyz")
+		if it := tx.ProcessRequestHeaders(); (it != nil) != interrupted {
+			t.Errorf("unexpected interruption for %s, want %t, have %v", arg, interrupted, it)
+		}
+	}
+	if restored.Rules.FindByID(4) != nil {
+		t.Error("unexpected restored rule removed by SecRuleRemoveById")
+	}
+
+	err := NewParser(corazawaf.NewWAF()).FromSnapshot(bytes.NewReader(snapshot), "v2")
+	if !errors.Is(err, ErrStaleSnapshot) {
+		t.Errorf("expected stale snapshot error, have %v", err)
+	}
+}
@@ -7,12 +7,16 @@ import (
 	"bufio"
 	"bytes"
 	"embed"
+	"errors"
 	"fmt"
 	"io/fs"
 	"os"
 	"path/filepath"
+	"slices"
 	"strings"
 	"testing"
+	"testing/fstest"
+	"testing/iotest"
 
 	"github.com/jcchavezs/mergefs"
 	"github.com/jcchavezs/mergefs/io"
@@ -21,6 +25,7 @@ import (
 
 	coreruleset "github.com/corazawaf/coraza-coreruleset"
 	"github.com/corazawaf/coraza/v3/debuglog"
+	"github.com/corazawaf/coraza/v3/types"
 )
 
 //go:embed testdata
@@ -89,6 +94,230 @@ func TestErrorWithBackticks(t *testing.T) {
 	}
 }
 
+func TestFromReader(t *testing.T) {
+	rules := "SecRule ARGS:id \"@eq 1\" \\\n" +
+		"    \"id:1,phase:1,\\\n" +
+		"    deny,status:403\"\n" +
+		"SecDataset ips `\n" +
+		"127.0.0.1\n" +
+		"10.0.0.1\n" +
+		"`\n" +
+		"SecRule REMOTE_ADDR \"@ipMatchFromDataset ips\" \"id:2,phase:1,pass,nolog\"\n"
+	waf := coraza.NewWAF()
+	p := NewParser(waf)
+	// chunks of a single byte split continuations and backtick blocks
+	if err := p.FromReader(iotest.OneByteReader(strings.NewReader(rules))); err != nil {
+		t.Fatal(err)
+	}
+	if want, have := 2, waf.Rules.Count(); want != have {
+		t.Fatalf("unexpected number of rules, want %d, have %d", want, have)
+	}
+	if want, have := []string{"127.0.0.1", "10.0.0.1"}, p.options.WAF.Datasets()["ips"]; !slices.Equal(want, have) {
+		t.Errorf("unexpected dataset, want %q, have %q", want, have)
+	}
+	if want, have := 3, waf.Rules.FindByID(1).Line_; want != have {
+		t.Errorf("unexpected rule line, want %d, have %d", want, have)
+	}
+
+	errRead := errors.New("connection reset")
+	err := NewParser(coraza.NewWAF()).FromReader(iotest.ErrReader(errRead))
+	if err == nil || !strings.Contains(err.Error(), errRead.Error()) {
+		t.Errorf("expected reader error, have %v", err)
+	}
+}
+
+func TestFromReaderLongLine(t *testing.T) {
+	// a generated @pm list longer than the 64KiB of bufio.Scanner
+	words := strings.Repeat("word ", 20000)
+	rule := `SecRule ARGS "@pm ` + words + `" "id:1,phase:1,pass,nolog"` + "\n"
+	waf := coraza.NewWAF()
+	if err := NewParser(waf).FromReader(strings.NewReader(rule)); err != nil {
+		t.Fatal(err)
+	}
+	if waf.Rules.FindByID(1) == nil {
+		t.Error("expected the rule of the long line")
+	}
+
+	p := NewParser(coraza.NewWAF())
+	p.SetMaxLineLength(1024)
+	err := p.FromNamedReader(strings.NewReader(rule), "long.conf")
+	if err == nil || !strings.Contains(err.Error(), "longer than 1024 bytes") {
+		t.Errorf("expected the line length error, have %v", err)
+	}
+}
+
+func TestFromNamedReader(t *testing.T) {
+	waf := coraza.NewWAF()
+	p := NewParser(waf)
+	if err := p.FromString(`SecAction "id:1,phase:1,pass,nolog"`); err != nil {
+		t.Fatal(err)
+	}
+	rules := "SecAction \"id:2,phase:1,pass,nolog\"\n\nSecAction \"id:3,phase:1,pass,nolog\"\n"
+	if err := p.FromNamedReader(strings.NewReader(rules), "s3://rules/custom.conf"); err != nil {
+		t.Fatal(err)
+	}
+	if want, have := "s3://rules/custom.conf:3", waf.Rules.FindByID(3).Location(); want != have {
+		t.Errorf("unexpected rule location, want %q, have %q", want, have)
+	}
+
+	f, err := fstest.MapFS{"dir/local.conf": &fstest.MapFile{Data: []byte(`SecAction "id:4,phase:1,pass,nolog"`)}}.Open("dir/local.conf")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if err := p.FromFSFile(f); err != nil {
+		t.Fatal(err)
+	}
+	if want, have := "local.conf:1", waf.Rules.FindByID(4).Location(); want != have {
+		t.Errorf("unexpected rule location, want %q, have %q", want, have)
+	}
+}
+
+func TestIfDefine(t *testing.T) {
+	rules := `
+<IfDefine PRODUCTION>
+SecRuleEngine On
+SecAction "id:1,phase:1,pass,nolog"
+<IfDefine !VERBOSE>
+SecAction "id:2,phase:1,pass,nolog"
+</IfDefine>
+</IfDefine>
+<IfDefine !PRODUCTION>
+SecRuleEngine DetectionOnly
+SecAction "id:3,phase:1,pass,nolog"
+</IfDefine>
+`
+	for _, test := range []struct {
+		name    string
+		defines []string
+		want    []int
+	}{
+		{"no defines", nil, []int{3}},
+		{"production", []string{"PRODUCTION"}, []int{1, 2}},
+		{"production verbose", []string{"PRODUCTION", "VERBOSE"}, []int{1}},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			waf := coraza.NewWAF()
+			p := NewParser(waf)
+			for _, d := range test.defines {
+				p.Define(d)
+			}
+			if err := p.FromString(rules); err != nil {
+				t.Fatal(err)
+			}
+			var have []int
+			for _, r := range waf.Rules.GetRules() {
+				have = append(have, r.ID_)
+			}
+			if fmt.Sprint(test.want) != fmt.Sprint(have) {
+				t.Errorf("unexpected rules, want %v, have %v", test.want, have)
+			}
+		})
+	}
+
+	for _, invalid := range []string{
+		"<IfDefine PRODUCTION>\nSecRuleEngine On",
+		"</IfDefine>",
+		"<IfDefine>\nSecRuleEngine On\n</IfDefine>",
+	} {
+		p := NewParser(coraza.NewWAF())
+		if err := p.FromString(invalid); err == nil {
+			t.Errorf("expected error for %q", invalid)
+		}
+	}
+}
+
+func TestDuplicateRuleIDs(t *testing.T) {
+	root := fstest.MapFS{
+		"rules/main.conf":   {Data: []byte("Include vendor.conf\nInclude local.conf\nSecAction \"id:3,phase:1,pass\"\n")},
+		"rules/vendor.conf": {Data: []byte("SecRule ARGS \"@rx a\" \"id:1,phase:1,pass,msg:'vendor'\"\nSecAction \"id:2,phase:1,pass,msg:'vendor'\"\n")},
+		"rules/local.conf":  {Data: []byte("# local overrides\nSecRule ARGS \"@rx b\" \"id:1,phase:1,pass,msg:'local'\"\n")},
+	}
+
+	p := NewParser(coraza.NewWAF())
+	p.SetRoot(root)
+	err := p.FromFile("rules/main.conf")
+	if err == nil {
+		t.Fatal("expected error for duplicate rule id")
+	}
+	for _, location := range []string{"rules/vendor.conf:1", "rules/local.conf:2"} {
+		if !strings.Contains(err.Error(), location) {
+			t.Errorf("expected error to contain %q, have %q", location, err.Error())
+		}
+	}
+
+	waf := coraza.NewWAF()
+	p = NewParser(waf)
+	p.SetRoot(root)
+	p.OverrideDuplicateRuleIDs(true)
+	if err := p.FromFile("rules/main.conf"); err != nil {
+		t.Fatal(err)
+	}
+	rules := waf.Rules.GetRules()
+	if want, have := 3, len(rules); want != have {
+		t.Fatalf("unexpected rule count, want %d, have %d", want, have)
+	}
+	// the overriding rule is evaluated at its own position
+	if want, have := 1, rules[1].ID_; want != have {
+		t.Errorf("unexpected last rule, want %d, have %d", want, have)
+	}
+	if want, have := "local", rules[1].Msg.String(); want != have {
+		t.Errorf("unexpected msg of the overridden rule, want %q, have %q", want, have)
+	}
+	// the location of the including file is restored after the includes
+	if want, have := "rules/main.conf:3", rules[2].Location(); want != have {
+		t.Errorf("unexpected location, want %q, have %q", want, have)
+	}
+}
+
+func TestOnDirective(t *testing.T) {
+	root := fstest.MapFS{
+		"rules/main.conf": {Data: []byte("SecRuleEngine On\nInclude crs.conf\n")},
+		"rules/crs.conf":  {Data: []byte("# CRS\nSecAction \"id:1,phase:1,pass\"\n")},
+	}
+
+	type directive struct {
+		file      string
+		line      int
+		directive string
+		args      string
+	}
+	var parsed []directive
+	p := NewParser(coraza.NewWAF())
+	p.SetRoot(root)
+	p.OnDirective(func(file string, line int, d, args string) error {
+		parsed = append(parsed, directive{file, line, d, args})
+		return nil
+	})
+	if err := p.FromFile("rules/main.conf"); err != nil {
+		t.Fatal(err)
+	}
+	want := []directive{
+		{"rules/main.conf", 1, "SecRuleEngine", "On"},
+		{"rules/main.conf", 2, "Include", "crs.conf"},
+		{"rules/crs.conf", 2, "SecAction", "id:1,phase:1,pass"},
+	}
+	if fmt.Sprint(want) != fmt.Sprint(parsed) {
+		t.Errorf("unexpected directives, want %v, have %v", want, parsed)
+	}
+
+	waf := coraza.NewWAF()
+	p = NewParser(waf)
+	p.OnDirective(func(file string, line int, d, args string) error {
+		if strings.EqualFold(d, "SecRuleEngine") && strings.EqualFold(args, "Off") {
+			return fmt.Errorf("%s:%d: SecRuleEngine Off is not allowed", file, line)
+		}
+		return nil
+	})
+	err := p.FromString("SecRuleEngine DetectionOnly\nSecRuleEngine Off")
+	if err == nil || !strings.Contains(err.Error(), "SecRuleEngine Off is not allowed") {
+		t.Fatalf("expected policy error, have %v", err)
+	}
+	if want, have := types.RuleEngineDetectionOnly, waf.RuleEngine; want != have {
+		t.Errorf("unexpected rule engine, want %s, have %s", want, have)
+	}
+}
+
 func TestLoadConfigurationFile(t *testing.T) {
 	waf := coraza.NewWAF()
 	p := NewParser(waf)
@@ -287,6 +516,71 @@ func TestEmbedFS(t *testing.T) {
 	}
 }
 
+func TestConfineFiles(t *testing.T) {
+	outside := t.TempDir()
+	if err := os.WriteFile(filepath.Join(outside, "secret.conf"), []byte(`SecAction "id:2,pass"`), 0600); err != nil {
+		t.Fatal(err)
+	}
+	dir := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(dir, "rules"), 0700); err != nil {
+		t.Fatal(err)
+	}
+	files := map[string]string{
+		"main.conf":        "Include rules/*.conf",
+		"rules/a.conf":     `SecRule ARGS "@pmFromFile /rules/words.data" "id:1,pass"`,
+		"rules/words.data": "attack",
+	}
+	for name, data := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(data), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	waf := coraza.NewWAF()
+	p := NewParser(waf)
+	if err := p.ConfineFiles(dir); err != nil {
+		t.Fatal(err)
+	}
+	if err := p.FromFile("/main.conf"); err != nil {
+		t.Fatal(err)
+	}
+	if want, have := 1, waf.Rules.Count(); want != have {
+		t.Errorf("unexpected number of rules, want %d, have %d", want, have)
+	}
+	if err := p.FromString("SecAuditLog /logs/audit.log"); err != nil {
+		t.Fatal(err)
+	}
+	if want, have := filepath.Join(dir, "logs", "audit.log"), waf.AuditLogWriterConfig.Target; want != have {
+		t.Errorf("unexpected audit log, want %q, have %q", want, have)
+	}
+
+	relOutside, err := filepath.Rel(dir, filepath.Join(outside, "secret.conf"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, directive := range []string{
+		"Include " + relOutside,
+		"Include " + filepath.Join(outside, "secret.conf"),
+		`SecRule ARGS "@pmFromFile ../../words.data" "id:3,pass"`,
+		"SecAuditLog ../audit.log",
+		"SecDebugLog /../debug.log",
+		"SecGuardianLog |/usr/bin/true",
+		"Include https://rules.example.com/shared.conf",
+		`SecRule ARGS "@pmFromFile https://lists.example.com/words.txt" "id:4,pass"`,
+		`SecRule FILES_TMPNAMES "@inspectFile /usr/bin/true" "id:5,pass"`,
+		"SecGsbLookupDb https://safebrowsing.googleapis.com/v4/threatMatches:find?key=KEY",
+		"SecAuditLog https://logs.example.com/audit",
+		`SecRemoteRules KEY https://rules.example.com/shared.conf`,
+	} {
+		if err := p.FromString(directive); err == nil {
+			t.Errorf("expected error confining %q", directive)
+		}
+	}
+	if want, have := 1, waf.Rules.Count(); want != have {
+		t.Errorf("unexpected number of rules, want %d, have %d", want, have)
+	}
+}
+
 //go:embed testdata/parserbenchmark.conf
 var parsingRule string
 
@@ -0,0 +1,107 @@
+// Copyright 2024 Juan Pablo Tosso and the OWASP Coraza contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package seclang
+
+import (
+	"errors"
+	"maps"
+	"slices"
+)
+
+// ParseTransaction accumulates the directives of several FromString,
+// FromFile or any other From* calls and applies them to the WAF of the
+// parser all at once with Commit, or discards them with Rollback, so a
+// configuration failing halfway doesn't leave the WAF with part of its
+// rules.
+//
+// The directives are compiled into a staging copy of the WAF of the parser,
+// which reports the errors, e.g. invalid or duplicate rules, before the WAF
+// of the parser is modified. Commit replaces the configuration and the rules
+// of the WAF with the staged ones without compiling the directives again, so
+// it can't fail halfway. It must not be called while the WAF evaluates
+// transactions, use ReloadableWAF to reload the rules of a live WAF. The
+// directive hooks and the load observers of the parser are called while
+// staging. Datasets published with ShareDatasets are published by Commit.
+//
+// Example:
+// ```go
+//
+//	tx, err := p.Begin()
+//	if err != nil {
+//		return err
+//	}
+//	for _, file := range files {
+//		if err := tx.FromFile(file); err != nil {
+//			tx.Rollback()
+//			return err
+//		}
+//	}
+//	return tx.Commit()
+//
+// ```
+type ParseTransaction struct {
+	// Parser is the staging parser, the settings changed on it, e.g. with
+	// Define or SetRoot, are not applied to the parser by Commit
+	*Parser
+	parent *Parser
+	closed bool
+}
+
+// ErrParseTransactionClosed is returned when committing or rolling back a
+// transaction that was already committed or rolled back
+var ErrParseTransactionClosed = errors.New("parse transaction already closed")
+
+// Begin starts a transaction, see ParseTransaction. The staging parser
+// starts with a copy of the WAF, the rules and the settings of the parser.
+// The parser must not be used until the transaction is committed or rolled
+// back.
+func (p *Parser) Begin() (*ParseTransaction, error) {
+	staging := NewParser(p.options.WAF.Stage())
+	staging.root = p.root
+	staging.remoteClient = p.remoteClient
+	staging.windowsPaths = p.windowsPaths
+	staging.defines = maps.Clone(p.defines)
+	staging.options.Parser = p.options.Parser.clone()
+	staging.includeCount = p.includeCount
+	staging.hooks = p.hooks
+	staging.observers = p.observers
+	return &ParseTransaction{Parser: staging, parent: p}, nil
+}
+
+// Commit applies the configuration and the rules compiled by the transaction
+// to the WAF of the parser, along with the settings of the directives, e.g.
+// SecDefaultAction. The guardian log and the audit log writer replaced by the
+// ones of the transaction are closed.
+func (t *ParseTransaction) Commit() error {
+	if t.closed {
+		return ErrParseTransactionClosed
+	}
+	t.closed = true
+	p, staged := t.parent, t.Parser.options
+	shared := p.options.Parser.SharedDatasets
+	if shared != nil {
+		for name, values := range staged.Parser.SharedDatasets.datasets {
+			if current, ok := shared.Get(name); !ok || !slices.Equal(current, values) {
+				shared.Set(name, values)
+			}
+		}
+	}
+	p.options.WAF.Apply(staged.WAF)
+	p.options.Datasets = p.options.WAF.Datasets()
+	p.options.Parser = staged.Parser.clone()
+	p.options.Parser.SharedDatasets = shared
+	p.includeCount = t.Parser.includeCount
+	return nil
+}
+
+// Rollback discards the directives loaded by the transaction, the WAF of
+// the parser is left untouched. The resources opened by the directives of
+// the transaction, e.g. the guardian log of SecGuardianLog, are closed.
+func (t *ParseTransaction) Rollback() error {
+	if t.closed {
+		return ErrParseTransactionClosed
+	}
+	t.closed = true
+	return t.Parser.options.WAF.Discard()
+}
@@ -0,0 +1,161 @@
+// Copyright 2024 Juan Pablo Tosso and the OWASP Coraza contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package seclang
+
+import (
+	"errors"
+	"slices"
+	"testing"
+	"testing/fstest"
+
+	"github.com/ad3n/seclang/internal/corazawaf"
+	"github.com/corazawaf/coraza/v3/types"
+)
+
+func TestParseTransactionCommit(t *testing.T) {
+	waf := corazawaf.NewWAF()
+	p := NewParser(waf)
+	p.SetRoot(fstest.MapFS{
+		"rules.conf": &fstest.MapFile{Data: []byte(`SecRule ARGS "@rx b" "id:2,phase:1,deny"`)},
+	})
+	if err := p.FromString(`SecDefaultAction "phase:1,log,pass"
+SecRule ARGS "@rx a" "id:1"`); err != nil {
+		t.Fatal(err)
+	}
+	var hooked int
+	p.OnDirective(func(string, int, string, string) error {
+		hooked++
+		return nil
+	})
+
+	tx, err := p.Begin()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := tx.FromString("SecRuleEngine Off"); err != nil {
+		t.Fatal(err)
+	}
+	if err := tx.FromFile("rules.conf"); err != nil {
+		t.Fatal(err)
+	}
+	if want, have := 1, waf.Rules.Count(); want != have {
+		t.Errorf("unexpected number of rules before the commit, want %d, have %d", want, have)
+	}
+	if want, have := types.RuleEngineOn, waf.RuleEngine; want != have {
+		t.Errorf("unexpected rule engine before the commit, want %s, have %s", want, have)
+	}
+	if err := tx.Commit(); err != nil {
+		t.Fatal(err)
+	}
+	if want, have := 2, waf.Rules.Count(); want != have {
+		t.Errorf("unexpected number of rules, want %d, have %d", want, have)
+	}
+	if want, have := types.RuleEngineOff, waf.RuleEngine; want != have {
+		t.Errorf("unexpected rule engine, want %s, have %s", want, have)
+	}
+	if want, have := 2, hooked; want != have {
+		t.Errorf("unexpected number of hooked directives, want %d, have %d", want, have)
+	}
+	if err := tx.Commit(); !errors.Is(err, ErrParseTransactionClosed) {
+		t.Errorf("unexpected error committing twice: %v", err)
+	}
+
+	// the committed directives are loaded by the next transactions
+	tx, err = p.Begin()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := tx.FromString(`SecRule ARGS "@rx b" "id:2,phase:1,deny"`); err == nil {
+		t.Error("expected error for a duplicated rule")
+	}
+	if err := tx.Rollback(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestParseTransactionRollback(t *testing.T) {
+	waf := corazawaf.NewWAF()
+	p := NewParser(waf)
+	if err := p.FromString(`SecRule ARGS "@rx a" "id:1,phase:1,pass"`); err != nil {
+		t.Fatal(err)
+	}
+	shared := NewDatasetRegistry()
+	p.ShareDatasets(shared)
+
+	tx, err := p.Begin()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := tx.FromString("SecRuleEngine Off\nSecDataset blocklist `\n10.0.0.1\n`\n" + `SecRule ARGS "@rx b" "id:2,phase:1,pass"`); err != nil {
+		t.Fatal(err)
+	}
+	// the second file fails halfway
+	if err := tx.FromString(`SecRule ARGS "@rx c" "id:3,phase:1,pass"
+SecRule ARGS "@rx d" "id:1,phase:1,pass"`); err == nil {
+		t.Fatal("expected error for a duplicated rule")
+	}
+	if err := tx.Rollback(); err != nil {
+		t.Fatal(err)
+	}
+	if want, have := 1, waf.Rules.Count(); want != have {
+		t.Errorf("unexpected number of rules, want %d, have %d", want, have)
+	}
+	if want, have := types.RuleEngineOn, waf.RuleEngine; want != have {
+		t.Errorf("unexpected rule engine, want %s, have %s", want, have)
+	}
+	if _, ok := shared.Get("blocklist"); ok {
+		t.Error("unexpected dataset published by a rolled back transaction")
+	}
+	if err := tx.Commit(); !errors.Is(err, ErrParseTransactionClosed) {
+		t.Errorf("unexpected error committing a rolled back transaction: %v", err)
+	}
+
+	// the parser keeps working after the rollback
+	if err := p.FromString(`SecRule ARGS "@rx b" "id:2,phase:1,pass"`); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestParseTransactionStagedUpdates(t *testing.T) {
+	waf := corazawaf.NewWAF()
+	p := NewParser(waf)
+	if err := p.FromString(`SecRule ARGS "@rx a" "id:1,phase:1,pass,tag:a"
+SecRule ARGS "@rx b" "id:2,phase:1,pass"`); err != nil {
+		t.Fatal(err)
+	}
+
+	tx, err := p.Begin()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := tx.FromString(`SecRuleUpdateActionById 1 "tag:b"
+SecRuleUpdateTargetById 1 "!ARGS:id"
+SecRuleRemoveById 2`); err != nil {
+		t.Fatal(err)
+	}
+	rule := waf.Rules.FindByID(1)
+	if want, have := []string{"a"}, rule.Tags_; !slices.Equal(want, have) {
+		t.Errorf("unexpected tags before the commit, want %q, have %q", want, have)
+	}
+	if have := rule.Definition().Variables[0].Exceptions; len(have) != 0 {
+		t.Errorf("unexpected exceptions before the commit: %q", have)
+	}
+	if want, have := 2, waf.Rules.Count(); want != have {
+		t.Errorf("unexpected number of rules before the commit, want %d, have %d", want, have)
+	}
+	if err := tx.Commit(); err != nil {
+		t.Fatal(err)
+	}
+
+	rule = waf.Rules.FindByID(1)
+	if want, have := []string{"a", "b"}, rule.Tags_; !slices.Equal(want, have) {
+		t.Errorf("unexpected tags, want %q, have %q", want, have)
+	}
+	if want, have := []string{"id"}, rule.Definition().Variables[0].Exceptions; !slices.Equal(want, have) {
+		t.Errorf("unexpected exceptions, want %q, have %q", want, have)
+	}
+	if want, have := 1, waf.Rules.Count(); want != have {
+		t.Errorf("unexpected number of rules, want %d, have %d", want, have)
+	}
+}
@@ -0,0 +1,37 @@
+// Copyright 2024 Juan Pablo Tosso and the OWASP Coraza contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package seclang
+
+import (
+	"strings"
+
+	"github.com/ad3n/seclang/experimental/plugins/plugintypes"
+)
+
+// customDirectives contains the directives registered by plugins.
+// They are only looked up when no built-in directive matches.
+var customDirectives = map[string]plugintypes.DirectiveHandler{}
+
+// RegisterDirective registers a custom directive handler.
+// If the directive already exists it will be overwritten
+func RegisterDirective(name string, handler plugintypes.DirectiveHandler) {
+	customDirectives[strings.ToLower(name)] = handler
+}
+
+// customDirective wraps a plugin handler so it can be dispatched
+// like any built-in directive.
+func customDirective(name string, handler plugintypes.DirectiveHandler) directive {
+	return func(options *DirectiveOptions) error {
+		return handler(plugintypes.DirectiveOptions{
+			Name:       name,
+			Arguments:  options.Opts,
+			Raw:        options.Raw,
+			ConfigFile: options.Parser.ConfigFile,
+			ConfigDir:  options.Parser.ConfigDir,
+			Line:       options.Parser.LastLine,
+			Root:       options.Parser.Root,
+			Logger:     options.WAF.Logger,
+		})
+	}
+}
@@ -0,0 +1,85 @@
+// Copyright 2024 Juan Pablo Tosso and the OWASP Coraza contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package seclang
+
+import (
+	"errors"
+	"sync"
+	"sync/atomic"
+
+	"github.com/ad3n/seclang/internal/corazawaf"
+)
+
+// ReloadableWAF holds a WAF whose rule set can be replaced at runtime.
+// New transactions are created from the latest WAF, while in-flight
+// transactions keep a reference to the WAF they were created from and
+// finish their evaluation with the previous rule set.
+type ReloadableWAF struct {
+	current atomic.Pointer[corazawaf.WAF]
+	// build configures a new WAF through the parser, e.g. by calling FromFile
+	build func(p *Parser) error
+	// mu serializes reloads
+	mu sync.Mutex
+}
+
+// NewReloadableWAF creates a ReloadableWAF and builds its first WAF
+// with the provided build function. The same function is called on
+// every Reload.
+func NewReloadableWAF(build func(p *Parser) error) (*ReloadableWAF, error) {
+	if build == nil {
+		return nil, errors.New("build function is required")
+	}
+	r := &ReloadableWAF{build: build}
+	if err := r.Reload(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// Reload builds a new WAF and atomically swaps it with the current one
+// once it is fully compiled and validated. If the new rule set fails
+// to compile, the current WAF is kept and the error is returned.
+// The previous WAF is closed, its resources are released once its
+// in-flight transactions are closed.
+func (r *ReloadableWAF) Reload() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	waf := corazawaf.NewWAF()
+	if err := r.build(NewParser(waf)); err != nil {
+		return err
+	}
+	if err := waf.Validate(); err != nil {
+		return err
+	}
+	if previous := r.current.Swap(waf); previous != nil {
+		if err := previous.Close(); err != nil {
+			previous.Logger.Error().Err(err).Msg("Failed to close the previous WAF")
+		}
+	}
+	return nil
+}
+
+// WAF returns the WAF used for new transactions
+func (r *ReloadableWAF) WAF() *corazawaf.WAF {
+	return r.current.Load()
+}
+
+// NewTransaction creates a new transaction using the latest rule set
+func (r *ReloadableWAF) NewTransaction() *corazawaf.Transaction {
+	return r.NewTransactionWithOptions(corazawaf.Options{})
+}
+
+// NewTransactionWithOptions creates a new transaction with the provided
+// options using the latest rule set
+func (r *ReloadableWAF) NewTransactionWithOptions(opts corazawaf.Options) *corazawaf.Transaction {
+	for {
+		// a concurrent Reload may close the loaded WAF before the transaction
+		// is counted in flight, the next iteration loads the new WAF as it is
+		// swapped before the previous one is closed
+		if tx, ok := r.current.Load().TryNewTransactionWithOptions(opts); ok {
+			return tx
+		}
+	}
+}
@@ -0,0 +1,84 @@
+// Copyright 2024 Juan Pablo Tosso and the OWASP Coraza contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package seclang
+
+import (
+	"errors"
+	"sync"
+	"testing"
+)
+
+func TestReloadableWAF(t *testing.T) {
+	rules := `SecRule ARGS:id "@eq 1" "id:1,phase:1,deny"`
+	r, err := NewReloadableWAF(func(p *Parser) error {
+		return p.FromString("SecRuleEngine On\n" + rules)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	inflight := r.NewTransaction()
+	defer inflight.Close()
+
+	rules = `SecRule ARGS:id "@eq 2" "id:2,phase:1,deny"`
+	if err := r.Reload(); err != nil {
+		t.Fatal(err)
+	}
+
+	inflight.AddGetRequestArgument("id", "1")
+	if it := inflight.ProcessRequestHeaders(); it == nil || it.RuleID != 1 {
+		t.Errorf("expected in-flight transaction to be evaluated with the previous rules, have %v", it)
+	}
+
+	tx := r.NewTransaction()
+	defer tx.Close()
+	tx.AddGetRequestArgument("id", "2")
+	if it := tx.ProcessRequestHeaders(); it == nil || it.RuleID != 2 {
+		t.Errorf("expected new transaction to be evaluated with the reloaded rules, have %v", it)
+	}
+
+	// a failing build keeps the current rule set
+	rules = `SecRule ARGS:id "@unknown 2" "id:3,phase:1,deny"`
+	if err := r.Reload(); err == nil {
+		t.Error("expected reload error")
+	}
+	if r.WAF().Rules.FindByID(2) == nil {
+		t.Error("expected the previous rule set to be kept after a failed reload")
+	}
+}
+
+func TestReloadableWAFConcurrency(t *testing.T) {
+	r, err := NewReloadableWAF(func(p *Parser) error {
+		return p.FromString(`SecRule ARGS:id "@eq 1" "id:1,phase:1,deny"`)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			if err := r.Reload(); err != nil {
+				t.Error(err)
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			tx := r.NewTransaction()
+			tx.ProcessRequestHeaders()
+			if err := tx.Close(); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if _, err := NewReloadableWAF(nil); err == nil {
+		t.Error("expected error for missing build function")
+	}
+	if _, err := NewReloadableWAF(func(*Parser) error { return errors.New("boom") }); err == nil {
+		t.Error("expected error for failing build function")
+	}
+}
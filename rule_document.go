@@ -0,0 +1,220 @@
+// Copyright 2024 Juan Pablo Tosso and the OWASP Coraza contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package seclang
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	utils "github.com/ad3n/seclang/internal/strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// RuleDocument is the structured representation of a rule, it is
+// compiled into the same rule a SecRule or SecAction directive would produce.
+// A rule without operator is compiled as a SecAction and must not
+// contain variables.
+type RuleDocument struct {
+	// ID of the rule, it must be empty for chained rules
+	ID int `json:"id,omitempty" yaml:"id,omitempty"`
+	// Phase of the rule, it must be empty for chained rules
+	Phase int `json:"phase,omitempty" yaml:"phase,omitempty"`
+	// Variables using the seclang syntax, e.g. ARGS:id, !ARGS:id or &ARGS
+	Variables []string `json:"variables,omitempty" yaml:"variables,omitempty"`
+	// Operator using the seclang syntax, e.g. @rx ^[a-z]+$.
+	// No quoting or escaping is required
+	Operator string `json:"operator,omitempty" yaml:"operator,omitempty"`
+	// Transformations applied to the variables, e.g. lowercase
+	Transformations []string `json:"transformations,omitempty" yaml:"transformations,omitempty"`
+	// Actions of the rule, in order. Values do not require quoting
+	Actions []RuleDocumentAction `json:"actions,omitempty" yaml:"actions,omitempty"`
+	// Chain is evaluated only if this rule matches
+	Chain *RuleDocument `json:"chain,omitempty" yaml:"chain,omitempty"`
+}
+
+// RuleDocumentAction is a rule action with its optional value
+type RuleDocumentAction struct {
+	Name  string `json:"name" yaml:"name"`
+	Value string `json:"value,omitempty" yaml:"value,omitempty"`
+}
+
+// FromJSON compiles a JSON list of rule documents
+// It will return error if the document is malformed, contains
+// unknown fields or any rule fails to compile.
+//
+// Example:
+// ```json
+// [{"id": 1, "phase": 1, "variables": ["ARGS:id"], "operator": "@eq 0", "actions": [{"name": "deny"}]}]
+// ```
+func (p *Parser) FromJSON(data []byte) error {
+	var docs []RuleDocument
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&docs); err != nil {
+		return fmt.Errorf("failed to decode json rules: %s", err.Error())
+	}
+	return p.fromDocuments(docs)
+}
+
+// FromYAML compiles a YAML list of rule documents
+// It will return error if the document is malformed, contains
+// unknown fields or any rule fails to compile.
+//
+// Example:
+// ```yaml
+//   - id: 1
+//     phase: 1
+//     variables: [ARGS:id]
+//     operator: "@eq 0"
+//     actions: [{name: deny}]
+//
+// ```
+func (p *Parser) FromYAML(data []byte) error {
+	var docs []RuleDocument
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+	if err := dec.Decode(&docs); err != nil && !errors.Is(err, io.EOF) {
+		return fmt.Errorf("failed to decode yaml rules: %s", err.Error())
+	}
+	return p.fromDocuments(docs)
+}
+
+func (p *Parser) fromDocuments(docs []RuleDocument) error {
+	oldCurrentFile := p.currentFile
+	p.currentFile = "_inline_"
+	for i, doc := range docs {
+		p.currentLine = i + 1
+		if err := p.compileRuleDocument(doc, false); err != nil {
+			// we don't use defer for this as tinygo does not seem to like it
+			p.currentFile = oldCurrentFile
+			return fmt.Errorf("failed to compile rule document %d: %w", i, err)
+		}
+	}
+	p.reportImplicitRxFlags()
+	p.currentFile = oldCurrentFile
+	return nil
+}
+
+func (p *Parser) compileRuleDocument(doc RuleDocument, chained bool) error {
+	if chained && (doc.ID != 0 || doc.Phase != 0) {
+		return errors.New("chained rules must not contain id or phase")
+	}
+	if doc.Operator == "" && len(doc.Variables) > 0 {
+		return errors.New("rules with variables require an operator")
+	}
+
+	directive := "SecRule"
+	if doc.Operator == "" {
+		directive = "SecAction"
+	}
+	p.options.Parser.LastLine = p.currentLine
+	p.options.Parser.ConfigFile = p.currentFile
+	p.options.Parser.ConfigDir = p.currentDir
+	p.options.Parser.Root = p.root
+
+	// the rules above the maximum paranoia level, the rest of the chain of a
+	// skipped rule and, in permissive mode, the rules using unknown actions,
+	// operators or variables are skipped like the SecRule directives
+	actions := doc.rawActions()
+	if !skipRule(p.options, actions, nil) {
+		if err := p.compileRuleDocumentRule(doc, directive); err != nil && !skipRule(p.options, actions, err) {
+			return err
+		}
+	}
+	if doc.Chain != nil {
+		return p.compileRuleDocument(*doc.Chain, true)
+	}
+	return nil
+}
+
+// compileRuleDocumentRule compiles the rule of the document, without its
+// chain, and adds it to the WAF
+func (p *Parser) compileRuleDocumentRule(doc RuleDocument, directive string) error {
+	options := RuleOptions{
+		WithOperator: doc.Operator != "",
+		WAF:          p.options.WAF,
+		ParserConfig: p.options.Parser,
+		Directive:    directive,
+		Datasets:     p.options.WAF.Datasets(),
+	}
+
+	rp, err := newRuleParser(options)
+	if err != nil {
+		return err
+	}
+	if options.WithOperator {
+		if utils.InSlice(doc.Operator, options.ParserConfig.DisabledRuleOperators) {
+			return fmt.Errorf("%s rule operator is disabled", doc.Operator)
+		}
+		if err := rp.ParseVariables(strings.Join(doc.Variables, "|")); err != nil {
+			return err
+		}
+		if err := rp.ParseOperator(doc.Operator); err != nil {
+			return err
+		}
+	}
+	act, err := doc.ruleActions()
+	if err != nil {
+		return err
+	}
+	if err := rp.initActions(act); err != nil {
+		return err
+	}
+	rp.recordImplicitRxFlags()
+	return addRule(p.options.WAF, p.options.Parser, linkRule(rp.Rule(), options))
+}
+
+// rawActions returns the actions deciding whether the rule is skipped, its
+// id, tags and chain, as they would be written in a SecRule directive
+func (doc RuleDocument) rawActions() string {
+	var actions []string
+	if doc.ID != 0 {
+		actions = append(actions, "id:"+strconv.Itoa(doc.ID))
+	}
+	for _, a := range doc.Actions {
+		if strings.EqualFold(a.Name, "tag") {
+			actions = append(actions, "tag:'"+a.Value+"'")
+		}
+	}
+	if doc.Chain != nil {
+		actions = append(actions, "chain")
+	}
+	return strings.Join(actions, ",")
+}
+
+// ruleActions returns the actions of the document in the same order
+// the seclang parser would read them: id, phase, transformations,
+// actions and chain.
+func (doc RuleDocument) ruleActions() ([]ruleAction, error) {
+	var res []ruleAction
+	var err error
+	disruptiveActionIndex := unset
+	add := func(key, val string) {
+		if err == nil {
+			res, disruptiveActionIndex, err = appendRuleAction(res, key, val, disruptiveActionIndex)
+		}
+	}
+	if doc.ID != 0 {
+		add("id", strconv.Itoa(doc.ID))
+	}
+	if doc.Phase != 0 {
+		add("phase", strconv.Itoa(doc.Phase))
+	}
+	for _, t := range doc.Transformations {
+		add("t", t)
+	}
+	for _, a := range doc.Actions {
+		add(a.Name, a.Value)
+	}
+	if doc.Chain != nil {
+		add("chain", "")
+	}
+	return res, err
+}
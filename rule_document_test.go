@@ -0,0 +1,120 @@
+// Copyright 2024 Juan Pablo Tosso and the OWASP Coraza contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package seclang
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/ad3n/seclang/internal/corazawaf"
+)
+
+func TestFromJSON(t *testing.T) {
+	waf := corazawaf.NewWAF()
+	p := NewParser(waf)
+	err := p.FromJSON([]byte(`[
+		{"id": 1, "phase": 1, "variables": ["ARGS:id", "!ARGS:name"], "operator": "@rx ^a\"b c$",
+		 "transformations": ["lowercase"], "actions": [{"name": "msg", "value": "it's a \"test\""}, {"name": "deny"}],
+		 "chain": {"variables": ["REQUEST_METHOD"], "operator": "@streq GET"}},
+		{"id": 2, "actions": [{"name": "phase", "value": "1"}, {"name": "pass"}, {"name": "nolog"}]}
+	]`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want, have := 2, waf.Rules.Count(); want != have {
+		t.Fatalf("unexpected number of rules, want %d, have %d", want, have)
+	}
+	r := waf.Rules.FindByID(1)
+	if want, have := `it's a "test"`, r.Msg.String(); want != have {
+		t.Errorf("unexpected msg, want %q, have %q", want, have)
+	}
+	if r.Chain == nil {
+		t.Error("expected rule 1 to be chained")
+	}
+	if want, have := "_inline_", r.File(); want != have {
+		t.Errorf("unexpected file, want %q, have %q", want, have)
+	}
+
+	tx := waf.NewTransaction()
+	tx.ProcessURI("/?id=A%22B+C", "GET", "HTTP/1.1")
+	if it := tx.ProcessRequestHeaders(); it == nil {
+		t.Error("expected the json rule to interrupt the transaction")
+	}
+}
+
+func TestFromYAML(t *testing.T) {
+	waf := corazawaf.NewWAF()
+	p := NewParser(waf)
+	err := p.FromYAML([]byte(`
+- id: 10
+  phase: 1
+  variables: [REQUEST_HEADERS:x-test]
+  operator: "@eq 5"
+  actions:
+    - name: deny
+    - name: status
+      value: "418"
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tx := waf.NewTransaction()
+	tx.AddRequestHeader("X-Test", "5")
+	it := tx.ProcessRequestHeaders()
+	if it == nil {
+		t.Fatal("expected the yaml rule to interrupt the transaction")
+	}
+	if want, have := 418, it.Status; want != have {
+		t.Errorf("unexpected status, want %d, have %d", want, have)
+	}
+}
+
+func TestRuleDocumentErrors(t *testing.T) {
+	tests := map[string]string{
+		"unknown field":        `[{"id": 1, "operatr": "@rx a"}]`,
+		"unknown action":       `[{"id": 1, "actions": [{"name": "nonexistent"}]}]`,
+		"variables without op": `[{"id": 1, "variables": ["ARGS"]}]`,
+		"chain with id":        `[{"id": 1, "variables": ["ARGS"], "operator": "@rx a", "chain": {"id": 2, "variables": ["ARGS"], "operator": "@rx b"}}]`,
+		"duplicated id":        `[{"id": 1, "actions": [{"name": "pass"}]}, {"id": 1, "actions": [{"name": "pass"}]}]`,
+		"invalid operator":     `[{"id": 1, "variables": ["ARGS"], "operator": "@nonexistent a"}]`,
+	}
+	for name, doc := range tests {
+		t.Run(name, func(t *testing.T) {
+			p := NewParser(corazawaf.NewWAF())
+			if err := p.FromJSON([]byte(doc)); err == nil {
+				t.Error("expected error")
+			}
+		})
+	}
+}
+
+func TestRuleDocumentSkipped(t *testing.T) {
+	waf := corazawaf.NewWAF()
+	p := NewParser(waf)
+	p.SetParseMode(ParseModePermissive)
+	p.SetMaxParanoiaLevel(1)
+	err := p.FromJSON([]byte(`[
+		{"id": 1, "phase": 1, "actions": [{"name": "pass"}, {"name": "tag", "value": "paranoia-level/1"}]},
+		{"id": 2, "phase": 1, "variables": ["ARGS"], "operator": "@rx a", "actions": [{"name": "pass"}, {"name": "tag", "value": "paranoia-level/2"}],
+		 "chain": {"variables": ["ARGS"], "operator": "@rx b"}},
+		{"id": 3, "phase": 1, "variables": ["ARGS"], "operator": "@unknownOperator a", "actions": [{"name": "pass"}]},
+		{"id": 4, "phase": 1, "variables": ["ARGS"], "operator": "@rx a", "actions": [{"name": "pass"}],
+		 "chain": {"variables": ["ARGS"], "operator": "@rx b", "actions": [{"name": "unknownaction"}]}},
+		{"id": 5, "phase": 1, "variables": ["ARGS"], "operator": "@rx a", "actions": [{"name": "pass"}],
+		 "chain": {"variables": ["ARGS"], "operator": "@rx b"}}
+	]`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var ids []int
+	for _, r := range waf.Rules.GetRules() {
+		ids = append(ids, r.ID_)
+	}
+	if want, have := "[1 5]", fmt.Sprint(ids); want != have {
+		t.Errorf("unexpected rules, want %s, have %s", want, have)
+	}
+	if rule := waf.Rules.FindByID(5); rule == nil || rule.Chain == nil {
+		t.Error("expected rule 5 to keep its chain")
+	}
+}
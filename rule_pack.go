@@ -0,0 +1,120 @@
+// Copyright 2024 Juan Pablo Tosso and the OWASP Coraza contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package seclang
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// RulePack is the namespace the rules of a vendor rule set are loaded under
+// with FromRulePack, so rule sets using colliding ID ranges can be loaded in
+// the same WAF.
+type RulePack struct {
+	// Name prefixes the tags of the rules of the pack, e.g. the tag
+	// attack-sqli of the pack vendor-a becomes vendor-a/attack-sqli.
+	// Tags are kept as they are if empty.
+	Name string
+	// IDOffset is added to the IDs of the rules of the pack, e.g. the rule
+	// 942100 of a pack with offset 10000000 is loaded as 10942100.
+	IDOffset int
+}
+
+// FromRulePack imports directives from a file like FromFile, loading the
+// rules under the namespace of the pack, including the ones of the files it
+// includes. IDs referenced inside the pack by SecRuleRemoveById,
+// SecRuleUpdateActionById, SecRuleUpdateTargetById and the ctl options
+// ruleRemoveById and ruleRemoveTargetById are offset too, as well as the tags
+// referenced by SecRuleUpdateTargetByTag, ctl:ruleRemoveByTag and
+// ctl:ruleRemoveTargetByTag. SecRuleRemoveByTag expressions are matched
+// against the prefixed tags.
+// Directives outside the pack must reference its rules by the offset IDs and
+// prefixed tags.
+//
+// Example:
+// ```go
+//
+//	err := p.FromRulePack("vendor-a/*.conf", seclang.RulePack{Name: "vendor-a", IDOffset: 10000000})
+//
+// ```
+func (p *Parser) FromRulePack(path string, pack RulePack) error {
+	if pack.IDOffset < 0 {
+		return fmt.Errorf("invalid rule pack %q: negative ID offset %d", pack.Name, pack.IDOffset)
+	}
+	if strings.ContainsAny(pack.Name, " \t,'\"") {
+		return fmt.Errorf("invalid rule pack name %q", pack.Name)
+	}
+	if pack.Name == "" && pack.IDOffset == 0 {
+		return errors.New("rule pack requires a name or an ID offset")
+	}
+
+	previous := p.options.Parser.RulePack
+	p.options.Parser.RulePack = pack
+	err := p.FromFile(path)
+	p.options.Parser.RulePack = previous
+	return err
+}
+
+// ruleIDs offsets a rule ID or range of IDs, values that are not IDs are
+// returned as they are for the caller to report them
+func (pack RulePack) ruleIDs(idOrRange string) string {
+	if pack.IDOffset == 0 {
+		return idOrRange
+	}
+	start, end, isRange := strings.Cut(idOrRange, "-")
+	startID, err := strconv.Atoi(start)
+	if err != nil {
+		return idOrRange
+	}
+	if !isRange {
+		return strconv.Itoa(startID + pack.IDOffset)
+	}
+	endID, err := strconv.Atoi(end)
+	if err != nil {
+		return idOrRange
+	}
+	return strconv.Itoa(startID+pack.IDOffset) + "-" + strconv.Itoa(endID+pack.IDOffset)
+}
+
+// tag prefixes the tag with the name of the pack
+func (pack RulePack) tag(tag string) string {
+	if pack.Name == "" {
+		return tag
+	}
+	return pack.Name + "/" + tag
+}
+
+// action namespaces the arguments of the actions referencing rule IDs or tags
+func (pack RulePack) action(a ruleAction) ruleAction {
+	switch a.Key {
+	case "id":
+		// invalid IDs are kept so the id action reports them
+		if id, err := strconv.Atoi(a.Value); err == nil && id > 0 {
+			a.Value = pack.ruleIDs(a.Value)
+		}
+	case "tag":
+		a.Value = pack.tag(a.Value)
+	case "ctl":
+		option, value, ok := strings.Cut(a.Value, "=")
+		if !ok {
+			break
+		}
+		value, target, hasTarget := strings.Cut(value, ";")
+		switch option {
+		case "ruleRemoveById", "ruleRemoveTargetById":
+			value = pack.ruleIDs(value)
+		case "ruleRemoveByTag", "ruleRemoveTargetByTag":
+			value = pack.tag(value)
+		default:
+			return a
+		}
+		if hasTarget {
+			value += ";" + target
+		}
+		a.Value = option + "=" + value
+	}
+	return a
+}
@@ -0,0 +1,119 @@
+// Copyright 2024 Juan Pablo Tosso and the OWASP Coraza contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package seclang
+
+import (
+	"bytes"
+	"testing"
+	"testing/fstest"
+
+	"github.com/ad3n/seclang/internal/corazawaf"
+)
+
+func TestFromRulePack(t *testing.T) {
+	vendor := []byte(`
+SecRule ARGS "@contains attack" "id:100,phase:1,deny,tag:'attack-sqli',chain"
+    SecRule ARGS "@contains other" "ctl:ruleRemoveById=101-102,ctl:ruleRemoveByTag=attack-xss"
+SecRule ARGS "@contains xss" "id:101,phase:1,deny,tag:'attack-xss'"
+SecAction "id:102,phase:1,pass,nolog"
+SecRuleRemoveById 102
+SecRuleUpdateActionById 101 "tag:'updated'"
+`)
+	root := fstest.MapFS{
+		"vendor-a/rules.conf": {Data: vendor},
+		"vendor-b/rules.conf": {Data: vendor},
+	}
+	waf := corazawaf.NewWAF()
+	p := NewParser(waf)
+	p.SetRoot(root)
+	if err := p.FromRulePack("vendor-a/rules.conf", RulePack{Name: "vendor-a", IDOffset: 1000}); err != nil {
+		t.Fatal(err)
+	}
+	if err := p.FromRulePack("vendor-b/rules.conf", RulePack{Name: "vendor-b", IDOffset: 2000}); err != nil {
+		t.Fatal(err)
+	}
+	// rules loaded afterwards are not namespaced
+	if err := p.FromString(`SecAction "id:100,phase:1,pass,nolog,tag:'local'"`); err != nil {
+		t.Fatal(err)
+	}
+
+	if want, have := 5, waf.Rules.Count(); want != have {
+		t.Fatalf("unexpected number of rules, want %d, have %d", want, have)
+	}
+	for _, id := range []int{1102, 2102} {
+		if waf.Rules.FindByID(id) != nil {
+			t.Errorf("expected rule %d to be removed", id)
+		}
+	}
+	for id, want := range map[int][]string{
+		100:  {"local"},
+		1100: {"vendor-a/attack-sqli"},
+		1101: {"vendor-a/attack-xss", "vendor-a/updated"},
+		2100: {"vendor-b/attack-sqli"},
+		2101: {"vendor-b/attack-xss", "vendor-b/updated"},
+	} {
+		rule := waf.Rules.FindByID(id)
+		if rule == nil {
+			t.Errorf("expected rule %d", id)
+			continue
+		}
+		if have := rule.Tags_; len(want) != len(have) || (len(want) > 0 && want[0] != have[0]) || want[len(want)-1] != have[len(have)-1] {
+			t.Errorf("unexpected tags of rule %d, want %q, have %q", id, want, have)
+		}
+	}
+
+	// the namespace is kept by snapshots
+	var snapshot bytes.Buffer
+	if err := p.WriteSnapshot(&snapshot, "v1"); err != nil {
+		t.Fatal(err)
+	}
+	restored := corazawaf.NewWAF()
+	if err := NewParser(restored).FromSnapshot(&snapshot, "v1"); err != nil {
+		t.Fatal(err)
+	}
+	if want, have := waf.Rules.Count(), restored.Rules.Count(); want != have {
+		t.Errorf("unexpected number of restored rules, want %d, have %d", want, have)
+	}
+	if restored.Rules.FindByID(2101) == nil {
+		t.Error("expected restored rule 2101")
+	}
+}
+
+func TestFromRulePackErrors(t *testing.T) {
+	for name, pack := range map[string]RulePack{
+		"empty":           {},
+		"negative offset": {Name: "vendor", IDOffset: -1},
+		"invalid name":    {Name: "vendor a"},
+	} {
+		t.Run(name, func(t *testing.T) {
+			p := NewParser(corazawaf.NewWAF())
+			p.SetRoot(fstest.MapFS{"rules.conf": {Data: []byte(`SecAction "id:1,pass"`)}})
+			if err := p.FromRulePack("rules.conf", pack); err == nil {
+				t.Error("expected error")
+			}
+		})
+	}
+}
+
+func TestRulePackAction(t *testing.T) {
+	pack := RulePack{Name: "vendor", IDOffset: 1000}
+	for _, tc := range []struct {
+		key, value, want string
+	}{
+		{"id", "5", "1005"},
+		{"id", "0", "0"},
+		{"id", "abc", "abc"},
+		{"tag", "attack-sqli", "vendor/attack-sqli"},
+		{"msg", "attack", "attack"},
+		{"ctl", "ruleRemoveById=5", "ruleRemoveById=1005"},
+		{"ctl", "ruleRemoveById=5-10", "ruleRemoveById=1005-1010"},
+		{"ctl", "ruleRemoveTargetById=5;ARGS:user", "ruleRemoveTargetById=1005;ARGS:user"},
+		{"ctl", "ruleRemoveTargetByTag=sqli;ARGS:user", "ruleRemoveTargetByTag=vendor/sqli;ARGS:user"},
+		{"ctl", "ruleEngine=Off", "ruleEngine=Off"},
+	} {
+		if have := pack.action(ruleAction{Key: tc.key, Value: tc.value}).Value; tc.want != have {
+			t.Errorf("unexpected %s:%s, want %q, have %q", tc.key, tc.value, tc.want, have)
+		}
+	}
+}
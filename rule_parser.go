@@ -6,9 +6,11 @@ package seclang
 import (
 	"errors"
 	"fmt"
+	"slices"
 	"strings"
 
 	actionsmod "github.com/ad3n/seclang/internal/actions"
+	"github.com/ad3n/seclang/internal/corazatypes"
 	"github.com/ad3n/seclang/internal/corazawaf"
 	"github.com/ad3n/seclang/internal/operators"
 	utils "github.com/ad3n/seclang/internal/strings"
@@ -33,6 +35,9 @@ type RuleParser struct {
 	rule           *corazawaf.Rule
 	defaultActions map[types.RulePhase][]ruleAction
 	options        RuleOptions
+	// implicitRxFlags is set when the operator of the rule relies on the
+	// implicit rx flags, see recordImplicitRxFlags
+	implicitRxFlags bool
 }
 
 // ParseVariables parses variables from a string and transforms it into
@@ -68,11 +73,11 @@ func (rp *RuleParser) ParseVariables(vars string) error {
 					curKey = append(curKey, c)
 				}
 			}
-			v, err := variables.Parse(string(curVar))
+			v, err := rp.parseVariable(string(curVar))
 			if err != nil {
 				return err
 			}
-			if curr == 1 && !v.CanBeSelected() {
+			if curr == 1 && !canBeSelected(v) {
 				return fmt.Errorf("attempting to select a value inside a non-selectable collection: %s", string(curVar))
 			}
 			// fmt.Printf("(PREVIOUS %s) %s:%s (%t %t)\n", vars, curvar, curkey, iscount, isnegation)
@@ -162,6 +167,30 @@ func (rp *RuleParser) ParseVariables(vars string) error {
 	return nil
 }
 
+// parseVariable parses the name of a variable, including the stream variables
+// enabled with SecStreamInBodyInspection and SecStreamOutBodyInspection
+func (rp *RuleParser) parseVariable(name string) (variables.RuleVariable, error) {
+	v, err := corazatypes.ParseVariable(name)
+	if err != nil {
+		return v, unknownRuleError{err}
+	}
+	waf := rp.options.WAF
+	if v == corazatypes.StreamInputBody && (waf == nil || !waf.StreamInBodyInspection) {
+		return variables.Unknown, fmt.Errorf("%s requires SecStreamInBodyInspection On", name)
+	}
+	if v == corazatypes.StreamOutputBody && (waf == nil || !waf.StreamOutBodyInspection) {
+		return variables.Unknown, fmt.Errorf("%s requires SecStreamOutBodyInspection On", name)
+	}
+	return v, nil
+}
+
+// canBeSelected returns true if the variable supports selection (ie, `:foobar`),
+// GEO is populated by @geoLookup with one key per field but it is not flagged
+// as selectable by the variables package.
+func canBeSelected(v variables.RuleVariable) bool {
+	return v == variables.Geo || corazatypes.CanBeSelected(v)
+}
+
 // ParseOperator parses a seclang formatted operator string
 // A operator must begin with @ (like @rx), if no operator is specified, rx
 // will be used. Everything after the operator will be used as operator argument
@@ -196,8 +225,22 @@ func (rp *RuleParser) ParseOperator(operator string) error {
 		Path: []string{
 			rp.options.ParserConfig.ConfigDir,
 		},
-		Root:     rp.options.ParserConfig.Root,
-		Datasets: rp.options.Datasets,
+		Root:              rp.options.ParserConfig.Root,
+		Datasets:          rp.options.Datasets,
+		RxDefaultFlags:    rp.options.ParserConfig.RxDefaultFlags,
+		HasRxDefaultFlags: rp.options.ParserConfig.HasRxDefaultFlags,
+
+		PcreMatchLimit:          rp.options.ParserConfig.PcreMatchLimit,
+		PcreMatchLimitRecursion: rp.options.ParserConfig.PcreMatchLimitRecursion,
+	}
+	if rp.options.WAF != nil {
+		if opts.Datasets == nil {
+			opts.Datasets = rp.options.WAF.Datasets()
+		}
+		opts.DatasetFeeds = rp.options.WAF.DatasetFeeds()
+	}
+	if shared := rp.options.ParserConfig.SharedDatasets; shared != nil {
+		opts.Datasets = shared.resolve(opts.Datasets)
 	}
 
 	if wd := rp.options.ParserConfig.WorkingDir; wd != "" {
@@ -206,9 +249,16 @@ func (rp *RuleParser) ParseOperator(operator string) error {
 
 	opfn, err := operators.Get(op, opts)
 	if err != nil {
+		if !slices.Contains(operators.Names(), op) {
+			return unknownRuleError{err}
+		}
 		return err
 	}
+	if op == "rx" && !opts.HasRxDefaultFlags {
+		rp.implicitRxFlags = operators.ImplicitRxFlags(opdata) != ""
+	}
 	rp.rule.SetOperator(opfn, opRaw, opdata)
+	rp.rule.SetOperatorOptions(opts)
 	return nil
 }
 
@@ -266,11 +316,22 @@ func (rp *RuleParser) ParseDefaultActions(actions string) error {
 // ParseActions parses a comma separated list of actions:arguments
 // Arguments can be wrapper inside quotes
 func (rp *RuleParser) ParseActions(actions string) error {
-	disabledActions := rp.options.ParserConfig.DisabledRuleActions
 	act, err := parseActions(rp.options.WAF.Logger, actions)
 	if err != nil {
 		return err
 	}
+	return rp.initActions(act)
+}
+
+// initActions initializes the parsed actions against the rule, merging
+// the default actions of the rule phase
+func (rp *RuleParser) initActions(act []ruleAction) error {
+	disabledActions := rp.options.ParserConfig.DisabledRuleActions
+	if pack := rp.options.ParserConfig.RulePack; pack != (RulePack{}) {
+		for i := range act {
+			act[i] = pack.action(act[i])
+		}
+	}
 	// check if forbidden action:
 	for _, a := range act {
 		if utils.InSlice(a.Key, disabledActions) {
@@ -280,7 +341,7 @@ func (rp *RuleParser) ParseActions(actions string) error {
 	// first we execute metadata rules
 	for _, a := range act {
 		if a.Atype == plugintypes.ActionTypeMetadata {
-			if err := a.F.Init(rp.rule, a.Value); err != nil {
+			if err := rp.rule.InitAction(a.Key, a.Value, a.F); err != nil {
 				return fmt.Errorf("failed to init action %s: %s", a.Key, err.Error())
 			}
 		}
@@ -299,7 +360,7 @@ func (rp *RuleParser) ParseActions(actions string) error {
 		if action.Atype == plugintypes.ActionTypeMetadata {
 			continue
 		}
-		if err := action.F.Init(rp.rule, action.Value); err != nil {
+		if err := rp.rule.InitAction(action.Key, action.Value, action.F); err != nil {
 			return err
 		}
 		if err := rp.rule.AddAction(action.Key, action.F); err != nil {
@@ -334,33 +395,11 @@ func ParseRule(options RuleOptions) (*corazawaf.Rule, error) {
 		return nil, errors.New("empty rule")
 	}
 
-	var err error
-	rp := RuleParser{
-		options:        options,
-		rule:           corazawaf.NewRule(),
-		defaultActions: map[types.RulePhase][]ruleAction{},
-	}
-	var defaultActionsRaw []string
-	// Default actions are persisted only inside the ParserConfig, therefore they are parsed every time a rule is parsed
-	// and not just once when the SecDefaultAction is read.
-	if options.ParserConfig.HasRuleDefaultActions {
-		defaultActionsRaw = options.ParserConfig.RuleDefaultActions
+	rp, err := newRuleParser(options)
+	if err != nil {
+		return nil, err
 	}
 	disabledRuleOperators := options.ParserConfig.DisabledRuleOperators
-	for _, da := range defaultActionsRaw {
-
-		err = rp.ParseDefaultActions(da)
-		if err != nil {
-			return nil, err
-		}
-	}
-	// If no default actions for phase 2 are defined, defaultActionsPhase2 variable (hardcoded default actions for phase 2) is used.
-	if rp.defaultActions[types.PhaseRequestBody] == nil {
-		err = rp.ParseDefaultActions(defaultActionsPhase2)
-		if err != nil {
-			return nil, err
-		}
-	}
 	actions := ""
 
 	if options.WithOperator {
@@ -390,7 +429,40 @@ func ParseRule(options RuleOptions) (*corazawaf.Rule, error) {
 			return nil, err
 		}
 	}
-	rule := rp.Rule()
+	rp.recordImplicitRxFlags()
+	return linkRule(rp.Rule(), options), nil
+}
+
+// recordImplicitRxFlags adds the rule, or the rule it is chained to, to the
+// rules of its file relying on the implicit rx flags, which the parser
+// reports once per file
+func (rp *RuleParser) recordImplicitRxFlags() {
+	if !rp.implicitRxFlags || rp.options.WAF == nil {
+		return
+	}
+	id := rp.rule.ID_
+	if parent := getLastRuleExpectingChain(rp.options.WAF); parent != nil {
+		id = parent.ID_
+	}
+	config := rp.options.ParserConfig
+	if config.implicitRxFlagRules == nil {
+		// the rules compiled without a parser are reported one by one
+		rp.options.WAF.Logger.Warn().
+			Str("file", config.ConfigFile).
+			Int("rule_id", id).
+			Msg("Rule relies on the implicit rx flags, set them with SecRxDefaultFlags")
+		return
+	}
+	ids := config.implicitRxFlagRules[config.ConfigFile]
+	if len(ids) == 0 || ids[len(ids)-1] != id {
+		config.implicitRxFlagRules[config.ConfigFile] = append(ids, id)
+	}
+}
+
+// linkRule sets the location of the compiled rule and attaches it to the
+// last rule of the WAF expecting a chain. It returns nil when the rule
+// has been chained.
+func linkRule(rule *corazawaf.Rule, options RuleOptions) *corazawaf.Rule {
 	rule.File_ = options.ParserConfig.ConfigFile
 	rule.Line_ = options.ParserConfig.LastLine
 
@@ -408,12 +480,63 @@ func ParseRule(options RuleOptions) (*corazawaf.Rule, error) {
 		lastChain.Chain = rule
 		// This way we store the raw rule in the parent
 		parent.Raw_ += " \n" + options.Raw
-		return nil, nil
+		return nil
 	} else {
 		// we only want Raw for the parent
 		rule.Raw_ = options.Raw
 	}
-	return rule, nil
+	return rule
+}
+
+// addRule adds the compiled rule to the WAF. If the parser allows overriding
+// duplicate rule IDs, a rule using the ID of a previous one replaces it.
+func addRule(waf *corazawaf.WAF, config ParserConfig, rule *corazawaf.Rule) error {
+	if rule != nil && rule.ID_ != 0 && config.OverrideDuplicateRuleIDs {
+		if previous := waf.Rules.FindByID(rule.ID_); previous != nil {
+			waf.Logger.Warn().
+				Int("rule_id", rule.ID_).
+				Str("previous", previous.Location()).
+				Str("override", rule.Location()).
+				Msg("Overriding rule with duplicate ID")
+			waf.Rules.DeleteByID(rule.ID_)
+		}
+	}
+	return waf.Rules.Add(rule)
+}
+
+// newRuleParser creates a RuleParser for a new rule, loading the default
+// actions configured in the parser
+func newRuleParser(options RuleOptions) (*RuleParser, error) {
+	var err error
+	rp := &RuleParser{
+		options:        options,
+		rule:           corazawaf.NewRule(),
+		defaultActions: map[types.RulePhase][]ruleAction{},
+	}
+	if options.WAF != nil {
+		rp.rule.SetUnicodeMap(options.WAF.UnicodeMap)
+	}
+	var defaultActionsRaw []string
+	// Default actions are persisted only inside the ParserConfig, therefore they are parsed every time a rule is parsed
+	// and not just once when the SecDefaultAction is read.
+	if options.ParserConfig.HasRuleDefaultActions {
+		defaultActionsRaw = options.ParserConfig.RuleDefaultActions
+	}
+	for _, da := range defaultActionsRaw {
+
+		err = rp.ParseDefaultActions(da)
+		if err != nil {
+			return nil, err
+		}
+	}
+	// If no default actions for phase 2 are defined, defaultActionsPhase2 variable (hardcoded default actions for phase 2) is used.
+	if rp.defaultActions[types.PhaseRequestBody] == nil {
+		err = rp.ParseDefaultActions(defaultActionsPhase2)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return rp, nil
 }
 
 func parseActionOperator(data string) (vars string, op string, actions string, err error) {
@@ -567,7 +690,7 @@ func appendRuleAction(res []ruleAction, key string, val string, disruptiveAction
 	val = utils.MaybeRemoveQuotes(val)
 	f, err := actionsmod.Get(key)
 	if err != nil {
-		return res, unset, err
+		return res, unset, unknownRuleError{err}
 	}
 	if f.Type() == plugintypes.ActionTypeDisruptive && disruptiveActionIndex != unset {
 		// There can only be one disruptive action per rule (if there are multiple disruptive
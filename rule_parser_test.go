@@ -7,6 +7,7 @@ import (
 	"bytes"
 	"errors"
 	"reflect"
+	"slices"
 	"strings"
 	"testing"
 
@@ -64,6 +65,138 @@ func TestVariables(t *testing.T) {
 	}
 }
 
+func TestNormalizedURLVariables(t *testing.T) {
+	waf := corazawaf.NewWAF()
+	if err := NewParser(waf).FromString(`
+SecRule REQUEST_URL_NORMALIZED "@streq http://example.com/api/users/42" "id:1,phase:1,pass,nolog"
+SecRule &REQUEST_PATH_SEGMENTS "@eq 3" "id:2,phase:1,pass,nolog"
+SecRule REQUEST_PATH_SEGMENTS:0 "@streq api" "id:3,phase:1,pass,nolog"
+SecRule REQUEST_PATH_SEGMENTS:2 "@rx ^\d+$" "id:4,phase:1,pass,nolog"
+SecRule REQUEST_PATH_SEGMENTS:3 "@rx ." "id:5,phase:1,pass,nolog"
+SecRule REQUEST_PATH_SEGMENTS:/^[01]$/ "@streq users" "id:6,phase:1,pass,nolog"
+`); err != nil {
+		t.Fatal(err)
+	}
+
+	tx := waf.NewTransaction()
+	defer tx.Close()
+	tx.ProcessURI("/api//v1/../users/./42", "GET", "HTTP/1.1")
+	tx.AddRequestHeader("Host", "Example.com:80")
+	tx.ProcessRequestHeaders()
+	var matched []int
+	for _, mr := range tx.MatchedRules() {
+		matched = append(matched, mr.Rule().ID())
+	}
+	if want, have := []int{1, 2, 3, 4, 6}, matched; !slices.Equal(want, have) {
+		t.Errorf("unexpected matched rules, want %v, have %v", want, have)
+	}
+
+	rule := `SecRule REQUEST_URL_NORMALIZED:foo "@rx a" "id:1,phase:1"`
+	if err := NewParser(corazawaf.NewWAF()).FromString(rule); err == nil {
+		t.Errorf("expected error for %q", rule)
+	}
+}
+
+func TestContentTypeAnomaliesVariables(t *testing.T) {
+	waf := corazawaf.NewWAF()
+	if err := NewParser(waf).FromString(`
+SecAction "id:10,phase:1,pass,nolog,setvar:tx.content_type_anomalies_unsafe_charset=utf-7,setvar:tx.unsafe_charset=utf-7"
+SecRule &REQUEST_CONTENT_TYPE_ANOMALIES "@eq 2" "id:1,phase:1,pass,nolog"
+SecRule REQUEST_CONTENT_TYPE_ANOMALIES:multiple_headers "@ge 2" "id:2,phase:1,pass,nolog"
+SecRule REQUEST_CONTENT_TYPE_ANOMALIES:conflicting_boundaries "@streq a, b" "id:3,phase:1,pass,nolog"
+SecRule REQUEST_CONTENT_TYPE_ANOMALIES:unsafe_charset "@rx ." "id:4,phase:1,pass,nolog"
+SecRule REQUEST_CONTENT_TYPE_ANOMALIES:/^multiple/ "@ge 2" "id:5,phase:1,pass,nolog"
+`); err != nil {
+		t.Fatal(err)
+	}
+
+	tx := waf.NewTransaction()
+	defer tx.Close()
+	tx.AddRequestHeader("Content-Type", "multipart/form-data; boundary=a")
+	tx.AddRequestHeader("Content-Type", "multipart/form-data; boundary=b")
+	tx.ProcessRequestHeaders()
+	var matched []int
+	for _, mr := range tx.MatchedRules() {
+		matched = append(matched, mr.Rule().ID())
+	}
+	// the TX keys set by the rules are not anomalies
+	if want, have := []int{10, 1, 2, 3, 5}, matched; !slices.Equal(want, have) {
+		t.Errorf("unexpected matched rules, want %v, have %v", want, have)
+	}
+}
+
+func TestResponseCookiesVariables(t *testing.T) {
+	waf := corazawaf.NewWAF()
+	if err := NewParser(waf).FromString(`
+SecRule RESPONSE_COOKIES:session "@streq abc" "id:1,phase:3,pass,nolog"
+SecRule &RESPONSE_COOKIES "@eq 2" "id:2,phase:3,pass,nolog"
+SecRule RESPONSE_COOKIES_ATTRS:session.samesite "@streq Lax" "id:3,phase:3,pass,nolog"
+SecRule RESPONSE_COOKIES_ATTRS:Secure "@eq 0" "id:4,phase:3,pass,log,msg:'Insecure cookie %{MATCHED_VAR_NAME}'"
+SecRule RESPONSE_COOKIES_ATTRS:session.secure "@eq 0" "id:5,phase:3,pass,nolog"
+`); err != nil {
+		t.Fatal(err)
+	}
+
+	tx := waf.NewTransaction()
+	defer tx.Close()
+	tx.ProcessRequestHeaders()
+	if _, err := tx.ProcessRequestBody(); err != nil {
+		t.Fatal(err)
+	}
+	tx.AddResponseHeader("Set-Cookie", "session=abc; Secure; SameSite=Lax")
+	tx.AddResponseHeader("Set-Cookie", "tracking=1; Path=/")
+	tx.ProcessResponseHeaders(200, "HTTP/1.1")
+	var matched []int
+	for _, mr := range tx.MatchedRules() {
+		matched = append(matched, mr.Rule().ID())
+		if mr.Rule().ID() == 4 {
+			if want, have := "Insecure cookie RESPONSE_COOKIES_ATTRS:tracking.secure", mr.Message(); want != have {
+				t.Errorf("unexpected message, want %q, have %q", want, have)
+			}
+		}
+	}
+	if want, have := []int{1, 2, 3, 4}, matched; !slices.Equal(want, have) {
+		t.Errorf("unexpected matched rules, want %v, have %v", want, have)
+	}
+}
+
+func TestSecurityHeadersVariables(t *testing.T) {
+	waf := corazawaf.NewWAF()
+	if err := NewParser(waf).FromString(`
+SecSecurityHeadersEngine On
+SecSecurityHeaderDefault X-Frame-Options "DENY"
+SecAction "id:10,phase:3,pass,nolog,setvar:tx.security_headers_csp=ok"
+SecRule SECURITY_HEADERS:csp "@streq weak" "id:1,phase:3,pass,log,msg:'Weak CSP: %{SECURITY_HEADERS.csp_reason}'"
+SecRule SECURITY_HEADERS:hsts "@streq injected" "id:2,phase:3,pass,nolog"
+SecRule RESPONSE_HEADERS:X-Frame-Options "@streq DENY" "id:3,phase:3,pass,nolog"
+SecRule SECURITY_HEADERS "@streq missing" "id:4,phase:3,pass,nolog"
+SecRule SECURITY_HEADERS:/_reason$/ "@streq unsafe-inline" "id:5,phase:3,pass,nolog"
+`); err != nil {
+		t.Fatal(err)
+	}
+
+	tx := waf.NewTransaction()
+	defer tx.Close()
+	tx.ProcessRequestHeaders()
+	if _, err := tx.ProcessRequestBody(); err != nil {
+		t.Fatal(err)
+	}
+	tx.AddResponseHeader("Content-Security-Policy", "default-src 'self' 'unsafe-inline'")
+	tx.ProcessResponseHeaders(200, "HTTP/1.1")
+	var matched []int
+	for _, mr := range tx.MatchedRules() {
+		matched = append(matched, mr.Rule().ID())
+		if mr.Rule().ID() == 1 {
+			if want, have := "Weak CSP: unsafe-inline", mr.Message(); want != have {
+				t.Errorf("unexpected message, want %q, have %q", want, have)
+			}
+		}
+	}
+	if want, have := []int{10, 1, 2, 3, 5}, matched; !slices.Equal(want, have) {
+		t.Errorf("unexpected matched rules, want %v, have %v", want, have)
+	}
+}
+
 func TestVariableCases(t *testing.T) {
 	waf := corazawaf.NewWAF()
 	p := NewParser(waf)
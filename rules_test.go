@@ -273,6 +273,33 @@ func TestPrintedExtraMsgAndDataFromRuleWithMultipleMatches(t *testing.T) {
 		t.Errorf("failed to log second logdata, expected %q occurence, got %v", "1 in ARGS_GET:test", logs[0])
 	}
 }
+func TestMaskedMatchedVar(t *testing.T) {
+	waf := corazawaf.NewWAF()
+	var logs []string
+	waf.SetErrorCallback(func(mr types.MatchedRule) {
+		logs = append(logs, mr.ErrorLog())
+	})
+	err := NewParser(waf).FromString(`
+	SecRule ARGS_GET "@verifyCC (\d{13,19})" "id:1, phase:1, log, pass, logdata:'%{MATCHED_VAR} in %{MATCHED_VAR_NAME}'"
+	`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tx := waf.NewTransaction()
+	defer tx.Close()
+	tx.AddGetRequestArgument("card", "pay with 5484605089158216")
+	tx.ProcessRequestHeaders()
+	if len(logs) != 1 {
+		t.Fatalf("unexpected logs, want 1, have %d", len(logs))
+	}
+	if want := "pay with 548460******8216 in ARGS_GET:card"; !strings.Contains(logs[0], want) {
+		t.Errorf("expected the card number to be masked, want %q in %q", want, logs[0])
+	}
+	if strings.Contains(logs[0], "5484605089158216") {
+		t.Errorf("unexpected card number in %q", logs[0])
+	}
+}
+
 func TestPrintedExtraMsgAndDataFromChainedRules(t *testing.T) {
 	waf := corazawaf.NewWAF()
 	var logs []string
@@ -366,6 +393,99 @@ func TestStatusFromInterruptions(t *testing.T) {
 	}
 }
 
+func TestPersistentCollections(t *testing.T) {
+	waf := corazawaf.NewWAF()
+	parser := NewParser(waf)
+	err := parser.FromString(`
+		SecRuleEngine On
+		SecAction "id:1,phase:1,nolog,pass,initcol:ip=%{REMOTE_ADDR}"
+		SecAction "id:2,phase:1,nolog,pass,setvar:ip.requests=+1"
+		SecRule IP:requests "@gt 2" "id:3,phase:1,deny,status:429"
+	`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, interrupted := range []bool{false, false, true} {
+		tx := waf.NewTransaction()
+		tx.ProcessConnection("10.0.0.1", 0, "", 0)
+		it := tx.ProcessRequestHeaders()
+		if (it != nil) != interrupted {
+			t.Errorf("unexpected interruption of request %d, want %t, have %v", i+1, interrupted, it)
+		}
+		if err := tx.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	data, ok := waf.PersistentCollections.Get("ip", "10.0.0.1")
+	if !ok {
+		t.Fatal("expected the IP collection to be stored")
+	}
+	if want, have := []string{"3"}, data["requests"]; len(have) != 1 || have[0] != want[0] {
+		t.Errorf("unexpected requests, want %v, have %v", want, have)
+	}
+	if _, ok := waf.PersistentCollections.Get("ip", "10.0.0.2"); ok {
+		t.Error("unexpected IP collection of another address")
+	}
+
+	// the fields stored meanwhile by another writer, e.g. an asynchronous
+	// @rbl lookup, are kept
+	tx := waf.NewTransaction()
+	tx.ProcessConnection("10.0.0.1", 0, "", 0)
+	tx.ProcessRequestHeaders()
+	waf.PersistentCollections.Update("ip", "10.0.0.1", func(data map[string][]string) {
+		data["rbl.example.org"] = []string{"1"}
+	})
+	if err := tx.Close(); err != nil {
+		t.Fatal(err)
+	}
+	data, _ = waf.PersistentCollections.Get("ip", "10.0.0.1")
+	for field, want := range map[string]string{"requests": "4", "rbl.example.org": "1"} {
+		if have := data[field]; len(have) != 1 || have[0] != want {
+			t.Errorf("unexpected %s, want %q, have %q", field, want, have)
+		}
+	}
+}
+
+func TestDenyBodyFromInterruptions(t *testing.T) {
+	waf := corazawaf.NewWAF()
+	parser := NewParser(waf)
+	err := parser.FromString(`
+		SecDefaultAction "phase:2,log,deny,status:403"
+		SecRule ARGS:id "!@rx ^[0-9]+$" "phase:1,id:1,deny,status:400,denybody:'{\"error\":\"E100\",\"value\":\"%{MATCHED_VAR}\"}'"
+		SecRule ARGS:q "@streq attack" "phase:2,id:2,block,denybody:'blocked'"
+		SecRule ARGS:q "@streq other" "phase:2,id:3,deny"
+		SecRule ARGS:name "@rx <" "phase:2,id:4,deny,denybody:'<p>Invalid name %{MATCHED_VAR}</p>'"
+	`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		arg, value string
+		want       string
+	}{
+		{"id", "abc", `{"error":"E100","value":"abc"}`},
+		{"id", `a","error":"none`, `{"error":"E100","value":"a\",\"error\":\"none"}`},
+		{"q", "attack", "blocked"},
+		{"q", "other", ""},
+		{"name", "<script>", "<p>Invalid name &lt;script&gt;</p>"},
+	}
+	for _, tc := range tests {
+		tx := waf.NewTransaction()
+		tx.AddGetRequestArgument(tc.arg, tc.value)
+		it := tx.ProcessRequestHeaders()
+		if it == nil {
+			it, _ = tx.ProcessRequestBody()
+		}
+		if it == nil {
+			t.Errorf("expected %s=%s to be denied", tc.arg, tc.value)
+		} else if want, have := tc.want, it.Data; want != have {
+			t.Errorf("unexpected interruption body, want %q, have %q", want, have)
+		}
+		tx.Close()
+	}
+}
+
 func TestChainWithUnconditionalMatch(t *testing.T) {
 	waf := corazawaf.NewWAF()
 	p := NewParser(waf)
@@ -564,6 +684,25 @@ func TestRxCapture(t *testing.T) {
 	}
 }
 
+func TestRxNamedCapture(t *testing.T) {
+	waf := corazawaf.NewWAF()
+	parser := NewParser(waf)
+	err := parser.FromString(`SecRule ARGS:user "@rx ^(?P<user_name>[a-z]+)@(?P<user_domain>[a-z.]+)$" \
+	"id:1,phase:1,pass,capture,chain"
+	SecRule TX:user_domain "@streq internal.example" "setvar:'tx.blocked_user=%{tx.user_name}'"
+SecRule TX:blocked_user "@streq admin" "id:2,phase:1,deny,status:403"`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tx := waf.NewTransaction()
+	tx.AddGetRequestArgument("user", "admin@internal.example")
+	it := tx.ProcessRequestHeaders()
+	if it == nil || it.RuleID != 2 {
+		t.Errorf("expected interruption by the rule referencing the named captures, have %v", it)
+	}
+}
+
 func TestUnicode(t *testing.T) {
 	waf := corazawaf.NewWAF()
 	rules := `SecRule ARGS "@rx \x{30cf}\x{30ed}\x{30fc}" "id:101,phase:2,t:lowercase,deny"`